@@ -0,0 +1,270 @@
+package foodblock
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ExchangeRates converts amounts between currencies.
+type ExchangeRates interface {
+	Rate(from, to, date string) (float64, error)
+}
+
+// StaticRates is an ExchangeRates backed by fixed rates against one base currency
+// (rate = units of currency per 1 unit of base). The date is ignored.
+type StaticRates struct {
+	Base  string
+	Rates map[string]float64
+}
+
+// Rate returns the multiplier converting an amount in from to an amount in to.
+func (s StaticRates) Rate(from, to, date string) (float64, error) {
+	if strings.EqualFold(from, to) {
+		return 1, nil
+	}
+	perBase := func(c string) (float64, error) {
+		if strings.EqualFold(c, s.Base) {
+			return 1, nil
+		}
+		if r, ok := s.Rates[strings.ToUpper(c)]; ok && r > 0 {
+			return r, nil
+		}
+		return 0, fmt.Errorf("FoodBlock: no exchange rate for %s", c)
+	}
+	rf, err := perBase(from)
+	if err != nil {
+		return 0, err
+	}
+	rt, err := perBase(to)
+	if err != nil {
+		return 0, err
+	}
+	return rt / rf, nil
+}
+
+// JournalAccounts names the debit and credit accounts for a block type.
+type JournalAccounts struct {
+	Debit  string `json:"debit"`
+	Credit string `json:"credit"`
+}
+
+// DefaultJournalAccounts maps exportable block types to ledger accounts.
+var DefaultJournalAccounts = map[string]JournalAccounts{
+	"transfer.order":    {Debit: "Accounts Receivable", Credit: "Sales"},
+	"transfer.invoice":  {Debit: "Accounts Receivable", Credit: "Sales"},
+	"transfer.donation": {Debit: "Charitable Donations", Credit: "Inventory"},
+}
+
+// JournalOptions configures ExportJournal.
+type JournalOptions struct {
+	From, To        time.Time                  // inclusive period; zero values are open-ended
+	Currency        string                     // reporting currency, default USD
+	Accounts        map[string]JournalAccounts // overrides DefaultJournalAccounts per type
+	Rates           ExchangeRates              // required when block currencies differ
+	DefaultCurrency string                     // for blocks without state.currency, default Currency
+}
+
+// JournalLine is one side of a journal entry.
+type JournalLine struct {
+	Account string  `json:"account"`
+	Debit   float64 `json:"debit,omitempty"`
+	Credit  float64 `json:"credit,omitempty"`
+}
+
+// JournalEntry is a balanced double-entry journal derived from one block.
+type JournalEntry struct {
+	Number       int           `json:"number"`
+	Date         string        `json:"date"`
+	Currency     string        `json:"currency"`
+	Memo         string        `json:"memo"`
+	BlockHash    string        `json:"block_hash"`
+	Counterparty string        `json:"counterparty,omitempty"`
+	Lines        []JournalLine `json:"lines"`
+}
+
+// confirmedStatuses are order statuses that have financial effect.
+var confirmedStatuses = map[string]bool{
+	"confirmed": true, "processing": true, "shipped": true, "delivered": true, "paid": true, "completed": true,
+}
+
+// ExportJournal converts confirmed orders, invoices and donations dated within
+// the period into journal entries in the reporting currency, ordered by date.
+// Only the latest version of each update chain is booked.
+func ExportJournal(blocks []Block, opts JournalOptions) ([]JournalEntry, error) {
+	if opts.Currency == "" {
+		opts.Currency = "USD"
+	}
+	if opts.DefaultCurrency == "" {
+		opts.DefaultCurrency = opts.Currency
+	}
+
+	type dated struct {
+		block Block
+		when  time.Time
+	}
+	var selected []dated
+	for _, b := range FilterBlocks(blocks, QueryParams{HeadsOnly: true, IncludeExpired: true}) {
+		accounts, ok := opts.Accounts[b.Type]
+		if !ok {
+			accounts, ok = DefaultJournalAccounts[b.Type]
+		}
+		if !ok || accounts.Debit == "" || accounts.Credit == "" {
+			continue
+		}
+		if b.Type == "transfer.order" {
+			status, _ := b.State["status"].(string)
+			if !confirmedStatuses[status] {
+				continue
+			}
+		}
+		when, ok := BlockDate(b)
+		if !ok {
+			continue
+		}
+		if (!opts.From.IsZero() && when.Before(opts.From)) || (!opts.To.IsZero() && when.After(opts.To)) {
+			continue
+		}
+		selected = append(selected, dated{b, when})
+	}
+	sort.SliceStable(selected, func(i, j int) bool { return selected[i].when.Before(selected[j].when) })
+
+	entries := make([]JournalEntry, 0, len(selected))
+	for _, d := range selected {
+		b := d.block
+		amount, ok := blockAmount(b)
+		if !ok || amount == 0 {
+			continue
+		}
+		currency, _ := b.State["currency"].(string)
+		if currency == "" {
+			currency = opts.DefaultCurrency
+		}
+		date := d.when.Format("2006-01-02")
+		if !strings.EqualFold(currency, opts.Currency) {
+			if opts.Rates == nil {
+				return nil, fmt.Errorf("FoodBlock: block %s is in %s but no exchange rates were given", b.Hash, currency)
+			}
+			rate, err := opts.Rates.Rate(currency, opts.Currency, date)
+			if err != nil {
+				return nil, err
+			}
+			amount *= rate
+		}
+		amount = roundCents(amount)
+
+		accounts, ok := opts.Accounts[b.Type]
+		if !ok {
+			accounts = DefaultJournalAccounts[b.Type]
+		}
+		counterparty, _ := b.Refs["buyer"].(string)
+		if counterparty == "" {
+			counterparty, _ = b.Refs["recipient"].(string)
+		}
+		entries = append(entries, JournalEntry{
+			Number:       len(entries) + 1,
+			Date:         date,
+			Currency:     strings.ToUpper(opts.Currency),
+			Memo:         journalMemo(b),
+			BlockHash:    b.Hash,
+			Counterparty: counterparty,
+			Lines: []JournalLine{
+				{Account: accounts.Debit, Debit: amount},
+				{Account: accounts.Credit, Credit: amount},
+			},
+		})
+	}
+	return entries, nil
+}
+
+// FormatJournalCSV renders journal entries in the "quickbooks" journal import
+// layout or the "xero" manual journal import layout.
+func FormatJournalCSV(entries []JournalEntry, format string) (string, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	money := func(v float64) string {
+		if v == 0 {
+			return ""
+		}
+		return fmt.Sprintf("%.2f", v)
+	}
+
+	switch format {
+	case "quickbooks":
+		w.Write([]string{"JournalNo", "JournalDate", "Currency", "Memo", "Account", "Debits", "Credits", "Description", "Name"})
+		for _, e := range entries {
+			for _, l := range e.Lines {
+				w.Write([]string{fmt.Sprint(e.Number), e.Date, e.Currency, e.Memo, l.Account, money(l.Debit), money(l.Credit), "fb:" + e.BlockHash, e.Counterparty})
+			}
+		}
+	case "xero":
+		w.Write([]string{"*Narration", "*Date", "Description", "*AccountCode", "*TaxRate", "*Amount", "TrackingName1", "TrackingOption1"})
+		for _, e := range entries {
+			for _, l := range e.Lines {
+				amount := l.Debit - l.Credit
+				w.Write([]string{e.Memo, e.Date, "fb:" + e.BlockHash, l.Account, "Tax Exempt", fmt.Sprintf("%.2f", amount), "", ""})
+			}
+		}
+	default:
+		return "", fmt.Errorf("FoodBlock: unknown journal format: %s", format)
+	}
+	w.Flush()
+	return buf.String(), w.Error()
+}
+
+// BlockDate returns the business date recorded in a block's state
+// (date, created_at or timestamp; RFC 3339 or YYYY-MM-DD).
+func BlockDate(b Block) (time.Time, bool) {
+	for _, field := range []string{"date", "created_at", "timestamp"} {
+		s, ok := b.State[field].(string)
+		if !ok || s == "" {
+			continue
+		}
+		for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"} {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// blockAmount reads a monetary amount from total, amount, value or price × quantity.
+// Quantity objects ({value, unit}) contribute their value.
+func blockAmount(b Block) (float64, bool) {
+	for _, field := range []string{"total", "amount", "value"} {
+		if v, ok := numericValue(b.State[field]); ok {
+			return v, true
+		}
+	}
+	price, ok := numericValue(b.State["price"])
+	if !ok {
+		return 0, false
+	}
+	if qty, ok := numericValue(b.State["quantity"]); ok {
+		return price * qty, true
+	}
+	return price, true
+}
+
+// numericValue reads a number or the value of a {value, unit} quantity.
+func numericValue(v interface{}) (float64, bool) {
+	if m, ok := v.(map[string]interface{}); ok {
+		return toFloat64(m["value"])
+	}
+	return toFloat64(v)
+}
+
+func journalMemo(b Block) string {
+	label := strings.TrimPrefix(b.Type, "transfer.")
+	if name, ok := b.State["name"].(string); ok && name != "" {
+		return label + ": " + name
+	}
+	if ref, ok := b.State["instance_id"].(string); ok && ref != "" {
+		return label + " " + ref
+	}
+	return label + " " + b.Hash[:12]
+}
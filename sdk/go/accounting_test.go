@@ -0,0 +1,82 @@
+package foodblock
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func accountingFixture() []Block {
+	return []Block{
+		Create("transfer.order", map[string]interface{}{"instance_id": "o1", "status": "confirmed", "total": 120.0, "currency": "USD", "date": "2026-03-02"}, map[string]interface{}{"buyer": "cafe"}),
+		Create("transfer.order", map[string]interface{}{"instance_id": "o2", "status": "draft", "total": 99.0, "date": "2026-03-03"}, nil),
+		Create("transfer.invoice", map[string]interface{}{"instance_id": "i1", "amount": 50.0, "currency": "GBP", "date": "2026-03-04"}, nil),
+		Create("transfer.donation", map[string]interface{}{"instance_id": "d1", "price": 2.5, "quantity": 4, "date": "2026-03-05"}, nil),
+		Create("transfer.order", map[string]interface{}{"instance_id": "o3", "status": "paid", "total": 10.0, "date": "2026-04-10"}, nil),
+	}
+}
+
+func TestExportJournalPeriodAndConversion(t *testing.T) {
+	rates := StaticRates{Base: "USD", Rates: map[string]float64{"GBP": 0.8}}
+	entries, err := ExportJournal(accountingFixture(), JournalOptions{
+		From:  time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		To:    time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC),
+		Rates: rates,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected confirmed order, invoice and donation in March, got %d", len(entries))
+	}
+	if entries[1].Lines[0].Debit != 62.5 {
+		t.Errorf("expected GBP 50 converted to USD 62.50, got %v", entries[1].Lines[0].Debit)
+	}
+	if entries[2].Lines[0].Account != "Charitable Donations" || entries[2].Lines[0].Debit != 10 {
+		t.Errorf("unexpected donation entry: %+v", entries[2])
+	}
+	for _, e := range entries {
+		if e.Lines[0].Debit != e.Lines[1].Credit {
+			t.Errorf("entry %d is unbalanced", e.Number)
+		}
+	}
+}
+
+func TestExportJournalBooksChainHeads(t *testing.T) {
+	order := Create("transfer.order", map[string]interface{}{"instance_id": "o1", "status": "confirmed", "total": 120.0, "date": "2026-03-02"}, nil)
+	shipped := MergeUpdate(order, map[string]interface{}{"status": "shipped"}, nil)
+	paid := MergeUpdate(shipped, map[string]interface{}{"status": "paid", "total": 125.0}, nil)
+	entries, err := ExportJournal([]Block{order, shipped, paid}, JournalOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Lines[0].Debit != 125 {
+		t.Fatalf("expected the order booked once at its latest total, got %+v", entries)
+	}
+}
+
+func TestExportJournalRequiresRates(t *testing.T) {
+	if _, err := ExportJournal(accountingFixture(), JournalOptions{}); err == nil {
+		t.Error("expected error for GBP invoice without exchange rates")
+	}
+}
+
+func TestFormatJournalCSV(t *testing.T) {
+	entries, _ := ExportJournal(accountingFixture()[:1], JournalOptions{
+		Accounts: map[string]JournalAccounts{"transfer.order": {Debit: "1200", Credit: "4000"}},
+	})
+	qb, err := FormatJournalCSV(entries, "quickbooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(qb, "1,2026-03-02,USD,order o1,1200,120.00,,") {
+		t.Errorf("unexpected QuickBooks CSV:\n%s", qb)
+	}
+	xero, _ := FormatJournalCSV(entries, "xero")
+	if !strings.Contains(xero, "4000,Tax Exempt,-120.00") {
+		t.Errorf("expected negative credit line in Xero CSV:\n%s", xero)
+	}
+	if _, err := FormatJournalCSV(entries, "sage"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
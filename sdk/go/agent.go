@@ -12,6 +12,10 @@ type Agent struct {
 	PublicKey  []byte
 	PrivateKey []byte
 	AuthorHash string
+	// Log, when set, receives an observe.agent_action block for every draft.
+	// It is nil unless the caller sets it, since an ActionLog keeps every
+	// entry in memory.
+	Log *ActionLog
 }
 
 // CreateAgent creates a new AI agent with an Ed25519 keypair.
@@ -52,6 +56,14 @@ func (a *Agent) Sign(block Block) SignedBlock {
 
 // CreateDraft creates a draft block on behalf of this agent.
 func (a *Agent) CreateDraft(typ string, state map[string]interface{}, refs map[string]interface{}) (Block, SignedBlock) {
+	block, signed := a.createDraft(typ, state, refs)
+	if a.Log != nil {
+		a.Log.Record(CreateAction(a.AuthorHash, "create_draft", AgentAction{}, block))
+	}
+	return block, signed
+}
+
+func (a *Agent) createDraft(typ string, state map[string]interface{}, refs map[string]interface{}) (Block, SignedBlock) {
 	if state == nil {
 		state = map[string]interface{}{}
 	}
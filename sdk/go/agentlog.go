@@ -0,0 +1,132 @@
+package foodblock
+
+import (
+	"sort"
+	"sync"
+)
+
+// AgentAction describes an action an agent is about to take and why.
+type AgentAction struct {
+	Intent   string   // short summary of what the agent was asked or decided to do
+	Prompt   string   // optional prompt or conversation excerpt that led to the action
+	Evidence []string // hashes of blocks the agent relied on
+	Type     string
+	State    map[string]interface{}
+	Refs     map[string]interface{}
+}
+
+// ActionLog records observe.agent_action blocks for an agent's drafts.
+type ActionLog struct {
+	mu      sync.RWMutex
+	entries []Block
+	byDraft map[string]int
+}
+
+// NewActionLog returns an empty action log.
+func NewActionLog() *ActionLog {
+	return &ActionLog{byDraft: make(map[string]int)}
+}
+
+// Record appends an action block to the log.
+func (l *ActionLog) Record(action Block) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, action)
+	if draft, ok := action.Refs["draft"].(string); ok {
+		l.byDraft[draft] = len(l.entries) - 1
+	}
+}
+
+// Entries returns all recorded action blocks, oldest first.
+func (l *ActionLog) Entries() []Block {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return append([]Block(nil), l.entries...)
+}
+
+// Recent returns the last n action blocks, oldest first. It serves as the
+// agent's short-term memory when building the next prompt.
+func (l *ActionLog) Recent(n int) []Block {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if n <= 0 || n > len(l.entries) {
+		n = len(l.entries)
+	}
+	return append([]Block(nil), l.entries[len(l.entries)-n:]...)
+}
+
+// ForDraft returns the action that produced a draft, or nil.
+func (l *ActionLog) ForDraft(draftHash string) *Block {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	i, ok := l.byDraft[draftHash]
+	if !ok {
+		return nil
+	}
+	b := l.entries[i]
+	return &b
+}
+
+// Why explains the reasoning behind a draft: the recorded intent followed by
+// the provenance of each evidence block.
+func (l *ActionLog) Why(draftHash string, resolve func(string) *Block) string {
+	action := l.ForDraft(draftHash)
+	if action == nil {
+		return "No agent action recorded for " + draftHash
+	}
+	out := "Intent: "
+	if intent, ok := action.State["intent"].(string); ok && intent != "" {
+		out += intent
+	} else {
+		out += "(none recorded)"
+	}
+	out += "."
+	for _, h := range flattenRefValues(map[string]interface{}{"evidence": action.Refs["evidence"]}) {
+		if resolve == nil {
+			out += " Evidence: " + h + "."
+			continue
+		}
+		out += " Evidence: " + Explain(h, resolve, 3)
+	}
+	return out
+}
+
+// CreateAction builds an observe.agent_action block recording why an agent
+// produced a draft.
+func CreateAction(agentHash, kind string, action AgentAction, draft Block) Block {
+	state := map[string]interface{}{
+		"action":     kind,
+		"draft_type": draft.Type,
+	}
+	if action.Intent != "" {
+		state["intent"] = action.Intent
+	}
+	if action.Prompt != "" {
+		state["prompt"] = action.Prompt
+	}
+	refs := map[string]interface{}{
+		"agent": agentHash,
+		"draft": draft.Hash,
+	}
+	if len(action.Evidence) > 0 {
+		evidence := append([]string(nil), action.Evidence...)
+		sort.Strings(evidence)
+		list := make([]interface{}, len(evidence))
+		for i, h := range evidence {
+			list[i] = h
+		}
+		refs["evidence"] = list
+	}
+	return Create("observe.agent_action", state, refs)
+}
+
+// Execute creates a draft for the action and records an observe.agent_action
+// block in the agent's log. It returns the signed draft and the signed action.
+func (a *Agent) Execute(action AgentAction) (Block, SignedBlock, SignedBlock) {
+	draft, signed := a.createDraft(action.Type, action.State, action.Refs)
+	entry := CreateAction(a.AuthorHash, "execute", action, draft)
+	if a.Log != nil {
+		a.Log.Record(entry)
+	}
+	return draft, signed, a.Sign(entry)
+}
@@ -0,0 +1,58 @@
+package foodblock
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAgentExecuteRecordsAction(t *testing.T) {
+	operator := Create("actor.venue", map[string]interface{}{"name": "Bakery"}, nil)
+	agent, err := CreateAgent("Reorder bot", operator.Hash, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if agent.Log != nil {
+		t.Fatal("expected agents to keep no action log unless asked")
+	}
+	agent.Log = NewActionLog()
+	stock := Create("observe.reading", map[string]interface{}{"name": "Flour stock 4kg", "value": 4}, nil)
+	blocks := map[string]*Block{stock.Hash: &stock}
+
+	draft, _, signed := agent.Execute(AgentAction{
+		Intent:   "Reorder 50kg of flour: stock below 5kg threshold",
+		Evidence: []string{stock.Hash},
+		Type:     "transfer.order",
+		State:    map[string]interface{}{"quantity": 50, "unit": "kg"},
+	})
+	action := signed.FoodBlock
+	if action.Type != "observe.agent_action" {
+		t.Fatalf("type = %s", action.Type)
+	}
+	if action.Refs["draft"] != draft.Hash || action.Refs["agent"] != agent.AuthorHash {
+		t.Errorf("refs = %v", action.Refs)
+	}
+	if got := agent.Log.ForDraft(draft.Hash); got == nil || got.Hash != action.Hash {
+		t.Fatal("action not recorded in log")
+	}
+	why := agent.Log.Why(draft.Hash, func(h string) *Block { return blocks[h] })
+	if !strings.Contains(why, "Reorder 50kg") || !strings.Contains(why, "Flour stock") {
+		t.Errorf("why = %q", why)
+	}
+}
+
+func TestCreateDraftLogsAction(t *testing.T) {
+	agent, _ := CreateAgent("bot", "op", nil)
+	agent.CreateDraft("transfer.order", map[string]interface{}{"n": 0}, nil)
+	agent.Log = NewActionLog()
+	draft, _ := agent.CreateDraft("transfer.order", nil, nil)
+	agent.CreateDraft("transfer.order", map[string]interface{}{"n": 2}, nil)
+	if agent.Log.ForDraft(draft.Hash) == nil {
+		t.Fatal("CreateDraft not logged")
+	}
+	if n := len(agent.Log.Recent(1)); n != 1 {
+		t.Errorf("Recent(1) = %d entries", n)
+	}
+	if n := len(agent.Log.Entries()); n != 2 {
+		t.Errorf("entries = %d, want 2", n)
+	}
+}
@@ -0,0 +1,230 @@
+package foodblock
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// AgreementType is a standing trade agreement between a buyer and a seller.
+// Call-off orders are transfer.order blocks whose refs.agreement points to it.
+const AgreementType = "transfer.agreement"
+
+// Agreement describes the terms of a framework agreement. Prices are the
+// maximum unit price per product hash; Commitments caps the total quantity
+// that may be called off per product (products without one are uncapped).
+type Agreement struct {
+	Start       time.Time
+	End         time.Time
+	Currency    string
+	Unit        string
+	Prices      map[string]float64
+	Commitments map[string]float64
+}
+
+// CreateAgreement creates a transfer.agreement block.
+func CreateAgreement(buyer, seller string, a Agreement) (Block, error) {
+	if buyer == "" || seller == "" {
+		return Block{}, errors.New("FoodBlock: buyer and seller are required")
+	}
+	if a.Start.IsZero() || a.End.Before(a.Start) {
+		return Block{}, errors.New("FoodBlock: agreement needs a start and an end after it")
+	}
+	if len(a.Prices) == 0 {
+		return Block{}, errors.New("FoodBlock: agreement needs a price list")
+	}
+	prices := make(map[string]interface{}, len(a.Prices))
+	products := make([]string, 0, len(a.Prices))
+	for p, v := range a.Prices {
+		prices[p] = v
+		products = append(products, p)
+	}
+	sort.Strings(products)
+	productRefs := make([]interface{}, len(products))
+	for i, p := range products {
+		productRefs[i] = p
+	}
+	state := map[string]interface{}{
+		"start":      a.Start.Format("2006-01-02"),
+		"end":        a.End.Format("2006-01-02"),
+		"price_list": prices,
+	}
+	if len(a.Commitments) > 0 {
+		commitments := make(map[string]interface{}, len(a.Commitments))
+		for p, v := range a.Commitments {
+			commitments[p] = v
+		}
+		state["commitments"] = commitments
+	}
+	if a.Currency != "" {
+		state["currency"] = a.Currency
+	}
+	if a.Unit != "" {
+		state["unit"] = a.Unit
+	}
+	return Create(AgreementType, state, map[string]interface{}{
+		"buyer": buyer, "seller": seller, "products": productRefs,
+	}), nil
+}
+
+// CallOff creates a transfer.order against an agreement after checking it
+// with ValidateCallOff. prior holds the agreement's earlier call-offs.
+func CallOff(agreement Block, product string, quantity, price float64, date time.Time, prior []Block) (Block, error) {
+	state := map[string]interface{}{
+		"quantity": quantity,
+		"price":    price,
+		"total":    math.Round(price*quantity*100) / 100,
+		"date":     date.Format("2006-01-02"),
+	}
+	for _, k := range []string{"currency", "unit"} {
+		if v, ok := agreement.State[k]; ok {
+			state[k] = v
+		}
+	}
+	order := Create("transfer.order", state, map[string]interface{}{
+		"buyer":     agreement.Refs["buyer"],
+		"seller":    agreement.Refs["seller"],
+		"product":   product,
+		"agreement": agreement.Hash,
+	})
+	if err := ValidateCallOff(agreement, order, prior); err != nil {
+		return Block{}, err
+	}
+	return order, nil
+}
+
+// ValidateCallOff checks an order against its agreement: same parties, dated
+// within the term, a positive quantity of a listed product at a positive price
+// no higher than its list price, and the cumulative quantity (the latest
+// version of each prior call-off plus this one) within the commitment.
+func ValidateCallOff(agreement, order Block, prior []Block) error {
+	if agreement.Type != AgreementType {
+		return errors.New("FoodBlock: not a trade agreement")
+	}
+	if order.Refs["agreement"] != agreement.Hash {
+		return errors.New("FoodBlock: order does not reference the agreement")
+	}
+	if order.Refs["buyer"] != agreement.Refs["buyer"] || order.Refs["seller"] != agreement.Refs["seller"] {
+		return errors.New("FoodBlock: order parties differ from the agreement")
+	}
+	date, _ := order.State["date"].(string)
+	start, _ := agreement.State["start"].(string)
+	end, _ := agreement.State["end"].(string)
+	if date == "" || date < start || date > end {
+		return fmt.Errorf("FoodBlock: order date %q is outside the agreement term %s to %s", date, start, end)
+	}
+	product, _ := order.Refs["product"].(string)
+	prices, _ := agreement.State["price_list"].(map[string]interface{})
+	listPrice, ok := toFloat64(prices[product])
+	if !ok {
+		return fmt.Errorf("FoodBlock: product %s is not on the agreement price list", product)
+	}
+	qty, ok := numericValue(order.State["quantity"])
+	if !ok || qty <= 0 {
+		return fmt.Errorf("FoodBlock: call-off quantity must be positive, got %v", order.State["quantity"])
+	}
+	price, ok := numericValue(order.State["price"])
+	if !ok || price <= 0 {
+		return fmt.Errorf("FoodBlock: call-off price must be positive, got %v", order.State["price"])
+	}
+	if price > listPrice {
+		return fmt.Errorf("FoodBlock: price %.2f exceeds list price %.2f", price, listPrice)
+	}
+	commitments, _ := agreement.State["commitments"].(map[string]interface{})
+	if limit, ok := toFloat64(commitments[product]); ok {
+		// Including the order lets it supersede a prior version it updates.
+		called := calledOff(agreement.Hash, product, append(prior[:len(prior):len(prior)], order), order.Hash)
+		if called+qty > limit {
+			return fmt.Errorf("FoodBlock: call-off of %v would exceed the commitment of %v (%v already called off)", qty, limit, called)
+		}
+	}
+	return nil
+}
+
+// calledOff sums the quantity of a product already called off by the latest
+// version of each order, ignoring skip.
+func calledOff(agreementHash, product string, orders []Block, skip string) float64 {
+	total := 0.0
+	for _, o := range FilterBlocks(orders, QueryParams{HeadsOnly: true, IncludeExpired: true}) {
+		if o.Hash == skip || o.Refs["agreement"] != agreementHash || o.Refs["product"] != product {
+			continue
+		}
+		qty, _ := numericValue(o.State["quantity"])
+		total += qty
+	}
+	return total
+}
+
+// UtilizationLine reports one product's usage of an agreement.
+type UtilizationLine struct {
+	Product     string  `json:"product"`
+	ListPrice   float64 `json:"list_price"`
+	Committed   float64 `json:"committed,omitempty"`
+	CalledOff   float64 `json:"called_off"`
+	Remaining   float64 `json:"remaining,omitempty"`
+	Utilization float64 `json:"utilization,omitempty"` // called off / committed, 0..1+
+	Spend       float64 `json:"spend"`
+	Orders      int     `json:"orders"`
+}
+
+// AgreementReport summarises how much of an agreement has been used.
+type AgreementReport struct {
+	Agreement string            `json:"agreement"`
+	Start     string            `json:"start"`
+	End       string            `json:"end"`
+	Currency  string            `json:"currency,omitempty"`
+	Lines     []UtilizationLine `json:"lines"`
+	Spend     float64           `json:"spend"`
+	Orders    int               `json:"orders"`
+}
+
+// AgreementUtilization reports call-off volume and spend per product for an
+// agreement from the latest version of each order. Orders not referencing the
+// agreement are ignored.
+func AgreementUtilization(agreement Block, orders []Block) AgreementReport {
+	report := AgreementReport{Agreement: agreement.Hash}
+	report.Start, _ = agreement.State["start"].(string)
+	report.End, _ = agreement.State["end"].(string)
+	report.Currency, _ = agreement.State["currency"].(string)
+	prices, _ := agreement.State["price_list"].(map[string]interface{})
+	commitments, _ := agreement.State["commitments"].(map[string]interface{})
+
+	lines := map[string]*UtilizationLine{}
+	for product, v := range prices {
+		line := &UtilizationLine{Product: product}
+		line.ListPrice, _ = toFloat64(v)
+		line.Committed, _ = toFloat64(commitments[product])
+		lines[product] = line
+	}
+	for _, o := range FilterBlocks(orders, QueryParams{HeadsOnly: true, IncludeExpired: true}) {
+		if o.Refs["agreement"] != agreement.Hash {
+			continue
+		}
+		product, _ := o.Refs["product"].(string)
+		line, ok := lines[product]
+		if !ok {
+			line = &UtilizationLine{Product: product}
+			lines[product] = line
+		}
+		qty, _ := numericValue(o.State["quantity"])
+		spend, _ := blockAmount(o)
+		line.CalledOff += qty
+		line.Spend += spend
+		line.Orders++
+		report.Spend += spend
+		report.Orders++
+	}
+	for _, line := range lines {
+		if line.Committed > 0 {
+			line.Remaining = math.Max(0, line.Committed-line.CalledOff)
+			line.Utilization = line.CalledOff / line.Committed
+		}
+		line.Spend = roundCents(line.Spend)
+		report.Lines = append(report.Lines, *line)
+	}
+	sort.Slice(report.Lines, func(i, j int) bool { return report.Lines[i].Product < report.Lines[j].Product })
+	report.Spend = roundCents(report.Spend)
+	return report
+}
@@ -0,0 +1,76 @@
+package foodblock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAgreementCallOffs(t *testing.T) {
+	flour, oats := "product-flour", "product-oats"
+	agreement, err := CreateAgreement("bakery", "mill", Agreement{
+		Start:       time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		End:         time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC),
+		Currency:    "GBP",
+		Unit:        "kg",
+		Prices:      map[string]float64{flour: 1.20, oats: 0.90},
+		Commitments: map[string]float64{flour: 1000},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	march := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	first, err := CallOff(agreement, flour, 600, 1.20, march, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Refs["agreement"] != agreement.Hash || first.State["currency"] != "GBP" {
+		t.Errorf("call-off should reference the agreement and inherit its currency: %+v", first)
+	}
+	prior := []Block{first}
+
+	cases := []struct {
+		name    string
+		product string
+		qty     float64
+		price   float64
+		date    time.Time
+	}{
+		{"over list price", flour, 10, 1.50, march},
+		{"over commitment", flour, 500, 1.10, march},
+		{"unlisted product", "product-rye", 10, 1.00, march},
+		{"outside term", flour, 10, 1.00, time.Date(2027, 1, 5, 0, 0, 0, 0, time.UTC)},
+		{"negative quantity", flour, -500, 1.10, march},
+		{"zero price", flour, 10, 0, march},
+	}
+	for _, c := range cases {
+		if _, err := CallOff(agreement, c.product, c.qty, c.price, c.date, prior); err == nil {
+			t.Errorf("%s: expected an error", c.name)
+		}
+	}
+
+	// A new version of a call-off replaces it rather than adding to it.
+	confirmed := MergeUpdate(first, map[string]interface{}{"status": "confirmed"}, first.Refs)
+	prior = append(prior, confirmed)
+	second, err := CallOff(agreement, flour, 400, 1.10, march, prior)
+	if err != nil {
+		t.Fatal(err)
+	}
+	third, err := CallOff(agreement, oats, 5000, 0.85, march, prior)
+	if err != nil {
+		t.Fatalf("uncapped product should not be limited: %v", err)
+	}
+
+	other := Create("transfer.order", map[string]interface{}{"quantity": 50.0, "total": 60.0}, map[string]interface{}{"buyer": "bakery"})
+	report := AgreementUtilization(agreement, []Block{first, confirmed, second, third, other})
+	if report.Orders != 3 || len(report.Lines) != 2 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	f := report.Lines[0]
+	if f.Product != flour || f.CalledOff != 1000 || f.Remaining != 0 || f.Utilization != 1 || f.Spend != 1160 {
+		t.Errorf("unexpected flour line %+v", f)
+	}
+	if report.Spend != 1160+4250 {
+		t.Errorf("expected spend 5410, got %v", report.Spend)
+	}
+}
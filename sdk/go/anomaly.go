@@ -0,0 +1,230 @@
+package foodblock
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// AnomalyRule selects a time series from the graph and how to judge jumps in
+// it. Blocks of Types whose state matches Match contribute their Field value
+// (temperatures in celsius, quantities by value) at their reading time, one
+// series per first ref found among Series roles.
+type AnomalyRule struct {
+	Types     []string               `json:"types"` // exact types or "prefix.*" patterns
+	Field     string                 `json:"field"`
+	Match     map[string]interface{} `json:"match,omitempty"`
+	Series    []string               `json:"series,omitempty"` // ref roles identifying a series
+	Method    string                 `json:"method"`           // "zscore" or "ewma"
+	Window    int                    `json:"window,omitempty"` // zscore: previous points compared, default 10
+	Alpha     float64                `json:"alpha,omitempty"`  // ewma: smoothing factor, default 0.3
+	Threshold float64                `json:"threshold"`        // deviations from the baseline that count as a jump
+	MinPoints int                    `json:"min_points,omitempty"`
+	// MaxChange flags any change between consecutive points larger than this,
+	// whatever the statistics say (0 disables).
+	MaxChange float64 `json:"max_change,omitempty"`
+}
+
+// AnomalyRules are the built-in rules, keyed by name.
+var AnomalyRules = map[string]AnomalyRule{
+	"temperature": {Types: []string{"observe.reading"}, Field: "temperature", Series: []string{"subject"}, Method: "ewma", Threshold: 4, MinPoints: 5},
+	"price":       {Types: []string{"observe.price", "transfer.order"}, Field: "price", Series: []string{"product", "item", "subject"}, Method: "zscore", Window: 10, Threshold: 3, MinPoints: 4},
+	"stock":       {Types: []string{"observe.reading"}, Field: "quantity", Match: map[string]interface{}{"reading_type": "stock_level"}, Series: []string{"subject"}, Method: "zscore", Window: 10, Threshold: 3, MinPoints: 4},
+}
+
+// Anomaly is one point judged a jump.
+type Anomaly struct {
+	Rule     string    `json:"rule"`
+	Field    string    `json:"field"`
+	Series   string    `json:"series"` // the series subject; "" when the blocks have none
+	Block    string    `json:"block"`
+	At       time.Time `json:"at"`
+	Value    float64   `json:"value"`
+	Expected float64   `json:"expected"` // the baseline before the point
+	Score    float64   `json:"score"`    // deviations from the baseline
+	Method   string    `json:"method"`   // zscore, ewma or max_change
+}
+
+type anomalyPoint struct {
+	block string
+	at    time.Time
+	value float64
+}
+
+// DetectAnomalies applies rules to the blocks in a store and returns the
+// anomalies found, ordered by time. The baseline's deviation is floored at 1%
+// of its level, so a series that has never moved is not flagged for a
+// rounding-sized change.
+func DetectAnomalies(store BlockStore, rules map[string]AnomalyRule) []Anomaly {
+	names := make([]string, 0, len(rules))
+	for name := range rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	blocks := store.Blocks()
+	var out []Anomaly
+	for _, name := range names {
+		rule := rules[name]
+		for subject, points := range anomalySeries(blocks, rule) {
+			for _, a := range judgeSeries(points, rule) {
+				a.Rule, a.Field, a.Series = name, rule.Field, subject
+				out = append(out, a)
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if !out[i].At.Equal(out[j].At) {
+			return out[i].At.Before(out[j].At)
+		}
+		return out[i].Block < out[j].Block
+	})
+	return out
+}
+
+// anomalySeries groups the rule's points by series subject, oldest first.
+func anomalySeries(blocks []Block, rule AnomalyRule) map[string][]anomalyPoint {
+	series := map[string][]anomalyPoint{}
+	for _, b := range blocks {
+		if !anomalyMatches(b, rule) {
+			continue
+		}
+		var v float64
+		var ok bool
+		if rule.Field == "temperature" {
+			v, ok = readingTemperature(b)
+		} else {
+			v, ok = numericValue(b.State[rule.Field])
+		}
+		at, hasTime := readingTime(b)
+		if !ok || !hasTime {
+			continue
+		}
+		subject := ""
+		for _, role := range rule.Series {
+			if h := firstRef(b.Refs, role); h != "" {
+				subject = h
+				break
+			}
+		}
+		series[subject] = append(series[subject], anomalyPoint{b.Hash, at, v})
+	}
+	for _, points := range series {
+		sort.SliceStable(points, func(i, j int) bool { return points[i].at.Before(points[j].at) })
+	}
+	return series
+}
+
+func anomalyMatches(b Block, rule AnomalyRule) bool {
+	typed := false
+	for _, t := range rule.Types {
+		typed = typed || matchesType(b.Type, t)
+	}
+	if !typed {
+		return false
+	}
+	for k, want := range rule.Match {
+		if b.State[k] != want {
+			return false
+		}
+	}
+	return true
+}
+
+// judgeSeries scores each point against the baseline of the points before it.
+func judgeSeries(points []anomalyPoint, rule AnomalyRule) []Anomaly {
+	window := rule.Window
+	if window <= 0 {
+		window = 10
+	}
+	alpha := rule.Alpha
+	if alpha <= 0 || alpha > 1 {
+		alpha = 0.3
+	}
+	minPoints := rule.MinPoints
+	if minPoints < 2 {
+		minPoints = 2
+	}
+	var out []Anomaly
+	var mean, variance float64
+	for i, p := range points {
+		if rule.MaxChange > 0 && i > 0 {
+			if change := math.Abs(p.value - points[i-1].value); change > rule.MaxChange {
+				out = append(out, Anomaly{Block: p.block, At: p.at, Value: p.value, Expected: points[i-1].value,
+					Score: round3(change / rule.MaxChange), Method: "max_change"})
+				continue
+			}
+		}
+		var expected, deviation float64
+		switch rule.Method {
+		case "ewma":
+			if i == 0 {
+				mean = p.value
+				continue
+			}
+			expected, deviation = mean, math.Sqrt(variance)
+			diff := p.value - mean
+			mean += alpha * diff
+			variance = (1 - alpha) * (variance + alpha*diff*diff)
+		default:
+			start := i - window
+			if start < 0 {
+				start = 0
+			}
+			expected, deviation = meanStd(points[start:i])
+		}
+		if i < minPoints {
+			continue
+		}
+		deviation = math.Max(deviation, math.Abs(expected)*0.01)
+		if deviation == 0 {
+			continue
+		}
+		if score := math.Abs(p.value-expected) / deviation; score >= rule.Threshold {
+			method := rule.Method
+			if method != "ewma" {
+				method = "zscore"
+			}
+			out = append(out, Anomaly{Block: p.block, At: p.at, Value: p.value, Expected: round3(expected), Score: round3(score), Method: method})
+		}
+	}
+	return out
+}
+
+func meanStd(points []anomalyPoint) (float64, float64) {
+	if len(points) == 0 {
+		return 0, 0
+	}
+	var sum float64
+	for _, p := range points {
+		sum += p.value
+	}
+	mean := sum / float64(len(points))
+	var sq float64
+	for _, p := range points {
+		sq += (p.value - mean) * (p.value - mean)
+	}
+	return mean, math.Sqrt(sq / float64(len(points)))
+}
+
+// AnomalyBlock records an anomaly as an observe.anomaly block, which
+// RenderNotification turns into an alert. refs.evidence is the flagged block
+// and refs.subject the series subject.
+func AnomalyBlock(a Anomaly) Block {
+	refs := map[string]interface{}{"evidence": a.Block}
+	if a.Series != "" {
+		refs["subject"] = a.Series
+	}
+	return Create("observe.anomaly", map[string]interface{}{
+		"instance_id": "anomaly-" + a.Rule + "-" + a.Block,
+		"kind":        "anomaly",
+		"rule":        a.Rule,
+		"field":       a.Field,
+		"value":       a.Value,
+		"expected":    a.Expected,
+		"score":       a.Score,
+		"method":      a.Method,
+		"at":          a.At.UTC().Format(time.RFC3339),
+		"message":     fmt.Sprintf("%s jumped to %g (expected about %g)", a.Field, a.Value, a.Expected),
+	}, refs)
+}
@@ -0,0 +1,81 @@
+package foodblock
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDetectAnomalies(t *testing.T) {
+	store := NewMemoryStore()
+	start := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	fridge := Create("place.storage", map[string]interface{}{"name": "Walk-in"}, nil)
+	bread := Create("substance.product", map[string]interface{}{"name": "Bread"}, nil)
+	store.PutAll([]Block{fridge, bread})
+
+	temps := []float64{3.0, 3.2, 2.9, 3.1, 3.0, 3.1, 2.9, 9.5, 3.0}
+	var spike Block
+	for i, c := range temps {
+		r := Create("observe.reading", map[string]interface{}{
+			"instance_id": fmt.Sprintf("t%d", i),
+			"temperature": map[string]interface{}{"value": c, "unit": "celsius"},
+			"at":          start.Add(time.Duration(i) * time.Hour).Format(time.RFC3339),
+		}, map[string]interface{}{"subject": fridge.Hash})
+		store.Put(r)
+		if c == 9.5 {
+			spike = r
+		}
+	}
+	prices := []float64{4.0, 4.1, 3.9, 4.0, 4.05, 4.0, 8.0}
+	for i, p := range prices {
+		store.Put(Create("observe.price", map[string]interface{}{
+			"instance_id": fmt.Sprintf("p%d", i),
+			"price":       p,
+			"date":        start.AddDate(0, 0, i).Format("2006-01-02"),
+		}, map[string]interface{}{"product": bread.Hash}))
+	}
+
+	got := DetectAnomalies(store, AnomalyRules)
+	if len(got) != 2 {
+		t.Fatalf("expected a temperature spike and a price jump, got %+v", got)
+	}
+	temp, price := got[0], got[1]
+	if temp.Rule != "temperature" || temp.Block != spike.Hash || temp.Series != fridge.Hash || temp.Method != "ewma" || temp.Expected > 3.2 {
+		t.Errorf("unexpected temperature anomaly %+v", temp)
+	}
+	if price.Rule != "price" || price.Value != 8 || price.Series != bread.Hash || price.Method != "zscore" {
+		t.Errorf("unexpected price anomaly %+v", price)
+	}
+
+	alert := AnomalyBlock(temp)
+	if alert.Type != "observe.anomaly" || alert.Refs["evidence"] != spike.Hash || AnomalyBlock(temp).Hash != alert.Hash {
+		t.Errorf("expected a deterministic anomaly block, got %+v", alert)
+	}
+	n, err := RenderNotification(alert, "email", "en", "ops@example.com")
+	if err != nil || !strings.Contains(n.Subject, "temperature") || !strings.Contains(n.Body, "9.5") {
+		t.Errorf("unexpected notification %+v, %v", n, err)
+	}
+}
+
+func TestAnomalyMaxChange(t *testing.T) {
+	store := NewMemoryStore()
+	start := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	for i, q := range []float64{40, 38, 300, 36} {
+		store.Put(Create("observe.reading", map[string]interface{}{
+			"instance_id":  fmt.Sprintf("s%d", i),
+			"reading_type": "stock_level",
+			"quantity":     q,
+			"at":           start.Add(time.Duration(i) * time.Hour).Format(time.RFC3339),
+		}, nil))
+	}
+	rule := AnomalyRules["stock"]
+	rule.MaxChange = 100
+	got := DetectAnomalies(store, map[string]AnomalyRule{"stock": rule})
+	if len(got) != 2 || got[0].Value != 300 || got[0].Method != "max_change" || got[1].Value != 36 {
+		t.Errorf("expected the jump up and back down, got %+v", got)
+	}
+	if got := DetectAnomalies(store, AnomalyRules); len(got) != 0 {
+		t.Errorf("expected too few points for statistics, got %+v", got)
+	}
+}
@@ -0,0 +1,251 @@
+package foodblock
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// DefaultPIIFields are the state fields AnonymizeExport strips from every
+// block.
+var DefaultPIIFields = []string{
+	"email", "phone", "telephone", "mobile", "contact", "contact_name", "contact_email",
+	"first_name", "last_name", "full_name", "date_of_birth", "dob",
+	"payment_ref", "card", "ip", "owner", "signature",
+}
+
+// DefaultLocationFields are the state fields AnonymizeExport generalizes to a
+// region.
+var DefaultLocationFields = []string{
+	"location", "address", "street", "postcode", "postal_code", "zip",
+	"lat", "lng", "latitude", "longitude", "geo",
+}
+
+// AnonymizeOptions configures AnonymizeExport.
+type AnonymizeOptions struct {
+	// Key keys the pseudonyms. The same key maps a hash to the same pseudonym
+	// in every export; keep it secret, as it allows re-identification.
+	Key            string
+	PIIFields      []string // default DefaultPIIFields
+	LocationFields []string // default DefaultLocationFields
+	// EscrowKeys are X25519 public keys (hex) the pseudonym mapping is
+	// encrypted to; without any the mapping is discarded.
+	EscrowKeys []string
+	// Aggregates are published alongside the records with Privacy's
+	// differential privacy, for figures that must not be recomputed from
+	// the records themselves.
+	Aggregates []AggregateQuery
+	Privacy    PrivacyOptions
+}
+
+// AnonymizedRecord is one line of an anonymized dataset.
+type AnonymizedRecord struct {
+	ID    string                 `json:"id"`
+	Type  string                 `json:"type"`
+	State map[string]interface{} `json:"state"`
+	Refs  map[string]interface{} `json:"refs"`
+}
+
+// AnonymizedDataset describes an export.
+type AnonymizedDataset struct {
+	Records     int                      `json:"records"`
+	Types       map[string]int           `json:"types"`
+	Skipped     int                      `json:"skipped"` // stubs, whose content is gone
+	Stripped    []string                 `json:"stripped"`
+	Generalized []string                 `json:"generalized"`
+	Doc         string                   `json:"doc"`
+	Escrow      *EncryptionEnvelope      `json:"escrow,omitempty"` // pseudonym -> hash or instance_id
+	Aggregates  []PrivateAggregateResult `json:"aggregates,omitempty"`
+}
+
+// AnonymizeExport writes a store's blocks as an NDJSON research dataset, one
+// AnonymizedRecord per line in store order. Every hash, in ids, refs,
+// instance_id and _prov, is replaced by a keyed pseudonym, so the graph
+// keeps its shape while records cannot be matched to the original blocks.
+// PII fields are dropped, as are the names of actors and places, and
+// location fields are generalized to state.region: the block's own region,
+// else the outward part of its postcode, else its country. Stubs are left
+// out. The returned Doc documents the dataset, and the mapping from
+// pseudonyms back to the originals is returned encrypted to EscrowKeys.
+func AnonymizeExport(store BlockStore, w io.Writer, opts AnonymizeOptions) (AnonymizedDataset, error) {
+	if opts.Key == "" {
+		return AnonymizedDataset{}, errors.New("FoodBlock: anonymized export needs a pseudonym key")
+	}
+	if opts.PIIFields == nil {
+		opts.PIIFields = DefaultPIIFields
+	}
+	if opts.LocationFields == nil {
+		opts.LocationFields = DefaultLocationFields
+	}
+	ds := AnonymizedDataset{Types: map[string]int{}, Stripped: []string{}, Generalized: []string{}}
+	mapping := map[string]string{}
+	pseudonym := func(hash string) string {
+		mac := hmac.New(sha256.New, []byte(opts.Key))
+		mac.Write([]byte(hash))
+		p := "anon-" + hex.EncodeToString(mac.Sum(nil))[:20]
+		mapping[p] = hash
+		return p
+	}
+
+	out := bufio.NewWriter(w)
+	enc := json.NewEncoder(out)
+	for _, b := range store.Blocks() {
+		if IsStub(b) {
+			ds.Skipped++
+			continue
+		}
+		rec := AnonymizedRecord{ID: pseudonym(b.Hash), Type: b.Type, State: map[string]interface{}{}, Refs: map[string]interface{}{}}
+		identifying := strings.HasPrefix(b.Type, "actor.") || strings.HasPrefix(b.Type, "place.")
+		for k, v := range b.State {
+			switch {
+			case containsStr(opts.PIIFields, k) || (identifying && k == "name"):
+				ds.Stripped = appendUnique(ds.Stripped, k)
+			case containsStr(opts.LocationFields, k):
+				ds.Generalized = appendUnique(ds.Generalized, k)
+			case k == "instance_id":
+				rec.State[k] = pseudonym(fmt.Sprint(v))
+			case k == ProvenanceKey:
+				prov := map[string]interface{}{}
+				for field, h := range Provenance(b) {
+					prov[field] = pseudonym(h)
+				}
+				rec.State[k] = prov
+			default:
+				rec.State[k] = v
+			}
+		}
+		if region := blockRegion(b); region != "" {
+			rec.State["region"] = region
+		}
+		for role, v := range b.Refs {
+			rec.Refs[role] = mapRefs(v, pseudonym)
+		}
+		if err := enc.Encode(rec); err != nil {
+			return ds, err
+		}
+		ds.Records++
+		ds.Types[b.Type]++
+	}
+	if err := out.Flush(); err != nil {
+		return ds, err
+	}
+	sort.Strings(ds.Stripped)
+	sort.Strings(ds.Generalized)
+	for _, q := range opts.Aggregates {
+		agg, err := PrivateAggregate(store, q, opts.Privacy)
+		if err != nil {
+			return ds, err
+		}
+		ds.Aggregates = append(ds.Aggregates, agg)
+	}
+	ds.Doc = datasetDoc(ds)
+
+	if len(opts.EscrowKeys) > 0 {
+		env, err := Encrypt(mapping, opts.EscrowKeys)
+		if err != nil {
+			return ds, err
+		}
+		ds.Escrow = env
+	}
+	return ds, nil
+}
+
+// Reidentify decrypts an export's escrowed mapping from pseudonyms to block
+// hashes and instance_ids with one of the escrow keypairs.
+func Reidentify(escrow *EncryptionEnvelope, privateKeyHex, publicKeyHex string) (map[string]string, error) {
+	if escrow == nil {
+		return nil, errors.New("FoodBlock: export has no escrowed mapping")
+	}
+	v, err := Decrypt(escrow, privateKeyHex, publicKeyHex)
+	if err != nil {
+		return nil, err
+	}
+	raw, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("FoodBlock: escrowed mapping is malformed")
+	}
+	out := make(map[string]string, len(raw))
+	for p, h := range raw {
+		s, _ := h.(string)
+		out[p] = s
+	}
+	return out, nil
+}
+
+// mapRefs applies f to every hash in a ref value, keeping its shape.
+func mapRefs(v interface{}, f func(string) string) interface{} {
+	switch x := v.(type) {
+	case string:
+		return f(x)
+	case []interface{}:
+		out := make([]interface{}, len(x))
+		for i, e := range x {
+			out[i] = mapRefs(e, f)
+		}
+		return out
+	case []string:
+		out := make([]interface{}, len(x))
+		for i, e := range x {
+			out[i] = f(e)
+		}
+		return out
+	}
+	return v
+}
+
+// blockRegion generalizes a block's location: its region, the outward code
+// of its postcode or its country.
+func blockRegion(b Block) string {
+	if r := firstString(b.State, "region"); r != "" {
+		return r
+	}
+	if pc := firstString(b.State, "postcode", "postal_code", "zip"); pc != "" {
+		if fields := strings.Fields(pc); len(fields) > 1 {
+			return strings.ToUpper(fields[0])
+		}
+		if len(pc) > 3 {
+			return strings.ToUpper(pc[:3])
+		}
+		return strings.ToUpper(pc)
+	}
+	return firstString(b.State, "country")
+}
+
+// datasetDoc documents an anonymized dataset.
+func datasetDoc(ds AnonymizedDataset) string {
+	types := make([]string, 0, len(ds.Types))
+	for t := range ds.Types {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	var sb strings.Builder
+	sb.WriteString("# FoodBlock anonymized dataset\n\n")
+	sb.WriteString("One JSON record per line: id, type, state and refs.\n\n")
+	sb.WriteString("- id, refs, state.instance_id and state._prov hold keyed pseudonyms (anon-...).\n")
+	sb.WriteString("  The same block has the same pseudonym everywhere, so refs join records.\n")
+	sb.WriteString("- Actor and place names are removed.\n")
+	if len(ds.Stripped) > 0 {
+		fmt.Fprintf(&sb, "- Removed fields: %s.\n", strings.Join(ds.Stripped, ", "))
+	}
+	if len(ds.Generalized) > 0 {
+		fmt.Fprintf(&sb, "- Generalized to state.region: %s.\n", strings.Join(ds.Generalized, ", "))
+	}
+	if ds.Skipped > 0 {
+		fmt.Fprintf(&sb, "- %d erased or archived blocks are left out.\n", ds.Skipped)
+	}
+	if len(ds.Aggregates) > 0 {
+		fmt.Fprintf(&sb, "- %d aggregates carry Laplace noise (epsilon %g each); groups under the minimum size are withheld.\n", len(ds.Aggregates), ds.Aggregates[0].Epsilon)
+	}
+	fmt.Fprintf(&sb, "\n%d records:\n", ds.Records)
+	for _, t := range types {
+		fmt.Fprintf(&sb, "- %s: %d\n", t, ds.Types[t])
+	}
+	return sb.String()
+}
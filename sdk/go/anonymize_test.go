@@ -0,0 +1,86 @@
+package foodblock
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestAnonymizeExport(t *testing.T) {
+	store := NewMemoryStore()
+	farm := Create("actor.producer", map[string]interface{}{"name": "Green Acres", "email": "jo@example.com", "postcode": "SW1A 1AA", "crop": "wheat"}, nil)
+	venue := Create("actor.venue", map[string]interface{}{"name": "Bakery", "region": "Kent", "address": "1 High St"}, nil)
+	order := Create("transfer.order", map[string]interface{}{"instance_id": "TX-991", "quantity": 20, "payment_ref": "pi_123", "location": "Stall 4"},
+		map[string]interface{}{"buyer": venue.Hash, "seller": farm.Hash, "items": []interface{}{farm.Hash, venue.Hash}})
+	gone := Create("substance.product", map[string]interface{}{"name": "Old"}, nil)
+	store.PutAll([]Block{farm, venue, order, gone})
+	store.Stub(gone.Hash, map[string]interface{}{"tombstoned": true})
+
+	pub, priv, err := GenerateEncryptionKeypair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	ds, err := AnonymizeExport(store, &buf, AnonymizeOptions{Key: "secret", EscrowKeys: []string{pub}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds.Records != 3 || ds.Skipped != 1 || ds.Types["actor.producer"] != 1 {
+		t.Fatalf("dataset = %+v", ds)
+	}
+	out := buf.String()
+	for _, leak := range []string{"Green Acres", "jo@example.com", "SW1A 1AA", "1 High St", "pi_123", "TX-991", "Stall 4", farm.Hash, venue.Hash} {
+		if strings.Contains(out, leak) {
+			t.Errorf("export leaks %q", leak)
+		}
+	}
+
+	var recs []AnonymizedRecord
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var r AnonymizedRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatal(err)
+		}
+		recs = append(recs, r)
+	}
+	farmRec, venueRec, orderRec := recs[0], recs[1], recs[2]
+	if farmRec.State["region"] != "SW1A" || farmRec.State["crop"] != "wheat" || venueRec.State["region"] != "Kent" {
+		t.Errorf("generalized states = %v, %v", farmRec.State, venueRec.State)
+	}
+	// Graph structure survives: refs point at the other records' ids.
+	if orderRec.Refs["seller"] != farmRec.ID || orderRec.Refs["buyer"] != venueRec.ID {
+		t.Errorf("order refs = %v", orderRec.Refs)
+	}
+	if items, _ := orderRec.Refs["items"].([]interface{}); len(items) != 2 || items[0] != farmRec.ID {
+		t.Errorf("items = %v", orderRec.Refs["items"])
+	}
+	if !strings.Contains(ds.Doc, "payment_ref") || !strings.Contains(ds.Doc, "transfer.order: 1") {
+		t.Errorf("doc = %s", ds.Doc)
+	}
+
+	// Pseudonyms are stable for a key and differ between keys.
+	var again bytes.Buffer
+	AnonymizeExport(store, &again, AnonymizeOptions{Key: "secret"})
+	if !strings.Contains(again.String(), farmRec.ID) {
+		t.Error("pseudonyms changed between exports with the same key")
+	}
+	var other bytes.Buffer
+	AnonymizeExport(store, &other, AnonymizeOptions{Key: "other"})
+	if strings.Contains(other.String(), farmRec.ID) {
+		t.Error("pseudonyms do not depend on the key")
+	}
+
+	mapping, err := Reidentify(ds.Escrow, priv, pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mapping[farmRec.ID] != farm.Hash || mapping[orderRec.State["instance_id"].(string)] != "TX-991" {
+		t.Errorf("mapping = %v", mapping)
+	}
+	if _, err := AnonymizeExport(store, &bytes.Buffer{}, AnonymizeOptions{}); err == nil {
+		t.Error("expected an error without a key")
+	}
+}
@@ -0,0 +1,288 @@
+package foodblock
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HoneyDensity converts bottled volumes to mass, in kg per litre.
+const HoneyDensity = 1.42
+
+// HoneyMoistureLimits is the maximum moisture content, in percent, for honey
+// by floral source (Codex Alimentarius). "" is the general limit.
+var HoneyMoistureLimits = map[string]float64{
+	"":        20,
+	"heather": 23,
+	"clover":  23,
+}
+
+// HoneyMoistureLimit returns the moisture limit for a floral source.
+func HoneyMoistureLimit(floralSource string) float64 {
+	if limit, ok := HoneyMoistureLimits[strings.ToLower(floralSource)]; ok {
+		return limit
+	}
+	return HoneyMoistureLimits[""]
+}
+
+// Harvest describes honey extracted from one hive.
+type Harvest struct {
+	Apiary       string  // actor.apiary hash
+	Hive         string  // place.hive hash, optional
+	HiveID       string  // beekeeper's hive label, optional
+	FloralSource string  // optional
+	Region       string  // optional
+	Quantity     float64 // kg
+	Moisture     float64 // percent, optional
+	Date         time.Time
+}
+
+// HarvestHoney creates the substance.honey block for a harvest.
+func HarvestHoney(h Harvest) (Block, error) {
+	if h.Apiary == "" {
+		return Block{}, errors.New("FoodBlock: harvest needs an apiary")
+	}
+	if h.Quantity <= 0 {
+		return Block{}, errors.New("FoodBlock: harvest quantity must be positive")
+	}
+	if h.Date.IsZero() {
+		h.Date = time.Now()
+	}
+	state := map[string]interface{}{
+		"name":         "Harvested honey",
+		"quantity":     h.Quantity,
+		"unit":         "kg",
+		"harvest_date": h.Date.Format("2006-01-02"),
+	}
+	for field, v := range map[string]string{"hive_id": h.HiveID, "floral_source": h.FloralSource, "region": h.Region} {
+		if v != "" {
+			state[field] = v
+		}
+	}
+	if h.Moisture > 0 {
+		state["moisture_content"] = h.Moisture
+	}
+	refs := map[string]interface{}{"apiary": h.Apiary}
+	if h.Hive != "" {
+		refs["hive"] = h.Hive
+	}
+	return Create("substance.honey", state, refs), nil
+}
+
+// Bottling describes a run of jars filled from one or more harvests.
+type Bottling struct {
+	Harvests []string // substance.honey harvest hashes, drawn down in order
+	Packer   string
+	Jars     int
+	JarSize  float64
+	JarUnit  string // g, kg, ml or l
+	Date     time.Time
+}
+
+// BottleHoney records a bottling run as a transform.process (process_type
+// bottling) drawing from its harvests, and the substance.honey jar lot it
+// produced. Each harvest is drawn down in order up to what earlier runs have
+// left of it; the draw per harvest is stored on the process as state.drawn.
+// The run is rejected when it needs more honey than its harvests have left, or
+// a harvest is over its moisture limit.
+func BottleHoney(b Bottling, store BlockStore) (Block, Block, error) {
+	if len(b.Harvests) == 0 || b.Packer == "" {
+		return Block{}, Block{}, errors.New("FoodBlock: bottling needs harvests and a packer")
+	}
+	if b.Jars <= 0 || b.JarSize <= 0 {
+		return Block{}, Block{}, errors.New("FoodBlock: bottling needs a positive jar count and size")
+	}
+	jarKg, ok := honeyKilograms(b.JarSize, b.JarUnit)
+	if !ok {
+		return Block{}, Block{}, fmt.Errorf("FoodBlock: unsupported jar unit %q", b.JarUnit)
+	}
+	if b.Date.IsZero() {
+		b.Date = time.Now()
+	}
+	need := round3(jarKg * float64(b.Jars))
+
+	drawn := honeyDrawn(store)
+	draw := map[string]interface{}{}
+	var sources, regions, hives []string
+	left := need
+	for _, h := range b.Harvests {
+		harvest := store.Resolve(h)
+		if harvest == nil || harvest.Type != "substance.honey" {
+			return Block{}, Block{}, fmt.Errorf("FoodBlock: harvest not found: %s", h)
+		}
+		floral, _ := harvest.State["floral_source"].(string)
+		if m, ok := toFloat64(harvest.State["moisture_content"]); ok && m > HoneyMoistureLimit(floral) {
+			return Block{}, Block{}, fmt.Errorf("FoodBlock: harvest %s moisture %.1f%% exceeds the %.0f%% limit", h, m, HoneyMoistureLimit(floral))
+		}
+		qty, _ := toFloat64(harvest.State["quantity"])
+		available := qty
+		for _, v := range Chain(h, store.Resolve, 0) {
+			available -= drawn[v.Hash]
+		}
+		available = round3(available)
+		if available <= 0 || left <= 0 {
+			continue
+		}
+		take := available
+		if left < take {
+			take = left
+		}
+		draw[h] = round3(take)
+		left = round3(left - take)
+		sources = appendUnique(sources, floral)
+		regions = appendUnique(regions, firstString(harvest.State, "region"))
+		hives = appendUnique(hives, firstString(harvest.State, "hive_id"))
+	}
+	if left > 0 {
+		return Block{}, Block{}, fmt.Errorf("FoodBlock: bottling needs %.3f kg but its harvests have %.3f kg left", need, round3(need-left))
+	}
+
+	process := Create("transform.process", map[string]interface{}{
+		"name":         "Honey bottling",
+		"process_type": "bottling",
+		"drawn":        draw,
+		"quantity":     need,
+		"unit":         "kg",
+		"date":         b.Date.Format("2006-01-02"),
+	}, map[string]interface{}{"inputs": refList(b.Harvests), "operator": b.Packer})
+
+	state := map[string]interface{}{
+		"name":     "Honey",
+		"quantity": b.Jars,
+		"jar_size": map[string]interface{}{"value": b.JarSize, "unit": b.JarUnit},
+		"net_kg":   need,
+		"date":     b.Date.Format("2006-01-02"),
+	}
+	if len(sources) == 1 {
+		state["floral_source"] = sources[0]
+	}
+	if len(regions) == 1 {
+		state["region"] = regions[0]
+	}
+	if len(hives) > 0 {
+		sort.Strings(hives)
+		state["hive_ids"] = refList(hives)
+	}
+	jars := Create("substance.honey", state, map[string]interface{}{"origin": process.Hash, "producer": b.Packer})
+	return process, jars, nil
+}
+
+// appendUnique appends v to list unless it is empty or already present.
+func appendUnique(list []string, v string) []string {
+	if v == "" || containsStr(list, v) {
+		return list
+	}
+	return append(list, v)
+}
+
+// honeyKilograms converts a jar size to kilograms, using HoneyDensity for
+// volumes.
+func honeyKilograms(v float64, unit string) (float64, bool) {
+	switch strings.ToLower(unit) {
+	case "ml":
+		return v / 1000 * HoneyDensity, true
+	case "l":
+		return v * HoneyDensity, true
+	}
+	return toKilograms(v, unit)
+}
+
+// honeyDrawn totals the kilograms bottling runs have drawn from each harvest.
+func honeyDrawn(store BlockStore) map[string]float64 {
+	drawn := map[string]float64{}
+	for _, b := range store.Blocks() {
+		if b.Type != "transform.process" || b.State["process_type"] != "bottling" {
+			continue
+		}
+		m, _ := b.State["drawn"].(map[string]interface{})
+		for h, v := range m {
+			kg, _ := toFloat64(v)
+			drawn[h] += kg
+		}
+	}
+	return drawn
+}
+
+// ApiaryBalance compares honey harvested at an apiary with what has been
+// bottled from it.
+type ApiaryBalance struct {
+	Apiary      string  `json:"apiary"`
+	Harvests    int     `json:"harvests"`
+	HarvestedKg float64 `json:"harvested_kg"`
+	BottledKg   float64 `json:"bottled_kg"`
+	RemainingKg float64 `json:"remaining_kg"`
+	Balanced    bool    `json:"balanced"` // bottled does not exceed harvested
+}
+
+// ApiaryMassBalances balances every apiary's harvests against the bottling
+// runs drawing on them, in apiary order. An unbalanced apiary has more honey
+// in jars than its hives produced.
+func ApiaryMassBalances(store BlockStore) []ApiaryBalance {
+	drawn := honeyDrawn(store)
+	byApiary := map[string]*ApiaryBalance{}
+	for _, b := range FilterBlocks(store.Blocks(), QueryParams{Type: "substance.honey", HeadsOnly: true}) {
+		apiary, _ := b.Refs["apiary"].(string)
+		if apiary == "" {
+			continue
+		}
+		bal := byApiary[apiary]
+		if bal == nil {
+			bal = &ApiaryBalance{Apiary: apiary}
+			byApiary[apiary] = bal
+		}
+		qty, _ := toFloat64(b.State["quantity"])
+		bal.Harvests++
+		bal.HarvestedKg += qty
+		for _, v := range Chain(b.Hash, store.Resolve, 0) {
+			bal.BottledKg += drawn[v.Hash]
+		}
+	}
+	out := make([]ApiaryBalance, 0, len(byApiary))
+	for _, bal := range byApiary {
+		bal.HarvestedKg = round3(bal.HarvestedKg)
+		bal.BottledKg = round3(bal.BottledKg)
+		bal.RemainingKg = round3(bal.HarvestedKg - bal.BottledKg)
+		bal.Balanced = bal.RemainingKg >= 0
+		out = append(out, *bal)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Apiary < out[j].Apiary })
+	return out
+}
+
+// JarTrace is where a jar lot's honey came from.
+type JarTrace struct {
+	Jar           string   `json:"jar"`
+	Harvests      []string `json:"harvests"`
+	Apiaries      []string `json:"apiaries"`
+	Hives         []string `json:"hives"` // place.hive hashes, or hive_id labels when no hive block is linked
+	FloralSources []string `json:"floral_sources"`
+	Regions       []string `json:"regions"`
+}
+
+// TraceJar follows a jar lot's refs.origin to its bottling run and on to the
+// harvests, hives and apiaries it drew from.
+func TraceJar(jar Block, store BlockStore) (JarTrace, error) {
+	t := JarTrace{Jar: jar.Hash, Harvests: []string{}, Apiaries: []string{}, Hives: []string{}, FloralSources: []string{}, Regions: []string{}}
+	process := store.Resolve(firstRef(jar.Refs, "origin"))
+	if process == nil {
+		return t, fmt.Errorf("FoodBlock: %s has no bottling run", jar.Hash)
+	}
+	drawn, _ := process.State["drawn"].(map[string]interface{})
+	for _, h := range flattenRefValues(map[string]interface{}{"inputs": process.Refs["inputs"]}) {
+		if _, used := drawn[h]; drawn != nil && !used {
+			continue
+		}
+		harvest := store.Resolve(h)
+		if harvest == nil {
+			continue
+		}
+		t.Harvests = appendUnique(t.Harvests, h)
+		t.Apiaries = appendUnique(t.Apiaries, firstRef(harvest.Refs, "apiary"))
+		t.Hives = appendUnique(t.Hives, firstNonEmpty(firstRef(harvest.Refs, "hive"), firstString(harvest.State, "hive_id")))
+		t.FloralSources = appendUnique(t.FloralSources, firstString(harvest.State, "floral_source"))
+		t.Regions = appendUnique(t.Regions, firstString(harvest.State, "region"))
+	}
+	return t, nil
+}
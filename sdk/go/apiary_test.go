@@ -0,0 +1,57 @@
+package foodblock
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHoneyBottlingMassBalance(t *testing.T) {
+	day := time.Date(2026, 8, 20, 0, 0, 0, 0, time.UTC)
+	apiary := Create("actor.apiary", map[string]interface{}{"name": "Moorside Apiary"}, nil)
+	hive := Create("place.hive", map[string]interface{}{"name": "Hive 7", "hive_id": "H7"}, map[string]interface{}{"apiary": apiary.Hash})
+	packer := Create("actor.producer", map[string]interface{}{"name": "Moorside Honey"}, nil)
+	heather, _ := HarvestHoney(Harvest{Apiary: apiary.Hash, Hive: hive.Hash, HiveID: "H7", FloralSource: "heather", Region: "North York Moors", Quantity: 12, Moisture: 21.5, Date: day})
+	summer, _ := HarvestHoney(Harvest{Apiary: apiary.Hash, HiveID: "H3", FloralSource: "wildflower", Region: "North York Moors", Quantity: 5, Moisture: 18, Date: day})
+	wet, _ := HarvestHoney(Harvest{Apiary: apiary.Hash, HiveID: "H9", FloralSource: "wildflower", Quantity: 4, Moisture: 21, Date: day})
+
+	store := NewMemoryStore()
+	store.PutAll([]Block{apiary, hive, packer, heather, summer, wet})
+
+	run, jars, err := BottleHoney(Bottling{Harvests: []string{heather.Hash, summer.Hash}, Packer: packer.Hash, Jars: 30, JarSize: 454, JarUnit: "g", Date: day}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drawn := run.State["drawn"].(map[string]interface{})
+	if drawn[heather.Hash] != 12.0 || drawn[summer.Hash] != 1.62 || jars.State["region"] != "North York Moors" || jars.State["floral_source"] != nil {
+		t.Errorf("unexpected run %v / jars %v", run.State, jars.State)
+	}
+	store.PutAll([]Block{run, jars})
+
+	if _, _, err := BottleHoney(Bottling{Harvests: []string{heather.Hash, summer.Hash}, Packer: packer.Hash, Jars: 10, JarSize: 340, JarUnit: "g"}, store); err == nil || !strings.Contains(err.Error(), "3.380 kg left") {
+		t.Errorf("expected the second run to exceed what is left, got %v", err)
+	}
+	if _, _, err := BottleHoney(Bottling{Harvests: []string{wet.Hash}, Packer: packer.Hash, Jars: 1, JarSize: 1, JarUnit: "l"}, store); err == nil || !strings.Contains(err.Error(), "moisture") {
+		t.Errorf("expected wet honey to be rejected, got %v", err)
+	}
+
+	trace, err := TraceJar(jars, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trace.Harvests) != 2 || trace.Hives[0] != hive.Hash || trace.Hives[1] != "H3" || len(trace.Apiaries) != 1 {
+		t.Errorf("unexpected trace %+v", trace)
+	}
+	if recall := Recall(hive.Hash, store.ResolveForward, 0, []string{"substance.honey", "transform.process"}, nil); len(recall.Affected) != 3 {
+		t.Errorf("expected a hive recall to reach the harvest, run and jars, got %d", len(recall.Affected))
+	}
+
+	// A run recorded outside BottleHoney that overdraws the apiary.
+	forged := Create("transform.process", map[string]interface{}{"process_type": "bottling", "drawn": map[string]interface{}{summer.Hash: 10.0}},
+		map[string]interface{}{"inputs": []interface{}{summer.Hash}})
+	store.Put(forged)
+	balances := ApiaryMassBalances(store)
+	if len(balances) != 1 || balances[0].HarvestedKg != 21 || balances[0].BottledKg != 23.62 || balances[0].Balanced {
+		t.Errorf("expected the apiary to be over-bottled, got %+v", balances)
+	}
+}
@@ -0,0 +1,192 @@
+package foodblock
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ColdBackend stores archived blocks outside the hot store (filesystem, S3, ...).
+type ColdBackend interface {
+	PutCold(block Block) error
+	GetCold(hash string) (*Block, error)
+	DeleteCold(hash string) error // removing a missing block is not an error
+}
+
+// ArchivePolicy selects which blocks move to cold storage.
+type ArchivePolicy struct {
+	MinAge     time.Duration // only blocks stored longer than this are archived
+	RecentRefs time.Duration // blocks referenced by anything stored within this window stay hot
+	Now        func() time.Time
+}
+
+// FileColdStore is a ColdBackend that keeps one JSON file per block in a directory.
+type FileColdStore struct {
+	Dir string
+}
+
+// NewFileColdStore creates a file-backed cold store, creating dir if needed.
+func NewFileColdStore(dir string) (*FileColdStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileColdStore{Dir: dir}, nil
+}
+
+// PutCold writes a block to <dir>/<hash>.json.
+func (f *FileColdStore) PutCold(block Block) error {
+	data, err := json.Marshal(block)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(f.path(block.Hash), data, 0o644)
+}
+
+// GetCold reads an archived block.
+func (f *FileColdStore) GetCold(hash string) (*Block, error) {
+	data, err := os.ReadFile(f.path(hash))
+	if err != nil {
+		return nil, err
+	}
+	var b Block
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// DeleteCold removes an archived block.
+func (f *FileColdStore) DeleteCold(hash string) error {
+	if err := os.Remove(f.path(hash)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (f *FileColdStore) path(hash string) string {
+	return filepath.Join(f.Dir, filepath.Base(hash)+".json")
+}
+
+// ArchiveCold moves cold blocks out of the hot store: blocks older than MinAge
+// whose forward references are all older than RecentRefs are written to the cold
+// backend and replaced by {"archived": true} stubs. Pinned blocks stay hot.
+// Returns the archived hashes.
+func ArchiveCold(store StubStore, cold ColdBackend, policy ArchivePolicy) ([]string, error) {
+	if policy.Now == nil {
+		policy.Now = time.Now
+	}
+	now := policy.Now()
+
+	var archived []string
+	for _, b := range store.Blocks() {
+		if IsStub(b) {
+			continue
+		}
+		stored, ok := store.StoredAt(b.Hash)
+		if !ok || now.Sub(stored) < policy.MinAge {
+			continue
+		}
+		if hasRecentForwardRef(store, b.Hash, now, policy.RecentRefs) || IsPinned(b.Hash, store) {
+			continue
+		}
+		if err := cold.PutCold(b); err != nil {
+			return archived, err
+		}
+		store.Stub(b.Hash, map[string]interface{}{"archived": true})
+		archived = append(archived, b.Hash)
+	}
+	return archived, nil
+}
+
+func hasRecentForwardRef(store StubStore, hash string, now time.Time, window time.Duration) bool {
+	for _, ref := range store.ResolveForward(hash) {
+		if t, ok := store.StoredAt(ref.Hash); ok && now.Sub(t) < window {
+			return true
+		}
+	}
+	return false
+}
+
+// TieredStore reads through a hot store to a cold backend. Archived stubs are
+// transparently replaced by their cold copies, verified against their hash,
+// unless a tombstone with erasure authority targets them.
+type TieredStore struct {
+	Hot  StubStore
+	Cold ColdBackend
+}
+
+// Put stores a block in the hot tier.
+func (t *TieredStore) Put(block Block) error {
+	return t.Hot.Put(block)
+}
+
+// Resolve returns a block from the hot tier, fetching archived content from cold storage.
+func (t *TieredStore) Resolve(hash string) *Block {
+	b := t.Hot.Resolve(hash)
+	if b == nil {
+		return nil
+	}
+	full, err := t.rehydrate(*b)
+	if err != nil {
+		return b
+	}
+	return &full
+}
+
+// ResolveForward returns referencing blocks, rehydrating archived ones.
+func (t *TieredStore) ResolveForward(hash string) []Block {
+	blocks := t.Hot.ResolveForward(hash)
+	for i, b := range blocks {
+		if full, err := t.rehydrate(b); err == nil {
+			blocks[i] = full
+		}
+	}
+	return blocks
+}
+
+// Blocks returns all blocks across both tiers in hot-store order.
+func (t *TieredStore) Blocks() []Block {
+	blocks := t.Hot.Blocks()
+	for i, b := range blocks {
+		if full, err := t.rehydrate(b); err == nil {
+			blocks[i] = full
+		}
+	}
+	return blocks
+}
+
+// Delete removes a block's hot entry.
+func (t *TieredStore) Delete(hash string) bool {
+	return t.Hot.Delete(hash)
+}
+
+// Compact runs Compact on the hot tier, deleting the cold copies of
+// tombstoned archived blocks.
+func (t *TieredStore) Compact(policy CompactionPolicy) (CompactionReport, error) {
+	policy.Cold = t.Cold
+	return Compact(t.Hot, policy)
+}
+
+// Len returns the number of blocks, archived ones included.
+func (t *TieredStore) Len() int {
+	return t.Hot.Len()
+}
+
+func (t *TieredStore) rehydrate(b Block) (Block, error) {
+	if archived, _ := b.State["archived"].(bool); !archived || !IsStub(b) {
+		return b, nil
+	}
+	if isTombstoned(b.Hash, t.Hot) {
+		return b, errors.New("FoodBlock: archived block has been erased")
+	}
+	full, err := t.Cold.GetCold(b.Hash)
+	if err != nil {
+		return b, err
+	}
+	if Hash(full.Type, full.State, full.Refs) != b.Hash {
+		return b, errors.New("FoodBlock: archived block failed hash verification")
+	}
+	return *full, nil
+}
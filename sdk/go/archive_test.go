@@ -0,0 +1,93 @@
+package foodblock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestArchiveColdRoundTrip(t *testing.T) {
+	cold, err := NewFileColdStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewMemoryStore()
+	farm := Create("actor.producer", map[string]interface{}{"name": "Old Farm"}, nil)
+	harvest := Create("substance.ingredient", map[string]interface{}{"name": "2019 Wheat"}, map[string]interface{}{"source": farm.Hash})
+	store.PutAll([]Block{farm, harvest})
+	snapshot := CreateSnapshot(store.Blocks(), "", nil)
+
+	later := func() time.Time { return time.Now().Add(400 * 24 * time.Hour) }
+	archived, err := ArchiveCold(store, cold, ArchivePolicy{MinAge: 365 * 24 * time.Hour, RecentRefs: 30 * 24 * time.Hour, Now: later})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archived) != 2 {
+		t.Fatalf("expected both blocks archived, got %v", archived)
+	}
+	if hot := store.Resolve(harvest.Hash); hot == nil || !IsStub(*hot) {
+		t.Fatal("expected hot store to keep a stub")
+	}
+
+	tiered := &TieredStore{Hot: store, Cold: cold}
+	got := tiered.Resolve(harvest.Hash)
+	if got == nil || got.State["name"] != "2019 Wheat" {
+		t.Errorf("expected transparent cold fetch, got %v", got)
+	}
+	if explained := Explain(harvest.Hash, tiered.Resolve, 0); explained == "" {
+		t.Error("expected explain to work across tiers")
+	}
+
+	// Snapshot roots only need hashes, which stubs keep.
+	if ok, _ := VerifySnapshot(snapshot, store.Blocks()); !ok {
+		t.Error("expected snapshot to verify against hot stubs")
+	}
+	if ok, _ := VerifySnapshot(snapshot, tiered.Blocks()); !ok {
+		t.Error("expected snapshot to verify across tiers")
+	}
+}
+
+func TestArchiveColdKeepsRecentlyReferenced(t *testing.T) {
+	cold, _ := NewFileColdStore(t.TempDir())
+	store := NewMemoryStore()
+	farm := Create("actor.producer", map[string]interface{}{"name": "Busy Farm"}, nil)
+	store.Put(farm)
+	order := Create("transfer.order", map[string]interface{}{"quantity": 5}, map[string]interface{}{"seller": farm.Hash})
+	store.Put(order)
+
+	archived, _ := ArchiveCold(store, cold, ArchivePolicy{MinAge: 0, RecentRefs: time.Hour})
+	for _, h := range archived {
+		if h == farm.Hash {
+			t.Error("farm has a recent forward ref and must stay hot")
+		}
+	}
+}
+
+func TestErasureReachesColdStorage(t *testing.T) {
+	cold, _ := NewFileColdStore(t.TempDir())
+	hot := NewMemoryStore()
+	person := Create("actor.person", map[string]interface{}{"name": "Alice", "email": "a@x"}, nil)
+	hot.PutSigned(SignedBlock{FoodBlock: person, AuthorHash: person.Hash})
+	if archived, _ := ArchiveCold(hot, cold, ArchivePolicy{}); len(archived) != 1 {
+		t.Fatalf("archived = %v", archived)
+	}
+	hot.PutSigned(SignedBlock{FoodBlock: Tombstone(person.Hash, person.Hash), AuthorHash: person.Hash})
+	tiered := &TieredStore{Hot: hot, Cold: cold}
+
+	// Reads refuse a tombstoned hash even before compaction.
+	if b := tiered.Resolve(person.Hash); b == nil || b.State["name"] != nil {
+		t.Fatalf("erased block readable before compaction: %v", b)
+	}
+	report, err := tiered.Compact(CompactionPolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Erased) != 1 || report.Erased[0] != person.Hash {
+		t.Errorf("erased = %v", report.Erased)
+	}
+	if b := tiered.Resolve(person.Hash); b == nil || b.State["tombstoned"] != true {
+		t.Errorf("resolve after compaction = %v", b)
+	}
+	if _, err := cold.GetCold(person.Hash); err == nil {
+		t.Error("cold copy survived erasure")
+	}
+}
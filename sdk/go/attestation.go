@@ -2,10 +2,17 @@ package foodblock
 
 import "errors"
 
-// AttestationTrace holds attestations and disputes for a block.
+// RevocationType withdraws an attestation or certification. refs.revokes
+// names the withdrawn block and refs.revoker who withdrew it.
+const RevocationType = "observe.revocation"
+
+// AttestationTrace holds attestations and disputes for a block. Revoked
+// attestations are listed apart and do not count towards Score.
 type AttestationTrace struct {
 	Attestations []Block
 	Disputes     []Block
+	Revoked      []Block
+	Revocations  []Block
 	Score        int
 }
 
@@ -52,15 +59,114 @@ func Dispute(targetHash, disputerHash, reason string) (Block, error) {
 	}), nil
 }
 
+// Revoke creates a revocation withdrawing an attestation or certification.
+// Only the attestor or authority of the target may revoke it, and the
+// revocation must be signed by them; revocations by anyone else, or unsigned,
+// are ignored.
+func Revoke(targetHash, revokerHash, reason string) (Block, error) {
+	if targetHash == "" {
+		return Block{}, errors.New("FoodBlock: targetHash is required")
+	}
+	if revokerHash == "" {
+		return Block{}, errors.New("FoodBlock: revokerHash is required")
+	}
+	if reason == "" {
+		return Block{}, errors.New("FoodBlock: reason is required")
+	}
+
+	return Create(RevocationType, map[string]interface{}{
+		"reason": reason,
+	}, map[string]interface{}{
+		"revokes": targetHash,
+		"revoker": revokerHash,
+	}), nil
+}
+
+// revokes reports whether rev validly withdraws target. The revocation's
+// signer must be known and be both its refs.revoker and the target's
+// issuer: its attestor, authority or author, or the target's own signer.
+// An unsigned refs.revoker claim is never trusted.
+func revokes(rev TrustBlock, target TrustBlock) bool {
+	if rev.Type != RevocationType || rev.Refs["revokes"] != target.Hash || rev.AuthorHash == "" {
+		return false
+	}
+	if revoker, _ := rev.Refs["revoker"].(string); revoker != rev.AuthorHash {
+		return false
+	}
+	return rev.AuthorHash == target.AuthorHash || rev.AuthorHash == firstRef(target.Refs, "attestor", "authority", "author")
+}
+
+// revokedHashes maps each validly revoked block to its revocation.
+func revokedHashes(blocks []TrustBlock) map[string]TrustBlock {
+	out := map[string]TrustBlock{}
+	byHash := map[string]TrustBlock{}
+	for _, b := range blocks {
+		byHash[b.Hash] = b
+	}
+	for _, b := range blocks {
+		if b.Type != RevocationType {
+			continue
+		}
+		target, _ := b.Refs["revokes"].(string)
+		if t, ok := byHash[target]; ok && revokes(b, t) {
+			if _, seen := out[target]; !seen {
+				out[target] = b
+			}
+		}
+	}
+	return out
+}
+
+// IsRevoked reports whether a block has a valid revocation in the store.
+func IsRevoked(hash string, store BlockStore) bool {
+	target := store.Resolve(hash)
+	if target == nil {
+		return false
+	}
+	signers, _ := store.(interface{ SignerOf(string) string })
+	author := func(h string) string {
+		if signers == nil {
+			return ""
+		}
+		return signers.SignerOf(h)
+	}
+	t := TrustBlock{Block: *target, AuthorHash: author(hash)}
+	for _, b := range store.ResolveForward(hash) {
+		if revokes(TrustBlock{Block: b, AuthorHash: author(b.Hash)}, t) {
+			return true
+		}
+	}
+	return false
+}
+
 // TraceAttestations finds all attestation and dispute blocks referencing a given hash.
+// Without signers it cannot tell who issued a revocation, so revocations are
+// ignored; use TraceAttestationsWith to honour them.
 func TraceAttestations(hash string, allBlocks []Block) AttestationTrace {
-	var attestations, disputes []Block
+	tb := make([]TrustBlock, len(allBlocks))
+	for i, b := range allBlocks {
+		tb[i] = TrustBlock{Block: b}
+	}
+	return TraceAttestationsWith(hash, tb)
+}
+
+// TraceAttestationsWith is TraceAttestations over blocks with their signers,
+// leaving out attestations withdrawn by a valid revocation.
+func TraceAttestationsWith(hash string, blocks []TrustBlock) AttestationTrace {
+	var attestations, disputes, revoked, revocations []Block
+	withdrawn := revokedHashes(blocks)
 
-	for _, block := range allBlocks {
+	for _, tb := range blocks {
+		block := tb.Block
 		if block.Refs == nil {
 			continue
 		}
 		if confirms, ok := block.Refs["confirms"].(string); ok && confirms == hash {
+			if rev, ok := withdrawn[block.Hash]; ok {
+				revoked = append(revoked, block)
+				revocations = append(revocations, rev.Block)
+				continue
+			}
 			attestations = append(attestations, block)
 		}
 		if challenges, ok := block.Refs["challenges"].(string); ok && challenges == hash {
@@ -71,6 +177,8 @@ func TraceAttestations(hash string, allBlocks []Block) AttestationTrace {
 	return AttestationTrace{
 		Attestations: attestations,
 		Disputes:     disputes,
+		Revoked:      revoked,
+		Revocations:  revocations,
 		Score:        len(attestations) - len(disputes),
 	}
 }
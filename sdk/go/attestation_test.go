@@ -1,6 +1,9 @@
 package foodblock
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestAttest(t *testing.T) {
 	target := Create("substance.product", map[string]interface{}{"name": "Organic Bread", "organic": true}, nil)
@@ -158,3 +161,79 @@ func TestTrustScore(t *testing.T) {
 		t.Errorf("expected trust score 0, got %d", score3)
 	}
 }
+
+func TestRevoke(t *testing.T) {
+	farm := Create("actor.producer", map[string]interface{}{"name": "Green Acres"}, nil)
+	certifier := Create("actor.certifier", map[string]interface{}{"name": "USDA Organic"}, nil)
+	stranger := Create("actor.producer", map[string]interface{}{"name": "Rival"}, nil)
+	attestation, _ := Attest(farm.Hash, certifier.Hash, "verified", "")
+	cert := Create("observe.certification", map[string]interface{}{"name": "Organic"}, map[string]interface{}{"subject": farm.Hash, "authority": certifier.Hash})
+	forged, _ := Revoke(attestation.Hash, stranger.Hash, "spite")
+	signed := func(bs ...Block) []TrustBlock {
+		out := make([]TrustBlock, len(bs))
+		for i, b := range bs {
+			out[i] = TrustBlock{Block: b, AuthorHash: firstRef(b.Refs, "revoker", "attestor", "authority")}
+		}
+		return out
+	}
+	if trace := TraceAttestationsWith(farm.Hash, signed(attestation, forged)); trace.Score != 1 || len(trace.Revoked) != 0 {
+		t.Errorf("a stranger's revocation counted: %+v", trace)
+	}
+
+	withdrawn, err := Revoke(attestation.Hash, certifier.Hash, "audit failed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if withdrawn.Type != RevocationType || withdrawn.Refs["revokes"] != attestation.Hash {
+		t.Errorf("revocation = %+v", withdrawn)
+	}
+	trace := TraceAttestationsWith(farm.Hash, signed(attestation, withdrawn))
+	if trace.Score != 0 || len(trace.Revoked) != 1 || trace.Revocations[0].Hash != withdrawn.Hash {
+		t.Errorf("trace = %+v", trace)
+	}
+	if TrustScore(farm.Hash, []Block{attestation, withdrawn}) != 1 {
+		t.Error("an unsigned revocation counted")
+	}
+
+	certRevoked, _ := Revoke(cert.Hash, certifier.Hash, "lapsed inspection")
+	// A rival claiming to be the authority in refs.revoker, but signing as itself.
+	rival := TrustBlock{Block: certRevoked, AuthorHash: stranger.Hash}
+	if result := ComputeTrust(farm.Hash, []TrustBlock{{Block: farm}, {Block: cert}, rival}, nil); result.Inputs.AuthorityCerts != 1 {
+		t.Errorf("a rival's revocation counted: %+v", result.Inputs)
+	}
+	if result := ComputeTrust(farm.Hash, []TrustBlock{{Block: farm}, {Block: cert}, {Block: certRevoked}}, nil); result.Inputs.AuthorityCerts != 1 {
+		t.Errorf("an unsigned revocation counted: %+v", result.Inputs)
+	}
+	blocks := []TrustBlock{{Block: farm}, {Block: cert}, {Block: certRevoked, AuthorHash: certifier.Hash}}
+	result := ComputeTrust(farm.Hash, blocks, nil)
+	if result.Inputs.AuthorityCerts != 0 || len(result.Inputs.RevokedCerts) != 1 {
+		t.Errorf("inputs = %+v", result.Inputs)
+	}
+	if explained := ExplainTrust(result); !strings.Contains(explained, "0 certification(s), 1 revoked") || !strings.Contains(explained, "revoked certification "+cert.Hash) {
+		t.Errorf("explanation = %s", explained)
+	}
+
+	_, certifierPriv := GenerateKeypair()
+	_, strangerPriv := GenerateKeypair()
+	store := NewMemoryStore()
+	store.PutAll([]Block{farm, cert})
+	store.PutSigned(Sign(certRevoked, stranger.Hash, strangerPriv))
+	if IsRevoked(cert.Hash, store) {
+		t.Error("a rival's signed revocation counted")
+	}
+	store = NewMemoryStore()
+	store.PutAll([]Block{farm, cert, certRevoked})
+	if IsRevoked(cert.Hash, store) {
+		t.Error("an unsigned revocation counted")
+	}
+	store = NewMemoryStore()
+	store.PutAll([]Block{farm, cert})
+	store.PutSigned(Sign(certRevoked, certifier.Hash, certifierPriv))
+	if !IsRevoked(cert.Hash, store) {
+		t.Error("expected the certification to be revoked")
+	}
+
+	if _, err := Revoke(cert.Hash, certifier.Hash, ""); err == nil {
+		t.Error("expected a reason to be required")
+	}
+}
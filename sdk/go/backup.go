@@ -0,0 +1,241 @@
+package foodblock
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+)
+
+var backupMagic = []byte("FBBK\x01")
+
+// backupArchive is the plaintext content of a backup, before compression and encryption.
+type backupArchive struct {
+	CreatedAt string              `json:"created_at"`
+	Snapshot  Block               `json:"snapshot"`
+	Blocks    []Block             `json:"blocks"`
+	Indexes   map[string][]string `json:"indexes"`
+	Aliases   map[string]string   `json:"aliases,omitempty"`
+	// Per-block metadata the store records beside the content: verified
+	// signers, HLC clocks and first-stored times.
+	Signers  map[string]string    `json:"signers,omitempty"`
+	Clocks   map[string]Clock     `json:"clocks,omitempty"`
+	StoredAt map[string]time.Time `json:"stored_at,omitempty"`
+}
+
+// BackupStore writes an encrypted, gzip-compressed archive of a store's blocks,
+// type index and alias registry, with the signers, clocks and stored times
+// the store records for each block. The archive embeds an observe.snapshot block
+// whose Merkle root is checked on restore. The key is derived from the
+// passphrase with Argon2id; content is sealed with AES-256-GCM.
+func BackupStore(store BlockStore, w io.Writer, passphrase string) error {
+	if passphrase == "" {
+		return errors.New("FoodBlock: backup passphrase is required")
+	}
+
+	blocks := store.Blocks()
+	archive := backupArchive{
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		Snapshot:  CreateSnapshot(blocks, "backup", nil),
+		Blocks:    blocks,
+		Indexes:   typeIndexOf(store),
+	}
+	if as, ok := store.(AliasStore); ok {
+		archive.Aliases = as.Aliases().Aliases()
+	}
+	signers, _ := store.(interface{ SignerOf(string) string })
+	clocks, _ := store.(ClockIndex)
+	stored, _ := store.(interface {
+		StoredAt(string) (time.Time, bool)
+	})
+	for _, b := range blocks {
+		if signers != nil {
+			if a := signers.SignerOf(b.Hash); a != "" {
+				if archive.Signers == nil {
+					archive.Signers = map[string]string{}
+				}
+				archive.Signers[b.Hash] = a
+			}
+		}
+		if clocks != nil {
+			if c, ok := clocks.ClockOf(b.Hash); ok {
+				if archive.Clocks == nil {
+					archive.Clocks = map[string]Clock{}
+				}
+				archive.Clocks[b.Hash] = c
+			}
+		}
+		if stored != nil {
+			if t, ok := stored.StoredAt(b.Hash); ok {
+				if archive.StoredAt == nil {
+					archive.StoredAt = map[string]time.Time{}
+				}
+				archive.StoredAt[b.Hash] = t
+			}
+		}
+	}
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if err := json.NewEncoder(gz).Encode(archive); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	salt := make([]byte, 16)
+	nonce := make([]byte, 12)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	header := append(append([]byte{}, backupMagic...), salt...)
+	aead, err := passphraseAEAD(passphrase, salt)
+	if err != nil {
+		return err
+	}
+	sealed := aead.Seal(nil, nonce, compressed.Bytes(), header)
+
+	for _, part := range [][]byte{header, nonce, sealed} {
+		if _, err := w.Write(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RestoreStore decrypts a backup written by BackupStore into a new MemoryStore,
+// verifying every block hash, the embedded snapshot root and the type index.
+// Signers, clocks and stored times come back as they were backed up; the
+// restored store's HLC observes every clock, so new blocks order after them.
+func RestoreStore(r io.Reader, passphrase string) (*MemoryStore, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	headerLen := len(backupMagic) + 16
+	if len(data) < headerLen+12 || !bytes.Equal(data[:len(backupMagic)], backupMagic) {
+		return nil, errors.New("FoodBlock: not a FoodBlock backup")
+	}
+	header := data[:headerLen]
+	salt := header[len(backupMagic):]
+	nonce := data[headerLen : headerLen+12]
+
+	aead, err := passphraseAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	compressed, err := aead.Open(nil, nonce, data[headerLen+12:], header)
+	if err != nil {
+		return nil, errors.New("FoodBlock: wrong passphrase or corrupted backup")
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	var archive backupArchive
+	if err := json.NewDecoder(gz).Decode(&archive); err != nil {
+		return nil, err
+	}
+
+	snap := archive.Snapshot
+	if snap.Type != "observe.snapshot" || Hash(snap.Type, snap.State, snap.Refs) != snap.Hash {
+		return nil, errors.New("FoodBlock: backup snapshot is invalid")
+	}
+	if valid, _ := VerifySnapshot(snap, archive.Blocks); !valid {
+		return nil, errors.New("FoodBlock: backup blocks do not match snapshot root")
+	}
+
+	store := NewMemoryStore()
+	for _, b := range archive.Blocks {
+		put := store.Put
+		if IsStub(b) {
+			put = store.PutStub
+		}
+		if err := put(b); err != nil {
+			return nil, err
+		}
+	}
+	store.restoreMetadata(archive)
+	if !sameIndex(store.TypeIndex(), archive.Indexes) {
+		return nil, errors.New("FoodBlock: backup index does not match blocks")
+	}
+	for alias, hash := range archive.Aliases {
+		store.Aliases().Set(alias, hash)
+	}
+	return store, nil
+}
+
+// restoreMetadata sets the signers, clocks and stored times of restored
+// blocks, which Put and PutStub would otherwise leave unset or stamp with
+// the restore time.
+func (s *MemoryStore) restoreMetadata(archive backupArchive) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for hash, signer := range archive.Signers {
+		if _, ok := s.blocks[hash]; ok {
+			s.authors[hash] = signer
+		}
+	}
+	for hash, c := range archive.Clocks {
+		if _, ok := s.blocks[hash]; ok {
+			s.clocks[hash] = c
+			s.clock.Observe(c)
+		}
+	}
+	for hash, t := range archive.StoredAt {
+		if _, ok := s.blocks[hash]; ok {
+			s.stored[hash] = t
+		}
+	}
+}
+
+// passphraseAEAD derives an AES-256-GCM cipher from a passphrase with Argon2id.
+func passphraseAEAD(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key := argon2.IDKey([]byte(passphrase), salt, 2, 19*1024, 1, 32)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func typeIndexOf(store BlockStore) map[string][]string {
+	if ti, ok := store.(interface{ TypeIndex() map[string][]string }); ok {
+		return ti.TypeIndex()
+	}
+	index := make(map[string][]string)
+	for _, b := range store.Blocks() {
+		index[b.Type] = append(index[b.Type], b.Hash)
+	}
+	return index
+}
+
+func sameIndex(a, b map[string][]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, va := range a {
+		vb := b[k]
+		if len(va) != len(vb) {
+			return false
+		}
+		for i := range va {
+			if va[i] != vb[i] {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,102 @@
+package foodblock
+
+import (
+	"bytes"
+	"testing"
+)
+
+func backupFixture(t *testing.T) *MemoryStore {
+	t.Helper()
+	store := NewMemoryStore()
+	vendor := Create("actor.vendor", map[string]interface{}{"name": "Jam Stall"}, nil)
+	jam := Create("substance.product", map[string]interface{}{"name": "Strawberry Jam", "price": 4.5}, map[string]interface{}{"seller": vendor.Hash})
+	sale := Create("transfer.order", map[string]interface{}{"quantity": 2}, map[string]interface{}{"item": jam.Hash, "seller": vendor.Hash})
+	if err := store.PutAll([]Block{vendor, jam, sale}); err != nil {
+		t.Fatal(err)
+	}
+	store.Aliases().Set("stall", vendor.Hash)
+	return store
+}
+
+func TestBackupRestoreRoundTrip(t *testing.T) {
+	store := backupFixture(t)
+	var buf bytes.Buffer
+	if err := BackupStore(store, &buf, "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(buf.Bytes(), []byte("Strawberry")) {
+		t.Fatal("backup must not contain plaintext")
+	}
+
+	restored, err := RestoreStore(bytes.NewReader(buf.Bytes()), "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Len() != store.Len() {
+		t.Errorf("expected %d blocks, got %d", store.Len(), restored.Len())
+	}
+	hash, err := restored.Aliases().Resolve("@stall")
+	if err != nil || restored.Resolve(hash) == nil {
+		t.Errorf("expected alias registry to be restored, got %q %v", hash, err)
+	}
+	if len(restored.ResolveForward(hash)) != 2 {
+		t.Error("expected forward index to be rebuilt")
+	}
+}
+
+func TestRestoreWrongPassphrase(t *testing.T) {
+	var buf bytes.Buffer
+	BackupStore(backupFixture(t), &buf, "correct horse")
+	if _, err := RestoreStore(bytes.NewReader(buf.Bytes()), "battery staple"); err == nil {
+		t.Error("expected wrong passphrase to fail")
+	}
+}
+
+func TestRestoreDetectsCorruption(t *testing.T) {
+	var buf bytes.Buffer
+	BackupStore(backupFixture(t), &buf, "pw")
+	data := buf.Bytes()
+	data[len(data)-1] ^= 0xff
+	if _, err := RestoreStore(bytes.NewReader(data), "pw"); err == nil {
+		t.Error("expected corrupted archive to fail")
+	}
+}
+
+func TestBackupKeepsSignersClocksAndStoredTimes(t *testing.T) {
+	store := NewMemoryStore()
+	farm := Create("actor.producer", map[string]interface{}{"name": "Green Acres"}, nil)
+	wheat := Create("substance.product", map[string]interface{}{"name": "Wheat"}, map[string]interface{}{"seller": farm.Hash})
+	store.PutSigned(SignedBlock{FoodBlock: farm, AuthorHash: farm.Hash, Clock: &Clock{Wall: 1000, Counter: 2, Node: "farm"}})
+	store.PutSigned(SignedBlock{FoodBlock: wheat, AuthorHash: farm.Hash})
+	store.Stub(wheat.Hash, map[string]interface{}{"tombstoned": true})
+
+	var buf bytes.Buffer
+	if err := BackupStore(store, &buf, "pw"); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := RestoreStore(bytes.NewReader(buf.Bytes()), "pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, h := range []string{farm.Hash, wheat.Hash} {
+		if got := restored.SignerOf(h); got != farm.Hash {
+			t.Errorf("signer of %s = %q", h, got)
+		}
+		want, _ := store.ClockOf(h)
+		if got, ok := restored.ClockOf(h); !ok || got != want {
+			t.Errorf("clock of %s = %+v, want %+v", h, got, want)
+		}
+		want2, _ := store.StoredAt(h)
+		if got, ok := restored.StoredAt(h); !ok || !got.Equal(want2) {
+			t.Errorf("stored at of %s = %v, want %v", h, got, want2)
+		}
+	}
+	// New blocks order after everything restored.
+	later := Create("observe.note", map[string]interface{}{"text": "after"}, nil)
+	restored.Put(later)
+	c, _ := restored.ClockOf(later.Hash)
+	last, _ := restored.ClockOf(wheat.Hash)
+	if c.Compare(last) <= 0 {
+		t.Errorf("new clock %+v does not follow %+v", c, last)
+	}
+}
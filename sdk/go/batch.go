@@ -0,0 +1,271 @@
+package foodblock
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// BatchContentType is the media type of an encoded batch. A compressed
+// batch names its EnvelopeCompressors entry in Content-Encoding.
+const BatchContentType = "application/x-foodblock-batch+ndjson"
+
+// MaxBatchBytes caps the body BatchHandler reads.
+const MaxBatchBytes = 64 << 20
+
+// A batch is newline-delimited JSON: one SignedBlock per line, then a
+// footer line {"footer":{"count":N,"root":R}} where R is the Merkle root of
+// the block hashes, as in snapshots. The footer lets the receiver detect a
+// truncated or altered batch before storing any of it.
+
+// BatchFooter closes a batch.
+type BatchFooter struct {
+	Count int    `json:"count"`
+	Root  string `json:"root"`
+}
+
+type batchLine struct {
+	Footer *BatchFooter `json:"footer,omitempty"`
+	SignedBlock
+}
+
+// BatchWriter encodes a batch block by block. Close writes the footer and
+// must be called.
+type BatchWriter struct {
+	w      io.Writer
+	zw     io.WriteCloser
+	enc    *json.Encoder
+	hashes []string
+	closed bool
+}
+
+// NewBatchWriter starts a batch on w, compressed with the named
+// EnvelopeCompressors entry unless compression is empty.
+func NewBatchWriter(w io.Writer, compression string) (*BatchWriter, error) {
+	bw := &BatchWriter{w: w}
+	if compression != "" {
+		c, ok := EnvelopeCompressors[compression]
+		if !ok {
+			return nil, fmt.Errorf("FoodBlock: unknown batch compression %s", compression)
+		}
+		zw, err := c.Compress(w)
+		if err != nil {
+			return nil, err
+		}
+		bw.zw, w = zw, zw
+	}
+	bw.enc = json.NewEncoder(w)
+	bw.enc.SetEscapeHTML(false)
+	return bw, nil
+}
+
+// Add appends a block to the batch.
+func (bw *BatchWriter) Add(s SignedBlock) error {
+	if bw.closed {
+		return errors.New("FoodBlock: batch is closed")
+	}
+	if s.FoodBlock.Hash == "" {
+		return errors.New("FoodBlock: batch blocks need a hash")
+	}
+	bw.hashes = append(bw.hashes, s.FoodBlock.Hash)
+	return bw.enc.Encode(batchLine{SignedBlock: s})
+}
+
+// Close writes the footer and flushes any compression.
+func (bw *BatchWriter) Close() error {
+	if bw.closed {
+		return nil
+	}
+	bw.closed = true
+	footer := BatchFooter{Count: len(bw.hashes), Root: computeMerkleRoot(bw.hashes)}
+	if err := bw.enc.Encode(map[string]BatchFooter{"footer": footer}); err != nil {
+		return err
+	}
+	if bw.zw != nil {
+		return bw.zw.Close()
+	}
+	return nil
+}
+
+// EncodeBatch writes blocks to w as a complete batch.
+func EncodeBatch(w io.Writer, blocks []SignedBlock, compression string) error {
+	bw, err := NewBatchWriter(w, compression)
+	if err != nil {
+		return err
+	}
+	for _, s := range blocks {
+		if err := bw.Add(s); err != nil {
+			return err
+		}
+	}
+	return bw.Close()
+}
+
+// DecodeBatch reads a batch and verifies it: every block's hash matches its
+// content, the footer is present and last, and its count and Merkle root
+// match the blocks read. Nothing is returned unless all checks pass.
+func DecodeBatch(r io.Reader, compression string) ([]SignedBlock, BatchFooter, error) {
+	if compression != "" {
+		c, ok := EnvelopeCompressors[compression]
+		if !ok {
+			return nil, BatchFooter{}, fmt.Errorf("FoodBlock: unknown batch compression %s", compression)
+		}
+		zr, err := c.Decompress(r)
+		if err != nil {
+			return nil, BatchFooter{}, fmt.Errorf("FoodBlock: failed to decompress batch: %w", err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	var blocks []SignedBlock
+	var hashes []string
+	var footer *BatchFooter
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), MaxBatchBytes)
+	for n := 1; sc.Scan(); n++ {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if footer != nil {
+			return nil, BatchFooter{}, fmt.Errorf("FoodBlock: batch line %d follows the footer", n)
+		}
+		var l batchLine
+		if err := json.Unmarshal(line, &l); err != nil {
+			return nil, BatchFooter{}, fmt.Errorf("FoodBlock: batch line %d: %w", n, err)
+		}
+		if l.Footer != nil {
+			footer = l.Footer
+			continue
+		}
+		b := l.FoodBlock
+		if b.Type == "" || Hash(b.Type, b.State, b.Refs) != b.Hash {
+			return nil, BatchFooter{}, fmt.Errorf("FoodBlock: batch line %d: hash does not match content", n)
+		}
+		blocks = append(blocks, l.SignedBlock)
+		hashes = append(hashes, b.Hash)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, BatchFooter{}, fmt.Errorf("FoodBlock: reading batch: %w", err)
+	}
+	if footer == nil {
+		return nil, BatchFooter{}, errors.New("FoodBlock: batch has no footer, it may be truncated")
+	}
+	if footer.Count != len(blocks) {
+		return nil, *footer, fmt.Errorf("FoodBlock: batch footer counts %d blocks, read %d", footer.Count, len(blocks))
+	}
+	if root := computeMerkleRoot(hashes); footer.Root != root {
+		return nil, *footer, fmt.Errorf("FoodBlock: batch root %s does not match blocks (%s)", footer.Root, root)
+	}
+	return blocks, *footer, nil
+}
+
+// BatchReceipt is BatchHandler's response to an accepted batch.
+type BatchReceipt struct {
+	Accepted int    `json:"accepted"`
+	Root     string `json:"root"`
+}
+
+// BatchHandler accepts batches POSTed to it, storing them only after the
+// whole batch verifies: its integrity footer, every block hash and, when
+// keys is not nil, every block's signature. Blocks are stored in causal
+// order and blocks already stored are skipped.
+//
+// Without keys, signatures are not checked, so blocks are stored with Put
+// and no signer is recorded. With keys, blocks are stored with PutSigned
+// when the store supports it. With a guard as well, every wrapper must be a
+// write made with SignWrite and is admitted through the guard, as
+// WriteHandler does; without one the handler relays blocks signed by
+// anyone in keys, such as a peer forwarding other actors' blocks.
+func BatchHandler(store BlockStore, keys *KeyRegistry, guard *ReplayGuard) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "batches must be POSTed")
+			return
+		}
+		blocks, footer, err := DecodeBatch(http.MaxBytesReader(w, r.Body, MaxBatchBytes), r.Header.Get("Content-Encoding"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		byHash := map[string]SignedBlock{}
+		plain := make([]Block, len(blocks))
+		for i, s := range blocks {
+			switch {
+			case keys == nil:
+			case !keys.VerifySigned(s):
+				writeError(w, http.StatusUnprocessableEntity, "signature does not verify for block "+s.FoodBlock.Hash)
+				return
+			case guard != nil && !keys.VerifyWrite(s):
+				writeError(w, http.StatusUnprocessableEntity, "write binding does not verify for block "+s.FoodBlock.Hash)
+				return
+			}
+			byHash[s.FoodBlock.Hash] = s
+			plain[i] = s.FoodBlock
+		}
+		signed, _ := store.(interface{ PutSigned(SignedBlock) error })
+		for _, b := range Order(plain, nil) {
+			s := byHash[b.Hash]
+			if keys != nil && guard != nil {
+				if _, err := acceptWrite(store, guard, s); err != nil {
+					writeError(w, http.StatusConflict, err.Error())
+					return
+				}
+				continue
+			}
+			if store.Resolve(b.Hash) != nil {
+				continue
+			}
+			put := func() error { return store.Put(b) }
+			if keys != nil && signed != nil {
+				put = func() error { return signed.PutSigned(s) }
+			}
+			if err := put(); err != nil {
+				writeError(w, http.StatusConflict, err.Error())
+				return
+			}
+		}
+		writeJSON(w, http.StatusOK, BatchReceipt{Accepted: footer.Count, Root: footer.Root})
+	})
+}
+
+// PostBatch sends blocks to a peer's BatchHandler mounted at /batch.
+func (c *FederationClient) PostBatch(ctx context.Context, blocks []SignedBlock, compression string) (BatchReceipt, error) {
+	var body bytes.Buffer
+	if err := EncodeBatch(&body, blocks, compression); err != nil {
+		return BatchReceipt{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/batch", &body)
+	if err != nil {
+		return BatchReceipt{}, err
+	}
+	req.Header.Set("Content-Type", BatchContentType)
+	if compression != "" {
+		req.Header.Set("Content-Encoding", compression)
+	}
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return BatchReceipt{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&e)
+		return BatchReceipt{}, fmt.Errorf("FoodBlock: peer returned %d: %s", resp.StatusCode, e.Error)
+	}
+	var receipt BatchReceipt
+	err = json.NewDecoder(resp.Body).Decode(&receipt)
+	return receipt, err
+}
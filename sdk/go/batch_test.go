@@ -0,0 +1,144 @@
+package foodblock
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func batchBlocks(t *testing.T) ([]SignedBlock, *KeyRegistry) {
+	t.Helper()
+	pub, priv := GenerateKeypair()
+	mill := Create("actor.producer", map[string]interface{}{"name": "Mill"}, nil)
+	keys := NewKeyRegistry()
+	keys.Register(mill.Hash, pub)
+	flour := Create("substance.ingredient", map[string]interface{}{"name": "Flour"}, map[string]interface{}{"seller": mill.Hash})
+	bread := Create("substance.product", map[string]interface{}{"name": "Bread"}, map[string]interface{}{"seller": mill.Hash, "inputs": []interface{}{flour.Hash}})
+	// Dependents first: the receiver orders them.
+	return []SignedBlock{Sign(bread, mill.Hash, priv), Sign(flour, mill.Hash, priv), Sign(mill, mill.Hash, priv)}, keys
+}
+
+func TestEncodeDecodeBatch(t *testing.T) {
+	blocks, _ := batchBlocks(t)
+	for _, compression := range []string{"", "gzip"} {
+		var buf bytes.Buffer
+		if err := EncodeBatch(&buf, blocks, compression); err != nil {
+			t.Fatal(err)
+		}
+		got, footer, err := DecodeBatch(bytes.NewReader(buf.Bytes()), compression)
+		if err != nil || len(got) != 3 || footer.Count != 3 || got[0].Signature != blocks[0].Signature {
+			t.Fatalf("%q: %d blocks, %+v, %v", compression, len(got), footer, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	EncodeBatch(&buf, blocks, "")
+	lines := strings.SplitAfter(buf.String(), "\n")
+	if !strings.HasPrefix(lines[3], `{"footer":{"count":3,"root":"`) {
+		t.Errorf("footer line = %s", lines[3])
+	}
+	for name, body := range map[string]string{
+		"truncated":     strings.Join(lines[:3], ""),
+		"dropped block": lines[0] + lines[2] + lines[3],
+		"swapped block": lines[0] + lines[1] + strings.Replace(lines[2], `"Mill"`, `"Mall"`, 1) + lines[3],
+		"after footer":  buf.String() + lines[0],
+	} {
+		if _, _, err := DecodeBatch(strings.NewReader(body), ""); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestBatchHandler(t *testing.T) {
+	blocks, keys := batchBlocks(t)
+	store := NewMemoryStore()
+	store.SetIntegrity(IntegrityStrict)
+	mux := http.NewServeMux()
+	mux.Handle("/batch", BatchHandler(store, keys, nil))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	client := NewFederationClient(srv.URL)
+
+	receipt, err := client.PostBatch(context.Background(), blocks, "gzip")
+	if err != nil || receipt.Accepted != 3 || store.Len() != 3 {
+		t.Fatalf("receipt = %+v, %v, stored %d", receipt, err, store.Len())
+	}
+	if store.AuthorOf(blocks[0].FoodBlock.Hash) != blocks[0].AuthorHash {
+		t.Error("signed blocks should be stored with their author")
+	}
+
+	// A bad signature rejects the whole batch.
+	other := NewMemoryStore()
+	forged := append([]SignedBlock(nil), blocks...)
+	forged[1].Signature = blocks[0].Signature
+	srv2 := httptest.NewServer(BatchHandler(other, keys, nil))
+	defer srv2.Close()
+	if _, err := NewFederationClient(srv2.URL).PostBatch(context.Background(), forged, ""); err == nil || other.Len() != 0 {
+		t.Errorf("forged batch: %v, stored %d", err, other.Len())
+	}
+
+	// Re-signing a stored block does not change its signer.
+	intruderPub, intruderPriv := GenerateKeypair()
+	keys.Register("intruder", intruderPub)
+	resigned := []SignedBlock{Sign(blocks[0].FoodBlock, "intruder", intruderPriv)}
+	if _, err := client.PostBatch(context.Background(), resigned, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := store.SignerOf(blocks[0].FoodBlock.Hash); got != blocks[0].AuthorHash {
+		t.Errorf("re-signed block now has signer %q", got)
+	}
+}
+
+func TestBatchHandlerWithoutKeysRecordsNoSigner(t *testing.T) {
+	blocks, _ := batchBlocks(t)
+	for i := range blocks {
+		blocks[i].Signature = "00"
+	}
+	store := NewMemoryStore()
+	srv := httptest.NewServer(BatchHandler(store, nil, nil))
+	defer srv.Close()
+	if _, err := NewFederationClient(srv.URL).PostBatch(context.Background(), blocks, ""); err != nil || store.Len() != 3 {
+		t.Fatalf("%v, stored %d", err, store.Len())
+	}
+	for _, b := range blocks {
+		if got := store.SignerOf(b.FoodBlock.Hash); got != "" {
+			t.Errorf("unverified block has signer %q", got)
+		}
+	}
+}
+
+func TestBatchHandlerWithReplayGuard(t *testing.T) {
+	pub, priv := GenerateKeypair()
+	mill := Create("actor.producer", map[string]interface{}{"name": "Mill"}, nil)
+	keys := NewKeyRegistry()
+	keys.Register(mill.Hash, pub)
+	flour := Create("substance.ingredient", map[string]interface{}{"name": "Flour"}, map[string]interface{}{"seller": mill.Hash})
+	store := NewMemoryStore()
+	srv := httptest.NewServer(BatchHandler(store, keys, NewReplayGuard("peer-a")))
+	defer srv.Close()
+	client := NewFederationClient(srv.URL)
+
+	// Plain signatures carry no write binding.
+	if _, err := client.PostBatch(context.Background(), []SignedBlock{Sign(mill, mill.Hash, priv)}, ""); err == nil || store.Len() != 0 {
+		t.Fatalf("unbound write: %v, stored %d", err, store.Len())
+	}
+	writes := []SignedBlock{
+		SignWrite(flour, mill.Hash, priv, WriteOptions{Audience: "peer-a"}),
+		SignWrite(mill, mill.Hash, priv, WriteOptions{Audience: "peer-a"}),
+	}
+	if _, err := client.PostBatch(context.Background(), writes, ""); err != nil || store.Len() != 2 {
+		t.Fatalf("bound writes: %v, stored %d", err, store.Len())
+	}
+	// Resending the batch is a retry; a write for another peer is refused.
+	if _, err := client.PostBatch(context.Background(), writes, ""); err != nil {
+		t.Errorf("retry: %v", err)
+	}
+	bread := Create("substance.product", map[string]interface{}{"name": "Bread"}, map[string]interface{}{"seller": mill.Hash})
+	elsewhere := SignWrite(bread, mill.Hash, priv, WriteOptions{Audience: "peer-b"})
+	if _, err := client.PostBatch(context.Background(), []SignedBlock{elsewhere}, ""); err == nil || store.Len() != 2 {
+		t.Errorf("write for another peer: %v, stored %d", err, store.Len())
+	}
+}
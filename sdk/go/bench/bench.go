@@ -0,0 +1,124 @@
+// Package bench holds the SDK benchmark suite and its performance budgets.
+//
+// Run the suite with:
+//
+//	go test -run '^$' -bench . ./bench
+//
+// Each benchmark compares its ns/op against the budget below and fails when
+// it exceeds the budget by more than Tolerance, so a major regression breaks
+// the run. Budgets sit at roughly 2.5x the figure measured on a shared CI
+// core, so together with the tolerance they catch algorithmic regressions
+// rather than machine noise. The million-block recall and the 1k-actor trust
+// sweep only run when FOODBLOCK_BENCH_LARGE=1 is set.
+package bench
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+
+	foodblock "github.com/FoodXDevelopment/foodblock/sdk/go"
+)
+
+// Tolerance is the multiple of a budget a benchmark may reach before failing.
+// Override with FOODBLOCK_BENCH_TOLERANCE.
+var Tolerance = 2.0
+
+// Budgets are the expected upper bounds per operation.
+var Budgets = map[string]time.Duration{
+	"Create":           25 * time.Microsecond,
+	"Hash":             20 * time.Microsecond,
+	"Canonical":        15 * time.Microsecond,
+	"Recall/10k":       100 * time.Millisecond,
+	"Recall/100k":      1500 * time.Millisecond,
+	"Recall/1M":        20 * time.Second,
+	"Trust/single":     40 * time.Millisecond,
+	"Trust/100-actors": 500 * time.Millisecond,
+	"Trust/1k-actors":  40 * time.Second,
+	"MapFields/long":   30 * time.Millisecond,
+}
+
+// Budget returns the failure threshold for a named benchmark.
+func Budget(name string) (time.Duration, bool) {
+	d, ok := Budgets[name]
+	if !ok {
+		return 0, false
+	}
+	tol := Tolerance
+	if env := os.Getenv("FOODBLOCK_BENCH_TOLERANCE"); env != "" {
+		fmt.Sscanf(env, "%g", &tol)
+	}
+	return time.Duration(float64(d) * tol), true
+}
+
+// LargeEnabled reports whether the largest benchmarks should run.
+func LargeEnabled() bool {
+	return os.Getenv("FOODBLOCK_BENCH_LARGE") == "1"
+}
+
+// RecallGraph builds a graph of n blocks descending from a single source
+// ingredient. Each block references one or two earlier blocks, so a recall
+// of the source reaches every block.
+func RecallGraph(n int, seed int64) (*foodblock.MemoryStore, string) {
+	rng := rand.New(rand.NewSource(seed))
+	store := foodblock.NewMemoryStore()
+	source := foodblock.Create("substance.ingredient", map[string]interface{}{"name": "Wheat lot 1"}, nil)
+	store.Put(source)
+	hashes := make([]string, 1, n)
+	hashes[0] = source.Hash
+	for i := 1; i < n; i++ {
+		inputs := []interface{}{hashes[rng.Intn(len(hashes))]}
+		if i > 2 && rng.Intn(4) == 0 {
+			if other := hashes[rng.Intn(len(hashes))]; other != inputs[0] {
+				inputs = append(inputs, other)
+			}
+		}
+		b := foodblock.Create("substance.product", map[string]interface{}{"name": fmt.Sprintf("Product %d", i)}, map[string]interface{}{"inputs": inputs})
+		store.Put(b)
+		hashes = append(hashes, b.Hash)
+	}
+	return store, source.Hash
+}
+
+// TrustGraph builds reviews, certifications and orders for a set of actors.
+func TrustGraph(actors, blocksPerActor int, seed int64) ([]string, []foodblock.TrustBlock) {
+	rng := rand.New(rand.NewSource(seed))
+	authority := foodblock.Create("actor.authority", map[string]interface{}{"name": "Authority"}, nil)
+	hashes := make([]string, actors)
+	var blocks []foodblock.TrustBlock
+	for i := range hashes {
+		actor := foodblock.Create("actor.producer", map[string]interface{}{"name": fmt.Sprintf("Farm %d", i)}, nil)
+		hashes[i] = actor.Hash
+		blocks = append(blocks, foodblock.TrustBlock{Block: actor, AuthorHash: actor.Hash})
+	}
+	created := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC).Format(time.RFC3339)
+	for i, actor := range hashes {
+		for j := 0; j < blocksPerActor; j++ {
+			var b foodblock.Block
+			author := hashes[rng.Intn(len(hashes))]
+			switch j % 3 {
+			case 0:
+				b = foodblock.Create("observe.review", map[string]interface{}{"rating": 1 + rng.Intn(5), "instance_id": fmt.Sprintf("r-%d-%d", i, j)},
+					map[string]interface{}{"subject": actor, "author": author})
+			case 1:
+				b = foodblock.Create("observe.certification", map[string]interface{}{"name": "Organic", "valid_until": "2030-01-01", "instance_id": fmt.Sprintf("c-%d-%d", i, j)},
+					map[string]interface{}{"subject": actor, "authority": authority.Hash})
+				author = authority.Hash
+			default:
+				b = foodblock.Create("transfer.order", map[string]interface{}{"status": "confirmed", "instance_id": fmt.Sprintf("o-%d-%d", i, j)},
+					map[string]interface{}{"seller": actor, "buyer": author})
+			}
+			blocks = append(blocks, foodblock.TrustBlock{Block: b, AuthorHash: author, CreatedAt: created})
+		}
+	}
+	return hashes, blocks
+}
+
+// LongText returns a product description of roughly n words.
+func LongText(n int) string {
+	sentence := "organic sourdough loaf weighs 800g costs £4.50 contains gluten and wheat baked fresh this morning "
+	words := len(strings.Fields(sentence))
+	return strings.Repeat(sentence, n/words+1)
+}
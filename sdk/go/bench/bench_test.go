@@ -0,0 +1,148 @@
+package bench
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	foodblock "github.com/FoodXDevelopment/foodblock/sdk/go"
+)
+
+// checkBudget fails the benchmark when ns/op exceeds its budget. The first
+// calibration round (b.N == 1) is skipped because it includes warm-up.
+func checkBudget(b *testing.B, name string) {
+	b.Helper()
+	if b.N == 1 && b.Elapsed() < time.Second {
+		return
+	}
+	limit, ok := Budget(name)
+	if !ok {
+		return
+	}
+	perOp := b.Elapsed() / time.Duration(b.N)
+	b.ReportMetric(float64(perOp)/float64(Budgets[name]), "x-budget")
+	if perOp > limit {
+		b.Fatalf("%s: %v/op exceeds budget %v (x%.1f tolerance)", name, perOp, Budgets[name], Tolerance)
+	}
+}
+
+func BenchmarkCreate(b *testing.B) {
+	state := map[string]interface{}{"name": "Sourdough", "price": 4.5, "allergens": map[string]interface{}{"gluten": true}}
+	refs := map[string]interface{}{"seller": "a1b2c3"}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		foodblock.Create("substance.product", state, refs)
+	}
+	b.StopTimer()
+	checkBudget(b, "Create")
+}
+
+func BenchmarkHash(b *testing.B) {
+	state := map[string]interface{}{"name": "Sourdough", "price": 4.5, "tags": []interface{}{"organic", "local"}}
+	refs := map[string]interface{}{"seller": "a1b2c3", "inputs": []interface{}{"d4", "e5"}}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		foodblock.Hash("substance.product", state, refs)
+	}
+	b.StopTimer()
+	checkBudget(b, "Hash")
+}
+
+func BenchmarkCanonical(b *testing.B) {
+	state := map[string]interface{}{"name": "Sourdough", "price": 4.5, "weight": map[string]interface{}{"value": 800, "unit": "g"}}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		foodblock.Canonical("substance.product", state, nil)
+	}
+	b.StopTimer()
+	checkBudget(b, "Canonical")
+}
+
+func BenchmarkRecall(b *testing.B) {
+	for _, size := range []struct {
+		name  string
+		n     int
+		large bool
+	}{{"10k", 10_000, false}, {"100k", 100_000, false}, {"1M", 1_000_000, true}} {
+		b.Run(size.name, func(b *testing.B) {
+			if size.large && !LargeEnabled() {
+				b.Skip("set FOODBLOCK_BENCH_LARGE=1 to run")
+			}
+			store, source := RecallGraph(size.n, 1)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				result := foodblock.Recall(source, store.ResolveForward, size.n, nil, nil)
+				if len(result.Affected) < size.n/2 {
+					b.Fatalf("recall reached %d of %d blocks", len(result.Affected), size.n)
+				}
+			}
+			b.StopTimer()
+			checkBudget(b, "Recall/"+size.name)
+		})
+	}
+}
+
+func BenchmarkTrust(b *testing.B) {
+	actors, blocks := TrustGraph(1000, 12, 1)
+	b.Run("single", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			foodblock.ComputeTrust(actors[i%len(actors)], blocks, nil)
+		}
+		b.StopTimer()
+		checkBudget(b, "Trust/single")
+	})
+	for _, set := range []struct {
+		name  string
+		n     int
+		large bool
+	}{{"100-actors", 100, false}, {"1k-actors", 1000, true}} {
+		b.Run(set.name, func(b *testing.B) {
+			if set.large && !LargeEnabled() {
+				b.Skip("set FOODBLOCK_BENCH_LARGE=1 to run")
+			}
+			actors, blocks := TrustGraph(set.n, 12, 1)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for _, a := range actors {
+					foodblock.ComputeTrust(a, blocks, nil)
+				}
+			}
+			b.StopTimer()
+			checkBudget(b, "Trust/"+set.name)
+		})
+	}
+}
+
+func BenchmarkMapFields(b *testing.B) {
+	text := LongText(2000)
+	vocab := foodblock.Vocabularies["bakery"]
+	b.SetBytes(int64(len(text)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		foodblock.MapFields(text, vocab)
+	}
+	b.StopTimer()
+	checkBudget(b, "MapFields/long")
+}
+
+func TestBudgetsCoverBenchmarks(t *testing.T) {
+	for _, name := range []string{"Create", "Hash", "Canonical", "Recall/10k", "Recall/100k", "Recall/1M", "Trust/single", "Trust/100-actors", "Trust/1k-actors", "MapFields/long"} {
+		if _, ok := Budget(name); !ok {
+			t.Errorf("no budget for %s", name)
+		}
+	}
+}
+
+func TestRecallGraphReachesAll(t *testing.T) {
+	store, source := RecallGraph(500, 3)
+	result := foodblock.Recall(source, store.ResolveForward, 500, nil, nil)
+	if len(result.Affected) != 499 {
+		t.Errorf("affected = %d, want 499", len(result.Affected))
+	}
+	if fmt.Sprint(store.Len()) != "500" {
+		t.Errorf("store len = %d", store.Len())
+	}
+}
@@ -0,0 +1,371 @@
+package foodblock
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ButcheryType records one carcass broken down into cuts.
+const ButcheryType = "transform.butchery"
+
+// ButcheryLossTolerance is the share of carcass weight, in percent, that may
+// go unaccounted for (drip, sawdust, moisture) before a breakdown's mass
+// balance fails.
+const ButcheryLossTolerance = 3.0
+
+// CutSpec is one cut of a cutting specification and its expected yield as a
+// percentage of carcass weight.
+type CutSpec struct {
+	Cut           string  `json:"cut"`
+	Primal        string  `json:"primal"`
+	ExpectedYield float64 `json:"expected_yield"` // percent of carcass weight
+}
+
+// CuttingSpec breaks a species' carcass into primals and cuts. Tolerance is
+// the allowed yield variance per cut in percentage points.
+type CuttingSpec struct {
+	Name      string    `json:"name"`
+	Species   string    `json:"species"`
+	Cuts      []CutSpec `json:"cuts"`
+	Tolerance float64   `json:"tolerance"`
+}
+
+// CuttingSpecs holds the built-in specifications by species. Callers may add
+// or replace entries, or pass a spec to CreateButchery directly.
+var CuttingSpecs = map[string]CuttingSpec{
+	"beef": {
+		Name: "beef_standard", Species: "beef", Tolerance: 1.5,
+		Cuts: []CutSpec{
+			{"chuck_roll", "chuck", 10}, {"blade", "chuck", 6},
+			{"ribeye", "rib", 6},
+			{"striploin", "loin", 5}, {"tenderloin", "loin", 2}, {"sirloin", "loin", 6},
+			{"topside", "round", 8}, {"silverside", "round", 7}, {"knuckle", "round", 4},
+			{"brisket", "brisket", 4}, {"flank", "flank", 2},
+		},
+	},
+	"lamb": {
+		Name: "lamb_standard", Species: "lamb", Tolerance: 2,
+		Cuts: []CutSpec{
+			{"leg", "leg", 30}, {"shoulder", "shoulder", 20}, {"rack", "rack", 8},
+			{"loin", "loin", 9}, {"neck", "neck", 4}, {"breast", "breast", 5},
+		},
+	},
+	"pork": {
+		Name: "pork_standard", Species: "pork", Tolerance: 2,
+		Cuts: []CutSpec{
+			{"leg", "leg", 24}, {"loin", "loin", 20}, {"shoulder", "shoulder", 18}, {"belly", "belly", 14},
+		},
+	},
+}
+
+// cut returns the spec entry for a cut name.
+func (s CuttingSpec) cut(name string) (CutSpec, bool) {
+	for _, c := range s.Cuts {
+		if c.Cut == name {
+			return c, true
+		}
+	}
+	return CutSpec{}, false
+}
+
+// Butchery describes one carcass breakdown.
+type Butchery struct {
+	Carcass       string             // substance hash of the carcass
+	Operator      string             // butcher or plant
+	Species       string             // selects CuttingSpecs; defaults to the carcass's state.animal or species
+	Spec          *CuttingSpec       // overrides CuttingSpecs[Species]
+	CarcassWeight float64            // kg; defaults to the carcass's quantity or weight
+	Cuts          map[string]float64 // cut -> kg
+	Trim          float64            // kg of fat, bone and trim kept as by-product
+	Date          time.Time
+}
+
+// CreateButchery records a breakdown as a transform.butchery block (refs.input
+// is the carcass) and returns one substance.meat block per cut with
+// refs.origin pointing at the breakdown, so recalls from the carcass reach
+// every cut. Expected yields are copied onto the breakdown so variance can be
+// reported without the spec.
+func CreateButchery(b Butchery, resolve func(string) *Block) (Block, []Block, error) {
+	if b.Carcass == "" {
+		return Block{}, nil, errors.New("FoodBlock: butchery needs a carcass")
+	}
+	if b.Operator == "" {
+		return Block{}, nil, errors.New("FoodBlock: butchery needs an operator")
+	}
+	if len(b.Cuts) == 0 {
+		return Block{}, nil, errors.New("FoodBlock: butchery needs at least one cut")
+	}
+	if carcass := resolve(b.Carcass); carcass != nil {
+		if b.Species == "" {
+			b.Species = firstString(carcass.State, "animal", "species")
+		}
+		if b.CarcassWeight == 0 {
+			if q, unit, ok := blockMass(*carcass); ok {
+				b.CarcassWeight, _ = toKilograms(q, unit)
+			}
+		}
+	}
+	if b.CarcassWeight <= 0 {
+		return Block{}, nil, errors.New("FoodBlock: carcass weight must be positive")
+	}
+	var spec CuttingSpec
+	switch {
+	case b.Spec != nil:
+		spec = *b.Spec
+	default:
+		s, ok := CuttingSpecs[strings.ToLower(b.Species)]
+		if !ok {
+			return Block{}, nil, fmt.Errorf("FoodBlock: no cutting spec for species %q", b.Species)
+		}
+		spec = s
+	}
+	output := b.Trim
+	for cut, kg := range b.Cuts {
+		if kg <= 0 {
+			return Block{}, nil, fmt.Errorf("FoodBlock: cut %q weight must be positive", cut)
+		}
+		output += kg
+	}
+	if output > b.CarcassWeight {
+		return Block{}, nil, fmt.Errorf("FoodBlock: cuts and trim (%.3f kg) exceed the carcass weight (%.3f kg)", output, b.CarcassWeight)
+	}
+	if b.Date.IsZero() {
+		b.Date = time.Now()
+	}
+
+	cuts := map[string]interface{}{}
+	yields := map[string]interface{}{}
+	for cut, kg := range b.Cuts {
+		cuts[cut] = kg
+		yields[cut] = round3(kg / b.CarcassWeight * 100)
+	}
+	expected := map[string]interface{}{}
+	for _, c := range spec.Cuts {
+		expected[c.Cut] = c.ExpectedYield
+	}
+	event := Create(ButcheryType, map[string]interface{}{
+		"species":         spec.Species,
+		"spec":            spec.Name,
+		"tolerance":       spec.Tolerance,
+		"carcass_weight":  b.CarcassWeight,
+		"cuts":            cuts,
+		"yields":          yields,
+		"expected_yields": expected,
+		"trim":            b.Trim,
+		"loss":            round3(b.CarcassWeight - output),
+		"unit":            "kg",
+		"date":            b.Date.Format("2006-01-02"),
+	}, map[string]interface{}{"input": b.Carcass, "operator": b.Operator})
+
+	names := make([]string, 0, len(b.Cuts))
+	for cut := range b.Cuts {
+		names = append(names, cut)
+	}
+	sort.Strings(names)
+	products := make([]Block, 0, len(names))
+	for _, cut := range names {
+		state := map[string]interface{}{
+			"name":     strings.ReplaceAll(cut, "_", " "),
+			"cut":      cut,
+			"animal":   spec.Species,
+			"quantity": b.Cuts[cut],
+			"unit":     "kg",
+			"date":     b.Date.Format("2006-01-02"),
+		}
+		if c, ok := spec.cut(cut); ok {
+			state["primal"] = c.Primal
+		}
+		products = append(products, Create("substance.meat", state,
+			map[string]interface{}{"origin": event.Hash, "carcass": b.Carcass, "producer": b.Operator}))
+	}
+	return event, products, nil
+}
+
+// CarcassOf returns the carcass a cut came from, via refs.carcass or its
+// breakdown's refs.input, or "" when the cut is not from a breakdown.
+func CarcassOf(cut Block, resolve func(string) *Block) string {
+	if h, ok := cut.Refs["carcass"].(string); ok {
+		return h
+	}
+	origin, _ := cut.Refs["origin"].(string)
+	if event := resolve(origin); event != nil && event.Type == ButcheryType {
+		h, _ := event.Refs["input"].(string)
+		return h
+	}
+	return ""
+}
+
+// YieldVariance compares one cut's actual yield with its spec.
+type YieldVariance struct {
+	Cut      string  `json:"cut"`
+	Expected float64 `json:"expected"` // percent of carcass weight
+	Actual   float64 `json:"actual"`
+	Variance float64 `json:"variance"` // actual - expected, percentage points
+	Status   string  `json:"status"`   // ok, under, over or unspecified (cut not in the spec)
+}
+
+// ButcheryVariance reports each cut's yield against the spec recorded on a
+// transform.butchery block, spec cuts first in name order. Spec cuts that
+// were not produced are reported at 0%.
+func ButcheryVariance(event Block) ([]YieldVariance, error) {
+	if event.Type != ButcheryType {
+		return nil, fmt.Errorf("FoodBlock: %s is not a butchery block", event.Hash)
+	}
+	weight, _ := toFloat64(event.State["carcass_weight"])
+	cuts, _ := event.State["cuts"].(map[string]interface{})
+	actual := map[string]float64{}
+	for cut, v := range cuts {
+		kg, _ := toFloat64(v)
+		actual[cut] += kg
+	}
+	return yieldVariances(weight, actual, event.State), nil
+}
+
+func yieldVariances(weight float64, actual map[string]float64, state map[string]interface{}) []YieldVariance {
+	tolerance, _ := toFloat64(state["tolerance"])
+	expected, _ := state["expected_yields"].(map[string]interface{})
+	var out []YieldVariance
+	add := func(cut string, exp float64, specified bool) {
+		v := YieldVariance{Cut: cut, Expected: exp}
+		if weight > 0 {
+			v.Actual = round3(actual[cut] / weight * 100)
+		}
+		v.Variance = round3(v.Actual - v.Expected)
+		switch {
+		case !specified:
+			v.Status = "unspecified"
+		case math.Abs(v.Variance) <= tolerance:
+			v.Status = "ok"
+		case v.Variance < 0:
+			v.Status = "under"
+		default:
+			v.Status = "over"
+		}
+		out = append(out, v)
+	}
+	var specCuts, extra []string
+	for cut := range expected {
+		specCuts = append(specCuts, cut)
+	}
+	for cut := range actual {
+		if _, ok := expected[cut]; !ok {
+			extra = append(extra, cut)
+		}
+	}
+	sort.Strings(specCuts)
+	sort.Strings(extra)
+	for _, cut := range specCuts {
+		exp, _ := toFloat64(expected[cut])
+		add(cut, exp, true)
+	}
+	for _, cut := range extra {
+		add(cut, 0, false)
+	}
+	return out
+}
+
+// ButcheryMassBalance accounts for a carcass's weight across cuts, trim and
+// unexplained loss.
+type ButcheryMassBalance struct {
+	Carcass  string  `json:"carcass"`
+	InputKg  float64 `json:"input_kg"`
+	CutsKg   float64 `json:"cuts_kg"`
+	TrimKg   float64 `json:"trim_kg"`
+	WasteKg  float64 `json:"waste_kg"` // transform.waste recorded against the breakdown
+	LossKg   float64 `json:"loss_kg"`
+	LossPct  float64 `json:"loss_pct"`
+	Balanced bool    `json:"balanced"`
+}
+
+// MassBalanceOf balances a breakdown's carcass weight against its cuts,
+// trim and any transform.waste whose refs.source is the breakdown. It is
+// balanced when the remaining loss is within ButcheryLossTolerance.
+func MassBalanceOf(event Block, store BlockStore) (ButcheryMassBalance, error) {
+	if event.Type != ButcheryType {
+		return ButcheryMassBalance{}, fmt.Errorf("FoodBlock: %s is not a butchery block", event.Hash)
+	}
+	mb := ButcheryMassBalance{}
+	mb.Carcass, _ = event.Refs["input"].(string)
+	mb.InputKg, _ = toFloat64(event.State["carcass_weight"])
+	mb.TrimKg, _ = toFloat64(event.State["trim"])
+	cuts, _ := event.State["cuts"].(map[string]interface{})
+	for _, v := range cuts {
+		kg, _ := toFloat64(v)
+		mb.CutsKg += kg
+	}
+	for _, b := range store.ResolveForward(event.Hash) {
+		if b.Type == WasteType && b.Refs["source"] == event.Hash {
+			if q, unit, ok := blockMass(b); ok {
+				kg, _ := toKilograms(q, unit)
+				mb.WasteKg += kg
+			}
+		}
+	}
+	mb.CutsKg = round3(mb.CutsKg)
+	mb.WasteKg = round3(mb.WasteKg)
+	mb.LossKg = round3(mb.InputKg - mb.CutsKg - mb.TrimKg - mb.WasteKg)
+	if mb.InputKg > 0 {
+		mb.LossPct = round3(mb.LossKg / mb.InputKg * 100)
+	}
+	mb.Balanced = mb.LossKg >= 0 && mb.LossPct <= ButcheryLossTolerance
+	return mb, nil
+}
+
+// YieldReport aggregates breakdowns under one spec over a period.
+type YieldReport struct {
+	Spec      string          `json:"spec"`
+	From      string          `json:"from"`
+	To        string          `json:"to"`
+	Carcasses int             `json:"carcasses"`
+	InputKg   float64         `json:"input_kg"`
+	Cuts      []YieldVariance `json:"cuts"` // weighted by carcass weight
+	OffSpec   []string        `json:"off_spec"`
+}
+
+// ReportYields aggregates the transform.butchery blocks for a spec dated in
+// [from, to), comparing weighted average yields with the spec and listing the
+// breakdowns with any cut out of tolerance. Zero times leave the period open.
+func ReportYields(blocks []Block, spec string, from, to time.Time) YieldReport {
+	r := YieldReport{Spec: spec, Cuts: []YieldVariance{}, OffSpec: []string{}}
+	if !from.IsZero() {
+		r.From = from.Format("2006-01-02")
+	}
+	if !to.IsZero() {
+		r.To = to.Format("2006-01-02")
+	}
+	actual := map[string]float64{}
+	var state map[string]interface{}
+	for _, b := range blocks {
+		if b.Type != ButcheryType || b.State["spec"] != spec {
+			continue
+		}
+		if d, ok := BlockDate(b); ok && ((!from.IsZero() && d.Before(from)) || (!to.IsZero() && !d.Before(to))) {
+			continue
+		}
+		weight, _ := toFloat64(b.State["carcass_weight"])
+		r.Carcasses++
+		r.InputKg += weight
+		cuts, _ := b.State["cuts"].(map[string]interface{})
+		for cut, v := range cuts {
+			kg, _ := toFloat64(v)
+			actual[cut] += kg
+		}
+		state = b.State
+		variances, _ := ButcheryVariance(b)
+		for _, v := range variances {
+			if v.Status == "under" || v.Status == "over" {
+				r.OffSpec = append(r.OffSpec, b.Hash)
+				break
+			}
+		}
+	}
+	if state != nil {
+		r.Cuts = yieldVariances(r.InputKg, actual, state)
+	}
+	r.InputKg = round3(r.InputKg)
+	return r
+}
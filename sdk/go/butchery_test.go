@@ -0,0 +1,71 @@
+package foodblock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestButcheryYieldsAndTraceability(t *testing.T) {
+	day := time.Date(2026, 5, 12, 0, 0, 0, 0, time.UTC)
+	butcher := Create("actor.producer", map[string]interface{}{"name": "Top Field Butchery"}, nil)
+	carcass := Create("substance.meat", map[string]interface{}{"name": "Lamb carcass 118", "animal": "lamb", "weight": 20.0, "unit": "kg"}, nil)
+
+	event, cuts, err := CreateButchery(Butchery{
+		Carcass: carcass.Hash, Operator: butcher.Hash, Date: day, Trim: 3.5,
+		Cuts: map[string]float64{"leg": 6.2, "shoulder": 3.2, "rack": 1.6, "loin": 1.8, "neck": 0.8, "breast": 1.0, "kidneys": 0.3},
+	}, func(h string) *Block {
+		if h == carcass.Hash {
+			return &carcass
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cuts) != 7 || event.Refs["input"] != carcass.Hash || event.State["spec"] != "lamb_standard" {
+		t.Fatalf("unexpected breakdown %+v with %d cuts", event.State, len(cuts))
+	}
+
+	variances, _ := ButcheryVariance(event)
+	byCut := map[string]YieldVariance{}
+	for _, v := range variances {
+		byCut[v.Cut] = v
+	}
+	if v := byCut["shoulder"]; v.Actual != 16 || v.Variance != -4 || v.Status != "under" {
+		t.Errorf("expected shoulder 4 points under spec, got %+v", v)
+	}
+	if v := byCut["leg"]; v.Status != "ok" {
+		t.Errorf("expected leg within tolerance, got %+v", v)
+	}
+	if v := byCut["kidneys"]; v.Status != "unspecified" {
+		t.Errorf("expected kidneys outside the spec, got %+v", v)
+	}
+
+	waste, _ := CreateWaste(Waste{Source: event.Hash, Venue: butcher.Hash, Quantity: 0.4, Route: RouteAnimalFeed, Date: day})
+	store := NewMemoryStore()
+	store.PutAll(append([]Block{butcher, carcass, event, waste}, cuts...))
+
+	mb, _ := MassBalanceOf(event, store)
+	if mb.CutsKg != 14.9 || mb.WasteKg != 0.4 || mb.LossKg != 1.2 || mb.LossPct != 6 || mb.Balanced {
+		t.Errorf("expected 1.2kg unexplained loss to fail the balance, got %+v", mb)
+	}
+
+	recall := Recall(carcass.Hash, store.ResolveForward, 0, []string{"substance.*"}, nil)
+	if len(recall.Affected) != 7 {
+		t.Errorf("expected a carcass recall to reach every cut, got %d blocks", len(recall.Affected))
+	}
+	for _, c := range cuts {
+		if CarcassOf(c, store.Resolve) != carcass.Hash {
+			t.Errorf("cut %v does not trace back to the carcass", c.State["cut"])
+		}
+	}
+
+	report := ReportYields(store.Blocks(), "lamb_standard", day, day.AddDate(0, 0, 1))
+	if report.Carcasses != 1 || len(report.OffSpec) != 1 || report.InputKg != 20 {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	if _, _, err := CreateButchery(Butchery{Carcass: carcass.Hash, Operator: butcher.Hash, Species: "lamb", CarcassWeight: 5, Cuts: map[string]float64{"leg": 6}}, store.Resolve); err == nil {
+		t.Error("expected cuts heavier than the carcass to be rejected")
+	}
+}
@@ -0,0 +1,31 @@
+package foodblock
+
+import "fmt"
+
+// MarshalCanonicalJSON renders a block in canonical form: the bytes Hash
+// and Sign cover, with the hash added under "hash". Keys are sorted, so the
+// hash comes first; a block without a hash renders exactly as Canonical.
+// Logs, caches and external systems can compare the output byte for byte.
+// A hash that does not match the content is an error.
+func MarshalCanonicalJSON(b Block) ([]byte, error) {
+	if b.Hash == "" {
+		return []byte(Canonical(b.Type, b.State, b.Refs)), nil
+	}
+	if want := Hash(b.Type, b.State, b.Refs); b.Hash != want {
+		return nil, fmt.Errorf("FoodBlock: hash %s does not match content (expected %s)", b.Hash, want)
+	}
+	return []byte(stringify(map[string]interface{}{
+		"hash":  b.Hash,
+		"type":  b.Type,
+		"state": b.State,
+		"refs":  b.Refs,
+	}, false)), nil
+}
+
+// EqualCanonical reports whether two blocks have the same canonical
+// content, however their maps were built or decoded: key order, NFC
+// normalization, integer or float numbers and the order of ref arrays do
+// not matter. Hash fields are ignored.
+func EqualCanonical(a, b Block) bool {
+	return Canonical(a.Type, a.State, a.Refs) == Canonical(b.Type, b.State, b.Refs)
+}
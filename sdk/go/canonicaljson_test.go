@@ -0,0 +1,51 @@
+package foodblock
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestMarshalCanonicalJSON(t *testing.T) {
+	mill := Create("actor.producer", map[string]interface{}{"name": "Mill"}, nil)
+	b := Create("substance.product", map[string]interface{}{"name": "Café", "price": 4.0, "tags": []interface{}{"rye", "b"}},
+		map[string]interface{}{"seller": mill.Hash, "inputs": []interface{}{"b", "a"}})
+
+	data, err := MarshalCanonicalJSON(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"hash":"` + b.Hash + `","refs":{"inputs":["a","b"],"seller":"` + mill.Hash + `"},"state":{"name":"Café","price":4,"tags":["rye","b"]},"type":"substance.product"}`
+	if string(data) != want {
+		t.Errorf("got  %s\nwant %s", data, want)
+	}
+
+	// It decodes back to an equal block.
+	var back Block
+	if err := json.Unmarshal(data, &back); err != nil || back.Hash != b.Hash || !EqualCanonical(back, b) {
+		t.Errorf("round trip = %+v, %v", back, err)
+	}
+
+	unhashed := b
+	unhashed.Hash = ""
+	if data, _ := MarshalCanonicalJSON(unhashed); string(data) != Canonical(b.Type, b.State, b.Refs) {
+		t.Errorf("unhashed = %s", data)
+	}
+	forged := b
+	forged.State = map[string]interface{}{"name": "Rye"}
+	if _, err := MarshalCanonicalJSON(forged); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestEqualCanonical(t *testing.T) {
+	a := Block{Type: "substance.product", State: map[string]interface{}{"price": 4, "name": "Rye"}, Refs: map[string]interface{}{"inputs": []interface{}{"x", "y"}}}
+	b := Block{Type: "substance.product", State: map[string]interface{}{"name": "Rye", "price": 4.0}, Refs: map[string]interface{}{"inputs": []interface{}{"y", "x"}}, Hash: "stale"}
+	if !EqualCanonical(a, b) {
+		t.Error("expected key order, number form, ref order and hash to be ignored")
+	}
+	b.State["price"] = 4.5
+	if EqualCanonical(a, b) {
+		t.Error("expected different prices to differ")
+	}
+}
@@ -0,0 +1,248 @@
+package foodblock
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FAOSpeciesCodes maps the fishery vocabulary's species to FAO 3-alpha codes
+// used on catch certificates.
+var FAOSpeciesCodes = map[string]string{
+	"cod":      "COD",
+	"salmon":   "SAL",
+	"haddock":  "HAD",
+	"mackerel": "MAC",
+	"tuna":     "TUN",
+	"sea bass": "BSS",
+	"crab":     "CRE",
+	"lobster":  "LBE",
+	"prawns":   "PRA",
+	"oyster":   "OYF",
+	"mussels":  "MUS",
+}
+
+// CatchVessel is box 2 of the EU catch certificate.
+type CatchVessel struct {
+	Name              string `json:"name"`
+	FlagState         string `json:"flag_state"`
+	HomePort          string `json:"home_port,omitempty"`
+	Registration      string `json:"registration"`
+	CallSign          string `json:"call_sign,omitempty"`
+	IMONumber         string `json:"imo_number,omitempty"`
+	Licence           string `json:"licence"`
+	LicenceValidUntil string `json:"licence_valid_until,omitempty"`
+	Block             string `json:"block,omitempty"`
+}
+
+// CatchProduct is one line of box 3: the species caught and its weights.
+type CatchProduct struct {
+	Species        string  `json:"species"`
+	SpeciesCode    string  `json:"species_code,omitempty"` // FAO 3-alpha
+	ProductCode    string  `json:"product_code,omitempty"` // CN code
+	CatchArea      string  `json:"catch_area"`
+	CatchDate      string  `json:"catch_date"`
+	CatchMethod    string  `json:"catch_method"`
+	LiveWeightKg   float64 `json:"live_weight_kg"`
+	LandedWeightKg float64 `json:"landed_weight_kg,omitempty"`
+	VerifiedKg     float64 `json:"verified_weight_kg,omitempty"`
+}
+
+// CatchParty is an exporter or importer.
+type CatchParty struct {
+	Name    string `json:"name"`
+	Address string `json:"address,omitempty"`
+	Country string `json:"country,omitempty"`
+	Block   string `json:"block,omitempty"`
+}
+
+// CustodyStep is one transfer or processing step after landing (box 10 and
+// the processing statement).
+type CustodyStep struct {
+	Block   string `json:"block"`
+	Type    string `json:"type"`
+	Date    string `json:"date,omitempty"`
+	From    string `json:"from,omitempty"`
+	To      string `json:"to,omitempty"`
+	Carrier string `json:"carrier,omitempty"`
+	Mode    string `json:"mode,omitempty"` // transport mode for transfers
+	Detail  string `json:"detail,omitempty"`
+}
+
+// CatchCertificate is the data of an EU catch certificate (Regulation (EC)
+// No 1005/2008, Annex II) for one catch and its custody chain.
+type CatchCertificate struct {
+	DocumentNumber string         `json:"document_number"`
+	Catch          string         `json:"catch"`
+	Vessel         CatchVessel    `json:"vessel"`
+	Products       []CatchProduct `json:"products"`
+	LandingPort    string         `json:"landing_port"`
+	Master         string         `json:"master,omitempty"`
+	Exporter       CatchParty     `json:"exporter"`
+	Importer       CatchParty     `json:"importer"`
+	Custody        []CustodyStep  `json:"custody"`
+}
+
+// ExportCatchCertificate maps a substance.seafood catch to catch certificate
+// data. Vessel details come from the catch's fishery fields and, when present,
+// its refs.vessel (or refs.fishery / producer) actor. The custody chain is
+// every transfer and transform downstream of the catch in date order; the
+// exporter and importer are the seller and buyer of the last transfer.
+func ExportCatchCertificate(catchHash string, store BlockStore) (CatchCertificate, error) {
+	catch := store.Resolve(catchHash)
+	if catch == nil {
+		return CatchCertificate{}, fmt.Errorf("FoodBlock: catch not found: %s", catchHash)
+	}
+	if !strings.HasPrefix(catch.Type, "substance.") {
+		return CatchCertificate{}, fmt.Errorf("FoodBlock: %s is not a substance", catchHash)
+	}
+	c := CatchCertificate{DocumentNumber: "CC-" + catch.Hash[:12], Catch: catch.Hash, Custody: []CustodyStep{}}
+	str := func(b *Block, keys ...string) string {
+		if b == nil {
+			return ""
+		}
+		return firstString(b.State, keys...)
+	}
+
+	vesselBlock := store.Resolve(firstRef(catch.Refs, "vessel", "fishery", "producer"))
+	c.Vessel = CatchVessel{
+		Name:              firstNonEmpty(str(catch, "vessel"), str(vesselBlock, "vessel", "name")),
+		FlagState:         firstNonEmpty(str(catch, "flag_state"), str(vesselBlock, "flag_state", "flag")),
+		HomePort:          str(vesselBlock, "home_port"),
+		Registration:      firstNonEmpty(str(catch, "vessel_registration"), str(vesselBlock, "registration", "vessel_registration")),
+		CallSign:          str(vesselBlock, "call_sign"),
+		IMONumber:         str(vesselBlock, "imo_number", "imo"),
+		Licence:           firstNonEmpty(str(catch, "licence", "license"), str(vesselBlock, "licence", "license")),
+		LicenceValidUntil: str(vesselBlock, "licence_valid_until", "license_valid_until"),
+	}
+	if vesselBlock != nil {
+		c.Vessel.Block = vesselBlock.Hash
+	}
+	c.Master = str(catch, "master")
+	c.LandingPort = str(catch, "landing_port")
+
+	species := str(catch, "species")
+	product := CatchProduct{
+		Species:     species,
+		SpeciesCode: firstNonEmpty(str(catch, "species_code"), FAOSpeciesCodes[strings.ToLower(species)]),
+		ProductCode: str(catch, "product_code", "cn_code"),
+		CatchArea:   str(catch, "fishing_zone"),
+		CatchDate:   str(catch, "catch_date"),
+		CatchMethod: str(catch, "catch_method"),
+	}
+	if q, unit, ok := blockMass(*catch); ok {
+		product.LiveWeightKg, _ = toKilograms(q, unit)
+	}
+	for field, dst := range map[string]*float64{"landed_weight": &product.LandedWeightKg, "verified_weight": &product.VerifiedKg} {
+		if v, ok := numericValue(catch.State[field]); ok {
+			*dst = v
+		}
+	}
+	c.Products = []CatchProduct{product}
+
+	type step struct {
+		CustodyStep
+		at time.Time
+	}
+	var steps []step
+	for _, b := range Recall(catch.Hash, store.ResolveForward, 0, []string{"transfer.*", "transform.*", "substance.*"}, nil).Affected {
+		if strings.HasPrefix(b.Type, "substance.") {
+			continue
+		}
+		s := CustodyStep{Block: b.Hash, Type: b.Type}
+		at, _ := BlockDate(b)
+		if !at.IsZero() {
+			s.Date = at.Format("2006-01-02")
+		}
+		s.From = blockName(store, firstRef(b.Refs, "seller", "source", "from", "operator"))
+		s.To = blockName(store, firstRef(b.Refs, "buyer", "to", "destination"))
+		if carrier := firstRef(b.Refs, "carrier"); carrier != "" {
+			s.Carrier = blockName(store, carrier)
+		}
+		s.Mode = str(&b, "transport_mode", "mode", "vehicle_type")
+		s.Detail = str(&b, "name", "process_type")
+		steps = append(steps, step{s, at})
+	}
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].at.Before(steps[j].at) })
+	var last *Block
+	for _, s := range steps {
+		c.Custody = append(c.Custody, s.CustodyStep)
+		if strings.HasPrefix(s.Type, "transfer.") {
+			last = store.Resolve(s.Block)
+		}
+	}
+	if last != nil {
+		c.Exporter = catchParty(store, firstRef(last.Refs, "seller", "from"))
+		c.Importer = catchParty(store, firstRef(last.Refs, "buyer", "to"))
+	}
+	return c, nil
+}
+
+func catchParty(store BlockStore, hash string) CatchParty {
+	p := CatchParty{Block: hash}
+	if b := store.Resolve(hash); b != nil {
+		p.Name = firstString(b.State, "name")
+		p.Address = firstString(b.State, "address")
+		p.Country = firstString(b.State, "country")
+	}
+	return p
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// Validate lists what the certificate still lacks before it can be
+// submitted. An empty result means it is complete.
+func (c CatchCertificate) Validate() []string {
+	var errs []string
+	need := func(v, what string) {
+		if strings.TrimSpace(v) == "" {
+			errs = append(errs, "Missing "+what)
+		}
+	}
+	need(c.Vessel.Name, "vessel name")
+	need(c.Vessel.FlagState, "vessel flag state")
+	need(c.Vessel.Registration, "vessel registration number")
+	need(c.Vessel.Licence, "fishing licence number")
+	need(c.LandingPort, "landing port")
+	if len(c.Products) == 0 {
+		errs = append(errs, "Missing product description")
+	}
+	for i, p := range c.Products {
+		prefix := fmt.Sprintf("product %d ", i+1)
+		need(p.Species, prefix+"species")
+		need(p.CatchArea, prefix+"catch area (fishing_zone)")
+		need(p.CatchMethod, prefix+"catch method")
+		need(p.CatchDate, prefix+"catch date")
+		if p.CatchDate != "" {
+			if _, err := time.Parse("2006-01-02", p.CatchDate); err != nil {
+				errs = append(errs, fmt.Sprintf("Invalid %scatch date %q", prefix, p.CatchDate))
+			}
+		}
+		if p.LiveWeightKg <= 0 {
+			errs = append(errs, "Missing "+prefix+"estimated live weight")
+		}
+		if p.VerifiedKg > 0 && p.LandedWeightKg > 0 && p.VerifiedKg > p.LandedWeightKg*1.1 {
+			errs = append(errs, fmt.Sprintf("Verified weight of %sexceeds the estimated landed weight by more than 10%%", prefix))
+		}
+	}
+	need(c.Exporter.Name, "exporter")
+	need(c.Importer.Name, "importer")
+	hasTransport := false
+	for _, s := range c.Custody {
+		if strings.HasPrefix(s.Type, "transfer.") {
+			hasTransport = true
+		}
+	}
+	if !hasTransport {
+		errs = append(errs, "Missing transport details (no transfer after landing)")
+	}
+	return errs
+}
@@ -0,0 +1,60 @@
+package foodblock
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExportCatchCertificate(t *testing.T) {
+	vessel := Create("actor.fishery", map[string]interface{}{
+		"name": "Girl Rachel", "flag_state": "GB", "registration": "PZ 123", "licence": "GBR-LIC-0042", "call_sign": "MGRL4",
+	}, nil)
+	exporter := Create("actor.distributor", map[string]interface{}{"name": "Newlyn Seafoods", "country": "GB"}, nil)
+	importer := Create("actor.distributor", map[string]interface{}{"name": "Marée Lyon", "country": "FR"}, nil)
+	haulier := Create("actor.distributor", map[string]interface{}{"name": "Channel Reefers"}, nil)
+
+	catch := Create("substance.seafood", map[string]interface{}{
+		"name": "Line caught cod", "species": "Cod", "catch_method": "rod and line", "fishing_zone": "ICES VIIe",
+		"landing_port": "Newlyn", "catch_date": "2026-03-02", "quantity": 420.0, "unit": "kg", "landed_weight": 400.0,
+	}, map[string]interface{}{"vessel": vessel.Hash})
+	landing := Create("transfer.order", map[string]interface{}{"instance_id": "landing-1", "date": "2026-03-02", "status": "completed"},
+		map[string]interface{}{"seller": vessel.Hash, "buyer": exporter.Hash, "item": catch.Hash})
+	fillet := Create("transform.process", map[string]interface{}{"name": "Filleting", "date": "2026-03-03"},
+		map[string]interface{}{"input": catch.Hash, "operator": exporter.Hash})
+	export := Create("transfer.delivery", map[string]interface{}{"instance_id": "exp-1", "date": "2026-03-04", "transport_mode": "road"},
+		map[string]interface{}{"seller": exporter.Hash, "buyer": importer.Hash, "carrier": haulier.Hash, "item": fillet.Hash})
+
+	store := NewMemoryStore()
+	store.PutAll([]Block{vessel, exporter, importer, haulier, catch, landing, fillet, export})
+
+	cert, err := ExportCatchCertificate(catch.Hash, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Vessel.Name != "Girl Rachel" || cert.Vessel.Registration != "PZ 123" || cert.LandingPort != "Newlyn" {
+		t.Errorf("unexpected vessel section %+v", cert.Vessel)
+	}
+	p := cert.Products[0]
+	if p.SpeciesCode != "COD" || p.CatchArea != "ICES VIIe" || p.LiveWeightKg != 420 || p.LandedWeightKg != 400 {
+		t.Errorf("unexpected product line %+v", p)
+	}
+	if len(cert.Custody) != 3 || cert.Custody[1].Type != "transform.process" || cert.Custody[2].Carrier != "Channel Reefers" || cert.Custody[2].Mode != "road" {
+		t.Errorf("unexpected custody chain %+v", cert.Custody)
+	}
+	if cert.Exporter.Name != "Newlyn Seafoods" || cert.Importer.Country != "FR" {
+		t.Errorf("expected the export leg's parties, got %+v / %+v", cert.Exporter, cert.Importer)
+	}
+	if errs := cert.Validate(); len(errs) != 0 {
+		t.Errorf("expected a complete certificate, got %v", errs)
+	}
+
+	bare := Create("substance.seafood", map[string]interface{}{"name": "Mystery fish", "species": "mackerel"}, nil)
+	store.Put(bare)
+	cert, _ = ExportCatchCertificate(bare.Hash, store)
+	errs := strings.Join(cert.Validate(), "; ")
+	for _, want := range []string{"vessel name", "fishing licence", "catch area", "live weight", "exporter", "transport details"} {
+		if !strings.Contains(errs, want) {
+			t.Errorf("expected %q among %s", want, errs)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package foodblock
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CertBatchType is the summary block of an AttestBatch or CertifyBatch run.
+// Its refs.members lists every block issued in the batch and state records
+// the batch_id, kind, count, issued_at and the members' merkle_root.
+const CertBatchType = "observe.batch"
+
+// CertBatch is the outcome of a bulk attestation or certification. Members
+// are in target order and all carry state.batch_id and state.issued_at.
+type CertBatch struct {
+	Members  []Block
+	Summary  Block
+	Failures []BatchFailure // targets left out, for CertifyBatch
+}
+
+// BatchFailure is a target that could not be issued.
+type BatchFailure struct {
+	Target string
+	Error  string
+}
+
+// CertSubject is one producer in a CertifyBatch run with the state fields
+// that differ per subject, such as certificate_id.
+type CertSubject struct {
+	Subject string
+	State   map[string]interface{}
+}
+
+// AttestBatch attests every target as Attest would, stamping the blocks
+// with shared batch metadata and linking them from a summary block.
+func AttestBatch(targets []string, attestorHash, confidence, method string) (CertBatch, error) {
+	if attestorHash == "" {
+		return CertBatch{}, errors.New("FoodBlock: attestorHash is required")
+	}
+	if err := checkBatchTargets(targets); err != nil {
+		return CertBatch{}, err
+	}
+	issued := time.Now().UTC().Format(time.RFC3339)
+	id := batchID(attestorHash, issued, targets)
+	var members []Block
+	for _, target := range targets {
+		a, err := Attest(target, attestorHash, confidence, method)
+		if err != nil {
+			return CertBatch{}, err
+		}
+		members = append(members, Create(a.Type, withBatch(a.State, id, issued), a.Refs))
+	}
+	return CertBatch{Members: members, Summary: batchSummary("attestation", "", id, issued, "attestor", attestorHash, members)}, nil
+}
+
+// CertifyBatch certifies every subject under a scheme as
+// CreateCertification would, with shared state applied to all and each
+// subject's own state on top. Subjects whose certification fails the
+// scheme's rules are reported in Failures rather than failing the batch.
+func CertifyBatch(authority, scheme string, shared map[string]interface{}, subjects []CertSubject) (CertBatch, error) {
+	if authority == "" {
+		return CertBatch{}, errors.New("FoodBlock: authority is required")
+	}
+	if _, ok := CertSchemes[scheme]; !ok {
+		return CertBatch{}, fmt.Errorf("FoodBlock: unknown certification scheme: %s", scheme)
+	}
+	targets := make([]string, len(subjects))
+	for i, s := range subjects {
+		targets[i] = s.Subject
+	}
+	if err := checkBatchTargets(targets); err != nil {
+		return CertBatch{}, err
+	}
+	issued := time.Now().UTC().Format(time.RFC3339)
+	id := batchID(authority, issued, targets)
+	var batch CertBatch
+	for _, s := range subjects {
+		state := map[string]interface{}{}
+		for k, v := range shared {
+			state[k] = v
+		}
+		for k, v := range s.State {
+			state[k] = v
+		}
+		c, err := CreateCertification(s.Subject, authority, scheme, withBatch(state, id, issued))
+		if err != nil {
+			batch.Failures = append(batch.Failures, BatchFailure{Target: s.Subject, Error: strings.TrimPrefix(err.Error(), "FoodBlock: ")})
+			continue
+		}
+		batch.Members = append(batch.Members, c)
+	}
+	if len(batch.Members) == 0 {
+		return batch, fmt.Errorf("FoodBlock: no certification in the batch is valid: %s", batch.Failures[0].Error)
+	}
+	batch.Summary = batchSummary("certification", scheme, id, issued, "authority", authority, batch.Members)
+	return batch, nil
+}
+
+// Sign signs the members and the summary with the issuer's key in one pass,
+// summary last.
+func (b CertBatch) Sign(authorHash string, privateKey []byte) []SignedBlock {
+	signed := make([]SignedBlock, 0, len(b.Members)+1)
+	for _, m := range b.Members {
+		signed = append(signed, Sign(m, authorHash, privateKey))
+	}
+	return append(signed, Sign(b.Summary, authorHash, privateKey))
+}
+
+// AuditCertBatch checks a batch summary against the blocks it lists: every
+// member resolves, carries the batch's batch_id and issuer, and together
+// they give the recorded merkle_root.
+func AuditCertBatch(summary Block, resolve func(string) *Block) []string {
+	if summary.Type != CertBatchType {
+		return []string{"Not an " + CertBatchType + " block"}
+	}
+	id, _ := summary.State["batch_id"].(string)
+	role := "attestor"
+	if summary.State["kind"] == "certification" {
+		role = "authority"
+	}
+	issuer := firstRef(summary.Refs, role)
+	members := flattenRefValues(map[string]interface{}{"members": summary.Refs["members"]})
+	var issues []string
+	if n, _ := toFloat64(summary.State["count"]); int(n) != len(members) {
+		issues = append(issues, fmt.Sprintf("Summary counts %v members but lists %d", summary.State["count"], len(members)))
+	}
+	for _, h := range members {
+		m := resolve(h)
+		switch {
+		case m == nil:
+			issues = append(issues, "Member not found: "+h)
+		case m.State["batch_id"] != id:
+			issues = append(issues, "Member "+h+" belongs to another batch")
+		case firstRef(m.Refs, role) != issuer:
+			issues = append(issues, "Member "+h+" has another "+role)
+		}
+	}
+	if computeMerkleRoot(members) != summary.State["merkle_root"] {
+		issues = append(issues, "Members do not match the summary's merkle_root")
+	}
+	return issues
+}
+
+func checkBatchTargets(targets []string) error {
+	if len(targets) == 0 {
+		return errors.New("FoodBlock: a batch needs at least one target")
+	}
+	seen := map[string]bool{}
+	for _, t := range targets {
+		if t == "" {
+			return errors.New("FoodBlock: batch targets must not be empty")
+		}
+		if seen[t] {
+			return fmt.Errorf("FoodBlock: duplicate batch target: %s", t)
+		}
+		seen[t] = true
+	}
+	return nil
+}
+
+func batchID(issuer, issued string, targets []string) string {
+	return Sha256Hex(issuer + "\n" + issued + "\n" + strings.Join(targets, "\n"))[:16]
+}
+
+func withBatch(state map[string]interface{}, id, issued string) map[string]interface{} {
+	out := make(map[string]interface{}, len(state)+2)
+	for k, v := range state {
+		out[k] = v
+	}
+	out["batch_id"] = id
+	out["issued_at"] = issued
+	return out
+}
+
+func batchSummary(kind, scheme, id, issued, role, issuer string, members []Block) Block {
+	hashes := make([]string, len(members))
+	refs := make([]interface{}, len(members))
+	for i, m := range members {
+		hashes[i] = m.Hash
+		refs[i] = m.Hash
+	}
+	state := map[string]interface{}{
+		"batch_id":    id,
+		"kind":        kind,
+		"count":       len(members),
+		"issued_at":   issued,
+		"merkle_root": computeMerkleRoot(hashes),
+	}
+	if scheme != "" {
+		state["scheme"] = scheme
+	}
+	return Create(CertBatchType, state, map[string]interface{}{role: issuer, "members": refs})
+}
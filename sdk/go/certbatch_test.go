@@ -0,0 +1,86 @@
+package foodblock
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestAttestBatch(t *testing.T) {
+	pub, priv := GenerateKeypair()
+	authority := Create("actor.authority", map[string]interface{}{"name": "Soil Association"}, nil)
+	var targets []string
+	for i := 0; i < 5; i++ {
+		targets = append(targets, Create("actor.producer", map[string]interface{}{"name": fmt.Sprintf("Farm %d", i)}, nil).Hash)
+	}
+	batch, err := AttestBatch(targets, authority.Hash, "verified", "site_visit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.Members) != 5 || batch.Summary.State["count"] != 5 || batch.Summary.State["kind"] != "attestation" {
+		t.Fatalf("batch = %+v", batch)
+	}
+	id := batch.Summary.State["batch_id"]
+	for i, m := range batch.Members {
+		if m.Refs["confirms"] != targets[i] || m.State["batch_id"] != id || m.State["issued_at"] != batch.Summary.State["issued_at"] || m.State["method"] != "site_visit" {
+			t.Errorf("member %d = %+v", i, m)
+		}
+	}
+
+	signed := batch.Sign(authority.Hash, priv)
+	if len(signed) != 6 || signed[5].FoodBlock.Hash != batch.Summary.Hash {
+		t.Fatalf("signed %d blocks", len(signed))
+	}
+	store := NewMemoryStore()
+	for _, s := range signed {
+		if !Verify(s, pub) {
+			t.Fatalf("signature on %s does not verify", s.FoodBlock.Hash)
+		}
+		store.PutSigned(s)
+	}
+	if issues := AuditCertBatch(batch.Summary, store.Resolve); len(issues) != 0 {
+		t.Errorf("issues = %v", issues)
+	}
+
+	partial := NewMemoryStore()
+	partial.PutAll(batch.Members[1:])
+	if issues := AuditCertBatch(batch.Summary, partial.Resolve); len(issues) != 1 || !strings.Contains(issues[0], "not found") {
+		t.Errorf("missing member issues = %v", issues)
+	}
+
+	if _, err := AttestBatch([]string{targets[0], targets[0]}, authority.Hash, "", ""); err == nil {
+		t.Error("expected duplicate targets to fail")
+	}
+}
+
+func TestCertifyBatch(t *testing.T) {
+	authority := Create("actor.authority", map[string]interface{}{"name": "IFANCA"}, nil)
+	shared := map[string]interface{}{"valid_from": "2026-01-01", "valid_until": "2026-12-31"}
+	var subjects []CertSubject
+	for i := 0; i < 3; i++ {
+		subjects = append(subjects, CertSubject{
+			Subject: Create("actor.producer", map[string]interface{}{"name": fmt.Sprintf("Farm %d", i)}, nil).Hash,
+			State:   map[string]interface{}{"certificate_id": fmt.Sprintf("IF-%03d", i)},
+		})
+	}
+	subjects = append(subjects, CertSubject{Subject: Create("actor.producer", map[string]interface{}{"name": "No ID"}, nil).Hash})
+
+	batch, err := CertifyBatch(authority.Hash, "ifanca", shared, subjects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.Members) != 3 || len(batch.Failures) != 1 || batch.Failures[0].Target != subjects[3].Subject {
+		t.Fatalf("batch = %+v", batch)
+	}
+	if batch.Summary.State["scheme"] != "ifanca" || batch.Members[1].State["certificate_id"] != "IF-001" || batch.Members[1].State["valid_until"] != "2026-12-31" {
+		t.Errorf("summary = %v, member = %v", batch.Summary.State, batch.Members[1].State)
+	}
+	store := NewMemoryStore()
+	store.PutAll(append(batch.Members, batch.Summary))
+	if issues := AuditCertBatch(batch.Summary, store.Resolve); len(issues) != 0 {
+		t.Errorf("issues = %v", issues)
+	}
+	if _, err := CertifyBatch(authority.Hash, "ifanca", shared, subjects[3:]); err == nil {
+		t.Error("expected a batch without valid certifications to fail")
+	}
+}
@@ -0,0 +1,202 @@
+package foodblock
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// CertScheme describes one certification scheme. An observe.certification
+// names its scheme in state.scheme; the scheme then decides which state fields
+// are required, the format of the certificate identifier and how long a
+// certificate may be valid.
+type CertScheme struct {
+	ID               string
+	Name             string
+	Kind             string         // organic, sustainability, halal, ...
+	RequiredFields   []string       // state fields beyond name and scheme
+	IdentifierField  string         // default "certificate_id"
+	Identifier       *regexp.Regexp // nil accepts any non-empty identifier
+	MaxValidity      time.Duration  // longest valid_from..valid_until span; 0 is unlimited
+	AuthorityDomains []string       // domains whose proven actors may issue it
+}
+
+const certDay = 24 * time.Hour
+
+// CertSchemes is the scheme registry, keyed by scheme ID. RegisterCertScheme
+// adds or replaces entries.
+var CertSchemes = map[string]CertScheme{
+	"soil_association": {
+		ID: "soil_association", Name: "Soil Association Organic", Kind: "organic",
+		RequiredFields:   []string{"valid_until"},
+		Identifier:       regexp.MustCompile(`^[A-Z]{0,2}\d{3,6}$`),
+		MaxValidity:      548 * certDay,
+		AuthorityDomains: []string{"soilassociation.org"},
+	},
+	"usda_nop": {
+		ID: "usda_nop", Name: "USDA National Organic Program", Kind: "organic",
+		RequiredFields:   []string{"certifier", "valid_from"},
+		Identifier:       regexp.MustCompile(`^\d{10}$`),
+		AuthorityDomains: []string{"usda.gov", "ams.usda.gov"},
+	},
+	"eu_organic": {
+		ID: "eu_organic", Name: "EU Organic", Kind: "organic",
+		RequiredFields:   []string{"control_body_code", "valid_until"},
+		MaxValidity:      548 * certDay,
+		AuthorityDomains: []string{"ec.europa.eu"},
+	},
+	"msc": {
+		ID: "msc", Name: "Marine Stewardship Council Chain of Custody", Kind: "sustainability",
+		RequiredFields:   []string{"valid_until"},
+		Identifier:       regexp.MustCompile(`^MSC-C-\d{5}$`),
+		MaxValidity:      3 * 366 * certDay,
+		AuthorityDomains: []string{"msc.org"},
+	},
+	"hfa": {
+		ID: "hfa", Name: "Halal Food Authority", Kind: "halal",
+		RequiredFields:   []string{"valid_until"},
+		Identifier:       regexp.MustCompile(`^HFA[-/]?\d{3,6}$`),
+		MaxValidity:      366 * certDay,
+		AuthorityDomains: []string{"halalfoodauthority.com"},
+	},
+	"ifanca": {
+		ID: "ifanca", Name: "IFANCA Halal", Kind: "halal",
+		RequiredFields:   []string{"valid_until"},
+		MaxValidity:      366 * certDay,
+		AuthorityDomains: []string{"ifanca.org"},
+	},
+	"jakim": {
+		ID: "jakim", Name: "JAKIM Halal Malaysia", Kind: "halal",
+		RequiredFields:   []string{"valid_until"},
+		MaxValidity:      2 * 366 * certDay,
+		AuthorityDomains: []string{"halal.gov.my"},
+	},
+}
+
+// eu control body codes look like "GB-ORG-05" or "DE-ÖKO-001".
+var euControlBody = regexp.MustCompile(`^[A-Z]{2}-[A-ZÄÖÜ]{2,4}-\d{2,3}$`)
+
+// RegisterCertScheme adds or replaces a scheme in CertSchemes.
+func RegisterCertScheme(s CertScheme) error {
+	if s.ID == "" {
+		return errors.New("FoodBlock: scheme ID is required")
+	}
+	CertSchemes[s.ID] = s
+	return nil
+}
+
+// ValidateCertification checks an observe.certification block against its
+// scheme's rules. Certifications without state.scheme are not checked.
+// Expired certificates are valid blocks; expiry is left to trust scoring.
+func ValidateCertification(block Block) []string {
+	if block.Type != "observe.certification" {
+		return []string{"Not an observe.certification block"}
+	}
+	id, _ := block.State["scheme"].(string)
+	if id == "" {
+		return nil
+	}
+	scheme, ok := CertSchemes[id]
+	if !ok {
+		return []string{fmt.Sprintf("Unknown certification scheme: %s", id)}
+	}
+	var errs []string
+	for _, f := range scheme.RequiredFields {
+		if v, ok := block.State[f]; !ok || v == "" {
+			errs = append(errs, fmt.Sprintf("%s requires state.%s", scheme.Name, f))
+		}
+	}
+	field := scheme.IdentifierField
+	if field == "" {
+		field = "certificate_id"
+	}
+	ident, _ := block.State[field].(string)
+	switch {
+	case ident == "":
+		errs = append(errs, fmt.Sprintf("%s requires state.%s", scheme.Name, field))
+	case scheme.Identifier != nil && !scheme.Identifier.MatchString(ident):
+		errs = append(errs, fmt.Sprintf("Invalid %s identifier %q", scheme.Name, ident))
+	}
+	if code, ok := block.State["control_body_code"].(string); ok && id == "eu_organic" && !euControlBody.MatchString(code) {
+		errs = append(errs, fmt.Sprintf("Invalid EU control body code %q", code))
+	}
+	errs = append(errs, checkValidity(block, scheme)...)
+	return errs
+}
+
+func checkValidity(block Block, scheme CertScheme) []string {
+	var errs []string
+	from, okFrom, err := certDate(block, "valid_from")
+	if err != nil {
+		errs = append(errs, err.Error())
+	}
+	until, okUntil, err := certDate(block, "valid_until")
+	if err != nil {
+		errs = append(errs, err.Error())
+	}
+	if !okFrom || !okUntil {
+		return errs
+	}
+	if !until.After(from) {
+		errs = append(errs, "state.valid_until must be after state.valid_from")
+	} else if scheme.MaxValidity > 0 && until.Sub(from) > scheme.MaxValidity {
+		errs = append(errs, fmt.Sprintf("%s certificates are valid for at most %d days", scheme.Name, int(scheme.MaxValidity/certDay)))
+	}
+	return errs
+}
+
+func certDate(block Block, field string) (time.Time, bool, error) {
+	s, ok := block.State[field].(string)
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		t, err = time.Parse(time.RFC3339, s)
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("Invalid date in state.%s: %q", field, s)
+	}
+	return t, true, nil
+}
+
+// CreateCertification creates an observe.certification under a registered
+// scheme, returning the scheme's validation errors joined when invalid.
+func CreateCertification(subject, authority, scheme string, state map[string]interface{}) (Block, error) {
+	if subject == "" || authority == "" {
+		return Block{}, errors.New("FoodBlock: subject and authority are required")
+	}
+	s, ok := CertSchemes[scheme]
+	if !ok {
+		return Block{}, fmt.Errorf("FoodBlock: unknown certification scheme: %s", scheme)
+	}
+	merged := map[string]interface{}{"name": s.Name}
+	for k, v := range state {
+		merged[k] = v
+	}
+	merged["scheme"] = scheme
+	block := Create("observe.certification", merged, map[string]interface{}{"subject": subject, "authority": authority})
+	if errs := ValidateCertification(block); len(errs) > 0 {
+		return Block{}, errors.New("FoodBlock: invalid certification: " + strings.Join(errs, "; "))
+	}
+	return block, nil
+}
+
+// checkCertification rejects certifications that break their registered
+// scheme's rules. Unregistered schemes are accepted so blocks from peers with
+// other registries still sync.
+func checkCertification(block Block) error {
+	if block.Type != "observe.certification" {
+		return nil
+	}
+	id, _ := block.State["scheme"].(string)
+	if _, ok := CertSchemes[id]; !ok {
+		return nil
+	}
+	if errs := ValidateCertification(block); len(errs) > 0 {
+		return errors.New("FoodBlock: invalid certification: " + strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package foodblock
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCreateCertificationSchemes(t *testing.T) {
+	cert, err := CreateCertification("farm", "soil-assoc", "soil_association", map[string]interface{}{
+		"certificate_id": "P4821", "valid_from": "2026-01-01", "valid_until": "2027-01-01",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.State["name"] != "Soil Association Organic" || cert.State["scheme"] != "soil_association" {
+		t.Errorf("unexpected certification state %v", cert.State)
+	}
+
+	cases := []struct {
+		name   string
+		scheme string
+		state  map[string]interface{}
+		want   string
+	}{
+		{"bad identifier", "msc", map[string]interface{}{"certificate_id": "MSC-12", "valid_until": "2027-01-01"}, "identifier"},
+		{"missing field", "usda_nop", map[string]interface{}{"certificate_id": "8150001234"}, "state.certifier"},
+		{"too long", "hfa", map[string]interface{}{"certificate_id": "HFA-1234", "valid_from": "2026-01-01", "valid_until": "2028-06-01"}, "at most 366 days"},
+		{"reversed", "ifanca", map[string]interface{}{"certificate_id": "IF1", "valid_from": "2026-05-01", "valid_until": "2026-01-01"}, "after state.valid_from"},
+		{"bad control body", "eu_organic", map[string]interface{}{"certificate_id": "X1", "control_body_code": "organic", "valid_until": "2027-01-01"}, "control body"},
+	}
+	for _, c := range cases {
+		_, err := CreateCertification("farm", "body", c.scheme, c.state)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: expected error containing %q, got %v", c.name, c.want, err)
+		}
+	}
+	if _, err := CreateCertification("farm", "body", "nope", nil); err == nil {
+		t.Error("expected unknown scheme error")
+	}
+	if _, err := CreateCertification("farm", "body", "eu_organic", map[string]interface{}{
+		"certificate_id": "X1", "control_body_code": "GB-ORG-05", "valid_until": "2027-01-01",
+	}); err != nil {
+		t.Errorf("valid EU certificate rejected: %v", err)
+	}
+}
+
+func TestStoreValidatesCertifications(t *testing.T) {
+	store := NewMemoryStore()
+	bad := Create("observe.certification", map[string]interface{}{"name": "MSC", "scheme": "msc", "certificate_id": "nope"},
+		map[string]interface{}{"subject": "boat"})
+	if err := store.Put(bad); err == nil {
+		t.Error("store should reject certifications that break their scheme")
+	}
+	foreign := Create("observe.certification", map[string]interface{}{"name": "Local label", "scheme": "other_registry"}, nil)
+	plain := Create("observe.certification", map[string]interface{}{"name": "Organic"}, nil)
+	if err := store.PutAll([]Block{foreign, plain}); err != nil {
+		t.Errorf("unregistered and scheme-less certifications should be accepted: %v", err)
+	}
+	if errs := ValidateCertification(foreign); len(errs) != 1 {
+		t.Errorf("expected unknown scheme to be reported, got %v", errs)
+	}
+
+	RegisterCertScheme(CertScheme{ID: "test_scheme", Name: "Test", RequiredFields: []string{"grade"}})
+	defer delete(CertSchemes, "test_scheme")
+	custom := Create("observe.certification", map[string]interface{}{"name": "T", "scheme": "test_scheme", "certificate_id": "1"}, nil)
+	if errs := ValidateCertification(custom); len(errs) != 1 || !strings.Contains(errs[0], "state.grade") {
+		t.Errorf("expected registered scheme rules to apply, got %v", errs)
+	}
+}
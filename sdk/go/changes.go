@@ -0,0 +1,226 @@
+package foodblock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type change struct {
+	seq  int
+	hash string
+}
+
+// Change is one entry in a store's change feed. Signed is the block's signed
+// wrapper when the store keeps one; stubs never carry one.
+type Change struct {
+	Seq    int          `json:"seq"`
+	Block  Block        `json:"block"`
+	Signed *SignedBlock `json:"signed,omitempty"`
+}
+
+// ChangePage is a page of the change feed. Pass LastSeq as since to continue.
+type ChangePage struct {
+	Changes []Change `json:"changes"`
+	LastSeq int      `json:"last_seq"`
+	HasMore bool     `json:"has_more"`
+}
+
+// ChangeFeed is implemented by stores that assign sequence numbers.
+type ChangeFeed interface {
+	Changes(since, limit int) ChangePage
+	LastSeq() int
+}
+
+// recordChange appends the current sequence number for hash. Callers hold the lock.
+func (s *MemoryStore) recordChange(hash string) {
+	s.changes = append(s.changes, change{seq: s.seq, hash: hash})
+	s.latest[hash] = s.seq
+}
+
+// LastSeq returns the highest sequence number assigned so far.
+func (s *MemoryStore) LastSeq() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.seq
+}
+
+// Changes returns blocks changed after sequence number since, oldest first.
+// Each block appears once, at its most recent change; stubbing a block
+// (erasure, pruning, archival) moves it to the end of the feed so peers learn
+// about the stub.
+func (s *MemoryStore) Changes(since, limit int) ChangePage {
+	limit = ClampLimit(limit)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	page := ChangePage{Changes: []Change{}, LastSeq: since}
+	start := sort.Search(len(s.changes), func(i int) bool { return s.changes[i].seq > since })
+	for _, c := range s.changes[start:] {
+		if s.latest[c.hash] != c.seq {
+			continue // superseded by a later change, or deleted
+		}
+		if len(page.Changes) == limit {
+			page.HasMore = true
+			break
+		}
+		ch := Change{Seq: c.seq, Block: s.blocks[c.hash]}
+		if w, ok := s.signedOf(c.hash); ok {
+			ch.Signed = &w
+		}
+		page.Changes = append(page.Changes, ch)
+		page.LastSeq = c.seq
+	}
+	if !page.HasMore && s.seq > page.LastSeq {
+		page.LastSeq = s.seq
+	}
+	return page
+}
+
+// changesHandler serves GET /changes?since=N&limit=M.
+func changesHandler(feed ChangeFeed) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		since := 0
+		if v := q.Get("since"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				writeError(w, http.StatusBadRequest, "since must be a non-negative integer")
+				return
+			}
+			since = n
+		}
+		writeJSON(w, http.StatusOK, feed.Changes(since, atoi(q.Get("limit"))))
+	}
+}
+
+// FederationClient talks to a peer's federation API. Pull verifies signed
+// wrappers against Keys; without it, pulled blocks are stored unsigned.
+type FederationClient struct {
+	BaseURL string
+	HTTP    *http.Client
+	Keys    *KeyRegistry
+}
+
+// NewFederationClient creates a client for a peer base URL.
+func NewFederationClient(baseURL string) *FederationClient {
+	return &FederationClient{BaseURL: strings.TrimRight(baseURL, "/"), HTTP: http.DefaultClient}
+}
+
+// Changes fetches one page of the peer's change feed.
+func (c *FederationClient) Changes(ctx context.Context, since, limit int) (ChangePage, error) {
+	var page ChangePage
+	u := fmt.Sprintf("%s/changes?since=%d", c.BaseURL, since)
+	if limit > 0 {
+		u += "&limit=" + strconv.Itoa(limit)
+	}
+	err := c.getJSON(ctx, u, &page)
+	return page, err
+}
+
+// Block fetches a single block by hash.
+func (c *FederationClient) Block(ctx context.Context, hash string) (*Block, error) {
+	var b Block
+	if err := c.getJSON(ctx, c.BaseURL+"/blocks/"+url.PathEscape(hash), &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (c *FederationClient) getJSON(ctx context.Context, u string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var body struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		return fmt.Errorf("FoodBlock: peer returned %d: %s", resp.StatusCode, body.Error)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// Pull applies every change after since to the local store and returns the
+// sequence number to resume from. Blocks whose signed wrapper verifies
+// against c.Keys are stored with their signer. Erasures propagate through
+// tombstone blocks: a local copy is stubbed only once a tombstone with
+// erasure authority (see Compact) is held locally, and never while it is
+// pinned. A tombstone from the peer without that authority is stored but
+// erases nothing. Stubs from the peer are not stored; they carry no content
+// that can be verified, so they only confirm an erasure the local
+// tombstones already allow.
+func (c *FederationClient) Pull(ctx context.Context, local BlockStore, since int) (int, error) {
+	for {
+		page, err := c.Changes(ctx, since, MaxListLimit)
+		if err != nil {
+			return since, err
+		}
+		for _, ch := range page.Changes {
+			if err := applyChange(local, ch, c.Keys); err != nil {
+				return since, err
+			}
+			since = ch.Seq
+		}
+		if !page.HasMore {
+			if page.LastSeq > since {
+				since = page.LastSeq
+			}
+			return since, nil
+		}
+	}
+}
+
+func applyChange(local BlockStore, ch Change, keys *KeyRegistry) error {
+	b := ch.Block
+	if IsStub(b) {
+		if erased, _ := b.State["tombstoned"].(bool); erased {
+			eraseTombstoned(local, b.Hash)
+		}
+		return nil
+	}
+	ps, canSign := local.(interface{ PutSigned(SignedBlock) error })
+	var err error
+	if w := ch.Signed; w != nil && canSign && keys != nil && w.FoodBlock.Hash == b.Hash && keys.VerifySigned(*w) {
+		err = ps.PutSigned(*w)
+	} else {
+		err = local.Put(b)
+	}
+	if err != nil {
+		return err
+	}
+	if b.Type == "observe.tombstone" {
+		eraseTombstoned(local, firstRef(b.Refs, "target"))
+	}
+	return nil
+}
+
+// eraseTombstoned stubs the local copy of hash when a tombstone with erasure
+// authority for it is held locally and no pin holds it.
+func eraseTombstoned(local BlockStore, hash string) {
+	stubs, ok := local.(StubStore)
+	if !ok || hash == "" {
+		return
+	}
+	if t := local.Resolve(hash); t == nil || IsStub(*t) {
+		return
+	}
+	if !isTombstoned(hash, local) || IsPinned(hash, local) {
+		return
+	}
+	stubs.Stub(hash, map[string]interface{}{"tombstoned": true})
+}
@@ -0,0 +1,117 @@
+package foodblock
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestChangeFeedIncludesStubs(t *testing.T) {
+	store := NewMemoryStore()
+	populate(store, "p", 5)
+	first := store.Changes(0, 2)
+	if len(first.Changes) != 2 || !first.HasMore || first.LastSeq != 2 {
+		t.Fatalf("first page = %+v", first)
+	}
+	rest := store.Changes(first.LastSeq, 0)
+	if len(rest.Changes) != 3 || rest.HasMore || rest.LastSeq != 5 {
+		t.Fatalf("rest = %+v", rest)
+	}
+
+	victim := rest.Changes[0].Block.Hash
+	store.Stub(victim, map[string]interface{}{"tombstoned": true})
+	after := store.Changes(rest.LastSeq, 0)
+	if len(after.Changes) != 1 || after.Changes[0].Block.Hash != victim || !IsStub(after.Changes[0].Block) {
+		t.Fatalf("stub not in feed: %+v", after)
+	}
+	full := store.Changes(0, 0)
+	if len(full.Changes) != 5 {
+		t.Errorf("full feed lists %d blocks, want each block once", len(full.Changes))
+	}
+
+	store.Delete(victim)
+	if got := store.Changes(0, 0); len(got.Changes) != 4 {
+		t.Errorf("deleted block still in feed")
+	}
+}
+
+func TestFederationClientPull(t *testing.T) {
+	remote := NewMemoryStore()
+	populate(remote, "p", 7)
+	srv := httptest.NewServer(FederationHandler(remote, WellKnownInfo{}))
+	defer srv.Close()
+
+	ownerPub, ownerKey := GenerateKeypair()
+	malloryPub, malloryKey := GenerateKeypair()
+	keys := NewKeyRegistry()
+	keys.Register("owner", ownerPub)
+	keys.Register("mallory", malloryPub)
+	owned := Create("observe.review", map[string]interface{}{"text": "personal data"}, nil)
+	remote.PutSigned(Sign(owned, "owner", ownerKey))
+
+	local := NewMemoryStore()
+	client := NewFederationClient(srv.URL)
+	client.Keys = keys
+	since, err := client.Pull(context.Background(), local, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if local.Len() != 8 || since != remote.LastSeq() || local.SignerOf(owned.Hash) != "owner" {
+		t.Fatalf("pulled %d blocks, since %d", local.Len(), since)
+	}
+
+	// A bare erasure stub from the peer erases nothing.
+	rogue := remote.Blocks()[4].Hash
+	remote.Stub(rogue, map[string]interface{}{"tombstoned": true})
+	since, err = client.Pull(context.Background(), local, since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if IsStub(*local.Resolve(rogue)) {
+		t.Error("peer stub erased local content without a tombstone")
+	}
+
+	// Unsigned and forged tombstones are stored but erase nothing.
+	forged := Tombstone(owned.Hash, "mallory")
+	remote.Put(Tombstone(rogue, "subject"))
+	remote.PutSigned(Sign(forged, "mallory", malloryKey))
+	remote.Stub(rogue, map[string]interface{}{"tombstoned": true})
+	since, err = client.Pull(context.Background(), local, since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if IsStub(*local.Resolve(rogue)) || IsStub(*local.Resolve(owned.Hash)) || local.Resolve(forged.Hash) == nil {
+		t.Error("a tombstone without erasure authority erased local content")
+	}
+
+	victim := owned.Hash
+	remote.PutSigned(Sign(Tombstone(victim, "owner"), "owner", ownerKey))
+	remote.Stub(victim, map[string]interface{}{"tombstoned": true})
+	populate(remote, "new", 2)
+	since, err = client.Pull(context.Background(), local, since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if local.Len() != 13 || !IsStub(*local.Resolve(victim)) {
+		t.Errorf("incremental pull: len %d, stub propagated %v", local.Len(), IsStub(*local.Resolve(victim)))
+	}
+	if since != remote.LastSeq() {
+		t.Errorf("since = %d, want %d", since, remote.LastSeq())
+	}
+
+	// Archival and pruning on the peer leave local content alone.
+	kept := remote.Blocks()[1].Hash
+	remote.Stub(kept, map[string]interface{}{"archived": true})
+	remote.Stub(remote.Blocks()[2].Hash, map[string]interface{}{"pruned": true})
+	if since, err = client.Pull(context.Background(), local, since); err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range local.Blocks() {
+		if IsStub(b) && b.Hash != victim {
+			t.Errorf("non-erasure stub propagated over %s: %v", b.Hash, b.State)
+		}
+	}
+	if _, err := client.Changes(context.Background(), -1, 0); err == nil {
+		t.Error("expected negative since to be rejected")
+	}
+}
@@ -0,0 +1,205 @@
+package foodblock
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is a hybrid logical clock timestamp: wall-clock milliseconds, a
+// counter ordering events within the same millisecond, and the node that
+// issued it. A Lamport clock is a Clock with Wall left at zero.
+//
+// Clocks travel in the SignedBlock wrapper and are maintained by stores. They
+// are not covered by the signature, so they order blocks but do not prove
+// when a block was made.
+type Clock struct {
+	Wall    int64  `json:"wall"`
+	Counter int    `json:"counter"`
+	Node    string `json:"node,omitempty"`
+}
+
+// Compare orders clocks by wall time, then counter, then node. It returns -1,
+// 0 or 1.
+func (c Clock) Compare(o Clock) int {
+	switch {
+	case c.Wall != o.Wall:
+		return cmpInt64(c.Wall, o.Wall)
+	case c.Counter != o.Counter:
+		return cmpInt64(int64(c.Counter), int64(o.Counter))
+	case c.Node < o.Node:
+		return -1
+	case c.Node > o.Node:
+		return 1
+	}
+	return 0
+}
+
+// IsZero reports whether c is unset.
+func (c Clock) IsZero() bool {
+	return c == Clock{}
+}
+
+// String renders c as wall.counter@node.
+func (c Clock) String() string {
+	return fmt.Sprintf("%d.%d@%s", c.Wall, c.Counter, c.Node)
+}
+
+func cmpInt64(a, b int64) int {
+	if a < b {
+		return -1
+	}
+	return 1
+}
+
+// HLC issues hybrid logical clock timestamps for one node. It is safe for
+// concurrent use.
+type HLC struct {
+	mu   sync.Mutex
+	node string
+	last Clock
+	now  func() time.Time
+}
+
+// NewHLC creates a clock for a node. The node name breaks ties between peers
+// and should be unique among them, e.g. the actor hash of the store's owner.
+func NewHLC(node string) *HLC {
+	return &HLC{node: node, now: time.Now}
+}
+
+// Now returns a timestamp for a local event, later than every timestamp this
+// clock has issued or observed.
+func (h *HLC) Now() Clock {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	wall := h.now().UnixMilli()
+	if wall > h.last.Wall {
+		h.last = Clock{Wall: wall, Node: h.node}
+	} else {
+		h.last = Clock{Wall: h.last.Wall, Counter: h.last.Counter + 1, Node: h.node}
+	}
+	return h.last
+}
+
+// Observe merges a timestamp received from a peer, so the next local event
+// orders after it, and returns the clock's new timestamp. A remote wall time
+// ahead of the local clock is adopted rather than rejected; the counter keeps
+// the result unique.
+func (h *HLC) Observe(remote Clock) Clock {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	wall := h.now().UnixMilli()
+	next := Clock{Wall: wall, Node: h.node}
+	switch {
+	case wall > h.last.Wall && wall > remote.Wall:
+	case h.last.Wall == remote.Wall:
+		next.Wall = remote.Wall
+		next.Counter = max(h.last.Counter, remote.Counter) + 1
+	case h.last.Wall > remote.Wall:
+		next.Wall = h.last.Wall
+		next.Counter = h.last.Counter + 1
+	default:
+		next.Wall = remote.Wall
+		next.Counter = remote.Counter + 1
+	}
+	h.last = next
+	return next
+}
+
+// Last returns the latest timestamp issued or observed.
+func (h *HLC) Last() Clock {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.last
+}
+
+// ClockIndex is implemented by stores that keep a Clock for every block.
+type ClockIndex interface {
+	ClockOf(hash string) (Clock, bool)
+}
+
+// Order returns blocks in a deterministic causal order: every block comes
+// after the blocks in the set it references, and blocks that are otherwise
+// unordered are sorted by clock, then hash. clockOf may be nil or a store's
+// ClockOf; blocks without a clock sort before clocked ones. The result is the
+// same on every peer holding the same blocks and clocks, so projections and
+// audit exports replay identically.
+func Order(blocks []Block, clockOf func(string) (Clock, bool)) []Block {
+	byHash := make(map[string]Block, len(blocks))
+	for _, b := range blocks {
+		byHash[b.Hash] = b
+	}
+	pending := make(map[string]int, len(byHash))
+	dependents := make(map[string][]string)
+	for h, b := range byHash {
+		for _, ref := range uniqueRefValues(b.Refs) {
+			if _, ok := byHash[ref]; ok && ref != h {
+				pending[h]++
+				dependents[ref] = append(dependents[ref], h)
+			}
+		}
+	}
+
+	q := &orderQueue{clocks: make(map[string]Clock, len(byHash))}
+	for h := range byHash {
+		if clockOf != nil {
+			if c, ok := clockOf(h); ok {
+				q.clocks[h] = c
+			}
+		}
+		if pending[h] == 0 {
+			q.hashes = append(q.hashes, h)
+		}
+	}
+	heap.Init(q)
+	out := make([]Block, 0, len(byHash))
+	for q.Len() > 0 {
+		h := heap.Pop(q).(string)
+		out = append(out, byHash[h])
+		for _, d := range dependents[h] {
+			if pending[d]--; pending[d] == 0 {
+				heap.Push(q, d)
+			}
+		}
+	}
+	// Hash cycles cannot be built honestly; order any leftovers by hash.
+	if len(out) < len(byHash) {
+		var rest []string
+		for h := range byHash {
+			if pending[h] > 0 {
+				rest = append(rest, h)
+			}
+		}
+		sort.Slice(rest, func(i, j int) bool { return q.less(rest[i], rest[j]) })
+		for _, h := range rest {
+			out = append(out, byHash[h])
+		}
+	}
+	return out
+}
+
+// orderQueue is a min-heap of hashes by clock, then hash.
+type orderQueue struct {
+	hashes []string
+	clocks map[string]Clock
+}
+
+func (q *orderQueue) less(a, b string) bool {
+	if c := q.clocks[a].Compare(q.clocks[b]); c != 0 {
+		return c < 0
+	}
+	return a < b
+}
+
+func (q *orderQueue) Len() int           { return len(q.hashes) }
+func (q *orderQueue) Less(i, j int) bool { return q.less(q.hashes[i], q.hashes[j]) }
+func (q *orderQueue) Swap(i, j int)      { q.hashes[i], q.hashes[j] = q.hashes[j], q.hashes[i] }
+func (q *orderQueue) Push(x interface{}) { q.hashes = append(q.hashes, x.(string)) }
+func (q *orderQueue) Pop() interface{} {
+	old := q.hashes
+	h := old[len(old)-1]
+	q.hashes = old[:len(old)-1]
+	return h
+}
@@ -0,0 +1,97 @@
+package foodblock
+
+import (
+	"testing"
+	"time"
+)
+
+func fixedHLC(node string, now *time.Time) *HLC {
+	h := NewHLC(node)
+	h.now = func() time.Time { return *now }
+	return h
+}
+
+func TestHLC(t *testing.T) {
+	now := time.UnixMilli(1000)
+	h := fixedHLC("a", &now)
+	first, second := h.Now(), h.Now()
+	if first != (Clock{Wall: 1000, Node: "a"}) || second != (Clock{Wall: 1000, Counter: 1, Node: "a"}) {
+		t.Errorf("unexpected clocks %v %v", first, second)
+	}
+
+	// A peer ahead of us is adopted; the next local event follows it.
+	got := h.Observe(Clock{Wall: 5000, Counter: 3, Node: "b"})
+	if got != (Clock{Wall: 5000, Counter: 4, Node: "a"}) {
+		t.Errorf("observe ahead = %v", got)
+	}
+	if next := h.Now(); next.Compare(got) <= 0 {
+		t.Errorf("expected %v after %v", next, got)
+	}
+
+	// A peer behind us leaves the wall time alone.
+	got = h.Observe(Clock{Wall: 10, Node: "c"})
+	if got.Wall != 5000 || got.Counter != 6 {
+		t.Errorf("observe behind = %v", got)
+	}
+
+	// Once physical time passes the merged clock the counter resets.
+	now = time.UnixMilli(6000)
+	if got := h.Now(); got != (Clock{Wall: 6000, Node: "a"}) {
+		t.Errorf("after time passes = %v", got)
+	}
+}
+
+func TestStoreClocksAndOrder(t *testing.T) {
+	now := time.UnixMilli(2000)
+	peer := NewMemoryStore()
+	peer.UseClock(fixedHLC("peer", &now))
+	local := NewMemoryStore()
+	local.UseClock(fixedHLC("local", &now))
+
+	farm := Create("actor.producer", map[string]interface{}{"name": "Green Acres"}, nil)
+	wheat := Create("substance.ingredient", map[string]interface{}{"name": "Wheat"}, map[string]interface{}{"source": farm.Hash})
+	peer.PutAll([]Block{farm, wheat})
+
+	// The peer's clocks travel in the wrapper and are kept on ingest.
+	for _, b := range []Block{farm, wheat} {
+		signed := peer.Stamp(SignedBlock{FoodBlock: b, AuthorHash: "peer"})
+		if signed.Clock == nil {
+			t.Fatal("expected Stamp to set a clock")
+		}
+		if err := local.PutSigned(signed); err != nil {
+			t.Fatal(err)
+		}
+		if c, _ := local.ClockOf(b.Hash); c != *signed.Clock {
+			t.Errorf("expected peer clock %v, got %v", *signed.Clock, c)
+		}
+	}
+	flour := Create("substance.ingredient", map[string]interface{}{"name": "Flour"}, map[string]interface{}{"source": wheat.Hash})
+	local.Put(flour)
+	fc, _ := local.ClockOf(flour.Hash)
+	wc, _ := local.ClockOf(wheat.Hash)
+	if fc.Compare(wc) <= 0 {
+		t.Errorf("local block %v should order after ingested %v", fc, wc)
+	}
+
+	// Refs win over clocks, and the order does not depend on input order.
+	skewed := func(h string) (Clock, bool) {
+		if h == farm.Hash {
+			return Clock{Wall: 9999}, true
+		}
+		return local.ClockOf(h)
+	}
+	bread := Create("substance.product", map[string]interface{}{"name": "Bread"}, nil)
+	local.Put(bread)
+	for _, in := range [][]Block{{flour, bread, wheat, farm}, {farm, wheat, bread, flour}} {
+		got := Order(in, skewed)
+		want := []string{bread.Hash, farm.Hash, wheat.Hash, flour.Hash}
+		for i := range want {
+			if got[i].Hash != want[i] {
+				t.Fatalf("order %d = %s, want %s", i, got[i].State["name"], local.Resolve(want[i]).State["name"])
+			}
+		}
+	}
+	if got := Order([]Block{wheat, farm}, nil); got[0].Hash != farm.Hash {
+		t.Error("expected refs to order blocks without clocks")
+	}
+}
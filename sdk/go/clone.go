@@ -0,0 +1,138 @@
+package foodblock
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CloneOverrides are the changes a clone makes to the original. Entries
+// replace the original's; a nil value removes the field or ref.
+type CloneOverrides struct {
+	State map[string]interface{}
+	Refs  map[string]interface{}
+}
+
+// CloneBlock creates a new block from an existing one, such as this week's
+// listing of last week's product. The clone is not an update: refs.updates
+// is dropped, and an event's instance_id is replaced with a fresh one unless
+// the overrides set it.
+func CloneBlock(block Block, overrides CloneOverrides) Block {
+	state := make(map[string]interface{}, len(block.State))
+	for k, v := range block.State {
+		if k != "instance_id" {
+			state[k] = v
+		}
+	}
+	for k, v := range overrides.State {
+		state[k] = v
+	}
+	refs := make(map[string]interface{}, len(block.Refs))
+	for k, v := range block.Refs {
+		if k != "updates" {
+			refs[k] = v
+		}
+	}
+	for k, v := range overrides.Refs {
+		refs[k] = v
+	}
+	return Create(block.Type, state, refs)
+}
+
+// DeriveTemplateFromBlocks infers a template from an example subgraph. Each
+// type becomes a step, ordered so steps come after those they reference.
+// Refs between blocks of the subgraph become @alias refs; refs leaving it
+// are left for whoever instantiates the template. Fields every example of a
+// type has are required, and fields they share with the same value become
+// defaults when there is more than one example.
+func DeriveTemplateFromBlocks(name string, blocks []Block) TemplateDef {
+	blocks = Order(blocks, nil)
+	typeOf := make(map[string]string, len(blocks))
+	byType := map[string][]Block{}
+	var types []string
+	for _, b := range blocks {
+		typeOf[b.Hash] = b.Type
+		if _, seen := byType[b.Type]; !seen {
+			types = append(types, b.Type)
+		}
+		byType[b.Type] = append(byType[b.Type], b)
+	}
+
+	aliases := map[string]string{}
+	used := map[string]bool{}
+	for _, t := range types {
+		alias := t[strings.LastIndex(t, ".")+1:]
+		for n := 2; used[alias]; n++ {
+			alias = fmt.Sprintf("%s%d", t[strings.LastIndex(t, ".")+1:], n)
+		}
+		used[alias] = true
+		aliases[t] = alias
+	}
+
+	steps := make(map[string]TemplateStep, len(types))
+	for _, t := range types {
+		examples := byType[t]
+		step := TemplateStep{Type: t, Alias: aliases[t]}
+		for _, b := range examples {
+			for role, v := range b.Refs {
+				for _, h := range flattenRefValues(map[string]interface{}{role: v}) {
+					if target, ok := typeOf[h]; ok && role != "updates" {
+						if step.Refs == nil {
+							step.Refs = map[string]string{}
+						}
+						step.Refs[role] = "@" + aliases[target]
+					}
+				}
+			}
+		}
+		for field, v := range examples[0].State {
+			if field == "instance_id" {
+				continue
+			}
+			shared, same := true, true
+			for _, b := range examples[1:] {
+				other, ok := b.State[field]
+				shared = shared && ok
+				same = same && ok && sameJSON(v, other)
+			}
+			if shared {
+				step.Required = append(step.Required, field)
+			}
+			if same && len(examples) > 1 {
+				if step.DefaultState == nil {
+					step.DefaultState = map[string]interface{}{}
+				}
+				step.DefaultState[field] = v
+			}
+		}
+		sort.Strings(step.Required)
+		steps[t] = step
+	}
+
+	tmpl := TemplateDef{Name: name, Description: fmt.Sprintf("Derived from %d example blocks", len(blocks))}
+	placed := map[string]bool{}
+	for len(tmpl.Steps) < len(types) {
+		next := ""
+		for _, t := range types {
+			if placed[aliases[t]] {
+				continue
+			}
+			if next == "" {
+				next = t
+			}
+			ready := true
+			for _, target := range steps[t].Refs {
+				if target != "@"+aliases[t] && !placed[target[1:]] {
+					ready = false
+				}
+			}
+			if ready {
+				next = t
+				break
+			}
+		}
+		placed[aliases[next]] = true
+		tmpl.Steps = append(tmpl.Steps, steps[next])
+	}
+	return tmpl
+}
@@ -0,0 +1,48 @@
+package foodblock
+
+import "testing"
+
+func TestCloneBlock(t *testing.T) {
+	venue := Create("actor.venue", map[string]interface{}{"name": "Corner Cafe"}, nil)
+	v1 := Create("substance.product", map[string]interface{}{"name": "Soup of the week", "price": 5.0, "week": 11}, map[string]interface{}{"seller": venue.Hash})
+	v2 := MergeUpdate(v1, map[string]interface{}{"price": 5.5}, map[string]interface{}{"seller": venue.Hash})
+
+	next := CloneBlock(v2, CloneOverrides{State: map[string]interface{}{"week": 12, "price": nil}})
+	if next.Refs["updates"] != nil || next.Refs["seller"] != venue.Hash || next.State["week"] != 12 || next.State["price"] != nil || next.State["name"] != "Soup of the week" {
+		t.Errorf("clone = %+v", next)
+	}
+
+	order := Create("transfer.order", map[string]interface{}{"total": 12.0}, map[string]interface{}{"buyer": venue.Hash})
+	again := CloneBlock(order, CloneOverrides{})
+	if again.Hash == order.Hash || again.State["instance_id"] == order.State["instance_id"] || again.State["total"] != 12.0 {
+		t.Errorf("event clone = %+v", again)
+	}
+	if fixed := CloneBlock(order, CloneOverrides{State: map[string]interface{}{"instance_id": "o-2"}}); fixed.State["instance_id"] != "o-2" {
+		t.Errorf("instance_id override lost: %v", fixed.State)
+	}
+}
+
+func TestDeriveTemplateFromBlocks(t *testing.T) {
+	venue := Create("actor.venue", map[string]interface{}{"name": "Corner Cafe"}, nil)
+	soup := Create("substance.product", map[string]interface{}{"name": "Soup", "price": 5.0, "unit": "bowl"}, map[string]interface{}{"seller": venue.Hash})
+	stew := Create("substance.product", map[string]interface{}{"name": "Stew", "unit": "bowl"}, map[string]interface{}{"seller": venue.Hash})
+	review := Create("observe.review", map[string]interface{}{"rating": 5.0}, map[string]interface{}{"subject": soup.Hash, "author": "outside"})
+
+	tmpl := DeriveTemplateFromBlocks("weekly menu", []Block{review, stew, soup, venue})
+	if len(tmpl.Steps) != 3 || tmpl.Steps[0].Type != "actor.venue" || tmpl.Steps[2].Type != "observe.review" {
+		t.Fatalf("steps = %+v", tmpl.Steps)
+	}
+	product := tmpl.Steps[1]
+	if product.Alias != "product" || product.Refs["seller"] != "@venue" || len(product.Required) != 2 || product.DefaultState["unit"] != "bowl" || product.DefaultState["name"] != nil {
+		t.Errorf("product step = %+v", product)
+	}
+	if r := tmpl.Steps[2].Refs; len(r) != 1 || r["subject"] != "@product" {
+		t.Errorf("review refs = %v", r)
+	}
+
+	// The derived template instantiates.
+	blocks := FromTemplate(tmpl, map[string]StepOverrides{"venue": {State: map[string]interface{}{"name": "Harbour Cafe"}}, "product": {State: map[string]interface{}{"name": "Chowder"}}})
+	if len(blocks) != 3 || blocks[1].Refs["seller"] != blocks[0].Hash || blocks[1].State["unit"] != "bowl" || blocks[2].Refs["subject"] != blocks[1].Hash {
+		t.Errorf("instantiated = %+v", blocks)
+	}
+}
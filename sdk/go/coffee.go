@@ -0,0 +1,220 @@
+package foodblock
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RoastType records one roast batch charged from green coffee (or cacao)
+// lots.
+const RoastType = "transform.roast"
+
+// RoastLossRange is the expected weight loss during roasting, in percent of
+// the green weight.
+type RoastLossRange struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// RoastLossRanges are the expected weight losses by roast level.
+var RoastLossRanges = map[string]RoastLossRange{
+	"light":  {11, 14},
+	"medium": {14, 17},
+	"dark":   {17, 22},
+}
+
+// CuppingGrade classifies an SCA cupping score.
+func CuppingGrade(score float64) string {
+	switch {
+	case score >= 90:
+		return "outstanding"
+	case score >= 85:
+		return "excellent"
+	case score >= 80:
+		return "specialty"
+	}
+	return "below_specialty"
+}
+
+// Roast describes a roast batch.
+type Roast struct {
+	Name      string
+	Roaster   string
+	Level     string             // light, medium or dark
+	Charges   map[string]float64 // green lot hash -> kg charged
+	RoastedKg float64
+	Date      time.Time
+}
+
+// CreateRoast records a roast batch as a transform.roast block (refs.inputs
+// are the green lots) and the substance.coffee it produced (refs.origin is the
+// roast). The weight loss is compared with RoastLossRanges for the level and
+// recorded as loss_status ok, low or high. The roasted coffee carries the
+// lots' origin when they all share one.
+func CreateRoast(r Roast, resolve func(string) *Block) (Block, Block, error) {
+	if r.Roaster == "" || len(r.Charges) == 0 {
+		return Block{}, Block{}, errors.New("FoodBlock: roast needs a roaster and green lots")
+	}
+	expected, ok := RoastLossRanges[strings.ToLower(r.Level)]
+	if !ok {
+		return Block{}, Block{}, fmt.Errorf("FoodBlock: unknown roast level %q", r.Level)
+	}
+	lots := make([]string, 0, len(r.Charges))
+	for h := range r.Charges {
+		lots = append(lots, h)
+	}
+	sort.Strings(lots)
+	var green float64
+	var origins []string
+	missingOrigin := false
+	charges := map[string]interface{}{}
+	for _, h := range lots {
+		lot := resolve(h)
+		if lot == nil || !strings.HasPrefix(lot.Type, "substance.") {
+			return Block{}, Block{}, fmt.Errorf("FoodBlock: green lot not found: %s", h)
+		}
+		if r.Charges[h] <= 0 {
+			return Block{}, Block{}, fmt.Errorf("FoodBlock: charge for %s must be positive", h)
+		}
+		green += r.Charges[h]
+		charges[h] = r.Charges[h]
+		o := firstString(lot.State, "origin", "country")
+		missingOrigin = missingOrigin || o == ""
+		origins = appendUnique(origins, o)
+	}
+	if r.RoastedKg <= 0 || r.RoastedKg > green {
+		return Block{}, Block{}, fmt.Errorf("FoodBlock: roasted weight %.3f kg must be positive and at most the green weight %.3f kg", r.RoastedKg, green)
+	}
+	if r.Date.IsZero() {
+		r.Date = time.Now()
+	}
+	loss := round3((green - r.RoastedKg) / green * 100)
+	status := "ok"
+	switch {
+	case loss < expected.Min:
+		status = "low"
+	case loss > expected.Max:
+		status = "high"
+	}
+	level := strings.ToLower(r.Level)
+	roast := Create(RoastType, map[string]interface{}{
+		"roast_level":   level,
+		"charges":       charges,
+		"green_kg":      round3(green),
+		"roasted_kg":    r.RoastedKg,
+		"weight_loss":   loss,
+		"expected_loss": map[string]interface{}{"min": expected.Min, "max": expected.Max},
+		"loss_status":   status,
+		"date":          r.Date.Format("2006-01-02"),
+	}, map[string]interface{}{"inputs": refList(lots), "operator": r.Roaster})
+
+	name := r.Name
+	if name == "" {
+		name = "Roasted coffee"
+	}
+	state := map[string]interface{}{
+		"name":        name,
+		"roast_level": level,
+		"quantity":    r.RoastedKg,
+		"unit":        "kg",
+		"date":        r.Date.Format("2006-01-02"),
+	}
+	if len(origins) == 1 && !missingOrigin {
+		state["origin"] = origins[0]
+	}
+	roasted := Create("substance.coffee", state, map[string]interface{}{"origin": roast.Hash, "producer": r.Roaster})
+	return roast, roasted, nil
+}
+
+// RoastsOf returns the roast batches charged from a green lot.
+func RoastsOf(lotHash string, store BlockStore) []Block {
+	var out []Block
+	for _, b := range store.ResolveForward(lotHash) {
+		if b.Type == RoastType && containsStr(flattenRefValues(map[string]interface{}{"inputs": b.Refs["inputs"]}), lotHash) {
+			out = append(out, b)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Hash < out[j].Hash })
+	return out
+}
+
+// OriginClaim is the verdict on a product's origin claims against the green
+// lots upstream of it.
+type OriginClaim struct {
+	Product      string   `json:"product"`
+	Claimed      string   `json:"claimed,omitempty"` // state.origin
+	SingleOrigin bool     `json:"single_origin"`     // state.single_origin
+	Lots         []string `json:"lots"`              // upstream source lots
+	Origins      []string `json:"origins"`           // distinct origins of those lots
+	Valid        bool     `json:"valid"`
+	Reasons      []string `json:"reasons"`
+}
+
+// ValidateOriginClaim checks a product's state.origin and state.single_origin
+// against the source lots found by walking its origin, process, source and
+// input refs to blocks with no further inputs. A claimed origin must match
+// every source lot; a single-origin claim needs exactly one origin among them.
+// Lots without an origin fail both claims.
+func ValidateOriginClaim(product Block, store BlockStore) OriginClaim {
+	c := OriginClaim{Product: product.Hash, Lots: []string{}, Origins: []string{}, Reasons: []string{}}
+	c.Claimed = firstString(product.State, "origin")
+	c.SingleOrigin, _ = product.State["single_origin"].(bool)
+	if c.Claimed == "" && !c.SingleOrigin {
+		c.Valid = true
+		return c
+	}
+
+	unknown := 0
+	visited := map[string]bool{product.Hash: true}
+	var walk func(b Block, depth int)
+	walk = func(b Block, depth int) {
+		upstream := 0
+		for _, key := range upstreamRefs {
+			for _, h := range flattenRefValues(map[string]interface{}{key: b.Refs[key]}) {
+				up := store.Resolve(h)
+				if up == nil {
+					continue
+				}
+				upstream++
+				if !visited[h] && depth < 20 {
+					visited[h] = true
+					walk(*up, depth+1)
+				}
+			}
+		}
+		if upstream > 0 || b.Hash == product.Hash || !strings.HasPrefix(b.Type, "substance.") {
+			return
+		}
+		c.Lots = append(c.Lots, b.Hash)
+		origin := firstString(b.State, "origin", "country")
+		if origin == "" {
+			unknown++
+		}
+		c.Origins = appendUnique(c.Origins, origin)
+	}
+	walk(product, 0)
+	sort.Strings(c.Lots)
+	sort.Strings(c.Origins)
+
+	if len(c.Lots) == 0 {
+		c.Reasons = append(c.Reasons, "no source lots upstream")
+	}
+	if unknown > 0 {
+		c.Reasons = append(c.Reasons, fmt.Sprintf("%d source lot(s) have no origin", unknown))
+	}
+	if c.Claimed != "" {
+		for _, o := range c.Origins {
+			if !strings.EqualFold(o, c.Claimed) {
+				c.Reasons = append(c.Reasons, fmt.Sprintf("claims %s but includes %s", c.Claimed, o))
+			}
+		}
+	}
+	if c.SingleOrigin && len(c.Origins) > 1 {
+		c.Reasons = append(c.Reasons, fmt.Sprintf("claims single origin but blends %s", strings.Join(c.Origins, ", ")))
+	}
+	c.Valid = len(c.Reasons) == 0
+	return c
+}
@@ -0,0 +1,73 @@
+package foodblock
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRoastAndOriginClaims(t *testing.T) {
+	day := time.Date(2026, 2, 10, 0, 0, 0, 0, time.UTC)
+	roaster := Create("actor.producer", map[string]interface{}{"name": "Northside Roasters"}, nil)
+	yirga := Create("substance.coffee", map[string]interface{}{"name": "Yirgacheffe G1", "origin": "Ethiopia", "variety": "heirloom", "process_method": "washed", "quantity": 60.0}, nil)
+	guji := Create("substance.coffee", map[string]interface{}{"name": "Guji natural", "origin": "Ethiopia", "process_method": "natural", "quantity": 60.0}, nil)
+	huila := Create("substance.coffee", map[string]interface{}{"name": "Huila excelso", "origin": "Colombia", "quantity": 70.0}, nil)
+	store := NewMemoryStore()
+	store.PutAll([]Block{roaster, yirga, guji, huila})
+
+	roast, coffee, err := CreateRoast(Roast{Name: "Ethiopia blend", Roaster: roaster.Hash, Level: "medium", Charges: map[string]float64{yirga.Hash: 6, guji.Hash: 4}, RoastedKg: 8.5, Date: day}, store.Resolve)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if roast.State["weight_loss"] != 15.0 || roast.State["loss_status"] != "ok" || coffee.State["origin"] != "Ethiopia" {
+		t.Errorf("unexpected roast %v / coffee %v", roast.State, coffee.State)
+	}
+	dark, _, _ := CreateRoast(Roast{Roaster: roaster.Hash, Level: "dark", Charges: map[string]float64{huila.Hash: 10}, RoastedKg: 8.8, Date: day}, store.Resolve)
+	if dark.State["loss_status"] != "low" {
+		t.Errorf("expected a 12%% loss to be low for a dark roast, got %v", dark.State["loss_status"])
+	}
+	if _, _, err := CreateRoast(Roast{Roaster: roaster.Hash, Level: "light", Charges: map[string]float64{huila.Hash: 1}, RoastedKg: 2}, store.Resolve); err == nil {
+		t.Error("expected roasted weight above green weight to be rejected")
+	}
+	store.PutAll([]Block{roast, coffee, dark})
+	if roasts := RoastsOf(yirga.Hash, store); len(roasts) != 1 || roasts[0].Hash != roast.Hash {
+		t.Errorf("expected the lot to link to its roast, got %d", len(roasts))
+	}
+
+	claim := ValidateOriginClaim(coffee, store)
+	if !claim.Valid || len(claim.Lots) != 2 {
+		t.Errorf("expected the Ethiopia claim to hold, got %+v", claim)
+	}
+	single := MergeUpdate(coffee, map[string]interface{}{"single_origin": true}, map[string]interface{}{"origin": roast.Hash})
+	if !ValidateOriginClaim(single, store).Valid {
+		t.Error("expected two Ethiopian lots to count as a single origin")
+	}
+
+	blendRoast, blend, _ := CreateRoast(Roast{Roaster: roaster.Hash, Level: "medium", Charges: map[string]float64{yirga.Hash: 5, huila.Hash: 5}, RoastedKg: 8.5, Date: day}, store.Resolve)
+	store.PutAll([]Block{blendRoast, blend})
+	if blend.State["origin"] != nil {
+		t.Errorf("a two-country blend should carry no origin, got %v", blend.State["origin"])
+	}
+	false1 := MergeUpdate(blend, map[string]interface{}{"origin": "Colombia", "single_origin": true}, map[string]interface{}{"origin": blendRoast.Hash})
+	claim = ValidateOriginClaim(false1, store)
+	reasons := strings.Join(claim.Reasons, "; ")
+	if claim.Valid || !strings.Contains(reasons, "includes Ethiopia") || !strings.Contains(reasons, "blends Colombia, Ethiopia") {
+		t.Errorf("expected both claims to fail, got %+v", claim)
+	}
+}
+
+func TestCoffeeGrading(t *testing.T) {
+	if grade, _, ok := SchemeFor("coffee").Grade(350, map[string]int{"black": 1, "sour": 3}); grade != "specialty" || !ok {
+		t.Errorf("expected 4 defects in 350 g to grade specialty, got %s", grade)
+	}
+	if grade, _, ok := SchemeFor("coffee").Grade(350, map[string]int{"broken": 12}); grade != "exchange" || ok {
+		t.Errorf("expected exchange grade to be refused, got %s %v", grade, ok)
+	}
+	if CuppingGrade(86.25) != "excellent" || CuppingGrade(79.5) != "below_specialty" {
+		t.Error("unexpected cupping grades")
+	}
+	result := MapFields("washed caturra grown at 1850 masl, cupping score 87", Vocabularies["coffee"])
+	if result.Matched["altitude"] != 1850.0 || result.Matched["cupping_score"] != 87.0 {
+		t.Errorf("unexpected fields %v", result.Matched)
+	}
+}
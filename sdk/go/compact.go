@@ -0,0 +1,182 @@
+package foodblock
+
+import (
+	"errors"
+	"time"
+)
+
+// CompactionPolicy controls what a compaction run may remove.
+type CompactionPolicy struct {
+	PruneSuperseded bool          // stub non-head versions past the retention window
+	Retention       time.Duration // minimum age before a superseded version may be pruned
+	Cold            ColdBackend   // archived copies of tombstoned blocks are deleted here
+	Authorities     []string      // actors whose signed tombstones may erase any block
+	Now             func() time.Time
+}
+
+// CompactionReport summarises a compaction run.
+type CompactionReport struct {
+	Erased  []string // tombstoned targets reduced to stubs
+	Pruned  []string // superseded versions reduced to stubs
+	Skipped []string // referenced superseded versions, and tombstones without erasure authority
+	Held    []string // blocks kept because they are pinned (see Pin)
+	Report  Block    // the observe.compaction block stored with the results
+}
+
+// Compact erases the content of tombstoned blocks and, when the policy allows,
+// prunes superseded versions older than the retention window. A tombstone
+// erases only when its signer is the target's signer or author, or one of
+// policy.Authorities (Section 5.4); other tombstones are reported as skipped. Blocks are never
+// deleted: removed content leaves a stub with the original hash, type and refs
+// so references and snapshot roots stay valid. A superseded version that any
+// block references by a role other than "updates" keeps its content, and so
+// does a pinned block, even when tombstoned. A tombstoned block that was
+// archived is erased too, and its cold copy deleted when policy.Cold is set.
+// Indexes are rebuilt and an observe.compaction report block is stored.
+func Compact(store StubStore, policy CompactionPolicy) (CompactionReport, error) {
+	if policy.Now == nil {
+		policy.Now = time.Now
+	}
+	now := policy.Now()
+	var report CompactionReport
+
+	blocks := store.Blocks()
+	superseded := make(map[string]bool)
+	for _, b := range blocks {
+		if b.Type == "observe.tombstone" {
+			target, _ := b.Refs["target"].(string)
+			if target == "" {
+				continue
+			}
+			t := store.Resolve(target)
+			if t == nil {
+				continue
+			}
+			archived, _ := t.State["archived"].(bool)
+			if IsStub(*t) && !archived {
+				continue
+			}
+			if !erasureAllowed(b, store, policy.Authorities) {
+				report.Skipped = append(report.Skipped, b.Hash)
+				continue
+			}
+			if IsPinned(target, store) {
+				report.Held = append(report.Held, target)
+				continue
+			}
+			if archived && policy.Cold != nil {
+				if err := policy.Cold.DeleteCold(target); err != nil {
+					return report, err
+				}
+			}
+			store.Stub(target, map[string]interface{}{"tombstoned": true})
+			report.Erased = append(report.Erased, target)
+			continue
+		}
+		if prev, ok := b.Refs["updates"].(string); ok {
+			superseded[prev] = true
+		}
+	}
+
+	if policy.PruneSuperseded {
+		if policy.Retention < 0 {
+			return report, errors.New("FoodBlock: retention must not be negative")
+		}
+		for _, b := range blocks {
+			// Re-resolve: the block may have been erased above.
+			if !superseded[b.Hash] {
+				continue
+			}
+			if current := store.Resolve(b.Hash); current == nil || IsStub(*current) {
+				continue
+			}
+			stored, ok := store.StoredAt(b.Hash)
+			if !ok || now.Sub(stored) < policy.Retention {
+				continue
+			}
+			if IsPinned(b.Hash, store) {
+				report.Held = append(report.Held, b.Hash)
+				continue
+			}
+			if referencedBeyondUpdates(store, b.Hash) {
+				report.Skipped = append(report.Skipped, b.Hash)
+				continue
+			}
+			store.Stub(b.Hash, map[string]interface{}{"pruned": true})
+			report.Pruned = append(report.Pruned, b.Hash)
+		}
+	}
+
+	if rb, ok := store.(interface{ RebuildIndexes() }); ok {
+		rb.RebuildIndexes()
+	}
+
+	state := map[string]interface{}{
+		"erased":         len(report.Erased),
+		"pruned":         len(report.Pruned),
+		"skipped":        len(report.Skipped),
+		"held":           len(report.Held),
+		"retention_days": policy.Retention.Hours() / 24,
+		"compacted_at":   now.UTC().Format(time.RFC3339),
+	}
+	report.Report = Create("observe.compaction", state, nil)
+	if err := store.Put(report.Report); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// isTombstoned reports whether a tombstone with erasure authority targets hash.
+func isTombstoned(hash string, store BlockStore) bool {
+	for _, b := range store.ResolveForward(hash) {
+		if b.Type == "observe.tombstone" && b.Refs["target"] == hash && erasureAllowed(b, store, nil) {
+			return true
+		}
+	}
+	return false
+}
+
+// erasureAllowed reports whether a tombstone may erase its target. Its signer
+// must be known and be one of authorities, or match state.requested_by when
+// set and be the target's signer or the author the target names. An
+// unsigned tombstone never erases anything.
+func erasureAllowed(tomb Block, store BlockStore, authorities []string) bool {
+	signers, ok := store.(interface{ SignerOf(string) string })
+	if !ok {
+		return false
+	}
+	signer := signers.SignerOf(tomb.Hash)
+	if signer == "" {
+		return false
+	}
+	if containsStr(authorities, signer) {
+		return true
+	}
+	if by, _ := tomb.State["requested_by"].(string); by != "" && by != signer {
+		return false
+	}
+	target, _ := tomb.Refs["target"].(string)
+	if signer == signers.SignerOf(target) {
+		return true
+	}
+	t := store.Resolve(target)
+	return t != nil && firstRef(t.Refs, "author") == signer
+}
+
+// referencedBeyondUpdates reports whether any block references hash through a
+// role other than "updates" (tombstone "target" refs do not count).
+func referencedBeyondUpdates(store BlockStore, hash string) bool {
+	for _, b := range store.ResolveForward(hash) {
+		for role, ref := range b.Refs {
+			if role == "updates" || (b.Type == "observe.tombstone" && role == "target") {
+				continue
+			}
+			for _, h := range flattenRefValues(map[string]interface{}{role: ref}) {
+				if h == hash {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package foodblock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCompactErasesTombstonedContent(t *testing.T) {
+	store := NewMemoryStore()
+	review := Create("observe.review", map[string]interface{}{"rating": 1.0, "text": "personal data"}, nil)
+	tomb := Tombstone(review.Hash, "user_hash")
+	store.PutSigned(SignedBlock{FoodBlock: review, AuthorHash: "user_hash"})
+	store.PutSigned(SignedBlock{FoodBlock: tomb, AuthorHash: "user_hash"})
+
+	report, err := Compact(store, CompactionPolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Erased) != 1 || report.Erased[0] != review.Hash {
+		t.Fatalf("expected review to be erased, got %v", report.Erased)
+	}
+	stub := store.Resolve(review.Hash)
+	if stub == nil || !IsStub(*stub) || stub.State["text"] != nil {
+		t.Errorf("expected hash stub without content, got %v", stub)
+	}
+	if store.Resolve(report.Report.Hash) == nil || report.Report.Type != "observe.compaction" {
+		t.Error("expected compaction report block to be stored")
+	}
+}
+
+func TestCompactSkipsTombstonesWithoutAuthority(t *testing.T) {
+	store := NewMemoryStore()
+	review := Create("observe.review", map[string]interface{}{"text": "personal data"}, map[string]interface{}{"author": "alice"})
+	other := Create("observe.review", map[string]interface{}{"text": "more data"}, nil)
+	store.PutSigned(SignedBlock{FoodBlock: review, AuthorHash: "venue"})
+	store.PutSigned(SignedBlock{FoodBlock: other, AuthorHash: "venue"})
+	unsigned := Tombstone(review.Hash, "anyone")
+	stranger := Tombstone(review.Hash, "mallory")
+	posing := Tombstone(other.Hash, "venue")
+	store.Put(unsigned)
+	store.PutSigned(SignedBlock{FoodBlock: stranger, AuthorHash: "mallory"})
+	store.PutSigned(SignedBlock{FoodBlock: posing, AuthorHash: "mallory"})
+
+	report, err := Compact(store, CompactionPolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Erased) != 0 || len(report.Skipped) != 3 {
+		t.Fatalf("erased %v, skipped %v", report.Erased, report.Skipped)
+	}
+	if store.Resolve(review.Hash).State["text"] != "personal data" {
+		t.Error("an unauthorised tombstone erased the review")
+	}
+
+	// The author the review names, and a compaction authority, may erase.
+	store.PutSigned(SignedBlock{FoodBlock: Tombstone(review.Hash, "alice"), AuthorHash: "alice"})
+	store.PutSigned(SignedBlock{FoodBlock: Tombstone(other.Hash, "subject"), AuthorHash: "dpo"})
+	report, _ = Compact(store, CompactionPolicy{Authorities: []string{"dpo"}})
+	if len(report.Erased) != 2 || !IsStub(*store.Resolve(review.Hash)) || !IsStub(*store.Resolve(other.Hash)) {
+		t.Errorf("erased %v", report.Erased)
+	}
+}
+
+func TestCompactPrunesSupersededVersions(t *testing.T) {
+	store := NewMemoryStore()
+	v1 := Create("substance.product", map[string]interface{}{"name": "Bread", "price": 3.0}, nil)
+	v2 := MergeUpdate(v1, map[string]interface{}{"price": 3.5}, nil)
+	v3 := MergeUpdate(v2, map[string]interface{}{"price": 4.0}, nil)
+	// An order pinned to v2's price keeps v2 alive.
+	order := Create("transfer.order", map[string]interface{}{"quantity": 1}, map[string]interface{}{"item": v2.Hash})
+	store.PutAll([]Block{v1, v2, v3, order})
+
+	policy := CompactionPolicy{PruneSuperseded: true, Retention: 24 * time.Hour}
+	report, _ := Compact(store, policy)
+	if len(report.Pruned) != 0 {
+		t.Fatalf("expected nothing pruned inside retention window, got %v", report.Pruned)
+	}
+
+	policy.Now = func() time.Time { return time.Now().Add(48 * time.Hour) }
+	report, _ = Compact(store, policy)
+	if len(report.Pruned) != 1 || report.Pruned[0] != v1.Hash {
+		t.Errorf("expected only v1 pruned, got %v", report.Pruned)
+	}
+	if len(report.Skipped) != 1 || report.Skipped[0] != v2.Hash {
+		t.Errorf("expected v2 skipped as referenced, got %v", report.Skipped)
+	}
+	if b := store.Resolve(v3.Hash); b == nil || IsStub(*b) {
+		t.Error("head version must keep its content")
+	}
+	if chain := Chain(v3.Hash, store.Resolve, 0); len(chain) != 3 {
+		t.Errorf("expected update chain to survive pruning, got %d versions", len(chain))
+	}
+}
+
+func TestCompactKeepsTombstoneMarkerOnSupersededVersion(t *testing.T) {
+	store := NewMemoryStore()
+	v1 := Create("actor.producer", map[string]interface{}{"name": "Jane Smith", "phone": "0700"}, nil)
+	v2 := MergeUpdate(v1, map[string]interface{}{"phone": "0800"}, nil)
+	store.PutSigned(SignedBlock{FoodBlock: v1, AuthorHash: "user_hash"})
+	store.Put(v2)
+	store.PutSigned(SignedBlock{FoodBlock: Tombstone(v1.Hash, "user_hash"), AuthorHash: "user_hash"})
+
+	report, err := Compact(store, CompactionPolicy{PruneSuperseded: true, Now: func() time.Time { return time.Now().Add(time.Hour) }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Erased) != 1 || len(report.Pruned) != 0 {
+		t.Fatalf("expected v1 erased and not pruned, got erased %v pruned %v", report.Erased, report.Pruned)
+	}
+	if stub := store.Resolve(v1.Hash); stub == nil || stub.State["tombstoned"] != true {
+		t.Errorf("tombstone marker overwritten: %v", stub)
+	}
+}
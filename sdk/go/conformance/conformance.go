@@ -0,0 +1,613 @@
+// Package conformance checks a FoodBlock implementation served over the
+// federation HTTP API against the Go reference implementation.
+//
+// Run it against a peer with:
+//
+//	go test ./conformance -run TestPeer -peer https://example.org -write
+//
+// Every check reads the peer through GET /.well-known/foodblock, /blocks,
+// /blocks/{hash} and /heads. With Write set the suite also seeds its own
+// fixtures through POST /blocks (plain {type, state, refs} bodies or signed
+// wrappers), so a peer can be checked from empty; without it, checks that
+// find nothing relevant on the peer are skipped rather than failed.
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+
+	foodblock "github.com/FoodXDevelopment/foodblock/sdk/go"
+)
+
+// Check outcomes.
+const (
+	Pass = "pass"
+	Fail = "fail"
+	Skip = "skip"
+)
+
+// DefaultSample is how many served blocks the read-only checks examine.
+const DefaultSample = 200
+
+// Vector is one canonical hashing vector from test/vectors.json.
+type Vector struct {
+	Name              string                 `json:"name"`
+	Type              string                 `json:"type"`
+	State             map[string]interface{} `json:"state"`
+	Refs              map[string]interface{} `json:"refs"`
+	ExpectedCanonical string                 `json:"expected_canonical"`
+	ExpectedHash      string                 `json:"expected_hash"`
+}
+
+// LoadVectors reads a vectors file.
+func LoadVectors(path string) ([]Vector, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var vectors []Vector
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		return nil, fmt.Errorf("FoodBlock: vectors: %w", err)
+	}
+	return vectors, nil
+}
+
+// Options configures Run.
+type Options struct {
+	BaseURL string
+	HTTP    *http.Client // default http.DefaultClient
+	Vectors []Vector
+	Sample  int  // served blocks examined, default DefaultSample
+	Write   bool // seed fixtures through POST /blocks
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Name     string   `json:"name"`
+	Status   string   `json:"status"`
+	Detail   string   `json:"detail,omitempty"`
+	Failures []string `json:"failures,omitempty"`
+}
+
+// Report is the outcome of a run.
+type Report struct {
+	Peer    string   `json:"peer"`
+	Results []Result `json:"results"`
+	Passed  int      `json:"passed"`
+	Failed  int      `json:"failed"`
+	Skipped int      `json:"skipped"`
+}
+
+// OK reports whether no check failed.
+func (r Report) OK() bool { return r.Failed == 0 }
+
+// String renders the report one check per line.
+func (r Report) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "FoodBlock conformance: %s\n", r.Peer)
+	for _, res := range r.Results {
+		fmt.Fprintf(&sb, "  %-4s %s", strings.ToUpper(res.Status), res.Name)
+		if res.Detail != "" {
+			fmt.Fprintf(&sb, ": %s", res.Detail)
+		}
+		sb.WriteString("\n")
+		for _, f := range res.Failures {
+			fmt.Fprintf(&sb, "       - %s\n", f)
+		}
+	}
+	fmt.Fprintf(&sb, "%d passed, %d failed, %d skipped\n", r.Passed, r.Failed, r.Skipped)
+	return sb.String()
+}
+
+// Run checks the peer at opts.BaseURL:
+//
+//	discovery       the well-known document names the protocol
+//	vectors         the peer hashes every vector as the reference does
+//	served-hashes   served blocks hash to the hash they are served under
+//	signatures      valid signatures verify and tampered ones are rejected
+//	update-chains   heads are the latest versions of their chains
+//	tombstones      tombstoned targets keep their hash but lose their content
+//	merges          merge blocks name two resolvable forks and keep a_wins/b_wins state
+func Run(ctx context.Context, opts Options) Report {
+	r := &runner{ctx: ctx, opts: opts, base: strings.TrimRight(opts.BaseURL, "/"), blocks: map[string]*served{}}
+	if r.opts.HTTP == nil {
+		r.opts.HTTP = http.DefaultClient
+	}
+	if r.opts.Sample <= 0 {
+		r.opts.Sample = DefaultSample
+	}
+	report := Report{Peer: r.base}
+	for _, check := range []struct {
+		name string
+		fn   func() Result
+	}{
+		{"discovery", r.discovery},
+		{"vectors", r.vectors},
+		{"served-hashes", r.servedHashes},
+		{"signatures", r.signatures},
+		{"update-chains", r.updateChains},
+		{"tombstones", r.tombstones},
+		{"merges", r.merges},
+	} {
+		res := check.fn()
+		res.Name = check.name
+		if res.Status == "" {
+			res.Status = Pass
+			if len(res.Failures) > 0 {
+				res.Status = Fail
+			}
+		}
+		switch res.Status {
+		case Pass:
+			report.Passed++
+		case Fail:
+			report.Failed++
+		default:
+			report.Skipped++
+		}
+		report.Results = append(report.Results, res)
+	}
+	return report
+}
+
+// served is a block as a peer returns it; signed peers include the wrapper
+// fields alongside the block.
+type served struct {
+	foodblock.Block
+	AuthorHash string `json:"author_hash,omitempty"`
+	Signature  string `json:"signature,omitempty"`
+}
+
+type runner struct {
+	ctx    context.Context
+	opts   Options
+	base   string
+	sample []served
+	listed bool
+	blocks map[string]*served // fetched by hash; nil when not found
+}
+
+func (r *runner) discovery() Result {
+	var doc foodblock.WellKnownDoc
+	if status, err := r.get("/.well-known/foodblock", &doc); err != nil || status != http.StatusOK {
+		return Result{Failures: []string{fmt.Sprintf("GET /.well-known/foodblock: %s", describe(status, err))}}
+	}
+	if doc.Protocol != "foodblock" {
+		return Result{Failures: []string{fmt.Sprintf("protocol is %q", doc.Protocol)}}
+	}
+	return Result{Detail: fmt.Sprintf("version %s, %d blocks", doc.Version, doc.Count)}
+}
+
+func (r *runner) vectors() Result {
+	if len(r.opts.Vectors) == 0 {
+		return Result{Status: Skip, Detail: "no vectors loaded"}
+	}
+	var res Result
+	checked := 0
+	for _, v := range r.opts.Vectors {
+		if got := foodblock.Hash(v.Type, v.State, v.Refs); got != v.ExpectedHash {
+			res.Failures = append(res.Failures, fmt.Sprintf("%s: reference hash %s, vector expects %s", v.Name, got, v.ExpectedHash))
+			continue
+		}
+		var b *served
+		if r.opts.Write {
+			created, err := r.post(map[string]interface{}{"type": v.Type, "state": v.State, "refs": v.Refs})
+			if err != nil {
+				res.Failures = append(res.Failures, fmt.Sprintf("%s: %v", v.Name, err))
+				continue
+			}
+			b = &served{Block: created}
+		} else if b = r.block(v.ExpectedHash); b == nil {
+			continue
+		}
+		checked++
+		if b.Hash != v.ExpectedHash {
+			res.Failures = append(res.Failures, fmt.Sprintf("%s: peer hash %s, expected %s", v.Name, b.Hash, v.ExpectedHash))
+		} else if got := foodblock.Canonical(b.Type, b.State, b.Refs); got != v.ExpectedCanonical {
+			res.Failures = append(res.Failures, fmt.Sprintf("%s: served content canonicalizes to %s", v.Name, got))
+		}
+	}
+	if checked == 0 && len(res.Failures) == 0 {
+		return Result{Status: Skip, Detail: "peer holds none of the vectors"}
+	}
+	res.Detail = fmt.Sprintf("%d of %d vectors on the peer", checked, len(r.opts.Vectors))
+	return res
+}
+
+func (r *runner) servedHashes() Result {
+	blocks, err := r.list()
+	if err != nil {
+		return Result{Failures: []string{err.Error()}}
+	}
+	if len(blocks) == 0 {
+		return Result{Status: Skip, Detail: "peer lists no blocks"}
+	}
+	var res Result
+	stubs := 0
+	for _, b := range blocks {
+		if foodblock.IsStub(b.Block) {
+			stubs++
+			continue
+		}
+		if got := foodblock.Hash(b.Type, b.State, b.Refs); got != b.Hash {
+			res.Failures = append(res.Failures, fmt.Sprintf("%s (%s) hashes to %s", b.Hash, b.Type, got))
+			continue
+		}
+		if one := r.block(b.Hash); one == nil || !reflect.DeepEqual(one.Block, b.Block) {
+			res.Failures = append(res.Failures, fmt.Sprintf("%s differs between /blocks and /blocks/{hash}", b.Hash))
+		}
+	}
+	res.Detail = fmt.Sprintf("%d blocks, %d stubs", len(blocks), stubs)
+	return res
+}
+
+func (r *runner) signatures() Result {
+	var res Result
+	checked := 0
+	if r.opts.Write {
+		pub, priv := foodblock.GenerateKeypair()
+		actor, err := r.post(map[string]interface{}{
+			"type":  "actor.producer",
+			"state": map[string]interface{}{"name": "Conformance " + hex.EncodeToString(pub[:4]), "public_key": hex.EncodeToString(pub)},
+			"refs":  map[string]interface{}{},
+		})
+		if err != nil {
+			return Result{Failures: []string{"seeding actor: " + err.Error()}}
+		}
+		block := foodblock.Create("observe.reading", map[string]interface{}{"instance_id": "conformance-" + actor.Hash[:12], "value": 4.2}, map[string]interface{}{"author": actor.Hash})
+		signed := foodblock.Sign(block, actor.Hash, priv)
+		if _, err := r.post(signed); err != nil {
+			res.Failures = append(res.Failures, "valid signature rejected: "+err.Error())
+		}
+		tampered := signed
+		tampered.FoodBlock = foodblock.Create(block.Type, map[string]interface{}{"instance_id": block.State["instance_id"], "value": 9.9}, block.Refs)
+		if _, err := r.post(tampered); err == nil {
+			res.Failures = append(res.Failures, "tampered signature accepted")
+		}
+		checked += 2
+	}
+
+	blocks, err := r.list()
+	if err != nil {
+		return Result{Failures: []string{err.Error()}}
+	}
+	for _, b := range blocks {
+		if b.Signature == "" || b.AuthorHash == "" {
+			continue
+		}
+		author := r.block(b.AuthorHash)
+		if author == nil {
+			continue
+		}
+		key, _ := author.State["public_key"].(string)
+		pub, err := hex.DecodeString(key)
+		if err != nil || len(pub) == 0 {
+			continue
+		}
+		checked++
+		if !foodblock.Verify(foodblock.SignedBlock{FoodBlock: b.Block, AuthorHash: b.AuthorHash, Signature: b.Signature}, pub) {
+			res.Failures = append(res.Failures, fmt.Sprintf("%s: signature does not verify against %s", b.Hash, b.AuthorHash))
+		}
+	}
+	if checked == 0 {
+		return Result{Status: Skip, Detail: "peer serves no signed blocks with resolvable keys"}
+	}
+	res.Detail = fmt.Sprintf("%d signatures checked", checked)
+	return res
+}
+
+func (r *runner) updateChains() Result {
+	var res Result
+	if r.opts.Write {
+		v1, err := r.post(map[string]interface{}{"type": "substance.product", "state": map[string]interface{}{"name": "Conformance loaf", "price": 3.5}, "refs": map[string]interface{}{}})
+		if err != nil {
+			return Result{Failures: []string{"seeding chain: " + err.Error()}}
+		}
+		want := foodblock.Update(v1.Hash, v1.Type, map[string]interface{}{"name": "Conformance loaf", "price": 3.75}, nil)
+		v2, err := r.post(map[string]interface{}{"type": want.Type, "state": want.State, "refs": want.Refs})
+		switch {
+		case err != nil:
+			res.Failures = append(res.Failures, "posting update: "+err.Error())
+		case v2.Hash != want.Hash:
+			res.Failures = append(res.Failures, fmt.Sprintf("update hashed to %s, reference %s", v2.Hash, want.Hash))
+		}
+		var page struct {
+			Blocks []served `json:"blocks"`
+		}
+		if status, err := r.get("/heads?type=substance.product&limit=1000", &page); err != nil || status != http.StatusOK {
+			res.Failures = append(res.Failures, "GET /heads: "+describe(status, err))
+		} else {
+			heads := map[string]bool{}
+			for _, h := range page.Blocks {
+				heads[h.Hash] = true
+			}
+			if heads[v1.Hash] {
+				res.Failures = append(res.Failures, "superseded version still a head")
+			}
+			if !heads[want.Hash] {
+				res.Failures = append(res.Failures, "latest version not a head")
+			}
+		}
+	}
+
+	var page struct {
+		Blocks []served `json:"blocks"`
+	}
+	status, err := r.get(fmt.Sprintf("/heads?limit=%d", r.opts.Sample), &page)
+	if status == http.StatusNotImplemented || status == http.StatusNotFound {
+		if r.opts.Write {
+			return res
+		}
+		return Result{Status: Skip, Detail: "peer does not serve /heads"}
+	}
+	if err != nil {
+		return Result{Failures: append(res.Failures, "GET /heads: "+err.Error())}
+	}
+	blocks, err := r.list()
+	if err != nil {
+		return Result{Failures: append(res.Failures, err.Error())}
+	}
+	superseded := map[string]string{}
+	for _, b := range blocks {
+		if prev, ok := b.Refs["updates"].(string); ok {
+			superseded[prev] = b.Hash
+		}
+	}
+	for _, h := range page.Blocks {
+		if next, ok := superseded[h.Hash]; ok {
+			res.Failures = append(res.Failures, fmt.Sprintf("head %s is updated by %s", h.Hash, next))
+		}
+		if prev, ok := h.Refs["updates"].(string); ok && isHash(prev) && r.block(prev) == nil {
+			res.Failures = append(res.Failures, fmt.Sprintf("head %s updates %s, which does not resolve", h.Hash, prev))
+		}
+	}
+	res.Detail = fmt.Sprintf("%d heads", len(page.Blocks))
+	return res
+}
+
+func (r *runner) tombstones() Result {
+	var res Result
+	var targets []string
+	if r.opts.Write {
+		target, err := r.post(map[string]interface{}{"type": "actor.producer", "state": map[string]interface{}{"name": "Conformance erasure", "email": "erase@example.org"}, "refs": map[string]interface{}{}})
+		if err != nil {
+			return Result{Failures: []string{"seeding target: " + err.Error()}}
+		}
+		ts := foodblock.Tombstone(target.Hash, target.Hash)
+		if _, err := r.post(map[string]interface{}{"type": ts.Type, "state": ts.State, "refs": ts.Refs}); err != nil {
+			return Result{Failures: []string{"posting tombstone: " + err.Error()}}
+		}
+		delete(r.blocks, target.Hash)
+		targets = append(targets, target.Hash)
+	}
+	blocks, err := r.list()
+	if err != nil {
+		return Result{Failures: []string{err.Error()}}
+	}
+	for _, b := range blocks {
+		if t, ok := b.Refs["target"].(string); ok && isHash(t) && b.Type == "observe.tombstone" {
+			targets = append(targets, t)
+		}
+	}
+	if len(targets) == 0 {
+		return Result{Status: Skip, Detail: "peer serves no tombstones"}
+	}
+	for _, t := range targets {
+		b := r.block(t)
+		switch {
+		case b == nil:
+			res.Failures = append(res.Failures, fmt.Sprintf("tombstoned %s no longer resolves", t))
+		case b.Hash != t:
+			res.Failures = append(res.Failures, fmt.Sprintf("tombstoned %s served as %s", t, b.Hash))
+		case !foodblock.IsStub(b.Block):
+			res.Failures = append(res.Failures, fmt.Sprintf("tombstoned %s still has its content", t))
+		}
+	}
+	res.Detail = fmt.Sprintf("%d tombstones", len(targets))
+	return res
+}
+
+func (r *runner) merges() Result {
+	var res Result
+	var merges []served
+	if r.opts.Write {
+		base, err := r.post(map[string]interface{}{"type": "substance.product", "state": map[string]interface{}{"name": "Conformance fork", "price": 2.0}, "refs": map[string]interface{}{}})
+		if err != nil {
+			return Result{Failures: []string{"seeding fork base: " + err.Error()}}
+		}
+		forks := map[string]foodblock.Block{}
+		for _, price := range []float64{2.2, 2.4} {
+			f := foodblock.Update(base.Hash, base.Type, map[string]interface{}{"name": "Conformance fork", "price": price}, nil)
+			if _, err := r.post(map[string]interface{}{"type": f.Type, "state": f.State, "refs": f.Refs}); err != nil {
+				if statusOf(err) == http.StatusConflict {
+					return Result{Status: Skip, Detail: "peer rejects unsigned forks"}
+				}
+				return Result{Failures: []string{"posting fork: " + err.Error()}}
+			}
+			forks[f.Hash] = f
+		}
+		var hashes []string
+		for h := range forks {
+			hashes = append(hashes, h)
+		}
+		resolve := func(h string) *foodblock.Block {
+			if f, ok := forks[h]; ok {
+				return &f
+			}
+			return nil
+		}
+		want, _ := foodblock.Merge(hashes[0], hashes[1], resolve, "a_wins", nil)
+		got, err := r.post(map[string]interface{}{"type": want.Type, "state": want.State, "refs": want.Refs})
+		switch {
+		case err != nil:
+			res.Failures = append(res.Failures, "posting merge: "+err.Error())
+		case got.Hash != want.Hash:
+			res.Failures = append(res.Failures, fmt.Sprintf("merge hashed to %s, reference %s", got.Hash, want.Hash))
+		default:
+			merges = append(merges, served{Block: got})
+		}
+	}
+	blocks, err := r.list()
+	if err != nil {
+		return Result{Failures: []string{err.Error()}}
+	}
+	for _, b := range blocks {
+		if parents, _ := b.Refs["merges"].([]interface{}); b.Type == "observe.merge" && len(parents) > 0 && isHash(fmt.Sprint(parents[0])) {
+			merges = append(merges, b)
+		}
+	}
+	if len(merges) == 0 && len(res.Failures) == 0 {
+		return Result{Status: Skip, Detail: "peer serves no merges"}
+	}
+	for _, m := range merges {
+		parents, _ := m.Refs["merges"].([]interface{})
+		if len(parents) != 2 {
+			res.Failures = append(res.Failures, fmt.Sprintf("merge %s names %d forks", m.Hash, len(parents)))
+			continue
+		}
+		var forks [2]*served
+		for i, p := range parents {
+			h, _ := p.(string)
+			if forks[i] = r.block(h); forks[i] == nil {
+				res.Failures = append(res.Failures, fmt.Sprintf("merge %s: fork %v does not resolve", m.Hash, p))
+			}
+		}
+		winner := -1
+		switch m.State["strategy"] {
+		case "a_wins":
+			winner = 0
+		case "b_wins":
+			winner = 1
+		case "manual", "auto":
+		default:
+			res.Failures = append(res.Failures, fmt.Sprintf("merge %s: unknown strategy %v", m.Hash, m.State["strategy"]))
+		}
+		if winner >= 0 && forks[winner] != nil {
+			for k, v := range forks[winner].State {
+				if !reflect.DeepEqual(m.State[k], v) {
+					res.Failures = append(res.Failures, fmt.Sprintf("merge %s: %s is %v, winning fork has %v", m.Hash, k, m.State[k], v))
+				}
+			}
+		}
+	}
+	res.Detail = fmt.Sprintf("%d merges", len(merges))
+	return res
+}
+
+// list fetches up to Sample blocks from /blocks once.
+func (r *runner) list() ([]served, error) {
+	if r.listed {
+		return r.sample, nil
+	}
+	var page struct {
+		Blocks []served `json:"blocks"`
+	}
+	status, err := r.get(fmt.Sprintf("/blocks?limit=%d", r.opts.Sample), &page)
+	if err != nil || status != http.StatusOK {
+		return nil, fmt.Errorf("GET /blocks: %s", describe(status, err))
+	}
+	r.sample, r.listed = page.Blocks, true
+	return r.sample, nil
+}
+
+// block fetches a block by hash, remembering the answer.
+func (r *runner) block(hash string) *served {
+	if b, ok := r.blocks[hash]; ok {
+		return b
+	}
+	var b served
+	status, err := r.get("/blocks/"+url.PathEscape(hash), &b)
+	if err != nil || status != http.StatusOK {
+		r.blocks[hash] = nil
+		return nil
+	}
+	r.blocks[hash] = &b
+	return &b
+}
+
+func (r *runner) get(path string, v interface{}) (int, error) {
+	req, err := http.NewRequestWithContext(r.ctx, http.MethodGet, r.base+path, nil)
+	if err != nil {
+		return 0, err
+	}
+	return r.do(req, v)
+}
+
+// post creates a block through POST /blocks. Peers answer 201 with the block
+// or 200 with {exists, block} when they already hold it.
+func (r *runner) post(body interface{}) (foodblock.Block, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return foodblock.Block{}, err
+	}
+	req, err := http.NewRequestWithContext(r.ctx, http.MethodPost, r.base+"/blocks", bytes.NewReader(data))
+	if err != nil {
+		return foodblock.Block{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	var out struct {
+		foodblock.Block
+		Exists   bool             `json:"exists"`
+		Existing *foodblock.Block `json:"block"`
+	}
+	status, err := r.do(req, &out)
+	if err != nil || status/100 != 2 {
+		return foodblock.Block{}, &postError{status, describe(status, err)}
+	}
+	if out.Existing != nil {
+		return *out.Existing, nil
+	}
+	return out.Block, nil
+}
+
+func (r *runner) do(req *http.Request, v interface{}) (int, error) {
+	resp, err := r.opts.HTTP.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		io.Copy(io.Discard, resp.Body)
+		return resp.StatusCode, nil
+	}
+	return resp.StatusCode, json.NewDecoder(resp.Body).Decode(v)
+}
+
+// postError is a failed POST /blocks.
+type postError struct {
+	status int
+	msg    string
+}
+
+func (e *postError) Error() string { return "POST /blocks: " + e.msg }
+
+// statusOf returns the status a failed post was answered with.
+func statusOf(err error) int {
+	if e, ok := err.(*postError); ok {
+		return e.status
+	}
+	return 0
+}
+
+// isHash reports whether a ref is a block hash rather than a placeholder,
+// as the vectors use.
+func isHash(s string) bool {
+	_, err := hex.DecodeString(s)
+	return len(s) == 64 && err == nil
+}
+
+func describe(status int, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("status %d", status)
+}
@@ -0,0 +1,139 @@
+package conformance
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	foodblock "github.com/FoodXDevelopment/foodblock/sdk/go"
+)
+
+const vectorsPath = "../../../test/vectors.json"
+
+var (
+	peer  = flag.String("peer", "", "base URL of a peer to check")
+	write = flag.Bool("write", false, "seed fixtures on the peer through POST /blocks")
+)
+
+// writable serves a store over the federation API plus POST /blocks,
+// verifying signed wrappers and erasing tombstone targets.
+func writable(store *foodblock.MemoryStore) http.Handler {
+	fed := foodblock.FederationHandler(store, foodblock.WellKnownInfo{Name: "reference"})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/blocks" {
+			fed.ServeHTTP(w, r)
+			return
+		}
+		var body struct {
+			foodblock.Block
+			Wrapped    *foodblock.Block `json:"foodblock"`
+			AuthorHash string           `json:"author_hash"`
+			Signature  string           `json:"signature"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		b := body.Block
+		if body.Wrapped != nil {
+			b = *body.Wrapped
+			var pub []byte
+			if author := store.Resolve(body.AuthorHash); author != nil {
+				key, _ := author.State["public_key"].(string)
+				pub, _ = hex.DecodeString(key)
+			}
+			if !foodblock.Verify(foodblock.SignedBlock{FoodBlock: b, Signature: body.Signature}, pub) {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+		}
+		block := foodblock.Create(b.Type, b.State, b.Refs)
+		store.Put(block)
+		if block.Type == "observe.tombstone" {
+			store.Stub(block.Refs["target"].(string), map[string]interface{}{"tombstoned": true})
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(block)
+	})
+}
+
+func loadVectors(t *testing.T) []Vector {
+	t.Helper()
+	vectors, err := LoadVectors(vectorsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return vectors
+}
+
+func TestReferencePeer(t *testing.T) {
+	vectors := loadVectors(t)
+	store := foodblock.NewMemoryStore()
+	for _, v := range vectors[:10] {
+		store.Put(foodblock.Create(v.Type, v.State, v.Refs))
+	}
+	v1 := foodblock.Create("substance.product", map[string]interface{}{"name": "Rye", "price": 4.0}, nil)
+	a := foodblock.Update(v1.Hash, v1.Type, map[string]interface{}{"name": "Rye", "price": 4.2}, nil)
+	b := foodblock.Update(v1.Hash, v1.Type, map[string]interface{}{"name": "Rye", "price": 4.4}, nil)
+	gone := foodblock.Create("actor.producer", map[string]interface{}{"name": "Gone"}, nil)
+	store.PutAll([]foodblock.Block{v1, a, b, gone})
+	merge, _ := foodblock.Merge(a.Hash, b.Hash, store.Resolve, "b_wins", nil)
+	store.PutAll([]foodblock.Block{merge, foodblock.Tombstone(gone.Hash, gone.Hash)})
+	store.Stub(gone.Hash, map[string]interface{}{"tombstoned": true})
+
+	srv := httptest.NewServer(foodblock.FederationHandler(store, foodblock.WellKnownInfo{Name: "reference"}))
+	defer srv.Close()
+	report := Run(context.Background(), Options{BaseURL: srv.URL, Vectors: vectors})
+	if !report.OK() {
+		t.Fatal(report)
+	}
+	status := map[string]string{}
+	for _, res := range report.Results {
+		status[res.Name] = res.Status
+	}
+	if status["signatures"] != Skip || status["vectors"] != Pass || status["tombstones"] != Pass || status["merges"] != Pass || status["update-chains"] != Pass {
+		t.Errorf("statuses = %v\n%s", status, report)
+	}
+}
+
+func TestWritablePeer(t *testing.T) {
+	srv := httptest.NewServer(writable(foodblock.NewMemoryStore()))
+	defer srv.Close()
+	report := Run(context.Background(), Options{BaseURL: srv.URL, Vectors: loadVectors(t), Write: true})
+	if !report.OK() || report.Skipped != 0 {
+		t.Fatal(report)
+	}
+}
+
+func TestBrokenPeer(t *testing.T) {
+	store := foodblock.NewMemoryStore()
+	target := foodblock.Create("actor.producer", map[string]interface{}{"name": "Kept"}, nil)
+	store.PutAll([]foodblock.Block{target, foodblock.Tombstone(target.Hash, target.Hash)})
+	forged := foodblock.Create("substance.product", map[string]interface{}{"name": "Forged"}, nil)
+	store.Put(forged)
+	store.Stub(forged.Hash, map[string]interface{}{"name": "Altered"})
+
+	srv := httptest.NewServer(foodblock.FederationHandler(store, foodblock.WellKnownInfo{Name: "broken"}))
+	defer srv.Close()
+	report := Run(context.Background(), Options{BaseURL: srv.URL})
+	failed := map[string]bool{}
+	for _, res := range report.Results {
+		failed[res.Name] = res.Status == Fail
+	}
+	if report.OK() || !failed["served-hashes"] || !failed["tombstones"] || failed["discovery"] {
+		t.Errorf("report:\n%s", report)
+	}
+}
+
+// TestPeer checks an external peer named with -peer.
+func TestPeer(t *testing.T) {
+	if *peer == "" {
+		t.Skip("no -peer given")
+	}
+	report := Run(context.Background(), Options{BaseURL: *peer, Vectors: loadVectors(t), Write: *write})
+	t.Log("\n" + report.String())
+	if !report.OK() {
+		t.Fail()
+	}
+}
@@ -0,0 +1,133 @@
+package foodblock
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// Constraint restricts the values a field accepts beyond its type. Schema
+// fields and vocabulary fields embed it, so Validate and the vocabulary
+// checks enforce the same rules.
+type Constraint struct {
+	Min        *float64               `json:"min,omitempty"`     // numbers, quantity values and array lengths
+	Max        *float64               `json:"max,omitempty"`     // numbers, quantity values and array lengths
+	Pattern    string                 `json:"pattern,omitempty"` // strings, a Go regexp
+	Enum       []interface{}          `json:"enum,omitempty"`
+	Items      *SchemaField           `json:"items,omitempty"`      // array elements
+	Properties map[string]SchemaField `json:"properties,omitempty"` // object fields
+}
+
+// Bound returns a pointer to n, for Constraint.Min and Max.
+func Bound(n float64) *float64 {
+	return &n
+}
+
+// IsZero reports whether the constraint restricts nothing.
+func (c Constraint) IsZero() bool {
+	return c.Min == nil && c.Max == nil && c.Pattern == "" && len(c.Enum) == 0 && c.Items == nil && len(c.Properties) == 0
+}
+
+// toMap renders the constraint as vocabulary block state.
+func (c Constraint) toMap() map[string]interface{} {
+	out := map[string]interface{}{}
+	if c.IsZero() {
+		return out
+	}
+	data, _ := json.Marshal(c)
+	json.Unmarshal(data, &out)
+	return out
+}
+
+var patternCache sync.Map // pattern -> *regexp.Regexp or error
+
+func compilePattern(p string) (*regexp.Regexp, error) {
+	if v, ok := patternCache.Load(p); ok {
+		if re, ok := v.(*regexp.Regexp); ok {
+			return re, nil
+		}
+		return nil, v.(error)
+	}
+	re, err := regexp.Compile(p)
+	if err != nil {
+		patternCache.Store(p, err)
+		return nil, err
+	}
+	patternCache.Store(p, re)
+	return re, nil
+}
+
+// checkConstraint checks a value of the right type against c and returns
+// messages naming it by path, such as "state.price".
+func checkConstraint(path string, c Constraint, v interface{}) []string {
+	var errs []string
+	size, sized := numericValue(v)
+	what := ""
+	if arr, ok := v.([]interface{}); ok {
+		size, sized, what = float64(len(arr)), true, " items"
+	}
+	if sized {
+		if c.Min != nil && size < *c.Min {
+			errs = append(errs, fmt.Sprintf("%s should be at least %g%s, got %g", path, *c.Min, what, size))
+		}
+		if c.Max != nil && size > *c.Max {
+			errs = append(errs, fmt.Sprintf("%s should be at most %g%s, got %g", path, *c.Max, what, size))
+		}
+	}
+	if s, ok := v.(string); ok && c.Pattern != "" {
+		if re, err := compilePattern(c.Pattern); err != nil {
+			errs = append(errs, fmt.Sprintf("%s has an invalid pattern: %v", path, err))
+		} else if !re.MatchString(s) {
+			errs = append(errs, fmt.Sprintf("%s %q does not match %s", path, s, c.Pattern))
+		}
+	}
+	if len(c.Enum) > 0 {
+		found := false
+		for _, e := range c.Enum {
+			found = found || sameJSON(e, v)
+		}
+		if !found {
+			errs = append(errs, fmt.Sprintf("%s should be one of %v, got %v", path, c.Enum, v))
+		}
+	}
+	if arr, ok := v.([]interface{}); ok && c.Items != nil {
+		for i, item := range arr {
+			errs = append(errs, checkField(fmt.Sprintf("%s[%d]", path, i), *c.Items, item)...)
+		}
+	}
+	if obj, ok := v.(map[string]interface{}); ok && len(c.Properties) > 0 {
+		names := make([]string, 0, len(c.Properties))
+		for name := range c.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			def := c.Properties[name]
+			val, present := obj[name]
+			if !present {
+				if def.Required {
+					errs = append(errs, fmt.Sprintf("%s.%s is required", path, name))
+				}
+				continue
+			}
+			errs = append(errs, checkField(path+"."+name, def, val)...)
+		}
+	}
+	return errs
+}
+
+// checkField checks a nested value's type and then its constraints.
+func checkField(path string, def SchemaField, v interface{}) []string {
+	if got := goTypeToSchemaType(v); def.Type != "" && got != def.Type {
+		return []string{fmt.Sprintf("%s should be %s, got %s", path, def.Type, got)}
+	}
+	return checkConstraint(path, def.Constraint, v)
+}
+
+func sameJSON(a, b interface{}) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return string(x) == string(y)
+}
@@ -0,0 +1,71 @@
+package foodblock
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateConstraints(t *testing.T) {
+	schema := &Schema{
+		TargetType: "observe.review",
+		Fields: map[string]SchemaField{
+			"rating": {Type: "number", Required: true, Constraint: Constraint{Min: Bound(1), Max: Bound(5)}},
+			"sku":    {Type: "string", Constraint: Constraint{Pattern: `^[A-Z]{2}-\d+$`}},
+			"grade":  {Type: "string", Constraint: Constraint{Enum: []interface{}{"A", "B"}}},
+			"tags":   {Type: "array", Constraint: Constraint{Max: Bound(2), Items: &SchemaField{Type: "string"}}},
+			"dims": {Type: "object", Constraint: Constraint{Properties: map[string]SchemaField{
+				"width": {Type: "number", Required: true, Constraint: Constraint{Min: Bound(0)}},
+			}}},
+		},
+	}
+	valid := Block{Type: "observe.review", State: map[string]interface{}{
+		"rating": 4.0, "sku": "BR-12", "grade": "A", "tags": []interface{}{"rye"}, "dims": map[string]interface{}{"width": 3.0},
+	}}
+	if errs := Validate(valid, schema); len(errs) != 0 {
+		t.Fatalf("valid block: %v", errs)
+	}
+
+	invalid := Block{Type: "observe.review", State: map[string]interface{}{
+		"rating": 7.0, "sku": "br12", "grade": "C", "tags": []interface{}{"rye", 2.0, "spelt"}, "dims": map[string]interface{}{"height": 1.0},
+	}}
+	errs := strings.Join(Validate(invalid, schema), "\n")
+	for _, want := range []string{
+		"state.rating should be at most 5, got 7",
+		`state.sku "br12" does not match`,
+		"state.grade should be one of [A B], got C",
+		"state.tags should be at most 2 items, got 3",
+		"state.tags[1] should be string, got number",
+		"state.dims.width is required",
+	} {
+		if !strings.Contains(errs, want) {
+			t.Errorf("missing %q in:\n%s", want, errs)
+		}
+	}
+}
+
+func TestVocabularyConstraints(t *testing.T) {
+	vocabs := map[string]VocabularyDef{"cold_chain": {
+		Domain:   "cold_chain",
+		ForTypes: []string{"observe.reading"},
+		Fields: map[string]FieldDef{
+			"temperature": {Type: "quantity", Constraint: Constraint{Min: Bound(-30), Max: Bound(8)}},
+			"probe":       {Type: "string", Constraint: Constraint{Pattern: `^P\d{3}$`}},
+		},
+	}}
+	ok := Create("observe.reading", map[string]interface{}{"instance_id": "r1", "temperature": map[string]interface{}{"value": 4.0, "unit": "celsius"}, "probe": "P001"}, nil)
+	warm := Create("observe.reading", map[string]interface{}{"instance_id": "r2", "temperature": 12.5, "probe": "probe-1"}, nil)
+	if issues := vocabularyIssues(ok, vocabs); len(issues) != 0 {
+		t.Errorf("issues = %v", issues)
+	}
+	issues := vocabularyIssues(warm, vocabs)
+	if len(issues) != 2 || !strings.Contains(issues[1], "cold_chain: state.temperature should be at most 8, got 12.5") {
+		t.Errorf("issues = %v", issues)
+	}
+
+	// Constraints travel with vocabulary blocks.
+	vocab := CreateVocabulary("cold_chain", vocabs["cold_chain"].ForTypes, vocabs["cold_chain"].Fields, "")
+	temp := vocab.State["fields"].(map[string]interface{})["temperature"].(map[string]interface{})
+	if temp["min"] != -30.0 || temp["max"] != 8.0 {
+		t.Errorf("temperature entry = %v", temp)
+	}
+}
@@ -0,0 +1,190 @@
+package foodblock
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// ManufacturingType records who makes a product for whom, and where: refs
+// product, brand_owner, manufacturer and facility. Products or lots point
+// back at it with refs.manufacturing so narratives can attribute them.
+const ManufacturingType = "observe.manufacturing"
+
+// Manufacturing is a resolved brand owner / manufacturer / facility triple.
+type Manufacturing struct {
+	Relationship string `json:"relationship,omitempty"`
+	Product      string `json:"product"`
+	BrandOwner   string `json:"brand_owner"`
+	Manufacturer string `json:"manufacturer"`
+	Facility     string `json:"facility,omitempty"`
+}
+
+// CreateManufacturing records that manufacturer makes product for brandOwner
+// at facility. A brand making its own product passes itself as manufacturer.
+func CreateManufacturing(product, brandOwner, manufacturer, facility string, state map[string]interface{}) (Block, error) {
+	if product == "" || brandOwner == "" || manufacturer == "" {
+		return Block{}, errors.New("FoodBlock: manufacturing needs a product, brand owner and manufacturer")
+	}
+	s := map[string]interface{}{"instance_id": "manufacturing-" + product + "-" + manufacturer + "-" + facility}
+	for k, v := range state {
+		s[k] = v
+	}
+	refs := map[string]interface{}{"product": product, "brand_owner": brandOwner, "manufacturer": manufacturer}
+	if facility != "" {
+		refs["facility"] = facility
+	}
+	return Create(ManufacturingType, s, refs), nil
+}
+
+// AttachManufacturing updates a product or lot to point at its manufacturing
+// relationship.
+func AttachManufacturing(product, relationship Block) Block {
+	refs := map[string]interface{}{}
+	for k, v := range product.Refs {
+		if k != "updates" {
+			refs[k] = v
+		}
+	}
+	refs["manufacturing"] = relationship.Hash
+	return MergeUpdate(product, nil, refs)
+}
+
+func manufacturingFrom(b Block) Manufacturing {
+	m := Manufacturing{Relationship: b.Hash}
+	m.Product, _ = b.Refs["product"].(string)
+	m.BrandOwner, _ = b.Refs["brand_owner"].(string)
+	m.Manufacturer, _ = b.Refs["manufacturer"].(string)
+	m.Facility, _ = b.Refs["facility"].(string)
+	return m
+}
+
+// ManufacturingOf attributes a block from its refs.manufacturing, or from
+// brand_owner / manufacturer / facility refs carried on the block itself.
+func ManufacturingOf(block Block, resolve func(string) *Block) (Manufacturing, bool) {
+	if h, ok := block.Refs["manufacturing"].(string); ok {
+		if rel := resolve(h); rel != nil && rel.Type == ManufacturingType {
+			return manufacturingFrom(*rel), true
+		}
+	}
+	m := manufacturingFrom(block)
+	m.Relationship, m.Product = "", block.Hash
+	if m.Manufacturer == "" {
+		return Manufacturing{}, false
+	}
+	if m.BrandOwner == "" {
+		m.BrandOwner = m.Manufacturer
+	}
+	return m, true
+}
+
+// Describe renders the attribution as "Made by X for Y at Z.", dropping
+// "for Y" when the brand owner makes its own product.
+func (m Manufacturing) Describe(resolve func(string) *Block) string {
+	name := func(h string) string {
+		if b := resolve(h); b != nil {
+			if n, ok := b.State["name"].(string); ok && n != "" {
+				return n
+			}
+		}
+		return h
+	}
+	s := "Made by " + name(m.Manufacturer)
+	if m.BrandOwner != "" && m.BrandOwner != m.Manufacturer {
+		s += " for " + name(m.BrandOwner)
+	}
+	if m.Facility != "" {
+		s += " at " + name(m.Facility)
+	}
+	return s + "."
+}
+
+// ManufacturingFor returns every manufacturing relationship for any version
+// of a product.
+func ManufacturingFor(productHash string, store BlockStore) []Manufacturing {
+	var out []Manufacturing
+	seen := map[string]bool{}
+	for _, v := range Chain(productHash, store.Resolve, 0) {
+		for _, b := range store.ResolveForward(v.Hash) {
+			if b.Type == ManufacturingType && b.Refs["product"] == v.Hash && !seen[b.Hash] {
+				seen[b.Hash] = true
+				out = append(out, manufacturingFrom(b))
+			}
+		}
+	}
+	return out
+}
+
+// FacilityProducts returns every manufacturing relationship at a facility.
+func FacilityProducts(facility string, store BlockStore) []Manufacturing {
+	var out []Manufacturing
+	for _, b := range store.ResolveForward(facility) {
+		if b.Type == ManufacturingType && b.Refs["facility"] == facility {
+			out = append(out, manufacturingFrom(b))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Relationship < out[j].Relationship })
+	return out
+}
+
+// FacilityRecall is a recall widened to every product packed at the
+// facilities that made the source.
+type FacilityRecall struct {
+	Source     string   `json:"source"`
+	Facilities []string `json:"facilities"`
+	Products   []string `json:"products"`
+	Brands     []string `json:"brands"`
+	Affected   []Block  `json:"affected"`
+}
+
+// ExpandRecall finds the facilities that made sourceHash (a product, a lot
+// attributed to one, or a facility itself), every product any brand has
+// packed there, and everything downstream of those products.
+func ExpandRecall(sourceHash string, store BlockStore, maxDepth int) (FacilityRecall, error) {
+	source := store.Resolve(sourceHash)
+	if source == nil {
+		return FacilityRecall{}, errors.New("FoodBlock: block not found: " + sourceHash)
+	}
+	r := FacilityRecall{Source: sourceHash, Facilities: []string{}, Products: []string{}, Brands: []string{}, Affected: []Block{}}
+	add := func(list *[]string, h string) {
+		if h != "" && !containsStr(*list, h) {
+			*list = append(*list, h)
+		}
+	}
+	if strings.HasPrefix(source.Type, "place.") || strings.HasPrefix(source.Type, "actor.") {
+		add(&r.Facilities, source.Hash)
+	}
+	if m, ok := ManufacturingOf(*source, store.Resolve); ok {
+		add(&r.Facilities, m.Facility)
+	}
+	for _, m := range ManufacturingFor(sourceHash, store) {
+		add(&r.Facilities, m.Facility)
+	}
+	if product, ok := source.Refs["product"].(string); ok && source.Type != ManufacturingType {
+		for _, m := range ManufacturingFor(product, store) {
+			add(&r.Facilities, m.Facility)
+		}
+	}
+	for _, f := range r.Facilities {
+		for _, m := range FacilityProducts(f, store) {
+			add(&r.Products, m.Product)
+			add(&r.Brands, m.BrandOwner)
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, p := range r.Products {
+		if b := store.Resolve(p); b != nil && !seen[b.Hash] {
+			seen[b.Hash] = true
+			r.Affected = append(r.Affected, *b)
+		}
+		for _, b := range Recall(p, store.ResolveForward, maxDepth, nil, nil).Affected {
+			if b.Type == ManufacturingType || seen[b.Hash] {
+				continue
+			}
+			seen[b.Hash] = true
+			r.Affected = append(r.Affected, b)
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,62 @@
+package foodblock
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestContractManufacturing(t *testing.T) {
+	brand := Create("actor.brand", map[string]interface{}{"name": "Hillside Granola"}, nil)
+	other := Create("actor.brand", map[string]interface{}{"name": "Morning Co"}, nil)
+	packer := Create("actor.processor", map[string]interface{}{"name": "PackRight Ltd"}, nil)
+	plant := Create("place.facility", map[string]interface{}{"name": "Leeds Plant 2"}, map[string]interface{}{"operator": packer.Hash})
+	elsewhere := Create("place.facility", map[string]interface{}{"name": "York Plant"}, map[string]interface{}{"operator": packer.Hash})
+
+	granola := Create("substance.product", map[string]interface{}{"name": "Honey Granola"}, map[string]interface{}{"seller": brand.Hash})
+	muesli := Create("substance.product", map[string]interface{}{"name": "Bircher Muesli"}, map[string]interface{}{"seller": other.Hash})
+	bars := Create("substance.product", map[string]interface{}{"name": "Oat Bars"}, map[string]interface{}{"seller": other.Hash})
+
+	granolaRel, err := CreateManufacturing(granola.Hash, brand.Hash, packer.Hash, plant.Hash, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	muesliRel, _ := CreateManufacturing(muesli.Hash, other.Hash, packer.Hash, plant.Hash, nil)
+	barsRel, _ := CreateManufacturing(bars.Hash, other.Hash, packer.Hash, elsewhere.Hash, nil)
+	if _, err := CreateManufacturing(granola.Hash, "", packer.Hash, plant.Hash, nil); err == nil {
+		t.Error("expected a missing brand owner to be rejected")
+	}
+	attributed := AttachManufacturing(granola, granolaRel)
+	order := Create("transfer.order", map[string]interface{}{"quantity": 40.0}, map[string]interface{}{"item": muesli.Hash, "buyer": "cafe"})
+
+	store := NewMemoryStore()
+	store.PutAll([]Block{brand, other, packer, plant, elsewhere, granola, muesli, bars, granolaRel, muesliRel, barsRel, attributed, order})
+
+	narrative := Explain(attributed.Hash, store.Resolve, 5)
+	if !strings.Contains(narrative, "Made by PackRight Ltd for Hillside Granola at Leeds Plant 2.") {
+		t.Errorf("expected a co-packing attribution, got %q", narrative)
+	}
+	own := Create("substance.product", map[string]interface{}{"name": "House Loaf"}, map[string]interface{}{"manufacturer": brand.Hash})
+	store.Put(own)
+	if n := Explain(own.Hash, store.Resolve, 5); !strings.Contains(n, "Made by Hillside Granola.") {
+		t.Errorf("expected a self-made attribution, got %q", n)
+	}
+
+	if got := ManufacturingFor(attributed.Hash, store); len(got) != 1 || got[0].Facility != plant.Hash {
+		t.Errorf("expected one relationship at the plant, got %+v", got)
+	}
+
+	recall, err := ExpandRecall(attributed.Hash, store, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recall.Facilities) != 1 || len(recall.Products) != 2 || len(recall.Brands) != 2 {
+		t.Fatalf("expected both brands packed at the plant, got %+v", recall)
+	}
+	hit := map[string]bool{}
+	for _, b := range recall.Affected {
+		hit[b.Hash] = true
+	}
+	if !hit[muesli.Hash] || !hit[order.Hash] || hit[bars.Hash] {
+		t.Errorf("expected muesli and its order recalled but not bars from York, got %v", hit)
+	}
+}
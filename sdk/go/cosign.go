@@ -0,0 +1,92 @@
+package foodblock
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CoSignatureType adds a signature to a block that someone else authored,
+// for blocks that need the agreement of several parties. refs.signs names
+// the block, refs.signer the co-signer, and state.signature is the signer's
+// Ed25519 signature over the block's canonical content prefixed with
+// coSignContext.
+const CoSignatureType = "observe.cosignature"
+
+// coSignContext separates co-signatures from the signatures Sign makes, so
+// a co-signature cannot be passed off as the co-signer authoring the block.
+const coSignContext = "foodblock-cosign\n"
+
+func coSignPayload(target Block) []byte {
+	return []byte(coSignContext + Canonical(target.Type, target.State, target.Refs))
+}
+
+// CoSign creates a co-signature of target by signer.
+func CoSign(target Block, signerHash string, privateKey []byte) Block {
+	sig := ed25519.Sign(ed25519.PrivateKey(privateKey), coSignPayload(target))
+	return Create(CoSignatureType, map[string]interface{}{
+		"signature": hex.EncodeToString(sig),
+	}, map[string]interface{}{"signs": target.Hash, "signer": signerHash})
+}
+
+// VerifyCoSignature checks a co-signature of target against the signer's
+// registered key.
+func VerifyCoSignature(cosig, target Block, keys *KeyRegistry) bool {
+	if cosig.Type != CoSignatureType || cosig.Refs["signs"] != target.Hash {
+		return false
+	}
+	signer, _ := cosig.Refs["signer"].(string)
+	pub, ok := keys.PublicKey(signer)
+	if !ok {
+		return false
+	}
+	sigHex, _ := cosig.State["signature"].(string)
+	sig, err := hex.DecodeString(sigHex)
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(ed25519.PublicKey(pub), coSignPayload(target), sig)
+}
+
+// Signers lists who signed a block: the signer the store recorded through
+// PutSigned, and every co-signer whose co-signature verifies against keys.
+// refs.author is never taken as a signature. A nil registry lists only the
+// recorded signer.
+func Signers(hash string, store BlockStore, keys *KeyRegistry) []string {
+	target := store.Resolve(hash)
+	if target == nil {
+		return nil
+	}
+	var signers []string
+	if signed, ok := store.(interface{ SignerOf(string) string }); ok {
+		if a := signed.SignerOf(hash); a != "" {
+			signers = append(signers, a)
+		}
+	}
+	if keys != nil {
+		for _, b := range store.ResolveForward(hash) {
+			if VerifyCoSignature(b, *target, keys) {
+				signers = appendUnique(signers, firstRef(b.Refs, "signer"))
+			}
+		}
+	}
+	sort.Strings(signers)
+	return signers
+}
+
+// RequireSigners fails unless every one of required has signed the block.
+func RequireSigners(hash string, store BlockStore, keys *KeyRegistry, required []string) error {
+	signers := Signers(hash, store, keys)
+	var missing []string
+	for _, r := range required {
+		if !containsStr(signers, r) {
+			missing = append(missing, r)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("FoodBlock: %s is missing signatures from %s", hash, strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package foodblock
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule yields the run times of a recurring job.
+type Schedule interface {
+	// Next returns the first run time after t, or the zero time if there
+	// is none within five years.
+	Next(t time.Time) time.Time
+}
+
+// scheduleShortcuts are the named schedules ParseSchedule accepts.
+var scheduleShortcuts = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+}
+
+var (
+	cronMonths = map[string]int{"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6, "jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12}
+	cronDays   = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+)
+
+// ParseSchedule parses a five-field cron expression (minute, hour, day of
+// month, month, day of week, with lists, ranges, steps and three-letter
+// names), a shortcut such as "@weekly", or "@every <duration>". Times are
+// matched in the location of the time passed to Next.
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if rest := strings.TrimPrefix(spec, "@every "); rest != spec {
+		d, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil || d < time.Minute {
+			return nil, fmt.Errorf("FoodBlock: invalid schedule %q: @every needs a duration of at least a minute", spec)
+		}
+		return everySchedule(d), nil
+	}
+	if expanded, ok := scheduleShortcuts[strings.ToLower(spec)]; ok {
+		spec = expanded
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("FoodBlock: invalid schedule %q: want 5 fields, got %d", spec, len(fields))
+	}
+	var c cronSchedule
+	var err error
+	for i, f := range []struct {
+		bits     *uint64
+		min, max int
+		names    map[string]int
+	}{
+		{&c.minute, 0, 59, nil},
+		{&c.hour, 0, 23, nil},
+		{&c.dom, 1, 31, nil},
+		{&c.month, 1, 12, cronMonths},
+		{&c.dow, 0, 7, cronDays},
+	} {
+		if *f.bits, err = parseCronField(fields[i], f.min, f.max, f.names); err != nil {
+			return nil, fmt.Errorf("FoodBlock: invalid schedule %q: %w", spec, err)
+		}
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1 // 7 is Sunday too
+	}
+	c.domAny, c.dowAny = fields[2] == "*", fields[4] == "*"
+	return c, nil
+}
+
+func parseCronField(field string, min, max int, names map[string]int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("bad step in %q", part)
+			}
+			step, part = n, part[:i]
+		}
+		lo, hi := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if lo, err = cronValue(bounds[0], names); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = cronValue(bounds[1], names); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q is outside %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func cronValue(s string, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("bad value %q", s)
+	}
+	return v, nil
+}
+
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+}
+
+func (c cronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		y, m, d := t.Date()
+		switch {
+		case c.month&(1<<uint(m)) == 0:
+			t = time.Date(y, m+1, 1, 0, 0, 0, 0, loc)
+		case !c.dayMatches(t):
+			t = time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(y, m, d, t.Hour()+1, 0, 0, 0, loc)
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches follows cron: when both day fields are restricted, either may
+// match.
+func (c cronSchedule) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dow
+	case c.dowAny:
+		return dom
+	}
+	return dom || dow
+}
+
+type everySchedule time.Duration
+
+func (e everySchedule) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e)).Truncate(time.Minute)
+}
@@ -0,0 +1,38 @@
+package foodblock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	from := time.Date(2026, 6, 3, 10, 17, 30, 0, time.UTC) // a Wednesday
+	for _, tc := range []struct {
+		spec string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2026, 6, 3, 10, 30, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 6, 3, 11, 0, 0, 0, time.UTC)},
+		{"0 8 * * mon", time.Date(2026, 6, 8, 8, 0, 0, 0, time.UTC)},
+		{"30 6 1,15 * *", time.Date(2026, 6, 15, 6, 30, 0, 0, time.UTC)},
+		{"0 9 * feb-mar 7", time.Date(2027, 2, 7, 9, 0, 0, 0, time.UTC)},
+		{"0 0 13 * fri", time.Date(2026, 6, 5, 0, 0, 0, 0, time.UTC)}, // either day field
+		{"@every 90m", time.Date(2026, 6, 3, 11, 47, 0, 0, time.UTC)},
+	} {
+		s, err := ParseSchedule(tc.spec)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.spec, err)
+		}
+		if got := s.Next(from); !got.Equal(tc.want) {
+			t.Errorf("%s: next = %v, want %v", tc.spec, got, tc.want)
+		}
+	}
+	if s, _ := ParseSchedule("0 0 30 2 *"); !s.Next(from).IsZero() {
+		t.Error("February 30th should never run")
+	}
+	for _, bad := range []string{"* * * *", "60 * * * *", "0 0 * * funday", "*/0 * * * *", "@every 10s"} {
+		if _, err := ParseSchedule(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package foodblock
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Attachment points at a file kept outside the graph, such as a photo,
+// pinning its content by hash.
+type Attachment struct {
+	URI       string `json:"uri"`
+	MediaType string `json:"media_type"`
+	SHA256    string `json:"sha256"`
+}
+
+// NewAttachment describes content stored at uri.
+func NewAttachment(uri, mediaType string, content []byte) Attachment {
+	sum := sha256.Sum256(content)
+	return Attachment{URI: uri, MediaType: mediaType, SHA256: hex.EncodeToString(sum[:])}
+}
+
+// Matches reports whether content is the attached file.
+func (a Attachment) Matches(content []byte) bool {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]) == a.SHA256
+}
+
+// HandoverDetails are what the parties record when custody changes hands.
+type HandoverDetails struct {
+	Place     string      // place.* block where the handover happened
+	Condition string      // e.g. "good", "damaged"
+	Notes     string      // free text on the state of the goods
+	Photo     *Attachment // optional photo of the load
+}
+
+// RecordHandover records custody of a shipment passing from one actor to
+// another with the place and the condition of the goods. Both parties sign
+// it: the giver as author and the receiver with CoSign, which
+// CustodyTimeline checks.
+func RecordHandover(shipment, from, to string, at time.Time, d HandoverDetails) (Block, error) {
+	if shipment == "" || from == "" || to == "" {
+		return Block{}, errors.New("FoodBlock: a handover needs a shipment, a giver and a receiver")
+	}
+	if from == to {
+		return Block{}, errors.New("FoodBlock: a handover needs two different parties")
+	}
+	h := Handover(shipment, from, to, at)
+	state := map[string]interface{}{}
+	for k, v := range h.State {
+		state[k] = v
+	}
+	if d.Condition != "" {
+		state["condition"] = d.Condition
+	}
+	if d.Notes != "" {
+		state["notes"] = d.Notes
+	}
+	if d.Photo != nil {
+		state["photo"] = map[string]interface{}{"uri": d.Photo.URI, "media_type": d.Photo.MediaType, "sha256": d.Photo.SHA256}
+	}
+	if d.Place != "" {
+		h.Refs["place"] = d.Place
+	}
+	return Create(HandoverType, state, h.Refs), nil
+}
+
+// CustodyEntry is one event on a shipment's timeline. Custodian is who
+// holds the shipment after the event.
+type CustodyEntry struct {
+	At        string   `json:"at"`
+	Kind      string   `json:"kind"` // handover, seal_applied, seal_inspected, seal_broken or reading
+	Block     Block    `json:"block"`
+	Custodian string   `json:"custodian,omitempty"`
+	Signers   []string `json:"signers,omitempty"`   // handovers only
+	CoSigned  bool     `json:"co_signed,omitempty"` // both parties signed the handover
+}
+
+// CustodyTimeline is a shipment's chain of custody.
+type CustodyTimeline struct {
+	Shipment string         `json:"shipment"`
+	Entries  []CustodyEntry `json:"entries"`
+	Gaps     []CustodyGap   `json:"gaps"`
+	Intact   bool           `json:"intact"`
+}
+
+// BuildCustodyTimeline stitches the handovers, seal events and readings
+// (observe.reading with refs.subject or refs.shipment) of every version of
+// a shipment into one timeline. It reports the seal gaps VerifyCustody
+// finds and, as unsigned_handover, handovers that the giver and receiver
+// have not both signed, checked against keys.
+func BuildCustodyTimeline(shipmentHash string, store BlockStore, keys *KeyRegistry) (CustodyTimeline, error) {
+	custody, err := VerifyCustody(shipmentHash, store)
+	if err != nil {
+		return CustodyTimeline{}, err
+	}
+	tl := CustodyTimeline{Shipment: shipmentHash, Entries: []CustodyEntry{}, Gaps: custody.Gaps}
+
+	events := append([]Block{}, custody.Events...)
+	seen := map[string]bool{}
+	for _, b := range Chain(shipmentHash, store.Resolve, 0) {
+		for _, r := range store.ResolveForward(b.Hash) {
+			if r.Type == "observe.reading" && !seen[r.Hash] && (r.Refs["subject"] == b.Hash || r.Refs["shipment"] == b.Hash) {
+				seen[r.Hash] = true
+				events = append(events, r)
+			}
+		}
+	}
+	at := func(b Block) time.Time {
+		t, _ := stateTime(b.State, "at", "timestamp", "date")
+		return t
+	}
+	sort.SliceStable(events, func(i, j int) bool { return at(events[i]).Before(at(events[j])) })
+
+	custodian := ""
+	if s := store.Resolve(shipmentHash); s != nil {
+		custodian = firstRef(s.Refs, "seller", "from")
+	}
+	for _, b := range events {
+		e := CustodyEntry{Block: b}
+		if t := at(b); !t.IsZero() {
+			e.At = t.UTC().Format(time.RFC3339)
+		}
+		switch {
+		case b.Type == HandoverType:
+			e.Kind = "handover"
+			custodian = firstRef(b.Refs, "to")
+			e.Signers = Signers(b.Hash, store, keys)
+			from, to := firstRef(b.Refs, "from"), firstRef(b.Refs, "to")
+			e.CoSigned = containsStr(e.Signers, from) && containsStr(e.Signers, to)
+			if !e.CoSigned {
+				tl.Gaps = append(tl.Gaps, CustodyGap{Kind: "unsigned_handover", At: e.At, Block: b.Hash,
+					Detail: fmt.Sprintf("handover from %s to %s is not signed by both parties", from, to)})
+			}
+		case b.Type == SealBreakType:
+			e.Kind = "seal_broken"
+		case b.Type == SealType && b.State["event"] == "applied":
+			e.Kind = "seal_applied"
+		case b.Type == SealType:
+			e.Kind = "seal_inspected"
+		default:
+			e.Kind = "reading"
+		}
+		e.Custodian = custodian
+		tl.Entries = append(tl.Entries, e)
+	}
+	tl.Intact = len(tl.Gaps) == 0
+	return tl, nil
+}
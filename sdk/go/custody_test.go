@@ -0,0 +1,96 @@
+package foodblock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCustodyTimeline(t *testing.T) {
+	t0 := time.Date(2026, 6, 1, 6, 0, 0, 0, time.UTC)
+	at := func(h int) time.Time { return t0.Add(time.Duration(h) * time.Hour) }
+	farmPub, farmPriv := GenerateKeypair()
+	haulPub, haulPriv := GenerateKeypair()
+	shopPub, _ := GenerateKeypair()
+	farm := Create("actor.producer", map[string]interface{}{"name": "Green Acres"}, nil)
+	haulier := Create("actor.distributor", map[string]interface{}{"name": "Swift Haulage"}, nil)
+	shop := Create("actor.venue", map[string]interface{}{"name": "Corner Shop"}, nil)
+	depot := Create("place.warehouse", map[string]interface{}{"name": "Depot"}, nil)
+	keys := NewKeyRegistry()
+	keys.Register(farm.Hash, farmPub)
+	keys.Register(haulier.Hash, haulPub)
+	keys.Register(shop.Hash, shopPub)
+
+	shipment := Create("transfer.delivery", map[string]interface{}{"instance_id": "ship-1"}, map[string]interface{}{"seller": farm.Hash, "buyer": shop.Hash})
+	photo := NewAttachment("https://example.test/p/1.jpg", "image/jpeg", []byte("jpeg"))
+	first, err := RecordHandover(shipment.Hash, farm.Hash, haulier.Hash, at(1), HandoverDetails{Place: depot.Hash, Condition: "good", Notes: "12 crates", Photo: &photo})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := RecordHandover(shipment.Hash, haulier.Hash, shop.Hash, at(5), HandoverDetails{Condition: "good"})
+
+	store := NewMemoryStore()
+	store.PutAll([]Block{farm, haulier, shop, depot, shipment,
+		ApplySeal(shipment.Hash, "S-1", farm.Hash, at(0)),
+		InspectSeal(shipment.Hash, "S-1", haulier.Hash, at(1), true),
+		Create("observe.reading", map[string]interface{}{"temperature": 4.1, "at": at(3).Format(time.RFC3339)}, map[string]interface{}{"subject": shipment.Hash}),
+		InspectSeal(shipment.Hash, "S-1", shop.Hash, at(5), true),
+	})
+	store.PutSigned(Sign(first, farm.Hash, farmPriv))
+	store.Put(CoSign(first, haulier.Hash, haulPriv))
+	store.PutSigned(Sign(second, haulier.Hash, haulPriv)) // the shop never co-signs
+
+	tl, err := BuildCustodyTimeline(shipment.Hash, store, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []string
+	for _, e := range tl.Entries {
+		kinds = append(kinds, e.Kind)
+	}
+	if len(kinds) != 6 || kinds[0] != "seal_applied" || kinds[1] != "handover" || kinds[3] != "reading" || kinds[4] != "handover" {
+		t.Fatalf("kinds = %v", kinds)
+	}
+	if h := tl.Entries[1]; !h.CoSigned || h.Custodian != haulier.Hash || h.Block.Refs["place"] != depot.Hash || h.Block.State["condition"] != "good" {
+		t.Errorf("first handover = %+v", h)
+	}
+	if tl.Entries[3].Custodian != haulier.Hash || tl.Entries[5].Custodian != shop.Hash {
+		t.Errorf("custodians = %s, %s", tl.Entries[3].Custodian, tl.Entries[5].Custodian)
+	}
+	if tl.Intact || len(tl.Gaps) != 1 || tl.Gaps[0].Kind != "unsigned_handover" || tl.Gaps[0].Block != second.Hash {
+		t.Errorf("gaps = %+v", tl.Gaps)
+	}
+	if err := RequireSigners(second.Hash, store, keys, []string{haulier.Hash, shop.Hash}); err == nil {
+		t.Error("expected the shop's signature to be missing")
+	}
+	// A co-signature made with someone else's key does not verify.
+	if VerifyCoSignature(CoSign(first, shop.Hash, haulPriv), first, keys) {
+		t.Error("co-signature with the wrong key verified")
+	}
+	// A co-signature cannot be lifted into a block signature by the co-signer.
+	cosig := CoSign(first, haulier.Hash, haulPriv)
+	lifted := SignedBlock{FoodBlock: first, AuthorHash: haulier.Hash, Signature: cosig.State["signature"].(string)}
+	if keys.VerifySigned(lifted) {
+		t.Error("co-signature verified as the co-signer's block signature")
+	}
+	// An unsigned handover naming the farm as author is not signed by the farm.
+	forged, _ := RecordHandover(shipment.Hash, farm.Hash, haulier.Hash, at(2), HandoverDetails{Notes: "forged"})
+	forged.Refs["author"] = farm.Hash
+	forged = Create(forged.Type, forged.State, forged.Refs)
+	store.Put(forged)
+	store.Put(CoSign(forged, haulier.Hash, haulPriv))
+	if signers := Signers(forged.Hash, store, keys); len(signers) != 1 || signers[0] != haulier.Hash {
+		t.Errorf("unsigned handover signers = %v", signers)
+	}
+	tl, _ = BuildCustodyTimeline(shipment.Hash, store, keys)
+	for _, e := range tl.Entries {
+		if e.Block.Hash == forged.Hash && e.CoSigned {
+			t.Error("unsigned handover reported as co-signed")
+		}
+	}
+	if !photo.Matches([]byte("jpeg")) || photo.Matches([]byte("png")) {
+		t.Error("attachment hash mismatch")
+	}
+	if _, err := RecordHandover(shipment.Hash, farm.Hash, farm.Hash, at(2), HandoverDetails{}); err == nil {
+		t.Error("expected a handover to oneself to fail")
+	}
+}
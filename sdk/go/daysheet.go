@@ -0,0 +1,249 @@
+package foodblock
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DaySheetTolerance is the largest stock discrepancy, in the stock's own
+// unit, that still reconciles.
+const DaySheetTolerance = 0.001
+
+// DaySheetLine is one product a vendor brought to market.
+type DaySheetLine struct {
+	Stock       string   `json:"stock"`
+	Name        string   `json:"name"`
+	Unit        string   `json:"unit,omitempty"`
+	Brought     float64  `json:"brought"`
+	Price       float64  `json:"price"`
+	PriceBasis  string   `json:"price_basis"` // list_price, product or price_history; empty when unknown
+	Allergens   []string `json:"allergens"`
+	Sold        float64  `json:"sold"`
+	Surplus     float64  `json:"surplus"`
+	Wasted      float64  `json:"wasted"`
+	Takings     float64  `json:"takings"`
+	Discrepancy float64  `json:"discrepancy"` // brought - (sold + surplus + wasted)
+	Status      string   `json:"status"`      // ok, short (stock unaccounted for) or over (more accounted than brought)
+}
+
+// DaySheet is a vendor's sheet for one market day.
+type DaySheet struct {
+	Producer        string              `json:"producer"`
+	ProducerName    string              `json:"producer_name"`
+	Market          string              `json:"market"`
+	MarketName      string              `json:"market_name"`
+	Date            string              `json:"date"`
+	Stall           string              `json:"stall,omitempty"`
+	Lines           []DaySheetLine      `json:"lines"`
+	Allergens       map[string][]string `json:"allergens"` // allergen -> product names
+	ExpectedTakings float64             `json:"expected_takings"`
+	Takings         float64             `json:"takings"`
+	Discrepancies   int                 `json:"discrepancies"`
+	Reconciled      bool                `json:"reconciled"`
+}
+
+// GenerateDaySheet builds a producer's sheet for a market day following the
+// market-day template: the stock they brought (substance blocks sold by the
+// producer that reference the market, or their pitch there, dated that day),
+// expected prices, allergens per product, and an end-of-day reconciliation of
+// sales (transfer.order refs.item), surplus (substance.surplus refs.source) and
+// waste (transform.waste refs.source) against the stock brought.
+func GenerateDaySheet(producerHash, marketHash string, date time.Time, store BlockStore) (DaySheet, error) {
+	if store.Resolve(producerHash) == nil {
+		return DaySheet{}, fmt.Errorf("FoodBlock: producer not found: %s", producerHash)
+	}
+	if store.Resolve(marketHash) == nil {
+		return DaySheet{}, fmt.Errorf("FoodBlock: market not found: %s", marketHash)
+	}
+	day := date.Format("2006-01-02")
+	sheet := DaySheet{
+		Producer: producerHash, ProducerName: blockName(store, producerHash),
+		Market: marketHash, MarketName: blockName(store, marketHash),
+		Date: day, Lines: []DaySheetLine{}, Allergens: map[string][]string{},
+	}
+	sameDay := func(b Block) bool {
+		d, ok := BlockDate(b)
+		return ok && d.Format("2006-01-02") == day
+	}
+
+	anchors := map[string]bool{}
+	for _, p := range activePitches(store.ResolveForward(marketHash)) {
+		if p.Refs["vendor"] == producerHash && p.State["date"] == day {
+			anchors[p.Hash] = true
+			sheet.Stall, _ = p.State["stall_number"].(string)
+		}
+	}
+	var stock []Block
+	for _, b := range FilterBlocks(store.Blocks(), QueryParams{Type: "substance.*", HeadsOnly: true, IncludeExpired: true}) {
+		if b.Type == "substance.surplus" || firstRef(b.Refs, "seller", "producer") != producerHash {
+			continue
+		}
+		pitch, _ := b.Refs["pitch"].(string)
+		market, _ := b.Refs["market"].(string)
+		if (pitch != "" && anchors[pitch]) || (market == marketHash && sameDay(b)) {
+			stock = append(stock, b)
+		}
+	}
+	sort.Slice(stock, func(i, j int) bool { return blockName(store, stock[i].Hash) < blockName(store, stock[j].Hash) })
+
+	prices := priceHistory(store)
+	for _, s := range stock {
+		line := DaySheetLine{Stock: s.Hash, Name: blockName(store, s.Hash), Allergens: []string{}}
+		line.Brought, line.Unit = stockQuantity(s)
+		var product *Block
+		if h, ok := s.Refs["product"].(string); ok {
+			product = store.Resolve(h)
+		}
+		switch p, ok := numericValue(s.State["price"]); {
+		case ok:
+			line.Price, line.PriceBasis = p, "list_price"
+		case product != nil:
+			if p, ok := numericValue(product.State["price"]); ok {
+				line.Price, line.PriceBasis = p, "product"
+			}
+		}
+		if line.PriceBasis == "" {
+			if p, _, ok := prices.at(s.Hash, &s, date); ok {
+				line.Price, line.PriceBasis = p, "price_history"
+			}
+		}
+		line.Allergens = blockAllergens(s)
+		if len(line.Allergens) == 0 && product != nil {
+			line.Allergens = blockAllergens(*product)
+		}
+		for _, a := range line.Allergens {
+			sheet.Allergens[a] = append(sheet.Allergens[a], line.Name)
+		}
+
+		for _, b := range store.ResolveForward(s.Hash) {
+			switch {
+			case strings.HasPrefix(b.Type, "transfer.order") && containsStr(flattenRefValues(map[string]interface{}{"i": b.Refs["item"], "is": b.Refs["items"]}), s.Hash):
+				if b.State["status"] == "cancelled" || !headOf(b, store) {
+					continue
+				}
+				qty, _ := stockQuantity(b)
+				if qty == 0 {
+					qty = 1
+				}
+				line.Sold += qty
+				if total, ok := blockAmount(b); ok {
+					line.Takings += total
+				} else {
+					line.Takings += qty * line.Price
+				}
+			case b.Type == "substance.surplus" && b.Refs["source"] == s.Hash && headOf(b, store):
+				qty, _ := stockQuantity(b)
+				line.Surplus += qty
+			case b.Type == WasteType && b.Refs["source"] == s.Hash:
+				qty, _ := stockQuantity(b)
+				line.Wasted += qty
+			}
+		}
+		line.Takings = roundCents(line.Takings)
+		line.Discrepancy = round3(line.Brought - line.Sold - line.Surplus - line.Wasted)
+		switch {
+		case math.Abs(line.Discrepancy) <= DaySheetTolerance:
+			line.Status = "ok"
+		case line.Discrepancy > 0:
+			line.Status = "short"
+		default:
+			line.Status = "over"
+		}
+		if line.Status != "ok" {
+			sheet.Discrepancies++
+		}
+		sheet.ExpectedTakings += line.Sold * line.Price
+		sheet.Takings += line.Takings
+		sheet.Lines = append(sheet.Lines, line)
+	}
+	for a := range sheet.Allergens {
+		sort.Strings(sheet.Allergens[a])
+	}
+	sheet.ExpectedTakings = roundCents(sheet.ExpectedTakings)
+	sheet.Takings = roundCents(sheet.Takings)
+	sheet.Reconciled = sheet.Discrepancies == 0
+	return sheet, nil
+}
+
+// headOf reports whether b is the latest version of its update chain.
+func headOf(b Block, store BlockStore) bool {
+	for _, next := range store.ResolveForward(b.Hash) {
+		if next.Refs["updates"] == b.Hash {
+			return false
+		}
+	}
+	return true
+}
+
+// stockQuantity reads state.quantity (a number or {value, unit}) and its
+// unit; a count has no unit.
+func stockQuantity(b Block) (float64, string) {
+	v, ok := numericValue(b.State["quantity"])
+	if !ok {
+		return 0, ""
+	}
+	unit := ""
+	if m, ok := b.State["quantity"].(map[string]interface{}); ok {
+		unit, _ = m["unit"].(string)
+	}
+	if unit == "" {
+		unit, _ = b.State["unit"].(string)
+	}
+	return v, unit
+}
+
+// blockAllergens reads state.allergens as a list of names or a compound
+// {allergen: true} map, sorted.
+func blockAllergens(b Block) []string {
+	var out []string
+	switch v := b.State["allergens"].(type) {
+	case []interface{}:
+		for _, a := range v {
+			if s, ok := a.(string); ok && s != "" {
+				out = append(out, strings.ToLower(s))
+			}
+		}
+	case map[string]interface{}:
+		for a, present := range v {
+			if p, ok := present.(bool); ok && p {
+				out = append(out, strings.ToLower(a))
+			}
+		}
+	case string:
+		for _, a := range strings.Split(v, ",") {
+			if a = strings.TrimSpace(a); a != "" {
+				out = append(out, strings.ToLower(a))
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Text renders the day sheet for printing.
+func (s DaySheet) Text() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "DAY SHEET %s — %s at %s", s.Date, s.ProducerName, s.MarketName)
+	if s.Stall != "" {
+		fmt.Fprintf(&sb, ", stall %s", s.Stall)
+	}
+	sb.WriteString("\n\n")
+	fmt.Fprintf(&sb, "  %-24s %8s %8s %8s %8s %8s %8s  %s\n", "Product", "Brought", "Price", "Sold", "Surplus", "Waste", "Diff", "Allergens")
+	for _, l := range s.Lines {
+		fmt.Fprintf(&sb, "  %-24s %8g %8.2f %8g %8g %8g %8g  %s", l.Name, l.Brought, l.Price, l.Sold, l.Surplus, l.Wasted, l.Discrepancy, strings.Join(l.Allergens, ", "))
+		if l.Status != "ok" {
+			fmt.Fprintf(&sb, "  [%s]", strings.ToUpper(l.Status))
+		}
+		sb.WriteString("\n")
+	}
+	fmt.Fprintf(&sb, "\nTakings: %.2f (expected %.2f)\n", s.Takings, s.ExpectedTakings)
+	if s.Reconciled {
+		sb.WriteString("Stock reconciled.\n")
+	} else {
+		fmt.Fprintf(&sb, "%d line(s) do not reconcile.\n", s.Discrepancies)
+	}
+	return sb.String()
+}
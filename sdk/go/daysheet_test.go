@@ -0,0 +1,65 @@
+package foodblock
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerateDaySheet(t *testing.T) {
+	day := time.Date(2026, 6, 6, 0, 0, 0, 0, time.UTC)
+	producer := Create("actor.producer", map[string]interface{}{"name": "Hill Farm Bakery"}, nil)
+	market := Create("place.market", map[string]interface{}{"name": "Saturday Market", "pitch_fee": 30.0}, nil)
+	pitch, _ := AllocatePitch(market, producer.Hash, "2026-06-06", "B4", nil)
+	loaf := Create("substance.product", map[string]interface{}{"name": "Sourdough", "price": 4.5, "allergens": map[string]interface{}{"gluten": true, "nuts": false}}, nil)
+
+	bread := Create("substance.product", map[string]interface{}{"name": "Sourdough stock", "quantity": 30.0},
+		map[string]interface{}{"seller": producer.Hash, "pitch": pitch.Hash, "product": loaf.Hash})
+	cake := Create("substance.product", map[string]interface{}{"name": "Walnut cake", "quantity": 8.0, "price": 3.0, "allergens": []interface{}{"Eggs", "Nuts", "Gluten"}, "date": "2026-06-06"},
+		map[string]interface{}{"seller": producer.Hash, "market": market.Hash})
+	lastWeek := Create("substance.product", map[string]interface{}{"name": "Old stock", "quantity": 5.0, "date": "2026-05-30"},
+		map[string]interface{}{"seller": producer.Hash, "market": market.Hash})
+
+	sale := func(item string, qty, total float64, id string) Block {
+		return Create("transfer.order", map[string]interface{}{"instance_id": id, "status": "completed", "quantity": qty, "total": total, "date": "2026-06-06"},
+			map[string]interface{}{"seller": producer.Hash, "item": item})
+	}
+	surplus := Create("substance.surplus", map[string]interface{}{"name": "End of Day Surplus", "quantity": 4.0, "status": "available"},
+		map[string]interface{}{"seller": producer.Hash, "source": bread.Hash})
+	waste, _ := CreateWaste(Waste{Source: bread.Hash, Venue: producer.Hash, Quantity: 1, Unit: "kg", Route: RouteCompost, Date: day})
+
+	store := NewMemoryStore()
+	store.PutAll([]Block{
+		producer, market, pitch, loaf, bread, cake, lastWeek,
+		sale(bread.Hash, 20, 90, "s1"), sale(bread.Hash, 5, 22.5, "s2"), surplus, waste,
+		sale(cake.Hash, 6, 18, "s3"),
+	})
+
+	sheet, err := GenerateDaySheet(producer.Hash, market.Hash, day, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sheet.Lines) != 2 || sheet.Stall != "B4" {
+		t.Fatalf("expected two lines at stall B4, got %+v", sheet)
+	}
+	b := sheet.Lines[0]
+	if b.Name != "Sourdough stock" || b.Price != 4.5 || b.PriceBasis != "product" || b.Sold != 25 || b.Surplus != 4 || b.Wasted != 1 || b.Status != "ok" {
+		t.Errorf("unexpected bread line %+v", b)
+	}
+	c := sheet.Lines[1]
+	if c.Discrepancy != 2 || c.Status != "short" || c.Takings != 18 {
+		t.Errorf("expected two cakes unaccounted for, got %+v", c)
+	}
+	if got := sheet.Allergens["gluten"]; len(got) != 2 {
+		t.Errorf("expected gluten in both products, got %v", sheet.Allergens)
+	}
+	if _, ok := sheet.Allergens["nuts"]; !ok || len(sheet.Allergens["nuts"]) != 1 {
+		t.Errorf("expected nuts only in the cake, got %v", sheet.Allergens)
+	}
+	if sheet.Reconciled || sheet.Discrepancies != 1 || sheet.Takings != 130.5 || sheet.ExpectedTakings != 130.5 {
+		t.Errorf("unexpected totals %+v", sheet)
+	}
+	if text := sheet.Text(); !strings.Contains(text, "[SHORT]") || !strings.Contains(text, "stall B4") {
+		t.Errorf("unexpected text:\n%s", text)
+	}
+}
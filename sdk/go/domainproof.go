@@ -0,0 +1,203 @@
+package foodblock
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DomainProofType records that an actor controls a domain.
+const DomainProofType = "observe.domain_proof"
+
+// Where an authority publishes its binding. The well-known document is
+// {"authorities": [{"actor": "<hash>", "public_key": "<hex>"}]}; the DNS
+// record is a TXT at _foodblock.<domain> of the form
+// "foodblock-authority actor=<hash> key=<hex>".
+const (
+	AuthorityWellKnownPath = "/.well-known/foodblock-authority.json"
+	AuthorityDNSPrefix     = "_foodblock."
+)
+
+// DomainProof is a verified actor-to-domain binding.
+type DomainProof struct {
+	Actor      string    `json:"actor"`
+	Domain     string    `json:"domain"`
+	PublicKey  string    `json:"public_key"`
+	Method     string    `json:"method"` // "well-known" or "dns"
+	VerifiedAt time.Time `json:"verified_at"`
+}
+
+// DomainVerifier checks authority bindings. The zero value uses
+// http.DefaultClient over https and the system resolver.
+type DomainVerifier struct {
+	Client    *http.Client
+	Scheme    string // default "https"
+	LookupTXT func(ctx context.Context, name string) ([]string, error)
+	Keys      *KeyRegistry // when set, the published key must match the registered one
+	Now       func() time.Time
+}
+
+// VerifyAuthorityDomain checks that domain publishes actorHash, first at its
+// well-known URL and then in DNS, using a default DomainVerifier.
+func VerifyAuthorityDomain(actorHash, domain string) (DomainProof, error) {
+	return (&DomainVerifier{}).Verify(context.Background(), actorHash, domain)
+}
+
+// Verify checks that domain publishes actorHash with a public key.
+func (v *DomainVerifier) Verify(ctx context.Context, actorHash, domain string) (DomainProof, error) {
+	domain = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(domain), "."))
+	if actorHash == "" || domain == "" {
+		return DomainProof{}, errors.New("FoodBlock: actor hash and domain are required")
+	}
+	key, method, wkErr := v.wellKnown(ctx, actorHash, domain)
+	if wkErr != nil {
+		var dnsErr error
+		key, method, dnsErr = v.dns(ctx, actorHash, domain)
+		if dnsErr != nil {
+			return DomainProof{}, fmt.Errorf("FoodBlock: %s does not publish %s (well-known: %v; dns: %v)", domain, actorHash, wkErr, dnsErr)
+		}
+	}
+	if _, err := hex.DecodeString(key); err != nil || key == "" {
+		return DomainProof{}, fmt.Errorf("FoodBlock: %s publishes an invalid public key", domain)
+	}
+	if v.Keys != nil {
+		if registered, ok := v.Keys.PublicKey(actorHash); ok {
+			published, _ := hex.DecodeString(key)
+			if !bytes.Equal(registered, published) {
+				return DomainProof{}, fmt.Errorf("FoodBlock: %s publishes a different key for %s", domain, actorHash)
+			}
+		}
+	}
+	now := time.Now
+	if v.Now != nil {
+		now = v.Now
+	}
+	return DomainProof{Actor: actorHash, Domain: domain, PublicKey: strings.ToLower(key), Method: method, VerifiedAt: now().UTC()}, nil
+}
+
+func (v *DomainVerifier) wellKnown(ctx context.Context, actorHash, domain string) (string, string, error) {
+	client := v.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	scheme := v.Scheme
+	if scheme == "" {
+		scheme = "https"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, scheme+"://"+domain+AuthorityWellKnownPath, nil)
+	if err != nil {
+		return "", "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("status %d", resp.StatusCode)
+	}
+	var doc struct {
+		Authorities []struct {
+			Actor     string `json:"actor"`
+			PublicKey string `json:"public_key"`
+		} `json:"authorities"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return "", "", err
+	}
+	for _, a := range doc.Authorities {
+		if a.Actor == actorHash {
+			return a.PublicKey, "well-known", nil
+		}
+	}
+	return "", "", errors.New("actor not listed")
+}
+
+func (v *DomainVerifier) dns(ctx context.Context, actorHash, domain string) (string, string, error) {
+	lookup := v.LookupTXT
+	if lookup == nil {
+		lookup = net.DefaultResolver.LookupTXT
+	}
+	records, err := lookup(ctx, AuthorityDNSPrefix+domain)
+	if err != nil {
+		return "", "", err
+	}
+	for _, r := range records {
+		fields := strings.Fields(r)
+		if len(fields) == 0 || fields[0] != "foodblock-authority" {
+			continue
+		}
+		values := map[string]string{}
+		for _, f := range fields[1:] {
+			if k, val, ok := strings.Cut(f, "="); ok {
+				values[k] = val
+			}
+		}
+		if values["actor"] == actorHash {
+			return values["key"], "dns", nil
+		}
+	}
+	return "", "", errors.New("no matching TXT record")
+}
+
+// CreateDomainProof records a verified binding as an observe.domain_proof
+// about the actor, attested by verifier.
+func CreateDomainProof(proof DomainProof, verifier string) Block {
+	refs := map[string]interface{}{"subject": proof.Actor}
+	if verifier != "" {
+		refs["verifier"] = verifier
+	}
+	return Create(DomainProofType, map[string]interface{}{
+		"domain":      proof.Domain,
+		"public_key":  proof.PublicKey,
+		"method":      proof.Method,
+		"verified_at": proof.VerifiedAt.Format(time.RFC3339),
+	}, refs)
+}
+
+// ProvenDomains returns the domains an actor has observe.domain_proof blocks
+// for.
+func ProvenDomains(actorHash string, blocks []Block) []string {
+	var out []string
+	for _, b := range blocks {
+		if b.Type != DomainProofType || b.Refs["subject"] != actorHash {
+			continue
+		}
+		if d, ok := b.State["domain"].(string); ok && !containsStr(out, d) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// domainCovers reports whether proven is domain or one of its parents.
+func domainCovers(domain, proven string) bool {
+	return proven == domain || strings.HasSuffix(proven, "."+domain)
+}
+
+// ValidateCertificationAuthority checks that a certification's refs.authority
+// has proven a domain listed by its scheme. Certifications whose scheme lists
+// no domains, or that name no registered scheme, pass.
+func ValidateCertificationAuthority(cert Block, blocks []Block) error {
+	id, _ := cert.State["scheme"].(string)
+	scheme, ok := CertSchemes[id]
+	if !ok || len(scheme.AuthorityDomains) == 0 {
+		return nil
+	}
+	authority, _ := cert.Refs["authority"].(string)
+	for _, proven := range ProvenDomains(authority, blocks) {
+		for _, d := range scheme.AuthorityDomains {
+			if domainCovers(d, proven) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("FoodBlock: authority %s has no domain proof for %s (%s)", authority, scheme.Name, strings.Join(scheme.AuthorityDomains, ", "))
+}
@@ -0,0 +1,108 @@
+package foodblock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyAuthorityDomain(t *testing.T) {
+	authority := Create("actor.authority", map[string]interface{}{"name": "Soil Association"}, nil)
+	keyHex := strings.Repeat("ab", 32)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != AuthorityWellKnownPath {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"authorities": []map[string]string{{"actor": authority.Hash, "public_key": keyHex}},
+		})
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+	now := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	noDNS := func(ctx context.Context, name string) ([]string, error) { return nil, errors.New("no such host") }
+
+	v := &DomainVerifier{Scheme: "http", LookupTXT: noDNS, Now: func() time.Time { return now }}
+	proof, err := v.Verify(context.Background(), authority.Hash, host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proof.Method != "well-known" || proof.PublicKey != keyHex || !proof.VerifiedAt.Equal(now) {
+		t.Errorf("unexpected proof %+v", proof)
+	}
+	if _, err := v.Verify(context.Background(), "impostor", host); err == nil {
+		t.Error("an unlisted actor should fail verification")
+	}
+
+	keys := NewKeyRegistry()
+	keys.RegisterHex(authority.Hash, strings.Repeat("cd", 32))
+	v.Keys = keys
+	if _, err := v.Verify(context.Background(), authority.Hash, host); err == nil {
+		t.Error("expected a key mismatch error")
+	}
+
+	// Without a well-known document the DNS TXT record is used.
+	notFound := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: http.NoBody, Request: r}, nil
+	})
+	dns := &DomainVerifier{Client: &http.Client{Transport: notFound}, LookupTXT: func(ctx context.Context, name string) ([]string, error) {
+		if name != "_foodblock.usda.gov" {
+			return nil, errors.New("no such host")
+		}
+		return []string{"v=spf1 -all", "foodblock-authority actor=" + authority.Hash + " key=" + keyHex}, nil
+	}}
+	proof, err = dns.Verify(context.Background(), authority.Hash, "USDA.gov.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proof.Method != "dns" || proof.Domain != "usda.gov" {
+		t.Errorf("unexpected dns proof %+v", proof)
+	}
+	if _, err := dns.Verify(context.Background(), authority.Hash, "example.org"); err == nil {
+		t.Error("expected failure when neither method publishes the actor")
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestDomainProofInTrustAndCertification(t *testing.T) {
+	authority := trustActor("Soil Association")
+	impostor := trustActor("Soil Association Ltd")
+	farm := trustActor("Farm")
+	cert := func(by TrustBlock, id string) TrustBlock {
+		return TrustBlock{Block: Create("observe.certification", map[string]interface{}{
+			"instance_id": id, "name": "Organic", "scheme": "soil_association", "certificate_id": "P1234", "valid_until": "2099-01-01",
+		}, map[string]interface{}{"subject": farm.Hash, "authority": by.Hash})}
+	}
+	real, fake := cert(authority, "c1"), cert(impostor, "c2")
+	proof := CreateDomainProof(DomainProof{Actor: authority.Hash, Domain: "www.soilassociation.org", PublicKey: "ab", Method: "dns",
+		VerifiedAt: time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)}, "")
+	blocks := []TrustBlock{authority, impostor, farm, real, fake, {Block: proof}}
+
+	if got := ComputeTrust(farm.Hash, blocks, nil).Inputs.AuthorityCerts; got != 2 {
+		t.Errorf("without the policy both certificates count, got %d", got)
+	}
+	if got := ComputeTrust(farm.Hash, blocks, map[string]interface{}{"require_domain_proof": true}).Inputs.AuthorityCerts; got != 1 {
+		t.Errorf("only the domain-proven authority should count, got %d", got)
+	}
+
+	plain := []Block{proof}
+	if err := ValidateCertificationAuthority(real.Block, plain); err != nil {
+		t.Errorf("proven authority rejected: %v", err)
+	}
+	if err := ValidateCertificationAuthority(fake.Block, plain); err == nil {
+		t.Error("unproven authority accepted")
+	}
+	if got := ProvenDomains(authority.Hash, plain); len(got) != 1 || got[0] != "www.soilassociation.org" {
+		t.Errorf("unexpected proven domains %v", got)
+	}
+}
@@ -1,31 +1,125 @@
 package foodblock
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
 )
 
+// Envelope algorithms. EnvelopeAlg derives each recipient's wrapping key
+// from the X25519 shared secret with HKDF-SHA256 and binds the envelope's
+// context and bound hash; EnvelopeAlgLegacy uses the shared secret as the
+// key directly. Encrypt writes EnvelopeAlg; Decrypt reads both, and
+// EnvelopeAlgLegacy is written only when asked for, for readers that do not
+// support EnvelopeAlg yet.
+const (
+	EnvelopeAlg       = "x25519-hkdf-sha256-aes-256-gcm"
+	EnvelopeAlgLegacy = "x25519-aes-256-gcm"
+)
+
+// EnvelopeVersion is the current envelope format, written on every
+// EnvelopeAlg envelope and on compressed or chunked ones. Envelopes without
+// a version are version 1: uncompressed, in a single AEAD message.
+const EnvelopeVersion = 2
+
+// DefaultChunkSize is a suitable EncryptOptions.ChunkSize for large values.
+const DefaultChunkSize = 64 << 10
+
 // EncryptionEnvelope is the encrypted payload per Section 7.2.
 type EncryptionEnvelope struct {
+	Version      int                `json:"version,omitempty"`
 	Alg          string             `json:"alg"`
 	EphemeralKey string             `json:"ephemeral_key"`
 	Recipients   []EncryptRecipient `json:"recipients"`
 	Nonce        string             `json:"nonce"`
-	Ciphertext   string             `json:"ciphertext"`
+	Context      string             `json:"context,omitempty"`     // HKDF info, e.g. "state.allergens"
+	Bound        string             `json:"bound,omitempty"`       // block hash bound as additional data
+	Compression  string             `json:"compression,omitempty"` // an EnvelopeCompressors name
+	ChunkSize    int                `json:"chunk_size,omitempty"`  // set when the ciphertext is chunked
+	Escrow       *EnvelopeEscrow    `json:"escrow,omitempty"`      // content key shares for escrow agents
+	Ciphertext   string             `json:"ciphertext,omitempty"`  // empty when streamed separately
 }
 
-// EncryptRecipient holds a per-recipient encrypted content key.
+// EncryptRecipient holds a per-recipient encrypted content key. Key
+// recipients are found by KeyHash; passphrase recipients have Type
+// "passphrase" and the salt and Argon2id parameters their key derives from.
 type EncryptRecipient struct {
-	KeyHash      string `json:"key_hash"`
-	EncryptedKey string `json:"encrypted_key"`
+	Type         string        `json:"type,omitempty"`
+	KeyHash      string        `json:"key_hash,omitempty"`
+	Salt         string        `json:"salt,omitempty"`
+	Argon2       *Argon2Params `json:"argon2,omitempty"`
+	EncryptedKey string        `json:"encrypted_key"`
+}
+
+// Argon2Params are the Argon2id cost parameters for a passphrase recipient.
+// Memory is in KiB.
+type Argon2Params struct {
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory"`
+	Threads uint8  `json:"threads"`
+}
+
+// DefaultArgon2Params follow the RFC 9106 second recommendation.
+var DefaultArgon2Params = Argon2Params{Time: 3, Memory: 64 << 10, Threads: 4}
+
+// maxChunkSize caps an envelope's chunk_size, which sizes Decrypt's buffer.
+const maxChunkSize = 16 << 20 // 16 MiB
+
+// MaxDecompressedSize caps the plaintext a compressed envelope may expand
+// to, so a small hostile ciphertext cannot decompress without bound.
+var MaxDecompressedSize int64 = 1 << 30 // 1 GiB
+
+// Caps on the work an envelope may ask Decrypt to spend on a passphrase.
+const (
+	maxArgon2Memory  = 1 << 20 // 1 GiB
+	maxArgon2Time    = 16
+	maxArgon2Threads = 16
+)
+
+// EncryptOptions tune the envelope Encrypt produces.
+type EncryptOptions struct {
+	Compression string // an EnvelopeCompressors name, e.g. "gzip"
+	ChunkSize   int    // seal the plaintext in chunks of this size
+	Context     string // what the value is, mixed into key derivation
+	Bind        string // hash of the block the value belongs to, such as its subject
+	Alg         string // EnvelopeAlg or EnvelopeAlgLegacy; see EncryptStream for the default
+
+	// Passphrases adds a passphrase recipient for each, alongside any keys.
+	Passphrases []string
+	Argon2      *Argon2Params // DefaultArgon2Params when nil
+
+	// Escrow splits the content key among escrow agents.
+	Escrow *EscrowPolicy
+}
+
+// Compressor wraps streams for one envelope compression.
+type Compressor struct {
+	Compress   func(w io.Writer) (io.WriteCloser, error)
+	Decompress func(r io.Reader) (io.ReadCloser, error)
+}
+
+// EnvelopeCompressors are the compressions envelopes may use. Register
+// others, such as zstd, by name.
+var EnvelopeCompressors = map[string]Compressor{
+	"gzip": {
+		Compress:   func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil },
+		Decompress: func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+	},
 }
 
 // GenerateEncryptionKeypair generates an X25519 keypair for encryption.
@@ -43,16 +137,74 @@ func GenerateEncryptionKeypair() (publicKeyHex, privateKeyHex string, err error)
 }
 
 // Encrypt encrypts a value for multiple recipients using envelope encryption.
-// Uses X25519 key agreement + AES-256-GCM symmetric encryption.
+// It writes an EnvelopeAlg envelope; for one that readers of the
+// EnvelopeAlgLegacy format only can open, call EncryptWithOptions with Alg
+// set to EnvelopeAlgLegacy. See section 6.4 of spec/implementation-paper.md.
 func Encrypt(value interface{}, recipientPublicKeys []string) (*EncryptionEnvelope, error) {
-	if len(recipientPublicKeys) == 0 {
-		return nil, errors.New("FoodBlock: at least one recipient public key is required")
-	}
+	return EncryptWithOptions(value, recipientPublicKeys, EncryptOptions{})
+}
 
+// EncryptWithPassphrase encrypts a value for whoever knows the passphrase,
+// for recipients without a keypair.
+func EncryptWithPassphrase(value interface{}, passphrase string) (*EncryptionEnvelope, error) {
+	return EncryptWithOptions(value, nil, EncryptOptions{Passphrases: []string{passphrase}})
+}
+
+// EncryptWithOptions is Encrypt with compression, chunking, binding and
+// passphrase recipients.
+func EncryptWithOptions(value interface{}, recipientPublicKeys []string, opts EncryptOptions) (*EncryptionEnvelope, error) {
 	plaintext, err := json.Marshal(value)
 	if err != nil {
 		return nil, err
 	}
+	var ciphertext bytes.Buffer
+	envelope, err := EncryptStream(&ciphertext, bytes.NewReader(plaintext), recipientPublicKeys, opts)
+	if err != nil {
+		return nil, err
+	}
+	envelope.Ciphertext = base64.StdEncoding.EncodeToString(ciphertext.Bytes())
+	return envelope, nil
+}
+
+// EncryptStream encrypts src for the recipients, writing the ciphertext to
+// dst and returning the envelope without it. Unless opts.ChunkSize is set,
+// src is read whole; with it, src is sealed chunk by chunk, so values of any
+// size encrypt in constant memory. Without opts.Alg, envelopes use
+// EnvelopeAlg.
+func EncryptStream(dst io.Writer, src io.Reader, recipientPublicKeys []string, opts EncryptOptions) (*EncryptionEnvelope, error) {
+	if len(recipientPublicKeys) == 0 && len(opts.Passphrases) == 0 {
+		return nil, errors.New("FoodBlock: at least one recipient public key or passphrase is required")
+	}
+	alg := opts.Alg
+	if alg == "" {
+		alg = EnvelopeAlg
+	}
+	if alg != EnvelopeAlg && alg != EnvelopeAlgLegacy {
+		return nil, fmt.Errorf("FoodBlock: unknown envelope alg %s", alg)
+	}
+	if alg == EnvelopeAlgLegacy && (opts.Context != "" || opts.Bind != "" || len(opts.Passphrases) > 0 || opts.Escrow != nil) {
+		return nil, errors.New("FoodBlock: legacy envelopes cannot carry a context, bound hash, passphrase or escrow")
+	}
+	params := DefaultArgon2Params
+	if opts.Argon2 != nil {
+		params = *opts.Argon2
+	}
+	if len(opts.Passphrases) > 0 {
+		if err := params.check(); err != nil {
+			return nil, err
+		}
+	}
+	if opts.ChunkSize > maxChunkSize {
+		return nil, fmt.Errorf("FoodBlock: chunk size %d exceeds %d", opts.ChunkSize, maxChunkSize)
+	}
+	var compressor Compressor
+	if opts.Compression != "" {
+		c, ok := EnvelopeCompressors[opts.Compression]
+		if !ok {
+			return nil, fmt.Errorf("FoodBlock: unknown envelope compression %s", opts.Compression)
+		}
+		compressor = c
+	}
 
 	// Generate random content key (256-bit)
 	contentKey := make([]byte, 32)
@@ -66,17 +218,6 @@ func Encrypt(value interface{}, recipientPublicKeys []string) (*EncryptionEnvelo
 		return nil, err
 	}
 
-	// Encrypt value with content key using AES-256-GCM
-	block, err := aes.NewCipher(contentKey)
-	if err != nil {
-		return nil, err
-	}
-	aead, err := cipher.NewGCM(block)
-	if err != nil {
-		return nil, err
-	}
-	ciphertext := aead.Seal(nil, nonce, plaintext, nil)
-
 	// Generate ephemeral X25519 keypair
 	var ephPriv [32]byte
 	if _, err := rand.Read(ephPriv[:]); err != nil {
@@ -87,6 +228,20 @@ func Encrypt(value interface{}, recipientPublicKeys []string) (*EncryptionEnvelo
 		return nil, err
 	}
 
+	envelope := &EncryptionEnvelope{
+		Alg:          alg,
+		EphemeralKey: hex.EncodeToString(ephPub),
+		Nonce:        base64.StdEncoding.EncodeToString(nonce),
+		Context:      opts.Context,
+		Bound:        opts.Bind,
+		Compression:  opts.Compression,
+		ChunkSize:    opts.ChunkSize,
+	}
+	if alg != EnvelopeAlgLegacy || opts.Compression != "" || opts.ChunkSize > 0 {
+		envelope.Version = EnvelopeVersion
+	}
+	aad := envelopeAAD(envelope)
+
 	// Encrypt content key for each recipient
 	recipients := make([]EncryptRecipient, 0, len(recipientPublicKeys))
 	for _, pubKeyHex := range recipientPublicKeys {
@@ -102,22 +257,10 @@ func Encrypt(value interface{}, recipientPublicKeys []string) (*EncryptionEnvelo
 		}
 
 		// Encrypt content key with shared secret
-		keyNonce := make([]byte, 12)
-		if _, err := rand.Read(keyNonce); err != nil {
-			return nil, err
-		}
-
-		keyBlock, err := aes.NewCipher(sharedSecret)
-		if err != nil {
-			return nil, err
-		}
-		keyAead, err := cipher.NewGCM(keyBlock)
+		encryptedKey, err := wrapContentKey(wrappingKey(envelope, sharedSecret, ephPub, pubKeyBytes), contentKey, aad)
 		if err != nil {
 			return nil, err
 		}
-		encryptedKey := keyAead.Seal(nil, keyNonce, contentKey, nil)
-		// Append nonce to encrypted key (same as JS)
-		encryptedKey = append(encryptedKey, keyNonce...)
 
 		// Compute key_hash
 		keyHashBytes := sha256.Sum256(pubKeyBytes)
@@ -129,17 +272,208 @@ func Encrypt(value interface{}, recipientPublicKeys []string) (*EncryptionEnvelo
 		})
 	}
 
-	return &EncryptionEnvelope{
-		Alg:          "x25519-aes-256-gcm",
-		EphemeralKey: hex.EncodeToString(ephPub),
-		Recipients:   recipients,
-		Nonce:        base64.StdEncoding.EncodeToString(nonce),
-		Ciphertext:   base64.StdEncoding.EncodeToString(ciphertext),
-	}, nil
+	for _, passphrase := range opts.Passphrases {
+		if passphrase == "" {
+			return nil, errors.New("FoodBlock: passphrase is empty")
+		}
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		p := params
+		encryptedKey, err := wrapContentKey(passphraseKey(passphrase, salt, p), contentKey, aad)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, EncryptRecipient{
+			Type:         "passphrase",
+			Salt:         base64.StdEncoding.EncodeToString(salt),
+			Argon2:       &p,
+			EncryptedKey: base64.StdEncoding.EncodeToString(encryptedKey),
+		})
+	}
+
+	envelope.Recipients = recipients
+	if opts.Escrow != nil {
+		if envelope.Escrow, err = escrowContentKey(envelope, contentKey, ephPriv[:], *opts.Escrow); err != nil {
+			return nil, err
+		}
+	}
+	aead, err := newGCM(contentKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// Compression runs ahead of encryption through a pipe.
+	plain := src
+	if compressor.Compress != nil {
+		pr, pw := io.Pipe()
+		go func() {
+			zw, err := compressor.Compress(pw)
+			if err == nil {
+				_, err = io.Copy(zw, src)
+				if cerr := zw.Close(); err == nil {
+					err = cerr
+				}
+			}
+			pw.CloseWithError(err)
+		}()
+		defer pr.Close()
+		plain = pr
+	}
+
+	if opts.ChunkSize <= 0 {
+		data, err := io.ReadAll(plain)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := dst.Write(aead.Seal(nil, nonce, data, aad)); err != nil {
+			return nil, err
+		}
+		return envelope, nil
+	}
+	if err := sealChunks(dst, plain, aead, nonce, aad, opts.ChunkSize); err != nil {
+		return nil, err
+	}
+	return envelope, nil
 }
 
-// Decrypt decrypts an encryption envelope.
+// Decrypt decrypts an encryption envelope. Envelopes of either algorithm
+// decrypt; a bound hash is checked as additional data but not compared with
+// anything, for which see DecryptBound.
 func Decrypt(envelope *EncryptionEnvelope, privateKeyHex, publicKeyHex string) (interface{}, error) {
+	ciphertextBuf, err := base64.StdEncoding.DecodeString(envelope.Ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	var plaintext bytes.Buffer
+	if err := DecryptStream(&plaintext, bytes.NewReader(ciphertextBuf), envelope, privateKeyHex, publicKeyHex); err != nil {
+		return nil, err
+	}
+
+	var result interface{}
+	if err := json.Unmarshal(plaintext.Bytes(), &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// DecryptWithPassphrase decrypts an envelope through one of its
+// passphrase recipients.
+func DecryptWithPassphrase(envelope *EncryptionEnvelope, passphrase string) (interface{}, error) {
+	if err := checkEnvelope(envelope); err != nil {
+		return nil, err
+	}
+	contentKey, err := openPassphraseKey(envelope, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return decryptWithContentKey(envelope, contentKey)
+}
+
+// decryptWithContentKey decrypts an envelope's inline ciphertext once its
+// content key is known.
+func decryptWithContentKey(envelope *EncryptionEnvelope, contentKey []byte) (interface{}, error) {
+	ciphertextBuf, err := base64.StdEncoding.DecodeString(envelope.Ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	var plaintext bytes.Buffer
+	if err := decryptContent(&plaintext, bytes.NewReader(ciphertextBuf), envelope, contentKey); err != nil {
+		return nil, err
+	}
+	var result interface{}
+	if err := json.Unmarshal(plaintext.Bytes(), &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// DecryptBound decrypts an envelope that must be bound to the given block
+// hash, so an envelope copied from another block is refused.
+func DecryptBound(envelope *EncryptionEnvelope, bound, privateKeyHex, publicKeyHex string) (interface{}, error) {
+	if envelope.Bound != bound {
+		return nil, fmt.Errorf("FoodBlock: envelope is bound to %q, not %q", envelope.Bound, bound)
+	}
+	return Decrypt(envelope, privateKeyHex, publicKeyHex)
+}
+
+// DecryptStream decrypts ciphertext read from src, as written by
+// EncryptStream, into dst. Chunked ciphertext is decrypted chunk by chunk;
+// a stream cut short fails rather than yielding a prefix.
+func DecryptStream(dst io.Writer, src io.Reader, envelope *EncryptionEnvelope, privateKeyHex, publicKeyHex string) error {
+	if err := checkEnvelope(envelope); err != nil {
+		return err
+	}
+	contentKey, err := openContentKey(envelope, privateKeyHex, publicKeyHex)
+	if err != nil {
+		return err
+	}
+	return decryptContent(dst, src, envelope, contentKey)
+}
+
+func checkEnvelope(envelope *EncryptionEnvelope) error {
+	if envelope.Version > EnvelopeVersion {
+		return fmt.Errorf("FoodBlock: unsupported envelope version %d", envelope.Version)
+	}
+	if envelope.Alg != EnvelopeAlg && envelope.Alg != EnvelopeAlgLegacy {
+		return fmt.Errorf("FoodBlock: unknown envelope alg %s", envelope.Alg)
+	}
+	if envelope.ChunkSize < 0 || envelope.ChunkSize > maxChunkSize {
+		return fmt.Errorf("FoodBlock: envelope chunk size %d is out of range", envelope.ChunkSize)
+	}
+	return nil
+}
+
+// decryptContent decrypts the ciphertext under an opened content key.
+func decryptContent(dst io.Writer, src io.Reader, envelope *EncryptionEnvelope, contentKey []byte) error {
+	aad := envelopeAAD(envelope)
+	contentNonce, err := base64.StdEncoding.DecodeString(envelope.Nonce)
+	if err != nil {
+		return err
+	}
+	contentAead, err := newGCM(contentKey)
+	if err != nil {
+		return err
+	}
+
+	if envelope.Compression == "" {
+		return openContent(dst, src, envelope.ChunkSize, contentAead, contentNonce, aad)
+	}
+	c, ok := EnvelopeCompressors[envelope.Compression]
+	if !ok {
+		return fmt.Errorf("FoodBlock: unknown envelope compression %s", envelope.Compression)
+	}
+
+	// Decompression runs behind decryption through a pipe.
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		zr, err := c.Decompress(pr)
+		if err == nil {
+			var n int64
+			n, err = io.Copy(dst, io.LimitReader(zr, MaxDecompressedSize+1))
+			if err == nil && n > MaxDecompressedSize {
+				err = fmt.Errorf("plaintext exceeds %d bytes", MaxDecompressedSize)
+			}
+			zr.Close()
+		}
+		if err != nil {
+			err = fmt.Errorf("FoodBlock: failed to decompress plaintext: %w", err)
+		}
+		pr.CloseWithError(err)
+		done <- err
+	}()
+	err = openContent(pw, src, envelope.ChunkSize, contentAead, contentNonce, aad)
+	pw.CloseWithError(err)
+	if zerr := <-done; err == nil {
+		err = zerr
+	}
+	return err
+}
+
+// openContentKey finds this recipient's entry and decrypts the content key.
+func openContentKey(envelope *EncryptionEnvelope, privateKeyHex, publicKeyHex string) ([]byte, error) {
 	pubKeyBytes, err := hex.DecodeString(publicKeyHex)
 	if err != nil {
 		return nil, errors.New("FoodBlock: invalid public key hex")
@@ -181,49 +515,212 @@ func Decrypt(envelope *EncryptionEnvelope, privateKeyHex, publicKeyHex string) (
 	if err != nil {
 		return nil, err
 	}
+	return unwrapContentKey(wrappingKey(envelope, sharedSecret, ephPubBytes, pubKeyBytes), encryptedKeyBuf, envelopeAAD(envelope))
+}
 
-	keyNonce := encryptedKeyBuf[len(encryptedKeyBuf)-12:]
-	keyData := encryptedKeyBuf[:len(encryptedKeyBuf)-12]
+// openPassphraseKey tries each passphrase recipient in turn.
+func openPassphraseKey(envelope *EncryptionEnvelope, passphrase string) ([]byte, error) {
+	found := false
+	for _, r := range envelope.Recipients {
+		if r.Type != "passphrase" || r.Argon2 == nil {
+			continue
+		}
+		found = true
+		if err := r.Argon2.check(); err != nil {
+			return nil, err
+		}
+		salt, err := base64.StdEncoding.DecodeString(r.Salt)
+		if err != nil {
+			return nil, err
+		}
+		encryptedKey, err := base64.StdEncoding.DecodeString(r.EncryptedKey)
+		if err != nil {
+			return nil, err
+		}
+		if contentKey, err := unwrapContentKey(passphraseKey(passphrase, salt, *r.Argon2), encryptedKey, envelopeAAD(envelope)); err == nil {
+			return contentKey, nil
+		}
+	}
+	if !found {
+		return nil, errors.New("FoodBlock: envelope has no passphrase recipient")
+	}
+	return nil, errors.New("FoodBlock: failed to decrypt content key")
+}
 
-	keyBlock, err := aes.NewCipher(sharedSecret)
-	if err != nil {
+// wrapContentKey seals the content key, appending the nonce (same as JS).
+func wrapContentKey(key, contentKey, aad []byte) ([]byte, error) {
+	keyNonce := make([]byte, 12)
+	if _, err := rand.Read(keyNonce); err != nil {
 		return nil, err
 	}
-	keyAead, err := cipher.NewGCM(keyBlock)
+	keyAead, err := newGCM(key)
 	if err != nil {
 		return nil, err
 	}
-	contentKey, err := keyAead.Open(nil, keyNonce, keyData, nil)
-	if err != nil {
+	return append(keyAead.Seal(nil, keyNonce, contentKey, aad), keyNonce...), nil
+}
+
+func unwrapContentKey(key, encryptedKey, aad []byte) ([]byte, error) {
+	if len(encryptedKey) < 12 {
 		return nil, errors.New("FoodBlock: failed to decrypt content key")
 	}
+	keyNonce := encryptedKey[len(encryptedKey)-12:]
+	keyData := encryptedKey[:len(encryptedKey)-12]
 
-	// Decrypt ciphertext
-	ciphertextBuf, err := base64.StdEncoding.DecodeString(envelope.Ciphertext)
+	keyAead, err := newGCM(key)
 	if err != nil {
 		return nil, err
 	}
-	contentNonce, err := base64.StdEncoding.DecodeString(envelope.Nonce)
+	contentKey, err := keyAead.Open(nil, keyNonce, keyData, aad)
 	if err != nil {
-		return nil, err
+		return nil, errors.New("FoodBlock: failed to decrypt content key")
+	}
+	return contentKey, nil
+}
+
+// passphraseKey derives a passphrase recipient's wrapping key with Argon2id.
+func passphraseKey(passphrase string, salt []byte, p Argon2Params) []byte {
+	return argon2.IDKey([]byte(passphrase), salt, p.Time, p.Memory, p.Threads, 32)
+}
+
+func (p Argon2Params) check() error {
+	if p.Time == 0 || p.Threads == 0 || p.Memory < 8*uint32(p.Threads) {
+		return fmt.Errorf("FoodBlock: invalid argon2 parameters %+v", p)
+	}
+	if p.Memory > maxArgon2Memory {
+		return fmt.Errorf("FoodBlock: argon2 memory %d KiB exceeds %d KiB", p.Memory, maxArgon2Memory)
+	}
+	if p.Time > maxArgon2Time {
+		return fmt.Errorf("FoodBlock: argon2 time %d exceeds %d", p.Time, maxArgon2Time)
 	}
+	if p.Threads > maxArgon2Threads {
+		return fmt.Errorf("FoodBlock: argon2 threads %d exceeds %d", p.Threads, maxArgon2Threads)
+	}
+	return nil
+}
 
-	contentBlock, err := aes.NewCipher(contentKey)
+// wrappingKey derives the key that wraps the content key for one recipient.
+// Legacy envelopes use the shared secret itself.
+func wrappingKey(envelope *EncryptionEnvelope, sharedSecret, ephemeralKey, recipientKey []byte) []byte {
+	if envelope.Alg == EnvelopeAlgLegacy {
+		return sharedSecret
+	}
+	salt := append(append([]byte(nil), ephemeralKey...), recipientKey...)
+	info := []byte(envelope.Alg + "\x00" + envelope.Context)
+	key := make([]byte, 32)
+	io.ReadFull(hkdf.New(sha256.New, sharedSecret, salt, info), key)
+	return key
+}
+
+// envelopeAAD is the additional data both AEAD layers authenticate: the
+// alg, context, bound hash, version, compression and chunk size, so none can
+// be rewritten. Plain legacy envelopes, as other SDKs write them, have none.
+func envelopeAAD(envelope *EncryptionEnvelope) []byte {
+	if envelope.Alg == EnvelopeAlgLegacy && envelope.Compression == "" && envelope.ChunkSize == 0 {
+		return nil
+	}
+	return []byte(fmt.Sprintf("%s\x00%s\x00%s\x00%d\x00%s\x00%d", envelope.Alg, envelope.Context, envelope.Bound, envelope.Version, envelope.Compression, envelope.ChunkSize))
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
-	contentAead, err := cipher.NewGCM(contentBlock)
+	return cipher.NewGCM(block)
+}
+
+// openContent decrypts a single AEAD message, or chunks when chunkSize is
+// set.
+func openContent(dst io.Writer, src io.Reader, chunkSize int, aead cipher.AEAD, nonce, aad []byte) error {
+	if chunkSize > 0 {
+		return openChunks(dst, src, aead, nonce, aad, chunkSize)
+	}
+	data, err := io.ReadAll(src)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	plaintext, err := contentAead.Open(nil, contentNonce, ciphertextBuf, nil)
+	plaintext, err := aead.Open(nil, nonce, data, aad)
 	if err != nil {
-		return nil, errors.New("FoodBlock: failed to decrypt ciphertext")
+		return errors.New("FoodBlock: failed to decrypt ciphertext")
 	}
+	_, err = dst.Write(plaintext)
+	return err
+}
 
-	var result interface{}
-	if err := json.Unmarshal(plaintext, &result); err != nil {
-		return nil, err
+// Chunked ciphertext is a sequence of AEAD messages, each sealing up to
+// chunkSize bytes under the envelope nonce with the chunk index added to its
+// last eight bytes. Each chunk's additional data ends with a byte saying
+// whether it is the last, so chunks can be neither reordered nor dropped
+// from the end.
+func chunkNonce(base []byte, i uint64) []byte {
+	n := append([]byte(nil), base...)
+	tail := n[len(n)-8:]
+	binary.BigEndian.PutUint64(tail, binary.BigEndian.Uint64(tail)+i)
+	return n
+}
+
+func chunkAAD(aad []byte, final bool) []byte {
+	flag := byte(0)
+	if final {
+		flag = 1
+	}
+	return append(append([]byte(nil), aad...), flag)
+}
+
+func sealChunks(dst io.Writer, src io.Reader, aead cipher.AEAD, nonce, aad []byte, chunkSize int) error {
+	br := bufio.NewReaderSize(src, chunkSize)
+	buf := make([]byte, chunkSize)
+	for i := uint64(0); ; i++ {
+		n, err := io.ReadFull(br, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		final := err != nil
+		if !final {
+			if _, err := br.Peek(1); err == io.EOF {
+				final = true
+			} else if err != nil {
+				return err
+			}
+		}
+		if _, err := dst.Write(aead.Seal(nil, chunkNonce(nonce, i), buf[:n], chunkAAD(aad, final))); err != nil {
+			return err
+		}
+		if final {
+			return nil
+		}
+	}
+}
+
+func openChunks(dst io.Writer, src io.Reader, aead cipher.AEAD, nonce, aad []byte, chunkSize int) error {
+	br := bufio.NewReader(src)
+	buf := make([]byte, chunkSize+aead.Overhead())
+	for i := uint64(0); ; i++ {
+		n, err := io.ReadFull(br, buf)
+		if err == io.EOF {
+			return errors.New("FoodBlock: ciphertext is truncated")
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		final := err != nil
+		if !final {
+			if _, err := br.Peek(1); err == io.EOF {
+				final = true
+			} else if err != nil {
+				return err
+			}
+		}
+		plaintext, err := aead.Open(nil, chunkNonce(nonce, i), buf[:n], chunkAAD(aad, final))
+		if err != nil {
+			return errors.New("FoodBlock: failed to decrypt ciphertext")
+		}
+		if _, err := dst.Write(plaintext); err != nil {
+			return err
+		}
+		if final {
+			return nil
+		}
 	}
-	return result, nil
 }
@@ -1,7 +1,10 @@
 package foodblock
 
 import (
+	"bytes"
 	"encoding/hex"
+	"io"
+	"strings"
 	"testing"
 )
 
@@ -45,8 +48,8 @@ func TestEncryptDecryptRoundtrip(t *testing.T) {
 		t.Fatalf("Encrypt returned error: %v", err)
 	}
 
-	if envelope.Alg != "x25519-aes-256-gcm" {
-		t.Errorf("envelope.Alg = %q, want %q", envelope.Alg, "x25519-aes-256-gcm")
+	if envelope.Alg != EnvelopeAlg || envelope.Version != EnvelopeVersion {
+		t.Errorf("envelope alg %q version %d, want %q version %d", envelope.Alg, envelope.Version, EnvelopeAlg, EnvelopeVersion)
 	}
 	if len(envelope.Recipients) != 1 {
 		t.Errorf("len(envelope.Recipients) = %d, want 1", len(envelope.Recipients))
@@ -151,3 +154,181 @@ func TestEncryptEmptyRecipients(t *testing.T) {
 		t.Errorf("Encrypt with nil recipients should return error, got nil")
 	}
 }
+
+func TestEncryptCompressedAndChunked(t *testing.T) {
+	pub, priv, _ := GenerateEncryptionKeypair()
+	value := map[string]interface{}{"notes": strings.Repeat("organic rye, stone milled; ", 500)}
+
+	plain, _ := EncryptWithOptions(value, []string{pub}, EncryptOptions{Alg: EnvelopeAlgLegacy})
+	packed, err := EncryptWithOptions(value, []string{pub}, EncryptOptions{Compression: "gzip", ChunkSize: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if packed.Version != EnvelopeVersion || packed.Compression != "gzip" || len(packed.Ciphertext) >= len(plain.Ciphertext)/4 {
+		t.Errorf("compressed envelope: version %d, %d vs %d bytes", packed.Version, len(packed.Ciphertext), len(plain.Ciphertext))
+	}
+	got, err := Decrypt(packed, priv, pub)
+	if err != nil || got.(map[string]interface{})["notes"] != value["notes"] {
+		t.Fatalf("Decrypt = %v", err)
+	}
+
+	// Legacy envelopes written before versioning still decrypt.
+	plain.Version = 0
+	if _, err := Decrypt(plain, priv, pub); err != nil {
+		t.Errorf("unversioned envelope: %v", err)
+	}
+	plain.Version = EnvelopeVersion + 1
+	if _, err := Decrypt(plain, priv, pub); err == nil {
+		t.Error("expected an error for a future envelope version")
+	}
+	if _, err := EncryptWithOptions(value, []string{pub}, EncryptOptions{Compression: "lz4"}); err == nil {
+		t.Error("expected an error for an unknown compression")
+	}
+
+	// Version, compression and chunk size are authenticated, and a hostile
+	// chunk size is refused rather than allocated.
+	for _, tamper := range []func(*EncryptionEnvelope){
+		func(e *EncryptionEnvelope) { e.Compression = "" },
+		func(e *EncryptionEnvelope) { e.ChunkSize = 2000 },
+		func(e *EncryptionEnvelope) { e.Version = 1 },
+		func(e *EncryptionEnvelope) { e.ChunkSize = 1 << 62 },
+		func(e *EncryptionEnvelope) { e.ChunkSize = -1 },
+	} {
+		forged := *packed
+		tamper(&forged)
+		if _, err := Decrypt(&forged, priv, pub); err == nil {
+			t.Errorf("tampered envelope decrypted: %+v", forged.ChunkSize)
+		}
+	}
+
+	// Decompression stops at MaxDecompressedSize.
+	defer func(max int64) { MaxDecompressedSize = max }(MaxDecompressedSize)
+	MaxDecompressedSize = 1000
+	if _, err := Decrypt(packed, priv, pub); err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Errorf("expected the decompression limit to apply, got %v", err)
+	}
+}
+
+func TestEncryptStream(t *testing.T) {
+	pub, priv, _ := GenerateEncryptionKeypair()
+	data := bytes.Repeat([]byte("0123456789abcdef"), 4096) // 64 KiB, a whole number of chunks
+	var sealed bytes.Buffer
+	env, err := EncryptStream(&sealed, bytes.NewReader(data), []string{pub}, EncryptOptions{ChunkSize: 4096})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.Ciphertext != "" || env.ChunkSize != 4096 {
+		t.Errorf("envelope = %+v", env)
+	}
+	var out bytes.Buffer
+	if err := DecryptStream(&out, bytes.NewReader(sealed.Bytes()), env, priv, pub); err != nil || !bytes.Equal(out.Bytes(), data) {
+		t.Fatalf("roundtrip: %v, %d bytes", err, out.Len())
+	}
+
+	// Dropping the last chunk or swapping two is detected.
+	chunk := 4096 + 16
+	truncated := sealed.Bytes()[:sealed.Len()-chunk]
+	if err := DecryptStream(io.Discard, bytes.NewReader(truncated), env, priv, pub); err == nil {
+		t.Error("expected truncated ciphertext to fail")
+	}
+	swapped := append([]byte(nil), sealed.Bytes()...)
+	copy(swapped[:chunk], sealed.Bytes()[chunk:2*chunk])
+	copy(swapped[chunk:2*chunk], sealed.Bytes()[:chunk])
+	if err := DecryptStream(io.Discard, bytes.NewReader(swapped), env, priv, pub); err == nil {
+		t.Error("expected reordered chunks to fail")
+	}
+}
+
+func TestEncryptBoundEnvelope(t *testing.T) {
+	pub, priv, _ := GenerateEncryptionKeypair()
+	order := Create("transfer.order", map[string]interface{}{"instance_id": "o1"}, nil)
+	other := Create("transfer.order", map[string]interface{}{"instance_id": "o2"}, nil)
+
+	env, err := EncryptWithOptions(map[string]interface{}{"iban": "GB00"}, []string{pub}, EncryptOptions{Context: "state.payment", Bind: order.Hash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.Alg != EnvelopeAlg {
+		t.Errorf("bound envelope alg = %q, want %q", env.Alg, EnvelopeAlg)
+	}
+	if _, err := DecryptBound(env, order.Hash, priv, pub); err != nil {
+		t.Fatalf("DecryptBound: %v", err)
+	}
+	if _, err := DecryptBound(env, other.Hash, priv, pub); err == nil {
+		t.Error("expected an envelope bound to another block to be refused")
+	}
+
+	// Rewriting the binding or context breaks both AEAD layers.
+	for _, tamper := range []func(*EncryptionEnvelope){
+		func(e *EncryptionEnvelope) { e.Bound = other.Hash },
+		func(e *EncryptionEnvelope) { e.Context = "state.notes" },
+		func(e *EncryptionEnvelope) { e.Alg = EnvelopeAlgLegacy },
+	} {
+		forged := *env
+		tamper(&forged)
+		if _, err := Decrypt(&forged, priv, pub); err == nil {
+			t.Errorf("tampered envelope decrypted: %+v", forged)
+		}
+	}
+	if _, err := EncryptWithOptions("x", []string{pub}, EncryptOptions{Alg: EnvelopeAlgLegacy, Bind: order.Hash}); err == nil {
+		t.Error("expected legacy envelopes to refuse a binding")
+	}
+}
+
+func TestEncryptWithPassphrase(t *testing.T) {
+	// Cheap parameters keep the test fast; the defaults cost 64 MiB.
+	cheap := &Argon2Params{Time: 1, Memory: 64, Threads: 1}
+	value := map[string]interface{}{"harvest": "2026-09-01"}
+
+	env, err := EncryptWithOptions(value, nil, EncryptOptions{Passphrases: []string{"green valley"}, Argon2: cheap})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := env.Recipients[0]; r.Type != "passphrase" || r.KeyHash != "" || r.Salt == "" || r.Argon2.Memory != 64 {
+		t.Errorf("recipient = %+v", r)
+	}
+	got, err := DecryptWithPassphrase(env, "green valley")
+	if err != nil || got.(map[string]interface{})["harvest"] != "2026-09-01" {
+		t.Fatalf("DecryptWithPassphrase = %v, %v", got, err)
+	}
+	if _, err := DecryptWithPassphrase(env, "green vally"); err == nil {
+		t.Error("expected a wrong passphrase to fail")
+	}
+
+	// A mixed envelope opens with either the key or the passphrase.
+	pub, priv, _ := GenerateEncryptionKeypair()
+	mixed, err := EncryptWithOptions(value, []string{pub}, EncryptOptions{Passphrases: []string{"green valley"}, Argon2: cheap, Bind: "abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mixed.Recipients) != 2 {
+		t.Fatalf("recipients = %+v", mixed.Recipients)
+	}
+	if _, err := Decrypt(mixed, priv, pub); err != nil {
+		t.Errorf("Decrypt: %v", err)
+	}
+	if _, err := DecryptWithPassphrase(mixed, "green valley"); err != nil {
+		t.Errorf("DecryptWithPassphrase: %v", err)
+	}
+	keyOnly, _ := Encrypt(value, []string{pub})
+	if _, err := DecryptWithPassphrase(keyOnly, "green valley"); err == nil {
+		t.Error("expected an envelope without passphrase recipients to refuse")
+	}
+
+	// Envelopes cannot demand unbounded work from the decrypter.
+	greedy := *env
+	greedy.Recipients = []EncryptRecipient{env.Recipients[0]}
+	for _, params := range []Argon2Params{
+		{Time: 1, Memory: 1 << 30, Threads: 1},
+		{Time: 1 << 30, Memory: 64, Threads: 1},
+		{Time: 1, Memory: 64 << 10, Threads: 255},
+	} {
+		greedy.Recipients[0].Argon2 = &params
+		if _, err := DecryptWithPassphrase(&greedy, "green valley"); err == nil || !strings.Contains(err.Error(), "exceeds") {
+			t.Errorf("%+v: err = %v", params, err)
+		}
+	}
+	if _, err := EncryptWithOptions(value, nil, EncryptOptions{Passphrases: []string{"x"}, Alg: EnvelopeAlgLegacy}); err == nil {
+		t.Error("expected legacy envelopes to refuse passphrases")
+	}
+}
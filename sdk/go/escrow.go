@@ -0,0 +1,276 @@
+package foodblock
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// EscrowReleaseType documents an escrow unlock: which agents released
+// their shares, for which block, and why. DecryptEscrowed requires one.
+const EscrowReleaseType = "observe.escrow_release"
+
+// EscrowPolicy names the escrow agents for an envelope by their X25519
+// public keys and how many of them must cooperate to unlock it.
+type EscrowPolicy struct {
+	Agents    []string
+	Threshold int
+}
+
+// EnvelopeEscrow holds the content key split into Shamir shares, each
+// encrypted to one escrow agent.
+type EnvelopeEscrow struct {
+	Threshold int           `json:"threshold"`
+	Shares    []EscrowShare `json:"shares"`
+}
+
+// EscrowShare is one agent's encrypted share.
+type EscrowShare struct {
+	KeyHash        string `json:"key_hash"`
+	Index          int    `json:"index"`
+	EncryptedShare string `json:"encrypted_share"`
+}
+
+// escrowContentKey splits the content key for the policy's agents.
+func escrowContentKey(envelope *EncryptionEnvelope, contentKey, ephPriv []byte, policy EscrowPolicy) (*EnvelopeEscrow, error) {
+	if policy.Threshold < 1 || policy.Threshold > len(policy.Agents) {
+		return nil, fmt.Errorf("FoodBlock: escrow threshold %d needs between 1 and %d agents", policy.Threshold, len(policy.Agents))
+	}
+	shares, err := splitSecret(contentKey, len(policy.Agents), policy.Threshold)
+	if err != nil {
+		return nil, err
+	}
+	escrow := &EnvelopeEscrow{Threshold: policy.Threshold}
+	for i, agent := range policy.Agents {
+		agentKey, err := hex.DecodeString(agent)
+		if err != nil {
+			return nil, errors.New("FoodBlock: invalid escrow agent public key hex")
+		}
+		sharedSecret, err := curve25519.X25519(ephPriv, agentKey)
+		if err != nil {
+			return nil, err
+		}
+		encrypted, err := wrapContentKey(escrowKey(envelope, sharedSecret, agentKey), shares[i], envelopeAAD(envelope))
+		if err != nil {
+			return nil, err
+		}
+		keyHash := sha256.Sum256(agentKey)
+		escrow.Shares = append(escrow.Shares, EscrowShare{
+			KeyHash:        hex.EncodeToString(keyHash[:]),
+			Index:          int(shares[i][0]),
+			EncryptedShare: base64.StdEncoding.EncodeToString(encrypted),
+		})
+	}
+	return escrow, nil
+}
+
+// escrowKey derives the key wrapping an agent's share, kept apart from
+// the key a recipient with the same keypair would get.
+func escrowKey(envelope *EncryptionEnvelope, sharedSecret, agentKey []byte) []byte {
+	ephPub, _ := hex.DecodeString(envelope.EphemeralKey)
+	salt := append(append([]byte(nil), ephPub...), agentKey...)
+	info := []byte(envelope.Alg + "\x00escrow\x00" + envelope.Context)
+	key := make([]byte, 32)
+	io.ReadFull(hkdf.New(sha256.New, sharedSecret, salt, info), key)
+	return key
+}
+
+// OpenEscrowShare decrypts an escrow agent's share of an envelope's
+// content key. The share alone reveals nothing; the agent hands it over
+// for DecryptEscrowed once a release is agreed.
+func OpenEscrowShare(envelope *EncryptionEnvelope, privateKeyHex, publicKeyHex string) ([]byte, error) {
+	if envelope.Escrow == nil {
+		return nil, errors.New("FoodBlock: envelope has no escrow")
+	}
+	pub, err := hex.DecodeString(publicKeyHex)
+	if err != nil {
+		return nil, errors.New("FoodBlock: invalid public key hex")
+	}
+	priv, err := hex.DecodeString(privateKeyHex)
+	if err != nil {
+		return nil, errors.New("FoodBlock: invalid private key hex")
+	}
+	keyHash := sha256.Sum256(pub)
+	for _, s := range envelope.Escrow.Shares {
+		if s.KeyHash != hex.EncodeToString(keyHash[:]) {
+			continue
+		}
+		ephPub, err := hex.DecodeString(envelope.EphemeralKey)
+		if err != nil {
+			return nil, err
+		}
+		sharedSecret, err := curve25519.X25519(priv, ephPub)
+		if err != nil {
+			return nil, err
+		}
+		encrypted, err := base64.StdEncoding.DecodeString(s.EncryptedShare)
+		if err != nil {
+			return nil, err
+		}
+		share, err := unwrapContentKey(escrowKey(envelope, sharedSecret, pub), encrypted, envelopeAAD(envelope))
+		if err != nil || len(share) == 0 || int(share[0]) != s.Index {
+			return nil, errors.New("FoodBlock: failed to decrypt escrow share")
+		}
+		return share, nil
+	}
+	return nil, errors.New("FoodBlock: no escrow share for this key")
+}
+
+// CreateEscrowRelease creates the observe.escrow_release block documenting
+// an unlock of the envelope bound to subject, naming the releasing agents
+// by key hash.
+func CreateEscrowRelease(subject, reason string, agentKeyHashes []string) Block {
+	agents := make([]interface{}, len(agentKeyHashes))
+	for i, h := range agentKeyHashes {
+		agents[i] = h
+	}
+	return Create(EscrowReleaseType, map[string]interface{}{
+		"reason": reason,
+		"agents": agents,
+	}, map[string]interface{}{"subject": subject})
+}
+
+// DecryptEscrowed reconstructs an envelope's content key from escrow
+// shares and decrypts it. The release must be for the envelope's bound
+// block, give a reason, and name every agent whose share is used; at least
+// the envelope's threshold of shares is required.
+func DecryptEscrowed(envelope *EncryptionEnvelope, release Block, shares [][]byte) (interface{}, error) {
+	escrow := envelope.Escrow
+	if escrow == nil {
+		return nil, errors.New("FoodBlock: envelope has no escrow")
+	}
+	if release.Type != EscrowReleaseType {
+		return nil, fmt.Errorf("FoodBlock: %s is not an escrow release", release.Type)
+	}
+	subject, _ := release.Refs["subject"].(string)
+	if subject == "" || (envelope.Bound != "" && subject != envelope.Bound) {
+		return nil, fmt.Errorf("FoodBlock: escrow release is for %q, envelope is bound to %q", subject, envelope.Bound)
+	}
+	if reason, _ := release.State["reason"].(string); reason == "" {
+		return nil, errors.New("FoodBlock: escrow release must give a reason")
+	}
+	released := map[string]bool{}
+	for _, a := range flattenRefValues(map[string]interface{}{"agents": release.State["agents"]}) {
+		released[a] = true
+	}
+
+	agentAt := map[int]string{}
+	for _, s := range escrow.Shares {
+		agentAt[s.Index] = s.KeyHash
+	}
+	seen := map[byte]bool{}
+	for _, share := range shares {
+		if len(share) < 2 || seen[share[0]] {
+			return nil, errors.New("FoodBlock: invalid or duplicate escrow share")
+		}
+		seen[share[0]] = true
+		agent, ok := agentAt[int(share[0])]
+		if !ok {
+			return nil, fmt.Errorf("FoodBlock: escrow share %d is not in the envelope", share[0])
+		}
+		if !released[agent] {
+			return nil, fmt.Errorf("FoodBlock: escrow agent %s is not named in the release", agent)
+		}
+	}
+	if len(seen) < escrow.Threshold {
+		return nil, fmt.Errorf("FoodBlock: escrow needs %d shares, got %d", escrow.Threshold, len(seen))
+	}
+
+	if err := checkEnvelope(envelope); err != nil {
+		return nil, err
+	}
+	contentKey, err := combineShares(shares)
+	if err != nil {
+		return nil, err
+	}
+	return decryptWithContentKey(envelope, contentKey)
+}
+
+// splitSecret splits secret into n Shamir shares over GF(2^8), any k of
+// which recover it. Each share is its x coordinate followed by one y byte
+// per secret byte.
+func splitSecret(secret []byte, n, k int) ([][]byte, error) {
+	if k < 1 || n < k || n > 255 {
+		return nil, fmt.Errorf("FoodBlock: cannot split a secret %d of %d ways", k, n)
+	}
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, 1, len(secret)+1)
+		shares[i][0] = byte(i + 1)
+	}
+	coeffs := make([]byte, k)
+	for _, b := range secret {
+		coeffs[0] = b
+		if _, err := rand.Read(coeffs[1:]); err != nil {
+			return nil, err
+		}
+		for i := range shares {
+			x, y := shares[i][0], byte(0)
+			for j := k - 1; j >= 0; j-- {
+				y = gfMul(y, x) ^ coeffs[j]
+			}
+			shares[i] = append(shares[i], y)
+		}
+	}
+	return shares, nil
+}
+
+// combineShares recovers a secret by Lagrange interpolation at zero.
+func combineShares(shares [][]byte) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, errors.New("FoodBlock: no escrow shares")
+	}
+	size := len(shares[0]) - 1
+	for _, s := range shares {
+		if len(s)-1 != size || s[0] == 0 {
+			return nil, errors.New("FoodBlock: invalid escrow share")
+		}
+	}
+	secret := make([]byte, size)
+	for i, si := range shares {
+		// basis = product of x_j / (x_j - x_i) over j != i; subtraction is xor
+		basis := byte(1)
+		for j, sj := range shares {
+			if i != j {
+				basis = gfMul(basis, gfMul(sj[0], gfInv(sj[0]^si[0])))
+			}
+		}
+		for b := 0; b < size; b++ {
+			secret[b] ^= gfMul(si[b+1], basis)
+		}
+	}
+	return secret, nil
+}
+
+// gfMul multiplies in GF(2^8) with the AES polynomial.
+func gfMul(a, b byte) byte {
+	var p byte
+	for b > 0 {
+		if b&1 == 1 {
+			p ^= a
+		}
+		carry := a & 0x80
+		a <<= 1
+		if carry != 0 {
+			a ^= 0x1b
+		}
+		b >>= 1
+	}
+	return p
+}
+
+// gfInv inverts a non-zero element as a^254.
+func gfInv(a byte) byte {
+	r := byte(1)
+	for i := 0; i < 254; i++ {
+		r = gfMul(r, a)
+	}
+	return r
+}
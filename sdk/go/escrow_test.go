@@ -0,0 +1,85 @@
+package foodblock
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSplitAndCombineShares(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	shares, err := splitSecret(secret, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pick := range [][]int{{0, 1, 2}, {4, 2, 0}, {1, 3, 4, 0}} {
+		var subset [][]byte
+		for _, i := range pick {
+			subset = append(subset, shares[i])
+		}
+		if got, _ := combineShares(subset); !bytes.Equal(got, secret) {
+			t.Errorf("shares %v recovered %x", pick, got)
+		}
+	}
+	if got, _ := combineShares(shares[:2]); bytes.Equal(got, secret) {
+		t.Error("two shares recovered a threshold-3 secret")
+	}
+}
+
+func TestEscrowRelease(t *testing.T) {
+	owner, ownerPriv, _ := GenerateEncryptionKeypair()
+	var agents, privs []string
+	for i := 0; i < 3; i++ {
+		pub, priv, _ := GenerateEncryptionKeypair()
+		agents, privs = append(agents, pub), append(privs, priv)
+	}
+	shipment := Create("transfer.order", map[string]interface{}{"instance_id": "s1"}, nil)
+	value := map[string]interface{}{"min_temp": 1.5, "max_temp": 9.2}
+
+	env, err := EncryptWithOptions(value, []string{owner}, EncryptOptions{Bind: shipment.Hash, Escrow: &EscrowPolicy{Agents: agents, Threshold: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decrypt(env, ownerPriv, owner); err != nil {
+		t.Fatalf("owner Decrypt: %v", err)
+	}
+	if _, err := OpenEscrowShare(env, ownerPriv, owner); err == nil {
+		t.Error("expected the owner to hold no escrow share")
+	}
+
+	var shares [][]byte
+	var keyHashes []string
+	for i := 0; i < 2; i++ {
+		share, err := OpenEscrowShare(env, privs[i], agents[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		shares = append(shares, share)
+		keyHashes = append(keyHashes, env.Escrow.Shares[i].KeyHash)
+	}
+
+	release := CreateEscrowRelease(shipment.Hash, "temperature excursion dispute", keyHashes)
+	got, err := DecryptEscrowed(env, release, shares)
+	if err != nil || got.(map[string]interface{})["max_temp"] != 9.2 {
+		t.Fatalf("DecryptEscrowed = %v, %v", got, err)
+	}
+
+	for _, tc := range []struct {
+		release Block
+		shares  [][]byte
+		want    string
+	}{
+		{release, shares[:1], "needs 2 shares"},
+		{CreateEscrowRelease("other", "dispute", keyHashes), shares, "bound to"},
+		{CreateEscrowRelease(shipment.Hash, "", keyHashes), shares, "reason"},
+		{CreateEscrowRelease(shipment.Hash, "dispute", keyHashes[:1]), shares, "not named"},
+		{Create("observe.note", nil, nil), shares, "not an escrow release"},
+	} {
+		if _, err := DecryptEscrowed(env, tc.release, tc.shares); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("want %q, got %v", tc.want, err)
+		}
+	}
+	if _, err := EncryptWithOptions(value, []string{owner}, EncryptOptions{Escrow: &EscrowPolicy{Agents: agents, Threshold: 4}}); err == nil {
+		t.Error("expected a threshold above the agent count to fail")
+	}
+}
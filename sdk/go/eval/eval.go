@@ -0,0 +1,382 @@
+// Package eval scores the SDK's natural-language extractors against a
+// labelled corpus so extraction quality cannot regress silently.
+//
+// The shared corpus lives at test/extraction-corpus.json. Run it with:
+//
+//	go test -v ./eval
+//
+// Each extractor's report is compared with its entry in Thresholds and the
+// test fails when any score drops below it. Thresholds sit just under the
+// scores measured when the corpus was last labelled; raise them as the
+// extractors improve. FOODBLOCK_EVAL_SLACK widens every threshold by the
+// given number of points for experimental runs.
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	foodblock "github.com/FoodXDevelopment/foodblock/sdk/go"
+)
+
+// Case is one labelled example. Extractor is "fb" or "mapfields"; mapfields
+// cases name the Vocabulary to map against. Type is the expected FB block
+// type and Fields the expected state values. Ignore lists fields whose value
+// is a matter of taste (a free-text name, say) and is not scored either way.
+type Case struct {
+	Name       string                 `json:"name"`
+	Domain     string                 `json:"domain"`
+	Extractor  string                 `json:"extractor"`
+	Vocabulary string                 `json:"vocabulary,omitempty"`
+	Text       string                 `json:"text"`
+	Type       string                 `json:"type,omitempty"`
+	Fields     map[string]interface{} `json:"fields,omitempty"`
+	Ignore     []string               `json:"ignore,omitempty"`
+}
+
+// LoadCorpus reads a JSON array of cases.
+func LoadCorpus(path string) ([]Case, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cases []Case
+	if err := json.Unmarshal(data, &cases); err != nil {
+		return nil, fmt.Errorf("eval: %s: %w", path, err)
+	}
+	return cases, nil
+}
+
+// Extractor runs one extraction stage over a case. Cases selects the corpus
+// entries it applies to; Types marks extractors that predict a block type;
+// Scores selects the labelled fields it is responsible for (nil scores none).
+type Extractor struct {
+	Cases  func(Case) bool
+	Types  bool
+	Scores func(field string) bool
+	Run    func(Case) (typ string, fields map[string]interface{})
+}
+
+func isFB(c Case) bool        { return c.Extractor == "" || c.Extractor == "fb" }
+func isMapFields(c Case) bool { return c.Extractor == "mapfields" }
+func allFields(string) bool   { return true }
+
+// Extractors are the stages that can be evaluated by name: the full FB()
+// pipeline, its individual stages, and MapFields.
+var Extractors = map[string]Extractor{
+	"fb": {Cases: isFB, Types: true, Scores: allFields, Run: func(c Case) (string, map[string]interface{}) {
+		r := foodblock.FB(c.Text)
+		return r.Type, r.State
+	}},
+	"type": {Cases: isFB, Types: true, Run: func(c Case) (string, map[string]interface{}) {
+		return foodblock.DetectType(c.Text), nil
+	}},
+	"name": {Cases: isFB, Scores: func(f string) bool { return f == "name" }, Run: func(c Case) (string, map[string]interface{}) {
+		typ := c.Type
+		if typ == "" {
+			typ = foodblock.DetectType(c.Text)
+		}
+		if name := foodblock.ExtractName(c.Text, typ); name != "" {
+			return "", map[string]interface{}{"name": name}
+		}
+		return "", nil
+	}},
+	"quantities": {Cases: isFB, Scores: inList(foodblock.QuantityFields()), Run: func(c Case) (string, map[string]interface{}) {
+		return "", foodblock.ExtractQuantities(c.Text)
+	}},
+	"flags": {Cases: isFB, Scores: flagField, Run: func(c Case) (string, map[string]interface{}) {
+		return "", foodblock.ExtractFlags(c.Text)
+	}},
+	"mapfields": {Cases: isMapFields, Scores: allFields, Run: func(c Case) (string, map[string]interface{}) {
+		return "", foodblock.MapFields(c.Text, foodblock.Vocabularies[c.Vocabulary]).Matched
+	}},
+}
+
+func inList(fields []string) func(string) bool {
+	return func(f string) bool {
+		for _, x := range fields {
+			if x == f {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// flagField reports whether any vocabulary defines f as a boolean or
+// compound field.
+func flagField(f string) bool {
+	for _, v := range foodblock.Vocabularies {
+		if def, ok := v.Fields[f]; ok && (def.Type == "boolean" || def.Type == "compound") {
+			return true
+		}
+	}
+	return false
+}
+
+// Score is the accuracy of an extractor over a set of cases. Field recall is
+// the share of labelled fields extracted with the expected value; field
+// precision is the share of extracted in-scope fields that are labelled with
+// the value extracted.
+type Score struct {
+	Cases          int     `json:"cases"`
+	TypeAccuracy   float64 `json:"type_accuracy"`
+	FieldRecall    float64 `json:"field_recall"`
+	FieldPrecision float64 `json:"field_precision"`
+
+	typed, typeHits             int
+	expected, extracted, fields int
+}
+
+func (s *Score) finish() {
+	s.TypeAccuracy = ratio(s.typeHits, s.typed)
+	s.FieldRecall = ratio(s.fields, s.expected)
+	s.FieldPrecision = ratio(s.fields, s.extracted)
+}
+
+// summary formats the scores that were measured: type accuracy only when
+// cases were typed, field scores only when fields were labelled or extracted.
+func (s Score) summary() string {
+	var parts []string
+	if s.typed > 0 {
+		parts = append(parts, fmt.Sprintf("type %5.1f%%", s.TypeAccuracy*100))
+	}
+	if s.expected+s.extracted > 0 {
+		parts = append(parts, fmt.Sprintf("recall %5.1f%%", s.FieldRecall*100), fmt.Sprintf("precision %5.1f%%", s.FieldPrecision*100))
+	}
+	return strings.Join(parts, "  ")
+}
+
+func ratio(n, d int) float64 {
+	if d == 0 {
+		return 1
+	}
+	return math.Round(float64(n)/float64(d)*1000) / 1000
+}
+
+// Failure is one mismatch between a case's label and the extraction.
+type Failure struct {
+	Case  string      `json:"case"`
+	Field string      `json:"field"` // "type" for type mismatches
+	Want  interface{} `json:"want"`
+	Got   interface{} `json:"got"`
+}
+
+// Report is the result of evaluating one extractor over a corpus.
+type Report struct {
+	Extractor string `json:"extractor"`
+	Score
+	ByDomain map[string]Score `json:"by_domain"`
+	Failures []Failure        `json:"failures"`
+}
+
+// Evaluate runs the named extractor over every case it applies to.
+func Evaluate(cases []Case, extractor string) (Report, error) {
+	ex, ok := Extractors[extractor]
+	if !ok {
+		return Report{}, fmt.Errorf("eval: unknown extractor %q", extractor)
+	}
+	r := Report{Extractor: extractor, ByDomain: map[string]Score{}, Failures: []Failure{}}
+	domains := map[string]*Score{}
+	for i, c := range cases {
+		if !ex.Cases(c) {
+			continue
+		}
+		name := c.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i)
+		}
+		d := domains[c.Domain]
+		if d == nil {
+			d = &Score{}
+			domains[c.Domain] = d
+		}
+		typ, got := ex.Run(c)
+		for _, s := range []*Score{&r.Score, d} {
+			s.Cases++
+		}
+		if ex.Types && c.Type != "" {
+			hit := typ == c.Type
+			for _, s := range []*Score{&r.Score, d} {
+				s.typed++
+				if hit {
+					s.typeHits++
+				}
+			}
+			if !hit {
+				r.Failures = append(r.Failures, Failure{Case: name, Field: "type", Want: c.Type, Got: typ})
+			}
+		}
+		if ex.Scores == nil {
+			continue
+		}
+		scored := func(field string) bool {
+			return ex.Scores(field) && !inList(c.Ignore)(field)
+		}
+		for field, want := range c.Fields {
+			if !scored(field) {
+				continue
+			}
+			v, present := got[field]
+			hit := present && Equal(want, v)
+			for _, s := range []*Score{&r.Score, d} {
+				s.expected++
+				if hit {
+					s.fields++
+				}
+			}
+			if !hit {
+				r.Failures = append(r.Failures, Failure{Case: name, Field: field, Want: want, Got: v})
+			}
+		}
+		for field := range got {
+			if !scored(field) {
+				continue
+			}
+			for _, s := range []*Score{&r.Score, d} {
+				s.extracted++
+			}
+			if _, labelled := c.Fields[field]; !labelled {
+				r.Failures = append(r.Failures, Failure{Case: name, Field: field, Got: got[field]})
+			}
+		}
+	}
+	sort.SliceStable(r.Failures, func(i, j int) bool {
+		if r.Failures[i].Case != r.Failures[j].Case {
+			return r.Failures[i].Case < r.Failures[j].Case
+		}
+		return r.Failures[i].Field < r.Failures[j].Field
+	})
+	r.finish()
+	for domain, s := range domains {
+		s.finish()
+		r.ByDomain[domain] = *s
+	}
+	return r, nil
+}
+
+// Equal compares an expected label with an extracted value. Numbers compare
+// within 1e-9, strings case-insensitively, and maps and lists element-wise.
+func Equal(want, got interface{}) bool {
+	w, g := normalize(want), normalize(got)
+	switch wv := w.(type) {
+	case float64:
+		gv, ok := g.(float64)
+		return ok && math.Abs(wv-gv) < 1e-9
+	case string:
+		gv, ok := g.(string)
+		return ok && strings.EqualFold(strings.TrimSpace(wv), strings.TrimSpace(gv))
+	case map[string]interface{}:
+		gv, ok := g.(map[string]interface{})
+		if !ok || len(gv) != len(wv) {
+			return false
+		}
+		for k, x := range wv {
+			if !Equal(x, gv[k]) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		gv, ok := g.([]interface{})
+		if !ok || len(gv) != len(wv) {
+			return false
+		}
+		for i := range wv {
+			if !Equal(wv[i], gv[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return w == g
+}
+
+// normalize round-trips a value through JSON so Go and decoded values share
+// representations.
+func normalize(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return v
+	}
+	return out
+}
+
+// Threshold is the minimum acceptable score for an extractor.
+type Threshold struct {
+	TypeAccuracy   float64 `json:"type_accuracy"`
+	FieldRecall    float64 `json:"field_recall"`
+	FieldPrecision float64 `json:"field_precision"`
+}
+
+// Thresholds are the CI floors per extractor.
+var Thresholds = map[string]Threshold{
+	"fb":         {TypeAccuracy: 0.87, FieldRecall: 0.94, FieldPrecision: 0.91},
+	"type":       {TypeAccuracy: 0.87},
+	"name":       {FieldRecall: 0.99, FieldPrecision: 0.99},
+	"quantities": {FieldRecall: 0.88, FieldPrecision: 0.88},
+	"flags":      {FieldRecall: 0.96, FieldPrecision: 0.84},
+	"mapfields":  {FieldRecall: 0.94, FieldPrecision: 0.76},
+}
+
+// Check returns an error naming every score below the threshold, widened by
+// FOODBLOCK_EVAL_SLACK points when set.
+func (r Report) Check(t Threshold) error {
+	slack := 0.0
+	if env := os.Getenv("FOODBLOCK_EVAL_SLACK"); env != "" {
+		fmt.Sscanf(env, "%g", &slack)
+	}
+	var low []string
+	for _, m := range []struct {
+		name       string
+		got, floor float64
+	}{
+		{"type accuracy", r.TypeAccuracy, t.TypeAccuracy},
+		{"field recall", r.FieldRecall, t.FieldRecall},
+		{"field precision", r.FieldPrecision, t.FieldPrecision},
+	} {
+		if m.got < m.floor-slack/100 {
+			low = append(low, fmt.Sprintf("%s %.3f < %.3f", m.name, m.got, m.floor))
+		}
+	}
+	if len(low) > 0 {
+		return fmt.Errorf("eval: %s below threshold: %s", r.Extractor, strings.Join(low, ", "))
+	}
+	return nil
+}
+
+// String renders the report as a summary table followed by up to 20
+// failures.
+func (r Report) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s: %d cases, %s\n", r.Extractor, r.Cases, r.Score.summary())
+	domains := make([]string, 0, len(r.ByDomain))
+	for d := range r.ByDomain {
+		domains = append(domains, d)
+	}
+	sort.Strings(domains)
+	for _, d := range domains {
+		s := r.ByDomain[d]
+		fmt.Fprintf(&sb, "  %-14s %4d  %s\n", d, s.Cases, s.summary())
+	}
+	for i, f := range r.Failures {
+		if i == 20 {
+			fmt.Fprintf(&sb, "  ... %d more failures\n", len(r.Failures)-20)
+			break
+		}
+		if f.Want == nil {
+			fmt.Fprintf(&sb, "  %s: unexpected %s = %v\n", f.Case, f.Field, f.Got)
+		} else {
+			fmt.Fprintf(&sb, "  %s: %s want %v, got %v\n", f.Case, f.Field, f.Want, f.Got)
+		}
+	}
+	return sb.String()
+}
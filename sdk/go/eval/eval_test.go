@@ -0,0 +1,82 @@
+package eval
+
+import (
+	"sort"
+	"testing"
+)
+
+const corpusPath = "../../../test/extraction-corpus.json"
+
+func TestCorpus(t *testing.T) {
+	cases, err := LoadCorpus(corpusPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cases) < 200 {
+		t.Fatalf("corpus has %d cases, want at least 200", len(cases))
+	}
+	names := make([]string, 0, len(Extractors))
+	for name := range Extractors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		r, err := Evaluate(cases, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Log(r.String())
+		threshold, ok := Thresholds[name]
+		if !ok {
+			t.Errorf("no threshold for extractor %s", name)
+			continue
+		}
+		if err := r.Check(threshold); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	cases := []Case{
+		{Name: "a", Domain: "bakery", Text: "Sourdough bread $4.50", Type: "substance.product",
+			Fields: map[string]interface{}{"price": map[string]interface{}{"value": 4.5, "unit": "USD"}}, Ignore: []string{"name"}},
+		{Name: "b", Domain: "bakery", Text: "Rye bread $3", Type: "actor.venue", Ignore: []string{"name"}},
+	}
+	r, err := Evaluate(cases, "fb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Cases != 2 || r.TypeAccuracy != 0.5 || r.FieldRecall != 1 {
+		t.Errorf("unexpected score: %+v", r.Score)
+	}
+	// Case b's price is extracted but unlabelled, so it counts against precision.
+	if r.FieldPrecision != 0.5 {
+		t.Errorf("precision = %v, want 0.5", r.FieldPrecision)
+	}
+	if _, err := Evaluate(cases, "nope"); err == nil {
+		t.Error("expected an error for an unknown extractor")
+	}
+	if err := r.Check(Threshold{TypeAccuracy: 0.9}); err == nil {
+		t.Error("expected a threshold failure")
+	}
+}
+
+func TestEqual(t *testing.T) {
+	for _, c := range []struct {
+		want, got interface{}
+		equal     bool
+	}{
+		{4.5, 4.5, true},
+		{float64(3), 3, true},
+		{"Kent", "kent ", true},
+		{map[string]interface{}{"value": 250.0, "unit": "g"}, map[string]interface{}{"value": 250, "unit": "g"}, true},
+		{map[string]interface{}{"gluten": true}, map[string]interface{}{"gluten": true, "nuts": true}, false},
+		{true, false, false},
+		{1.06, "1.06", false},
+	} {
+		if got := Equal(c.want, c.got); got != c.equal {
+			t.Errorf("Equal(%v, %v) = %v, want %v", c.want, c.got, got, c.equal)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package foodblock
+
+import (
+	"fmt"
+	"time"
+)
+
+// ExpiryRetained lists the type patterns that never expire, whatever their
+// state says: transfers, attestations, tombstones and the other events an
+// audit must be able to replay.
+var ExpiryRetained = []string{
+	"transfer.*",
+	"observe.attestation",
+	"observe.tombstone",
+	"observe.escrow_release",
+	"observe.key_rotation",
+	"observe.compaction",
+	PinType,
+}
+
+// Expiry actions.
+const (
+	ExpireTombstone = "tombstone"
+	ExpireArchive   = "archive"
+)
+
+// ExpiryPolicy controls what ExpireBlocks does with expired blocks.
+type ExpiryPolicy struct {
+	Action      string      // ExpireTombstone (the default) or ExpireArchive
+	Cold        ColdBackend // required for ExpireArchive
+	RequestedBy string      // recorded on expiry tombstones
+	Now         func() time.Time
+}
+
+// ExpiresAt reads when a block expires: state.expires_at (RFC 3339 or
+// YYYY-MM-DD), or state.ttl (a duration such as "48h", or seconds) counted
+// from since, falling back to the block's own date when since is zero.
+// Retained types never expire.
+func ExpiresAt(b Block, since time.Time) (time.Time, bool) {
+	for _, pattern := range ExpiryRetained {
+		if matchesType(b.Type, pattern) {
+			return time.Time{}, false
+		}
+	}
+	if t, ok := stateTime(b.State, "expires_at"); ok {
+		return t, true
+	}
+	ttl, ok := blockTTL(b)
+	if !ok {
+		return time.Time{}, false
+	}
+	if since.IsZero() {
+		if since, ok = BlockDate(b); !ok {
+			return time.Time{}, false
+		}
+	}
+	return since.Add(ttl), true
+}
+
+// IsExpired reports whether a block has expired at now, judged from its
+// state alone.
+func IsExpired(b Block, now time.Time) bool {
+	t, ok := ExpiresAt(b, time.Time{})
+	return ok && !now.Before(t)
+}
+
+func blockTTL(b Block) (time.Duration, bool) {
+	switch v := b.State["ttl"].(type) {
+	case string:
+		d, err := time.ParseDuration(v)
+		return d, err == nil && d > 0
+	default:
+		if n, ok := numericValue(v); ok && n > 0 {
+			return time.Duration(n * float64(time.Second)), true
+		}
+	}
+	return 0, false
+}
+
+// ExpiredBlocks scans a store for the current versions that have expired at
+// now. A ttl counts from when the block was stored when the store records
+// it. Stubs, superseded versions and pinned blocks are skipped.
+func ExpiredBlocks(store BlockStore, now time.Time) []Block {
+	stubs, _ := store.(StubStore)
+	var out []Block
+	for _, b := range FilterBlocks(store.Blocks(), QueryParams{HeadsOnly: true, IncludeExpired: true}) {
+		if IsStub(b) {
+			continue
+		}
+		var since time.Time
+		if stubs != nil {
+			since, _ = stubs.StoredAt(b.Hash)
+		}
+		if t, ok := ExpiresAt(b, since); !ok || now.Before(t) || IsPinned(b.Hash, store) {
+			continue
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+// ExpireBlocks acts on the blocks ExpiredBlocks finds: each is tombstoned
+// with reason "expired", or archived to policy.Cold and replaced by a stub.
+// Returns the hashes handled.
+func ExpireBlocks(store StubStore, policy ExpiryPolicy) ([]string, error) {
+	if policy.Now == nil {
+		policy.Now = time.Now
+	}
+	if policy.Action == "" {
+		policy.Action = ExpireTombstone
+	}
+	if policy.Action != ExpireTombstone && policy.Action != ExpireArchive {
+		return nil, fmt.Errorf("FoodBlock: unknown expiry action %s", policy.Action)
+	}
+	if policy.Action == ExpireArchive && policy.Cold == nil {
+		return nil, fmt.Errorf("FoodBlock: archiving expired blocks needs a cold backend")
+	}
+
+	var expired []string
+	for _, b := range ExpiredBlocks(store, policy.Now()) {
+		switch policy.Action {
+		case ExpireArchive:
+			if err := policy.Cold.PutCold(b); err != nil {
+				return expired, err
+			}
+			store.Stub(b.Hash, map[string]interface{}{"archived": true})
+		default:
+			tomb := Create("observe.tombstone", map[string]interface{}{
+				"reason":       "expired",
+				"requested_by": policy.RequestedBy,
+			}, map[string]interface{}{
+				"target":  b.Hash,
+				"updates": b.Hash,
+			})
+			if err := store.Put(tomb); err != nil {
+				return expired, err
+			}
+		}
+		expired = append(expired, b.Hash)
+	}
+	return expired, nil
+}
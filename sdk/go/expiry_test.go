@@ -0,0 +1,82 @@
+package foodblock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpiresAt(t *testing.T) {
+	stored := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	surplus := Create("substance.surplus", map[string]interface{}{"name": "Loaves", "expires_at": "2026-06-01T18:00:00Z"}, nil)
+	if at, ok := ExpiresAt(surplus, time.Time{}); !ok || !at.Equal(stored.Add(6*time.Hour)) {
+		t.Errorf("expires_at = %v, %v", at, ok)
+	}
+	listing := Create("substance.surplus", map[string]interface{}{"name": "Buns", "ttl": "2h"}, nil)
+	if _, ok := ExpiresAt(listing, time.Time{}); ok {
+		t.Error("a ttl without a start time should not expire")
+	}
+	if at, _ := ExpiresAt(listing, stored); !at.Equal(stored.Add(2 * time.Hour)) {
+		t.Errorf("ttl from stored = %v", at)
+	}
+	dated := Create("substance.surplus", map[string]interface{}{"name": "Rolls", "ttl": 3600, "created_at": "2026-06-01T12:00:00Z"}, nil)
+	if !IsExpired(dated, stored.Add(time.Hour)) || IsExpired(dated, stored) {
+		t.Error("numeric ttl should count seconds from created_at")
+	}
+	order := Create("transfer.order", map[string]interface{}{"expires_at": "2020-01-01"}, nil)
+	if IsExpired(order, stored) {
+		t.Error("transfers are retained for audit")
+	}
+}
+
+func TestExpireBlocks(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now()
+	bakery := Create("actor.producer", map[string]interface{}{"name": "Bakery"}, nil)
+	stale := Create("substance.surplus", map[string]interface{}{"name": "Loaves", "expires_at": now.Add(-time.Hour).Format(time.RFC3339)}, map[string]interface{}{"source": bakery.Hash})
+	fresh := Create("substance.surplus", map[string]interface{}{"name": "Buns", "ttl": "1h"}, map[string]interface{}{"source": bakery.Hash})
+	held := Create("substance.surplus", map[string]interface{}{"name": "Rolls", "expires_at": "2020-01-01"}, nil)
+	store.PutAll([]Block{bakery, stale, fresh, held, Pin(held.Hash, PinRecall)})
+
+	// Queries leave expired blocks out unless asked.
+	got, _ := NewQuery(store.Query).Type("substance.surplus").Exec()
+	if len(got) != 1 || got[0].Hash != fresh.Hash {
+		t.Errorf("query = %v", got)
+	}
+	if got, _ := NewQuery(store.Query).Type("substance.surplus").IncludeExpired().Exec(); len(got) != 3 {
+		t.Errorf("include expired = %d blocks", len(got))
+	}
+	later := QueryParams{Type: "substance.surplus", AsOf: now.Add(2 * time.Hour)}
+	if got, _ := store.Query(later); len(got) != 0 {
+		t.Errorf("ttl should count from when the block was stored, got %v", got)
+	}
+
+	if expired := ExpiredBlocks(store, now); len(expired) != 1 || expired[0].Hash != stale.Hash {
+		t.Fatalf("expired = %v", expired)
+	}
+	hashes, err := ExpireBlocks(store, ExpiryPolicy{RequestedBy: bakery.Hash, Now: func() time.Time { return now }})
+	if err != nil || len(hashes) != 1 {
+		t.Fatalf("expire = %v, %v", hashes, err)
+	}
+	if heads := store.AllHeads("observe.tombstone"); len(heads) != 1 || heads[0].State["reason"] != "expired" {
+		t.Errorf("tombstones = %v", heads)
+	}
+	if expired := ExpiredBlocks(store, now); len(expired) != 0 {
+		t.Errorf("tombstoned blocks should not expire again: %v", expired)
+	}
+
+	// Archiving replaces the content with a stub and keeps it cold.
+	cold, _ := NewFileColdStore(t.TempDir())
+	archived, err := ExpireBlocks(store, ExpiryPolicy{Action: ExpireArchive, Cold: cold, Now: func() time.Time { return now.Add(2 * time.Hour) }})
+	if err != nil || len(archived) != 1 || archived[0] != fresh.Hash {
+		t.Fatalf("archived = %v, %v", archived, err)
+	}
+	if b := store.Resolve(fresh.Hash); b == nil || !IsStub(*b) {
+		t.Error("expected a stub in the hot store")
+	}
+	if b, err := cold.GetCold(fresh.Hash); err != nil || b.State["name"] != "Buns" {
+		t.Errorf("cold copy = %v, %v", b, err)
+	}
+	if _, err := ExpireBlocks(store, ExpiryPolicy{Action: ExpireArchive}); err == nil {
+		t.Error("expected an error archiving without a cold backend")
+	}
+}
@@ -1,9 +1,18 @@
 package foodblock
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+)
 
 // Explain generates a human-readable narrative for a block and its provenance.
 func Explain(hash string, resolve func(string) *Block, maxDepth int) string {
+	return ExplainLimited(hash, resolve, maxDepth, BreadthLimits{})
+}
+
+// ExplainLimited is Explain with long lists of inputs and certifications cut
+// to limits.MaxPerLevel, the rest counted and optionally grouped.
+func ExplainLimited(hash string, resolve func(string) *Block, maxDepth int, limits BreadthLimits) string {
 	if maxDepth <= 0 {
 		maxDepth = 10
 	}
@@ -13,7 +22,7 @@ func Explain(hash string, resolve func(string) *Block, maxDepth int) string {
 	}
 
 	visited := make(map[string]bool)
-	parts := buildNarrative(block, resolve, visited, 0, maxDepth)
+	parts := buildNarrative(block, resolve, visited, 0, maxDepth, limits)
 	result := ""
 	for i, p := range parts {
 		if i > 0 {
@@ -24,7 +33,7 @@ func Explain(hash string, resolve func(string) *Block, maxDepth int) string {
 	return result
 }
 
-func buildNarrative(block *Block, resolve func(string) *Block, visited map[string]bool, depth, maxDepth int) []string {
+func buildNarrative(block *Block, resolve func(string) *Block, visited map[string]bool, depth, maxDepth int, limits BreadthLimits) []string {
 	if block == nil || visited[block.Hash] || depth > maxDepth {
 		return nil
 	}
@@ -56,7 +65,8 @@ func buildNarrative(block *Block, resolve func(string) *Block, visited map[strin
 
 	// Actor refs
 	for _, role := range []string{"seller", "buyer", "author", "operator", "producer"} {
-		if refHash, ok := refs[role].(string); ok {
+		ref, _ := refByRole(refs, role)
+		if refHash, ok := ref.(string); ok {
 			actor := resolve(refHash)
 			if actor != nil && !visited[actor.Hash] {
 				if actorName, ok := actor.State["name"].(string); ok {
@@ -69,9 +79,16 @@ func buildNarrative(block *Block, resolve func(string) *Block, visited map[strin
 		}
 	}
 
+	// Contract manufacturing
+	if depth == 0 {
+		if m, ok := ManufacturingOf(*block, resolve); ok {
+			parts = append(parts, m.Describe(resolve))
+		}
+	}
+
 	// Input/source refs
 	for _, role := range []string{"inputs", "source", "origin", "input"} {
-		ref, ok := refs[role]
+		ref, ok := refByRole(refs, role)
 		if !ok {
 			continue
 		}
@@ -87,7 +104,8 @@ func buildNarrative(block *Block, resolve func(string) *Block, visited map[strin
 			}
 		}
 
-		var names []string
+		var deps []Block
+		descs := make(map[string]string)
 		for _, h := range refHashes {
 			dep := resolve(h)
 			if dep == nil {
@@ -106,18 +124,23 @@ func buildNarrative(block *Block, resolve func(string) *Block, visited map[strin
 						break
 					}
 				}
-				names = append(names, depDesc)
+				deps = append(deps, *dep)
+				descs[dep.Hash] = depDesc
 			}
 		}
-		if len(names) > 0 {
+		if len(deps) > 0 {
+			shown, omitted := limitBlocks(deps, limits)
 			joined := ""
-			for i, n := range names {
+			for i, d := range shown {
 				if i > 0 {
 					joined += ", "
 				}
-				joined += n
+				joined += descs[d.Hash]
+			}
+			if omitted > 0 {
+				joined += fmt.Sprintf(" and %d more", omitted)
 			}
-			parts = append(parts, "Made from "+joined+".")
+			parts = append(parts, "Made from "+joined+describeGroups(groupBlocks(deps, limits.GroupBy, resolve))+".")
 		}
 	}
 
@@ -134,12 +157,17 @@ func buildNarrative(block *Block, resolve func(string) *Block, visited map[strin
 				}
 			}
 		}
+		listed := 0
 		for _, h := range certHashes {
 			cert := resolve(h)
 			if cert == nil {
 				continue
 			}
 			if certName, ok := cert.State["name"].(string); ok {
+				listed++
+				if limits.MaxPerLevel > 0 && listed > limits.MaxPerLevel {
+					continue
+				}
 				certDesc := "Certified: " + certName
 				if validUntil, ok := cert.State["valid_until"].(string); ok {
 					certDesc += " (expires " + validUntil + ")"
@@ -147,6 +175,9 @@ func buildNarrative(block *Block, resolve func(string) *Block, visited map[strin
 				parts = append(parts, certDesc+".")
 			}
 		}
+		if limits.MaxPerLevel > 0 && listed > limits.MaxPerLevel {
+			parts = append(parts, fmt.Sprintf("And %d more certifications.", listed-limits.MaxPerLevel))
+		}
 	}
 
 	// Tombstone
@@ -156,3 +187,64 @@ func buildNarrative(block *Block, resolve func(string) *Block, visited map[strin
 
 	return parts
 }
+
+// ExplainNode is one block of a structured explanation: the block, the role
+// its parent refs it by, and the blocks it refs in turn.
+type ExplainNode struct {
+	Hash     string        `json:"hash"`
+	Type     string        `json:"type,omitempty"`
+	Name     string        `json:"name,omitempty"`
+	Role     string        `json:"role,omitempty"`
+	Missing  bool          `json:"missing,omitempty"` // not resolvable here
+	Children []ExplainNode `json:"children,omitempty"`
+}
+
+// ExplainStructured returns the provenance tree of a block, following refs
+// in role order to maxDepth (default 10). A block reached twice appears
+// once, under the first role that reaches it.
+func ExplainStructured(hash string, resolve func(string) *Block, maxDepth int) ExplainNode {
+	if maxDepth <= 0 {
+		maxDepth = 10
+	}
+	visited := map[string]bool{}
+	var walk func(hash, role string, depth int) ExplainNode
+	walk = func(hash, role string, depth int) ExplainNode {
+		visited[hash] = true
+		node := ExplainNode{Hash: hash, Role: role}
+		b := resolve(hash)
+		if b == nil {
+			node.Missing = true
+			return node
+		}
+		node.Type, node.Name = b.Type, blockLabel(*b)
+		if depth >= maxDepth {
+			return node
+		}
+		roles := make([]string, 0, len(b.Refs))
+		for r := range b.Refs {
+			roles = append(roles, r)
+		}
+		sort.Strings(roles)
+		for _, r := range roles {
+			for _, h := range flattenRefValues(map[string]interface{}{r: b.Refs[r]}) {
+				if !visited[h] {
+					node.Children = append(node.Children, walk(h, r, depth+1))
+				}
+			}
+		}
+		return node
+	}
+	return walk(hash, "", 0)
+}
+
+// Hashes lists the resolvable blocks of the tree, root first.
+func (n ExplainNode) Hashes() []string {
+	var out []string
+	if !n.Missing {
+		out = append(out, n.Hash)
+	}
+	for _, c := range n.Children {
+		out = append(out, c.Hashes()...)
+	}
+	return out
+}
@@ -109,3 +109,44 @@ func TestExplainTombstoned(t *testing.T) {
 		t.Errorf("narrative does not contain 'erased', got %q", narrative)
 	}
 }
+
+func TestExplainHistory(t *testing.T) {
+	store := NewMemoryStore()
+	mill := Create("actor.producer", map[string]interface{}{"name": "Mill"}, nil)
+	farm := Create("actor.producer", map[string]interface{}{"name": "Farm"}, nil)
+	v1 := Create("transfer.order", map[string]interface{}{"instance_id": "o1", "price": 4.0, "status": "shipped"}, map[string]interface{}{"seller": mill.Hash})
+	v2 := MergeUpdate(v1, map[string]interface{}{"price": 4.5}, map[string]interface{}{"seller": mill.Hash})
+	v3 := Update(v2.Hash, "transfer.order", map[string]interface{}{"instance_id": "o1", "price": 4.5, "status": "delivered", "date": "2026-03-02"}, map[string]interface{}{"seller": farm.Hash})
+	store.PutAll([]Block{mill, farm, v1, v2, v3})
+
+	// Any version explains the whole chain.
+	h, err := ExplainHistory(v1.Hash, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Head != v3.Hash || len(h.Entries) != 3 || h.Entries[2].Version != 3 {
+		t.Fatalf("history = %+v", h)
+	}
+	if c := h.Entries[1].Changes; len(c) != 1 || c[0].Field != "price" || c[0].From != 4.0 || c[0].To != 4.5 {
+		t.Errorf("v2 changes = %+v", c)
+	}
+	text := h.String()
+	for _, want := range []string{
+		"Created as transfer.order.",
+		"Price changed from $4.00 to $4.50.\n",
+		"v3 (2026-03-02): Date set to 2026-03-02. Status moved from shipped to delivered. Seller changed from Mill to Farm.",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("missing %q in:\n%s", want, text)
+		}
+	}
+
+	store.Put(Tombstone(v3.Hash, "owner"))
+	h, _ = ExplainHistory(v2.Hash, store)
+	if last := h.Entries[len(h.Entries)-1]; last.Summary[0] != "Erased." {
+		t.Errorf("last entry = %+v", last)
+	}
+	if _, err := ExplainHistory("missing", store); err == nil {
+		t.Error("expected an error for an unknown hash")
+	}
+}
@@ -70,6 +70,13 @@ var intents = []intent{
 			"shipped", "delivered", "shipment", "payment", "receipt", "transaction"},
 		Weight: 2,
 	},
+	{
+		Type: "transform.fermentation",
+		Signals: []string{"original gravity", "final gravity", "gravity", "abv", "wort", "fermenter",
+			"fermenting", "pitched", "yeast strain", "conditioning", "brew day", "brewhouse",
+			"dry hopped", "mash tun", "attenuation"},
+		Weight: 3,
+	},
 	{
 		Type: "transform.process",
 		Signals: []string{"baked", "cooked", "fried", "grilled", "roasted", "fermented",
@@ -121,6 +128,10 @@ var numPatterns = []numPattern{
 	{Pattern: regexp.MustCompile(`(?i)\brated?\s*([\d.]+)`), Field: "rating"},
 	{Pattern: regexp.MustCompile(`(?i)\bscore\s*([\d.]+)`), Field: "score"},
 	{Pattern: regexp.MustCompile(`(?i)([\d,]+)\s*units?\b`), Field: "lot_size"},
+	{Pattern: regexp.MustCompile(`(?i)([\d.]+)\s*%\s*abv\b`), Field: "abv"},
+	{Pattern: regexp.MustCompile(`(?i)\b(?:og|original gravity)\s*(?:of\s*)?(1\.\d+)`), Field: "original_gravity"},
+	{Pattern: regexp.MustCompile(`(?i)\b(?:fg|final gravity)\s*(?:of\s*)?(1\.\d+)`), Field: "final_gravity"},
+	{Pattern: regexp.MustCompile(`(?i)([\d.]+)\s*ibus?\b`), Field: "ibu"},
 }
 
 var unitNormalize = map[string]string{
@@ -135,9 +146,70 @@ func FB(text string) FBResult {
 		return FBResult{Text: text}
 	}
 
-	lower := strings.ToLower(text)
+	primaryType := DetectType(text)
+	name := ExtractName(text, primaryType)
+	quantities := ExtractQuantities(text)
+	flags := ExtractFlags(text)
+
+	// Build state
+	state := map[string]interface{}{}
+	if name != "" {
+		state["name"] = name
+	}
+	for field, val := range quantities {
+		state[field] = val
+	}
+	for field, val := range flags {
+		state[field] = val
+	}
+
+	// Type-specific enrichment
+	if primaryType == "observe.review" {
+		state["text"] = text
+	}
+	if primaryType == "observe.reading" {
+		locRe := regexp.MustCompile(`(?i)\b(?:in|at)\s+(?:the\s+)?(.+?)(?:\s*[,.]|$)`)
+		if m := locRe.FindStringSubmatch(text); len(m) > 1 {
+			loc := strings.TrimSpace(m[1])
+			if len(loc) > 1 && len(loc) < 50 {
+				state["location"] = loc
+			}
+		}
+	}
+	if primaryType == "actor.producer" {
+		growsRe := regexp.MustCompile(`(?i)\b(?:grows?|cultivates?|produces?)\s+(.+?)(?:\s*[,.]|\s+in\s+|\s+on\s+|$)`)
+		if m := growsRe.FindStringSubmatch(text); len(m) > 1 {
+			state["crop"] = strings.TrimSpace(m[1])
+		}
+		if v, ok := quantities["acreage"]; ok {
+			if m, ok := v.(map[string]interface{}); ok {
+				state["acreage"] = m["value"]
+			}
+		}
+		regionRe := regexp.MustCompile(`\bin\s+([A-Z][A-Za-z\s]+?)(?:\s*[,.]|$)`)
+		if m := regionRe.FindStringSubmatch(text); len(m) > 1 {
+			state["region"] = strings.TrimSpace(m[1])
+		}
+	}
+
+	// Create primary block
+	refs := map[string]interface{}{}
+	primary := Create(primaryType, state, refs)
+	blocks := []Block{primary}
 
-	// 1. Score intents
+	return FBResult{
+		Blocks:  blocks,
+		Primary: primary,
+		Type:    primaryType,
+		State:   state,
+		Text:    text,
+	}
+}
+
+// DetectType scores the text against each intent's signals and returns the
+// best-scoring block type, substance.product when nothing matches.
+func DetectType(text string) string {
+	lower := strings.ToLower(text)
 	type scored struct {
 		typ   string
 		score int
@@ -151,7 +223,7 @@ func FB(text string) FBResult {
 			}
 		}
 		if s > 0 {
-			scores = append(scores, scored{intent.typ, s})
+			scores = append(scores, scored{intent.Type, s})
 		}
 	}
 	// Sort by score descending
@@ -162,16 +234,15 @@ func FB(text string) FBResult {
 			}
 		}
 	}
-
-	primaryType := "substance.product"
-	if len(scores) > 0 {
-		primaryType = scores[0].typ
+	if len(scores) == 0 {
+		return "substance.product"
 	}
+	return scores[0].typ
+}
 
-	// 2. Extract name
-	name := extractName(text, primaryType)
-
-	// 3. Extract numbers and quantities
+// ExtractQuantities pulls prices, weights, volumes, temperatures and other
+// numeric fields out of text.
+func ExtractQuantities(text string) map[string]interface{} {
 	quantities := map[string]interface{}{}
 	for _, np := range numPatterns {
 		matches := np.Pattern.FindAllStringSubmatch(text, -1)
@@ -194,8 +265,24 @@ func FB(text string) FBResult {
 			}
 		}
 	}
+	return quantities
+}
+
+// QuantityFields lists the fields ExtractQuantities can produce.
+func QuantityFields() []string {
+	var fields []string
+	for _, np := range numPatterns {
+		if !containsStr(fields, np.Field) {
+			fields = append(fields, np.Field)
+		}
+	}
+	return fields
+}
 
-	// 4. Extract boolean flags from all vocabularies
+// ExtractFlags sets the boolean and compound fields of every vocabulary whose
+// aliases appear in text.
+func ExtractFlags(text string) map[string]interface{} {
+	lower := strings.ToLower(text)
 	flags := map[string]interface{}{}
 	for _, vocab := range Vocabularies {
 		for fieldName, fieldDef := range vocab.Fields {
@@ -220,63 +307,12 @@ func FB(text string) FBResult {
 			}
 		}
 	}
-
-	// 5. Build state
-	state := map[string]interface{}{}
-	if name != "" {
-		state["name"] = name
-	}
-	for field, val := range quantities {
-		state[field] = val
-	}
-	for field, val := range flags {
-		state[field] = val
-	}
-
-	// Type-specific enrichment
-	if primaryType == "observe.review" {
-		state["text"] = text
-	}
-	if primaryType == "observe.reading" {
-		locRe := regexp.MustCompile(`(?i)\b(?:in|at)\s+(?:the\s+)?(.+?)(?:\s*[,.]|$)`)
-		if m := locRe.FindStringSubmatch(text); len(m) > 1 {
-			loc := strings.TrimSpace(m[1])
-			if len(loc) > 1 && len(loc) < 50 {
-				state["location"] = loc
-			}
-		}
-	}
-	if primaryType == "actor.producer" {
-		growsRe := regexp.MustCompile(`(?i)\b(?:grows?|cultivates?|produces?)\s+(.+?)(?:\s*[,.]|\s+in\s+|\s+on\s+|$)`)
-		if m := growsRe.FindStringSubmatch(text); len(m) > 1 {
-			state["crop"] = strings.TrimSpace(m[1])
-		}
-		if v, ok := quantities["acreage"]; ok {
-			if m, ok := v.(map[string]interface{}); ok {
-				state["acreage"] = m["value"]
-			}
-		}
-		regionRe := regexp.MustCompile(`\bin\s+([A-Z][A-Za-z\s]+?)(?:\s*[,.]|$)`)
-		if m := regionRe.FindStringSubmatch(text); len(m) > 1 {
-			state["region"] = strings.TrimSpace(m[1])
-		}
-	}
-
-	// 6. Create primary block
-	refs := map[string]interface{}{}
-	primary := Create(primaryType, state, refs)
-	blocks := []Block{primary}
-
-	return FBResult{
-		Blocks:  blocks,
-		Primary: primary,
-		Type:    primaryType,
-		State:   state,
-		Text:    text,
-	}
+	return flags
 }
 
-func extractName(text, typ string) string {
+// ExtractName returns the name FB would give a block of type typ described
+// by text.
+func ExtractName(text, typ string) string {
 	if typ == "observe.review" {
 		atRe := regexp.MustCompile(`(?i)\bat\s+([A-Z][A-Za-z\s']+)`)
 		if m := atRe.FindStringSubmatch(text); len(m) > 1 {
@@ -11,10 +11,11 @@ type WellKnownDoc struct {
 	Templates []string `json:"templates"`
 	Peers     []string `json:"peers"`
 	Endpoints struct {
-		Blocks string `json:"blocks"`
-		Batch  string `json:"batch"`
-		Chain  string `json:"chain"`
-		Heads  string `json:"heads"`
+		Blocks    string `json:"blocks"`
+		Batch     string `json:"batch"`
+		Chain     string `json:"chain"`
+		Heads     string `json:"heads"`
+		Templates string `json:"templates"`
 	} `json:"endpoints"`
 }
 
@@ -70,6 +71,7 @@ func WellKnown(info WellKnownInfo) WellKnownDoc {
 	doc.Endpoints.Batch = "/blocks/batch"
 	doc.Endpoints.Chain = "/chain"
 	doc.Endpoints.Heads = "/heads"
+	doc.Endpoints.Templates = "/templates"
 
 	return doc
 }
@@ -0,0 +1,118 @@
+package foodblock
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FederationHandler serves a store over the federation HTTP API:
+//
+//	GET /.well-known/foodblock   discovery document
+//	GET /blocks                  cursor-paginated listing (type, author, since, order, limit, cursor)
+//	GET /blocks/{hash}           a single block
+//	GET /heads                   latest versions (type), for stores with a head index
+//	GET /changes                 change feed (since, limit), for stores with sequence numbers
+func FederationHandler(store BlockStore, info WellKnownInfo) http.Handler {
+	mux := http.NewServeMux()
+	if feed, ok := store.(ChangeFeed); ok {
+		mux.HandleFunc("/changes", changesHandler(feed))
+	}
+	mux.HandleFunc("/.well-known/foodblock", func(w http.ResponseWriter, r *http.Request) {
+		doc := info
+		doc.Count = store.Len()
+		if doc.Types == nil {
+			doc.Types = storeTypes(store)
+		}
+		writeJSON(w, http.StatusOK, WellKnown(doc))
+	})
+	mux.HandleFunc("/blocks", func(w http.ResponseWriter, r *http.Request) {
+		lister, ok := store.(Lister)
+		if !ok {
+			writeError(w, http.StatusNotImplemented, "store does not support listing")
+			return
+		}
+		opts, err := listOptionsFromQuery(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		page, err := lister.List(opts)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, page)
+	})
+	mux.HandleFunc("/blocks/", func(w http.ResponseWriter, r *http.Request) {
+		hash := strings.TrimPrefix(r.URL.Path, "/blocks/")
+		b := store.Resolve(hash)
+		if b == nil {
+			writeError(w, http.StatusNotFound, "Block not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, b)
+	})
+	mux.HandleFunc("/heads", func(w http.ResponseWriter, r *http.Request) {
+		idx, ok := store.(HeadIndex)
+		if !ok {
+			writeError(w, http.StatusNotImplemented, "store does not index heads")
+			return
+		}
+		heads := idx.AllHeads(r.URL.Query().Get("type"))
+		if limit := ClampLimit(atoi(r.URL.Query().Get("limit"))); len(heads) > limit {
+			heads = heads[:limit]
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"count": len(heads), "blocks": heads})
+	})
+	return mux
+}
+
+func listOptionsFromQuery(r *http.Request) (ListOptions, error) {
+	q := r.URL.Query()
+	opts := ListOptions{
+		Type:   q.Get("type"),
+		Author: q.Get("author"),
+		Order:  q.Get("order"),
+		Limit:  atoi(q.Get("limit")),
+		Cursor: q.Get("cursor"),
+	}
+	if since := q.Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return opts, err
+		}
+		opts.Since = t
+	}
+	return opts, nil
+}
+
+func storeTypes(store BlockStore) []string {
+	if ti, ok := store.(interface{ TypeIndex() map[string][]string }); ok {
+		var types []string
+		for t := range ti.TypeIndex() {
+			types = append(types, t)
+		}
+		sort.Strings(types)
+		return types
+	}
+	return nil
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,238 @@
+package foodblock
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// FermentationType is a brewery or fermentation batch. It moves through the
+// "fermentation" vocabulary's workflow: brew → ferment → condition → package,
+// or to dumped from any active stage.
+const FermentationType = "transform.fermentation"
+
+// GravityStableWindow is how long gravity must hold within
+// GravityStableTolerance before fermentation is considered complete.
+const GravityStableWindow = 48 * time.Hour
+
+// GravityStableTolerance is the largest gravity drift, in specific gravity
+// points, over GravityStableWindow for a stable reading.
+const GravityStableTolerance = 0.001
+
+// Brew describes a new batch on brew day.
+type Brew struct {
+	Name            string
+	Brewery         string
+	Style           string
+	Vessel          string
+	YeastStrain     string
+	OriginalGravity float64  // specific gravity, e.g. 1.052; optional until fermentation starts
+	IBU             float64  // optional
+	Inputs          []string // malt, hops, yeast, water, ...
+	Date            time.Time
+}
+
+// StartBrew creates a transform.fermentation batch in the brew stage.
+func StartBrew(b Brew) (Block, error) {
+	if b.Name == "" || b.Brewery == "" {
+		return Block{}, errors.New("FoodBlock: brew needs a name and a brewery")
+	}
+	if b.Date.IsZero() {
+		b.Date = time.Now()
+	}
+	state := map[string]interface{}{
+		"name":      b.Name,
+		"status":    Vocabularies["fermentation"].Initial,
+		"brewed_at": b.Date.UTC().Format(time.RFC3339),
+	}
+	for field, v := range map[string]string{"style": b.Style, "vessel": b.Vessel, "yeast_strain": b.YeastStrain} {
+		if v != "" {
+			state[field] = v
+		}
+	}
+	if b.OriginalGravity > 0 {
+		state["original_gravity"] = b.OriginalGravity
+	}
+	if b.IBU > 0 {
+		state["ibu"] = b.IBU
+	}
+	refs := map[string]interface{}{"operator": b.Brewery}
+	if len(b.Inputs) > 0 {
+		refs["inputs"] = refList(b.Inputs)
+	}
+	return Create(FermentationType, state, refs), nil
+}
+
+// AdvanceBatch moves a batch to its next stage, applying changes in the same
+// update so they count towards the transition's guards. Leaving the ferment
+// stage fills final_gravity from the latest gravity reading in graph when
+// changes do not set it, and records fermentation_days, apparent_attenuation
+// and abv (unless given) from the batch's gravities.
+func AdvanceBatch(batch Block, to string, changes map[string]interface{}, graph []Block, now time.Time) (Block, error) {
+	if batch.Type != FermentationType {
+		return Block{}, fmt.Errorf("FoodBlock: %s is not a fermentation batch", batch.Hash)
+	}
+	state := map[string]interface{}{}
+	for k, v := range changes {
+		state[k] = v
+	}
+	from, _ := batch.State["status"].(string)
+	if from == "ferment" && to == "condition" {
+		if _, ok := state["final_gravity"]; !ok {
+			if readings := GravityReadings(batch, graph); len(readings) > 0 {
+				state["final_gravity"] = readings[len(readings)-1].Gravity
+			}
+		}
+		if started, ok := batch.State["fermenting_at"].(string); ok {
+			if t, err := time.Parse(time.RFC3339, started); err == nil {
+				state["fermentation_days"] = math.Floor(now.Sub(t).Hours()/24*10) / 10
+			}
+		}
+	}
+
+	candidate := batch
+	candidate.State = map[string]interface{}{}
+	for k, v := range batch.State {
+		candidate.State[k] = v
+	}
+	for k, v := range state {
+		candidate.State[k] = v
+	}
+	og, _ := toFloat64(candidate.State["original_gravity"])
+	fg, _ := toFloat64(candidate.State["final_gravity"])
+	if _, ok := state["final_gravity"]; ok && og > 1 && fg > 0 {
+		state["apparent_attenuation"] = round3(ApparentAttenuation(og, fg))
+		if _, given := candidate.State["abv"]; !given {
+			state["abv"] = round3(EstimateABV(og, fg))
+			candidate.State["abv"] = state["abv"]
+		}
+	}
+
+	check := CheckTransition(Vocabularies["fermentation"], candidate, to, graph, nil)
+	if !check.Allowed {
+		return Block{}, errors.New("FoodBlock: " + check.Reason)
+	}
+	state["status"] = to
+	state["previous_status"] = check.From
+	state[stageTimestamp(to)] = now.UTC().Format(time.RFC3339)
+	refs := map[string]interface{}{}
+	for k, v := range batch.Refs {
+		if k != "updates" {
+			refs[k] = v
+		}
+	}
+	return MergeUpdate(batch, state, refs), nil
+}
+
+// stageTimestamp names the state field recording when a batch entered a stage.
+func stageTimestamp(stage string) string {
+	switch stage {
+	case "ferment":
+		return "fermenting_at"
+	case "condition":
+		return "conditioning_at"
+	case "package":
+		return "packaged_at"
+	}
+	return stage + "_at"
+}
+
+// RecordGravity creates an observe.reading of a batch's specific gravity.
+func RecordGravity(batch Block, gravity float64, by string, at time.Time) (Block, error) {
+	if gravity < 0.98 || gravity > 1.2 {
+		return Block{}, fmt.Errorf("FoodBlock: implausible specific gravity %.3f", gravity)
+	}
+	refs := map[string]interface{}{"subject": batch.Hash}
+	if by != "" {
+		refs["author"] = by
+	}
+	return Create("observe.reading", map[string]interface{}{
+		"instance_id": fmt.Sprintf("gravity-%s-%d", batch.Hash[:12], at.Unix()),
+		"gravity":     gravity,
+		"at":          at.UTC().Format(time.RFC3339),
+	}, refs), nil
+}
+
+// GravityReading is one gravity measurement of a batch.
+type GravityReading struct {
+	Reading string    `json:"reading"`
+	Gravity float64   `json:"gravity"`
+	At      time.Time `json:"at"`
+}
+
+// GravityReadings returns the gravity readings in graph about any version of
+// the batch, oldest first.
+func GravityReadings(batch Block, graph []Block) []GravityReading {
+	versions, _ := chainHashes(batch, graph)
+	var out []GravityReading
+	for _, b := range graph {
+		if b.Type != "observe.reading" {
+			continue
+		}
+		subject, _ := b.Refs["subject"].(string)
+		g, ok := toFloat64(b.State["gravity"])
+		if !ok || !versions[subject] {
+			continue
+		}
+		at, _ := readingTime(b)
+		out = append(out, GravityReading{Reading: b.Hash, Gravity: g, At: at})
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].At.Before(out[j].At) })
+	return out
+}
+
+// ApparentAttenuation is the percentage of the original gravity's extract
+// that has been fermented out.
+func ApparentAttenuation(og, fg float64) float64 {
+	if og <= 1 {
+		return 0
+	}
+	return (og - fg) / (og - 1) * 100
+}
+
+// EstimateABV estimates alcohol by volume from original and final gravity
+// with the standard homebrew formula (og - fg) × 131.25.
+func EstimateABV(og, fg float64) float64 {
+	return (og - fg) * 131.25
+}
+
+// FermentationProgress summarises a batch's gravity readings.
+type FermentationProgress struct {
+	Batch               string           `json:"batch"`
+	Status              string           `json:"status"`
+	OriginalGravity     float64          `json:"original_gravity"`
+	CurrentGravity      float64          `json:"current_gravity"`
+	ApparentAttenuation float64          `json:"apparent_attenuation"` // percent
+	ABV                 float64          `json:"abv"`                  // estimated so far
+	Readings            []GravityReading `json:"readings"`
+	Stable              bool             `json:"stable"` // gravity held within tolerance for GravityStableWindow
+}
+
+// TrackFermentation reports a batch's attenuation and estimated ABV from its
+// latest gravity reading, and whether gravity has stabilised.
+func TrackFermentation(batch Block, graph []Block) FermentationProgress {
+	p := FermentationProgress{Batch: batch.Hash, Readings: GravityReadings(batch, graph)}
+	p.Status, _ = batch.State["status"].(string)
+	p.OriginalGravity, _ = toFloat64(batch.State["original_gravity"])
+	if len(p.Readings) == 0 {
+		return p
+	}
+	last := p.Readings[len(p.Readings)-1]
+	p.CurrentGravity = last.Gravity
+	if p.OriginalGravity > 1 {
+		p.ApparentAttenuation = round3(ApparentAttenuation(p.OriginalGravity, last.Gravity))
+		p.ABV = round3(EstimateABV(p.OriginalGravity, last.Gravity))
+	}
+	earliest := last
+	for i := len(p.Readings) - 2; i >= 0; i-- {
+		r := p.Readings[i]
+		if math.Abs(r.Gravity-last.Gravity) > GravityStableTolerance+1e-9 {
+			break
+		}
+		earliest = r
+	}
+	p.Stable = !last.At.IsZero() && last.At.Sub(earliest.At) >= GravityStableWindow
+	return p
+}
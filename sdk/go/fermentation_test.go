@@ -0,0 +1,78 @@
+package foodblock
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFermentationBatchLifecycle(t *testing.T) {
+	day := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	brewery := Create("actor.brewery", map[string]interface{}{"name": "Harbour Brew Co"}, nil)
+	batch, err := StartBrew(Brew{Name: "Pale Ale #42", Brewery: brewery.Hash, Style: "pale ale", Vessel: "FV3", IBU: 38, Date: day})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batch.State["status"] != "brew" {
+		t.Fatalf("expected a batch in the brew stage, got %v", batch.State["status"])
+	}
+	if _, err := AdvanceBatch(batch, "ferment", nil, nil, day); err == nil || !strings.Contains(err.Error(), "original_gravity") {
+		t.Errorf("expected pitching without an OG to fail, got %v", err)
+	}
+	fermenting, err := AdvanceBatch(batch, "ferment", map[string]interface{}{"original_gravity": 1.052, "yeast_strain": "US-05"}, nil, day)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	graph := []Block{batch, fermenting}
+	for i, g := range []float64{1.030, 1.014, 1.011, 1.010, 1.010} {
+		r, err := RecordGravity(fermenting, g, brewery.Hash, day.Add(time.Duration(i+1)*24*time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		graph = append(graph, r)
+	}
+	p := TrackFermentation(fermenting, graph)
+	if len(p.Readings) != 5 || p.CurrentGravity != 1.010 || p.ApparentAttenuation != 80.769 || p.ABV != 5.513 || !p.Stable {
+		t.Errorf("unexpected progress %+v", p)
+	}
+	if p := TrackFermentation(fermenting, graph[:5]); p.Stable {
+		t.Errorf("gravity that is still dropping should not be stable, got %+v", p)
+	}
+
+	conditioning, err := AdvanceBatch(fermenting, "condition", nil, graph, day.Add(6*24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conditioning.State["final_gravity"] != 1.010 || conditioning.State["abv"] != 5.513 || conditioning.State["fermentation_days"] != 6.0 {
+		t.Errorf("expected FG, ABV and days recorded on conditioning, got %v", conditioning.State)
+	}
+	packaged, err := AdvanceBatch(conditioning, "package", nil, graph, day.Add(20*24*time.Hour))
+	if err != nil || packaged.State["status"] != "package" || packaged.Refs["operator"] != brewery.Hash {
+		t.Errorf("expected the batch packaged with its refs kept, got %v %v", packaged.State, err)
+	}
+	if _, err := AdvanceBatch(packaged, "ferment", nil, graph, day); err == nil {
+		t.Error("expected no transitions out of package")
+	}
+	if _, err := RecordGravity(batch, 1.5, "", day); err == nil {
+		t.Error("expected an implausible gravity to be rejected")
+	}
+}
+
+func TestFermentationVocabularyAndFB(t *testing.T) {
+	result := MapFields("yeast wlp001 in fermenter fv2 with original gravity 1.060", Vocabularies["fermentation"])
+	if result.Matched["original_gravity"] != 1.060 || result.Matched["vessel"] != "fv2" || result.Matched["yeast_strain"] != "wlp001" {
+		t.Errorf("unexpected fields %v", result.Matched)
+	}
+	if a := AnalyzeWorkflow(Vocabularies["fermentation"]); len(a.Unreachable) != 0 || len(a.DeadEnds) != 0 {
+		t.Errorf("unexpected workflow problems %+v", a)
+	}
+
+	fb := FB("Brew day: pale ale in the fermenter, original gravity 1.052, 38 IBU, 5.4% ABV")
+	if fb.Type != "transform.fermentation" {
+		t.Fatalf("expected a fermentation block, got %s", fb.Type)
+	}
+	if fb.State["original_gravity"] != 1.052 || fb.State["ibu"] != 38.0 || fb.State["abv"] != 5.4 {
+		t.Errorf("unexpected state %v", fb.State)
+	}
+}
@@ -0,0 +1,426 @@
+package foodblock
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Flow error policies, set per stage with OnError.
+const (
+	FlowAbort    = "abort"    // stop the run (the default)
+	FlowSkip     = "skip"     // drop the item and go on with the next one
+	FlowContinue = "continue" // record the error and run the item's next stage
+)
+
+// Built-in flow stages, by the op names flow definitions use.
+const (
+	StageParseFBN = "parse_fbn"
+	StageValidate = "validate"
+	StageSign     = "sign"
+	StageStore    = "store"
+	StagePublish  = "publish"
+)
+
+// FlowItem is one block moving through a flow.
+type FlowItem struct {
+	Index    int          `json:"index"`
+	Line     string       `json:"line,omitempty"` // FBN source
+	Block    Block        `json:"block"`
+	Signed   *SignedBlock `json:"signed,omitempty"`
+	Warnings []string     `json:"warnings,omitempty"`
+	Skipped  bool         `json:"skipped"`
+}
+
+// FlowError is a stage failing for an item.
+type FlowError struct {
+	Stage string `json:"stage"`
+	Index int    `json:"index"`
+	Err   string `json:"error"`
+}
+
+// StageFunc runs one stage for an item, updating it in place.
+type StageFunc func(ctx context.Context, item *FlowItem) error
+
+// FlowStage is a named step with its error policy.
+type FlowStage struct {
+	Name    string
+	Run     StageFunc
+	OnError string // FlowAbort, FlowSkip or FlowContinue
+	Retries int    // extra attempts before the policy applies
+}
+
+// FlowHooks observe a run. Before may veto a stage by returning an error,
+// which is handled like the stage failing.
+type FlowHooks struct {
+	Before func(stage string, item *FlowItem) error
+	After  func(stage string, item *FlowItem, err error)
+}
+
+// Publisher hands a block on once it is stored: to a federation peer, a
+// queue or a webhook.
+type Publisher interface {
+	Publish(ctx context.Context, signed SignedBlock) error
+}
+
+// PublisherFunc adapts a function into a Publisher.
+type PublisherFunc func(ctx context.Context, signed SignedBlock) error
+
+// Publish calls f.
+func (f PublisherFunc) Publish(ctx context.Context, signed SignedBlock) error {
+	return f(ctx, signed)
+}
+
+// Flow composes SDK operations into an ingest pipeline. Items run through
+// every stage in turn, one item at a time, so later items can ref blocks
+// earlier ones stored.
+type Flow struct {
+	Name   string
+	Stages []FlowStage
+	hooks  []FlowHooks
+}
+
+// FlowReport is the outcome of a run.
+type FlowReport struct {
+	Flow      string      `json:"flow"`
+	Items     []FlowItem  `json:"items"`
+	Completed int         `json:"completed"` // items through every stage
+	Skipped   int         `json:"skipped"`
+	Errors    []FlowError `json:"errors"`
+	Aborted   bool        `json:"aborted"`
+}
+
+// NewFlow creates an empty flow.
+func NewFlow(name string) *Flow {
+	return &Flow{Name: name}
+}
+
+// Stage appends a stage that aborts the run on error.
+func (f *Flow) Stage(name string, run StageFunc) *Flow {
+	f.Stages = append(f.Stages, FlowStage{Name: name, Run: run, OnError: FlowAbort})
+	return f
+}
+
+// OnError sets the error policy of the last stage.
+func (f *Flow) OnError(policy string) *Flow {
+	if n := len(f.Stages); n > 0 {
+		f.Stages[n-1].OnError = policy
+	}
+	return f
+}
+
+// Retry lets the last stage be attempted n more times before its error
+// policy applies.
+func (f *Flow) Retry(n int) *Flow {
+	if k := len(f.Stages); k > 0 {
+		f.Stages[k-1].Retries = n
+	}
+	return f
+}
+
+// Hooks adds hooks called around every stage.
+func (f *Flow) Hooks(h FlowHooks) *Flow {
+	f.hooks = append(f.hooks, h)
+	return f
+}
+
+// ParseFBN appends a stage turning an item's FBN line into a block. @alias
+// refs resolve against aliases, which records each new block's alias; nil
+// uses a registry private to the flow. Items that already hold a block pass
+// through.
+func (f *Flow) ParseFBN(aliases *Registry) *Flow {
+	if aliases == nil {
+		aliases = NewRegistry()
+	}
+	return f.Stage(StageParseFBN, func(ctx context.Context, item *FlowItem) error {
+		if item.Block.Type != "" {
+			return nil
+		}
+		parsed, err := ParseNotation(item.Line)
+		if err != nil {
+			return err
+		}
+		if parsed == nil {
+			return errors.New("FoodBlock: line holds no block")
+		}
+		block, err := aliases.Create(parsed.Type, parsed.State, parsed.Refs, parsed.Alias)
+		if err != nil {
+			return err
+		}
+		item.Block = block
+		return nil
+	})
+}
+
+// Validate appends a stage running the ValidatePipeline checks on each item.
+// Errors fail the stage; warnings are kept on the item.
+func (f *Flow) Validate(opts PipelineOptions) *Flow {
+	return f.Stage(StageValidate, func(ctx context.Context, item *FlowItem) error {
+		signed := SignedBlock{FoodBlock: item.Block}
+		if item.Signed != nil {
+			signed = *item.Signed
+		}
+		var errs []string
+		for _, is := range ValidatePipeline([]SignedBlock{signed}, opts).Blocks[0].Issues {
+			if is.Severity == "error" {
+				errs = append(errs, is.Message)
+			} else {
+				item.Warnings = append(item.Warnings, is.Message)
+			}
+		}
+		if len(errs) > 0 {
+			return errors.New("FoodBlock: " + strings.Join(errs, "; "))
+		}
+		return nil
+	})
+}
+
+// Sign appends a stage signing each item's block as authorHash.
+func (f *Flow) Sign(authorHash string, privateKey []byte) *Flow {
+	return f.Stage(StageSign, func(ctx context.Context, item *FlowItem) error {
+		signed := Sign(item.Block, authorHash, privateKey)
+		item.Signed = &signed
+		return nil
+	})
+}
+
+// Store appends a stage writing each item to store, with its signature when
+// the item is signed and the store keeps signatures.
+func (f *Flow) Store(store BlockStore) *Flow {
+	return f.Stage(StageStore, func(ctx context.Context, item *FlowItem) error {
+		if ps, ok := store.(interface{ PutSigned(SignedBlock) error }); ok && item.Signed != nil {
+			return ps.PutSigned(*item.Signed)
+		}
+		return store.Put(item.Block)
+	})
+}
+
+// Publish appends a stage handing each item to p; unsigned items are sent
+// as a wrapper without a signature.
+func (f *Flow) Publish(p Publisher) *Flow {
+	return f.Stage(StagePublish, func(ctx context.Context, item *FlowItem) error {
+		if item.Signed != nil {
+			return p.Publish(ctx, *item.Signed)
+		}
+		return p.Publish(ctx, SignedBlock{FoodBlock: item.Block})
+	})
+}
+
+// RunFBN runs the flow over the blocks of an FBN document, one item per
+// line; blank lines and comments are left out.
+func (f *Flow) RunFBN(ctx context.Context, text string) (FlowReport, error) {
+	var items []FlowItem
+	for _, line := range strings.Split(text, "\n") {
+		if parsed, err := ParseNotation(line); err == nil && parsed == nil {
+			continue
+		}
+		items = append(items, FlowItem{Line: strings.TrimSpace(line)})
+	}
+	return f.Run(ctx, items)
+}
+
+// RunBlocks runs the flow over blocks.
+func (f *Flow) RunBlocks(ctx context.Context, blocks []Block) (FlowReport, error) {
+	items := make([]FlowItem, len(blocks))
+	for i, b := range blocks {
+		items[i] = FlowItem{Block: b}
+	}
+	return f.Run(ctx, items)
+}
+
+// Run passes each item through the stages in order. A failing stage is
+// retried as configured and then handled by its policy; the error returned
+// is the one that aborted the run, with the report covering the items up to
+// it.
+func (f *Flow) Run(ctx context.Context, items []FlowItem) (FlowReport, error) {
+	report := FlowReport{Flow: f.Name, Items: make([]FlowItem, 0, len(items)), Errors: []FlowError{}}
+	for i := range items {
+		item := items[i]
+		item.Index = i
+		for _, stage := range f.Stages {
+			err := f.runStage(ctx, stage, &item)
+			if err == nil {
+				continue
+			}
+			report.Errors = append(report.Errors, FlowError{Stage: stage.Name, Index: i, Err: err.Error()})
+			if ctx.Err() == nil && stage.OnError == FlowContinue {
+				continue
+			}
+			if ctx.Err() == nil && stage.OnError == FlowSkip {
+				item.Skipped = true
+				break
+			}
+			report.Items = append(report.Items, item)
+			report.Aborted = true
+			return report, fmt.Errorf("FoodBlock: flow %s: %s failed for item %d: %w", f.Name, stage.Name, i, err)
+		}
+		if item.Skipped {
+			report.Skipped++
+		} else {
+			report.Completed++
+		}
+		report.Items = append(report.Items, item)
+	}
+	return report, nil
+}
+
+// runStage runs a stage with its hooks and retries.
+func (f *Flow) runStage(ctx context.Context, stage FlowStage, item *FlowItem) error {
+	var err error
+	for attempt := 0; attempt <= stage.Retries; attempt++ {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		for _, h := range f.hooks {
+			if h.Before != nil && err == nil {
+				err = h.Before(stage.Name, item)
+			}
+		}
+		if err == nil {
+			err = stage.Run(ctx, item)
+		}
+		for _, h := range f.hooks {
+			if h.After != nil {
+				h.After(stage.Name, item, err)
+			}
+		}
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// FlowDefinition is a flow described as data:
+//
+//	{"name": "pos-ingest", "stages": [
+//	  {"op": "parse_fbn"},
+//	  {"op": "validate", "on_error": "skip"},
+//	  {"op": "sign"}, {"op": "store"},
+//	  {"op": "publish", "on_error": "continue", "retries": 2}]}
+type FlowDefinition struct {
+	Name   string          `json:"name"`
+	Stages []FlowStageSpec `json:"stages"`
+}
+
+// FlowStageSpec is one stage of a FlowDefinition.
+type FlowStageSpec struct {
+	Op      string `json:"op"`
+	OnError string `json:"on_error,omitempty"` // default FlowAbort
+	Retries int    `json:"retries,omitempty"`
+}
+
+// FlowEnv supplies what a flow definition's stages run against; keys and
+// stores stay out of the definition itself.
+type FlowEnv struct {
+	Store      BlockStore
+	Aliases    *Registry // default the store's aliases, if it keeps any
+	AuthorHash string
+	PrivateKey []byte
+	Publisher  Publisher
+	Validation PipelineOptions // Store defaults to the env's store
+	Stages     map[string]StageFunc
+}
+
+// LoadFlow builds a flow from a JSON FlowDefinition. Ops are the built-in
+// stages or names in env.Stages.
+func LoadFlow(r io.Reader, env FlowEnv) (*Flow, error) {
+	var def FlowDefinition
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&def); err != nil {
+		return nil, fmt.Errorf("FoodBlock: flow definition: %w", err)
+	}
+	return def.Build(env)
+}
+
+// Build turns a definition into a flow, checking env supplies every stage.
+func (def FlowDefinition) Build(env FlowEnv) (*Flow, error) {
+	if env.Aliases == nil {
+		if as, ok := env.Store.(AliasStore); ok {
+			env.Aliases = as.Aliases()
+		}
+	}
+	if env.Validation.Store == nil {
+		env.Validation.Store = env.Store
+	}
+	f := NewFlow(def.Name)
+	for _, s := range def.Stages {
+		switch s.OnError {
+		case "":
+			s.OnError = FlowAbort
+		case FlowAbort, FlowSkip, FlowContinue:
+		default:
+			return nil, fmt.Errorf("FoodBlock: stage %s has unknown error policy %q", s.Op, s.OnError)
+		}
+		switch s.Op {
+		case StageParseFBN:
+			f.ParseFBN(env.Aliases)
+		case StageValidate:
+			f.Validate(env.Validation)
+		case StageSign:
+			if env.AuthorHash == "" || len(env.PrivateKey) == 0 {
+				return nil, errors.New("FoodBlock: sign stage needs an author and private key")
+			}
+			f.Sign(env.AuthorHash, env.PrivateKey)
+		case StageStore:
+			if env.Store == nil {
+				return nil, errors.New("FoodBlock: store stage needs a store")
+			}
+			f.Store(env.Store)
+		case StagePublish:
+			if env.Publisher == nil {
+				return nil, errors.New("FoodBlock: publish stage needs a publisher")
+			}
+			f.Publish(env.Publisher)
+		default:
+			run, ok := env.Stages[s.Op]
+			if !ok {
+				return nil, fmt.Errorf("FoodBlock: unknown flow stage %s", s.Op)
+			}
+			f.Stage(s.Op, run)
+		}
+		f.OnError(s.OnError).Retry(s.Retries)
+	}
+	return f, nil
+}
+
+// Publish posts a block to the peer's POST /blocks, as a signed wrapper when
+// it carries a signature.
+func (c *FederationClient) Publish(ctx context.Context, signed SignedBlock) error {
+	var body interface{} = signed
+	if signed.Signature == "" {
+		body = signed.FoodBlock
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/blocks", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		var out struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&out)
+		return fmt.Errorf("FoodBlock: peer returned %d: %s", resp.StatusCode, out.Error)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package foodblock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const flowFBN = `# morning intake
+@farm = actor.producer { name: "Green Acres" }
+@wheat = substance.product { name: "Wheat", price: 0.4 } -> seller: @farm
+substance.product { name: "Rye" } -> seller: @mill
+observe.reading { instance_id: "r1", temperature: 4.1 } -> subject: @wheat
+`
+
+func TestFlowBuilder(t *testing.T) {
+	store := NewMemoryStore()
+	_, priv := GenerateKeypair()
+	var published []SignedBlock
+	fails := 1
+	publisher := PublisherFunc(func(ctx context.Context, s SignedBlock) error {
+		if fails > 0 {
+			fails--
+			return errors.New("peer unavailable")
+		}
+		published = append(published, s)
+		return nil
+	})
+	var stages []string
+	flow := NewFlow("intake").
+		ParseFBN(nil).OnError(FlowSkip).
+		Validate(PipelineOptions{Store: store}).OnError(FlowSkip).
+		Sign("author-1", priv).
+		Store(store).
+		Publish(publisher).Retry(1).
+		Hooks(FlowHooks{After: func(stage string, item *FlowItem, err error) {
+			if err == nil {
+				stages = append(stages, stage)
+			}
+		}})
+
+	report, err := flow.RunFBN(context.Background(), flowFBN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Items) != 4 || report.Completed != 3 || report.Skipped != 1 {
+		t.Fatalf("report = %+v", report)
+	}
+	// The publish that failed once succeeded on its retry.
+	if len(report.Errors) != 1 || report.Errors[0].Stage != StageParseFBN || report.Errors[0].Index != 2 || fails != 0 {
+		t.Errorf("errors = %+v", report.Errors)
+	}
+	if store.Len() != 3 || len(published) != 3 || store.AuthorOf(published[0].FoodBlock.Hash) != "author-1" {
+		t.Errorf("stored %d, published %d", store.Len(), len(published))
+	}
+	reading := report.Items[3].Block
+	if reading.Refs["subject"] != report.Items[1].Block.Hash {
+		t.Errorf("alias not resolved: %v", reading.Refs)
+	}
+	if len(stages) != 15 {
+		t.Errorf("stages = %v", stages)
+	}
+}
+
+func TestFlowAbortAndVeto(t *testing.T) {
+	store := NewMemoryStore()
+	orphan := Create("substance.product", map[string]interface{}{"name": "Flour"}, map[string]interface{}{"seller": "missing"})
+	ok := Create("actor.producer", map[string]interface{}{"name": "Mill"}, nil)
+
+	flow := NewFlow("strict").Validate(PipelineOptions{Store: store}).Store(store)
+	report, err := flow.RunBlocks(context.Background(), []Block{ok, orphan, ok})
+	if err == nil || !report.Aborted || report.Completed != 1 || len(report.Items) != 2 || store.Len() != 1 {
+		t.Fatalf("err = %v, report = %+v", err, report)
+	}
+
+	// A hook vetoing a stage is handled by the stage's policy.
+	vetoed := NewMemoryStore()
+	flow = NewFlow("veto").Store(vetoed).OnError(FlowContinue).Hooks(FlowHooks{Before: func(stage string, item *FlowItem) error {
+		if item.Block.Type == "actor.producer" {
+			return errors.New("producers are imported elsewhere")
+		}
+		return nil
+	}})
+	report, err = flow.RunBlocks(context.Background(), []Block{ok, orphan})
+	if err != nil || report.Completed != 2 || len(report.Errors) != 1 || vetoed.Len() != 1 {
+		t.Errorf("err = %v, report = %+v", err, report)
+	}
+}
+
+func TestLoadFlow(t *testing.T) {
+	pub, priv := GenerateKeypair()
+	actor := Create("actor.producer", map[string]interface{}{"name": "Bakery"}, nil)
+	keys := NewKeyRegistry()
+	keys.Register(actor.Hash, pub)
+
+	var received []json.RawMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body json.RawMessage
+		json.NewDecoder(r.Body).Decode(&body)
+		received = append(received, body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	store := NewMemoryStore()
+	store.Put(actor)
+	def := `{"name": "bakery-ingest", "stages": [
+		{"op": "parse_fbn"},
+		{"op": "sign"},
+		{"op": "validate", "on_error": "skip"},
+		{"op": "store"},
+		{"op": "publish", "retries": 2}
+	]}`
+	env := FlowEnv{
+		Store:      store,
+		AuthorHash: actor.Hash,
+		PrivateKey: priv,
+		Publisher:  NewFederationClient(srv.URL),
+		Validation: PipelineOptions{Keys: keys, RequireSignatures: true},
+	}
+	flow, err := LoadFlow(strings.NewReader(def), env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(flow.Stages) != 5 || flow.Stages[2].OnError != FlowSkip || flow.Stages[4].Retries != 2 {
+		t.Fatalf("stages = %+v", flow.Stages)
+	}
+	report, err := flow.RunFBN(context.Background(), `substance.product { name: "Sourdough", price: 4.5 }`)
+	if err != nil || report.Completed != 1 {
+		t.Fatalf("err = %v, report = %+v", err, report)
+	}
+	if len(received) != 1 || !strings.Contains(string(received[0]), `"author_hash":"`+actor.Hash) {
+		t.Errorf("published %s", received)
+	}
+
+	for _, bad := range []struct {
+		def string
+		env FlowEnv
+	}{
+		{`{"stages": [{"op": "sign"}]}`, FlowEnv{}},
+		{`{"stages": [{"op": "enrich"}]}`, env},
+		{`{"stages": [{"op": "store", "on_error": "ignore"}]}`, env},
+		{`{"stages": [{"op": "store"}], "extra": true}`, env},
+	} {
+		if _, err := LoadFlow(strings.NewReader(bad.def), bad.env); err == nil {
+			t.Errorf("%s: expected an error", bad.def)
+		}
+	}
+
+	env.Stages = map[string]StageFunc{"enrich": func(ctx context.Context, item *FlowItem) error { return nil }}
+	if _, err := LoadFlow(strings.NewReader(`{"stages": [{"op": "enrich"}]}`), env); err != nil {
+		t.Error(err)
+	}
+}
@@ -32,10 +32,23 @@ type SignedBlock struct {
 	AuthorHash      string `json:"author_hash"`
 	Signature       string `json:"signature"`
 	ProtocolVersion string `json:"protocol_version"`
+	Clock           *Clock `json:"clock,omitempty"` // set by stores, not signed
+	// Write binding, set by SignWrite and signed by WriteSignature.
+	Nonce          string `json:"nonce,omitempty"`
+	Expires        string `json:"expires,omitempty"`
+	Audience       string `json:"audience,omitempty"`
+	WriteSignature string `json:"write_signature,omitempty"`
 }
 
-// Create makes a new FoodBlock.
+// Create makes a new FoodBlock. Event blocks without an instance_id get one
+// from the package's InstanceIDStrategy.
 func Create(typ string, state, refs map[string]interface{}) Block {
+	return CreateWithInstanceIDs(currentInstanceIDStrategy(), typ, state, refs)
+}
+
+// CreateWithInstanceIDs is Create with the instance_id strategy for this
+// call.
+func CreateWithInstanceIDs(strategy InstanceIDStrategy, typ string, state, refs map[string]interface{}) Block {
 	if state == nil {
 		state = map[string]interface{}{}
 	}
@@ -49,7 +62,7 @@ func Create(typ string, state, refs map[string]interface{}) Block {
 	if isEventType(typ) {
 		if _, hasID := state["instance_id"]; !hasID {
 			injected = make(map[string]interface{})
-			injected["instance_id"] = generateUUID()
+			injected["instance_id"] = strategy(typ, state, refs)
 			for k, v := range state {
 				injected[k] = v
 			}
@@ -167,7 +180,9 @@ func Chain(startHash string, resolve func(string) *Block, maxDepth int) []Block
 }
 
 // MergeUpdate creates an update by merging changes into the previous block's state.
-// Shallow-merges stateChanges into previousBlock.State.
+// Shallow-merges stateChanges into previousBlock.State. Provenance annotations
+// (state._prov) carry over for unchanged fields and are merged with any given
+// in stateChanges.
 func MergeUpdate(previousBlock Block, stateChanges, additionalRefs map[string]interface{}) Block {
 	mergedState := make(map[string]interface{})
 	for k, v := range previousBlock.State {
@@ -178,10 +193,15 @@ func MergeUpdate(previousBlock Block, stateChanges, additionalRefs map[string]in
 			mergedState[k] = v
 		}
 	}
+	delete(mergedState, ProvenanceKey)
+	if prov := mergeProvenance(previousBlock.State, stateChanges); len(prov) > 0 {
+		mergedState[ProvenanceKey] = prov
+	}
 	return Update(previousBlock.Hash, previousBlock.Type, mergedState, additionalRefs)
 }
 
 // Head finds the latest version in an update chain by walking forward.
+// Stores implementing HeadIndex answer the same question with HeadOf.
 func Head(startHash string, resolveForward func(string) []Block, maxDepth int) string {
 	if maxDepth <= 0 {
 		maxDepth = 1000
@@ -291,7 +311,40 @@ func canonicalNumber(n float64) string {
 		// Use Sprintf instead of FormatInt to avoid int64 overflow for large values
 		return fmt.Sprintf("%.0f", n)
 	}
-	return strconv.FormatFloat(n, 'f', -1, 64)
+
+	// Match ECMAScript Number::toString: shortest digits, decimal notation
+	// for exponents in (-7, 21], scientific notation otherwise.
+	prefix := ""
+	if n < 0 {
+		prefix = "-"
+		n = -n
+	}
+	sci := strconv.FormatFloat(n, 'e', -1, 64)
+	mantissa, expStr, _ := strings.Cut(sci, "e")
+	exp, _ := strconv.Atoi(expStr)
+	digits := strings.Replace(mantissa, ".", "", 1)
+	pos := exp + 1
+
+	var result string
+	switch {
+	case len(digits) <= pos && pos <= 21:
+		result = digits + strings.Repeat("0", pos-len(digits))
+	case 0 < pos && pos <= 21:
+		result = digits[:pos] + "." + digits[pos:]
+	case -6 < pos && pos <= 0:
+		result = "0." + strings.Repeat("0", -pos) + digits
+	default:
+		m := digits
+		if len(digits) > 1 {
+			m = digits[:1] + "." + digits[1:]
+		}
+		if exp > 0 {
+			result = m + "e+" + strconv.Itoa(exp)
+		} else {
+			result = m + "e" + strconv.Itoa(exp)
+		}
+	}
+	return prefix + result
 }
 
 func escapeJSON(s string) string {
@@ -392,14 +445,19 @@ func isEventType(typ string) bool {
 func generateUUID() string {
 	var buf [16]byte
 	_, _ = rand.Read(buf[:])
-	buf[6] = (buf[6] & 0x0f) | 0x40 // version 4
+	return formatUUID(buf, 4)
+}
+
+// formatUUID renders 16 bytes as a UUID of the given version.
+func formatUUID(buf [16]byte, version byte) string {
+	buf[6] = (buf[6] & 0x0f) | version<<4
 	buf[8] = (buf[8] & 0x3f) | 0x80 // variant 2
 	h := hex.EncodeToString(buf[:])
 	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
 }
 
 func omitNullsSlice(arr []interface{}) []interface{} {
-	var result []interface{}
+	result := make([]interface{}, 0, len(arr))
 	for _, v := range arr {
 		if v == nil {
 			continue
@@ -220,3 +220,39 @@ func TestInstanceIdPreservedIfProvided(t *testing.T) {
 		t.Errorf("provided instance_id should be preserved, got %v", block.State["instance_id"])
 	}
 }
+
+func TestCreateKeepsEmptyArrays(t *testing.T) {
+	block := Create("test", map[string]interface{}{"tags": []interface{}{}}, nil)
+	data, _ := json.Marshal(block)
+	var served Block
+	json.Unmarshal(data, &served)
+	if Hash(served.Type, served.State, served.Refs) != block.Hash {
+		t.Errorf("served block %s no longer hashes to %s", data, block.Hash)
+	}
+}
+
+func TestSeedHashesMatchJavaScript(t *testing.T) {
+	data, err := os.ReadFile("../../test/seed-hashes.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var js map[string]string
+	if err := json.Unmarshal(data, &js); err != nil {
+		t.Fatal(err)
+	}
+	shared := 0
+	for _, b := range SeedAll() {
+		key := seedKey(b)
+		want, ok := js[key]
+		if !ok {
+			continue
+		}
+		shared++
+		if b.Hash != want {
+			t.Errorf("%s hashes %s in Go, %s in JS", key, b.Hash, want)
+		}
+	}
+	if shared != len(js) {
+		t.Errorf("compared %d of the %d JS seeds", shared, len(js))
+	}
+}
@@ -0,0 +1,221 @@
+package foodblock
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// ForecastPoint is the forecast demand for one day with a 95% interval.
+type ForecastPoint struct {
+	Date     string  `json:"date"`
+	Quantity float64 `json:"quantity"`
+	Low      float64 `json:"low"`
+	High     float64 `json:"high"`
+}
+
+// DemandForecast is a daily demand forecast for an item.
+type DemandForecast struct {
+	Item      string          `json:"item"`
+	Method    string          `json:"method"` // "ses", or "seasonal" with weekday indices
+	Alpha     float64         `json:"alpha"`  // smoothing factor fitted to the history
+	Unit      string          `json:"unit,omitempty"`
+	History   int             `json:"history"` // days of history used
+	Orders    []string        `json:"orders"`  // the orders the forecast was fitted to
+	Points    []ForecastPoint `json:"points"`
+	Total     float64         `json:"total"`
+	TotalLow  float64         `json:"total_low"`
+	TotalHigh float64         `json:"total_high"`
+}
+
+// forecastAlphas are the smoothing factors tried when fitting.
+var forecastAlphas = []float64{0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9}
+
+// Forecast forecasts daily demand for an item over the horizon days after its
+// latest order, from the quantities of the latest version of every non-draft
+// transfer.order whose item or product ref is the item. Days without orders
+// count as zero demand. With two weeks of history demand is deseasonalised by
+// weekday before simple exponential smoothing; otherwise smoothing is applied
+// directly. The smoothing factor minimises one-step-ahead squared error, and
+// intervals widen with the horizon from the fitted residuals. Totals sum the
+// daily points and their bounds.
+func Forecast(itemHash string, store BlockStore, horizon int) (DemandForecast, error) {
+	return forecastOrders(itemHash, store, horizon, nil)
+}
+
+// forecastOrders is Forecast over the orders keep accepts; nil keeps all.
+func forecastOrders(itemHash string, store BlockStore, horizon int, keep func(Block) bool) (DemandForecast, error) {
+	if horizon <= 0 {
+		return DemandForecast{}, fmt.Errorf("FoodBlock: forecast horizon must be positive")
+	}
+	f := DemandForecast{Item: itemHash, Orders: []string{}, Points: []ForecastPoint{}}
+	daily := map[string]float64{}
+	var first, last time.Time
+	for _, b := range FilterBlocks(store.Blocks(), QueryParams{Type: "transfer.order", HeadsOnly: true}) {
+		if draft, _ := b.State["draft"].(bool); draft || firstRef(b.Refs, "item", "product") != itemHash || (keep != nil && !keep(b)) {
+			continue
+		}
+		qty, unit := stockQuantity(b)
+		date, ok := BlockDate(b)
+		if !ok || qty <= 0 {
+			continue
+		}
+		day := date.UTC().Truncate(24 * time.Hour)
+		if first.IsZero() || day.Before(first) {
+			first = day
+		}
+		if day.After(last) {
+			last = day
+		}
+		daily[day.Format("2006-01-02")] += qty
+		f.Orders = append(f.Orders, b.Hash)
+		if f.Unit == "" {
+			f.Unit = unit
+		}
+	}
+	sort.Strings(f.Orders)
+	f.History = int(last.Sub(first).Hours()/24) + 1
+	if len(f.Orders) == 0 || f.History < 2 {
+		return f, fmt.Errorf("FoodBlock: not enough order history to forecast %s", itemHash)
+	}
+
+	series := make([]float64, f.History)
+	for i := range series {
+		series[i] = daily[first.AddDate(0, 0, i).Format("2006-01-02")]
+	}
+	index := [7]float64{1, 1, 1, 1, 1, 1, 1}
+	f.Method = "ses"
+	if f.History >= 14 {
+		f.Method = "seasonal"
+		index = weekdayIndices(series, first)
+	}
+	adjusted := make([]float64, len(series))
+	for i, y := range series {
+		if idx := index[first.AddDate(0, 0, i).Weekday()]; idx > 0 {
+			adjusted[i] = y / idx
+		}
+	}
+
+	var level, sigma float64
+	best := math.Inf(1)
+	for _, alpha := range forecastAlphas {
+		l, sse := smooth(adjusted, alpha)
+		if sse < best {
+			best, level, f.Alpha = sse, l, alpha
+		}
+	}
+	sigma = math.Sqrt(best / float64(len(adjusted)-1))
+
+	for h := 1; h <= horizon; h++ {
+		day := last.AddDate(0, 0, h)
+		idx := index[day.Weekday()]
+		q := level * idx
+		half := 1.96 * sigma * idx * math.Sqrt(1+float64(h-1)*f.Alpha*f.Alpha)
+		p := ForecastPoint{Date: day.Format("2006-01-02"), Quantity: round3(q), Low: round3(math.Max(0, q-half)), High: round3(q + half)}
+		f.Points = append(f.Points, p)
+		f.Total += p.Quantity
+		f.TotalLow += p.Low
+		f.TotalHigh += p.High
+	}
+	f.Total, f.TotalLow, f.TotalHigh = round3(f.Total), round3(f.TotalLow), round3(f.TotalHigh)
+	return f, nil
+}
+
+// smooth runs simple exponential smoothing and returns the final level and
+// the sum of squared one-step-ahead errors.
+func smooth(series []float64, alpha float64) (float64, float64) {
+	level := series[0]
+	var sse float64
+	for _, y := range series[1:] {
+		err := y - level
+		sse += err * err
+		level += alpha * err
+	}
+	return level, sse
+}
+
+// weekdayIndices is each weekday's mean demand relative to the overall mean.
+func weekdayIndices(series []float64, first time.Time) [7]float64 {
+	var sum, total [7]float64
+	var count [7]int
+	var all float64
+	for i, y := range series {
+		d := first.AddDate(0, 0, i).Weekday()
+		sum[d] += y
+		count[d]++
+		all += y
+	}
+	mean := all / float64(len(series))
+	for d := range total {
+		total[d] = 1
+		if mean > 0 && count[d] > 0 {
+			total[d] = sum[d] / float64(count[d]) / mean
+		}
+	}
+	return total
+}
+
+// SuggestedQuantity is the quantity to order so stock on hand covers the
+// forecast's upper bound, rounded up; zero when it already does.
+func (f DemandForecast) SuggestedQuantity(onHand float64) float64 {
+	return math.Max(0, math.Ceil(f.TotalHigh-onHand))
+}
+
+// ForecastBlock records a forecast as an observe.forecast block about the item
+// (refs.subject), citing the orders it was fitted to (refs.evidence).
+func ForecastBlock(f DemandForecast) Block {
+	points := make([]interface{}, len(f.Points))
+	for i, p := range f.Points {
+		points[i] = map[string]interface{}{"date": p.Date, "quantity": p.Quantity, "low": p.Low, "high": p.High}
+	}
+	from := ""
+	if len(f.Points) > 0 {
+		from = f.Points[0].Date
+	}
+	state := map[string]interface{}{
+		"instance_id": fmt.Sprintf("forecast-%s-%s-%d", f.Item, from, len(f.Points)),
+		"method":      f.Method,
+		"alpha":       f.Alpha,
+		"history":     f.History,
+		"horizon":     len(f.Points),
+		"points":      points,
+		"total":       f.Total,
+		"total_low":   f.TotalLow,
+		"total_high":  f.TotalHigh,
+	}
+	if f.Unit != "" {
+		state["unit"] = f.Unit
+	}
+	return Create("observe.forecast", state, map[string]interface{}{"subject": f.Item, "evidence": refList(f.Orders)})
+}
+
+// DraftReorder drafts a transfer.order for the forecast's suggested quantity
+// given stock on hand, as in the agent-reorder template. The forecast is
+// recorded as an observe.forecast block and cited as the draft's evidence in
+// the agent's action log. refs names the buyer, seller and so on; the item is
+// added. It returns the forecast block, the draft, the signed draft and the
+// signed action.
+func (a *Agent) DraftReorder(f DemandForecast, onHand float64, refs map[string]interface{}) (Block, Block, SignedBlock, SignedBlock, error) {
+	qty := f.SuggestedQuantity(onHand)
+	if qty <= 0 {
+		return Block{}, Block{}, SignedBlock{}, SignedBlock{}, fmt.Errorf("FoodBlock: stock on hand %.3f covers forecast demand for %s", onHand, f.Item)
+	}
+	forecast := ForecastBlock(f)
+	orderRefs := map[string]interface{}{"item": f.Item}
+	for k, v := range refs {
+		orderRefs[k] = v
+	}
+	state := map[string]interface{}{"status": "draft", "quantity": qty, "forecast_total": f.Total}
+	if f.Unit != "" {
+		state["unit"] = f.Unit
+	}
+	draft, signed, action := a.Execute(AgentAction{
+		Intent:   fmt.Sprintf("Reorder %g to cover forecast demand of %g (up to %g) over %d days with %g on hand", qty, f.Total, f.TotalHigh, len(f.Points), onHand),
+		Evidence: []string{forecast.Hash},
+		Type:     "transfer.order",
+		State:    state,
+		Refs:     orderRefs,
+	})
+	return forecast, draft, signed, action, nil
+}
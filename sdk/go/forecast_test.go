@@ -0,0 +1,133 @@
+package foodblock
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func forecastStore(t *testing.T, item string, days int, demand func(time.Time) float64) *MemoryStore {
+	t.Helper()
+	store := NewMemoryStore()
+	start := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC) // a Monday
+	for i := 0; i < days; i++ {
+		day := start.AddDate(0, 0, i)
+		q := demand(day)
+		if q == 0 {
+			continue
+		}
+		order := Create("transfer.order", map[string]interface{}{
+			"instance_id": fmt.Sprintf("order-%d", i),
+			"quantity":    q,
+			"unit":        "kg",
+			"date":        day.Format("2006-01-02"),
+		}, map[string]interface{}{"item": item})
+		if err := store.Put(order); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store
+}
+
+func TestForecastSeasonal(t *testing.T) {
+	flour := Create("substance.product", map[string]interface{}{"name": "Flour"}, nil)
+	store := forecastStore(t, flour.Hash, 28, func(d time.Time) float64 {
+		if d.Weekday() == time.Saturday {
+			return 30
+		}
+		return 10
+	})
+	draft := Create("transfer.order", map[string]interface{}{"instance_id": "draft", "quantity": 500, "draft": true, "date": "2026-03-28"},
+		map[string]interface{}{"item": flour.Hash})
+	store.Put(draft)
+
+	f, err := Forecast(flour.Hash, store, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Method != "seasonal" || f.History != 28 || len(f.Orders) != 28 || f.Unit != "kg" {
+		t.Fatalf("forecast = %+v", f)
+	}
+	if len(f.Points) != 7 || f.Points[0].Date != "2026-03-30" {
+		t.Fatalf("points = %+v", f.Points)
+	}
+	for _, p := range f.Points {
+		want := 10.0
+		if p.Date == "2026-04-04" {
+			want = 30
+		}
+		if p.Quantity < want-0.5 || p.Quantity > want+0.5 {
+			t.Errorf("%s: quantity = %v, want about %v", p.Date, p.Quantity, want)
+		}
+		if p.Low > p.Quantity || p.High < p.Quantity {
+			t.Errorf("%s: interval %v..%v excludes %v", p.Date, p.Low, p.High, p.Quantity)
+		}
+	}
+	if f.Total < 89 || f.Total > 91 || f.TotalHigh < f.Total || f.TotalLow > f.Total {
+		t.Errorf("totals = %v %v..%v", f.Total, f.TotalLow, f.TotalHigh)
+	}
+}
+
+func TestForecastIntervalsWiden(t *testing.T) {
+	item := "item-1"
+	store := forecastStore(t, item, 10, func(d time.Time) float64 { return float64(8 + d.Day()%5) })
+	f, err := Forecast(item, store, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Method != "ses" {
+		t.Errorf("method = %s", f.Method)
+	}
+	first, last := f.Points[0], f.Points[4]
+	if last.High-last.Low <= first.High-first.Low {
+		t.Errorf("interval did not widen: %+v then %+v", first, last)
+	}
+}
+
+func TestForecastNeedsHistory(t *testing.T) {
+	store := forecastStore(t, "item-1", 1, func(time.Time) float64 { return 5 })
+	if _, err := Forecast("item-1", store, 7); err == nil {
+		t.Error("expected an error for a single day of history")
+	}
+	if _, err := Forecast("other", store, 7); err == nil {
+		t.Error("expected an error for an item with no orders")
+	}
+}
+
+func TestDraftReorderFromForecast(t *testing.T) {
+	item := "item-1"
+	store := forecastStore(t, item, 14, func(time.Time) float64 { return 10 })
+	f, err := Forecast(item, store, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := f.SuggestedQuantity(30); got != 40 {
+		t.Errorf("suggested = %v, want 40", got)
+	}
+
+	agent, _ := CreateAgent("Reorder bot", "op", nil)
+	agent.Log = NewActionLog()
+	forecast, draft, _, action, err := agent.DraftReorder(f, 30, map[string]interface{}{"seller": "mill"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if forecast.Type != "observe.forecast" || forecast.Refs["subject"] != item || forecast.State["total_high"] != f.TotalHigh {
+		t.Errorf("forecast = %+v", forecast)
+	}
+	if draft.Type != "transfer.order" || draft.State["quantity"] != 40.0 || draft.State["draft"] != true {
+		t.Errorf("draft state = %v", draft.State)
+	}
+	if draft.Refs["item"] != item || draft.Refs["seller"] != "mill" {
+		t.Errorf("draft refs = %v", draft.Refs)
+	}
+	if ev := flattenRefValues(map[string]interface{}{"e": action.FoodBlock.Refs["evidence"]}); len(ev) != 1 || ev[0] != forecast.Hash {
+		t.Errorf("evidence = %v", action.FoodBlock.Refs["evidence"])
+	}
+	if agent.Log.ForDraft(draft.Hash) == nil {
+		t.Error("action not logged")
+	}
+
+	if _, _, _, _, err := agent.DraftReorder(f, 1000, nil); err == nil {
+		t.Error("expected an error when stock covers demand")
+	}
+}
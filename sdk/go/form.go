@@ -0,0 +1,147 @@
+package foodblock
+
+import (
+	"fmt"
+	"sort"
+)
+
+// FormField is one input on a data-entry form for a template step. Type is
+// the vocabulary field type (string, number, boolean, quantity, object or
+// compound); compound fields list their options in ValidValues.
+type FormField struct {
+	Name        string      `json:"name"`
+	Type        string      `json:"type"`
+	Required    bool        `json:"required,omitempty"`
+	Default     interface{} `json:"default,omitempty"`
+	ValidValues []string    `json:"valid_values,omitempty"`
+	ValidUnits  []string    `json:"valid_units,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Domains     []string    `json:"domains,omitempty"` // vocabularies defining the field
+}
+
+// FormRef is a ref role on a template step. Default is the step's own target,
+// an @alias or a hash.
+type FormRef struct {
+	Role    string `json:"role"`
+	Default string `json:"default,omitempty"`
+}
+
+// Form describes the inputs for one template step, for rendering the
+// StepOverrides passed to FromTemplate.
+type Form struct {
+	Template string      `json:"template"`
+	Step     string      `json:"step"`
+	Type     string      `json:"type"`
+	Fields   []FormField `json:"fields"`
+	Refs     []FormRef   `json:"refs"`
+}
+
+// GenerateForm builds the form for a template step from every vocabulary
+// whose ForTypes include the step's type, the core schema for that type, and
+// the step's own required fields and default state. Required fields come
+// first, then the rest by name. stepAlias matches a step's alias, or its type
+// when the step has none, as in FromTemplate.
+func GenerateForm(tmpl TemplateDef, stepAlias string) (Form, error) {
+	var step *TemplateStep
+	for i := range tmpl.Steps {
+		alias := tmpl.Steps[i].Alias
+		if alias == "" {
+			alias = tmpl.Steps[i].Type
+		}
+		if alias == stepAlias {
+			step = &tmpl.Steps[i]
+			break
+		}
+	}
+	if step == nil {
+		return Form{}, fmt.Errorf("FoodBlock: template %q has no step %q", tmpl.Name, stepAlias)
+	}
+
+	fields := map[string]*FormField{}
+	field := func(name, typ string) *FormField {
+		f := fields[name]
+		if f == nil {
+			f = &FormField{Name: name, Type: typ}
+			fields[name] = f
+		}
+		return f
+	}
+
+	domains := make([]string, 0, len(Vocabularies))
+	for d, v := range Vocabularies {
+		if containsStr(v.ForTypes, step.Type) {
+			domains = append(domains, d)
+		}
+	}
+	sort.Strings(domains)
+	for _, d := range domains {
+		for name, def := range Vocabularies[d].Fields {
+			f := field(name, def.Type)
+			f.Required = f.Required || def.Required
+			if f.Description == "" {
+				f.Description = def.Description
+			}
+			values := def.ValidValues
+			if def.Compound || def.Type == "compound" {
+				values = def.Aliases
+			}
+			for _, v := range values {
+				f.ValidValues = appendUnique(f.ValidValues, v)
+			}
+			for _, u := range def.ValidUnits {
+				f.ValidUnits = appendUnique(f.ValidUnits, u)
+			}
+			f.Domains = append(f.Domains, d)
+		}
+	}
+	for _, schema := range CoreSchemas {
+		if schema.TargetType != step.Type {
+			continue
+		}
+		for name, def := range schema.Fields {
+			if name == "instance_id" {
+				continue // injected by Create
+			}
+			f := field(name, def.Type)
+			f.Required = f.Required || def.Required
+		}
+	}
+	for _, name := range step.Required {
+		field(name, "string").Required = true
+	}
+	for name, v := range step.DefaultState {
+		field(name, formType(v)).Default = v
+	}
+
+	form := Form{Template: tmpl.Name, Step: stepAlias, Type: step.Type, Fields: make([]FormField, 0, len(fields)), Refs: []FormRef{}}
+	for _, f := range fields {
+		form.Fields = append(form.Fields, *f)
+	}
+	sort.Slice(form.Fields, func(i, j int) bool {
+		a, b := form.Fields[i], form.Fields[j]
+		if a.Required != b.Required {
+			return a.Required
+		}
+		return a.Name < b.Name
+	})
+	for role, target := range step.Refs {
+		form.Refs = append(form.Refs, FormRef{Role: role, Default: target})
+	}
+	sort.Slice(form.Refs, func(i, j int) bool { return form.Refs[i].Role < form.Refs[j].Role })
+	return form, nil
+}
+
+// formType infers a field type from a default value.
+func formType(v interface{}) string {
+	switch v.(type) {
+	case bool:
+		return "boolean"
+	case float64, int:
+		return "number"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	return "string"
+}
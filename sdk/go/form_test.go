@@ -0,0 +1,47 @@
+package foodblock
+
+import "testing"
+
+func TestGenerateForm(t *testing.T) {
+	form, err := GenerateForm(Templates["review"], "review")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if form.Type != "observe.review" || len(form.Refs) != 1 || form.Refs[0] != (FormRef{Role: "subject", Default: "@product"}) {
+		t.Errorf("unexpected form header: %+v", form)
+	}
+	if first := form.Fields[0]; first.Name != "rating" || !first.Required || first.Type != "number" {
+		t.Errorf("expected required rating first, got %+v", first)
+	}
+	for _, f := range form.Fields {
+		if f.Name == "instance_id" {
+			t.Error("instance_id should be left to Create")
+		}
+	}
+
+	form, _ = GenerateForm(Templates["supply-chain"], "product")
+	byName := map[string]FormField{}
+	for _, f := range form.Fields {
+		byName[f.Name] = f
+	}
+	allergens := byName["allergens"]
+	if allergens.Type != "compound" || !containsStr(allergens.ValidValues, "gluten") || !containsStr(allergens.Domains, "bakery") {
+		t.Errorf("unexpected allergens field: %+v", allergens)
+	}
+	if !byName["name"].Required || byName["price"].Description == "" {
+		t.Errorf("expected required name and described price: %+v %+v", byName["name"], byName["price"])
+	}
+
+	form, _ = GenerateForm(Templates["surplus-rescue"], "surplus")
+	defaults := map[string]interface{}{}
+	for _, f := range form.Fields {
+		defaults[f.Name] = f.Default
+	}
+	if defaults["status"] != "available" {
+		t.Errorf("expected status default from the step, got %v", defaults["status"])
+	}
+
+	if _, err := GenerateForm(Templates["review"], "nope"); err == nil {
+		t.Error("expected an error for an unknown step")
+	}
+}
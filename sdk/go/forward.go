@@ -1,6 +1,10 @@
 package foodblock
 
-import "strings"
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
 
 // ForwardResult holds the result of a forward traversal.
 type ForwardResult struct {
@@ -9,9 +13,11 @@ type ForwardResult struct {
 }
 
 // ForwardRef is a block and the ref role that references the target.
+// Inverse is the role read from the target's side, from RefRoles.
 type ForwardRef struct {
-	Block Block
-	Role  string
+	Block   Block
+	Role    string
+	Inverse string
 }
 
 // RecallResult holds the result of a recall trace.
@@ -19,6 +25,8 @@ type RecallResult struct {
 	Affected []Block
 	Depth    int
 	Paths    [][]string
+	Stopped  []string // affected blocks of StopTypes, not traced through
+	Options  RecallOptions
 }
 
 // Forward finds all blocks that reference a given hash in any ref field.
@@ -41,7 +49,7 @@ func Forward(hash string, resolveForward func(string) []Block) ForwardResult {
 			}
 			for _, h := range hashes {
 				if h == hash {
-					referencing = append(referencing, ForwardRef{Block: block, Role: role})
+					referencing = append(referencing, ForwardRef{Block: block, Role: role, Inverse: InverseRole(role, nil)})
 				}
 			}
 		}
@@ -50,8 +58,61 @@ func Forward(hash string, resolveForward func(string) []Block) ForwardResult {
 	return ForwardResult{Referencing: referencing, Count: len(referencing)}
 }
 
+// Describe phrases the result from the target's side, one sentence per
+// relation, such as "Mill sells Rye and Spelt." or "Flour is input to
+// Bread." Roles without an inverse read "Mill is visited of Visit."
+func (r ForwardResult) Describe(target Block) string {
+	var order []string
+	byRelation := map[string][]string{}
+	for _, ref := range r.Referencing {
+		rel := ref.Inverse
+		switch {
+		case rel == "":
+			rel = "is " + ref.Role + " of"
+		case strings.HasSuffix(rel, "_of"), strings.HasSuffix(rel, "_to"), strings.HasSuffix(rel, "_by"):
+			rel = "is " + rel
+		}
+		rel = strings.ReplaceAll(rel, "_", " ")
+		if _, ok := byRelation[rel]; !ok {
+			order = append(order, rel)
+		}
+		byRelation[rel] = append(byRelation[rel], blockLabel(ref.Block))
+	}
+	sort.Strings(order)
+	sentences := make([]string, len(order))
+	for i, rel := range order {
+		names := byRelation[rel]
+		list := names[0]
+		if n := len(names); n > 1 {
+			list = strings.Join(names[:n-1], ", ") + " and " + names[n-1]
+		}
+		sentences[i] = fmt.Sprintf("%s %s %s.", blockLabel(target), rel, list)
+	}
+	return strings.Join(sentences, " ")
+}
+
+// RecallOptions filter a recall trace. Types and Roles admit only matching
+// blocks, reached through matching refs; ExcludeTypes and ExcludeRoles leave
+// matching blocks and refs out. Blocks of StopTypes are affected but not
+// traced through, as when a recall should reach the shipments of a product
+// but not what their buyers made. Type patterns take "prefix.*" and "*".
+type RecallOptions struct {
+	MaxDepth     int // default 50
+	Types        []string
+	Roles        []string
+	ExcludeTypes []string
+	ExcludeRoles []string
+	StopTypes    []string
+}
+
 // Recall traces a contamination/recall path downstream via BFS.
 func Recall(sourceHash string, resolveForward func(string) []Block, maxDepth int, types, roles []string) RecallResult {
+	return RecallWith(sourceHash, resolveForward, RecallOptions{MaxDepth: maxDepth, Types: types, Roles: roles})
+}
+
+// RecallWith traces a recall like Recall under the filters in opts.
+func RecallWith(sourceHash string, resolveForward func(string) []Block, opts RecallOptions) RecallResult {
+	maxDepth := opts.MaxDepth
 	if maxDepth <= 0 {
 		maxDepth = 50
 	}
@@ -59,6 +120,7 @@ func Recall(sourceHash string, resolveForward func(string) []Block, maxDepth int
 	visited := map[string]bool{sourceHash: true}
 	var affected []Block
 	var paths [][]string
+	var stopped []string
 	maxDepthReached := 0
 
 	type entry struct {
@@ -82,33 +144,15 @@ func Recall(sourceHash string, resolveForward func(string) []Block, maxDepth int
 				continue
 			}
 
-			// Check role filter
-			if len(roles) > 0 {
-				var matchingRoles []string
+			// Check role filters against the refs that reach e.hash
+			if len(opts.Roles) > 0 || len(opts.ExcludeRoles) > 0 {
+				hasMatch := false
 				for role, ref := range block.Refs {
-					var hashes []string
-					switch v := ref.(type) {
-					case string:
-						hashes = []string{v}
-					case []interface{}:
-						for _, item := range v {
-							if s, ok := item.(string); ok {
-								hashes = append(hashes, s)
-							}
-						}
+					if !containsStr(flattenRefValues(map[string]interface{}{role: ref}), e.hash) {
+						continue
 					}
-					for _, h := range hashes {
-						if h == e.hash {
-							matchingRoles = append(matchingRoles, role)
-						}
-					}
-				}
-				hasMatch := false
-				for _, mr := range matchingRoles {
-					for _, r := range roles {
-						if mr == r {
-							hasMatch = true
-						}
+					if (len(opts.Roles) == 0 || containsStr(opts.Roles, role)) && !containsStr(opts.ExcludeRoles, role) {
+						hasMatch = true
 					}
 				}
 				if !hasMatch {
@@ -116,22 +160,12 @@ func Recall(sourceHash string, resolveForward func(string) []Block, maxDepth int
 				}
 			}
 
-			// Check type filter
-			if len(types) > 0 {
-				matchesType := false
-				for _, t := range types {
-					if strings.HasSuffix(t, ".*") {
-						prefix := t[:len(t)-1]
-						if strings.HasPrefix(block.Type, prefix) {
-							matchesType = true
-						}
-					} else if block.Type == t {
-						matchesType = true
-					}
-				}
-				if !matchesType {
-					continue
-				}
+			// Check type filters
+			if !matchesAnyType(block.Type, opts.Types) {
+				continue
+			}
+			if len(opts.ExcludeTypes) > 0 && matchesAnyType(block.Type, opts.ExcludeTypes) {
+				continue
 			}
 
 			visited[block.Hash] = true
@@ -144,15 +178,178 @@ func Recall(sourceHash string, resolveForward func(string) []Block, maxDepth int
 
 			affected = append(affected, block)
 			paths = append(paths, blockPath)
+			if len(opts.StopTypes) > 0 && matchesAnyType(block.Type, opts.StopTypes) {
+				stopped = append(stopped, block.Hash)
+				continue
+			}
 			queue = append(queue, entry{hash: block.Hash, depth: currentDepth, path: blockPath})
 		}
 	}
 
-	return RecallResult{Affected: affected, Depth: maxDepthReached, Paths: paths}
+	return RecallResult{Affected: affected, Depth: maxDepthReached, Paths: paths, Stopped: stopped, Options: opts}
 }
 
 // Downstream finds all downstream substance blocks of a given ingredient.
 func Downstream(ingredientHash string, resolveForward func(string) []Block) []Block {
-	result := Recall(ingredientHash, resolveForward, 50, []string{"substance.*"}, nil)
-	return result.Affected
+	return DownstreamWith(ingredientHash, resolveForward, RecallOptions{})
+}
+
+// DownstreamWith finds downstream blocks under opts, which default to
+// substance types as in Downstream.
+func DownstreamWith(ingredientHash string, resolveForward func(string) []Block, opts RecallOptions) []Block {
+	if len(opts.Types) == 0 {
+		opts.Types = []string{"substance.*"}
+	}
+	return RecallWith(ingredientHash, resolveForward, opts).Affected
+}
+
+// BreadthLimits keeps Recall and Explain output readable when a block fans
+// out to thousands of others. The zero value lists everything.
+type BreadthLimits struct {
+	MaxPerLevel int    // blocks listed per level; the rest are only counted
+	Sample      bool   // list blocks spread across the level, not the first ones
+	GroupBy     string // "type" or "actor": count each level's blocks by group
+}
+
+// GroupCount counts the blocks sharing a type or actor.
+type GroupCount struct {
+	Group string `json:"group"`
+	Count int    `json:"count"`
+}
+
+// RecallLevel is the part of a recall at one depth.
+type RecallLevel struct {
+	Depth   int          `json:"depth"`
+	Total   int          `json:"total"`
+	Shown   []Block      `json:"shown"`
+	Omitted int          `json:"omitted"`
+	Groups  []GroupCount `json:"groups,omitempty"`
+}
+
+// RecallSummary is a recall cut down to BreadthLimits, level by level.
+type RecallSummary struct {
+	Total  int           `json:"total"`
+	Depth  int           `json:"depth"`
+	Levels []RecallLevel `json:"levels"`
+}
+
+// Summarize groups the affected blocks by depth and applies limits. Every
+// affected block is still counted. resolve names actors for GroupBy "actor"
+// and may be nil otherwise.
+func (r RecallResult) Summarize(limits BreadthLimits, resolve func(string) *Block) RecallSummary {
+	var byDepth [][]Block
+	for i, b := range r.Affected {
+		d := 1
+		if i < len(r.Paths) {
+			d = len(r.Paths[i]) - 1
+		}
+		for len(byDepth) < d {
+			byDepth = append(byDepth, nil)
+		}
+		byDepth[d-1] = append(byDepth[d-1], b)
+	}
+	s := RecallSummary{Total: len(r.Affected), Depth: r.Depth}
+	for i, blocks := range byDepth {
+		shown, omitted := limitBlocks(blocks, limits)
+		s.Levels = append(s.Levels, RecallLevel{
+			Depth: i + 1, Total: len(blocks), Shown: shown, Omitted: omitted,
+			Groups: groupBlocks(blocks, limits.GroupBy, resolve),
+		})
+	}
+	return s
+}
+
+// String renders the summary with one line per level.
+func (s RecallSummary) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d blocks affected, traced to depth %d.\n", s.Total, s.Depth)
+	for _, l := range s.Levels {
+		noun := "blocks"
+		if l.Total == 1 {
+			noun = "block"
+		}
+		fmt.Fprintf(&sb, "Depth %d: %d %s%s: %s.\n", l.Depth, l.Total, noun, describeGroups(l.Groups), listBlocks(l.Shown, l.Omitted))
+	}
+	return sb.String()
+}
+
+// limitBlocks picks the blocks to list under limits and counts the rest.
+func limitBlocks(blocks []Block, limits BreadthLimits) ([]Block, int) {
+	max := limits.MaxPerLevel
+	if max <= 0 || len(blocks) <= max {
+		return blocks, 0
+	}
+	if !limits.Sample {
+		return blocks[:max], len(blocks) - max
+	}
+	shown := make([]Block, max)
+	for k := range shown {
+		shown[k] = blocks[k*len(blocks)/max]
+	}
+	return shown, len(blocks) - max
+}
+
+// actorRoles are the refs checked, in order, to attribute a block.
+var actorRoles = []string{"seller", "producer", "operator", "buyer", "author"}
+
+// groupBlocks counts blocks by type or by the actor they name, largest group
+// first.
+func groupBlocks(blocks []Block, by string, resolve func(string) *Block) []GroupCount {
+	if by != "type" && by != "actor" {
+		return nil
+	}
+	counts := map[string]int{}
+	for _, b := range blocks {
+		key := b.Type
+		if by == "actor" {
+			key = "unattributed"
+			for _, role := range actorRoles {
+				if h, ok := b.Refs[role].(string); ok {
+					key = h
+					if resolve != nil {
+						if actor := resolve(h); actor != nil {
+							key = blockLabel(*actor)
+						}
+					}
+					break
+				}
+			}
+		}
+		counts[key]++
+	}
+	out := make([]GroupCount, 0, len(counts))
+	for k, n := range counts {
+		out = append(out, GroupCount{Group: k, Count: n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Group < out[j].Group
+	})
+	return out
+}
+
+func describeGroups(groups []GroupCount) string {
+	if len(groups) == 0 {
+		return ""
+	}
+	parts := make([]string, len(groups))
+	for i, g := range groups {
+		parts[i] = fmt.Sprintf("%d %s", g.Count, g.Group)
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
+
+// listBlocks names blocks, adding "and N more" for the omitted ones.
+func listBlocks(blocks []Block, omitted int) string {
+	names := make([]string, len(blocks))
+	for i, b := range blocks {
+		names[i] = blockLabel(b)
+	}
+	list := strings.Join(names, ", ")
+	if omitted > 0 {
+		list += fmt.Sprintf(" and %d more", omitted)
+	}
+	return list
 }
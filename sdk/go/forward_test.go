@@ -1,6 +1,10 @@
 package foodblock
 
-import "testing"
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
 
 // buildForwardIndex builds a map from referenced hash -> []Block for use as resolveForward.
 // It scans every block's refs and indexes each referenced hash to the block.
@@ -189,3 +193,75 @@ func TestDownstream(t *testing.T) {
 		}
 	}
 }
+
+func TestRecallSummarizeAndExplainLimits(t *testing.T) {
+	store := NewMemoryStore()
+	mill := Create("actor.producer", map[string]interface{}{"name": "Mill"}, nil)
+	flour := Create("substance.ingredient", map[string]interface{}{"name": "Flour"}, map[string]interface{}{"seller": mill.Hash})
+	store.PutAll([]Block{mill, flour})
+	var inputs []interface{}
+	for i := 0; i < 10; i++ {
+		bread := Create("substance.product", map[string]interface{}{"name": fmt.Sprintf("Loaf %d", i)}, map[string]interface{}{"inputs": []interface{}{flour.Hash}})
+		store.Put(bread)
+		inputs = append(inputs, bread.Hash)
+		for j := 0; j < 5; j++ {
+			store.Put(Create("transfer.delivery", map[string]interface{}{"instance_id": fmt.Sprintf("d%d-%d", i, j)}, map[string]interface{}{"item": bread.Hash, "seller": mill.Hash}))
+		}
+	}
+
+	result := Recall(flour.Hash, store.ResolveForward, 0, nil, nil)
+	s := result.Summarize(BreadthLimits{MaxPerLevel: 3, Sample: true, GroupBy: "type"}, store.Resolve)
+	if s.Total != 60 || len(s.Levels) != 2 || s.Levels[1].Total != 50 || len(s.Levels[1].Shown) != 3 || s.Levels[1].Omitted != 47 {
+		t.Fatalf("summary = %+v", s)
+	}
+	if s.Levels[1].Shown[1].Hash == result.Affected[11].Hash {
+		t.Error("sampled level lists the first blocks")
+	}
+	text := s.String()
+	if !strings.Contains(text, "Depth 2: 50 blocks (50 transfer.delivery):") || !strings.Contains(text, "and 47 more.") {
+		t.Errorf("narrative:\n%s", text)
+	}
+	if g := result.Summarize(BreadthLimits{GroupBy: "actor"}, store.Resolve).Levels[1].Groups; len(g) != 1 || g[0].Group != "Mill" || g[0].Count != 50 {
+		t.Errorf("actor groups = %+v", g)
+	}
+
+	sandwich := Create("substance.product", map[string]interface{}{"name": "Platter"}, map[string]interface{}{"inputs": inputs})
+	store.Put(sandwich)
+	narrative := ExplainLimited(sandwich.Hash, store.Resolve, 0, BreadthLimits{MaxPerLevel: 2, GroupBy: "type"})
+	if !strings.Contains(narrative, "Made from Loaf 0, Loaf 1 and 8 more (10 substance.product).") {
+		t.Errorf("narrative = %s", narrative)
+	}
+	if full := Explain(sandwich.Hash, store.Resolve, 0); !strings.Contains(full, "Loaf 9") {
+		t.Errorf("unlimited narrative = %s", full)
+	}
+}
+
+func TestRecallExclusions(t *testing.T) {
+	store := NewMemoryStore()
+	flour := Create("substance.ingredient", map[string]interface{}{"name": "Flour"}, nil)
+	bread := Create("substance.product", map[string]interface{}{"name": "Bread"}, map[string]interface{}{"inputs": []interface{}{flour.Hash}})
+	review := Create("observe.review", map[string]interface{}{"rating": 2.0}, map[string]interface{}{"subject": flour.Hash})
+	note := Create("observe.note", map[string]interface{}{"text": "dense"}, map[string]interface{}{"subject": bread.Hash})
+	delivery := Create("transfer.delivery", map[string]interface{}{"instance_id": "d1"}, map[string]interface{}{"item": bread.Hash})
+	sandwich := Create("substance.product", map[string]interface{}{"name": "Sandwich"}, map[string]interface{}{"inputs": []interface{}{delivery.Hash}})
+	store.PutAll([]Block{flour, bread, review, note, delivery, sandwich})
+
+	if all := RecallWith(flour.Hash, store.ResolveForward, RecallOptions{}); len(all.Affected) != 5 {
+		t.Fatalf("unfiltered = %d blocks", len(all.Affected))
+	}
+	noObs := RecallWith(flour.Hash, store.ResolveForward, RecallOptions{ExcludeTypes: []string{"observe.*"}})
+	if len(noObs.Affected) != 3 {
+		t.Errorf("excluding observations = %d blocks", len(noObs.Affected))
+	}
+	noSubject := RecallWith(flour.Hash, store.ResolveForward, RecallOptions{ExcludeRoles: []string{"subject"}})
+	if len(noSubject.Affected) != 3 {
+		t.Errorf("excluding subject refs = %d blocks", len(noSubject.Affected))
+	}
+	stopped := RecallWith(flour.Hash, store.ResolveForward, RecallOptions{ExcludeTypes: []string{"observe.*"}, StopTypes: []string{"transfer.*"}})
+	if len(stopped.Affected) != 2 || len(stopped.Stopped) != 1 || stopped.Stopped[0] != delivery.Hash {
+		t.Errorf("stopped = %+v", stopped)
+	}
+	if down := DownstreamWith(flour.Hash, store.ResolveForward, RecallOptions{StopTypes: []string{"substance.product"}}); len(down) != 1 || down[0].Hash != bread.Hash {
+		t.Errorf("downstream = %v", down)
+	}
+}
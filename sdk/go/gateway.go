@@ -0,0 +1,403 @@
+package foodblock
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// shareTokenDomain separates share token signatures from block signatures.
+const shareTokenDomain = "foodblock-share\n"
+
+// ShareToken grants read access to one block and its provenance until it
+// expires. Tokens are the base64url JSON claims and the issuer's Ed25519
+// signature over them, joined by a dot.
+type ShareToken struct {
+	Root     string `json:"root"`
+	Issuer   string `json:"iss"`
+	Expires  string `json:"exp"`           // RFC 3339
+	Audience string `json:"aud,omitempty"` // fields shown, see ProjectForAudience
+}
+
+// CreateShareToken signs a token sharing root for ttl with the public.
+func CreateShareToken(root, issuer string, privateKey []byte, ttl time.Duration) (string, error) {
+	return CreateAudienceShareToken(root, issuer, "", privateKey, ttl)
+}
+
+// CreateAudienceShareToken signs a token sharing root for ttl with an
+// audience, such as "trade", which then sees the fields visible to it.
+func CreateAudienceShareToken(root, issuer, audience string, privateKey []byte, ttl time.Duration) (string, error) {
+	if root == "" || issuer == "" {
+		return "", errors.New("FoodBlock: a share token needs a root and an issuer")
+	}
+	if ttl <= 0 {
+		return "", errors.New("FoodBlock: a share token needs a positive ttl")
+	}
+	claims, err := json.Marshal(ShareToken{Root: root, Issuer: issuer, Expires: time.Now().Add(ttl).UTC().Format(time.RFC3339), Audience: audience})
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(claims)
+	sig := ed25519.Sign(ed25519.PrivateKey(privateKey), []byte(shareTokenDomain+payload))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// VerifyShareToken checks a token's signature against the issuer's key and
+// its expiry.
+func VerifyShareToken(token string, keys *KeyRegistry, now time.Time) (ShareToken, error) {
+	payload, sigPart, ok := strings.Cut(token, ".")
+	if !ok {
+		return ShareToken{}, errors.New("FoodBlock: malformed share token")
+	}
+	claims, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return ShareToken{}, errors.New("FoodBlock: malformed share token")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(sigPart)
+	if err != nil {
+		return ShareToken{}, errors.New("FoodBlock: malformed share token")
+	}
+	var t ShareToken
+	if err := json.Unmarshal(claims, &t); err != nil || t.Root == "" {
+		return ShareToken{}, errors.New("FoodBlock: malformed share token")
+	}
+	pub, ok := keys.PublicKey(t.Issuer)
+	if !ok {
+		return ShareToken{}, errors.New("FoodBlock: unknown share token issuer")
+	}
+	if !ed25519.Verify(ed25519.PublicKey(pub), []byte(shareTokenDomain+payload), sig) {
+		return ShareToken{}, errors.New("FoodBlock: share token signature verification failed")
+	}
+	exp, err := time.Parse(time.RFC3339, t.Expires)
+	if err != nil || !now.Before(exp) {
+		return ShareToken{}, errors.New("FoodBlock: share token has expired")
+	}
+	return t, nil
+}
+
+// ShareLink is the gateway URL verifying root with a token.
+func ShareLink(baseURL, root, token string) string {
+	return strings.TrimRight(baseURL, "/") + "/verify/" + root + "?token=" + url.QueryEscape(token)
+}
+
+// GatewayOptions configures GatewayHandler.
+type GatewayOptions struct {
+	Allow     []string     // roots whose provenance is public without a token
+	Keys      *KeyRegistry // share token issuers; nil accepts no tokens
+	MaxDepth  int          // provenance depth served, default 10
+	RateLimit int          // requests per minute per client, default 60
+	Audience  string       // fields shown without a token's audience, default AudiencePublic
+	Now       func() time.Time
+}
+
+// GatewayProof is the verification data served with a shared block.
+type GatewayProof struct {
+	Root       string         `json:"root"`
+	MerkleRoot string         `json:"merkle_root"` // as an observe.snapshot of Blocks would record
+	Blocks     []GatewayCheck `json:"blocks"`
+	Verified   bool           `json:"verified"` // every block's hash matches its content
+	Expires    string         `json:"expires,omitempty"`
+}
+
+// GatewayCheck is one block's hash check.
+type GatewayCheck struct {
+	Hash     string `json:"hash"`
+	Type     string `json:"type"`
+	Author   string `json:"author,omitempty"`
+	HashOK   bool   `json:"hash_ok"`
+	Redacted bool   `json:"redacted,omitempty"` // a stub, checked by hash alone
+}
+
+// GatewayPage is the JSON body of a verification page.
+type GatewayPage struct {
+	Narrative  string         `json:"narrative"`
+	Provenance ExplainNode    `json:"provenance"`
+	Proof      GatewayProof   `json:"proof"`
+	Block      ProjectedBlock `json:"block"`
+}
+
+// GatewayHandler serves a read-only public view of a store: only allowed
+// roots, or roots named by a valid share token, and the blocks of their
+// provenance are visible, and of those only the fields the audience may
+// see (see ProjectForAudience).
+//
+//	GET /verify/{hash}?token=   verification page, HTML for browsers, else JSON
+//	GET /blocks/{hash}?token=   a single block within a shared provenance
+//
+// Tokens may also be sent as "Authorization: Bearer <token>". Clients are
+// rate limited by remote address.
+func GatewayHandler(store BlockStore, opts GatewayOptions) http.Handler {
+	g := newGateway(store, opts)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/verify/", g.limit(g.verify))
+	mux.HandleFunc("/blocks/", g.limit(g.block))
+	return mux
+}
+
+type gateway struct {
+	store   BlockStore
+	opts    GatewayOptions
+	limiter *rateLimiter
+}
+
+func newGateway(store BlockStore, opts GatewayOptions) *gateway {
+	if opts.MaxDepth <= 0 {
+		opts.MaxDepth = 10
+	}
+	if opts.RateLimit <= 0 {
+		opts.RateLimit = 60
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &gateway{store: store, opts: opts, limiter: &rateLimiter{perMinute: opts.RateLimit, now: opts.Now}}
+}
+
+func (g *gateway) limit(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			writeError(w, http.StatusMethodNotAllowed, "the gateway is read-only")
+			return
+		}
+		client, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			client = r.RemoteAddr
+		}
+		if ok, retry := g.limiter.allow(client); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(retry.Seconds()+0.5)))
+			writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+		h(w, r)
+	}
+}
+
+// gatewayGrant is what a request may read. A token's audience only
+// applies to blocks its issuer signed; see audienceOf.
+type gatewayGrant struct {
+	root     string
+	tree     ExplainNode
+	expires  string
+	audience string
+	issuer   string
+}
+
+// grant returns the shared root the request may read hash under: hash
+// itself for an allowed root or a token's root, or a root whose provenance
+// includes hash. A token must be issued by the recorded signer of its root
+// and covers only blocks that signer signed. status is non-zero when access
+// is refused.
+func (g *gateway) grant(r *http.Request, hash string) (gatewayGrant, int, string) {
+	audience := g.opts.Audience
+	var tokenAudience, expires, issuer string
+	var roots []string
+	roots = append(roots, g.opts.Allow...)
+	token := r.URL.Query().Get("token")
+	if bearer := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "); token == "" && bearer != r.Header.Get("Authorization") {
+		token = bearer
+	}
+	if token != "" {
+		if g.opts.Keys == nil {
+			return gatewayGrant{}, http.StatusUnauthorized, "share tokens are not accepted here"
+		}
+		t, err := VerifyShareToken(token, g.opts.Keys, g.opts.Now())
+		if err != nil {
+			return gatewayGrant{}, http.StatusUnauthorized, err.Error()
+		}
+		if g.signerOf(t.Root) != t.Issuer {
+			return gatewayGrant{}, http.StatusForbidden, "share token issuer did not sign the shared block"
+		}
+		roots = append([]string{t.Root}, roots...)
+		expires, tokenAudience, issuer = t.Expires, t.Audience, t.Issuer
+	}
+	for i, rt := range roots {
+		access := gatewayGrant{root: rt, audience: audience}
+		if i == 0 && token != "" {
+			access.expires, access.issuer = expires, issuer
+			if tokenAudience != "" {
+				access.audience = tokenAudience
+			}
+		}
+		// Names in the tree are projected too, in case they are restricted.
+		resolve := g.resolveFor(access)
+		if access.issuer != "" {
+			// A token shares only its issuer's blocks; other parties'
+			// appear as not shared.
+			project := resolve
+			resolve = func(h string) *Block {
+				if g.signerOf(h) != access.issuer {
+					return nil
+				}
+				return project(h)
+			}
+		}
+		access.tree = ExplainStructured(rt, resolve, g.opts.MaxDepth)
+		if !access.tree.Missing && containsStr(access.tree.Hashes(), hash) {
+			return access, 0, ""
+		}
+	}
+	return gatewayGrant{}, http.StatusNotFound, "Block not found"
+}
+
+func (g *gateway) block(w http.ResponseWriter, r *http.Request) {
+	hash := strings.TrimPrefix(r.URL.Path, "/blocks/")
+	access, status, msg := g.grant(r, hash)
+	if status != 0 {
+		writeError(w, status, msg)
+		return
+	}
+	writeJSON(w, http.StatusOK, ProjectForAudience(*g.store.Resolve(hash), g.audienceOf(access, hash)))
+}
+
+func (g *gateway) verify(w http.ResponseWriter, r *http.Request) {
+	hash := strings.TrimPrefix(r.URL.Path, "/verify/")
+	access, status, msg := g.grant(r, hash)
+	if status != 0 {
+		writeError(w, status, msg)
+		return
+	}
+	tree := access.tree
+	if access.root != hash {
+		tree, _ = tree.find(hash)
+	}
+	// The narrative may only name blocks that are shared, and only their
+	// fields the audience may see.
+	shared := map[string]bool{}
+	for _, h := range tree.Hashes() {
+		shared[h] = true
+	}
+	project := g.resolveFor(access)
+	resolve := func(h string) *Block {
+		if !shared[h] {
+			return nil
+		}
+		return project(h)
+	}
+	page := GatewayPage{
+		Narrative:  Explain(hash, resolve, g.opts.MaxDepth),
+		Provenance: tree,
+		Proof:      g.proof(tree, access.expires),
+		Block:      ProjectForAudience(*g.store.Resolve(hash), g.audienceOf(access, hash)),
+	}
+	if r.URL.Query().Get("format") == "json" || !strings.Contains(r.Header.Get("Accept"), "text/html") {
+		writeJSON(w, http.StatusOK, page)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	gatewayPageTemplate.Execute(w, page)
+}
+
+// resolveFor resolves blocks as the grant's audience sees them.
+func (g *gateway) resolveFor(access gatewayGrant) func(string) *Block {
+	return func(h string) *Block {
+		b := g.store.Resolve(h)
+		if b == nil {
+			return nil
+		}
+		projected := ProjectForAudience(*b, g.audienceOf(access, h)).Block()
+		return &projected
+	}
+}
+
+// audienceOf is the audience block h is shown to. A token speaks only for
+// its issuer, so its audience covers the blocks the issuer signed; other
+// authors' blocks get the gateway's default audience.
+func (g *gateway) audienceOf(access gatewayGrant, h string) string {
+	if access.issuer == "" || g.signerOf(h) == access.issuer {
+		return access.audience
+	}
+	return g.opts.Audience
+}
+
+// signerOf returns who signed a block, as far as the store records it.
+func (g *gateway) signerOf(h string) string {
+	if signers, ok := g.store.(interface{ SignerOf(string) string }); ok {
+		return signers.SignerOf(h)
+	}
+	if authors, ok := g.store.(interface{ AuthorOf(string) string }); ok {
+		return authors.AuthorOf(h)
+	}
+	return ""
+}
+
+func (g *gateway) proof(tree ExplainNode, expires string) GatewayProof {
+	authors, _ := g.store.(interface{ AuthorOf(string) string })
+	hashes := tree.Hashes()
+	p := GatewayProof{Root: tree.Hash, MerkleRoot: computeMerkleRoot(hashes), Verified: true, Expires: expires}
+	for _, h := range hashes {
+		b := g.store.Resolve(h)
+		if b == nil {
+			continue
+		}
+		c := GatewayCheck{Hash: h, Type: b.Type, Redacted: IsStub(*b)}
+		c.HashOK = c.Redacted || Hash(b.Type, b.State, b.Refs) == h
+		if authors != nil {
+			c.Author = authors.AuthorOf(h)
+		}
+		p.Verified = p.Verified && c.HashOK
+		p.Blocks = append(p.Blocks, c)
+	}
+	return p
+}
+
+var gatewayPageTemplate = template.Must(template.New("gateway").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Provenance.Name}} - FoodBlock verification</title></head>
+<body>
+<h1>{{.Provenance.Name}}</h1>
+<p>{{.Narrative}}</p>
+<h2>Provenance</h2>
+{{define "node"}}<li>{{if .Role}}{{.Role}}: {{end}}{{if .Missing}}not shared{{else}}{{.Name}} <small>{{.Type}}</small>{{end}} <code>{{.Hash}}</code>{{if .Children}}<ul>{{range .Children}}{{template "node" .}}{{end}}</ul>{{end}}</li>{{end}}
+<ul>{{template "node" .Provenance}}</ul>
+<h2>Proof</h2>
+<p>{{if .Proof.Verified}}Every block matches its hash.{{else}}Some blocks do not match their hash.{{end}}
+Merkle root <code>{{.Proof.MerkleRoot}}</code>{{with .Proof.Expires}}; this link expires {{.}}{{end}}.</p>
+<table><tr><th>Block</th><th>Type</th><th>Author</th><th>Hash</th></tr>
+{{range .Proof.Blocks}}<tr><td><code>{{.Hash}}</code></td><td>{{.Type}}</td><td><code>{{.Author}}</code></td><td>{{if .Redacted}}redacted{{else if .HashOK}}ok{{else}}mismatch{{end}}</td></tr>
+{{end}}</table>
+</body></html>
+`))
+
+func (n ExplainNode) find(hash string) (ExplainNode, bool) {
+	if n.Hash == hash {
+		return n, true
+	}
+	for _, c := range n.Children {
+		if found, ok := c.find(hash); ok {
+			return found, true
+		}
+	}
+	return ExplainNode{}, false
+}
+
+// rateLimiter allows perMinute requests per key in each clock minute.
+type rateLimiter struct {
+	perMinute int
+	now       func() time.Time
+
+	mu     sync.Mutex
+	window time.Time
+	counts map[string]int
+}
+
+func (l *rateLimiter) allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if window := now.Truncate(time.Minute); !window.Equal(l.window) {
+		l.window, l.counts = window, map[string]int{}
+	}
+	if l.counts[key] >= l.perMinute {
+		return false, l.window.Add(time.Minute).Sub(now)
+	}
+	l.counts[key]++
+	return true, 0
+}
@@ -0,0 +1,121 @@
+package foodblock
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGateway(t *testing.T) {
+	store := NewMemoryStore()
+	pub, priv := GenerateKeypair()
+	farm := Create("actor.producer", map[string]interface{}{"name": "Green Acres"}, nil)
+	wheat := Create("substance.ingredient", map[string]interface{}{"name": "Wheat"}, map[string]interface{}{"source": farm.Hash})
+	private := Create("transfer.order", map[string]interface{}{"total": 900.0}, map[string]interface{}{"seller": farm.Hash})
+	_, millPriv := GenerateKeypair()
+	mill := Create("actor.producer", map[string]interface{}{"name": "Mill"}, nil)
+	flour := Create("substance.ingredient", map[string]interface{}{"name": "Flour"}, map[string]interface{}{"seller": mill.Hash})
+	bread := Create("substance.product", map[string]interface{}{"name": "Sourdough"}, map[string]interface{}{"inputs": []interface{}{wheat.Hash, flour.Hash}, "seller": farm.Hash})
+	store.PutAll([]Block{private, mill})
+	for _, b := range []Block{farm, wheat, bread} {
+		store.PutSigned(Sign(b, farm.Hash, priv))
+	}
+	store.PutSigned(Sign(flour, mill.Hash, millPriv))
+	keys := NewKeyRegistry()
+	keys.Register(farm.Hash, pub)
+
+	now := time.Now()
+	srv := httptest.NewServer(GatewayHandler(store, GatewayOptions{Keys: keys, Now: func() time.Time { return now }}))
+	defer srv.Close()
+	token, err := CreateShareToken(bread.Hash, farm.Hash, priv, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(path, accept string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := get(strings.TrimPrefix(ShareLink(srv.URL, bread.Hash, token), srv.URL), "")
+	var page GatewayPage
+	json.NewDecoder(resp.Body).Decode(&page)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !page.Proof.Verified || len(page.Proof.Blocks) != 3 || page.Proof.Expires == "" {
+		t.Fatalf("status %d, page = %+v", resp.StatusCode, page)
+	}
+	if page.Proof.MerkleRoot != CreateSnapshot([]Block{bread, wheat, farm}, "", nil).State["merkle_root"] || page.Proof.Blocks[0].Author != farm.Hash {
+		t.Errorf("proof = %+v", page.Proof)
+	}
+	if !strings.Contains(page.Narrative, "Sourdough") || page.Provenance.Children[0].Name != "Wheat" {
+		t.Errorf("narrative = %q, tree = %+v", page.Narrative, page.Provenance)
+	}
+
+	// Blocks inside the shared provenance are readable; the rest are not.
+	if resp := get("/blocks/"+wheat.Hash+"?token="+token, ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("wheat: %d", resp.StatusCode)
+	}
+	if resp := get("/blocks/"+private.Hash+"?token="+token, ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("private order: %d", resp.StatusCode)
+	}
+	if resp := get("/blocks/"+flour.Hash+"?token="+token, ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("another party's block: %d", resp.StatusCode)
+	}
+	// Only the signer of a block may share it.
+	unsigned, _ := CreateShareToken(mill.Hash, farm.Hash, priv, time.Hour)
+	if resp := get("/verify/"+mill.Hash+"?token="+unsigned, ""); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("token for an unsigned block: %d", resp.StatusCode)
+	}
+	if resp := get("/verify/"+bread.Hash, ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("no token: %d", resp.StatusCode)
+	}
+	if resp := get("/verify/"+bread.Hash+"?token="+token[:len(token)-4]+"AAAA", ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("tampered token: %d", resp.StatusCode)
+	}
+
+	html := get("/verify/"+wheat.Hash+"?token="+token, "text/html")
+	body, _ := io.ReadAll(html.Body)
+	if !strings.Contains(html.Header.Get("Content-Type"), "text/html") || !strings.Contains(string(body), "Every block matches its hash") {
+		t.Errorf("html = %s", body)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if resp := get("/verify/"+bread.Hash+"?token="+token, ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expired token: %d", resp.StatusCode)
+	}
+}
+
+func TestGatewayAllowList(t *testing.T) {
+	store := NewMemoryStore()
+	farm := Create("actor.producer", map[string]interface{}{"name": "Green Acres"}, nil)
+	honey := Create("substance.product", map[string]interface{}{"name": "Honey"}, map[string]interface{}{"seller": farm.Hash})
+	store.PutAll([]Block{farm, honey})
+	srv := httptest.NewServer(GatewayHandler(store, GatewayOptions{Allow: []string{honey.Hash}, RateLimit: 2, Now: func() time.Time { return time.Unix(0, 0) }}))
+	defer srv.Close()
+
+	resp, _ := http.Get(srv.URL + "/blocks/" + farm.Hash)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("allowed provenance: %d", resp.StatusCode)
+	}
+	if resp, _ := http.Get(srv.URL + "/verify/" + honey.Hash); resp.StatusCode != http.StatusOK {
+		t.Errorf("allowed root: %d", resp.StatusCode)
+	}
+	if resp, _ := http.Get(srv.URL + "/verify/" + honey.Hash); resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("rate limit: %d", resp.StatusCode)
+	}
+	resp, _ = http.Post(srv.URL+"/blocks/"+farm.Hash, "application/json", nil)
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("write: %d", resp.StatusCode)
+	}
+}
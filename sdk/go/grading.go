@@ -0,0 +1,238 @@
+package foodblock
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// GradingType records a quality inspection of a lot or delivery.
+const GradingType = "observe.grading"
+
+// GradeBand is one grade of a scheme: a sample is awarded the first band
+// whose MaxDefectRate it does not exceed.
+type GradeBand struct {
+	Grade         string  `json:"grade"`
+	MaxDefectRate float64 `json:"max_defect_rate"` // defective units / sample size
+}
+
+// GradingScheme grades one commodity. Bands are ordered best first; samples
+// worse than every band get RejectGrade. Accept lists the grades that pass an
+// order; empty accepts every band.
+type GradingScheme struct {
+	Commodity   string      `json:"commodity"`
+	Bands       []GradeBand `json:"bands"`
+	RejectGrade string      `json:"reject_grade"`
+	Accept      []string    `json:"accept,omitempty"`
+}
+
+// GradingSchemes holds the built-in schemes by commodity. "produce" is the
+// fallback for commodities without their own scheme. Callers may add or
+// replace entries, or pass a scheme to CreateGrading directly.
+var GradingSchemes = map[string]GradingScheme{
+	"produce": {
+		Commodity:   "produce",
+		Bands:       []GradeBand{{"extra", 0.02}, {"class_1", 0.05}, {"class_2", 0.10}},
+		RejectGrade: "out_of_grade",
+	},
+	"apples": {
+		Commodity:   "apples",
+		Bands:       []GradeBand{{"extra", 0.01}, {"class_1", 0.04}, {"class_2", 0.08}},
+		RejectGrade: "processing",
+		Accept:      []string{"extra", "class_1"},
+	},
+	"potatoes": {
+		Commodity:   "potatoes",
+		Bands:       []GradeBand{{"premium", 0.03}, {"standard", 0.08}},
+		RejectGrade: "stockfeed",
+	},
+	// Green coffee per SCA: full defects in a 350 g sample, so a sample size
+	// of 350 gives specialty at 5 defects, premium at 8 and exchange at 23.
+	"coffee": {
+		Commodity:   "coffee",
+		Bands:       []GradeBand{{"specialty", 5.0 / 350}, {"premium", 8.0 / 350}, {"exchange", 23.0 / 350}},
+		RejectGrade: "below_standard",
+		Accept:      []string{"specialty", "premium"},
+	},
+}
+
+// SchemeFor returns the scheme for a commodity, falling back to "produce".
+func SchemeFor(commodity string) GradingScheme {
+	if s, ok := GradingSchemes[strings.ToLower(commodity)]; ok {
+		return s
+	}
+	return GradingSchemes["produce"]
+}
+
+// Grade awards a grade for a sample with the given defect counts and
+// reports whether the grade is acceptable.
+func (s GradingScheme) Grade(sampleSize int, defects map[string]int) (grade string, rate float64, accepted bool) {
+	total := 0
+	for _, n := range defects {
+		total += n
+	}
+	if sampleSize > 0 {
+		rate = float64(total) / float64(sampleSize)
+	}
+	grade = s.RejectGrade
+	for _, band := range s.Bands {
+		if rate <= band.MaxDefectRate {
+			grade = band.Grade
+			break
+		}
+	}
+	return grade, rate, s.accepts(grade)
+}
+
+func (s GradingScheme) accepts(grade string) bool {
+	if grade == s.RejectGrade {
+		return false
+	}
+	if len(s.Accept) == 0 {
+		return true
+	}
+	return containsStr(s.Accept, grade)
+}
+
+// Inspection is the input to CreateGrading.
+type Inspection struct {
+	Subject    string // lot, substance or delivery hash
+	Order      string // order the delivery fulfils, optional
+	Supplier   string // graded supplier; gradings of its orders count without it
+	Inspector  string
+	Commodity  string
+	SampleSize int
+	Defects    map[string]int // defect kind -> units
+	Scheme     *GradingScheme // overrides SchemeFor(Commodity)
+}
+
+// CreateGrading grades an inspection and returns the observe.grading block.
+// Its state.decision is "accepted" or "rejected" under the scheme.
+func CreateGrading(in Inspection) (Block, error) {
+	if in.Subject == "" && in.Order == "" {
+		return Block{}, errors.New("FoodBlock: inspection needs a subject or an order")
+	}
+	if in.Inspector == "" {
+		return Block{}, errors.New("FoodBlock: inspector is required")
+	}
+	if in.SampleSize <= 0 {
+		return Block{}, errors.New("FoodBlock: sample size must be positive")
+	}
+	scheme := SchemeFor(in.Commodity)
+	if in.Scheme != nil {
+		scheme = *in.Scheme
+	}
+	grade, rate, accepted := scheme.Grade(in.SampleSize, in.Defects)
+	decision := "rejected"
+	if accepted {
+		decision = "accepted"
+	}
+	defects := make(map[string]interface{}, len(in.Defects))
+	for k, n := range in.Defects {
+		defects[k] = n
+	}
+	state := map[string]interface{}{
+		"commodity":   scheme.Commodity,
+		"grade":       grade,
+		"sample_size": in.SampleSize,
+		"defects":     defects,
+		"defect_rate": rate,
+		"decision":    decision,
+	}
+	refs := map[string]interface{}{"inspector": in.Inspector}
+	if in.Subject != "" {
+		refs["subject"] = in.Subject
+	}
+	if in.Order != "" {
+		refs["order"] = in.Order
+	}
+	if in.Supplier != "" {
+		refs["seller"] = in.Supplier
+	}
+	return Create(GradingType, state, refs), nil
+}
+
+// DecideOrder records a grading's decision on the order it inspected as a new
+// version of the order with status "accepted" or "rejected".
+func DecideOrder(order, grading Block) (Block, error) {
+	if grading.Type != GradingType || grading.Refs["order"] != order.Hash {
+		return Block{}, fmt.Errorf("FoodBlock: grading %s does not inspect order %s", grading.Hash, order.Hash)
+	}
+	state := make(map[string]interface{}, len(order.State)+2)
+	for k, v := range order.State {
+		state[k] = v
+	}
+	state["status"] = grading.State["decision"]
+	state["grade"] = grading.State["grade"]
+	refs := make(map[string]interface{}, len(order.Refs)+1)
+	for k, v := range order.Refs {
+		if k != "updates" {
+			refs[k] = v
+		}
+	}
+	refs["grading"] = grading.Hash
+	return Update(order.Hash, order.Type, state, refs), nil
+}
+
+// GradingStats summarises a supplier's inspection history.
+type GradingStats struct {
+	Supplier       string         `json:"supplier"`
+	Inspections    int            `json:"inspections"`
+	Accepted       int            `json:"accepted"`
+	Rejected       int            `json:"rejected"`
+	AcceptanceRate float64        `json:"acceptance_rate"`
+	AvgDefectRate  float64        `json:"avg_defect_rate"`
+	ByGrade        map[string]int `json:"by_grade"`
+	ByDefect       map[string]int `json:"by_defect"`
+}
+
+// SupplierGradingStats aggregates observe.grading blocks for a supplier: those
+// naming it as seller, or inspecting an order it sold.
+func SupplierGradingStats(supplier string, blocks []Block) GradingStats {
+	stats := GradingStats{Supplier: supplier, ByGrade: map[string]int{}, ByDefect: map[string]int{}}
+	for _, g := range supplierGradings(supplier, blocks) {
+		stats.Inspections++
+		if g.State["decision"] == "accepted" {
+			stats.Accepted++
+		} else {
+			stats.Rejected++
+		}
+		rate, _ := toFloat64(g.State["defect_rate"])
+		stats.AvgDefectRate += rate
+		if grade, ok := g.State["grade"].(string); ok {
+			stats.ByGrade[grade]++
+		}
+		defects, _ := g.State["defects"].(map[string]interface{})
+		for k, v := range defects {
+			n, _ := toFloat64(v)
+			stats.ByDefect[k] += int(n)
+		}
+	}
+	if stats.Inspections > 0 {
+		stats.AcceptanceRate = float64(stats.Accepted) / float64(stats.Inspections)
+		stats.AvgDefectRate /= float64(stats.Inspections)
+	}
+	return stats
+}
+
+// supplierGradings returns the gradings naming the supplier as seller or
+// inspecting an order it sold, in input order.
+func supplierGradings(supplier string, blocks []Block) []Block {
+	sold := map[string]bool{}
+	for _, b := range blocks {
+		if strings.HasPrefix(b.Type, "transfer.order") && b.Refs["seller"] == supplier {
+			sold[b.Hash] = true
+		}
+	}
+	var out []Block
+	for _, b := range blocks {
+		if b.Type != GradingType {
+			continue
+		}
+		order, _ := b.Refs["order"].(string)
+		if b.Refs["seller"] == supplier || (order != "" && sold[order]) {
+			out = append(out, b)
+		}
+	}
+	return out
+}
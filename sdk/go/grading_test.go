@@ -0,0 +1,64 @@
+package foodblock
+
+import "testing"
+
+func TestGradingSchemes(t *testing.T) {
+	apples := SchemeFor("Apples")
+	grade, rate, ok := apples.Grade(100, map[string]int{"bruising": 2, "scab": 1})
+	if grade != "class_1" || rate != 0.03 || !ok {
+		t.Errorf("expected accepted class_1 at 3%%, got %s %v %v", grade, rate, ok)
+	}
+	if grade, _, ok := apples.Grade(100, map[string]int{"bruising": 6}); grade != "class_2" || ok {
+		t.Errorf("class_2 apples should be graded but not accepted, got %s %v", grade, ok)
+	}
+	if grade, _, ok := SchemeFor("kale").Grade(50, map[string]int{"yellowing": 10}); grade != "out_of_grade" || ok {
+		t.Errorf("unknown commodity should fall back to produce and reject, got %s %v", grade, ok)
+	}
+}
+
+func TestGradingOrdersAndStats(t *testing.T) {
+	buyer := trustActor("Grocer")
+	supplier := trustActor("Orchard")
+	order1 := trustOrder(buyer.Hash, supplier.Hash, true)
+	order2 := Create("transfer.order", map[string]interface{}{"instance_id": "ord-2", "quantity": 5.0, "adapter_ref": "x"},
+		map[string]interface{}{"buyer": buyer.Hash, "seller": supplier.Hash})
+
+	good, err := CreateGrading(Inspection{Order: order1.Hash, Inspector: buyer.Hash, Commodity: "apples", SampleSize: 200, Defects: map[string]int{"bruising": 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bad, _ := CreateGrading(Inspection{Order: order2.Hash, Inspector: buyer.Hash, Commodity: "apples", SampleSize: 100, Defects: map[string]int{"rot": 12}})
+	if good.State["decision"] != "accepted" || bad.State["decision"] != "rejected" {
+		t.Fatalf("unexpected decisions %v / %v", good.State["decision"], bad.State["decision"])
+	}
+	if _, err := CreateGrading(Inspection{Order: order1.Hash, Inspector: buyer.Hash}); err == nil {
+		t.Error("expected sample size error")
+	}
+
+	decided, err := DecideOrder(order2, bad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decided.State["status"] != "rejected" || decided.Refs["updates"] != order2.Hash || decided.Refs["grading"] != bad.Hash {
+		t.Errorf("unexpected order decision %+v", decided)
+	}
+	if _, err := DecideOrder(order1.Block, bad); err == nil {
+		t.Error("expected mismatch error")
+	}
+
+	all := []Block{buyer.Block, supplier.Block, order1.Block, order2, good, bad}
+	stats := SupplierGradingStats(supplier.Hash, all)
+	if stats.Inspections != 2 || stats.Accepted != 1 || stats.AcceptanceRate != 0.5 || stats.ByDefect["rot"] != 12 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	tb := []TrustBlock{buyer, supplier, order1, {Block: order2}, {Block: good}}
+	before := ComputeTrustProfiles(supplier.Hash, tb, nil)["reliability"]
+	if before.Inputs.Quality != 1 {
+		t.Errorf("expected quality 1, got %d", before.Inputs.Quality)
+	}
+	after := ComputeTrustProfiles(supplier.Hash, append(tb, TrustBlock{Block: bad}), nil)["reliability"]
+	if after.Score >= before.Score {
+		t.Errorf("a rejected delivery should lower reliability: %v -> %v", before.Score, after.Score)
+	}
+}
@@ -0,0 +1,287 @@
+package foodblock
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GraphStatsOptions tune GraphStats.
+type GraphStatsOptions struct {
+	TopN int // entries in TopDegree and Authors, default 10
+	Now  func() time.Time
+}
+
+// TypeCount counts blocks of one type.
+type TypeCount struct {
+	Type  string `json:"type"`
+	Count int    `json:"count"`
+}
+
+// AgeBucket counts blocks stored within an age. The last bucket, "older",
+// has no limit; blocks without a clock are counted under "unknown".
+type AgeBucket struct {
+	Label string `json:"label"`
+	Count int    `json:"count"`
+}
+
+// ChainLength counts update chains of one length, in versions.
+type ChainLength struct {
+	Length int `json:"length"`
+	Count  int `json:"count"`
+}
+
+// NodeDegree is a block's number of refs out and in.
+type NodeDegree struct {
+	Hash  string `json:"hash"`
+	Type  string `json:"type"`
+	Label string `json:"label"`
+	In    int    `json:"in"`
+	Out   int    `json:"out"`
+}
+
+// AuthorActivity counts the blocks an author wrote.
+type AuthorActivity struct {
+	Author     string    `json:"author"`
+	Label      string    `json:"label,omitempty"`
+	Blocks     int       `json:"blocks"`
+	LastActive time.Time `json:"last_active,omitempty"`
+}
+
+// GraphReport is the health summary of a store, as served to dashboards.
+type GraphReport struct {
+	Blocks          int              `json:"blocks"`
+	Stubs           int              `json:"stubs"`
+	ByType          []TypeCount      `json:"by_type"`
+	ByAge           []AgeBucket      `json:"by_age"`
+	Chains          int              `json:"chains"` // heads of update chains, including single versions
+	ChainLengths    []ChainLength    `json:"chain_lengths"`
+	LongestChain    int              `json:"longest_chain"`
+	OrphanedRefs    int              `json:"orphaned_refs"`    // refs that resolve to nothing
+	BlocksOrphaning int              `json:"blocks_orphaning"` // blocks with at least one such ref
+	TopDegree       []NodeDegree     `json:"top_degree"`
+	Authors         []AuthorActivity `json:"authors"`
+	Bytes           int              `json:"bytes"` // canonical size of all blocks
+	GeneratedAt     time.Time        `json:"generated_at"`
+}
+
+var graphAgeBuckets = []struct {
+	label string
+	max   time.Duration
+}{
+	{"1d", 24 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+	{"30d", 30 * 24 * time.Hour},
+	{"365d", 365 * 24 * time.Hour},
+	{"older", 0},
+}
+
+// GraphStats summarises a store for operators: blocks by type and age,
+// the distribution of update chain lengths, refs that resolve to nothing,
+// the most connected blocks, author activity and storage size. Ages come
+// from the store's clocks and authors from its AuthorOf, falling back to
+// refs.author, when the store keeps them.
+func GraphStats(store BlockStore, opts GraphStatsOptions) GraphReport {
+	if opts.TopN <= 0 {
+		opts.TopN = 10
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	now := opts.Now()
+	clocks, _ := store.(ClockIndex)
+	authors, _ := store.(interface{ AuthorOf(string) string })
+
+	blocks := store.Blocks()
+	r := GraphReport{Blocks: len(blocks), GeneratedAt: now}
+	byHash := make(map[string]Block, len(blocks))
+	for _, b := range blocks {
+		byHash[b.Hash] = b
+	}
+
+	types := map[string]int{}
+	ages := make([]int, len(graphAgeBuckets)+1)
+	superseded := map[string]bool{}
+	in := map[string]int{}
+	activity := map[string]*AuthorActivity{}
+	for _, b := range blocks {
+		types[b.Type]++
+		if IsStub(b) {
+			r.Stubs++
+		}
+		r.Bytes += len(Canonical(b.Type, b.State, b.Refs))
+
+		var stored time.Time
+		if clocks != nil {
+			if c, ok := clocks.ClockOf(b.Hash); ok {
+				stored = time.UnixMilli(c.Wall)
+			}
+		}
+		ages[ageBucket(stored, now)]++
+
+		if prev, ok := b.Refs["updates"].(string); ok {
+			superseded[prev] = true
+		}
+		orphaning := false
+		for _, h := range flattenRefValues(b.Refs) {
+			in[h]++
+			if _, ok := byHash[h]; !ok && store.Resolve(h) == nil {
+				r.OrphanedRefs++
+				orphaning = true
+			}
+		}
+		if orphaning {
+			r.BlocksOrphaning++
+		}
+
+		author := ""
+		if authors != nil {
+			author = authors.AuthorOf(b.Hash)
+		}
+		if author == "" {
+			author, _ = b.Refs["author"].(string)
+		}
+		if author != "" {
+			a := activity[author]
+			if a == nil {
+				a = &AuthorActivity{Author: author}
+				if actor, ok := byHash[author]; ok {
+					a.Label = blockLabel(actor)
+				}
+				activity[author] = a
+			}
+			a.Blocks++
+			if stored.After(a.LastActive) {
+				a.LastActive = stored
+			}
+		}
+	}
+
+	for t, n := range types {
+		r.ByType = append(r.ByType, TypeCount{t, n})
+	}
+	sort.Slice(r.ByType, func(i, j int) bool {
+		if r.ByType[i].Count != r.ByType[j].Count {
+			return r.ByType[i].Count > r.ByType[j].Count
+		}
+		return r.ByType[i].Type < r.ByType[j].Type
+	})
+	for i, bucket := range graphAgeBuckets {
+		r.ByAge = append(r.ByAge, AgeBucket{bucket.label, ages[i]})
+	}
+	r.ByAge = append(r.ByAge, AgeBucket{"unknown", ages[len(graphAgeBuckets)]})
+
+	lengths := map[int]int{}
+	for _, b := range blocks {
+		if superseded[b.Hash] {
+			continue
+		}
+		n := 1
+		for cur := b; n <= len(blocks); n++ {
+			prev, ok := cur.Refs["updates"].(string)
+			if !ok {
+				break
+			}
+			if cur, ok = byHash[prev]; !ok {
+				break
+			}
+		}
+		r.Chains++
+		lengths[n]++
+		if n > r.LongestChain {
+			r.LongestChain = n
+		}
+	}
+	for n, count := range lengths {
+		r.ChainLengths = append(r.ChainLengths, ChainLength{n, count})
+	}
+	sort.Slice(r.ChainLengths, func(i, j int) bool { return r.ChainLengths[i].Length < r.ChainLengths[j].Length })
+
+	for _, b := range blocks {
+		r.TopDegree = append(r.TopDegree, NodeDegree{Hash: b.Hash, Type: b.Type, Label: blockLabel(b), In: in[b.Hash], Out: len(flattenRefValues(b.Refs))})
+	}
+	sort.Slice(r.TopDegree, func(i, j int) bool {
+		di, dj := r.TopDegree[i].In+r.TopDegree[i].Out, r.TopDegree[j].In+r.TopDegree[j].Out
+		if di != dj {
+			return di > dj
+		}
+		return r.TopDegree[i].Hash < r.TopDegree[j].Hash
+	})
+	if len(r.TopDegree) > opts.TopN {
+		r.TopDegree = r.TopDegree[:opts.TopN]
+	}
+
+	for _, a := range activity {
+		r.Authors = append(r.Authors, *a)
+	}
+	sort.Slice(r.Authors, func(i, j int) bool {
+		if r.Authors[i].Blocks != r.Authors[j].Blocks {
+			return r.Authors[i].Blocks > r.Authors[j].Blocks
+		}
+		return r.Authors[i].Author < r.Authors[j].Author
+	})
+	if len(r.Authors) > opts.TopN {
+		r.Authors = r.Authors[:opts.TopN]
+	}
+	return r
+}
+
+// ageBucket indexes graphAgeBuckets, or one past it for an unknown time.
+func ageBucket(stored, now time.Time) int {
+	if stored.IsZero() {
+		return len(graphAgeBuckets)
+	}
+	age := now.Sub(stored)
+	for i, bucket := range graphAgeBuckets {
+		if bucket.max == 0 || age < bucket.max {
+			return i
+		}
+	}
+	return len(graphAgeBuckets) - 1
+}
+
+// String renders the report for the command line.
+func (r GraphReport) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d blocks (%d stubs), %d bytes.\n", r.Blocks, r.Stubs, r.Bytes)
+	parts := make([]string, len(r.ByType))
+	for i, t := range r.ByType {
+		parts[i] = fmt.Sprintf("%s %d", t.Type, t.Count)
+	}
+	fmt.Fprintf(&sb, "Types: %s.\n", strings.Join(parts, ", "))
+	parts = parts[:0]
+	for _, a := range r.ByAge {
+		if a.Count > 0 {
+			parts = append(parts, fmt.Sprintf("%s %d", a.Label, a.Count))
+		}
+	}
+	fmt.Fprintf(&sb, "Age: %s.\n", strings.Join(parts, ", "))
+	parts = parts[:0]
+	for _, c := range r.ChainLengths {
+		noun := "versions"
+		if c.Length == 1 {
+			noun = "version"
+		}
+		parts = append(parts, fmt.Sprintf("%d of %d %s", c.Count, c.Length, noun))
+	}
+	fmt.Fprintf(&sb, "Chains: %d, longest %d (%s).\n", r.Chains, r.LongestChain, strings.Join(parts, ", "))
+	fmt.Fprintf(&sb, "Orphaned refs: %d in %d blocks.\n", r.OrphanedRefs, r.BlocksOrphaning)
+	if len(r.TopDegree) > 0 {
+		sb.WriteString("Most connected:\n")
+	}
+	for _, d := range r.TopDegree {
+		fmt.Fprintf(&sb, "  %s (%s): %d in, %d out\n", d.Label, d.Hash[:min(12, len(d.Hash))], d.In, d.Out)
+	}
+	if len(r.Authors) > 0 {
+		sb.WriteString("Authors:\n")
+	}
+	for _, a := range r.Authors {
+		name := a.Label
+		if name == "" {
+			name = a.Author
+		}
+		fmt.Fprintf(&sb, "  %s: %d blocks\n", name, a.Blocks)
+	}
+	return sb.String()
+}
@@ -0,0 +1,40 @@
+package foodblock
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGraphStats(t *testing.T) {
+	store := NewMemoryStore()
+	mill := Create("actor.producer", map[string]interface{}{"name": "Mill"}, nil)
+	v1 := Create("substance.product", map[string]interface{}{"name": "Rye", "price": 4.0}, map[string]interface{}{"seller": mill.Hash})
+	v2 := Update(v1.Hash, "substance.product", map[string]interface{}{"name": "Rye", "price": 4.5}, map[string]interface{}{"seller": mill.Hash})
+	v3 := Update(v2.Hash, "substance.product", map[string]interface{}{"name": "Rye", "price": 5.0}, map[string]interface{}{"seller": mill.Hash})
+	review := Create("observe.review", map[string]interface{}{"instance_id": "r1", "rating": 5.0}, map[string]interface{}{"subject": v3.Hash, "author": mill.Hash, "photo": "gone"})
+	store.PutAll([]Block{mill, v1, v2, v3, review})
+
+	r := GraphStats(store, GraphStatsOptions{TopN: 2, Now: func() time.Time { return time.Now().Add(48 * time.Hour) }})
+	if r.Blocks != 5 || r.ByType[0] != (TypeCount{"substance.product", 3}) || r.Bytes == 0 {
+		t.Errorf("counts = %+v", r)
+	}
+	if r.ByAge[1] != (AgeBucket{"7d", 5}) {
+		t.Errorf("ages = %+v", r.ByAge)
+	}
+	if r.Chains != 3 || r.LongestChain != 3 || len(r.ChainLengths) != 2 || r.ChainLengths[1] != (ChainLength{3, 1}) {
+		t.Errorf("chains = %d, longest %d, %+v", r.Chains, r.LongestChain, r.ChainLengths)
+	}
+	if r.OrphanedRefs != 1 || r.BlocksOrphaning != 1 {
+		t.Errorf("orphaned = %d in %d", r.OrphanedRefs, r.BlocksOrphaning)
+	}
+	if len(r.TopDegree) != 2 || r.TopDegree[0].Hash != mill.Hash || r.TopDegree[0].In != 4 {
+		t.Errorf("top degree = %+v", r.TopDegree)
+	}
+	if len(r.Authors) != 1 || r.Authors[0].Label != "Mill" || r.Authors[0].Blocks != 1 {
+		t.Errorf("authors = %+v", r.Authors)
+	}
+	if text := r.String(); !strings.Contains(text, "Chains: 3, longest 3 (2 of 1 version, 1 of 3 versions).") || !strings.Contains(text, "Orphaned refs: 1 in 1 blocks.") {
+		t.Errorf("text:\n%s", text)
+	}
+}
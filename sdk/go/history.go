@@ -0,0 +1,208 @@
+package foodblock
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FieldChange is one difference between two versions of a block.
+type FieldChange struct {
+	Field string      `json:"field"` // state field, or "refs.<role>"
+	Kind  string      `json:"kind"`  // "added", "removed" or "changed"
+	From  interface{} `json:"from,omitempty"`
+	To    interface{} `json:"to,omitempty"`
+}
+
+// DiffBlocks lists what changed from a to b, state fields first and then
+// refs, each sorted by name. Provenance annotations (state._prov) and the
+// updates ref, which differ between any two versions, are left out.
+func DiffBlocks(a, b Block) []FieldChange {
+	changes := diffMaps("", a.State, b.State)
+	return append(changes, diffMaps("refs.", a.Refs, b.Refs)...)
+}
+
+func diffMaps(prefix string, a, b map[string]interface{}) []FieldChange {
+	keys := map[string]bool{}
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+	names := make([]string, 0, len(keys))
+	for k := range keys {
+		if k == "_prov" || (prefix == "refs." && k == "updates") {
+			continue
+		}
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var out []FieldChange
+	for _, k := range names {
+		x, inA := a[k]
+		y, inB := b[k]
+		switch {
+		case !inA:
+			out = append(out, FieldChange{Field: prefix + k, Kind: "added", To: y})
+		case !inB:
+			out = append(out, FieldChange{Field: prefix + k, Kind: "removed", From: x})
+		case !sameJSON(x, y):
+			out = append(out, FieldChange{Field: prefix + k, Kind: "changed", From: x, To: y})
+		}
+	}
+	return out
+}
+
+// HistoryEntry is one version in an update chain.
+type HistoryEntry struct {
+	Hash    string        `json:"hash"`
+	Version int           `json:"version"`        // 1 for the original block
+	Date    string        `json:"date,omitempty"` // the date the version sets, else the store's clock
+	Author  string        `json:"author,omitempty"`
+	Changes []FieldChange `json:"changes,omitempty"`
+	Summary []string      `json:"summary"` // one sentence per change
+}
+
+// History is the change log of an update chain, oldest version first.
+type History struct {
+	Head    string         `json:"head"`
+	Type    string         `json:"type"`
+	Entries []HistoryEntry `json:"entries"`
+}
+
+// String renders the history as one line per version.
+func (h History) String() string {
+	var sb strings.Builder
+	for _, e := range h.Entries {
+		fmt.Fprintf(&sb, "v%d", e.Version)
+		if e.Date != "" {
+			fmt.Fprintf(&sb, " (%s)", e.Date)
+		}
+		fmt.Fprintf(&sb, ": %s\n", strings.Join(e.Summary, " "))
+	}
+	return sb.String()
+}
+
+// ExplainHistory describes how the update chain containing hash changed,
+// from the original block to its latest version, one entry per version.
+func ExplainHistory(hash string, store BlockStore) (History, error) {
+	if store.Resolve(hash) == nil {
+		return History{}, fmt.Errorf("FoodBlock: block not found: %s", hash)
+	}
+	head := Head(hash, store.ResolveForward, 0)
+	chain := Chain(head, store.Resolve, 0)
+	h := History{Head: head, Type: chain[len(chain)-1].Type}
+	clocks, _ := store.(ClockIndex)
+	authors, _ := store.(interface{ AuthorOf(string) string })
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		b := chain[i]
+		e := HistoryEntry{Hash: b.Hash, Version: len(chain) - i}
+		// A date the version sets itself wins over when the store saw it;
+		// dates carried over unchanged from the previous version do not.
+		if t, ok := BlockDate(b); ok {
+			prev, had := time.Time{}, false
+			if i < len(chain)-1 {
+				prev, had = BlockDate(chain[i+1])
+			}
+			if !had || !prev.Equal(t) {
+				e.Date = t.Format("2006-01-02")
+			}
+		}
+		if e.Date == "" && clocks != nil {
+			if clock, ok := clocks.ClockOf(b.Hash); ok && clock.Wall > 0 {
+				e.Date = time.UnixMilli(clock.Wall).UTC().Format("2006-01-02")
+			}
+		}
+		if authors != nil {
+			e.Author = authors.AuthorOf(b.Hash)
+		}
+
+		switch {
+		case b.Type == "observe.tombstone":
+			e.Summary = []string{"Erased."}
+		case i == len(chain)-1:
+			e.Summary = []string{fmt.Sprintf("Created as %s.", blockLabel(b))}
+		default:
+			e.Changes = DiffBlocks(chain[i+1], b)
+			for _, c := range e.Changes {
+				e.Summary = append(e.Summary, describeChange(c, store.Resolve))
+			}
+			if len(e.Summary) == 0 {
+				e.Summary = []string{"Republished without changes."}
+			}
+		}
+		h.Entries = append(h.Entries, e)
+	}
+	return h, nil
+}
+
+// progressFields move through stages rather than being edited.
+var progressFields = map[string]bool{"status": true, "stage": true, "phase": true}
+
+func describeChange(c FieldChange, resolve func(string) *Block) string {
+	label := fieldLabel(c.Field)
+	if strings.HasPrefix(c.Field, "refs.") {
+		switch c.Kind {
+		case "added":
+			return fmt.Sprintf("%s set to %s.", label, refLabel(c.To, resolve))
+		case "removed":
+			return fmt.Sprintf("%s removed.", label)
+		}
+		return fmt.Sprintf("%s changed from %s to %s.", label, refLabel(c.From, resolve), refLabel(c.To, resolve))
+	}
+	switch c.Kind {
+	case "added":
+		return fmt.Sprintf("%s set to %s.", label, historyValue(c.Field, c.To))
+	case "removed":
+		return fmt.Sprintf("%s removed.", label)
+	}
+	verb := "changed"
+	if progressFields[c.Field] {
+		verb = "moved"
+	}
+	return fmt.Sprintf("%s %s from %s to %s.", label, verb, historyValue(c.Field, c.From), historyValue(c.Field, c.To))
+}
+
+func fieldLabel(field string) string {
+	name := strings.ReplaceAll(strings.TrimPrefix(field, "refs."), "_", " ")
+	if name == "" {
+		return field
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// moneyFields are shown as amounts.
+var moneyFields = map[string]bool{"price": true, "unit_price": true, "total": true, "amount": true}
+
+func historyValue(field string, v interface{}) string {
+	if q, ok := v.(map[string]interface{}); ok {
+		if n, ok := toFloat64(q["value"]); ok {
+			return strings.TrimSpace(fmt.Sprintf("%g %v", n, firstString(q, "unit")))
+		}
+	}
+	if n, ok := toFloat64(v); ok {
+		if moneyFields[field] {
+			return fmt.Sprintf("$%.2f", n)
+		}
+		return fmt.Sprintf("%g", n)
+	}
+	return fmt.Sprint(v)
+}
+
+// refLabel names the blocks a ref points at, falling back to short hashes.
+func refLabel(v interface{}, resolve func(string) *Block) string {
+	var names []string
+	for _, h := range flattenRefValues(map[string]interface{}{"ref": v}) {
+		if b := resolve(h); b != nil {
+			names = append(names, blockLabel(*b))
+		} else if len(h) > 8 {
+			names = append(names, h[:8])
+		} else {
+			names = append(names, h)
+		}
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,208 @@
+package foodblock
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Request signing headers.
+const (
+	HeaderAuthor    = "X-FoodBlock-Author"
+	HeaderTimestamp = "X-FoodBlock-Timestamp"
+	HeaderSignature = "X-FoodBlock-Signature"
+	HeaderNonce     = "X-FoodBlock-Nonce"
+)
+
+// MaxSignedRequestBytes is the default cap on the body AuthMiddleware reads
+// to verify a request signature.
+const MaxSignedRequestBytes = 8 << 20
+
+type contextKey string
+
+const authorContextKey contextKey = "foodblock.author"
+
+// AuthOptions configures AuthMiddleware.
+type AuthOptions struct {
+	MaxSkew      time.Duration // accepted clock difference, default 5 minutes
+	RequireReads bool          // also reject unauthenticated GET/HEAD/OPTIONS
+	MaxBodyBytes int64         // largest signed body read, default MaxSignedRequestBytes
+	// Replay remembers request nonces so a captured request cannot be sent
+	// again within the skew window. Defaults to a guard of the middleware's
+	// own; share one between middlewares serving the same actors.
+	Replay *ReplayGuard
+	Now    func() time.Time
+}
+
+// RequestDigest is the string an actor signs to authenticate a request:
+// method, host, path with query, author, unix timestamp, nonce and the hex
+// SHA-256 of the body. The host keeps a request for one server from being
+// accepted by another; the nonce lets the server refuse it a second time.
+func RequestDigest(method, host, pathAndQuery, author, timestamp, nonce string, body []byte) string {
+	sum := sha256.Sum256(body)
+	return strings.Join([]string{method, strings.ToLower(host), pathAndQuery, author, timestamp, nonce, hex.EncodeToString(sum[:])}, "\n")
+}
+
+// requestHost is the host a request is addressed to, on either side.
+func requestHost(req *http.Request) string {
+	if req.Host != "" {
+		return req.Host
+	}
+	return req.URL.Host
+}
+
+// SignRequest sets the FoodBlock signing headers on an outgoing request.
+func SignRequest(req *http.Request, authorHash string, privateKey []byte) error {
+	if authorHash == "" {
+		return errors.New("FoodBlock: authorHash is required")
+	}
+	if len(privateKey) != ed25519.PrivateKeySize {
+		return errors.New("FoodBlock: private key must be 64 bytes")
+	}
+	body, err := readBody(req)
+	if err != nil {
+		return err
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	nonceHex := hex.EncodeToString(nonce)
+	digest := RequestDigest(req.Method, requestHost(req), req.URL.RequestURI(), authorHash, ts, nonceHex, body)
+	sig := ed25519.Sign(ed25519.PrivateKey(privateKey), []byte(digest))
+
+	req.Header.Set(HeaderAuthor, authorHash)
+	req.Header.Set(HeaderTimestamp, ts)
+	req.Header.Set(HeaderNonce, nonceHex)
+	req.Header.Set(HeaderSignature, hex.EncodeToString(sig))
+	return nil
+}
+
+// VerifyRequest checks the signing headers on a request against the registry
+// and returns the authenticated author hash. It does not check the nonce
+// against earlier requests; AuthMiddleware does that with a ReplayGuard.
+func VerifyRequest(req *http.Request, keys *KeyRegistry, maxSkew time.Duration, now time.Time) (string, error) {
+	author := req.Header.Get(HeaderAuthor)
+	ts := req.Header.Get(HeaderTimestamp)
+	nonce := req.Header.Get(HeaderNonce)
+	sigHex := req.Header.Get(HeaderSignature)
+	if author == "" || ts == "" || nonce == "" || sigHex == "" {
+		return "", errors.New("FoodBlock: missing signature headers")
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return "", errors.New("FoodBlock: invalid timestamp")
+	}
+	if skew := now.Sub(time.Unix(unix, 0)); skew > maxSkew || skew < -maxSkew {
+		return "", errors.New("FoodBlock: request timestamp outside allowed skew")
+	}
+
+	pub, ok := keys.PublicKey(author)
+	if !ok {
+		return "", errors.New("FoodBlock: unknown author")
+	}
+	sig, err := hex.DecodeString(sigHex)
+	if err != nil {
+		return "", errors.New("FoodBlock: invalid signature encoding")
+	}
+	body, err := readBody(req)
+	if err != nil {
+		return "", err
+	}
+	digest := RequestDigest(req.Method, requestHost(req), req.URL.RequestURI(), author, ts, nonce, body)
+	if !ed25519.Verify(ed25519.PublicKey(pub), []byte(digest), sig) {
+		return "", errors.New("FoodBlock: signature verification failed")
+	}
+	return author, nil
+}
+
+// AuthMiddleware authenticates callers as FoodBlock actors. Requests carrying a
+// valid signature get the author hash attached to their context; requests with
+// an invalid signature or a nonce already used, and unsigned writes, are
+// rejected with 401, and signed bodies over opts.MaxBodyBytes with 413.
+func AuthMiddleware(keys *KeyRegistry, opts AuthOptions) func(http.Handler) http.Handler {
+	if opts.MaxSkew <= 0 {
+		opts.MaxSkew = 5 * time.Minute
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = MaxSignedRequestBytes
+	}
+	if opts.Replay == nil {
+		opts.Replay = &ReplayGuard{Now: opts.Now}
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get(HeaderSignature) == "" {
+				if opts.RequireReads || !isReadMethod(r.Method) {
+					http.Error(w, "FoodBlock: authentication required", http.StatusUnauthorized)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+			if r.Body != nil {
+				r.Body = http.MaxBytesReader(w, r.Body, opts.MaxBodyBytes)
+			}
+			author, err := VerifyRequest(r, keys, opts.MaxSkew, opts.Now())
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, "FoodBlock: request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			if err == nil {
+				// A request stays acceptable until its timestamp leaves the
+				// skew window, so its nonce is remembered that long.
+				unix, _ := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+				err = opts.Replay.CheckRequest(author, r.Header.Get(HeaderNonce), time.Unix(unix, 0).Add(opts.MaxSkew))
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(WithAuthor(r.Context(), author)))
+		})
+	}
+}
+
+// WithAuthor returns a context carrying an authenticated author hash.
+func WithAuthor(ctx context.Context, authorHash string) context.Context {
+	return context.WithValue(ctx, authorContextKey, authorHash)
+}
+
+// AuthorFromContext returns the authenticated author hash, if any.
+func AuthorFromContext(ctx context.Context) (string, bool) {
+	author, ok := ctx.Value(authorContextKey).(string)
+	return author, ok && author != ""
+}
+
+func isReadMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// readBody reads the request body and restores it for downstream handlers.
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
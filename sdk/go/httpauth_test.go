@@ -0,0 +1,136 @@
+package foodblock
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func authTestServer(t *testing.T) (http.Handler, string, []byte) {
+	t.Helper()
+	pub, priv := GenerateKeypair()
+	actor := Create("actor.venue", map[string]interface{}{"name": "Corner Bakery"}, nil)
+	keys := NewKeyRegistry()
+	if err := keys.Register(actor.Hash, pub); err != nil {
+		t.Fatal(err)
+	}
+	handler := AuthMiddleware(keys, AuthOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		author, _ := AuthorFromContext(r.Context())
+		w.Write([]byte(author))
+	}))
+	return handler, actor.Hash, priv
+}
+
+func TestAuthMiddlewareAcceptsSignedWrite(t *testing.T) {
+	handler, author, priv := authTestServer(t)
+	req := httptest.NewRequest("POST", "/blocks", strings.NewReader(`{"type":"substance.product"}`))
+	if err := SignRequest(req, author, priv); err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec.Body.String() != author {
+		t.Errorf("expected author %s in context, got %q", author, rec.Body.String())
+	}
+}
+
+func TestAuthMiddlewareRejectsUnsignedWrite(t *testing.T) {
+	handler, _, _ := authTestServer(t)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/blocks", strings.NewReader("{}")))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/blocks", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "" {
+		t.Errorf("expected anonymous read to pass without author, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestAuthMiddlewareRejectsTamperedBody(t *testing.T) {
+	handler, author, priv := authTestServer(t)
+	req := httptest.NewRequest("POST", "/blocks", strings.NewReader(`{"price":4.5}`))
+	SignRequest(req, author, priv)
+	tampered := httptest.NewRequest("POST", "/blocks", strings.NewReader(`{"price":0.5}`))
+	tampered.Header = req.Header
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, tampered)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for tampered body, got %d", rec.Code)
+	}
+}
+
+func TestAuthMiddlewareRejectsReplay(t *testing.T) {
+	handler, author, priv := authTestServer(t)
+	req := httptest.NewRequest("POST", "http://a.example/blocks", strings.NewReader(`{"price":4.5}`))
+	SignRequest(req, author, priv)
+	send := func(host string) int {
+		replay := httptest.NewRequest("POST", "http://"+host+"/blocks", strings.NewReader(`{"price":4.5}`))
+		replay.Header = req.Header.Clone()
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, replay)
+		return rec.Code
+	}
+	if code := send("b.example"); code != http.StatusUnauthorized {
+		t.Errorf("request for another host: got %d", code)
+	}
+	if code := send("a.example"); code != http.StatusOK {
+		t.Fatalf("first request: got %d", code)
+	}
+	if code := send("a.example"); code != http.StatusUnauthorized {
+		t.Errorf("replayed request: got %d", code)
+	}
+}
+
+func TestVerifyRequestCoversAuthor(t *testing.T) {
+	pub, priv := GenerateKeypair()
+	keys := NewKeyRegistry()
+	keys.Register("actor", pub)
+	keys.Register("alias", pub)
+	req := httptest.NewRequest("DELETE", "/blocks/abc", nil)
+	SignRequest(req, "actor", priv)
+	req.Header.Set(HeaderAuthor, "alias")
+	if _, err := VerifyRequest(req, keys, time.Minute, time.Now()); err == nil {
+		t.Error("accepted a request whose author header was swapped")
+	}
+}
+
+func TestVerifyRequestRejectsStaleTimestamp(t *testing.T) {
+	pub, priv := GenerateKeypair()
+	keys := NewKeyRegistry()
+	keys.Register("actor", pub)
+	req := httptest.NewRequest("DELETE", "/blocks/abc", nil)
+	SignRequest(req, "actor", priv)
+	if _, err := VerifyRequest(req, keys, time.Minute, time.Now().Add(time.Hour)); err == nil {
+		t.Error("expected stale request to be rejected")
+	}
+	if _, err := VerifyRequest(req, keys, time.Minute, time.Now()); err != nil {
+		t.Errorf("expected fresh request to verify, got %v", err)
+	}
+}
+
+func TestAuthMiddlewareLimitsBodyAndKeySize(t *testing.T) {
+	handler, author, priv := authTestServer(t)
+	req := httptest.NewRequest("POST", "/blocks", strings.NewReader(strings.Repeat("x", MaxSignedRequestBytes+1)))
+	req.Header.Set(HeaderAuthor, author)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(time.Now().Unix(), 10))
+	req.Header.Set(HeaderNonce, "n")
+	req.Header.Set(HeaderSignature, "00")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for oversized body, got %d", rec.Code)
+	}
+
+	if err := SignRequest(httptest.NewRequest("POST", "/blocks", nil), author, priv[:32]); err == nil {
+		t.Error("expected short private key to be rejected")
+	}
+}
@@ -0,0 +1,205 @@
+package foodblock
+
+import (
+	"strings"
+	"time"
+)
+
+// ICSOptions configures ExportICS.
+type ICSOptions struct {
+	CalendarName string
+	Now          time.Time // DTSTAMP for every event, default time.Now
+	Anchor       time.Time // first date for recurring market days without a start, default Now
+}
+
+var icsWeekdays = map[string]string{
+	"monday": "MO", "tuesday": "TU", "wednesday": "WE", "thursday": "TH",
+	"friday": "FR", "saturday": "SA", "sunday": "SU",
+}
+
+var icsFrequencies = map[string]string{
+	"daily": "DAILY", "weekly": "WEEKLY", "fortnightly": "WEEKLY;INTERVAL=2",
+	"biweekly": "WEEKLY;INTERVAL=2", "monthly": "MONTHLY",
+}
+
+type icsEvent struct {
+	uid         string
+	summary     string
+	description string
+	location    string
+	start, end  time.Time
+	allDay      bool
+	rrule       string
+}
+
+// ExportICS renders an iCalendar (RFC 5545) feed from transfer.booking blocks,
+// blocks with a market_day field, certification expiry dates (valid_until) and
+// transfer.subscription schedules. Only the latest version of each update
+// chain is exported. Event UIDs derive from block hashes, so re-exporting the
+// same blocks updates rather than duplicates subscribed events.
+func ExportICS(blocks []Block, opts ICSOptions) string {
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+	if opts.Anchor.IsZero() {
+		opts.Anchor = opts.Now
+	}
+	if opts.CalendarName == "" {
+		opts.CalendarName = "FoodBlock"
+	}
+
+	var events []icsEvent
+	for _, b := range FilterBlocks(blocks, QueryParams{HeadsOnly: true, IncludeExpired: true}) {
+		name := blockLabel(b)
+		switch {
+		case b.Type == "transfer.booking":
+			start, ok := stateTime(b.State, "start", "date", "time")
+			if !ok {
+				continue
+			}
+			allDay := isDateOnly(b.State, "start", "date")
+			end, ok := stateTime(b.State, "end")
+			if !ok {
+				// DTEND is exclusive, so an all-day booking ends the next day.
+				end = start.Add(time.Hour)
+				if allDay {
+					end = start.AddDate(0, 0, 1)
+				}
+			}
+			loc, _ := b.State["location"].(string)
+			events = append(events, icsEvent{uid: b.Hash + "-booking", summary: "Booking: " + name, location: loc, start: start, end: end,
+				allDay: allDay})
+
+		case b.Type == "observe.certification":
+			until, ok := stateTime(b.State, "valid_until")
+			if !ok {
+				continue
+			}
+			events = append(events, icsEvent{uid: b.Hash + "-expiry", summary: "Certification expires: " + name,
+				description: "fb:" + b.Hash, start: until, end: until.AddDate(0, 0, 1), allDay: true})
+
+		case b.Type == "transfer.subscription":
+			freq, _ := b.State["frequency"].(string)
+			rule, ok := icsFrequencies[strings.ToLower(freq)]
+			if !ok {
+				continue
+			}
+			start, ok := stateTime(b.State, "start_date", "next_delivery", "date")
+			if !ok {
+				continue
+			}
+			events = append(events, icsEvent{uid: b.Hash + "-subscription", summary: "Subscription: " + name,
+				start: start, end: start.AddDate(0, 0, 1), allDay: true, rrule: "FREQ=" + rule})
+		}
+
+		if day, ok := b.State["market_day"].(string); ok {
+			if code, ok := icsWeekdays[strings.ToLower(day)]; ok {
+				start := nextWeekday(opts.Anchor, code)
+				events = append(events, icsEvent{uid: b.Hash + "-market", summary: "Market day: " + name,
+					start: start, end: start.AddDate(0, 0, 1), allDay: true, rrule: "FREQ=WEEKLY;BYDAY=" + code})
+			}
+		}
+	}
+
+	var sb strings.Builder
+	line := func(s string) { sb.WriteString(foldICS(s) + "\r\n") }
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//FoodBlock//FoodBlock Go SDK//EN")
+	line("CALSCALE:GREGORIAN")
+	line("X-WR-CALNAME:" + escapeICS(opts.CalendarName))
+	stamp := opts.Now.UTC().Format("20060102T150405Z")
+	for _, e := range events {
+		line("BEGIN:VEVENT")
+		line("UID:" + e.uid + "@foodblock")
+		line("DTSTAMP:" + stamp)
+		if e.allDay {
+			line("DTSTART;VALUE=DATE:" + e.start.Format("20060102"))
+			line("DTEND;VALUE=DATE:" + e.end.Format("20060102"))
+		} else {
+			line("DTSTART:" + e.start.UTC().Format("20060102T150405Z"))
+			line("DTEND:" + e.end.UTC().Format("20060102T150405Z"))
+		}
+		if e.rrule != "" {
+			line("RRULE:" + e.rrule)
+		}
+		line("SUMMARY:" + escapeICS(e.summary))
+		if e.description != "" {
+			line("DESCRIPTION:" + escapeICS(e.description))
+		}
+		if e.location != "" {
+			line("LOCATION:" + escapeICS(e.location))
+		}
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return sb.String()
+}
+
+func blockLabel(b Block) string {
+	for _, f := range []string{"name", "title"} {
+		if s, ok := b.State[f].(string); ok && s != "" {
+			return s
+		}
+	}
+	return b.Type
+}
+
+func stateTime(state map[string]interface{}, fields ...string) (time.Time, bool) {
+	for _, f := range fields {
+		s, ok := state[f].(string)
+		if !ok || s == "" {
+			continue
+		}
+		for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"} {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+func isDateOnly(state map[string]interface{}, fields ...string) bool {
+	for _, f := range fields {
+		if s, ok := state[f].(string); ok && s != "" {
+			return len(s) == len("2006-01-02")
+		}
+	}
+	return false
+}
+
+func nextWeekday(from time.Time, code string) time.Time {
+	d := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 7; i++ {
+		if icsWeekdays[strings.ToLower(d.Weekday().String())] == code {
+			return d
+		}
+		d = d.AddDate(0, 0, 1)
+	}
+	return d
+}
+
+func escapeICS(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	return r.Replace(s)
+}
+
+// foldICS folds content lines longer than 75 octets without splitting UTF-8 sequences.
+func foldICS(s string) string {
+	if len(s) <= 75 {
+		return s
+	}
+	var sb strings.Builder
+	width := 0
+	for _, r := range s {
+		size := len(string(r))
+		if width+size > 75 {
+			sb.WriteString("\r\n ")
+			width = 1
+		}
+		sb.WriteRune(r)
+		width += size
+	}
+	return sb.String()
+}
@@ -0,0 +1,65 @@
+package foodblock
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportICS(t *testing.T) {
+	now := time.Date(2026, 5, 6, 12, 0, 0, 0, time.UTC) // a Wednesday
+	booking := Create("transfer.booking", map[string]interface{}{"name": "Table for 6, window", "start": "2026-05-08T19:30:00Z", "location": "Bistro; Main St"}, nil)
+	cert := Create("observe.certification", map[string]interface{}{"name": "Organic", "valid_until": "2026-09-30"}, nil)
+	stall := Create("actor.vendor", map[string]interface{}{"name": "Jam Stall", "market_day": "Saturday"}, nil)
+	sub := Create("transfer.subscription", map[string]interface{}{"name": "Veg box", "frequency": "weekly", "start_date": "2026-05-11"}, nil)
+	product := Create("substance.product", map[string]interface{}{"name": "Bread"}, nil)
+
+	ics := ExportICS([]Block{booking, cert, stall, sub, product}, ICSOptions{Now: now, CalendarName: "Bistro"})
+
+	if !strings.HasPrefix(ics, "BEGIN:VCALENDAR\r\n") || !strings.HasSuffix(ics, "END:VCALENDAR\r\n") {
+		t.Fatal("expected CRLF-delimited calendar")
+	}
+	if n := strings.Count(ics, "BEGIN:VEVENT"); n != 4 {
+		t.Errorf("expected 4 events, got %d", n)
+	}
+	unfolded := strings.ReplaceAll(ics, "\r\n ", "")
+	for _, want := range []string{
+		"UID:" + booking.Hash + "-booking@foodblock",
+		"DTSTART:20260508T193000Z",
+		"DTEND:20260508T203000Z",
+		`SUMMARY:Booking: Table for 6\, window`,
+		`LOCATION:Bistro\; Main St`,
+		"DTSTART;VALUE=DATE:20260930",
+		"DTSTART;VALUE=DATE:20260509",
+		"RRULE:FREQ=WEEKLY;BYDAY=SA",
+		"RRULE:FREQ=WEEKLY\r\n",
+	} {
+		if !strings.Contains(unfolded, want) {
+			t.Errorf("expected %q in calendar:\n%s", want, ics)
+		}
+	}
+}
+
+func TestExportICSAllDayBookingsAndUpdates(t *testing.T) {
+	booking := Create("transfer.booking", map[string]interface{}{"name": "Tasting day", "date": "2026-05-09"}, nil)
+	moved := MergeUpdate(booking, map[string]interface{}{"date": "2026-05-10"}, nil)
+
+	ics := ExportICS([]Block{booking, moved}, ICSOptions{Now: time.Date(2026, 5, 6, 12, 0, 0, 0, time.UTC)})
+	if n := strings.Count(ics, "BEGIN:VEVENT"); n != 1 {
+		t.Fatalf("expected only the latest version exported, got %d events", n)
+	}
+	unfolded := strings.ReplaceAll(ics, "\r\n ", "")
+	if !strings.Contains(unfolded, "UID:"+moved.Hash+"-booking@foodblock") ||
+		!strings.Contains(unfolded, "DTSTART;VALUE=DATE:20260510\r\nDTEND;VALUE=DATE:20260511\r\n") {
+		t.Errorf("expected a one-day event for the moved booking:\n%s", ics)
+	}
+}
+
+func TestFoldICS(t *testing.T) {
+	folded := foldICS("SUMMARY:" + strings.Repeat("é", 60))
+	for _, l := range strings.Split(folded, "\r\n") {
+		if len(l) > 75 {
+			t.Errorf("line exceeds 75 octets: %d", len(l))
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package foodblock
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Identity binding kinds.
+const (
+	BindingAPIToken = "api_token"
+	BindingOIDC     = "oidc"
+)
+
+// OIDCVerifier validates a raw OIDC ID token and returns its issuer and subject.
+// Token validation (signature, audience, expiry) belongs to the caller's OIDC library.
+type OIDCVerifier func(rawToken string) (issuer, subject string, err error)
+
+// TokenMap resolves bearer tokens and OIDC subjects to actor hashes from
+// signed observe.identity_binding blocks. It is safe for concurrent use.
+type TokenMap struct {
+	mu       sync.RWMutex
+	keys     *KeyRegistry
+	admins   map[string]bool
+	bindings map[string]string // credential key -> actor hash
+	blocks   map[string]string // credential key -> binding block hash
+}
+
+// NewTokenMap creates a token map. Bindings must be signed by the bound actor
+// itself or by one of the admin actor hashes.
+func NewTokenMap(keys *KeyRegistry, admins ...string) *TokenMap {
+	m := &TokenMap{
+		keys:     keys,
+		admins:   make(map[string]bool),
+		bindings: make(map[string]string),
+		blocks:   make(map[string]string),
+	}
+	for _, a := range admins {
+		m.admins[a] = true
+	}
+	return m
+}
+
+// CreateTokenBinding creates an observe.identity_binding block for an API token.
+// Only the token's SHA-256 is recorded, never the token itself.
+func CreateTokenBinding(token, actorHash, label string) (Block, error) {
+	if token == "" || actorHash == "" {
+		return Block{}, errors.New("FoodBlock: token and actorHash are required")
+	}
+	state := map[string]interface{}{
+		"kind":            BindingAPIToken,
+		"credential_hash": Sha256Hex(token),
+	}
+	if label != "" {
+		state["label"] = label
+	}
+	return Create("observe.identity_binding", state, map[string]interface{}{"actor": actorHash}), nil
+}
+
+// CreateOIDCBinding creates an observe.identity_binding block for an OIDC issuer and subject.
+func CreateOIDCBinding(issuer, subject, actorHash string) (Block, error) {
+	if issuer == "" || subject == "" || actorHash == "" {
+		return Block{}, errors.New("FoodBlock: issuer, subject and actorHash are required")
+	}
+	return Create("observe.identity_binding", map[string]interface{}{
+		"kind":    BindingOIDC,
+		"issuer":  issuer,
+		"subject": subject,
+	}, map[string]interface{}{"actor": actorHash}), nil
+}
+
+// Bind verifies a signed identity binding and adds it to the map. API token
+// bindings may be signed by the bound actor or an admin. OIDC bindings must
+// be signed by an admin, since anyone can name any issuer and subject; an
+// actor binding its own login uses BindOIDC instead. A credential already
+// bound to another actor is only rebound when an admin signs the new
+// binding; otherwise Unbind the old one first.
+func (m *TokenMap) Bind(signed SignedBlock) error {
+	if kind, _ := signed.FoodBlock.State["kind"].(string); kind == BindingOIDC && !m.admins[signed.AuthorHash] {
+		return errors.New("FoodBlock: oidc binding must be signed by an admin, or proven with BindOIDC")
+	}
+	return m.bind(signed)
+}
+
+// BindOIDC adds an OIDC binding signed by the bound actor itself, proven by
+// an ID token that verify accepts for the binding's issuer and subject.
+func (m *TokenMap) BindOIDC(signed SignedBlock, rawToken string, verify OIDCVerifier) error {
+	if verify == nil {
+		return errors.New("FoodBlock: an oidc verifier is required")
+	}
+	state := signed.FoodBlock.State
+	if kind, _ := state["kind"].(string); kind != BindingOIDC {
+		return errors.New("FoodBlock: not an oidc binding")
+	}
+	iss, sub, err := verify(rawToken)
+	if err != nil {
+		return fmt.Errorf("FoodBlock: oidc token does not verify: %w", err)
+	}
+	if iss != state["issuer"] || sub != state["subject"] {
+		return errors.New("FoodBlock: oidc token is for another issuer or subject")
+	}
+	return m.bind(signed)
+}
+
+func (m *TokenMap) bind(signed SignedBlock) error {
+	block := signed.FoodBlock
+	if block.Type != "observe.identity_binding" {
+		return errors.New("FoodBlock: not an identity binding block")
+	}
+	if Hash(block.Type, block.State, block.Refs) != block.Hash {
+		return errors.New("FoodBlock: binding hash does not match content")
+	}
+	actor, _ := block.Refs["actor"].(string)
+	if actor == "" {
+		return errors.New("FoodBlock: binding has no actor ref")
+	}
+	if signed.AuthorHash != actor && !m.admins[signed.AuthorHash] {
+		return errors.New("FoodBlock: binding must be signed by the actor or an admin")
+	}
+	if !m.keys.VerifySigned(signed) {
+		return errors.New("FoodBlock: binding signature verification failed")
+	}
+	key, err := bindingKey(block.State)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if current, ok := m.bindings[key]; ok && current != actor && !m.admins[signed.AuthorHash] {
+		return errors.New("FoodBlock: credential is already bound to another actor")
+	}
+	m.bindings[key] = actor
+	m.blocks[key] = block.Hash
+	return nil
+}
+
+// Unbind removes the binding created by the given block hash and returns
+// whether one was found. Pair with a Tombstone of the binding block.
+func (m *TokenMap) Unbind(bindingHash string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, h := range m.blocks {
+		if h == bindingHash {
+			delete(m.blocks, key)
+			delete(m.bindings, key)
+			return true
+		}
+	}
+	return false
+}
+
+// LookupToken returns the actor hash bound to an API token.
+func (m *TokenMap) LookupToken(token string) (string, bool) {
+	return m.lookup(BindingAPIToken + ":" + Sha256Hex(token))
+}
+
+// LookupOIDC returns the actor hash bound to an OIDC issuer and subject.
+func (m *TokenMap) LookupOIDC(issuer, subject string) (string, bool) {
+	return m.lookup(oidcKey(issuer, subject))
+}
+
+func (m *TokenMap) lookup(key string) (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	actor, ok := m.bindings[key]
+	return actor, ok
+}
+
+// TokenMiddleware resolves "Authorization: Bearer" credentials to actor hashes
+// and attaches them to the request context (see AuthorFromContext). API tokens are
+// tried first, then the OIDC verifier if one is given. Unknown credentials get 401;
+// requests without credentials pass through unauthenticated.
+func TokenMiddleware(tokens *TokenMap, oidc OIDCVerifier) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			auth := r.Header.Get("Authorization")
+			if auth == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			raw := strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+			if raw == auth || raw == "" {
+				http.Error(w, "FoodBlock: expected bearer token", http.StatusUnauthorized)
+				return
+			}
+
+			actor, ok := tokens.LookupToken(raw)
+			if !ok && oidc != nil {
+				if iss, sub, err := oidc(raw); err == nil {
+					actor, ok = tokens.LookupOIDC(iss, sub)
+				}
+			}
+			if !ok {
+				http.Error(w, "FoodBlock: unknown credential", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(WithAuthor(r.Context(), actor)))
+		})
+	}
+}
+
+func bindingKey(state map[string]interface{}) (string, error) {
+	kind, _ := state["kind"].(string)
+	switch kind {
+	case BindingAPIToken:
+		h, _ := state["credential_hash"].(string)
+		if h == "" {
+			return "", errors.New("FoodBlock: api_token binding requires credential_hash")
+		}
+		return BindingAPIToken + ":" + h, nil
+	case BindingOIDC:
+		iss, _ := state["issuer"].(string)
+		sub, _ := state["subject"].(string)
+		if iss == "" || sub == "" {
+			return "", errors.New("FoodBlock: oidc binding requires issuer and subject")
+		}
+		return oidcKey(iss, sub), nil
+	}
+	return "", errors.New("FoodBlock: unknown binding kind: " + kind)
+}
+
+// oidcKey length-prefixes the issuer so that no issuer and subject pair
+// shares a key with another, whatever characters they contain.
+func oidcKey(issuer, subject string) string {
+	return fmt.Sprintf("%s:%d:%s#%s", BindingOIDC, len(issuer), issuer, subject)
+}
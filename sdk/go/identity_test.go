@@ -0,0 +1,169 @@
+package foodblock
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTokenMapBindAndLookup(t *testing.T) {
+	pub, priv := GenerateKeypair()
+	actor := Create("actor.venue", map[string]interface{}{"name": "Market Cafe"}, nil)
+	keys := NewKeyRegistry()
+	keys.Register(actor.Hash, pub)
+	tokens := NewTokenMap(keys)
+
+	binding, err := CreateTokenBinding("tok_secret", actor.Hash, "pos integration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, leaked := binding.State["token"]; leaked {
+		t.Fatal("raw token must not be stored in the binding")
+	}
+	if err := tokens.Bind(Sign(binding, actor.Hash, priv)); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := tokens.LookupToken("tok_secret"); !ok || got != actor.Hash {
+		t.Errorf("expected token to resolve to actor, got %q", got)
+	}
+	if _, ok := tokens.LookupToken("tok_other"); ok {
+		t.Error("expected unknown token to miss")
+	}
+
+	if !tokens.Unbind(binding.Hash) {
+		t.Error("expected unbind to find binding")
+	}
+	if _, ok := tokens.LookupToken("tok_secret"); ok {
+		t.Error("expected token to be unbound")
+	}
+}
+
+func TestTokenMapRejectsForeignSigner(t *testing.T) {
+	_, priv := GenerateKeypair()
+	mallory, malloryPriv := GenerateKeypair()
+	keys := NewKeyRegistry()
+	keys.Register("mallory", mallory)
+	tokens := NewTokenMap(keys)
+
+	binding, _ := CreateTokenBinding("tok", "victim", "")
+	if err := tokens.Bind(Sign(binding, "mallory", malloryPriv)); err == nil {
+		t.Error("expected binding signed by another actor to be rejected")
+	}
+	if err := tokens.Bind(Sign(binding, "victim", priv)); err == nil {
+		t.Error("expected binding from unregistered key to be rejected")
+	}
+
+	admins := NewTokenMap(keys, "mallory")
+	if err := admins.Bind(Sign(binding, "mallory", malloryPriv)); err != nil {
+		t.Errorf("expected admin-signed binding to be accepted, got %v", err)
+	}
+}
+
+func TestTokenMiddlewareOIDC(t *testing.T) {
+	pub, priv := GenerateKeypair()
+	keys := NewKeyRegistry()
+	keys.Register("admin", pub)
+	tokens := NewTokenMap(keys, "admin")
+	binding, _ := CreateOIDCBinding("https://accounts.example", "user-42", "actor_hash")
+	if err := tokens.Bind(Sign(binding, "admin", priv)); err != nil {
+		t.Fatal(err)
+	}
+
+	verifier := func(raw string) (string, string, error) {
+		if raw != "id-token" {
+			return "", "", errors.New("bad token")
+		}
+		return "https://accounts.example", "user-42", nil
+	}
+	handler := TokenMiddleware(tokens, verifier)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		author, _ := AuthorFromContext(r.Context())
+		w.Write([]byte(author))
+	}))
+
+	req := httptest.NewRequest("GET", "/blocks", nil)
+	req.Header.Set("Authorization", "Bearer id-token")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "actor_hash" {
+		t.Errorf("expected actor_hash, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	req.Header.Set("Authorization", "Bearer forged")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for unknown credential, got %d", rec.Code)
+	}
+}
+
+func TestTokenMapRefusesTakeover(t *testing.T) {
+	ownerPub, ownerPriv := GenerateKeypair()
+	malloryPub, malloryPriv := GenerateKeypair()
+	adminPub, adminPriv := GenerateKeypair()
+	keys := NewKeyRegistry()
+	keys.Register("owner", ownerPub)
+	keys.Register("mallory", malloryPub)
+	keys.Register("admin", adminPub)
+	tokens := NewTokenMap(keys, "admin")
+	// Each ID token proves the login of whoever holds it.
+	idp := func(raw string) (string, string, error) {
+		switch raw {
+		case "owner-token":
+			return "https://id.example", "user-1", nil
+		case "mallory-token":
+			return "https://id.example", "user-2", nil
+		}
+		return "", "", errors.New("bad token")
+	}
+
+	owned, _ := CreateOIDCBinding("https://id.example", "user-1", "owner")
+	claim, _ := CreateOIDCBinding("https://id.example", "user-1", "mallory")
+	if err := tokens.Bind(Sign(claim, "mallory", malloryPriv)); err == nil {
+		t.Error("accepted a self-signed oidc binding without proof of the subject")
+	}
+	if err := tokens.BindOIDC(Sign(claim, "mallory", malloryPriv), "mallory-token", idp); err == nil {
+		t.Error("accepted an oidc binding proven with another subject's token")
+	}
+	if err := tokens.BindOIDC(Sign(owned, "owner", ownerPriv), "owner-token", idp); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := tokens.LookupOIDC("https://id.example", "user-1"); got != "owner" {
+		t.Errorf("credential now resolves to %q", got)
+	}
+	if err := tokens.Bind(Sign(claim, "admin", adminPriv)); err != nil {
+		t.Errorf("admin rebind refused: %v", err)
+	}
+	tokens.Unbind(claim.Hash)
+
+	// Token credentials may still be self-signed, but not taken over.
+	token, _ := CreateTokenBinding("tok", "owner", "")
+	if err := tokens.Bind(Sign(token, "owner", ownerPriv)); err != nil {
+		t.Fatal(err)
+	}
+	stolen, _ := CreateTokenBinding("tok", "mallory", "")
+	if err := tokens.Bind(Sign(stolen, "mallory", malloryPriv)); err == nil {
+		t.Error("self-signed binding took over another actor's token")
+	}
+	tokens.Unbind(token.Hash)
+	if err := tokens.Bind(Sign(stolen, "mallory", malloryPriv)); err != nil {
+		t.Errorf("binding after unbind refused: %v", err)
+	}
+}
+
+func TestTokenMapOIDCKeysAreUnambiguous(t *testing.T) {
+	pub, priv := GenerateKeypair()
+	keys := NewKeyRegistry()
+	keys.Register("admin", pub)
+	tokens := NewTokenMap(keys, "admin")
+	binding, _ := CreateOIDCBinding("https://id.example#a", "b", "actor_hash")
+	if err := tokens.Bind(Sign(binding, "admin", priv)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tokens.LookupOIDC("https://id.example", "a#b"); ok {
+		t.Error("a different issuer and subject resolved to the same binding")
+	}
+	if got, ok := tokens.LookupOIDC("https://id.example#a", "b"); !ok || got != "actor_hash" {
+		t.Errorf("binding resolves to %q", got)
+	}
+}
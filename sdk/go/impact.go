@@ -0,0 +1,213 @@
+package foodblock
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// ImpactType records the environmental impact of a product or batch.
+const ImpactType = "observe.impact"
+
+// Impact categories and the unit each is measured in per kg of ingredient.
+const (
+	ImpactCarbon = "carbon" // kg CO2e
+	ImpactWater  = "water"  // litres of freshwater withdrawal
+	ImpactLand   = "land"   // m² of land occupied for a year
+)
+
+// ImpactCategories lists the categories in report order.
+var ImpactCategories = []string{ImpactCarbon, ImpactWater, ImpactLand}
+
+// ImpactFactors are indicative per-kg factors by ingredient (global medians
+// from Poore & Nemecek, 2018). Keys are lower-case ingredient names; callers
+// may add or override entries with supplier-specific figures.
+var ImpactFactors = map[string]map[string]float64{
+	"wheat":    {ImpactCarbon: 1.4, ImpactWater: 648, ImpactLand: 3.9},
+	"flour":    {ImpactCarbon: 1.4, ImpactWater: 648, ImpactLand: 3.9},
+	"oats":     {ImpactCarbon: 2.5, ImpactWater: 482, ImpactLand: 7.6},
+	"barley":   {ImpactCarbon: 1.2, ImpactWater: 17, ImpactLand: 1.1},
+	"rice":     {ImpactCarbon: 4.5, ImpactWater: 2248, ImpactLand: 2.8},
+	"potatoes": {ImpactCarbon: 0.5, ImpactWater: 59, ImpactLand: 0.9},
+	"tomatoes": {ImpactCarbon: 2.1, ImpactWater: 370, ImpactLand: 0.8},
+	"apples":   {ImpactCarbon: 0.4, ImpactWater: 180, ImpactLand: 0.6},
+	"sugar":    {ImpactCarbon: 3.2, ImpactWater: 620, ImpactLand: 2.0},
+	"milk":     {ImpactCarbon: 3.2, ImpactWater: 628, ImpactLand: 8.9},
+	"butter":   {ImpactCarbon: 12.0, ImpactWater: 3000, ImpactLand: 42.0},
+	"cheese":   {ImpactCarbon: 23.9, ImpactWater: 5605, ImpactLand: 87.8},
+	"eggs":     {ImpactCarbon: 4.7, ImpactWater: 578, ImpactLand: 5.7},
+	"chicken":  {ImpactCarbon: 9.9, ImpactWater: 660, ImpactLand: 12.2},
+	"pork":     {ImpactCarbon: 12.3, ImpactWater: 1796, ImpactLand: 17.4},
+	"beef":     {ImpactCarbon: 99.5, ImpactWater: 1451, ImpactLand: 326.2},
+	"lamb":     {ImpactCarbon: 39.7, ImpactWater: 1803, ImpactLand: 369.8},
+}
+
+// DefaultImpactWeights weight categories in SustainabilityIndex.
+var DefaultImpactWeights = map[string]float64{ImpactCarbon: 0.5, ImpactWater: 0.25, ImpactLand: 0.25}
+
+// ImpactReferences normalise each category before weighting: the per-kg
+// value that scores 1.0 (roughly an average food).
+var ImpactReferences = map[string]float64{ImpactCarbon: 5, ImpactWater: 1000, ImpactLand: 10}
+
+// ImpactInput is an ingredient and the mass used, in kg.
+type ImpactInput struct {
+	Ingredient string  `json:"ingredient"`
+	Kg         float64 `json:"kg"`
+}
+
+// Impact is the combined footprint of a set of ingredients.
+type Impact struct {
+	TotalKg      float64                       `json:"total_kg"`
+	Categories   map[string]float64            `json:"categories"`
+	ByIngredient map[string]map[string]float64 `json:"by_ingredient"`
+	Unknown      []string                      `json:"unknown,omitempty"` // ingredients without factors
+}
+
+// ComputeImpact sums each category over the inputs. A nil factors table uses
+// ImpactFactors. Ingredients without factors add mass but no impact and are
+// listed in Unknown.
+func ComputeImpact(inputs []ImpactInput, factors map[string]map[string]float64) Impact {
+	if factors == nil {
+		factors = ImpactFactors
+	}
+	im := Impact{Categories: map[string]float64{}, ByIngredient: map[string]map[string]float64{}}
+	for _, c := range ImpactCategories {
+		im.Categories[c] = 0
+	}
+	for _, in := range inputs {
+		name := strings.ToLower(strings.TrimSpace(in.Ingredient))
+		im.TotalKg += in.Kg
+		f, ok := factors[name]
+		if !ok {
+			im.Unknown = append(im.Unknown, in.Ingredient)
+			continue
+		}
+		line := im.ByIngredient[name]
+		if line == nil {
+			line = map[string]float64{}
+			im.ByIngredient[name] = line
+		}
+		for c, v := range f {
+			line[c] += v * in.Kg
+			im.Categories[c] += v * in.Kg
+		}
+	}
+	return im
+}
+
+// PerKg returns the category totals per kg of input.
+func (im Impact) PerKg() map[string]float64 {
+	out := make(map[string]float64, len(im.Categories))
+	for c, v := range im.Categories {
+		if im.TotalKg > 0 {
+			out[c] = v / im.TotalKg
+		}
+	}
+	return out
+}
+
+// ImpactInputs reads a product's state.ingredients: a list of
+// {name, quantity, unit} objects (mass units only).
+func ImpactInputs(product Block) ([]ImpactInput, error) {
+	list, ok := product.State["ingredients"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("FoodBlock: %s has no ingredient list", product.Hash)
+	}
+	var inputs []ImpactInput
+	for _, entry := range list {
+		m, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := m["name"].(string)
+		qty, unit, ok := blockMass(Block{State: m})
+		if name == "" || !ok {
+			continue
+		}
+		kg, ok := toKilograms(qty, unit)
+		if !ok {
+			return nil, fmt.Errorf("FoodBlock: ingredient %s has a non-mass unit %q", name, unit)
+		}
+		inputs = append(inputs, ImpactInput{Ingredient: name, Kg: kg})
+	}
+	if len(inputs) == 0 {
+		return nil, errors.New("FoodBlock: no ingredients with a mass")
+	}
+	return inputs, nil
+}
+
+// CreateImpact records an impact as an observe.impact block about subject,
+// with per-kg figures for each category and the sustainability index under
+// the given weights (nil uses DefaultImpactWeights).
+func CreateImpact(subject string, im Impact, weights map[string]float64) Block {
+	perKg := im.PerKg()
+	categories := make(map[string]interface{}, len(im.Categories))
+	for c, v := range im.Categories {
+		categories[c] = map[string]interface{}{"total": round3(v), "per_kg": round3(perKg[c])}
+	}
+	state := map[string]interface{}{
+		"instance_id":          "impact-" + subject,
+		"total_kg":             round3(im.TotalKg),
+		"categories":           categories,
+		"sustainability_index": round3(SustainabilityIndex(im, weights)),
+	}
+	if len(im.Unknown) > 0 {
+		unknown := make([]interface{}, len(im.Unknown))
+		for i, u := range im.Unknown {
+			unknown[i] = u
+		}
+		state["unknown_ingredients"] = unknown
+	}
+	return Create(ImpactType, state, map[string]interface{}{"subject": subject})
+}
+
+// SustainabilityIndex combines per-kg category values, each divided by its
+// ImpactReferences value, as a weighted average. Lower is better; 1.0 is an
+// average food. Weights need not sum to one.
+func SustainabilityIndex(im Impact, weights map[string]float64) float64 {
+	if weights == nil {
+		weights = DefaultImpactWeights
+	}
+	perKg := im.PerKg()
+	total, sum := 0.0, 0.0
+	for c, w := range weights {
+		ref := ImpactReferences[c]
+		if ref <= 0 || w <= 0 {
+			continue
+		}
+		total += w * perKg[c] / ref
+		sum += w
+	}
+	if sum == 0 {
+		return 0
+	}
+	return total / sum
+}
+
+// RankedProduct is one entry of RankByImpact.
+type RankedProduct struct {
+	Product string  `json:"product"`
+	Index   float64 `json:"index"`
+}
+
+// RankByImpact orders products from most to least sustainable under the
+// given category weights.
+func RankByImpact(impacts map[string]Impact, weights map[string]float64) []RankedProduct {
+	out := make([]RankedProduct, 0, len(impacts))
+	for product, im := range impacts {
+		out = append(out, RankedProduct{Product: product, Index: SustainabilityIndex(im, weights)})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Index != out[j].Index {
+			return out[i].Index < out[j].Index
+		}
+		return out[i].Product < out[j].Product
+	})
+	return out
+}
+
+func round3(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
@@ -0,0 +1,64 @@
+package foodblock
+
+import (
+	"math"
+	"testing"
+)
+
+func TestComputeImpact(t *testing.T) {
+	loaf := Create("substance.product", map[string]interface{}{
+		"name": "Farmhouse loaf",
+		"ingredients": []interface{}{
+			map[string]interface{}{"name": "Flour", "quantity": 500.0, "unit": "g"},
+			map[string]interface{}{"name": "Butter", "quantity": 0.05, "unit": "kg"},
+			map[string]interface{}{"name": "Yeast", "quantity": 10.0, "unit": "g"},
+		},
+	}, nil)
+	inputs, err := ImpactInputs(loaf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	im := ComputeImpact(inputs, nil)
+	if want := 0.5*1.4 + 0.05*12.0; math.Abs(im.Categories[ImpactCarbon]-want) > 1e-9 {
+		t.Errorf("expected %v kg CO2e, got %v", want, im.Categories[ImpactCarbon])
+	}
+	if want := 0.5*648 + 0.05*3000; math.Abs(im.Categories[ImpactWater]-want) > 1e-9 {
+		t.Errorf("expected %v L water, got %v", want, im.Categories[ImpactWater])
+	}
+	if len(im.Unknown) != 1 || im.Unknown[0] != "Yeast" {
+		t.Errorf("expected yeast to be reported as unknown, got %v", im.Unknown)
+	}
+
+	block := CreateImpact(loaf.Hash, im, nil)
+	cats := block.State["categories"].(map[string]interface{})
+	if block.Type != ImpactType || len(cats) != 3 || block.Refs["subject"] != loaf.Hash {
+		t.Errorf("unexpected impact block %+v", block)
+	}
+	if _, ok := block.State["sustainability_index"]; !ok {
+		t.Error("impact block should carry the sustainability index")
+	}
+
+	_, err = ImpactInputs(Create("substance.product", map[string]interface{}{
+		"ingredients": []interface{}{map[string]interface{}{"name": "Milk", "quantity": 1.0, "unit": "l"}},
+	}, nil))
+	if err == nil {
+		t.Error("expected an error for a volume unit")
+	}
+}
+
+func TestRankByImpact(t *testing.T) {
+	impacts := map[string]Impact{
+		"beef-burger": ComputeImpact([]ImpactInput{{"beef", 0.15}}, nil),
+		"bean-stew":   ComputeImpact([]ImpactInput{{"tomatoes", 0.3}, {"potatoes", 0.2}}, nil),
+		"rice-bowl":   ComputeImpact([]ImpactInput{{"rice", 0.3}}, nil),
+	}
+	ranked := RankByImpact(impacts, nil)
+	if ranked[0].Product != "bean-stew" || ranked[2].Product != "beef-burger" {
+		t.Errorf("unexpected ranking %+v", ranked)
+	}
+	// Weighting water alone puts rice behind beef.
+	waterOnly := RankByImpact(impacts, map[string]float64{ImpactWater: 1})
+	if waterOnly[2].Product != "rice-bowl" {
+		t.Errorf("expected rice to rank last on water, got %+v", waterOnly)
+	}
+}
@@ -0,0 +1,422 @@
+package foodblock
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ImportSpec describes an import job. CSV and NDJSON rows become one block of
+// Type each; Square POS exports become one transfer.order per transaction, as
+// in ImportPOS.
+type ImportSpec struct {
+	ID     string `json:"id,omitempty"` // journal key; defaults to a hash of the spec and input
+	Format string `json:"format"`       // csv, ndjson or square
+	Type   string `json:"type,omitempty"`
+	// Fields maps columns to state fields; nil maps every column not used as a
+	// ref to a field of the same name.
+	Fields  map[string]string `json:"fields,omitempty"`
+	Refs    map[string]string `json:"refs,omitempty"`    // column -> ref role
+	Key     string            `json:"key,omitempty"`     // column that identifies a row; defaults to its number
+	Numeric []string          `json:"numeric,omitempty"` // CSV fields parsed as numbers, commas as thousands separators
+	Seller  string            `json:"seller,omitempty"`  // square: the selling venue
+	// Currency of a Square export, default USD.
+	Currency string `json:"currency,omitempty"`
+}
+
+// ImportProgress counts the rows an import job has handled.
+type ImportProgress struct {
+	Total     int `json:"total"`
+	Processed int `json:"processed"`
+	Created   int `json:"created"` // rows stored by this run
+	Skipped   int `json:"skipped"` // rows found in the journal
+	Failed    int `json:"failed"`
+}
+
+// ImportRowError is why one row failed.
+type ImportRowError struct {
+	Row   int    `json:"row"` // 1-based, after any header
+	Key   string `json:"key"`
+	Error string `json:"error"`
+}
+
+// ImportJournal records the rows a job has stored, so a job run again after a
+// crash skips them.
+type ImportJournal interface {
+	Lookup(job, key string) ([]string, bool)
+	Record(job, key string, hashes []string) error
+}
+
+// ImportJob is a running or finished import. Its methods are safe to call
+// while it runs.
+type ImportJob struct {
+	ID   string
+	Spec ImportSpec
+
+	mu       sync.RWMutex
+	progress ImportProgress
+	errors   []ImportRowError
+	hashes   []string
+	block    Block
+	err      error
+	done     chan struct{}
+}
+
+// importRow is one parsed row: its key and the blocks it produces.
+type importRow struct {
+	key    string
+	blocks []Block
+	err    error
+}
+
+// SubmitImport parses the input and starts importing it into the store in the
+// background, journalling each stored row. Submitting the same spec and input
+// again with the same journal resumes: rows already journalled are skipped.
+// When every row has been handled the job stores an observe.import block
+// recording its counts and row errors and referencing the blocks it covers
+// (refs.created). Errors that stop the whole job, such as unparseable input,
+// are returned here or from Wait.
+func SubmitImport(spec ImportSpec, r io.Reader, store BlockStore, journal ImportJournal) (*ImportJob, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if spec.ID == "" {
+		specJSON, _ := json.Marshal(spec)
+		sum := sha256.Sum256(append(specJSON, data...))
+		spec.ID = hex.EncodeToString(sum[:8])
+	}
+	rows, err := parseImport(spec, data, store)
+	if err != nil {
+		return nil, err
+	}
+	if journal == nil {
+		journal = NewMemoryJournal()
+	}
+	job := &ImportJob{ID: spec.ID, Spec: spec, progress: ImportProgress{Total: len(rows)}, hashes: []string{}, done: make(chan struct{})}
+	go job.run(rows, store, journal)
+	return job, nil
+}
+
+func (j *ImportJob) run(rows []importRow, store BlockStore, journal ImportJournal) {
+	defer close(j.done)
+	for i, row := range rows {
+		hashes, err := importOne(j.ID, row, store, journal)
+		j.mu.Lock()
+		j.progress.Processed++
+		switch {
+		case err != nil:
+			j.progress.Failed++
+			j.errors = append(j.errors, ImportRowError{Row: i + 1, Key: row.key, Error: err.Error()})
+		case hashes == nil:
+			j.progress.Skipped++
+			hashes, _ = journal.Lookup(j.ID, row.key)
+		default:
+			j.progress.Created++
+		}
+		j.hashes = append(j.hashes, hashes...)
+		j.mu.Unlock()
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.block = ImportBlock(j.ID, j.Spec, j.progress, j.errors, j.hashes)
+	j.err = store.Put(j.block)
+}
+
+// importOne stores a row's blocks and journals them. It returns nil hashes
+// when the row was already journalled.
+func importOne(job string, row importRow, store BlockStore, journal ImportJournal) ([]string, error) {
+	if row.err != nil {
+		return []string{}, row.err
+	}
+	if _, done := journal.Lookup(job, row.key); done {
+		return nil, nil
+	}
+	hashes := make([]string, 0, len(row.blocks))
+	for _, b := range row.blocks {
+		if err := store.Put(b); err != nil {
+			return []string{}, err
+		}
+		hashes = append(hashes, b.Hash)
+	}
+	if err := journal.Record(job, row.key, hashes); err != nil {
+		return []string{}, err
+	}
+	return hashes, nil
+}
+
+// Progress returns the job's counts so far.
+func (j *ImportJob) Progress() ImportProgress {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.progress
+}
+
+// Errors returns the row errors so far.
+func (j *ImportJob) Errors() []ImportRowError {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return append([]ImportRowError(nil), j.errors...)
+}
+
+// Done is closed when the job has finished.
+func (j *ImportJob) Done() <-chan struct{} {
+	return j.done
+}
+
+// Wait blocks until the job has finished and returns its observe.import block.
+func (j *ImportJob) Wait() (Block, error) {
+	<-j.done
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.block, j.err
+}
+
+// ImportBlock records a finished import job. Its instance_id is the job ID,
+// so a resumed job records the same block once every row is in.
+func ImportBlock(id string, spec ImportSpec, progress ImportProgress, rowErrors []ImportRowError, hashes []string) Block {
+	errs := make([]interface{}, len(rowErrors))
+	for i, e := range rowErrors {
+		errs[i] = map[string]interface{}{"row": e.Row, "key": e.Key, "error": e.Error}
+	}
+	state := map[string]interface{}{
+		"instance_id": "import-" + id,
+		"format":      spec.Format,
+		"total":       progress.Total,
+		"processed":   progress.Processed,
+		"failed":      progress.Failed,
+		"errors":      errs,
+	}
+	if spec.Type != "" {
+		state["type"] = spec.Type
+	}
+	created := append([]string(nil), hashes...)
+	sort.Strings(created)
+	refs := map[string]interface{}{"created": refList(created)}
+	if spec.Seller != "" {
+		refs["seller"] = spec.Seller
+	}
+	return Create("observe.import", state, refs)
+}
+
+// parseImport splits the input into rows and builds each row's blocks.
+func parseImport(spec ImportSpec, data []byte, store BlockStore) ([]importRow, error) {
+	switch spec.Format {
+	case "csv":
+		if spec.Type == "" {
+			return nil, errors.New("FoodBlock: CSV import needs a block type")
+		}
+		reader := csv.NewReader(bytes.NewReader(data))
+		reader.FieldsPerRecord = -1
+		records, err := reader.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		if len(records) == 0 {
+			return nil, errors.New("FoodBlock: CSV import has no header")
+		}
+		header := records[0]
+		rows := make([]importRow, 0, len(records)-1)
+		for i, rec := range records[1:] {
+			values := map[string]interface{}{}
+			for c, name := range header {
+				if c < len(rec) && strings.TrimSpace(rec[c]) != "" {
+					values[strings.TrimSpace(name)] = strings.TrimSpace(rec[c])
+				}
+			}
+			rows = append(rows, importRecord(spec, i+1, values))
+		}
+		return rows, nil
+	case "ndjson":
+		if spec.Type == "" {
+			return nil, errors.New("FoodBlock: NDJSON import needs a block type")
+		}
+		var rows []importRow
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for n := 1; scanner.Scan(); {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			var values map[string]interface{}
+			if err := json.Unmarshal(line, &values); err != nil {
+				rows = append(rows, importRow{key: strconv.Itoa(n), err: fmt.Errorf("FoodBlock: invalid JSON: %v", err)})
+			} else {
+				rows = append(rows, importRecord(spec, n, values))
+			}
+			n++
+		}
+		return rows, scanner.Err()
+	case "square":
+		currency := spec.Currency
+		if currency == "" {
+			currency = "USD"
+		}
+		txns, err := ParseSquareCSV(bytes.NewReader(data), currency)
+		if err != nil {
+			return nil, err
+		}
+		products := store.Blocks()
+		rows := make([]importRow, len(txns))
+		for i, txn := range txns {
+			result := ImportPOS([]POSTransaction{txn}, spec.Seller, products)
+			products = append(products, result.Drafts...)
+			rows[i] = importRow{key: txn.ID, blocks: append(result.Drafts, result.Orders...)}
+		}
+		return rows, nil
+	default:
+		return nil, fmt.Errorf("FoodBlock: unknown import format %q", spec.Format)
+	}
+}
+
+// importRecord builds the block for one CSV or NDJSON row. The row's key is
+// its instance_id, so importing the same rows again yields the same hashes.
+func importRecord(spec ImportSpec, n int, values map[string]interface{}) importRow {
+	row := importRow{key: strconv.Itoa(n)}
+	if spec.Key != "" {
+		key, ok := values[spec.Key]
+		if !ok {
+			row.err = fmt.Errorf("FoodBlock: row has no %s", spec.Key)
+			return row
+		}
+		row.key = fmt.Sprint(key)
+	}
+	state := map[string]interface{}{}
+	refs := map[string]interface{}{}
+	for col, v := range values {
+		if role, ok := spec.Refs[col]; ok {
+			refs[role] = v
+			continue
+		}
+		field := col
+		if spec.Fields != nil {
+			if field = spec.Fields[col]; field == "" {
+				continue
+			}
+		}
+		if s, ok := v.(string); ok && containsStr(spec.Numeric, field) {
+			f, err := strconv.ParseFloat(strings.ReplaceAll(s, ",", ""), 64)
+			if err != nil {
+				row.err = fmt.Errorf("FoodBlock: %s is not a number: %q", field, s)
+				return row
+			}
+			v = f
+		}
+		state[field] = v
+	}
+	if _, ok := state["instance_id"]; !ok {
+		state["instance_id"] = spec.ID + ":" + row.key
+	}
+	block := Create(spec.Type, state, refs)
+	if errs := Validate(block, nil); len(errs) > 0 {
+		row.err = fmt.Errorf("FoodBlock: %s", strings.Join(errs, "; "))
+		return row
+	}
+	row.blocks = []Block{block}
+	return row
+}
+
+// MemoryJournal is an in-memory ImportJournal.
+type MemoryJournal struct {
+	mu      sync.RWMutex
+	entries map[string][]string
+}
+
+// NewMemoryJournal returns an empty journal.
+func NewMemoryJournal() *MemoryJournal {
+	return &MemoryJournal{entries: map[string][]string{}}
+}
+
+// Lookup returns the hashes a job stored for a row.
+func (m *MemoryJournal) Lookup(job, key string) ([]string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	hashes, ok := m.entries[job+"\x00"+key]
+	return hashes, ok
+}
+
+// Record journals a stored row.
+func (m *MemoryJournal) Record(job, key string, hashes []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[job+"\x00"+key] = append([]string(nil), hashes...)
+	return nil
+}
+
+// FileJournal is an ImportJournal kept as an append-only NDJSON file, synced
+// after every row, so it survives a crash.
+type FileJournal struct {
+	*MemoryJournal
+	mu   sync.Mutex
+	file *os.File
+}
+
+type journalEntry struct {
+	Job    string   `json:"job"`
+	Key    string   `json:"key"`
+	Hashes []string `json:"hashes"`
+}
+
+// OpenFileJournal opens or creates a journal file and loads its entries. A
+// torn last line from a crash is ignored.
+func OpenFileJournal(path string) (*FileJournal, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	j := &FileJournal{MemoryJournal: NewMemoryJournal(), file: f}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e journalEntry
+		if json.Unmarshal(scanner.Bytes(), &e) == nil {
+			j.MemoryJournal.Record(e.Job, e.Key, e.Hashes)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	// End a torn line so the next entry starts on its own.
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			f.Write([]byte{'\n'})
+		}
+	}
+	return j, nil
+}
+
+// Record appends a stored row to the file before journalling it in memory.
+func (j *FileJournal) Record(job, key string, hashes []string) error {
+	line, err := json.Marshal(journalEntry{job, key, hashes})
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if err := j.file.Sync(); err != nil {
+		return err
+	}
+	return j.MemoryJournal.Record(job, key, hashes)
+}
+
+// Close closes the journal file.
+func (j *FileJournal) Close() error {
+	return j.file.Close()
+}
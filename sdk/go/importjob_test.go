@@ -0,0 +1,132 @@
+package foodblock
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// brokenStore fails to store blocks with one name, like a crash mid-import.
+type brokenStore struct {
+	*MemoryStore
+	name string
+}
+
+func (s brokenStore) Put(b Block) error {
+	if b.State["name"] == s.name {
+		return errors.New("disk full")
+	}
+	return s.MemoryStore.Put(b)
+}
+
+const produceCSV = `sku,name,price,farm
+A1,Carrots,1.20,farm-1
+B2,Leeks,2.5,farm-1
+C3,Kale,lots,farm-2
+D4,Beets,0.9,farm-2
+`
+
+func TestImportJobResumes(t *testing.T) {
+	spec := ImportSpec{Format: "csv", Type: "substance.product", Key: "sku", Numeric: []string{"price"}, Refs: map[string]string{"farm": "seller"}}
+	path := filepath.Join(t.TempDir(), "journal.ndjson")
+	journal, err := OpenFileJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewMemoryStore()
+	job, err := SubmitImport(spec, strings.NewReader(produceCSV), brokenStore{store, "Leeks"}, journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := job.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := job.Progress(); p.Total != 4 || p.Processed != 4 || p.Created != 2 || p.Failed != 2 {
+		t.Fatalf("progress = %+v", p)
+	}
+	errs := job.Errors()
+	if len(errs) != 2 || errs[0].Key != "B2" || errs[1].Row != 3 || !strings.Contains(errs[1].Error, "price") {
+		t.Fatalf("errors = %+v", errs)
+	}
+	if first.Type != "observe.import" || len(refValues(first.Refs["created"])) != 2 {
+		t.Errorf("import block = %+v", first)
+	}
+	carrots := store.Blocks()[0]
+	if carrots.State["price"] != 1.2 || carrots.Refs["seller"] != "farm-1" {
+		t.Errorf("carrots = %+v", carrots)
+	}
+	journal.Close()
+
+	// Reopen the journal as after a restart and run the same import again.
+	journal, err = OpenFileJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer journal.Close()
+	job, err = SubmitImport(spec, strings.NewReader(produceCSV), store, journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := job.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := job.Progress(); p.Skipped != 2 || p.Created != 1 || p.Failed != 1 {
+		t.Fatalf("resumed progress = %+v", p)
+	}
+	if second.State["instance_id"] != first.State["instance_id"] || len(refValues(second.Refs["created"])) != 3 {
+		t.Errorf("resumed import block = %+v", second)
+	}
+	if store.Resolve(second.Hash) == nil {
+		t.Error("import block not stored")
+	}
+}
+
+func refValues(v interface{}) []string {
+	return flattenRefValues(map[string]interface{}{"v": v})
+}
+
+func TestImportNDJSON(t *testing.T) {
+	input := `{"id":"r1","temperature":4.5,"subject":"fridge-1"}
+
+{"id":"r2","temperature":
+{"id":"r3","temperature":5.1,"subject":"fridge-1"}
+`
+	store := NewMemoryStore()
+	spec := ImportSpec{Format: "ndjson", Type: "observe.reading", Key: "id", Refs: map[string]string{"subject": "subject"}}
+	job, err := SubmitImport(spec, strings.NewReader(input), store, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := job.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if p := job.Progress(); p.Total != 3 || p.Created != 2 || p.Failed != 1 {
+		t.Errorf("progress = %+v", p)
+	}
+	readings := FilterBlocks(store.Blocks(), QueryParams{Type: "observe.reading"})
+	if len(readings) != 2 || readings[0].State["instance_id"] != job.ID+":r1" || readings[0].Refs["subject"] != "fridge-1" {
+		t.Errorf("readings = %+v", readings)
+	}
+}
+
+func TestImportSquare(t *testing.T) {
+	store := NewMemoryStore()
+	job, err := SubmitImport(ImportSpec{Format: "square", Seller: "bakery"}, strings.NewReader(squareCSV), store, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done, err := job.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Two orders and two draft products, made once though TX2 repeats a SKU.
+	if p := job.Progress(); p.Total != 2 || p.Created != 2 || len(refValues(done.Refs["created"])) != 4 {
+		t.Errorf("progress = %+v, created = %v", p, done.Refs["created"])
+	}
+	if _, err := SubmitImport(ImportSpec{Format: "xml"}, strings.NewReader(""), store, nil); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,221 @@
+package foodblock
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// IncidentType is a case raised for an excursion, failed audit, complaint or
+// dispute. It moves through the "incident" vocabulary's workflow:
+// open → investigating → corrective_action → closed.
+const IncidentType = "observe.incident"
+
+// IncidentSeverities in ascending order.
+var IncidentSeverities = []string{"low", "medium", "high", "critical"}
+
+// IncidentDeadlines is how long an incident of each severity may stay
+// unresolved before it is overdue.
+var IncidentDeadlines = map[string]time.Duration{
+	"critical": 24 * time.Hour,
+	"high":     3 * 24 * time.Hour,
+	"medium":   7 * 24 * time.Hour,
+	"low":      30 * 24 * time.Hour,
+}
+
+// Incident describes a new incident.
+type Incident struct {
+	Subject  string // block the incident is about (lot, shipment, audit, dispute, ...)
+	Category string // excursion, audit, complaint, contamination, dispute, ...
+	Severity string // one of IncidentSeverities
+	Title    string
+	Reporter string
+	Assignee string   // optional until investigation starts
+	Evidence []string // readings, certifications, photos, ...
+}
+
+// OpenIncident creates an observe.incident in the open state, due after the
+// severity's IncidentDeadlines window.
+func OpenIncident(in Incident, now time.Time) (Block, error) {
+	if in.Subject == "" || in.Reporter == "" {
+		return Block{}, errors.New("FoodBlock: incident needs a subject and a reporter")
+	}
+	due, ok := IncidentDeadlines[in.Severity]
+	if !ok {
+		return Block{}, fmt.Errorf("FoodBlock: unknown incident severity: %s", in.Severity)
+	}
+	state := map[string]interface{}{
+		"instance_id": fmt.Sprintf("incident-%s-%d", in.Subject, now.Unix()),
+		"status":      "open",
+		"severity":    in.Severity,
+		"opened_at":   now.UTC().Format(time.RFC3339),
+		"due_at":      now.Add(due).UTC().Format(time.RFC3339),
+	}
+	if in.Title != "" {
+		state["title"] = in.Title
+	}
+	if in.Category != "" {
+		state["category"] = in.Category
+	}
+	refs := map[string]interface{}{"subject": in.Subject, "reporter": in.Reporter}
+	if in.Assignee != "" {
+		refs["assignee"] = in.Assignee
+	}
+	if len(in.Evidence) > 0 {
+		refs["evidence"] = refList(in.Evidence)
+	}
+	return Create(IncidentType, state, refs), nil
+}
+
+// AdvanceIncident moves an incident to a new status, applying changes (for
+// example root_cause or corrective_action) and extra refs in the same update
+// so they count towards the transition's guards.
+func AdvanceIncident(incident Block, to string, changes, refs map[string]interface{}, graph []Block, now time.Time) (Block, error) {
+	if incident.Type != IncidentType {
+		return Block{}, fmt.Errorf("FoodBlock: %s is not an incident", incident.Hash)
+	}
+	candidate := incidentCandidate(incident, changes, refs)
+	check := CheckTransition(Vocabularies["incident"], candidate, to, graph, nil)
+	if !check.Allowed {
+		return Block{}, errors.New("FoodBlock: " + check.Reason)
+	}
+	state := map[string]interface{}{}
+	for k, v := range changes {
+		state[k] = v
+	}
+	state["status"] = to
+	state["previous_status"] = check.From
+	if to == "closed" {
+		state["closed_at"] = now.UTC().Format(time.RFC3339)
+	}
+	return MergeUpdate(incident, state, candidate.Refs), nil
+}
+
+// AssignIncident updates the incident's assignee.
+func AssignIncident(incident Block, assignee string) Block {
+	return MergeUpdate(incident, nil, incidentCandidate(incident, nil, map[string]interface{}{"assignee": assignee}).Refs)
+}
+
+// AttachEvidence links further evidence blocks to an incident.
+func AttachEvidence(incident Block, evidence ...string) Block {
+	return MergeUpdate(incident, nil, incidentCandidate(incident, nil, map[string]interface{}{"evidence": refList(evidence)}).Refs)
+}
+
+// EscalateIncident raises an open incident's severity one level and brings
+// its due date forward to the new severity's deadline from now, if sooner.
+func EscalateIncident(incident Block, reason string, now time.Time) (Block, error) {
+	status, _ := incident.State["status"].(string)
+	if status == "closed" {
+		return Block{}, errors.New("FoodBlock: cannot escalate a closed incident")
+	}
+	severity, _ := incident.State["severity"].(string)
+	level := -1
+	for i, s := range IncidentSeverities {
+		if s == severity {
+			level = i
+		}
+	}
+	if level == len(IncidentSeverities)-1 {
+		return Block{}, errors.New("FoodBlock: incident is already critical")
+	}
+	next := IncidentSeverities[level+1]
+	changes := map[string]interface{}{
+		"severity":          next,
+		"previous_severity": severity,
+		"escalated_at":      now.UTC().Format(time.RFC3339),
+	}
+	if reason != "" {
+		changes["escalation_reason"] = reason
+	}
+	due := now.Add(IncidentDeadlines[next])
+	if current, ok := incidentDue(incident); !ok || due.Before(current) {
+		changes["due_at"] = due.UTC().Format(time.RFC3339)
+	}
+	return MergeUpdate(incident, changes, incidentCandidate(incident, nil, nil).Refs), nil
+}
+
+// incidentCandidate returns the incident with changes and refs applied.
+// Refs carried by an incident (subject, reporter, assignee) are kept, and
+// evidence lists accumulate rather than replace.
+func incidentCandidate(incident Block, changes, refs map[string]interface{}) Block {
+	c := Block{Hash: incident.Hash, Type: incident.Type, State: map[string]interface{}{}, Refs: map[string]interface{}{}}
+	for k, v := range incident.State {
+		c.State[k] = v
+	}
+	for k, v := range changes {
+		c.State[k] = v
+	}
+	for k, v := range incident.Refs {
+		if k != "updates" {
+			c.Refs[k] = v
+		}
+	}
+	for k, v := range refs {
+		if k == "evidence" {
+			existing := flattenRefValues(map[string]interface{}{"e": c.Refs["evidence"]})
+			for _, h := range flattenRefValues(map[string]interface{}{"e": v}) {
+				if !containsStr(existing, h) {
+					existing = append(existing, h)
+				}
+			}
+			v = refList(existing)
+		}
+		c.Refs[k] = v
+	}
+	return c
+}
+
+func refList(hashes []string) []interface{} {
+	out := make([]interface{}, len(hashes))
+	for i, h := range hashes {
+		out[i] = h
+	}
+	return out
+}
+
+func incidentDue(b Block) (time.Time, bool) {
+	s, _ := b.State["due_at"].(string)
+	t, err := time.Parse(time.RFC3339, s)
+	return t, err == nil
+}
+
+// OverdueIncident is an unresolved incident past its due date.
+type OverdueIncident struct {
+	Incident Block         `json:"incident"`
+	Status   string        `json:"status"`
+	Severity string        `json:"severity"`
+	Assignee string        `json:"assignee,omitempty"`
+	Due      time.Time     `json:"due"`
+	Overdue  time.Duration `json:"overdue"`
+}
+
+// OverdueIncidents returns the latest version of every incident that is not
+// closed and is past its due date, most severe first, then most overdue.
+func OverdueIncidents(blocks []Block, now time.Time) []OverdueIncident {
+	rank := map[string]int{}
+	for i, s := range IncidentSeverities {
+		rank[s] = i
+	}
+	var out []OverdueIncident
+	for _, b := range FilterBlocks(blocks, QueryParams{Type: IncidentType, HeadsOnly: true}) {
+		status, _ := b.State["status"].(string)
+		due, ok := incidentDue(b)
+		if status == "closed" || !ok || !now.After(due) {
+			continue
+		}
+		severity, _ := b.State["severity"].(string)
+		assignee, _ := b.Refs["assignee"].(string)
+		out = append(out, OverdueIncident{Incident: b, Status: status, Severity: severity, Assignee: assignee, Due: due, Overdue: now.Sub(due)})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if rank[out[i].Severity] != rank[out[j].Severity] {
+			return rank[out[i].Severity] > rank[out[j].Severity]
+		}
+		if out[i].Overdue != out[j].Overdue {
+			return out[i].Overdue > out[j].Overdue
+		}
+		return out[i].Incident.Hash < out[j].Incident.Hash
+	})
+	return out
+}
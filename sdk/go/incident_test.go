@@ -0,0 +1,73 @@
+package foodblock
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIncidentWorkflow(t *testing.T) {
+	now := time.Date(2026, 4, 1, 9, 0, 0, 0, time.UTC)
+	reading := Create("observe.reading", map[string]interface{}{"temperature": 11.0}, map[string]interface{}{"subject": "cold-room"})
+	inc, err := OpenIncident(Incident{Subject: "lot-7", Category: "excursion", Severity: "high", Reporter: "qa", Evidence: []string{reading.Hash}}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inc.State["due_at"] != "2026-04-04T09:00:00Z" {
+		t.Errorf("expected a 3 day deadline, got %v", inc.State["due_at"])
+	}
+
+	if _, err := AdvanceIncident(inc, "investigating", nil, nil, nil, now); err == nil || !strings.Contains(err.Error(), "assignee") {
+		t.Fatalf("expected the assignee guard to fail, got %v", err)
+	}
+	if _, err := AdvanceIncident(inc, "closed", nil, nil, nil, now); err == nil {
+		t.Fatal("expected open->closed to be rejected")
+	}
+	inv, err := AdvanceIncident(inc, "investigating", nil, map[string]interface{}{"assignee": "tech"}, nil, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AdvanceIncident(inv, "corrective_action", nil, nil, nil, now); err == nil {
+		t.Fatal("expected the root_cause guard to fail")
+	}
+	ca, err := AdvanceIncident(inv, "corrective_action", map[string]interface{}{"root_cause": "door seal worn"}, nil, nil, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	photo := Create("observe.photo", map[string]interface{}{"name": "new seal"}, nil)
+	ca = AttachEvidence(ca, photo.Hash)
+	if ev := flattenRefValues(map[string]interface{}{"e": ca.Refs["evidence"]}); len(ev) != 2 || ca.Refs["assignee"] != "tech" {
+		t.Errorf("expected evidence to accumulate and refs to carry over, got %v", ca.Refs)
+	}
+	closed, err := AdvanceIncident(ca, "closed", map[string]interface{}{"corrective_action": "replaced door seal"}, nil, nil, now.Add(48*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if closed.State["status"] != "closed" || closed.State["previous_status"] != "corrective_action" || closed.Refs["subject"] != "lot-7" {
+		t.Errorf("unexpected closed incident %v %v", closed.State, closed.Refs)
+	}
+}
+
+func TestOverdueIncidents(t *testing.T) {
+	now := time.Date(2026, 4, 1, 9, 0, 0, 0, time.UTC)
+	low, _ := OpenIncident(Incident{Subject: "audit-1", Severity: "low", Reporter: "qa"}, now.Add(-40*24*time.Hour))
+	medium, _ := OpenIncident(Incident{Subject: "lot-2", Severity: "medium", Reporter: "qa"}, now.Add(-2*24*time.Hour))
+	escalated, err := EscalateIncident(medium, "customer complaint", now.Add(-30*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if escalated.State["severity"] != "high" {
+		t.Fatalf("expected escalation to high, got %v", escalated.State["severity"])
+	}
+	done, _ := OpenIncident(Incident{Subject: "lot-3", Severity: "critical", Reporter: "qa", Assignee: "tech"}, now.Add(-72*time.Hour))
+	done = MergeUpdate(done, map[string]interface{}{"status": "closed"}, map[string]interface{}{"subject": "lot-3"})
+
+	overdue := OverdueIncidents([]Block{low, medium, escalated, done}, now)
+	if len(overdue) != 1 || overdue[0].Incident.Hash != low.Hash {
+		t.Fatalf("expected only the low incident overdue, got %+v", overdue)
+	}
+	overdue = OverdueIncidents([]Block{low, medium, escalated, done}, now.Add(48*time.Hour))
+	if len(overdue) != 2 || overdue[0].Incident.Hash != escalated.Hash || overdue[0].Overdue != 6*time.Hour {
+		t.Errorf("expected the escalated incident first, got %+v", overdue)
+	}
+}
@@ -0,0 +1,90 @@
+package foodblock
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sync"
+)
+
+// InstanceIDStrategy supplies the instance_id Create injects into an event
+// block that has none. It sees the type, state and refs the caller passed.
+type InstanceIDStrategy func(typ string, state, refs map[string]interface{}) string
+
+// RandomInstanceIDs gives every event a random UUID. It is the default.
+func RandomInstanceIDs(typ string, state, refs map[string]interface{}) string {
+	return generateUUID()
+}
+
+// ContentInstanceIDs derives the UUID from the event's content, so creating
+// the same event twice yields the same block.
+func ContentInstanceIDs(typ string, state, refs map[string]interface{}) string {
+	sum := sha256.Sum256([]byte(Hash(typ, omitNulls(state), omitNulls(refs))))
+	var buf [16]byte
+	copy(buf[:], sum[:16])
+	return formatUUID(buf, 8)
+}
+
+// RequireInstanceIDs is for callers that assign every instance_id
+// themselves: creating an event block without one panics.
+func RequireInstanceIDs(typ string, state, refs map[string]interface{}) string {
+	panic(fmt.Sprintf("FoodBlock: %s requires a caller-supplied instance_id", typ))
+}
+
+// SequenceInstanceIDs numbers events prefix-1, prefix-2, ... in creation
+// order. It is safe for concurrent use.
+func SequenceInstanceIDs(prefix string) InstanceIDStrategy {
+	var mu sync.Mutex
+	n := 0
+	return func(typ string, state, refs map[string]interface{}) string {
+		mu.Lock()
+		defer mu.Unlock()
+		n++
+		return fmt.Sprintf("%s-%d", prefix, n)
+	}
+}
+
+// DeterministicInstanceIDs is the test mode: UUIDs drawn from seed in
+// creation order, the same on every run, so golden files of event blocks
+// stay stable.
+func DeterministicInstanceIDs(seed string) InstanceIDStrategy {
+	var mu sync.Mutex
+	n := 0
+	return func(typ string, state, refs map[string]interface{}) string {
+		mu.Lock()
+		defer mu.Unlock()
+		n++
+		sum := sha256.Sum256([]byte(fmt.Sprintf("%s/%d", seed, n)))
+		var buf [16]byte
+		copy(buf[:], sum[:16])
+		return formatUUID(buf, 4)
+	}
+}
+
+var instanceIDs struct {
+	sync.RWMutex
+	strategy InstanceIDStrategy
+}
+
+// SetInstanceIDStrategy sets the strategy Create uses and returns the
+// previous one; nil restores RandomInstanceIDs. In tests:
+//
+//	defer SetInstanceIDStrategy(SetInstanceIDStrategy(DeterministicInstanceIDs("golden")))
+func SetInstanceIDStrategy(s InstanceIDStrategy) InstanceIDStrategy {
+	instanceIDs.Lock()
+	defer instanceIDs.Unlock()
+	prev := instanceIDs.strategy
+	if prev == nil {
+		prev = RandomInstanceIDs
+	}
+	instanceIDs.strategy = s
+	return prev
+}
+
+func currentInstanceIDStrategy() InstanceIDStrategy {
+	instanceIDs.RLock()
+	defer instanceIDs.RUnlock()
+	if instanceIDs.strategy == nil {
+		return RandomInstanceIDs
+	}
+	return instanceIDs.strategy
+}
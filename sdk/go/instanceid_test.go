@@ -0,0 +1,53 @@
+package foodblock
+
+import (
+	"regexp"
+	"testing"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[48][0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestInstanceIDStrategies(t *testing.T) {
+	state := map[string]interface{}{"temperature": 4.1}
+	refs := map[string]interface{}{"subject": "abc"}
+
+	a := Create("observe.reading", state, refs)
+	b := Create("observe.reading", state, refs)
+	if a.Hash == b.Hash || !uuidPattern.MatchString(a.State["instance_id"].(string)) {
+		t.Errorf("random ids: %v, %v", a.State, b.State)
+	}
+
+	a = CreateWithInstanceIDs(ContentInstanceIDs, "observe.reading", state, refs)
+	b = CreateWithInstanceIDs(ContentInstanceIDs, "observe.reading", state, refs)
+	if a.Hash != b.Hash || !uuidPattern.MatchString(a.State["instance_id"].(string)) {
+		t.Errorf("content ids differ: %v, %v", a.State, b.State)
+	}
+
+	seq := SequenceInstanceIDs("pos")
+	CreateWithInstanceIDs(seq, "transfer.order", nil, nil)
+	if got := CreateWithInstanceIDs(seq, "transfer.order", nil, nil).State["instance_id"]; got != "pos-2" {
+		t.Errorf("sequence id = %v", got)
+	}
+
+	// The test mode repeats across runs, and definitional types are left alone.
+	run := func() []string {
+		defer SetInstanceIDStrategy(SetInstanceIDStrategy(DeterministicInstanceIDs("golden")))
+		return []string{Create("observe.reading", state, refs).Hash, Create("transfer.order", nil, nil).Hash, Create("actor.producer", nil, nil).Hash}
+	}
+	first, second := run(), run()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Errorf("block %d differs between runs", i)
+		}
+	}
+	if Create("observe.reading", state, refs).Hash == first[0] {
+		t.Error("default strategy not restored")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic without an instance_id")
+		}
+	}()
+	CreateWithInstanceIDs(RequireInstanceIDs, "transfer.order", nil, nil)
+}
@@ -0,0 +1,165 @@
+package foodblock
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// IntegrityMode is how a store treats refs to blocks it does not hold.
+type IntegrityMode string
+
+const (
+	// IntegrityOff accepts any refs without tracking them (the default).
+	IntegrityOff IntegrityMode = ""
+	// IntegrityLazy accepts blocks with missing refs and tracks those refs as
+	// pending until the blocks they name arrive.
+	IntegrityLazy IntegrityMode = "lazy"
+	// IntegrityStrict rejects a block unless every ref resolves locally or
+	// on a trusted peer; refs held only by a peer are tracked as pending.
+	IntegrityStrict IntegrityMode = "strict"
+)
+
+// RefResolver reports whether a trusted peer holds a block.
+type RefResolver func(hash string) bool
+
+// PendingRef is a ref to a block the store does not hold yet.
+type PendingRef struct {
+	Ref    string    `json:"ref"`
+	From   []string  `json:"from"` // blocks carrying the ref
+	Since  time.Time `json:"since"`
+	Remote bool      `json:"remote"` // a trusted peer held it when last checked
+}
+
+// Resolver adapts a federation peer into a RefResolver, fetching blocks with
+// ctx.
+func (c *FederationClient) Resolver(ctx context.Context) RefResolver {
+	return func(hash string) bool {
+		b, err := c.Block(ctx, hash)
+		return err == nil && b != nil && b.Hash == hash
+	}
+}
+
+// SetIntegrity sets the reference integrity mode Put and PutSigned enforce
+// and the trusted peers strict mode consults. Stubs and blocks already stored
+// are not checked.
+func (s *MemoryStore) SetIntegrity(mode IntegrityMode, peers ...RefResolver) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.integrity = mode
+	s.peers = peers
+}
+
+// missingRefs lists the distinct refs of a block the store does not hold.
+// Callers hold the lock.
+func (s *MemoryStore) missingRefs(block Block) []string {
+	var out []string
+	for _, h := range uniqueRefValues(block.Refs) {
+		if _, ok := s.blocks[h]; !ok && h != block.Hash {
+			out = append(out, h)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// checkRefs rejects a block in strict mode when a ref resolves neither
+// locally nor on a trusted peer. Peers are asked without holding the lock.
+func (s *MemoryStore) checkRefs(block Block) error {
+	s.mu.RLock()
+	mode, peers := s.integrity, s.peers
+	var missing []string
+	if mode == IntegrityStrict {
+		if _, exists := s.blocks[block.Hash]; !exists {
+			missing = s.missingRefs(block)
+		}
+	}
+	s.mu.RUnlock()
+	for _, h := range missing {
+		if !anyPeerHas(peers, h) {
+			return fmt.Errorf("FoodBlock: ref %s does not resolve", h)
+		}
+	}
+	return nil
+}
+
+func anyPeerHas(peers []RefResolver, hash string) bool {
+	for _, has := range peers {
+		if has(hash) {
+			return true
+		}
+	}
+	return false
+}
+
+// trackRefs records a block's missing refs as pending. Callers hold the lock.
+func (s *MemoryStore) trackRefs(block Block) {
+	if s.integrity == IntegrityOff {
+		return
+	}
+	for _, h := range s.missingRefs(block) {
+		p := s.pending[h]
+		if p == nil {
+			p = &PendingRef{Ref: h, Since: time.Now(), Remote: s.integrity == IntegrityStrict}
+			s.pending[h] = p
+		}
+		p.From = appendUnique(p.From, block.Hash)
+	}
+}
+
+// PendingRefs returns the refs still waiting for their blocks, by ref. A ref
+// stops being pending when its block is stored.
+func (s *MemoryStore) PendingRefs() []PendingRef {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]PendingRef, 0, len(s.pending))
+	for _, p := range s.pending {
+		c := *p
+		c.From = append([]string(nil), p.From...)
+		sort.Strings(c.From)
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Ref < out[j].Ref })
+	return out
+}
+
+// DanglingRefs re-checks refs pending for longer than grace against the
+// trusted peers and returns those no peer holds: refs that will not resolve
+// by waiting. Refs a peer holds are marked Remote and left pending.
+func (s *MemoryStore) DanglingRefs(grace time.Duration) []PendingRef {
+	cutoff := time.Now().Add(-grace)
+	s.mu.RLock()
+	peers := s.peers
+	var due []string
+	for h, p := range s.pending {
+		if !p.Since.After(cutoff) {
+			due = append(due, h)
+		}
+	}
+	s.mu.RUnlock()
+
+	remote := map[string]bool{}
+	for _, h := range due {
+		remote[h] = anyPeerHas(peers, h)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []PendingRef
+	for _, h := range due {
+		p := s.pending[h]
+		if p == nil {
+			continue // arrived while peers were asked
+		}
+		p.Remote = remote[h]
+		if !p.Remote {
+			c := *p
+			c.From = append([]string(nil), p.From...)
+			sort.Strings(c.From)
+			out = append(out, c)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Ref < out[j].Ref })
+	return out
+}
@@ -0,0 +1,93 @@
+package foodblock
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestIntegrityStrict(t *testing.T) {
+	farm := Create("actor.producer", map[string]interface{}{"name": "Farm"}, nil)
+	wheat := Create("substance.product", map[string]interface{}{"name": "Wheat"}, map[string]interface{}{"seller": farm.Hash})
+
+	store := NewMemoryStore()
+	store.SetIntegrity(IntegrityStrict)
+	if err := store.Put(wheat); err == nil {
+		t.Fatal("strict store accepted a dangling ref")
+	}
+	store.Put(farm)
+	if err := store.Put(wheat); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.PendingRefs()) != 0 {
+		t.Errorf("pending = %+v", store.PendingRefs())
+	}
+
+	// A ref a trusted peer holds is accepted and tracked until it arrives.
+	peer := NewMemoryStore()
+	mill := Create("actor.processor", map[string]interface{}{"name": "Mill"}, nil)
+	peer.Put(mill)
+	flour := Create("substance.product", map[string]interface{}{"name": "Flour"}, map[string]interface{}{"seller": mill.Hash, "source": wheat.Hash})
+	local := NewMemoryStore()
+	local.Put(wheat)
+	local.SetIntegrity(IntegrityStrict, func(h string) bool { return peer.Resolve(h) != nil })
+	if err := local.PutSigned(SignedBlock{FoodBlock: flour}); err != nil {
+		t.Fatal(err)
+	}
+	pending := local.PendingRefs()
+	if len(pending) != 1 || pending[0].Ref != mill.Hash || !pending[0].Remote || pending[0].From[0] != flour.Hash {
+		t.Fatalf("pending = %+v", pending)
+	}
+	local.Put(mill)
+	if len(local.PendingRefs()) != 0 {
+		t.Error("ref still pending after its block arrived")
+	}
+}
+
+func TestIntegrityLazy(t *testing.T) {
+	store := NewMemoryStore()
+	peer := NewMemoryStore()
+	store.SetIntegrity(IntegrityLazy, func(h string) bool { return peer.Resolve(h) != nil })
+
+	a := Create("actor.producer", map[string]interface{}{"name": "A"}, nil)
+	b := Create("actor.producer", map[string]interface{}{"name": "B"}, nil)
+	order := Create("transfer.order", map[string]interface{}{"instance_id": "o1"}, map[string]interface{}{"buyer": a.Hash, "seller": b.Hash})
+	if err := store.Put(order); err != nil {
+		t.Fatal(err)
+	}
+	if p := store.PendingRefs(); len(p) != 2 || p[0].Remote {
+		t.Fatalf("pending = %+v", p)
+	}
+
+	store.Put(a)
+	peer.Put(b)
+	dangling := store.DanglingRefs(0)
+	if len(dangling) != 0 {
+		t.Errorf("dangling = %+v", dangling)
+	}
+	if p := store.PendingRefs(); len(p) != 1 || p[0].Ref != b.Hash || !p[0].Remote {
+		t.Errorf("pending = %+v", p)
+	}
+
+	peer.Delete(b.Hash)
+	if d := store.DanglingRefs(0); len(d) != 1 || d[0].Ref != b.Hash || d[0].From[0] != order.Hash {
+		t.Errorf("dangling = %+v", d)
+	}
+	if d := store.DanglingRefs(time.Hour); len(d) != 0 {
+		t.Errorf("refs within the grace period reported dangling: %+v", d)
+	}
+}
+
+func TestFederationResolver(t *testing.T) {
+	peer := NewMemoryStore()
+	farm := Create("actor.producer", map[string]interface{}{"name": "Farm"}, nil)
+	peer.Put(farm)
+	srv := httptest.NewServer(FederationHandler(peer, WellKnownInfo{Name: "peer"}))
+	defer srv.Close()
+
+	has := NewFederationClient(srv.URL).Resolver(context.Background())
+	if !has(farm.Hash) || has("missing") {
+		t.Error("resolver does not reflect the peer")
+	}
+}
@@ -0,0 +1,77 @@
+package foodblock
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"sync"
+)
+
+// KeyRegistry maps actor hashes to their Ed25519 public keys.
+// It is safe for concurrent use.
+type KeyRegistry struct {
+	mu   sync.RWMutex
+	keys map[string][]byte
+}
+
+// NewKeyRegistry creates an empty key registry.
+func NewKeyRegistry() *KeyRegistry {
+	return &KeyRegistry{keys: make(map[string][]byte)}
+}
+
+// Register binds a public key to an actor hash, replacing any previous key.
+func (k *KeyRegistry) Register(authorHash string, publicKey []byte) error {
+	if authorHash == "" {
+		return errors.New("FoodBlock: authorHash is required")
+	}
+	if len(publicKey) != ed25519.PublicKeySize {
+		return errors.New("FoodBlock: public key must be 32 bytes")
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys[authorHash] = append([]byte(nil), publicKey...)
+	return nil
+}
+
+// RegisterHex binds a hex-encoded public key to an actor hash.
+func (k *KeyRegistry) RegisterHex(authorHash, publicKeyHex string) error {
+	pub, err := hex.DecodeString(publicKeyHex)
+	if err != nil {
+		return errors.New("FoodBlock: invalid public key hex")
+	}
+	return k.Register(authorHash, pub)
+}
+
+// PublicKey returns the public key registered for an actor hash.
+func (k *KeyRegistry) PublicKey(authorHash string) ([]byte, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	pub, ok := k.keys[authorHash]
+	return pub, ok
+}
+
+// Remove unregisters an actor's key.
+func (k *KeyRegistry) Remove(authorHash string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	delete(k.keys, authorHash)
+}
+
+// VerifySigned verifies a signed block against the author's registered key.
+func (k *KeyRegistry) VerifySigned(signed SignedBlock) bool {
+	pub, ok := k.PublicKey(signed.AuthorHash)
+	if !ok {
+		return false
+	}
+	return Verify(signed, pub)
+}
+
+// VerifyWrite verifies a write signed with SignWrite against the author's
+// registered key.
+func (k *KeyRegistry) VerifyWrite(signed SignedBlock) bool {
+	pub, ok := k.PublicKey(signed.AuthorHash)
+	if !ok {
+		return false
+	}
+	return VerifyWrite(signed, pub)
+}
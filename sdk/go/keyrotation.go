@@ -0,0 +1,193 @@
+package foodblock
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// KeyRotationType records an actor moving to a new signing key. It is
+// signed by the old key, which proves the actor made the change.
+const KeyRotationType = "observe.key_rotation"
+
+// KeyRotation is the result of RotateKeys.
+type KeyRotation struct {
+	Signed     SignedBlock // the rotation block, signed by the old key
+	PublicKey  []byte
+	PrivateKey []byte
+}
+
+// RotateKeys generates a new keypair for an actor and the rotation block
+// announcing it. Publish the signed rotation, update the actor block with
+// the new key, and sign everything from now on with it.
+func RotateKeys(actorHash string, oldPrivateKey []byte, reason string) (KeyRotation, error) {
+	if actorHash == "" {
+		return KeyRotation{}, errors.New("FoodBlock: actorHash is required")
+	}
+	if len(oldPrivateKey) != ed25519.PrivateKeySize {
+		return KeyRotation{}, errors.New("FoodBlock: private key must be 64 bytes")
+	}
+	if reason == "" {
+		reason = "scheduled"
+	}
+	pub, priv := GenerateKeypair()
+	old := ed25519.PrivateKey(oldPrivateKey).Public().(ed25519.PublicKey)
+	block := Create(KeyRotationType, map[string]interface{}{
+		"old_public_key": hex.EncodeToString(old),
+		"new_public_key": hex.EncodeToString(pub),
+		"reason":         reason,
+		"rotated_at":     time.Now().UTC().Format(time.RFC3339),
+	}, map[string]interface{}{"actor": actorHash})
+	return KeyRotation{Signed: Sign(block, actorHash, oldPrivateKey), PublicKey: pub, PrivateKey: priv}, nil
+}
+
+// KeyChain follows an actor's rotations from its first key and returns its
+// keys oldest first. Each rotation must be signed by the key before it;
+// rotations that are not, such as forgeries naming the current key, are
+// skipped. Rotations may be given in any order.
+func KeyChain(actorHash string, firstKey []byte, rotations []SignedBlock) ([][]byte, error) {
+	keys := [][]byte{firstKey}
+	used := map[string]bool{}
+	for {
+		current := keys[len(keys)-1]
+		next := -1
+		for i, r := range rotations {
+			b := r.FoodBlock
+			if used[b.Hash] || b.Type != KeyRotationType || b.Refs["actor"] != actorHash || b.State["old_public_key"] != hex.EncodeToString(current) {
+				continue
+			}
+			if r.AuthorHash != actorHash || !Verify(r, current) {
+				continue
+			}
+			next = i
+			break
+		}
+		if next < 0 {
+			return keys, nil
+		}
+		b := rotations[next].FoodBlock
+		used[b.Hash] = true
+		newKey, err := hex.DecodeString(fmt.Sprint(b.State["new_public_key"]))
+		if err != nil || len(newKey) != ed25519.PublicKeySize {
+			return keys, fmt.Errorf("FoodBlock: rotation %s has an invalid new_public_key", b.Hash)
+		}
+		keys = append(keys, newKey)
+	}
+}
+
+// ReattestOptions control a Reattest run.
+type ReattestOptions struct {
+	BatchSize int                       // attestations per batch, default 100
+	After     string                    // resume after this block hash, the Cursor of an earlier run
+	OnBatch   func(ReattestBatch) error // stores or publishes a batch; an error stops the run
+}
+
+// ReattestBatch is one batch of signed attestations.
+type ReattestBatch struct {
+	Attestations []SignedBlock
+	Cursor       string // the last block hash the batch covers
+}
+
+// ReattestReport summarizes a Reattest run.
+type ReattestReport struct {
+	Attested int      `json:"attested"`
+	Invalid  []string `json:"invalid,omitempty"` // blocks not signed by the old key, left unattested
+	Cursor   string   `json:"cursor,omitempty"`  // pass as After to resume
+	Done     bool     `json:"done"`
+}
+
+// Reattest confirms an actor's authorship of blocks signed with the key a
+// rotation retired. Each of the actor's blocks whose signature verifies
+// under the old key gets an observe.attestation signed with the new key,
+// naming the rotation in refs.rotation. Blocks are taken in hash order and
+// handed to OnBatch in batches; a run that stops can be resumed from its
+// Cursor, and rerunning yields the same attestations, so they are safe to
+// store twice.
+func Reattest(rotation SignedBlock, blocks []SignedBlock, newPrivateKey []byte, opts ReattestOptions) (ReattestReport, error) {
+	r := rotation.FoodBlock
+	actor, _ := r.Refs["actor"].(string)
+	if r.Type != KeyRotationType || actor == "" {
+		return ReattestReport{}, errors.New("FoodBlock: not a key rotation block")
+	}
+	oldKey, err := hex.DecodeString(fmt.Sprint(r.State["old_public_key"]))
+	if err != nil || len(oldKey) != ed25519.PublicKeySize || !Verify(rotation, oldKey) {
+		return ReattestReport{}, errors.New("FoodBlock: rotation is not signed by its old key")
+	}
+	if len(newPrivateKey) != ed25519.PrivateKeySize {
+		return ReattestReport{}, errors.New("FoodBlock: private key must be 64 bytes")
+	}
+	newKey := ed25519.PrivateKey(newPrivateKey).Public().(ed25519.PublicKey)
+	if r.State["new_public_key"] != hex.EncodeToString(newKey) {
+		return ReattestReport{}, errors.New("FoodBlock: private key does not match the rotation's new key")
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+
+	sorted := append([]SignedBlock(nil), blocks...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].FoodBlock.Hash < sorted[j].FoodBlock.Hash })
+	report := ReattestReport{Cursor: opts.After}
+	var batch ReattestBatch
+	flush := func() error {
+		if len(batch.Attestations) == 0 {
+			return nil
+		}
+		if opts.OnBatch != nil {
+			if err := opts.OnBatch(batch); err != nil {
+				return err
+			}
+		}
+		report.Attested += len(batch.Attestations)
+		report.Cursor = batch.Cursor
+		batch = ReattestBatch{}
+		return nil
+	}
+	for _, s := range sorted {
+		b := s.FoodBlock
+		if b.Hash <= opts.After || s.AuthorHash != actor || b.Hash == r.Hash {
+			continue
+		}
+		if !Verify(s, oldKey) {
+			report.Invalid = append(report.Invalid, b.Hash)
+			continue
+		}
+		att := CreateWithInstanceIDs(ContentInstanceIDs, "observe.attestation", map[string]interface{}{
+			"confidence":         "verified",
+			"method":             "key_rotation",
+			"original_signature": s.Signature,
+		}, map[string]interface{}{"confirms": b.Hash, "attestor": actor, "rotation": r.Hash})
+		batch.Attestations = append(batch.Attestations, Sign(att, actor, newPrivateKey))
+		batch.Cursor = b.Hash
+		if len(batch.Attestations) >= opts.BatchSize {
+			if err := flush(); err != nil {
+				return report, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return report, err
+	}
+	report.Done = true
+	return report, nil
+}
+
+// ReattestedBy reports whether a block signed with a retired key is
+// confirmed by an attestation under the rotation's new key.
+func ReattestedBy(hash string, rotation SignedBlock, attestations []SignedBlock) bool {
+	newKey, err := hex.DecodeString(fmt.Sprint(rotation.FoodBlock.State["new_public_key"]))
+	if err != nil {
+		return false
+	}
+	actor := rotation.FoodBlock.Refs["actor"]
+	for _, a := range attestations {
+		b := a.FoodBlock
+		if b.Type == "observe.attestation" && b.Refs["confirms"] == hash && b.Refs["rotation"] == rotation.FoodBlock.Hash &&
+			a.AuthorHash == actor && Verify(a, newKey) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package foodblock
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestRotateKeysAndKeyChain(t *testing.T) {
+	pub1, priv1 := GenerateKeypair()
+	venue := Create("actor.venue", map[string]interface{}{"name": "Corner Cafe"}, nil)
+	r1, err := RotateKeys(venue.Hash, priv1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r2, _ := RotateKeys(venue.Hash, r1.PrivateKey, "device_compromise")
+
+	keys, err := KeyChain(venue.Hash, pub1, []SignedBlock{r2.Signed, r1.Signed})
+	if err != nil || len(keys) != 3 || string(keys[2]) != string(r2.PublicKey) {
+		t.Fatalf("keys = %d, err %v", len(keys), err)
+	}
+
+	// A forged rotation from the current key is skipped, not fatal.
+	strangerPub, stranger := GenerateKeypair()
+	forgedBlock := Create(KeyRotationType, map[string]interface{}{
+		"old_public_key": hex.EncodeToString(r1.PublicKey),
+		"new_public_key": hex.EncodeToString(strangerPub),
+	}, map[string]interface{}{"actor": venue.Hash})
+	forged := Sign(forgedBlock, venue.Hash, stranger)
+	keys, err = KeyChain(venue.Hash, pub1, []SignedBlock{r1.Signed, forged, r2.Signed})
+	if err != nil || len(keys) != 3 || string(keys[2]) != string(r2.PublicKey) {
+		t.Fatalf("with a forged rotation: keys = %d, err %v", len(keys), err)
+	}
+	keys, err = KeyChain(venue.Hash, pub1, []SignedBlock{r1.Signed, forged})
+	if err != nil || len(keys) != 2 {
+		t.Errorf("expected the chain to stop at r1, keys = %d, err %v", len(keys), err)
+	}
+}
+
+func TestReattest(t *testing.T) {
+	_, oldPriv := GenerateKeypair()
+	venue := Create("actor.venue", map[string]interface{}{"name": "Corner Cafe"}, nil)
+	var history []SignedBlock
+	for i := 0; i < 7; i++ {
+		b := Create("substance.product", map[string]interface{}{"name": fmt.Sprintf("Dish %d", i)}, map[string]interface{}{"seller": venue.Hash})
+		history = append(history, Sign(b, venue.Hash, oldPriv))
+	}
+	_, otherPriv := GenerateKeypair()
+	history = append(history,
+		Sign(Create("substance.product", map[string]interface{}{"name": "Not ours"}, nil), venue.Hash, otherPriv),
+		Sign(Create("substance.product", map[string]interface{}{"name": "Other author"}, nil), "someone-else", otherPriv))
+	rotation, _ := RotateKeys(venue.Hash, oldPriv, "")
+
+	// The first run fails on its second batch and is resumed from its cursor.
+	var stored []SignedBlock
+	calls := 0
+	store := func(b ReattestBatch) error {
+		if calls++; calls == 2 {
+			return errors.New("disk full")
+		}
+		stored = append(stored, b.Attestations...)
+		return nil
+	}
+	report, err := Reattest(rotation.Signed, history, rotation.PrivateKey, ReattestOptions{BatchSize: 3, OnBatch: store})
+	if err == nil || report.Done || report.Attested != 3 {
+		t.Fatalf("first run: %+v, %v", report, err)
+	}
+	report, err = Reattest(rotation.Signed, history, rotation.PrivateKey, ReattestOptions{BatchSize: 3, After: report.Cursor, OnBatch: store})
+	if err != nil || !report.Done || report.Attested != 4 || len(report.Invalid) != 1 {
+		t.Fatalf("resumed run: %+v, %v", report, err)
+	}
+	for _, s := range history[:7] {
+		if !ReattestedBy(s.FoodBlock.Hash, rotation.Signed, stored) {
+			t.Errorf("%s not reattested", s.FoodBlock.Hash[:8])
+		}
+	}
+
+	// Rerunning produces the same attestation blocks.
+	again, _ := Reattest(rotation.Signed, history, rotation.PrivateKey, ReattestOptions{BatchSize: 100, OnBatch: func(b ReattestBatch) error {
+		if b.Attestations[0].FoodBlock.Hash != stored[0].FoodBlock.Hash {
+			t.Error("attestations differ between runs")
+		}
+		return nil
+	}})
+	if again.Attested != 7 {
+		t.Errorf("rerun = %+v", again)
+	}
+
+	if _, err := Reattest(rotation.Signed, history, otherPriv, ReattestOptions{}); err == nil {
+		t.Error("expected an error for a key the rotation does not name")
+	}
+}
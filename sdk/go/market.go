@@ -0,0 +1,255 @@
+package foodblock
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Market operations block types. A transfer.pitch lets one stall at a market
+// to a vendor for one date (refs market, vendor; state date, stall_number);
+// observe.attendance confirms the vendor turned up (refs pitch, vendor).
+const (
+	PitchType      = "transfer.pitch"
+	AttendanceType = "observe.attendance"
+)
+
+// AllocatePitch lets a stall at a market to a vendor on date (YYYY-MM-DD),
+// charging the market's pitch_fee. It refuses stalls already let to another
+// vendor that day in existing.
+func AllocatePitch(market Block, vendor, date, stall string, existing []Block) (Block, error) {
+	if vendor == "" || stall == "" {
+		return Block{}, errors.New("FoodBlock: pitch needs a vendor and a stall")
+	}
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		return Block{}, fmt.Errorf("FoodBlock: invalid pitch date: %q", date)
+	}
+	for _, p := range activePitches(existing) {
+		if p.Refs["market"] == market.Hash && p.State["date"] == date && p.State["stall_number"] == stall && p.Refs["vendor"] != vendor {
+			return Block{}, fmt.Errorf("FoodBlock: stall %s on %s is already let to %v", stall, date, p.Refs["vendor"])
+		}
+	}
+	state := map[string]interface{}{
+		"instance_id":  "pitch-" + market.Hash[:12] + "-" + date + "-" + stall,
+		"date":         date,
+		"stall_number": stall,
+		"status":       "allocated",
+	}
+	if fee, ok := numericValue(market.State["pitch_fee"]); ok {
+		state["pitch_fee"] = fee
+	}
+	if currency, ok := market.State["currency"].(string); ok {
+		state["currency"] = currency
+	}
+	return Create(PitchType, state, map[string]interface{}{"market": market.Hash, "vendor": vendor}), nil
+}
+
+// CancelPitch releases a pitch.
+func CancelPitch(pitch Block, reason string) Block {
+	changes := map[string]interface{}{"status": "cancelled"}
+	if reason != "" {
+		changes["reason"] = reason
+	}
+	return MergeUpdate(pitch, changes, map[string]interface{}{"market": pitch.Refs["market"], "vendor": pitch.Refs["vendor"]})
+}
+
+// activePitches returns the latest version of every pitch not cancelled.
+func activePitches(blocks []Block) []Block {
+	var out []Block
+	for _, b := range FilterBlocks(blocks, QueryParams{Type: PitchType, HeadsOnly: true}) {
+		if b.State["status"] != "cancelled" {
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+// PitchConflict is a stall let to more than one vendor on the same day.
+type PitchConflict struct {
+	Market  string   `json:"market"`
+	Date    string   `json:"date"`
+	Stall   string   `json:"stall"`
+	Vendors []string `json:"vendors"`
+	Pitches []string `json:"pitches"`
+}
+
+// DoubleBookings finds stalls let to more than one vendor on the same day,
+// for instance when allocations from two devices merge.
+func DoubleBookings(blocks []Block) []PitchConflict {
+	groups := map[string]*PitchConflict{}
+	var keys []string
+	for _, p := range activePitches(blocks) {
+		market, _ := p.Refs["market"].(string)
+		vendor, _ := p.Refs["vendor"].(string)
+		date, _ := p.State["date"].(string)
+		stall, _ := p.State["stall_number"].(string)
+		key := market + "|" + date + "|" + stall
+		g, ok := groups[key]
+		if !ok {
+			g = &PitchConflict{Market: market, Date: date, Stall: stall}
+			groups[key] = g
+			keys = append(keys, key)
+		}
+		g.Pitches = append(g.Pitches, p.Hash)
+		if !containsStr(g.Vendors, vendor) {
+			g.Vendors = append(g.Vendors, vendor)
+		}
+	}
+	sort.Strings(keys)
+	var out []PitchConflict
+	for _, k := range keys {
+		if g := groups[k]; len(g.Vendors) > 1 {
+			out = append(out, *g)
+		}
+	}
+	return out
+}
+
+// PitchInvoice bills a vendor for a pitch as a transfer.invoice from the
+// market's operator (or the market itself) for the pitch_fee.
+func PitchInvoice(pitch, market Block) (Block, error) {
+	fee, ok := numericValue(pitch.State["pitch_fee"])
+	if !ok {
+		return Block{}, fmt.Errorf("FoodBlock: pitch %s has no pitch_fee", pitch.Hash)
+	}
+	seller := firstRef(market.Refs, "operator", "owner")
+	if seller == "" {
+		seller = market.Hash
+	}
+	date, _ := pitch.State["date"].(string)
+	stall, _ := pitch.State["stall_number"].(string)
+	state := map[string]interface{}{
+		"instance_id": "INV-" + pitch.Hash[:12],
+		"name":        fmt.Sprintf("Pitch fee: stall %s, %s", stall, date),
+		"date":        date,
+		"total":       fee,
+	}
+	if currency, ok := pitch.State["currency"].(string); ok {
+		state["currency"] = currency
+	}
+	return Create("transfer.invoice", state, map[string]interface{}{
+		"seller": seller, "buyer": pitch.Refs["vendor"], "pitch": pitch.Hash, "market": market.Hash,
+	}), nil
+}
+
+// GeneratePitchInvoices invoices every active pitch at the market dated
+// within [from, to] that has no invoice yet.
+func GeneratePitchInvoices(market Block, blocks []Block, from, to time.Time) []Block {
+	invoiced := map[string]bool{}
+	for _, b := range blocks {
+		if b.Type == "transfer.invoice" {
+			if p, ok := b.Refs["pitch"].(string); ok {
+				invoiced[p] = true
+			}
+		}
+	}
+	var out []Block
+	for _, p := range activePitches(blocks) {
+		if p.Refs["market"] != market.Hash || invoiced[p.Hash] || !pitchInRange(p, from, to) {
+			continue
+		}
+		if inv, err := PitchInvoice(p, market); err == nil {
+			out = append(out, inv)
+		}
+	}
+	return out
+}
+
+func pitchInRange(p Block, from, to time.Time) bool {
+	date, ok := BlockDate(p)
+	if !ok {
+		return false
+	}
+	return (from.IsZero() || !date.Before(from)) && (to.IsZero() || !date.After(to))
+}
+
+// ConfirmAttendance records whether the vendor attended their pitch.
+func ConfirmAttendance(pitch Block, confirmedBy string, present bool, at time.Time) Block {
+	return Create(AttendanceType, map[string]interface{}{
+		"instance_id": "attendance-" + pitch.Hash[:12],
+		"present":     present,
+		"at":          at.UTC().Format(time.RFC3339),
+	}, map[string]interface{}{"pitch": pitch.Hash, "vendor": pitch.Refs["vendor"], "confirmed_by": confirmedBy})
+}
+
+// MarketDay is one day of an occupancy report.
+type MarketDay struct {
+	Date      string   `json:"date"`
+	Let       int      `json:"let"`
+	Attended  int      `json:"attended"`
+	NoShows   []string `json:"no_shows"`  // vendors confirmed absent
+	Occupancy float64  `json:"occupancy"` // let / stall_count, 0..1; 0 without a stall count
+}
+
+// MarketOccupancy reports how a market's stalls were let and used.
+type MarketOccupancy struct {
+	Market           string      `json:"market"`
+	Stalls           int         `json:"stalls"`
+	Days             []MarketDay `json:"days"`
+	AverageOccupancy float64     `json:"average_occupancy"`
+	FeesDue          float64     `json:"fees_due"`
+	FeesInvoiced     float64     `json:"fees_invoiced"`
+}
+
+// OccupancyReport summarises a market's pitches dated within [from, to]:
+// stalls let and attended per day, occupancy against state.stall_count, and
+// pitch fees due against those invoiced.
+func OccupancyReport(market Block, blocks []Block, from, to time.Time) MarketOccupancy {
+	r := MarketOccupancy{Market: market.Hash, Days: []MarketDay{}}
+	if n, ok := toFloat64(market.State["stall_count"]); ok {
+		r.Stalls = int(n)
+	}
+	attendance := map[string]Block{}
+	invoices := map[string]float64{}
+	for _, b := range blocks {
+		switch b.Type {
+		case AttendanceType:
+			if p, ok := b.Refs["pitch"].(string); ok {
+				attendance[p] = b
+			}
+		case "transfer.invoice":
+			if p, ok := b.Refs["pitch"].(string); ok {
+				invoices[p], _ = numericValue(b.State["total"])
+			}
+		}
+	}
+	days := map[string]*MarketDay{}
+	for _, p := range activePitches(blocks) {
+		if p.Refs["market"] != market.Hash || !pitchInRange(p, from, to) {
+			continue
+		}
+		date, _ := p.State["date"].(string)
+		d, ok := days[date]
+		if !ok {
+			d = &MarketDay{Date: date, NoShows: []string{}}
+			days[date] = d
+		}
+		d.Let++
+		if a, ok := attendance[p.Hash]; ok {
+			if present, _ := a.State["present"].(bool); present {
+				d.Attended++
+			} else if v, ok := p.Refs["vendor"].(string); ok {
+				d.NoShows = append(d.NoShows, v)
+			}
+		}
+		fee, _ := numericValue(p.State["pitch_fee"])
+		r.FeesDue += fee
+		r.FeesInvoiced += invoices[p.Hash]
+	}
+	total := 0.0
+	for _, d := range days {
+		if r.Stalls > 0 {
+			d.Occupancy = float64(d.Let) / float64(r.Stalls)
+		}
+		total += d.Occupancy
+		r.Days = append(r.Days, *d)
+	}
+	sort.Slice(r.Days, func(i, j int) bool { return r.Days[i].Date < r.Days[j].Date })
+	if len(r.Days) > 0 {
+		r.AverageOccupancy = total / float64(len(r.Days))
+	}
+	r.FeesDue = roundCents(r.FeesDue)
+	r.FeesInvoiced = roundCents(r.FeesInvoiced)
+	return r
+}
@@ -0,0 +1,73 @@
+package foodblock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMarketPitches(t *testing.T) {
+	organiser := Create("actor.venue", map[string]interface{}{"name": "Town Council"}, nil)
+	market := Create("place.market", map[string]interface{}{"name": "Saturday Market", "pitch_fee": 35.0, "currency": "GBP", "stall_count": 4.0},
+		map[string]interface{}{"operator": organiser.Hash})
+
+	var blocks []Block
+	allocate := func(vendor, date, stall string) (Block, error) {
+		p, err := AllocatePitch(market, vendor, date, stall, blocks)
+		if err == nil {
+			blocks = append(blocks, p)
+		}
+		return p, err
+	}
+	a, _ := allocate("bakery", "2026-06-06", "A1")
+	b, _ := allocate("cheese", "2026-06-06", "A2")
+	c, _ := allocate("bakery", "2026-06-13", "A1")
+	if _, err := allocate("veg", "2026-06-06", "A1"); err == nil {
+		t.Fatal("expected A1 on 6 June to be refused")
+	}
+	if a.State["pitch_fee"] != 35.0 || a.State["currency"] != "GBP" {
+		t.Errorf("expected the market's fee on the pitch, got %v", a.State)
+	}
+
+	// A cancelled pitch frees the stall.
+	cancelled := CancelPitch(b, "vendor ill")
+	blocks = append(blocks, cancelled)
+	if _, err := allocate("veg", "2026-06-06", "A2"); err != nil {
+		t.Fatalf("expected A2 to be free after cancellation: %v", err)
+	}
+
+	// Allocations merged from another device can still collide.
+	clash, _ := AllocatePitch(market, "flowers", "2026-06-13", "A1", nil)
+	conflicts := DoubleBookings(append(blocks, clash))
+	if len(conflicts) != 1 || conflicts[0].Stall != "A1" || conflicts[0].Date != "2026-06-13" || len(conflicts[0].Vendors) != 2 {
+		t.Errorf("expected one double booking on A1, got %+v", conflicts)
+	}
+
+	june := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	july := time.Date(2026, 6, 30, 0, 0, 0, 0, time.UTC)
+	invoices := GeneratePitchInvoices(market, blocks, june, july)
+	if len(invoices) != 3 {
+		t.Fatalf("expected 3 invoices, got %d", len(invoices))
+	}
+	if invoices[0].Refs["seller"] != organiser.Hash || invoices[0].State["total"] != 35.0 {
+		t.Errorf("unexpected invoice %v %v", invoices[0].State, invoices[0].Refs)
+	}
+	blocks = append(blocks, invoices[0])
+	if again := GeneratePitchInvoices(market, blocks, june, july); len(again) != 2 {
+		t.Errorf("expected invoiced pitches to be skipped, got %d", len(again))
+	}
+
+	blocks = append(blocks,
+		ConfirmAttendance(a, organiser.Hash, true, june.Add(5*24*time.Hour)),
+		ConfirmAttendance(c, organiser.Hash, false, june.Add(12*24*time.Hour)),
+	)
+	report := OccupancyReport(market, blocks, june, july)
+	if len(report.Days) != 2 || report.Days[0].Let != 2 || report.Days[0].Attended != 1 || report.Days[0].Occupancy != 0.5 {
+		t.Fatalf("unexpected first day %+v", report.Days)
+	}
+	if len(report.Days[1].NoShows) != 1 || report.Days[1].NoShows[0] != "bakery" {
+		t.Errorf("expected the bakery as a no-show, got %+v", report.Days[1])
+	}
+	if report.AverageOccupancy != 0.375 || report.FeesDue != 105 || report.FeesInvoiced != 35 {
+		t.Errorf("unexpected totals %+v", report)
+	}
+}
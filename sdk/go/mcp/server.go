@@ -0,0 +1,328 @@
+// Package mcp serves a FoodBlock graph over the Model Context Protocol, so MCP
+// clients can read blocks, provenance chains and trust scores as resources and
+// create, query, recall and explain blocks through tools.
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	foodblock "github.com/FoodXDevelopment/foodblock/sdk/go"
+)
+
+// ProtocolVersion is the MCP revision implemented by this server.
+const ProtocolVersion = "2024-11-05"
+
+// Resource URI prefixes.
+const (
+	BlockURI      = "foodblock://blocks/"
+	ProvenanceURI = "foodblock://provenance/"
+	TrustURI      = "foodblock://trust/"
+	StatsURI      = "foodblock://stats"
+)
+
+// JSON-RPC error codes.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+// Server handles MCP requests against a block store.
+type Server struct {
+	Store   foodblock.BlockStore
+	Name    string
+	Version string
+	// TrustBlocks supplies blocks with author metadata for trust scoring.
+	// Defaults to the store's blocks without authors.
+	TrustBlocks func() []foodblock.TrustBlock
+	TrustPolicy map[string]interface{}
+	// ListLimit caps the number of blocks returned by resources/list.
+	ListLimit int
+}
+
+// NewServer returns a server backed by store.
+func NewServer(store foodblock.BlockStore) *Server {
+	return &Server{Store: store, Name: "foodblock", Version: "0.5.0", ListLimit: 200}
+}
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// Handle processes one JSON-RPC message and returns the encoded response,
+// or nil for notifications.
+func (s *Server) Handle(ctx context.Context, msg []byte) []byte {
+	var req request
+	if err := json.Unmarshal(msg, &req); err != nil {
+		return encode(response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{codeParseError, "parse error"}})
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return encode(response{JSONRPC: "2.0", ID: idOrNull(req.ID), Error: &rpcError{codeInvalidRequest, "invalid request"}})
+	}
+	result, rerr := s.dispatch(ctx, req.Method, req.Params)
+	if len(req.ID) == 0 {
+		return nil
+	}
+	resp := response{JSONRPC: "2.0", ID: req.ID}
+	if rerr != nil {
+		resp.Error = rerr
+	} else {
+		resp.Result = result
+	}
+	return encode(resp)
+}
+
+func (s *Server) dispatch(ctx context.Context, method string, params json.RawMessage) (interface{}, *rpcError) {
+	switch method {
+	case "initialize":
+		var p struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(params, &p)
+		version := ProtocolVersion
+		if p.ProtocolVersion != "" && p.ProtocolVersion <= ProtocolVersion {
+			version = p.ProtocolVersion
+		}
+		return map[string]interface{}{
+			"protocolVersion": version,
+			"capabilities": map[string]interface{}{
+				"tools":     map[string]interface{}{},
+				"resources": map[string]interface{}{},
+			},
+			"serverInfo": map[string]interface{}{"name": s.Name, "version": s.Version},
+		}, nil
+	case "ping":
+		return map[string]interface{}{}, nil
+	case "notifications/initialized", "notifications/cancelled":
+		return nil, nil
+	case "tools/list":
+		return map[string]interface{}{"tools": s.tools()}, nil
+	case "tools/call":
+		var p struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil || p.Name == "" {
+			return nil, &rpcError{codeInvalidParams, "tools/call requires a tool name"}
+		}
+		out, err := s.callTool(p.Name, p.Arguments)
+		if err != nil {
+			return toolResult(err.Error(), true), nil
+		}
+		return toolResult(out, false), nil
+	case "resources/list":
+		return map[string]interface{}{"resources": s.listResources()}, nil
+	case "resources/templates/list":
+		return map[string]interface{}{"resourceTemplates": []map[string]interface{}{
+			{"uriTemplate": BlockURI + "{hash}", "name": "FoodBlock", "mimeType": "application/json"},
+			{"uriTemplate": ProvenanceURI + "{hash}", "name": "Provenance chain", "mimeType": "application/json"},
+			{"uriTemplate": TrustURI + "{actor_hash}", "name": "Trust score", "mimeType": "application/json"},
+		}}, nil
+	case "resources/read":
+		var p struct {
+			URI string `json:"uri"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil || p.URI == "" {
+			return nil, &rpcError{codeInvalidParams, "resources/read requires a uri"}
+		}
+		body, err := s.readResource(p.URI)
+		if err != nil {
+			return nil, &rpcError{codeInvalidParams, err.Error()}
+		}
+		return map[string]interface{}{"contents": []map[string]interface{}{
+			{"uri": p.URI, "mimeType": "application/json", "text": body},
+		}}, nil
+	}
+	return nil, &rpcError{codeMethodNotFound, "method not found: " + method}
+}
+
+func (s *Server) tools() []map[string]interface{} {
+	schemas := map[string]foodblock.ToolSchema{}
+	for _, t := range foodblock.ExportToolSchemas() {
+		schemas[t.Name] = t
+	}
+	hash := map[string]interface{}{"type": "string", "description": "Block hash"}
+	maxBreadth := map[string]interface{}{"type": "integer", "minimum": 1, "description": "Blocks listed per level; the rest are counted"}
+	groupBy := map[string]interface{}{"type": "string", "enum": []string{"type", "actor"}}
+	stringList := map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}}
+	return []map[string]interface{}{
+		{"name": "foodblock_create", "description": schemas[foodblock.ToolCreateBlock].Description, "inputSchema": schemas[foodblock.ToolCreateBlock].Parameters},
+		{"name": "foodblock_query", "description": schemas[foodblock.ToolQueryBlocks].Description, "inputSchema": schemas[foodblock.ToolQueryBlocks].Parameters},
+		{"name": "foodblock_recall", "description": "Trace every block downstream of a source block, e.g. products made from a contaminated ingredient.",
+			"inputSchema": map[string]interface{}{"type": "object", "required": []string{"hash"}, "properties": map[string]interface{}{
+				"hash":          hash,
+				"max_depth":     map[string]interface{}{"type": "integer", "minimum": 1},
+				"types":         stringList,
+				"exclude_types": stringList,
+				"exclude_roles": stringList,
+				"stop_types":    stringList,
+				"max_breadth":   maxBreadth,
+				"group_by":      groupBy,
+			}}},
+		{"name": "foodblock_explain", "description": "Explain a block and its provenance in plain language.",
+			"inputSchema": map[string]interface{}{"type": "object", "required": []string{"hash"}, "properties": map[string]interface{}{
+				"hash":        hash,
+				"max_breadth": maxBreadth,
+				"group_by":    groupBy,
+			}}},
+	}
+}
+
+func (s *Server) callTool(name string, args json.RawMessage) (string, error) {
+	switch name {
+	case "foodblock_create":
+		d := foodblock.ToolDispatcher{Store: s.Store, Persist: true}
+		return marshal(d.Dispatch(foodblock.ToolCreateBlock, args))
+	case "foodblock_query":
+		d := foodblock.ToolDispatcher{Store: s.Store}
+		return marshal(d.Dispatch(foodblock.ToolQueryBlocks, args))
+	case "foodblock_recall":
+		var p struct {
+			Hash         string   `json:"hash"`
+			MaxDepth     int      `json:"max_depth"`
+			Types        []string `json:"types"`
+			ExcludeTypes []string `json:"exclude_types"`
+			ExcludeRoles []string `json:"exclude_roles"`
+			StopTypes    []string `json:"stop_types"`
+			MaxBreadth   int      `json:"max_breadth"`
+			GroupBy      string   `json:"group_by"`
+		}
+		if err := json.Unmarshal(args, &p); err != nil || p.Hash == "" {
+			return "", errors.New("hash is required")
+		}
+		if s.Store.Resolve(p.Hash) == nil {
+			return "", errors.New("block not found: " + p.Hash)
+		}
+		result := foodblock.RecallWith(p.Hash, s.Store.ResolveForward, foodblock.RecallOptions{
+			MaxDepth: p.MaxDepth, Types: p.Types, ExcludeTypes: p.ExcludeTypes, ExcludeRoles: p.ExcludeRoles, StopTypes: p.StopTypes,
+		})
+		if p.MaxBreadth > 0 || p.GroupBy != "" {
+			summary := result.Summarize(foodblock.BreadthLimits{MaxPerLevel: p.MaxBreadth, Sample: true, GroupBy: p.GroupBy}, s.Store.Resolve)
+			return marshal(map[string]interface{}{"total": summary.Total, "depth": summary.Depth, "levels": summary.Levels, "narrative": summary.String()}, nil)
+		}
+		return marshal(map[string]interface{}{"affected": result.Affected, "depth": result.Depth, "paths": result.Paths}, nil)
+	case "foodblock_explain":
+		var p struct {
+			Hash       string `json:"hash"`
+			MaxBreadth int    `json:"max_breadth"`
+			GroupBy    string `json:"group_by"`
+		}
+		if err := json.Unmarshal(args, &p); err != nil || p.Hash == "" {
+			return "", errors.New("hash is required")
+		}
+		return foodblock.ExplainLimited(p.Hash, s.Store.Resolve, 0, foodblock.BreadthLimits{MaxPerLevel: p.MaxBreadth, Sample: true, GroupBy: p.GroupBy}), nil
+	}
+	return "", errors.New("unknown tool: " + name)
+}
+
+func (s *Server) listResources() []map[string]interface{} {
+	blocks := s.Store.Blocks()
+	sort.Slice(blocks, func(i, j int) bool {
+		if blocks[i].Type != blocks[j].Type {
+			return blocks[i].Type < blocks[j].Type
+		}
+		return blocks[i].Hash < blocks[j].Hash
+	})
+	if s.ListLimit > 0 && len(blocks) > s.ListLimit {
+		blocks = blocks[:s.ListLimit]
+	}
+	out := make([]map[string]interface{}, 0, len(blocks)+1)
+	out = append(out, map[string]interface{}{"uri": StatsURI, "name": "Graph statistics", "mimeType": "application/json"})
+	for _, b := range blocks {
+		name := b.Type
+		if n, ok := b.State["name"].(string); ok && n != "" {
+			name = n + " (" + b.Type + ")"
+		}
+		out = append(out, map[string]interface{}{"uri": BlockURI + b.Hash, "name": name, "mimeType": "application/json"})
+	}
+	return out
+}
+
+func (s *Server) readResource(uri string) (string, error) {
+	switch {
+	case uri == StatsURI:
+		return marshal(foodblock.GraphStats(s.Store, foodblock.GraphStatsOptions{}), nil)
+	case strings.HasPrefix(uri, BlockURI):
+		b := s.Store.Resolve(strings.TrimPrefix(uri, BlockURI))
+		if b == nil {
+			return "", errors.New("block not found")
+		}
+		return marshal(b, nil)
+	case strings.HasPrefix(uri, ProvenanceURI):
+		hash := strings.TrimPrefix(uri, ProvenanceURI)
+		if s.Store.Resolve(hash) == nil {
+			return "", errors.New("block not found")
+		}
+		return marshal(map[string]interface{}{
+			"chain":     foodblock.Chain(hash, s.Store.Resolve, 0),
+			"narrative": foodblock.Explain(hash, s.Store.Resolve, 0),
+		}, nil)
+	case strings.HasPrefix(uri, TrustURI):
+		actor := strings.TrimPrefix(uri, TrustURI)
+		if actor == "" {
+			return "", errors.New("actor hash is required")
+		}
+		return marshal(foodblock.ComputeTrust(actor, s.trustBlocks(), s.TrustPolicy), nil)
+	}
+	return "", fmt.Errorf("unknown resource: %s", uri)
+}
+
+func (s *Server) trustBlocks() []foodblock.TrustBlock {
+	if s.TrustBlocks != nil {
+		return s.TrustBlocks()
+	}
+	blocks := s.Store.Blocks()
+	out := make([]foodblock.TrustBlock, len(blocks))
+	for i, b := range blocks {
+		out[i] = foodblock.TrustBlock{Block: b}
+	}
+	return out
+}
+
+func toolResult(text string, isError bool) map[string]interface{} {
+	return map[string]interface{}{
+		"content": []map[string]interface{}{{"type": "text", "text": text}},
+		"isError": isError,
+	}
+}
+
+func marshal(v interface{}, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	return string(data), err
+}
+
+func encode(resp response) []byte {
+	data, _ := json.Marshal(resp)
+	return data
+}
+
+func idOrNull(id json.RawMessage) json.RawMessage {
+	if len(id) == 0 {
+		return json.RawMessage("null")
+	}
+	return id
+}
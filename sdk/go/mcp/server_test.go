@@ -0,0 +1,166 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	foodblock "github.com/FoodXDevelopment/foodblock/sdk/go"
+)
+
+func testServer(t *testing.T) (*Server, foodblock.Block, foodblock.Block) {
+	store := foodblock.NewMemoryStore()
+	wheat := foodblock.Create("substance.ingredient", map[string]interface{}{"name": "Wheat"}, nil)
+	bread := foodblock.Create("substance.product", map[string]interface{}{"name": "Sourdough"}, map[string]interface{}{"inputs": []interface{}{wheat.Hash}})
+	if err := store.PutAll([]foodblock.Block{wheat, bread}); err != nil {
+		t.Fatal(err)
+	}
+	return NewServer(store), wheat, bread
+}
+
+func call(t *testing.T, s *Server, method string, params interface{}) map[string]interface{} {
+	t.Helper()
+	p, _ := json.Marshal(params)
+	msg, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": method, "params": json.RawMessage(p)})
+	var resp map[string]interface{}
+	if err := json.Unmarshal(s.Handle(context.Background(), msg), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func toolText(t *testing.T, resp map[string]interface{}) (string, bool) {
+	t.Helper()
+	result, ok := resp["result"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("no result: %v", resp)
+	}
+	content := result["content"].([]interface{})[0].(map[string]interface{})
+	return content["text"].(string), result["isError"].(bool)
+}
+
+func TestInitializeAndList(t *testing.T) {
+	s, _, bread := testServer(t)
+	init := call(t, s, "initialize", map[string]interface{}{"protocolVersion": ProtocolVersion})
+	if init["result"].(map[string]interface{})["protocolVersion"] != ProtocolVersion {
+		t.Errorf("initialize = %v", init)
+	}
+	tools := call(t, s, "tools/list", nil)["result"].(map[string]interface{})["tools"].([]interface{})
+	if len(tools) != 4 {
+		t.Errorf("tools = %d, want 4", len(tools))
+	}
+	resources := call(t, s, "resources/list", nil)["result"].(map[string]interface{})["resources"].([]interface{})
+	found := false
+	for _, r := range resources {
+		if r.(map[string]interface{})["uri"] == BlockURI+bread.Hash {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("resources/list missing block")
+	}
+	if call(t, s, "nope", nil)["error"] == nil {
+		t.Error("expected method not found")
+	}
+}
+
+func TestToolsAndResources(t *testing.T) {
+	s, wheat, bread := testServer(t)
+
+	text, isErr := toolText(t, call(t, s, "tools/call", map[string]interface{}{
+		"name": "foodblock_recall", "arguments": map[string]interface{}{"hash": wheat.Hash},
+	}))
+	if isErr || !strings.Contains(text, bread.Hash) {
+		t.Errorf("recall = %s", text)
+	}
+
+	text, _ = toolText(t, call(t, s, "tools/call", map[string]interface{}{
+		"name": "foodblock_explain", "arguments": map[string]interface{}{"hash": bread.Hash},
+	}))
+	if !strings.Contains(text, "Sourdough") {
+		t.Errorf("explain = %s", text)
+	}
+
+	_, isErr = toolText(t, call(t, s, "tools/call", map[string]interface{}{
+		"name": "foodblock_create", "arguments": map[string]interface{}{"type": "actor.venue", "state": map[string]interface{}{"name": "Cafe"}},
+	}))
+	if isErr || s.Store.Len() != 3 {
+		t.Errorf("create did not persist: len %d", s.Store.Len())
+	}
+	text, _ = toolText(t, call(t, s, "tools/call", map[string]interface{}{
+		"name": "foodblock_query", "arguments": map[string]interface{}{"type": "actor.venue"},
+	}))
+	if !strings.Contains(text, "Cafe") {
+		t.Errorf("query = %s", text)
+	}
+	if _, isErr := toolText(t, call(t, s, "tools/call", map[string]interface{}{
+		"name": "foodblock_create", "arguments": map[string]interface{}{"type": "not.a.type"},
+	})); !isErr {
+		t.Error("invalid create should report a tool error")
+	}
+
+	for _, uri := range []string{BlockURI + bread.Hash, ProvenanceURI + bread.Hash, TrustURI + wheat.Hash, StatsURI} {
+		resp := call(t, s, "resources/read", map[string]interface{}{"uri": uri})
+		if resp["error"] != nil {
+			t.Errorf("read %s: %v", uri, resp["error"])
+		}
+	}
+	if call(t, s, "resources/read", map[string]interface{}{"uri": BlockURI + "missing"})["error"] == nil {
+		t.Error("expected error for missing block")
+	}
+}
+
+func TestServeStdio(t *testing.T) {
+	s, _, _ := testServer(t)
+	in := strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"ping"}` + "\n" +
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}` + "\n" +
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}` + "\n")
+	var out strings.Builder
+	if err := s.ServeStdio(context.Background(), in, &out); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d responses, want 2 (notifications get none)", len(lines))
+	}
+}
+
+func TestSSETransport(t *testing.T) {
+	s, _, _ := testServer(t)
+	srv := httptest.NewServer(s.SSEHandler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/sse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+	readData := func() string {
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.HasPrefix(line, "data: ") {
+				return strings.TrimSpace(strings.TrimPrefix(line, "data: "))
+			}
+		}
+	}
+	endpoint := readData()
+	post, err := http.Post(srv.URL+endpoint, "application/json", strings.NewReader(`{"jsonrpc":"2.0","id":7,"method":"ping"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	post.Body.Close()
+	if post.StatusCode != http.StatusAccepted {
+		t.Fatalf("post status = %d", post.StatusCode)
+	}
+	if msg := readData(); !strings.Contains(msg, `"id":7`) {
+		t.Errorf("sse message = %s", msg)
+	}
+}
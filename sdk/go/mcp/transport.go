@@ -0,0 +1,123 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// ServeStdio reads newline-delimited JSON-RPC messages from r and writes
+// responses to w until r is exhausted or ctx is cancelled.
+func (s *Server) ServeStdio(ctx context.Context, r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if out := s.Handle(ctx, line); out != nil {
+			if _, err := w.Write(append(out, '\n')); err != nil {
+				return err
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// SSEHandler serves the HTTP+SSE transport: clients open an event stream at
+// /sse, receive the message endpoint, and POST requests to it. Responses are
+// delivered on the event stream.
+func (s *Server) SSEHandler() http.Handler {
+	h := &sseHandler{server: s, sessions: make(map[string]chan []byte)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sse", h.stream)
+	mux.HandleFunc("/message", h.message)
+	return mux
+}
+
+type sseHandler struct {
+	server   *Server
+	mu       sync.Mutex
+	sessions map[string]chan []byte
+}
+
+func (h *sseHandler) stream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	id := newSessionID()
+	ch := make(chan []byte, 16)
+	h.mu.Lock()
+	h.sessions[id] = ch
+	h.mu.Unlock()
+	defer func() {
+		h.mu.Lock()
+		delete(h.sessions, id)
+		h.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	fmt.Fprintf(w, "event: endpoint\ndata: /message?sessionId=%s\n\n", id)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case msg := <-ch:
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", msg)
+			flusher.Flush()
+		}
+	}
+}
+
+func (h *sseHandler) message(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	h.mu.Lock()
+	ch, ok := h.sessions[r.URL.Query().Get("sessionId")]
+	h.mu.Unlock()
+	if !ok {
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 16*1024*1024))
+	if err != nil {
+		http.Error(w, "could not read body", http.StatusBadRequest)
+		return
+	}
+	if out := h.server.Handle(r.Context(), body); out != nil {
+		select {
+		case ch <- out:
+		case <-r.Context().Done():
+			return
+		}
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func newSessionID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
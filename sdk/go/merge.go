@@ -117,6 +117,8 @@ func Merge(hashA, hashB string, resolve func(string) *Block, strategy string, ma
 }
 
 // AutoMerge attempts automatic merge using per-field strategies from a vocabulary.
+// Fields the forks disagreed on are annotated in state._prov with the fork
+// they were taken from.
 func AutoMerge(hashA, hashB string, resolve func(string) *Block, fieldStrategies map[string]string) (Block, error) {
 	blockA := resolve(hashA)
 	blockB := resolve(hashB)
@@ -143,6 +145,7 @@ func AutoMerge(hashA, hashB string, resolve func(string) *Block, fieldStrategies
 	for k := range stateB {
 		allKeys[k] = true
 	}
+	delete(allKeys, ProvenanceKey)
 
 	mergedState := map[string]interface{}{}
 	for key := range allKeys {
@@ -212,6 +215,19 @@ func AutoMerge(hashA, hashB string, resolve func(string) *Block, fieldStrategies
 	for k, v := range mergedState {
 		state[k] = v
 	}
+	// Record which fork each field the forks disagreed on was taken from.
+	prov := map[string]interface{}{}
+	for k, v := range mergedState {
+		fromA, fromB := sameValue(blockA, k, v), sameValue(blockB, k, v)
+		if fromA && !fromB {
+			prov[k] = hashA
+		} else if fromB && !fromA {
+			prov[k] = hashB
+		}
+	}
+	if len(prov) > 0 {
+		state[ProvenanceKey] = prov
+	}
 
 	return Create("observe.merge", state, map[string]interface{}{
 		"merges": []interface{}{hashA, hashB},
@@ -0,0 +1,145 @@
+package foodblock
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// MigrateOptions controls a MigrateStore run.
+type MigrateOptions struct {
+	DryRun    bool                  // compare the stores without writing
+	BatchSize int                   // blocks between progress calls, default 500
+	Progress  func(MigrateProgress) // optional
+}
+
+// MigrateProgress is reported every BatchSize blocks and once at the end.
+type MigrateProgress struct {
+	Total   int    `json:"total"`
+	Done    int    `json:"done"`    // blocks visited so far
+	Copied  int    `json:"copied"`  // written to the destination
+	Skipped int    `json:"skipped"` // already in the destination
+	Last    string `json:"last"`    // last block visited
+}
+
+// MigrateReport summarises a migration. Missing lists source blocks absent
+// from the destination: what a dry run would copy, or what a failed run did
+// not reach.
+type MigrateReport struct {
+	MigrateProgress
+	DryRun     bool     `json:"dry_run"`
+	SourceRoot string   `json:"source_root"`
+	DestRoot   string   `json:"dest_root"`
+	Verified   bool     `json:"verified"` // the roots match
+	Missing    []string `json:"missing"`
+	Aliases    int      `json:"aliases"`
+}
+
+// MigrateStore copies every block from src to dst, one at a time in causal
+// order (see Order), so a reader of dst never sees a block before the blocks
+// it references. Stubs are copied as stubs, signers and logical clocks are
+// kept when both stores record them, and aliases are copied between
+// AliasStores once a run completes without errors. The destination's indexes
+// are rebuilt afterwards and the run is verified by comparing the Merkle roots
+// of both stores' hashes.
+//
+// Blocks already in dst are skipped, so an interrupted migration resumes by
+// running it again. On error the report covers the blocks copied so far.
+func MigrateStore(src, dst BlockStore, opts MigrateOptions) (MigrateReport, error) {
+	if src == nil || dst == nil {
+		return MigrateReport{}, errors.New("FoodBlock: migration needs a source and a destination")
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+	var clockOf func(string) (Clock, bool)
+	if ci, ok := src.(ClockIndex); ok {
+		clockOf = ci.ClockOf
+	}
+	blocks := Order(src.Blocks(), clockOf)
+	r := MigrateReport{MigrateProgress: MigrateProgress{Total: len(blocks)}, DryRun: opts.DryRun, Missing: []string{}}
+	progress := func() {
+		if opts.Progress != nil {
+			opts.Progress(r.MigrateProgress)
+		}
+	}
+
+	signers, _ := src.(interface{ SignerOf(string) string })
+	signed, _ := dst.(interface{ PutSigned(SignedBlock) error })
+	stubs, _ := dst.(StubStore)
+	var failed error
+	for i, b := range blocks {
+		r.Last = b.Hash
+		if dst.Resolve(b.Hash) != nil {
+			r.Skipped++
+		} else if opts.DryRun || failed != nil {
+			r.Missing = append(r.Missing, b.Hash)
+		} else if err := migrateBlock(b, dst, stubs, signed, signers, clockOf); err != nil {
+			failed = fmt.Errorf("FoodBlock: migrating %s: %w", b.Hash, err)
+			r.Missing = append(r.Missing, b.Hash)
+		} else {
+			r.Copied++
+		}
+		r.Done = i + 1
+		if r.Done%opts.BatchSize == 0 && r.Done < len(blocks) {
+			progress()
+		}
+	}
+
+	if !opts.DryRun {
+		// Aliases follow once every block they might name is in place.
+		from, fromOK := src.(AliasStore)
+		to, toOK := dst.(AliasStore)
+		if failed == nil && fromOK && toOK {
+			for alias, hash := range from.Aliases().Aliases() {
+				if current, err := to.Aliases().Resolve("@" + alias); err != nil || current != hash {
+					to.Aliases().Set(alias, hash)
+					r.Aliases++
+				}
+			}
+		}
+		if rb, ok := dst.(interface{ RebuildIndexes() }); ok {
+			rb.RebuildIndexes()
+		}
+	}
+	r.SourceRoot = storeRoot(src)
+	r.DestRoot = storeRoot(dst)
+	r.Verified = r.SourceRoot == r.DestRoot
+	progress()
+	return r, failed
+}
+
+func migrateBlock(b Block, dst BlockStore, stubs StubStore, signed interface{ PutSigned(SignedBlock) error }, signers interface{ SignerOf(string) string }, clockOf func(string) (Clock, bool)) error {
+	if IsStub(b) {
+		if stubs == nil {
+			return errors.New("destination cannot store stubs")
+		}
+		return stubs.PutStub(b)
+	}
+	if signed == nil {
+		return dst.Put(b)
+	}
+	// Only a signer the source recorded carries over; refs.author is a claim.
+	wrapper := SignedBlock{FoodBlock: b}
+	if signers != nil {
+		wrapper.AuthorHash = signers.SignerOf(b.Hash)
+	}
+	if clockOf != nil {
+		if c, ok := clockOf(b.Hash); ok {
+			wrapper.Clock = &c
+		}
+	}
+	return signed.PutSigned(wrapper)
+}
+
+// storeRoot is the Merkle root of a store's block hashes, as in
+// CreateSnapshot.
+func storeRoot(store BlockStore) string {
+	blocks := store.Blocks()
+	hashes := make([]string, len(blocks))
+	for i, b := range blocks {
+		hashes[i] = b.Hash
+	}
+	sort.Strings(hashes)
+	return computeMerkleRoot(hashes)
+}
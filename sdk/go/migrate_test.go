@@ -0,0 +1,72 @@
+package foodblock
+
+import (
+	"errors"
+	"testing"
+)
+
+// failingStore rejects puts after a budget, simulating an interrupted run.
+type failingStore struct {
+	*MemoryStore
+	budget int
+}
+
+func (f *failingStore) PutSigned(signed SignedBlock) error {
+	if f.budget == 0 {
+		return errors.New("disk full")
+	}
+	f.budget--
+	return f.MemoryStore.PutSigned(signed)
+}
+
+func TestMigrateStore(t *testing.T) {
+	src := NewMemoryStore()
+	farm := Create("actor.producer", map[string]interface{}{"name": "Green Acres"}, nil)
+	wheat := Create("substance.ingredient", map[string]interface{}{"name": "Wheat"}, map[string]interface{}{"source": farm.Hash, "author": farm.Hash})
+	flour := Create("substance.ingredient", map[string]interface{}{"name": "Flour"}, map[string]interface{}{"source": wheat.Hash})
+	bread := Update(flour.Hash, "substance.ingredient", map[string]interface{}{"name": "Stoneground flour"}, map[string]interface{}{"source": wheat.Hash})
+	src.PutSigned(SignedBlock{FoodBlock: farm, AuthorHash: "signer"})
+	src.PutAll([]Block{wheat, flour, bread})
+	src.Stub(flour.Hash, map[string]interface{}{"pruned": true})
+	src.Aliases().Set("farm", farm.Hash)
+
+	dst := NewMemoryStore()
+	dry, err := MigrateStore(src, dst, MigrateOptions{DryRun: true})
+	if err != nil || dry.Verified || len(dry.Missing) != 4 || dst.Len() != 0 {
+		t.Fatalf("unexpected dry run: %+v, %v", dry, err)
+	}
+
+	// The first run fails part way through; the second resumes.
+	partial := &failingStore{MemoryStore: dst, budget: 1}
+	r, err := MigrateStore(src, partial, MigrateOptions{})
+	if err == nil || r.Copied != 1 || r.Verified || len(r.Missing) != 3 {
+		t.Fatalf("expected an interrupted run, got %+v, %v", r, err)
+	}
+	if r.Missing[0] != wheat.Hash {
+		t.Errorf("expected causal order, resuming at wheat, got %v", r.Missing)
+	}
+
+	var calls []MigrateProgress
+	r, err = MigrateStore(src, dst, MigrateOptions{BatchSize: 2, Progress: func(p MigrateProgress) { calls = append(calls, p) }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Verified || r.Copied != 3 || r.Skipped != 1 || r.SourceRoot != r.DestRoot || r.Aliases != 1 {
+		t.Errorf("unexpected report: %+v", r)
+	}
+	if len(calls) != 2 || calls[0].Done != 2 || calls[1].Done != 4 {
+		t.Errorf("unexpected progress calls: %+v", calls)
+	}
+	if got := dst.Resolve(flour.Hash); got == nil || !IsStub(*got) {
+		t.Error("expected the stub to be migrated as a stub")
+	}
+	if dst.SignerOf(farm.Hash) != "signer" || dst.HeadOf(flour.Hash) != bread.Hash {
+		t.Error("expected signer and head index on the destination")
+	}
+	if dst.SignerOf(wheat.Hash) != "" {
+		t.Error("an unsigned block's refs.author became its signer")
+	}
+	if c1, _ := src.ClockOf(wheat.Hash); func() bool { c2, _ := dst.ClockOf(wheat.Hash); return c1 != c2 }() {
+		t.Error("expected the logical clock to be kept")
+	}
+}
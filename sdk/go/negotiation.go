@@ -0,0 +1,407 @@
+package foodblock
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Negotiation block types. A negotiation opens with a proposal, alternates
+// counter-proposals between buyer and seller (each refs.counters the previous
+// offer) and ends with an acceptance or a rejection of the latest offer.
+const (
+	ProposalType        = "transfer.proposal"
+	CounterProposalType = "transfer.counter_proposal"
+	AcceptanceType      = "transfer.acceptance"
+	RejectionType       = "transfer.rejection"
+	AgreedTermsType     = "transfer.terms"
+)
+
+// DefaultMaxRounds limits offers in a negotiation when the proposal sets none.
+const DefaultMaxRounds = 6
+
+// Terms are the negotiable fields of an offer.
+type Terms struct {
+	Price    float64 `json:"price"` // per unit
+	Quantity float64 `json:"quantity"`
+	Unit     string  `json:"unit,omitempty"`
+	Currency string  `json:"currency,omitempty"`
+	Product  string  `json:"product,omitempty"` // product block hash
+}
+
+// ProposalOptions configures an opening proposal.
+type ProposalOptions struct {
+	MaxRounds int       // total offers allowed, opening included; default DefaultMaxRounds
+	Deadline  time.Time // zero means no deadline
+}
+
+// Propose opens a negotiation. proposer must be the buyer or the seller.
+func Propose(proposer, buyer, seller string, terms Terms, opts ProposalOptions) (Block, error) {
+	if buyer == "" || seller == "" {
+		return Block{}, errors.New("FoodBlock: buyer and seller are required")
+	}
+	if proposer != buyer && proposer != seller {
+		return Block{}, errors.New("FoodBlock: proposer must be the buyer or the seller")
+	}
+	if err := terms.validate(); err != nil {
+		return Block{}, err
+	}
+	maxRounds := opts.MaxRounds
+	if maxRounds <= 0 {
+		maxRounds = DefaultMaxRounds
+	}
+	state := terms.state()
+	state["round"] = 1
+	state["max_rounds"] = maxRounds
+	if !opts.Deadline.IsZero() {
+		state["deadline"] = opts.Deadline.UTC().Format(time.RFC3339)
+	}
+	refs := map[string]interface{}{"buyer": buyer, "seller": seller, "proposer": proposer}
+	if terms.Product != "" {
+		refs["product"] = terms.Product
+	}
+	return Create(ProposalType, state, refs), nil
+}
+
+// Counter answers the latest offer with new terms. It fails when the proposer
+// is not the other party, the round limit is reached or the deadline passed.
+func Counter(offer Block, proposer string, terms Terms, now time.Time) (Block, error) {
+	if err := checkResponse(offer, proposer, now); err != nil {
+		return Block{}, err
+	}
+	if err := terms.validate(); err != nil {
+		return Block{}, err
+	}
+	round := offerInt(offer, "round") + 1
+	maxRounds := offerInt(offer, "max_rounds")
+	if round > maxRounds {
+		return Block{}, fmt.Errorf("FoodBlock: negotiation reached its limit of %d rounds", maxRounds)
+	}
+	state := terms.state()
+	state["round"] = round
+	state["max_rounds"] = maxRounds
+	if d, ok := offer.State["deadline"]; ok {
+		state["deadline"] = d
+	}
+	refs := map[string]interface{}{
+		"buyer":    offer.Refs["buyer"],
+		"seller":   offer.Refs["seller"],
+		"proposer": proposer,
+		"counters": offer.Hash,
+	}
+	if terms.Product != "" {
+		refs["product"] = terms.Product
+	} else if p, ok := offer.Refs["product"].(string); ok {
+		refs["product"] = p
+	}
+	return Create(CounterProposalType, state, refs), nil
+}
+
+// Accept accepts the latest offer on behalf of the other party.
+func Accept(offer Block, acceptor string, now time.Time) (Block, error) {
+	if err := checkResponse(offer, acceptor, now); err != nil {
+		return Block{}, err
+	}
+	return Create(AcceptanceType, map[string]interface{}{
+		"round":       offerInt(offer, "round"),
+		"accepted_at": now.UTC().Format(time.RFC3339),
+	}, map[string]interface{}{"accepts": offer.Hash, "acceptor": acceptor}), nil
+}
+
+// Reject ends the negotiation without agreement.
+func Reject(offer Block, rejector, reason string) (Block, error) {
+	if !isOffer(offer) {
+		return Block{}, errors.New("FoodBlock: can only reject a proposal or counter-proposal")
+	}
+	state := map[string]interface{}{}
+	if reason != "" {
+		state["reason"] = reason
+	}
+	return Create(RejectionType, state, map[string]interface{}{"rejects": offer.Hash, "rejector": rejector}), nil
+}
+
+// NegotiationChain returns the offers leading to hash (an offer, acceptance or
+// rejection), opening proposal first, followed by the closing block if any.
+func NegotiationChain(hash string, resolve func(string) *Block) ([]Block, error) {
+	b := resolve(hash)
+	if b == nil {
+		return nil, fmt.Errorf("FoodBlock: block not found: %s", hash)
+	}
+	var closing *Block
+	switch b.Type {
+	case AcceptanceType, RejectionType:
+		closing = b
+		target, _ := b.Refs["accepts"].(string)
+		if target == "" {
+			target, _ = b.Refs["rejects"].(string)
+		}
+		if b = resolve(target); b == nil {
+			return nil, fmt.Errorf("FoodBlock: offer not found: %s", target)
+		}
+	}
+	var chain []Block
+	seen := map[string]bool{}
+	for b != nil {
+		if !isOffer(*b) || seen[b.Hash] {
+			return nil, fmt.Errorf("FoodBlock: %s is not a negotiation offer", b.Hash)
+		}
+		seen[b.Hash] = true
+		chain = append([]Block{*b}, chain...)
+		prev, _ := b.Refs["counters"].(string)
+		if prev == "" {
+			break
+		}
+		if b = resolve(prev); b == nil {
+			return nil, fmt.Errorf("FoodBlock: offer not found: %s", prev)
+		}
+	}
+	if chain[0].Type != ProposalType {
+		return nil, errors.New("FoodBlock: negotiation does not start with a proposal")
+	}
+	if closing != nil {
+		chain = append(chain, *closing)
+	}
+	return chain, nil
+}
+
+// Referee checks a negotiation ending in acceptanceHash and derives the
+// agreed terms block. The opening proposer must be the buyer or the seller,
+// the chain must alternate between them, stay within the opening proposal's
+// max_rounds and be accepted by the counterparty before the proposal's
+// deadline, which no counter-proposal may change. Parties named in refs are
+// only claims, so every offer must be signed by its proposer and the
+// acceptance by its acceptor, as signerOf reports (such as a store's
+// SignerOf). accepted_at is the acceptor's own claim, so when there is a
+// deadline the acceptance time comes from recordedAt instead, such as a
+// store's StoredAt; without it such a negotiation is refused. The result is
+// deterministic for a given acceptance. The terms copied from the accepted
+// offer are annotated in state._prov.
+func Referee(acceptanceHash string, resolve func(string) *Block, signerOf func(string) string, recordedAt func(string) (time.Time, bool)) (Block, error) {
+	if signerOf == nil {
+		return Block{}, errors.New("FoodBlock: refereeing a negotiation needs the signer of each block")
+	}
+	chain, err := NegotiationChain(acceptanceHash, resolve)
+	if err != nil {
+		return Block{}, err
+	}
+	acceptance := chain[len(chain)-1]
+	if acceptance.Type != AcceptanceType {
+		return Block{}, errors.New("FoodBlock: negotiation was not accepted")
+	}
+	offers := chain[:len(chain)-1]
+	opening := offers[0]
+	buyer, _ := opening.Refs["buyer"].(string)
+	seller, _ := opening.Refs["seller"].(string)
+	maxRounds := offerInt(opening, "max_rounds")
+	_, hasDeadline := opening.State["deadline"]
+	deadline, _ := opening.State["deadline"].(string)
+	for i, o := range offers {
+		if o.Refs["buyer"] != buyer || o.Refs["seller"] != seller {
+			return Block{}, fmt.Errorf("FoodBlock: offer %s changes the parties", o.Hash)
+		}
+		proposer, _ := o.Refs["proposer"].(string)
+		if proposer == "" || proposer != buyer && proposer != seller {
+			return Block{}, fmt.Errorf("FoodBlock: offer %s is not proposed by the buyer or the seller", o.Hash)
+		}
+		if signerOf(o.Hash) != proposer {
+			return Block{}, fmt.Errorf("FoodBlock: offer %s is not signed by its proposer", o.Hash)
+		}
+		if d, _ := o.State["deadline"].(string); d != deadline {
+			return Block{}, fmt.Errorf("FoodBlock: offer %s changes the deadline", o.Hash)
+		}
+		if offerInt(o, "round") != i+1 || i+1 > maxRounds {
+			return Block{}, fmt.Errorf("FoodBlock: offer %s is out of round", o.Hash)
+		}
+		if i > 0 && proposer == offers[i-1].Refs["proposer"] {
+			return Block{}, fmt.Errorf("FoodBlock: offer %s does not alternate proposers", o.Hash)
+		}
+	}
+	final := offers[len(offers)-1]
+	if acceptance.Refs["acceptor"] != counterparty(final) {
+		return Block{}, errors.New("FoodBlock: offer was not accepted by the counterparty")
+	}
+	if signerOf(acceptance.Hash) != counterparty(final) {
+		return Block{}, errors.New("FoodBlock: acceptance is not signed by the acceptor")
+	}
+	if hasDeadline {
+		if recordedAt == nil {
+			return Block{}, errors.New("FoodBlock: a negotiation with a deadline needs the time its acceptance was recorded")
+		}
+		when, ok := recordedAt(acceptance.Hash)
+		if _, err := time.Parse(time.RFC3339, deadline); err != nil || !ok || offerExpired(opening, when) {
+			return Block{}, errors.New("FoodBlock: offer was not accepted before the deadline")
+		}
+	}
+
+	state := map[string]interface{}{
+		"instance_id": "terms-" + acceptance.Hash[:16],
+		"rounds":      len(offers),
+	}
+	for _, k := range []string{"price", "quantity", "unit", "currency"} {
+		if v, ok := final.State[k]; ok {
+			state[k] = v
+		}
+	}
+	if price, ok := toFloat64(final.State["price"]); ok {
+		if qty, ok := toFloat64(final.State["quantity"]); ok {
+			state["total"] = math.Round(price*qty*100) / 100
+		}
+	}
+	Annotate(state, final.Hash, "price", "quantity", "unit", "currency")
+	refs := map[string]interface{}{
+		"buyer":      buyer,
+		"seller":     seller,
+		"proposal":   opening.Hash,
+		"accepted":   final.Hash,
+		"acceptance": acceptance.Hash,
+	}
+	if p, ok := final.Refs["product"].(string); ok {
+		refs["product"] = p
+	}
+	return Create(AgreedTermsType, state, refs), nil
+}
+
+// NegotiationPolicy encodes an agent's acceptance criteria. A buyer accepts
+// offers at or below LimitPrice, a seller at or above it; both require the
+// quantity within [MinQuantity, MaxQuantity] (zero bounds are open). When
+// countering, the agent concedes linearly from TargetPrice towards
+// LimitPrice over the negotiation's rounds.
+type NegotiationPolicy struct {
+	Role        string  `json:"role"` // "buyer" or "seller"
+	TargetPrice float64 `json:"target_price"`
+	LimitPrice  float64 `json:"limit_price"`
+	MinQuantity float64 `json:"min_quantity,omitempty"`
+	MaxQuantity float64 `json:"max_quantity,omitempty"`
+}
+
+// NegotiationPolicyFromState reads a policy from an agent block's
+// state.negotiation map.
+func NegotiationPolicyFromState(state map[string]interface{}) (NegotiationPolicy, bool) {
+	m, ok := state["negotiation"].(map[string]interface{})
+	if !ok {
+		return NegotiationPolicy{}, false
+	}
+	p := NegotiationPolicy{}
+	p.Role, _ = m["role"].(string)
+	p.TargetPrice, _ = toFloat64(m["target_price"])
+	p.LimitPrice, _ = toFloat64(m["limit_price"])
+	p.MinQuantity, _ = toFloat64(m["min_quantity"])
+	p.MaxQuantity, _ = toFloat64(m["max_quantity"])
+	return p, p.Role == "buyer" || p.Role == "seller"
+}
+
+// Acceptable reports whether an offer meets the policy.
+func (p NegotiationPolicy) Acceptable(offer Block) bool {
+	price, _ := toFloat64(offer.State["price"])
+	qty, _ := toFloat64(offer.State["quantity"])
+	if p.MinQuantity > 0 && qty < p.MinQuantity || p.MaxQuantity > 0 && qty > p.MaxQuantity {
+		return false
+	}
+	if p.Role == "buyer" {
+		return price <= p.LimitPrice
+	}
+	return price >= p.LimitPrice
+}
+
+// Respond produces the agent's reply to an offer: an acceptance when it meets
+// the policy, otherwise a counter-proposal, or a rejection when no rounds or
+// time remain.
+func (p NegotiationPolicy) Respond(offer Block, actor string, now time.Time) (Block, error) {
+	if p.Acceptable(offer) {
+		return Accept(offer, actor, now)
+	}
+	round := offerInt(offer, "round") + 1
+	maxRounds := offerInt(offer, "max_rounds")
+	if round > maxRounds || offerExpired(offer, now) {
+		return Reject(offer, actor, "no acceptable terms")
+	}
+	price := p.TargetPrice
+	if maxRounds > 1 {
+		price += (p.LimitPrice - p.TargetPrice) * float64(round-1) / float64(maxRounds-1)
+	}
+	terms := Terms{Price: math.Round(price*100) / 100}
+	terms.Quantity, _ = toFloat64(offer.State["quantity"])
+	if p.MinQuantity > 0 && terms.Quantity < p.MinQuantity {
+		terms.Quantity = p.MinQuantity
+	}
+	if p.MaxQuantity > 0 && terms.Quantity > p.MaxQuantity {
+		terms.Quantity = p.MaxQuantity
+	}
+	terms.Unit, _ = offer.State["unit"].(string)
+	terms.Currency, _ = offer.State["currency"].(string)
+	return Counter(offer, actor, terms, now)
+}
+
+// Negotiate replies to an offer under the agent's policy, signs the reply
+// and records it in the agent's action log.
+func (a *Agent) Negotiate(offer Block, policy NegotiationPolicy, now time.Time) (Block, SignedBlock, error) {
+	reply, err := policy.Respond(offer, a.AuthorHash, now)
+	if err != nil {
+		return Block{}, SignedBlock{}, err
+	}
+	if a.Log != nil {
+		a.Log.Record(CreateAction(a.AuthorHash, "negotiate",
+			AgentAction{Intent: "respond to " + offer.Type, Evidence: []string{offer.Hash}}, reply))
+	}
+	return reply, a.Sign(reply), nil
+}
+
+func (t Terms) validate() error {
+	if t.Price < 0 || t.Quantity <= 0 {
+		return errors.New("FoodBlock: terms need a non-negative price and a positive quantity")
+	}
+	return nil
+}
+
+func (t Terms) state() map[string]interface{} {
+	state := map[string]interface{}{"price": t.Price, "quantity": t.Quantity}
+	if t.Unit != "" {
+		state["unit"] = t.Unit
+	}
+	if t.Currency != "" {
+		state["currency"] = t.Currency
+	}
+	return state
+}
+
+func isOffer(b Block) bool {
+	return b.Type == ProposalType || b.Type == CounterProposalType
+}
+
+// checkResponse validates that actor may answer offer at now.
+func checkResponse(offer Block, actor string, now time.Time) error {
+	if !isOffer(offer) {
+		return errors.New("FoodBlock: can only respond to a proposal or counter-proposal")
+	}
+	if actor != counterparty(offer) {
+		return errors.New("FoodBlock: only the other party can respond to an offer")
+	}
+	if offerExpired(offer, now) {
+		return errors.New("FoodBlock: negotiation deadline has passed")
+	}
+	return nil
+}
+
+func counterparty(offer Block) string {
+	buyer, _ := offer.Refs["buyer"].(string)
+	seller, _ := offer.Refs["seller"].(string)
+	if offer.Refs["proposer"] == buyer {
+		return seller
+	}
+	return buyer
+}
+
+func offerExpired(offer Block, now time.Time) bool {
+	d, ok := offer.State["deadline"].(string)
+	if !ok {
+		return false
+	}
+	t, err := time.Parse(time.RFC3339, d)
+	return err == nil && now.After(t)
+}
+
+func offerInt(b Block, key string) int {
+	n, _ := toFloat64(b.State[key])
+	return int(n)
+}
@@ -0,0 +1,200 @@
+package foodblock
+
+import (
+	"testing"
+	"time"
+)
+
+func negotiationResolver(blocks ...Block) func(string) *Block {
+	byHash := map[string]Block{}
+	for _, b := range blocks {
+		byHash[b.Hash] = b
+	}
+	return func(h string) *Block {
+		if b, ok := byHash[h]; ok {
+			return &b
+		}
+		return nil
+	}
+}
+
+// negotiationSigners reports each block as signed by the party its refs
+// name: the proposer of an offer, the acceptor of an acceptance.
+func negotiationSigners(blocks ...Block) func(string) string {
+	signers := map[string]string{}
+	for _, b := range blocks {
+		for _, role := range []string{"proposer", "acceptor"} {
+			if a, ok := b.Refs[role].(string); ok {
+				signers[b.Hash] = a
+			}
+		}
+	}
+	return func(h string) string { return signers[h] }
+}
+
+func TestNegotiationBetweenAgents(t *testing.T) {
+	buyerAgent, _ := CreateAgent("Buyer bot", "op-restaurant", nil)
+	sellerAgent, _ := CreateAgent("Seller bot", "op-mill", nil)
+	buyerAgent.Log = NewActionLog()
+	buyer, seller := buyerAgent.AuthorHash, sellerAgent.AuthorHash
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	opening, err := Propose(seller, buyer, seller, Terms{Price: 3.0, Quantity: 100, Unit: "kg", Currency: "GBP"},
+		ProposalOptions{MaxRounds: 5, Deadline: now.Add(24 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buyerPolicy := NegotiationPolicy{Role: "buyer", TargetPrice: 2.0, LimitPrice: 2.6, MaxQuantity: 80}
+	sellerPolicy := NegotiationPolicy{Role: "seller", TargetPrice: 3.0, LimitPrice: 2.4}
+
+	chain := []Block{opening}
+	signers := map[string]string{opening.Hash: seller}
+	signerOf := func(h string) string { return signers[h] }
+	offer := opening
+	for i := 0; i < 5; i++ {
+		agent, policy := buyerAgent, buyerPolicy
+		if offer.Refs["proposer"] == buyer {
+			agent, policy = sellerAgent, sellerPolicy
+		}
+		reply, signed, err := agent.Negotiate(offer, policy, now.Add(time.Duration(i)*time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if signed.AuthorHash != agent.AuthorHash {
+			t.Error("reply should be signed by the responding agent")
+		}
+		chain = append(chain, reply)
+		signers[reply.Hash] = signed.AuthorHash
+		if reply.Type != CounterProposalType {
+			break
+		}
+		offer = reply
+	}
+	last := chain[len(chain)-1]
+	if last.Type != AcceptanceType {
+		t.Fatalf("expected the agents to agree, ended with %s", last.Type)
+	}
+	if len(buyerAgent.Log.Entries()) == 0 {
+		t.Error("negotiation replies should be logged")
+	}
+
+	recorded := func(string) (time.Time, bool) { return now.Add(5 * time.Hour), true }
+	if _, err := Referee(last.Hash, negotiationResolver(chain...), signerOf, nil); err == nil {
+		t.Error("accepted a deadline on the acceptor's own word")
+	}
+	terms, err := Referee(last.Hash, negotiationResolver(chain...), signerOf, recorded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if terms.Type != AgreedTermsType || terms.Refs["proposal"] != opening.Hash {
+		t.Errorf("unexpected terms block %+v", terms)
+	}
+	price, _ := toFloat64(terms.State["price"])
+	if price > buyerPolicy.LimitPrice || price < sellerPolicy.LimitPrice {
+		t.Errorf("agreed price %v outside both limits", price)
+	}
+	if qty, _ := toFloat64(terms.State["quantity"]); qty != 80 {
+		t.Errorf("expected quantity capped at 80, got %v", qty)
+	}
+	again, _ := Referee(last.Hash, negotiationResolver(chain...), signerOf, recorded)
+	if again.Hash != terms.Hash {
+		t.Error("referee should be deterministic")
+	}
+}
+
+func TestNegotiationLimits(t *testing.T) {
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	opening, _ := Propose("b", "b", "s", Terms{Price: 1, Quantity: 10}, ProposalOptions{MaxRounds: 2, Deadline: now.Add(time.Hour)})
+
+	if _, err := Counter(opening, "b", Terms{Price: 1, Quantity: 10}, now); err == nil {
+		t.Error("proposer should not be able to counter its own offer")
+	}
+	if _, err := Accept(opening, "s", now.Add(2*time.Hour)); err == nil {
+		t.Error("expected deadline error")
+	}
+	counter, err := Counter(opening, "s", Terms{Price: 2, Quantity: 10}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Counter(counter, "b", Terms{Price: 1.5, Quantity: 10}, now); err == nil {
+		t.Error("expected round limit error")
+	}
+	reply, _ := NegotiationPolicy{Role: "buyer", TargetPrice: 1, LimitPrice: 1.2}.Respond(counter, "b", now)
+	if reply.Type != RejectionType {
+		t.Errorf("expected rejection when out of rounds, got %s", reply.Type)
+	}
+	if _, err := Referee(reply.Hash, negotiationResolver(opening, counter, reply), negotiationSigners(opening, counter, reply), nil); err == nil {
+		t.Error("referee should refuse a rejected negotiation")
+	}
+
+	// An acceptance by the wrong party is refused.
+	forged := Create(AcceptanceType, map[string]interface{}{"accepted_at": now.Format(time.RFC3339)},
+		map[string]interface{}{"accepts": counter.Hash, "acceptor": "s"})
+	recorded := func(string) (time.Time, bool) { return now, true }
+	if _, err := Referee(forged.Hash, negotiationResolver(opening, counter, forged), negotiationSigners(opening, counter, forged), recorded); err == nil {
+		t.Error("expected counterparty error")
+	}
+
+	// Refs naming a party are not enough: the block must be signed by it.
+	honest, _ := Accept(counter, "b", now)
+	byBuyer := func(string) string { return "b" }
+	if _, err := Referee(honest.Hash, negotiationResolver(opening, counter, honest), byBuyer, recorded); err == nil {
+		t.Error("accepted a counter-proposal signed by the buyer in the seller's name")
+	}
+	sellerAccepts := negotiationSigners(opening, counter, honest)
+	impostor := func(h string) string {
+		if h == honest.Hash {
+			return "s"
+		}
+		return sellerAccepts(h)
+	}
+	if _, err := Referee(honest.Hash, negotiationResolver(opening, counter, honest), impostor, recorded); err == nil {
+		t.Error("accepted an acceptance signed by someone other than the acceptor")
+	}
+	if _, err := Referee(honest.Hash, negotiationResolver(opening, counter, honest), nil, recorded); err == nil {
+		t.Error("refereed without signers")
+	}
+
+	// The opening proposer must be a party to the negotiation.
+	outsider := Create(ProposalType, opening.State, map[string]interface{}{"buyer": "b", "seller": "s", "proposer": "x"})
+	outsiderAccept := Create(AcceptanceType, map[string]interface{}{"round": 1}, map[string]interface{}{"accepts": outsider.Hash, "acceptor": "b"})
+	if _, err := Referee(outsiderAccept.Hash, negotiationResolver(outsider, outsiderAccept), negotiationSigners(outsider, outsiderAccept), recorded); err == nil {
+		t.Error("accepted a proposal from someone who is not a party")
+	}
+
+	// A counter-proposal cannot extend the proposal's deadline, and a late
+	// acceptance is refused whatever accepted_at it claims.
+	extended := counter
+	extended.State = map[string]interface{}{}
+	for k, v := range counter.State {
+		extended.State[k] = v
+	}
+	extended.State["deadline"] = now.Add(48 * time.Hour).Format(time.RFC3339)
+	extended = Create(extended.Type, extended.State, extended.Refs)
+	late, err := Accept(extended, "b", now.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Referee(late.Hash, negotiationResolver(opening, extended, late), negotiationSigners(opening, extended, late), func(string) (time.Time, bool) { return now.Add(24 * time.Hour), true }); err == nil {
+		t.Error("accepted a counter-proposal that moved the deadline")
+	}
+	backdated, _ := Accept(counter, "b", now)
+	if _, err := Referee(backdated.Hash, negotiationResolver(opening, counter, backdated), negotiationSigners(opening, counter, backdated), func(string) (time.Time, bool) { return now.Add(2 * time.Hour), true }); err == nil {
+		t.Error("accepted a late acceptance claiming an earlier accepted_at")
+	}
+	if _, err := Referee(backdated.Hash, negotiationResolver(opening, counter, backdated), negotiationSigners(opening, counter, backdated), recorded); err != nil {
+		t.Errorf("timely acceptance refused: %v", err)
+	}
+}
+
+func TestNegotiationPolicyFromState(t *testing.T) {
+	p, ok := NegotiationPolicyFromState(map[string]interface{}{
+		"negotiation": map[string]interface{}{"role": "seller", "target_price": 5.0, "limit_price": 4.0},
+	})
+	if !ok || p.Role != "seller" || p.LimitPrice != 4 {
+		t.Errorf("unexpected policy %+v", p)
+	}
+	if _, ok := NegotiationPolicyFromState(map[string]interface{}{}); ok {
+		t.Error("expected no policy")
+	}
+}
@@ -0,0 +1,181 @@
+package foodblock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// NoteType is a comment attached to a block. A note never alters its target:
+// refs.target names the block discussed, refs.author who wrote it and
+// refs.reply_to the note it answers. Replies keep the thread's target.
+const NoteType = "observe.note"
+
+// NoteOptions tune CreateNote.
+type NoteOptions struct {
+	ReplyTo string // the note answered
+	// Recipients are X25519 public keys. When set the text is encrypted to
+	// them as state._text, bound to the target, and visibility is "direct".
+	Recipients []string
+}
+
+// Note is one note in a thread with its replies.
+type Note struct {
+	Block     Block  `json:"block"`
+	Author    string `json:"author,omitempty"`
+	Text      string `json:"text,omitempty"` // empty when encrypted
+	Encrypted bool   `json:"encrypted,omitempty"`
+	Replies   []Note `json:"replies,omitempty"`
+}
+
+// CreateNote creates an observe.note about target.
+func CreateNote(target, author, text string, opts NoteOptions) (Block, error) {
+	if target == "" {
+		return Block{}, errors.New("FoodBlock: a note needs a target")
+	}
+	if strings.TrimSpace(text) == "" {
+		return Block{}, errors.New("FoodBlock: a note needs text")
+	}
+	state := map[string]interface{}{"text": text}
+	if len(opts.Recipients) > 0 {
+		env, err := EncryptWithOptions(text, opts.Recipients, EncryptOptions{Context: "state.text", Bind: target})
+		if err != nil {
+			return Block{}, err
+		}
+		data, _ := json.Marshal(env)
+		var sealed map[string]interface{}
+		json.Unmarshal(data, &sealed)
+		state = map[string]interface{}{"_text": sealed, "visibility": "direct"}
+	}
+	refs := map[string]interface{}{"target": target}
+	if author != "" {
+		refs["author"] = author
+	}
+	if opts.ReplyTo != "" {
+		refs["reply_to"] = opts.ReplyTo
+	}
+	return Create(NoteType, state, refs), nil
+}
+
+// ReplyNote creates a note answering parent, about the same target.
+func ReplyNote(parent Block, author, text string, opts NoteOptions) (Block, error) {
+	if parent.Type != NoteType {
+		return Block{}, fmt.Errorf("FoodBlock: %s is not a note", parent.Type)
+	}
+	target, _ := parent.Refs["target"].(string)
+	opts.ReplyTo = parent.Hash
+	return CreateNote(target, author, text, opts)
+}
+
+// DecryptNote reads an encrypted note's text with a recipient's keypair.
+func DecryptNote(note Block, privateKeyHex, publicKeyHex string) (string, error) {
+	if text, ok := note.State["text"].(string); ok {
+		return text, nil
+	}
+	data, err := json.Marshal(note.State["_text"])
+	if err != nil {
+		return "", err
+	}
+	var env EncryptionEnvelope
+	if err := json.Unmarshal(data, &env); err != nil || env.Ciphertext == "" {
+		return "", fmt.Errorf("FoodBlock: note %s has no text", note.Hash)
+	}
+	target, _ := note.Refs["target"].(string)
+	v, err := DecryptBound(&env, target, privateKeyHex, publicKeyHex)
+	if err != nil {
+		return "", err
+	}
+	text, _ := v.(string)
+	return text, nil
+}
+
+// NotesFor returns the threads of notes about a block: top-level notes with
+// their replies nested, each level in causal order, then clock order when
+// the store keeps clocks. Only the latest version of an edited note is
+// returned, and tombstoned notes are left out along with their replies.
+func NotesFor(hash string, store BlockStore) []Note {
+	var candidates []Block
+	for _, b := range store.ResolveForward(hash) {
+		if b.Type == NoteType && b.Refs["target"] == hash {
+			candidates = append(candidates, b)
+		}
+	}
+	var clockOf func(string) (Clock, bool)
+	if ci, ok := store.(ClockIndex); ok {
+		clockOf = ci.ClockOf
+	}
+
+	// Replies may name any version of the note they answer.
+	headOfVersion := map[string]string{}
+	var heads []Block
+	for _, b := range candidates {
+		if !headOf(b, store) {
+			continue
+		}
+		heads = append(heads, b)
+		for _, v := range Chain(b.Hash, store.Resolve, 0) {
+			headOfVersion[v.Hash] = b.Hash
+		}
+	}
+	heads = Order(heads, clockOf)
+
+	children := map[string][]Block{}
+	var roots []Block
+	for _, b := range heads {
+		parent, _ := b.Refs["reply_to"].(string)
+		if parent == "" {
+			roots = append(roots, b)
+			continue
+		}
+		if h, ok := headOfVersion[parent]; ok {
+			children[h] = append(children[h], b)
+		}
+	}
+
+	var thread func(b Block) Note
+	thread = func(b Block) Note {
+		n := Note{Block: b}
+		n.Author, _ = b.Refs["author"].(string)
+		n.Text, _ = b.State["text"].(string)
+		_, n.Encrypted = b.State["_text"]
+		for _, c := range children[b.Hash] {
+			n.Replies = append(n.Replies, thread(c))
+		}
+		return n
+	}
+	out := make([]Note, 0, len(roots))
+	for _, b := range roots {
+		out = append(out, thread(b))
+	}
+	return out
+}
+
+// ExplainWithNotes is Explain followed by the readable notes about the
+// block, one sentence each, replies after the note they answer.
+func ExplainWithNotes(hash string, store BlockStore, maxDepth int) string {
+	text := Explain(hash, store.Resolve, maxDepth)
+	var lines []string
+	var walk func(notes []Note, reply bool)
+	walk = func(notes []Note, reply bool) {
+		for _, n := range notes {
+			if n.Encrypted {
+				continue
+			}
+			who := "Note"
+			if reply {
+				who = "Reply"
+			}
+			if a := store.Resolve(n.Author); a != nil {
+				who += " from " + blockLabel(*a)
+			}
+			lines = append(lines, fmt.Sprintf("%s: %q.", who, n.Text))
+			walk(n.Replies, true)
+		}
+	}
+	walk(NotesFor(hash, store), false)
+	if len(lines) == 0 {
+		return text
+	}
+	return text + " " + strings.Join(lines, " ")
+}
@@ -0,0 +1,76 @@
+package foodblock
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNotesFor(t *testing.T) {
+	store := NewMemoryStore()
+	ann := Create("actor.producer", map[string]interface{}{"name": "Ann"}, nil)
+	bo := Create("actor.producer", map[string]interface{}{"name": "Bo"}, nil)
+	lot := Create("substance.product", map[string]interface{}{"name": "Rye Loaf"}, nil)
+	store.PutAll([]Block{ann, bo, lot})
+
+	first, _ := CreateNote(lot.Hash, ann.Hash, "Crust looks pale", NoteOptions{})
+	store.Put(first)
+	reply, err := ReplyNote(first, bo.Hash, "Oven ran cool", NoteOptions{})
+	if err != nil || reply.Refs["target"] != lot.Hash || reply.Refs["reply_to"] != first.Hash {
+		t.Fatalf("reply = %v, %v", reply.Refs, err)
+	}
+	store.Put(reply)
+	second, _ := CreateNote(lot.Hash, bo.Hash, "Batch re-tested", NoteOptions{})
+	store.Put(second)
+
+	// Editing a note keeps its replies; tombstoning removes it.
+	edited := Update(first.Hash, NoteType, map[string]interface{}{"text": "Crust looks pale on tray 2"}, first.Refs)
+	store.Put(edited)
+	store.Put(Tombstone(second.Hash, bo.Hash))
+
+	notes := NotesFor(lot.Hash, store)
+	if len(notes) != 1 || notes[0].Block.Hash != edited.Hash || notes[0].Author != ann.Hash {
+		t.Fatalf("notes = %+v", notes)
+	}
+	if len(notes[0].Replies) != 1 || notes[0].Replies[0].Text != "Oven ran cool" {
+		t.Errorf("replies = %+v", notes[0].Replies)
+	}
+	if store.Resolve(lot.Hash).Hash != lot.Hash || len(NotesFor(ann.Hash, store)) != 0 {
+		t.Error("notes must not alter or attach to anything but their target")
+	}
+
+	text := ExplainWithNotes(lot.Hash, store, 0)
+	if !strings.Contains(text, `Note from Ann: "Crust looks pale on tray 2". Reply from Bo: "Oven ran cool".`) {
+		t.Errorf("explain = %s", text)
+	}
+	if _, err := CreateNote(lot.Hash, ann.Hash, " ", NoteOptions{}); err == nil {
+		t.Error("expected an error for an empty note")
+	}
+}
+
+func TestEncryptedNote(t *testing.T) {
+	pub, priv, _ := GenerateEncryptionKeypair()
+	otherPub, otherPriv, _ := GenerateEncryptionKeypair()
+	store := NewMemoryStore()
+	lot := Create("substance.product", map[string]interface{}{"name": "Rye Loaf"}, nil)
+	note, err := CreateNote(lot.Hash, "", "Supplier under review", NoteOptions{Recipients: []string{pub}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, plain := note.State["text"]; plain || note.State["visibility"] != "direct" {
+		t.Fatalf("state = %v", note.State)
+	}
+	store.PutAll([]Block{lot, note})
+
+	if text, err := DecryptNote(note, priv, pub); err != nil || text != "Supplier under review" {
+		t.Errorf("decrypt = %q, %v", text, err)
+	}
+	if _, err := DecryptNote(note, otherPriv, otherPub); err == nil {
+		t.Error("expected a non-recipient to fail")
+	}
+	if notes := NotesFor(lot.Hash, store); len(notes) != 1 || !notes[0].Encrypted || notes[0].Text != "" {
+		t.Errorf("notes = %+v", notes)
+	}
+	if strings.Contains(ExplainWithNotes(lot.Hash, store, 0), "Note") {
+		t.Error("encrypted notes should stay out of the narrative")
+	}
+}
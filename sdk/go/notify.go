@@ -0,0 +1,279 @@
+package foodblock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// Notification is a rendered email or SMS message for an alert block.
+type Notification struct {
+	Channel   string // "email" or "sms"
+	To        string
+	Subject   string // email only
+	Body      string
+	Locale    string
+	AlertHash string
+}
+
+// NotificationTemplate holds text/template sources for one alert kind.
+// Templates see the localized block state plus .Type and .Hash.
+type NotificationTemplate struct {
+	Subject string
+	Body    string
+	SMS     string
+}
+
+// NotificationTemplates maps locale -> alert kind -> template. Alert kinds are
+// "recall", "excursion", "cert_expiry", "anomaly" and the generic "alert".
+var NotificationTemplates = map[string]map[string]NotificationTemplate{
+	"en": {
+		"recall": {
+			Subject: "RECALL: {{or .name .product \"product\"}}",
+			Body:    "A recall has been issued for {{or .name .product \"a product\"}}.\nReason: {{or .reason \"not specified\"}}\n{{with .action}}Action: {{.}}\n{{end}}Reference: fb:{{.Hash}}",
+			SMS:     "RECALL {{or .name .product \"product\"}}: {{or .reason \"see details\"}}. Ref fb:{{short .Hash}}",
+		},
+		"excursion": {
+			Subject: "Temperature excursion: {{or .location .name \"monitored unit\"}}",
+			Body:    "A reading outside the allowed range was recorded{{with .location}} at {{.}}{{end}}.\n{{with .value}}Value: {{.}}\n{{end}}{{with .message}}{{.}}\n{{end}}Reference: fb:{{.Hash}}",
+			SMS:     "ALERT excursion{{with .location}} at {{.}}{{end}}{{with .value}}: {{.}}{{end}}. Ref fb:{{short .Hash}}",
+		},
+		"cert_expiry": {
+			Subject: "Certification expiring: {{or .name \"certificate\"}}",
+			Body:    "{{or .name \"A certification\"}} expires{{with .valid_until}} on {{.}}{{end}}. Arrange renewal to stay compliant.\nReference: fb:{{.Hash}}",
+			SMS:     "{{or .name \"Certification\"}} expires{{with .valid_until}} {{.}}{{end}}. Ref fb:{{short .Hash}}",
+		},
+		"anomaly": {
+			Subject: "Unusual {{or .field \"value\"}} recorded",
+			Body:    "{{or .message \"An unusual jump was recorded.\"}}\n{{with .score}}Deviation: {{.}}\n{{end}}Reference: fb:{{.Hash}}",
+			SMS:     "ALERT {{or .message \"unusual reading\"}}. Ref fb:{{short .Hash}}",
+		},
+		"alert": {
+			Subject: "FoodBlock alert: {{or .name .message \"attention needed\"}}",
+			Body:    "{{or .message .name \"An alert was raised.\"}}\nReference: fb:{{.Hash}}",
+			SMS:     "ALERT: {{or .message .name \"attention needed\"}}. Ref fb:{{short .Hash}}",
+		},
+	},
+	"fr": {
+		"recall": {
+			Subject: "RAPPEL : {{or .name .product \"produit\"}}",
+			Body:    "Un rappel a été émis pour {{or .name .product \"un produit\"}}.\nMotif : {{or .reason \"non précisé\"}}\n{{with .action}}Action : {{.}}\n{{end}}Référence : fb:{{.Hash}}",
+			SMS:     "RAPPEL {{or .name .product \"produit\"}} : {{or .reason \"voir détails\"}}. Réf fb:{{short .Hash}}",
+		},
+		"alert": {
+			Subject: "Alerte FoodBlock : {{or .name .message \"action requise\"}}",
+			Body:    "{{or .message .name \"Une alerte a été émise.\"}}\nRéférence : fb:{{.Hash}}",
+			SMS:     "ALERTE : {{or .message .name \"action requise\"}}. Réf fb:{{short .Hash}}",
+		},
+	},
+}
+
+// smsMaxLen keeps SMS payloads to a single GSM segment.
+const smsMaxLen = 160
+
+// AlertKind classifies an alert block for template selection.
+func AlertKind(block Block) string {
+	if block.Type == "observe.recall" {
+		return "recall"
+	}
+	if kind, ok := block.State["kind"].(string); ok {
+		if _, known := NotificationTemplates["en"][kind]; known {
+			return kind
+		}
+	}
+	return "alert"
+}
+
+// RenderNotification renders an observe.alert, observe.recall or
+// observe.anomaly block for a channel ("email" or "sms") and locale, falling
+// back to English templates and localizing multi-language state fields.
+func RenderNotification(block Block, channel, locale, to string) (Notification, error) {
+	if block.Type != "observe.alert" && block.Type != "observe.recall" && block.Type != "observe.anomaly" {
+		return Notification{}, fmt.Errorf("FoodBlock: cannot notify for block type %s", block.Type)
+	}
+	if channel != "email" && channel != "sms" {
+		return Notification{}, fmt.Errorf("FoodBlock: unknown notification channel: %s", channel)
+	}
+	if locale == "" {
+		locale = "en"
+	}
+	kind := AlertKind(block)
+	tmpl, ok := NotificationTemplates[locale][kind]
+	if !ok {
+		tmpl, ok = NotificationTemplates[locale]["alert"]
+	}
+	if !ok {
+		locale = "en"
+		tmpl = NotificationTemplates["en"][kind]
+	}
+
+	data := make(map[string]interface{})
+	for k, v := range Localize(block, locale, "en").State {
+		data[k] = v
+	}
+	data["Type"] = block.Type
+	data["Hash"] = block.Hash
+
+	n := Notification{Channel: channel, To: to, Locale: locale, AlertHash: block.Hash}
+	var err error
+	if channel == "email" {
+		if n.Subject, err = renderTemplate(tmpl.Subject, data); err != nil {
+			return Notification{}, err
+		}
+		if n.Body, err = renderTemplate(tmpl.Body, data); err != nil {
+			return Notification{}, err
+		}
+		return n, nil
+	}
+	if n.Body, err = renderTemplate(tmpl.SMS, data); err != nil {
+		return Notification{}, err
+	}
+	if r := []rune(n.Body); len(r) > smsMaxLen {
+		n.Body = string(r[:smsMaxLen-1]) + "…"
+	}
+	return n, nil
+}
+
+func renderTemplate(src string, data map[string]interface{}) (string, error) {
+	t, err := template.New("n").Option("missingkey=zero").Funcs(template.FuncMap{
+		"short": func(h string) string {
+			if len(h) > 12 {
+				return h[:12]
+			}
+			return h
+		},
+	}).Parse(src)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// NotificationSender delivers a notification and returns the provider's message ID.
+type NotificationSender interface {
+	Send(ctx context.Context, n Notification) (string, error)
+}
+
+// SMTPSender sends email notifications through an SMTP relay.
+type SMTPSender struct {
+	Addr string // host:port
+	From string
+	Auth smtp.Auth
+}
+
+// Send delivers an email notification. SMTP has no message ID, so the alert hash is returned.
+func (s SMTPSender) Send(ctx context.Context, n Notification) (string, error) {
+	if n.Channel != "email" {
+		return "", errors.New("FoodBlock: SMTPSender only sends email")
+	}
+	msg, err := emailMessage(s.From, n)
+	if err != nil {
+		return "", err
+	}
+	if err := smtp.SendMail(s.Addr, s.Auth, s.From, []string{n.To}, msg); err != nil {
+		return "", err
+	}
+	return n.AlertHash, nil
+}
+
+// emailMessage builds the RFC 5322 message for n. The subject is rendered from
+// alert state, so control characters are dropped and it is MIME-encoded to
+// keep it from injecting headers; addresses containing them are rejected.
+func emailMessage(from string, n Notification) ([]byte, error) {
+	for _, addr := range []string{from, n.To} {
+		if strings.ContainsAny(addr, "\r\n") {
+			return nil, errors.New("FoodBlock: email address contains a line break")
+		}
+	}
+	subject := strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, n.Subject)
+	msg := "From: " + from + "\r\n" +
+		"To: " + n.To + "\r\n" +
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"X-FoodBlock-Ref: " + n.AlertHash + "\r\n\r\n" +
+		n.Body + "\r\n"
+	return []byte(msg), nil
+}
+
+// TwilioSender sends SMS through a Twilio-compatible Messages API.
+type TwilioSender struct {
+	AccountSID string
+	AuthToken  string
+	From       string
+	BaseURL    string // default https://api.twilio.com
+	Client     *http.Client
+}
+
+// Send posts an SMS and returns the provider message SID.
+func (s TwilioSender) Send(ctx context.Context, n Notification) (string, error) {
+	if n.Channel != "sms" {
+		return "", errors.New("FoodBlock: TwilioSender only sends sms")
+	}
+	base := s.BaseURL
+	if base == "" {
+		base = "https://api.twilio.com"
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	form := url.Values{"To": {n.To}, "From": {s.From}, "Body": {n.Body}}
+	endpoint := base + "/2010-04-01/Accounts/" + url.PathEscape(s.AccountSID) + "/Messages.json"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(s.AccountSID, s.AuthToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		SID     string `json:"sid"`
+		Message string `json:"message"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("FoodBlock: sms provider returned %d: %s", resp.StatusCode, body.Message)
+	}
+	return body.SID, nil
+}
+
+// Deliver sends a notification and returns an observe.delivery block recording
+// the outcome. The recipient address is stored only as a SHA-256 hash.
+func Deliver(ctx context.Context, sender NotificationSender, n Notification) (Block, error) {
+	providerID, err := sender.Send(ctx, n)
+	state := map[string]interface{}{
+		"channel":        n.Channel,
+		"recipient_hash": Sha256Hex(strings.ToLower(strings.TrimSpace(n.To))),
+		"locale":         n.Locale,
+		"status":         "sent",
+	}
+	if err != nil {
+		state["status"] = "failed"
+		state["error"] = err.Error()
+	} else if providerID != "" {
+		state["provider_id"] = providerID
+	}
+	return Create("observe.delivery", state, map[string]interface{}{"subject": n.AlertHash}), err
+}
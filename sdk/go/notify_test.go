@@ -0,0 +1,111 @@
+package foodblock
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRenderNotificationRecall(t *testing.T) {
+	recall := Create("observe.recall", map[string]interface{}{
+		"name":   map[string]interface{}{"en": "Oat Milk 1L", "fr": "Lait d'avoine 1L"},
+		"reason": "undeclared nuts",
+	}, nil)
+
+	email, err := RenderNotification(recall, "email", "en", "ops@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if email.Subject != "RECALL: Oat Milk 1L" || !strings.Contains(email.Body, "undeclared nuts") {
+		t.Errorf("unexpected email: %+v", email)
+	}
+
+	sms, _ := RenderNotification(recall, "sms", "fr", "+33600000000")
+	if !strings.HasPrefix(sms.Body, "RAPPEL Lait d'avoine 1L") || len([]rune(sms.Body)) > smsMaxLen {
+		t.Errorf("unexpected French SMS: %q", sms.Body)
+	}
+}
+
+func TestEmailMessageHeaderInjection(t *testing.T) {
+	recall := Create("observe.recall", map[string]interface{}{"name": "Oat Milk\r\nBcc: victim@example.com"}, nil)
+	email, err := RenderNotification(recall, "email", "en", "ops@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := emailMessage("alerts@example.com", email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers := string(msg[:strings.Index(string(msg), "\r\n\r\n")])
+	if strings.Contains(headers, "\r\nBcc:") || !strings.Contains(headers, "Subject: RECALL: Oat MilkBcc: victim@example.com\r\n") {
+		t.Errorf("subject not sanitised: %q", headers)
+	}
+	if msg, _ := emailMessage("alerts@example.com", Notification{Channel: "email", Subject: "Rappel : crème fraîche"}); !strings.Contains(string(msg), "Subject: =?utf-8?q?") {
+		t.Errorf("non-ASCII subject not encoded: %q", msg)
+	}
+	email.To = "ops@example.com\r\nBcc: victim@example.com"
+	if _, err := emailMessage("alerts@example.com", email); err == nil {
+		t.Error("accepted a recipient with a line break")
+	}
+}
+
+func TestRenderNotificationFallsBackToEnglish(t *testing.T) {
+	alert := Create("observe.alert", map[string]interface{}{"kind": "excursion", "location": "Walk-in cooler", "value": "9C"}, nil)
+	n, err := RenderNotification(alert, "email", "de", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Locale != "en" || n.Subject != "Temperature excursion: Walk-in cooler" {
+		t.Errorf("expected English excursion template, got %+v", n)
+	}
+	if _, err := RenderNotification(Create("substance.product", nil, nil), "email", "en", ""); err == nil {
+		t.Error("expected non-alert blocks to be rejected")
+	}
+}
+
+func TestTwilioSenderAndDelivery(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _, _ := r.BasicAuth()
+		if user != "AC123" || r.FormValue("Body") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"message":"bad request"}`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"sid":"SM42"}`))
+	}))
+	defer server.Close()
+
+	alert := Create("observe.alert", map[string]interface{}{"message": "Freezer door open"}, nil)
+	n, _ := RenderNotification(alert, "sms", "en", "+447700900000")
+	sender := TwilioSender{AccountSID: "AC123", AuthToken: "secret", From: "+15005550006", BaseURL: server.URL}
+
+	delivery, err := Deliver(context.Background(), sender, n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivery.Type != "observe.delivery" || delivery.State["provider_id"] != "SM42" || delivery.Refs["subject"] != alert.Hash {
+		t.Errorf("unexpected delivery block: %+v", delivery)
+	}
+	if strings.Contains(Canonical(delivery.Type, delivery.State, delivery.Refs), "+447700900000") {
+		t.Error("recipient must not be stored in clear")
+	}
+}
+
+type failingSender struct{}
+
+func (failingSender) Send(ctx context.Context, n Notification) (string, error) {
+	return "", errors.New("relay down")
+}
+
+func TestDeliverRecordsFailure(t *testing.T) {
+	alert := Create("observe.alert", map[string]interface{}{"message": "test"}, nil)
+	n, _ := RenderNotification(alert, "email", "en", "a@b.c")
+	delivery, err := Deliver(context.Background(), failingSender{}, n)
+	if err == nil || delivery.State["status"] != "failed" {
+		t.Errorf("expected failed delivery block, got %v %v", delivery.State, err)
+	}
+}
@@ -0,0 +1,282 @@
+package foodblock
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Supplier onboarding block types. A venue publishes its checklist as an
+// observe.onboarding_checklist; each supplier it onboards gets an
+// observe.onboarding referencing the venue, the supplier and the checklist,
+// with evidence attached as refs (insurance, bank_details, certifications).
+const (
+	ChecklistType  = "observe.onboarding_checklist"
+	OnboardingType = "observe.onboarding"
+)
+
+// Onboarding statuses. Approved onboardings become lapsed when evidence
+// expires or the supplier's trust falls below the minimum; rejected and
+// suspended are set by the venue and are never derived.
+const (
+	OnboardingPending   = "pending"
+	OnboardingInReview  = "in_review"
+	OnboardingApproved  = "approved"
+	OnboardingLapsed    = "lapsed"
+	OnboardingRejected  = "rejected"
+	OnboardingSuspended = "suspended"
+)
+
+// OnboardingChecklist is what a venue requires before buying from a supplier.
+type OnboardingChecklist struct {
+	RequiredCerts      []string `json:"required_certs,omitempty"` // CertSchemes IDs or scheme kinds (e.g. "organic")
+	RequireInsurance   bool     `json:"require_insurance,omitempty"`
+	RequireBankDetails bool     `json:"require_bank_details,omitempty"`
+	MinTrustScore      float64  `json:"min_trust_score,omitempty"` // under the default trust weights
+}
+
+// CreateOnboardingChecklist publishes a venue's checklist.
+func CreateOnboardingChecklist(venue, name string, c OnboardingChecklist) Block {
+	certs := make([]interface{}, len(c.RequiredCerts))
+	for i, s := range c.RequiredCerts {
+		certs[i] = s
+	}
+	return Create(ChecklistType, map[string]interface{}{
+		"name":                 name,
+		"required_certs":       certs,
+		"require_insurance":    c.RequireInsurance,
+		"require_bank_details": c.RequireBankDetails,
+		"min_trust_score":      c.MinTrustScore,
+	}, map[string]interface{}{"venue": venue})
+}
+
+// ChecklistFromBlock reads an observe.onboarding_checklist.
+func ChecklistFromBlock(b Block) OnboardingChecklist {
+	c := OnboardingChecklist{}
+	if list, ok := b.State["required_certs"].([]interface{}); ok {
+		for _, v := range list {
+			if s, ok := v.(string); ok {
+				c.RequiredCerts = append(c.RequiredCerts, s)
+			}
+		}
+	}
+	c.RequireInsurance, _ = b.State["require_insurance"].(bool)
+	c.RequireBankDetails, _ = b.State["require_bank_details"].(bool)
+	c.MinTrustScore, _ = toFloat64(b.State["min_trust_score"])
+	return c
+}
+
+// StartOnboarding opens a supplier's onboarding against a venue's checklist.
+func StartOnboarding(venue, supplier, checklist string) Block {
+	return Create(OnboardingType, map[string]interface{}{
+		"instance_id": "onboarding-" + venue + "-" + supplier,
+		"status":      OnboardingPending,
+	}, map[string]interface{}{"venue": venue, "supplier": supplier, "checklist": checklist})
+}
+
+// AddOnboardingEvidence attaches evidence to an onboarding. Item is
+// "insurance", "bank_details" or "certifications"; certifications accumulate.
+func AddOnboardingEvidence(onboarding Block, item, evidence string) (Block, error) {
+	refs := onboardingRefs(onboarding)
+	switch item {
+	case "insurance", "bank_details":
+		refs[item] = evidence
+	case "certifications":
+		certs := flattenRefValues(map[string]interface{}{"c": refs["certifications"]})
+		if !containsStr(certs, evidence) {
+			certs = append(certs, evidence)
+		}
+		refs["certifications"] = refList(certs)
+	default:
+		return Block{}, fmt.Errorf("FoodBlock: unknown onboarding item: %s", item)
+	}
+	return MergeUpdate(onboarding, nil, refs), nil
+}
+
+// ChecklistItem is one checklist requirement and whether it is met.
+type ChecklistItem struct {
+	Name     string `json:"name"`
+	Met      bool   `json:"met"`
+	Evidence string `json:"evidence,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+// OnboardingStatus is the derived state of a supplier's onboarding.
+type OnboardingStatus struct {
+	Onboarding string          `json:"onboarding"`
+	Venue      string          `json:"venue"`
+	Supplier   string          `json:"supplier"`
+	Status     string          `json:"status"`
+	Items      []ChecklistItem `json:"items"`
+	Missing    []string        `json:"missing"`
+	TrustScore float64         `json:"trust_score"`
+}
+
+// DeriveOnboardingStatus evaluates an onboarding against its checklist.
+// Certifications count when they are about the supplier, pass their scheme's
+// rules and have not expired, whether or not they were attached as evidence.
+// Insurance and bank details must be attached; insurance must not have passed
+// its valid_until. A venue's rejected or suspended status is kept as is.
+func DeriveOnboardingStatus(onboarding Block, blocks []Block, now time.Time) (OnboardingStatus, error) {
+	if onboarding.Type != OnboardingType {
+		return OnboardingStatus{}, fmt.Errorf("FoodBlock: %s is not an onboarding", onboarding.Hash)
+	}
+	byHash := make(map[string]*Block, len(blocks))
+	for i := range blocks {
+		byHash[blocks[i].Hash] = &blocks[i]
+	}
+	venue, _ := onboarding.Refs["venue"].(string)
+	supplier, _ := onboarding.Refs["supplier"].(string)
+	checklistHash, _ := onboarding.Refs["checklist"].(string)
+	cb, ok := byHash[checklistHash]
+	if !ok {
+		return OnboardingStatus{}, errors.New("FoodBlock: onboarding checklist not found")
+	}
+	checklist := ChecklistFromBlock(*cb)
+	st := OnboardingStatus{Onboarding: onboarding.Hash, Venue: venue, Supplier: supplier, Items: []ChecklistItem{}, Missing: []string{}}
+
+	attached := flattenRefValues(map[string]interface{}{"c": onboarding.Refs["certifications"]})
+	for _, req := range checklist.RequiredCerts {
+		item := ChecklistItem{Name: "cert:" + req, Detail: "no valid certification"}
+		for _, b := range blocks {
+			if b.Type != "observe.certification" || b.Refs["subject"] != supplier || !certMatches(b, req) {
+				continue
+			}
+			if len(ValidateCertification(b)) > 0 {
+				continue
+			}
+			if until, ok, _ := certDate(b, "valid_until"); ok && now.After(until) {
+				item.Detail = "certification expired " + until.Format("2006-01-02")
+				continue
+			}
+			item.Met, item.Evidence, item.Detail = true, b.Hash, ""
+			if containsStr(attached, b.Hash) {
+				break
+			}
+		}
+		st.Items = append(st.Items, item)
+	}
+	if checklist.RequireInsurance {
+		item := ChecklistItem{Name: "insurance", Detail: "no insurance attached"}
+		if h, ok := onboarding.Refs["insurance"].(string); ok {
+			if b, found := byHash[h]; !found {
+				item.Detail = "insurance evidence not found"
+			} else if until, ok, _ := certDate(*b, "valid_until"); ok && now.After(until) {
+				item.Evidence, item.Detail = h, "insurance expired "+until.Format("2006-01-02")
+			} else {
+				item.Met, item.Evidence, item.Detail = true, h, ""
+			}
+		}
+		st.Items = append(st.Items, item)
+	}
+	if checklist.RequireBankDetails {
+		item := ChecklistItem{Name: "bank_details", Detail: "no bank details attached"}
+		if h, ok := onboarding.Refs["bank_details"].(string); ok && byHash[h] != nil {
+			item.Met, item.Evidence, item.Detail = true, h, ""
+		}
+		st.Items = append(st.Items, item)
+	}
+	if checklist.MinTrustScore > 0 {
+		tb := make([]TrustBlock, len(blocks))
+		for i, b := range blocks {
+			tb[i] = TrustBlock{Block: b}
+		}
+		st.TrustScore = ComputeTrust(supplier, tb, nil).Score
+		item := ChecklistItem{Name: "trust_score", Met: st.TrustScore >= checklist.MinTrustScore}
+		if !item.Met {
+			item.Detail = fmt.Sprintf("trust score %.2f below %.2f", st.TrustScore, checklist.MinTrustScore)
+		}
+		st.Items = append(st.Items, item)
+	}
+
+	met, evidenced := 0, 0
+	for _, it := range st.Items {
+		if it.Met {
+			met++
+		} else {
+			st.Missing = append(st.Missing, it.Name)
+		}
+		if it.Evidence != "" {
+			evidenced++
+		}
+	}
+	previous, _ := onboarding.State["status"].(string)
+	switch {
+	case previous == OnboardingRejected || previous == OnboardingSuspended:
+		st.Status = previous
+	case met == len(st.Items):
+		st.Status = OnboardingApproved
+	case previous == OnboardingApproved || previous == OnboardingLapsed:
+		st.Status = OnboardingLapsed
+	case evidenced > 0 || met > 0:
+		st.Status = OnboardingInReview
+	default:
+		st.Status = OnboardingPending
+	}
+	return st, nil
+}
+
+func certMatches(cert Block, req string) bool {
+	id, _ := cert.State["scheme"].(string)
+	if id == req {
+		return true
+	}
+	s, ok := CertSchemes[id]
+	return ok && s.Kind == req
+}
+
+// RecordOnboardingStatus updates the onboarding with a derived status, or
+// returns false when it is unchanged.
+func RecordOnboardingStatus(onboarding Block, st OnboardingStatus, now time.Time) (Block, bool) {
+	if onboarding.State["status"] == st.Status {
+		return Block{}, false
+	}
+	missing := make([]interface{}, len(st.Missing))
+	for i, m := range st.Missing {
+		missing[i] = m
+	}
+	return MergeUpdate(onboarding, map[string]interface{}{
+		"status":          st.Status,
+		"previous_status": onboarding.State["status"],
+		"missing":         missing,
+		"evaluated_at":    now.UTC().Format(time.RFC3339),
+	}, onboardingRefs(onboarding)), true
+}
+
+// SetOnboardingStatus records a venue decision such as rejected or suspended.
+// Setting approved or pending hands the onboarding back to derivation.
+func SetOnboardingStatus(onboarding Block, status, reason string) Block {
+	changes := map[string]interface{}{"status": status, "previous_status": onboarding.State["status"]}
+	if reason != "" {
+		changes["reason"] = reason
+	}
+	return MergeUpdate(onboarding, changes, onboardingRefs(onboarding))
+}
+
+func onboardingRefs(b Block) map[string]interface{} {
+	refs := map[string]interface{}{}
+	for k, v := range b.Refs {
+		if k != "updates" {
+			refs[k] = v
+		}
+	}
+	return refs
+}
+
+// ApprovedSuppliers returns the suppliers a venue may buy from: those whose
+// latest onboarding derives to approved at now, sorted by hash.
+func ApprovedSuppliers(venue string, blocks []Block, now time.Time) []string {
+	var out []string
+	for _, b := range FilterBlocks(blocks, QueryParams{Type: OnboardingType, HeadsOnly: true}) {
+		if b.Refs["venue"] != venue {
+			continue
+		}
+		st, err := DeriveOnboardingStatus(b, blocks, now)
+		if err == nil && st.Status == OnboardingApproved && !containsStr(out, st.Supplier) {
+			out = append(out, st.Supplier)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,84 @@
+package foodblock
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSupplierOnboarding(t *testing.T) {
+	now := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	venue := Create("actor.venue", map[string]interface{}{"name": "Corner Bistro"}, nil)
+	farm := Create("actor.producer", map[string]interface{}{"name": "Green Acres"}, nil)
+	checklist := CreateOnboardingChecklist(venue.Hash, "Produce suppliers", OnboardingChecklist{
+		RequiredCerts: []string{"organic"}, RequireInsurance: true, RequireBankDetails: true, MinTrustScore: 1,
+	})
+	onb := StartOnboarding(venue.Hash, farm.Hash, checklist.Hash)
+	blocks := []Block{venue, farm, checklist, onb}
+
+	st, err := DeriveOnboardingStatus(onb, blocks, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Status != OnboardingPending || len(st.Missing) != 4 {
+		t.Fatalf("expected pending with 4 missing items, got %+v", st)
+	}
+
+	cert, err := CreateCertification(farm.Hash, "soil-assoc", "soil_association", map[string]interface{}{
+		"certificate_id": "P4821", "valid_from": "2026-01-01", "valid_until": "2026-12-31",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	insurance := Create("observe.insurance", map[string]interface{}{"name": "Public liability", "valid_until": "2026-06-30"}, map[string]interface{}{"subject": farm.Hash})
+	bank := Create("observe.bank_details", map[string]interface{}{"name": "Settlement account"}, map[string]interface{}{"subject": farm.Hash})
+	onb, _ = AddOnboardingEvidence(onb, "insurance", insurance.Hash)
+	onb, _ = AddOnboardingEvidence(onb, "bank_details", bank.Hash)
+	onb, _ = AddOnboardingEvidence(onb, "certifications", cert.Hash)
+	if _, err := AddOnboardingEvidence(onb, "references", bank.Hash); err == nil {
+		t.Error("expected an unknown item to be rejected")
+	}
+	blocks = append(blocks, cert, insurance, bank, onb)
+
+	st, _ = DeriveOnboardingStatus(onb, blocks, now)
+	if st.Status != OnboardingApproved || len(st.Missing) != 0 {
+		t.Fatalf("expected approved, got %+v", st)
+	}
+	recorded, changed := RecordOnboardingStatus(onb, st, now)
+	if !changed || recorded.State["status"] != OnboardingApproved || recorded.Refs["insurance"] != insurance.Hash {
+		t.Fatalf("expected the approval to be recorded, got %v %v", recorded.State, recorded.Refs)
+	}
+	blocks = append(blocks, recorded)
+	if got := ApprovedSuppliers(venue.Hash, blocks, now); len(got) != 1 || got[0] != farm.Hash {
+		t.Errorf("expected the farm to be approved, got %v", got)
+	}
+
+	// Insurance runs out: the approval lapses and the farm drops off the list.
+	later := time.Date(2026, 7, 15, 0, 0, 0, 0, time.UTC)
+	st, _ = DeriveOnboardingStatus(recorded, blocks, later)
+	if st.Status != OnboardingLapsed || len(st.Missing) != 1 || st.Missing[0] != "insurance" {
+		t.Errorf("expected lapsed on insurance, got %+v", st)
+	}
+	if got := ApprovedSuppliers(venue.Hash, blocks, later); len(got) != 0 {
+		t.Errorf("expected no approved suppliers, got %v", got)
+	}
+
+	suspended := SetOnboardingStatus(recorded, OnboardingSuspended, "late deliveries")
+	st, _ = DeriveOnboardingStatus(suspended, append(blocks, suspended), now)
+	if st.Status != OnboardingSuspended {
+		t.Errorf("expected suspension to stick, got %s", st.Status)
+	}
+}
+
+func TestOnboardingTrustMinimum(t *testing.T) {
+	venue := Create("actor.venue", map[string]interface{}{"name": "Canteen"}, nil)
+	checklist := CreateOnboardingChecklist(venue.Hash, "Strict", OnboardingChecklist{MinTrustScore: 50})
+	onb := StartOnboarding(venue.Hash, "new-supplier", checklist.Hash)
+	st, err := DeriveOnboardingStatus(onb, []Block{venue, checklist, onb}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Status != OnboardingPending || !strings.Contains(st.Items[0].Detail, "below 50.00") {
+		t.Errorf("expected the trust minimum to fail, got %+v", st)
+	}
+}
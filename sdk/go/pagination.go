@@ -0,0 +1,164 @@
+package foodblock
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Listing limits, matching the reference server.
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 1000
+)
+
+// List orderings.
+const (
+	OrderSequence = "seq"  // insertion order; new blocks always sort after existing cursors
+	OrderHash     = "hash" // lexicographic hash order
+)
+
+// ListOptions filters and pages a block listing.
+type ListOptions struct {
+	Type   string // exact type or a prefix: "substance" matches "substance.product"
+	Author string
+	Since  time.Time // only blocks stored at or after Since
+	Order  string    // OrderSequence (default) or OrderHash
+	Limit  int
+	Cursor string // opaque cursor from a previous page
+}
+
+// ListPage is one page of a listing. Next is empty on the last page.
+type ListPage struct {
+	Blocks []Block `json:"blocks"`
+	Count  int     `json:"count"`
+	Next   string  `json:"next_cursor,omitempty"`
+}
+
+// Lister is implemented by stores that support cursor pagination.
+type Lister interface {
+	List(opts ListOptions) (ListPage, error)
+}
+
+type cursorData struct {
+	Order string `json:"o"`
+	Seq   int    `json:"s,omitempty"`
+	Hash  string `json:"h,omitempty"`
+}
+
+func encodeCursor(c cursorData) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (cursorData, error) {
+	var c cursorData
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(data, &c)
+	}
+	if err != nil || (c.Order != OrderSequence && c.Order != OrderHash) {
+		return c, errors.New("FoodBlock: invalid cursor")
+	}
+	return c, nil
+}
+
+// ClampLimit applies the default and maximum page size.
+func ClampLimit(n int) int {
+	if n <= 0 {
+		return DefaultListLimit
+	}
+	if n > MaxListLimit {
+		return MaxListLimit
+	}
+	return n
+}
+
+// List returns a page of blocks. In sequence order a cursor is a position in
+// the store's insertion sequence, so blocks written while a client pages are
+// neither skipped nor repeated: they appear after every block that existed
+// when paging began. In hash order pages never repeat a block, but blocks
+// written behind the cursor are only seen by a new listing.
+func (s *MemoryStore) List(opts ListOptions) (ListPage, error) {
+	order := opts.Order
+	if order == "" {
+		order = OrderSequence
+	}
+	if order != OrderSequence && order != OrderHash {
+		return ListPage{}, errors.New("FoodBlock: unknown list order: " + order)
+	}
+	var after cursorData
+	if opts.Cursor != "" {
+		c, err := decodeCursor(opts.Cursor)
+		if err != nil {
+			return ListPage{}, err
+		}
+		if c.Order != order {
+			return ListPage{}, errors.New("FoodBlock: cursor was issued for a different order")
+		}
+		after = c
+	}
+	limit := ClampLimit(opts.Limit)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var hashes []string
+	if order == OrderSequence {
+		start := sort.Search(len(s.order), func(i int) bool { return s.pos[s.order[i]] > after.Seq })
+		hashes = s.order[start:]
+	} else {
+		hashes = make([]string, 0, len(s.blocks))
+		for h := range s.blocks {
+			if h > after.Hash {
+				hashes = append(hashes, h)
+			}
+		}
+		sort.Strings(hashes)
+	}
+
+	page := ListPage{Blocks: []Block{}}
+	for _, h := range hashes {
+		if !s.listMatches(h, opts) {
+			continue
+		}
+		if len(page.Blocks) == limit {
+			last := page.Blocks[len(page.Blocks)-1].Hash
+			if order == OrderSequence {
+				page.Next = encodeCursor(cursorData{Order: order, Seq: s.pos[last]})
+			} else {
+				page.Next = encodeCursor(cursorData{Order: order, Hash: last})
+			}
+			break
+		}
+		page.Blocks = append(page.Blocks, s.blocks[h])
+	}
+	page.Count = len(page.Blocks)
+	return page, nil
+}
+
+func (s *MemoryStore) listMatches(hash string, opts ListOptions) bool {
+	b := s.blocks[hash]
+	if opts.Type != "" && !typeMatchesPrefix(b.Type, opts.Type) {
+		return false
+	}
+	if opts.Author != "" && s.authorOf(hash) != opts.Author {
+		return false
+	}
+	if !opts.Since.IsZero() && s.stored[hash].Before(opts.Since) {
+		return false
+	}
+	return true
+}
+
+// typeMatchesPrefix matches a type exactly, as a dotted prefix, or as a
+// "prefix.*" pattern.
+func typeMatchesPrefix(typ, filter string) bool {
+	if strings.HasSuffix(filter, ".*") {
+		return matchesType(typ, filter)
+	}
+	return typ == filter || strings.HasPrefix(typ, filter+".")
+}
@@ -0,0 +1,137 @@
+package foodblock
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestListSequenceWithConcurrentWrites(t *testing.T) {
+	store := NewMemoryStore()
+	populate(store, "initial", 25)
+
+	seen := map[string]int{}
+	cursor := ""
+	for pages := 0; ; pages++ {
+		page, err := store.List(ListOptions{Limit: 10, Cursor: cursor})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, b := range page.Blocks {
+			seen[b.Hash]++
+		}
+		if pages == 0 {
+			// Writes during paging land after the cursor.
+			populate(store, "during", 5)
+		}
+		if page.Next == "" {
+			break
+		}
+		cursor = page.Next
+	}
+	if len(seen) != 30 {
+		t.Errorf("saw %d blocks, want 30", len(seen))
+	}
+	for h, n := range seen {
+		if n != 1 {
+			t.Errorf("block %s returned %d times", h[:8], n)
+		}
+	}
+}
+
+func TestListHashOrderAndFilters(t *testing.T) {
+	store := NewMemoryStore()
+	populate(store, "p", 12)
+	venue := Create("actor.venue", map[string]interface{}{"name": "Cafe"}, nil)
+	review := Create("observe.review", map[string]interface{}{"rating": 5}, map[string]interface{}{"author": venue.Hash})
+	store.Put(venue)
+	store.Put(review)
+	_, priv := GenerateKeypair()
+	signed := Sign(Create("substance.product", map[string]interface{}{"name": "Signed"}, nil), venue.Hash, priv)
+	store.PutSigned(signed)
+
+	var hashes []string
+	cursor := ""
+	for {
+		page, err := store.List(ListOptions{Order: OrderHash, Limit: 4, Cursor: cursor})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, b := range page.Blocks {
+			hashes = append(hashes, b.Hash)
+		}
+		if cursor = page.Next; cursor == "" {
+			break
+		}
+	}
+	if len(hashes) != 15 || !sort.StringsAreSorted(hashes) {
+		t.Errorf("hash order listing = %d blocks, sorted %v", len(hashes), sort.StringsAreSorted(hashes))
+	}
+
+	page, _ := store.List(ListOptions{Type: "substance"})
+	if page.Count != 13 {
+		t.Errorf("type prefix count = %d, want 13", page.Count)
+	}
+	page, _ = store.List(ListOptions{Author: venue.Hash})
+	if page.Count != 2 {
+		t.Errorf("author count = %d, want 2 (refs.author and signer)", page.Count)
+	}
+	page, _ = store.List(ListOptions{Since: time.Now().Add(time.Hour)})
+	if page.Count != 0 {
+		t.Errorf("future since returned %d blocks", page.Count)
+	}
+	if _, err := store.List(ListOptions{Order: OrderHash, Cursor: encodeCursor(cursorData{Order: OrderSequence, Seq: 3})}); err == nil {
+		t.Error("expected mismatched cursor to be rejected")
+	}
+	if _, err := store.List(ListOptions{Cursor: "not-a-cursor"}); err == nil {
+		t.Error("expected invalid cursor to be rejected")
+	}
+}
+
+func TestFederationHandler(t *testing.T) {
+	store := NewMemoryStore()
+	populate(store, "p", 3)
+	srv := httptest.NewServer(FederationHandler(store, WellKnownInfo{Name: "Test"}))
+	defer srv.Close()
+
+	var doc WellKnownDoc
+	getJSON(t, srv.URL+"/.well-known/foodblock", &doc)
+	if doc.Count != 3 || len(doc.Types) != 1 {
+		t.Errorf("well-known = %+v", doc)
+	}
+	var page ListPage
+	getJSON(t, srv.URL+"/blocks?limit=2", &page)
+	if page.Count != 2 || page.Next == "" {
+		t.Fatalf("page = %+v", page)
+	}
+	var second ListPage
+	getJSON(t, srv.URL+"/blocks?limit=2&cursor="+page.Next, &second)
+	if second.Count != 1 || second.Next != "" {
+		t.Errorf("second page = %+v", second)
+	}
+	page = second
+	var b Block
+	getJSON(t, fmt.Sprintf("%s/blocks/%s", srv.URL, page.Blocks[0].Hash), &b)
+	if b.Hash != page.Blocks[0].Hash {
+		t.Error("block lookup failed")
+	}
+	if resp, _ := http.Get(srv.URL + "/blocks/missing"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("missing block status = %d", resp.StatusCode)
+	}
+}
+
+func getJSON(t *testing.T, url string, v interface{}) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+}
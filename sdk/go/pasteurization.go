@@ -0,0 +1,218 @@
+package foodblock
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PasteurizationStandard is a legal minimum time/temperature combination.
+type PasteurizationStandard struct {
+	Name     string
+	MinTempC float64
+	MinHold  time.Duration
+}
+
+// PasteurizationStandards are keyed by a process's state.method. Processes
+// without a method are checked against "htst".
+var PasteurizationStandards = map[string]PasteurizationStandard{
+	"htst": {Name: "HTST", MinTempC: 72, MinHold: 15 * time.Second},
+	"ltlt": {Name: "LTLT (batch)", MinTempC: 63, MinHold: 30 * time.Minute},
+	"uht":  {Name: "UHT", MinTempC: 135, MinHold: time.Second},
+}
+
+// PasteurizationCheck is the verdict on one pasteurization process.
+type PasteurizationCheck struct {
+	Process     string        `json:"process"`
+	Method      string        `json:"method"`
+	Standard    string        `json:"standard"`
+	Readings    int           `json:"readings"`
+	MaxTempC    float64       `json:"max_temp_c"`
+	HoldSeconds float64       `json:"hold_seconds"` // longest run at or above the minimum
+	Hold        time.Duration `json:"-"`
+	Passed      bool          `json:"passed"`
+	Reason      string        `json:"reason,omitempty"`
+}
+
+// IsPasteurization reports whether a block is a pasteurization process:
+// a transform.* with process_type pasteurization (or pasteurisation,
+// pasteurizing, pasteurising).
+func IsPasteurization(b Block) bool {
+	if !strings.HasPrefix(b.Type, "transform.") {
+		return false
+	}
+	pt, _ := b.State["process_type"].(string)
+	return strings.HasPrefix(strings.ToLower(pt), "pasteuri")
+}
+
+// VerifyPasteurization checks a pasteurization process against its standard
+// using the observe.reading blocks about it (refs.subject). The hold time is
+// the longest span of consecutive timestamped readings at or above the
+// minimum temperature; a qualifying reading may also carry state.hold_seconds
+// from a chart recorder. Temperatures the process declares on itself are not
+// evidence.
+func VerifyPasteurization(processHash string, store BlockStore) (PasteurizationCheck, error) {
+	process := store.Resolve(processHash)
+	if process == nil {
+		return PasteurizationCheck{}, fmt.Errorf("FoodBlock: process not found: %s", processHash)
+	}
+	if !IsPasteurization(*process) {
+		return PasteurizationCheck{}, fmt.Errorf("FoodBlock: %s is not a pasteurization process", processHash)
+	}
+	method, _ := process.State["method"].(string)
+	method = strings.ToLower(method)
+	if method == "" {
+		method = "htst"
+	}
+	std, ok := PasteurizationStandards[method]
+	if !ok {
+		return PasteurizationCheck{}, fmt.Errorf("FoodBlock: unknown pasteurization method: %s", method)
+	}
+	check := PasteurizationCheck{Process: processHash, Method: method, Standard: std.Name}
+
+	type point struct {
+		at   time.Time
+		temp float64
+		hold time.Duration
+	}
+	var points []point
+	for _, v := range Chain(processHash, store.Resolve, 0) {
+		for _, b := range store.ResolveForward(v.Hash) {
+			if b.Type != "observe.reading" || b.Refs["subject"] != v.Hash {
+				continue
+			}
+			temp, ok := readingTemperature(b)
+			if !ok {
+				continue
+			}
+			at, _ := readingTime(b)
+			p := point{at: at, temp: temp}
+			if s, ok := toFloat64(b.State["hold_seconds"]); ok {
+				p.hold = time.Duration(s * float64(time.Second))
+			}
+			points = append(points, p)
+		}
+	}
+	check.Readings = len(points)
+	if len(points) == 0 {
+		check.Reason = "no temperature readings linked to the process"
+		return check, nil
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].at.Before(points[j].at) })
+
+	var runStart time.Time
+	inRun := false
+	for i, p := range points {
+		if i == 0 || p.temp > check.MaxTempC {
+			check.MaxTempC = p.temp
+		}
+		if p.temp < std.MinTempC {
+			inRun = false
+			continue
+		}
+		if p.hold > check.Hold {
+			check.Hold = p.hold
+		}
+		if p.at.IsZero() {
+			continue
+		}
+		if !inRun {
+			runStart, inRun = p.at, true
+		}
+		if d := p.at.Sub(runStart); d > check.Hold {
+			check.Hold = d
+		}
+	}
+	check.HoldSeconds = check.Hold.Seconds()
+	switch {
+	case check.MaxTempC < std.MinTempC:
+		check.Reason = fmt.Sprintf("peak %.1f°C below the %s minimum of %.1f°C", check.MaxTempC, std.Name, std.MinTempC)
+	case check.Hold < std.MinHold:
+		check.Reason = fmt.Sprintf("held at %.1f°C or above for %s, %s requires %s", std.MinTempC, check.Hold, std.Name, std.MinHold)
+	default:
+		check.Passed = true
+	}
+	return check, nil
+}
+
+// upstreamRefs are followed from a product to the processes and inputs it
+// was made from.
+var upstreamRefs = []string{"origin", "process", "source", "input", "inputs"}
+
+// PasteurizationClaim is the verdict on a product claiming pasteurized: true.
+type PasteurizationClaim struct {
+	Product   string                `json:"product"`
+	Supported bool                  `json:"supported"`
+	Processes []PasteurizationCheck `json:"processes"`
+	Reason    string                `json:"reason,omitempty"`
+}
+
+// CheckPasteurizationClaim walks upstream from a product through its origin,
+// process, source and input refs and reports whether a pasteurization process
+// on the way passed verification. Products that do not claim to be
+// pasteurized are trivially supported.
+func CheckPasteurizationClaim(product Block, store BlockStore) PasteurizationClaim {
+	claim := PasteurizationClaim{Product: product.Hash, Processes: []PasteurizationCheck{}}
+	if claimed, _ := product.State["pasteurized"].(bool); !claimed {
+		claim.Supported = true
+		return claim
+	}
+	visited := map[string]bool{product.Hash: true}
+	queue := []Block{product}
+	for depth := 0; len(queue) > 0 && depth < 10; depth++ {
+		var next []Block
+		for _, b := range queue {
+			for _, key := range upstreamRefs {
+				for _, h := range flattenRefValues(map[string]interface{}{key: b.Refs[key]}) {
+					up := store.Resolve(h)
+					if up == nil || visited[h] {
+						continue
+					}
+					visited[h] = true
+					if IsPasteurization(*up) {
+						check, err := VerifyPasteurization(h, store)
+						if err != nil {
+							check = PasteurizationCheck{Process: h, Reason: err.Error()}
+						}
+						claim.Processes = append(claim.Processes, check)
+						claim.Supported = claim.Supported || check.Passed
+						continue
+					}
+					next = append(next, *up)
+				}
+			}
+		}
+		queue = next
+	}
+	switch {
+	case claim.Supported:
+	case len(claim.Processes) == 0:
+		claim.Reason = "claims pasteurized but no pasteurization process is linked"
+	default:
+		claim.Reason = "no linked pasteurization process met its standard: " + claim.Processes[0].Reason
+	}
+	return claim
+}
+
+// UnsupportedPasteurizationClaims flags the latest version of every block
+// claiming pasteurized: true that CheckPasteurizationClaim does not support.
+func UnsupportedPasteurizationClaims(store BlockStore) []PasteurizationClaim {
+	var out []PasteurizationClaim
+	for _, b := range FilterBlocks(store.Blocks(), QueryParams{Type: "substance.*", HeadsOnly: true}) {
+		if claim := CheckPasteurizationClaim(b, store); !claim.Supported {
+			out = append(out, claim)
+		}
+	}
+	return out
+}
+
+// RequirePasteurizationEvidence returns an error when block claims to be
+// pasteurized without a verified process in store. Call it before Put to
+// reject unsupported claims instead of flagging them afterwards.
+func RequirePasteurizationEvidence(block Block, store BlockStore) error {
+	if claim := CheckPasteurizationClaim(block, store); !claim.Supported {
+		return fmt.Errorf("FoodBlock: unsupported pasteurization claim on %s: %s", block.Hash, claim.Reason)
+	}
+	return nil
+}
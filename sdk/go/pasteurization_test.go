@@ -0,0 +1,68 @@
+package foodblock
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVerifyPasteurization(t *testing.T) {
+	raw := Create("substance.dairy", map[string]interface{}{"name": "Raw milk", "pasteurized": false}, nil)
+	process := Create("transform.process", map[string]interface{}{"name": "HTST run 14", "process_type": "pasteurizing", "method": "HTST"},
+		map[string]interface{}{"input": raw.Hash})
+	milk := Create("substance.dairy", map[string]interface{}{"name": "Whole milk", "pasteurized": true},
+		map[string]interface{}{"origin": process.Hash})
+	reading := func(id, at string, temp float64, process string) Block {
+		return Create("observe.reading", map[string]interface{}{"instance_id": id, "temperature": temp, "unit": "celsius", "at": at},
+			map[string]interface{}{"subject": process})
+	}
+	store := NewMemoryStore()
+	store.PutAll([]Block{raw, process, milk,
+		reading("r1", "2026-04-01T06:00:00Z", 65, process.Hash),
+		reading("r2", "2026-04-01T06:00:05Z", 72.4, process.Hash),
+		reading("r3", "2026-04-01T06:00:12Z", 72.6, process.Hash),
+		reading("r4", "2026-04-01T06:00:21Z", 72.5, process.Hash),
+	})
+
+	check, err := VerifyPasteurization(process.Hash, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !check.Passed || check.HoldSeconds != 16 || check.MaxTempC != 72.6 || check.Standard != "HTST" {
+		t.Errorf("expected a 16s hold at 72.6°C to pass, got %+v", check)
+	}
+	if claim := CheckPasteurizationClaim(milk, store); !claim.Supported || len(claim.Processes) != 1 {
+		t.Errorf("expected the claim to be supported, got %+v", claim)
+	}
+
+	short := Create("transform.process", map[string]interface{}{"name": "HTST run 15", "process_type": "pasteurization"},
+		map[string]interface{}{"input": raw.Hash})
+	cream := Create("substance.dairy", map[string]interface{}{"name": "Cream", "pasteurized": true},
+		map[string]interface{}{"origin": short.Hash})
+	butter := Create("substance.dairy", map[string]interface{}{"name": "Butter", "pasteurized": true}, nil)
+	store.PutAll([]Block{short, cream, butter,
+		reading("r5", "2026-04-01T07:00:00Z", 72.1, short.Hash),
+		reading("r6", "2026-04-01T07:00:10Z", 72.2, short.Hash),
+	})
+	if check, _ := VerifyPasteurization(short.Hash, store); check.Passed || !strings.Contains(check.Reason, "requires 15s") {
+		t.Errorf("expected a 10s hold to fail, got %+v", check)
+	}
+	if _, err := VerifyPasteurization(raw.Hash, store); err == nil {
+		t.Error("expected an error for a non-process block")
+	}
+
+	flagged := UnsupportedPasteurizationClaims(store)
+	if len(flagged) != 2 {
+		t.Fatalf("expected cream and butter flagged, got %+v", flagged)
+	}
+	for _, c := range flagged {
+		if c.Product == butter.Hash && !strings.Contains(c.Reason, "no pasteurization process") {
+			t.Errorf("unexpected reason for butter: %s", c.Reason)
+		}
+	}
+	if err := RequirePasteurizationEvidence(cream, store); err == nil {
+		t.Error("expected the cream claim to be rejected")
+	}
+	if err := RequirePasteurizationEvidence(raw, store); err != nil {
+		t.Errorf("unclaimed products should pass, got %v", err)
+	}
+}
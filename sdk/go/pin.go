@@ -0,0 +1,131 @@
+package foodblock
+
+import "sort"
+
+// PinType is a retention tag: while its latest version is not released, the
+// block in refs.target keeps its content through compaction, archival and
+// sync.
+const PinType = "observe.pin"
+
+// Common pin reasons.
+const (
+	PinLegalHold = "legal_hold"
+	PinRecall    = "recall"
+)
+
+// Pin creates a retention tag holding a block for a reason. Store it with
+// PutSigned: the signer is recorded as the pin's placer, and only the placer
+// can release it.
+func Pin(hash, reason string) Block {
+	return Create(PinType, map[string]interface{}{"reason": reason}, map[string]interface{}{"target": hash})
+}
+
+// Unpin releases a pin by updating it with released set. The release counts
+// only when signed by the actor who placed the pin.
+func Unpin(pin Block, releasedBy string) Block {
+	state := map[string]interface{}{"released": true}
+	if reason, ok := pin.State["reason"]; ok {
+		state["reason"] = reason
+	}
+	return Update(pin.Hash, PinType, state, map[string]interface{}{"target": pin.Refs["target"], "released_by": releasedBy})
+}
+
+// activePins returns the unreleased pins holding a block, each at its
+// current version.
+func activePins(hash string, store BlockStore) []Block {
+	var out []Block
+	for _, b := range store.ResolveForward(hash) {
+		if b.Type != PinType || b.Refs["target"] != hash || b.Refs["updates"] != nil {
+			continue
+		}
+		if cur := currentPin(b, store); !pinReleased(cur) {
+			out = append(out, cur)
+		}
+	}
+	return out
+}
+
+// currentPin follows a pin's update chain through the versions signed by its
+// placer, the signer of the first version. Updates from anyone else, and all
+// updates to an unsigned pin, are ignored.
+func currentPin(pin Block, store BlockStore) Block {
+	signers, ok := store.(interface{ SignerOf(string) string })
+	if !ok {
+		return pin
+	}
+	placer := signers.SignerOf(pin.Hash)
+	if placer == "" {
+		return pin
+	}
+	cur := pin
+	for advanced := true; advanced; {
+		advanced = false
+		for _, next := range store.ResolveForward(cur.Hash) {
+			if next.Type == PinType && next.Refs["updates"] == cur.Hash && signers.SignerOf(next.Hash) == placer {
+				cur, advanced = next, true
+				break
+			}
+		}
+	}
+	return cur
+}
+
+func pinReleased(pin Block) bool {
+	released, _ := pin.State["released"].(bool)
+	return released
+}
+
+// PinReasons returns the reasons a block is pinned, sorted; none means it is
+// not pinned.
+func PinReasons(hash string, store BlockStore) []string {
+	var reasons []string
+	for _, p := range activePins(hash, store) {
+		r, _ := p.State["reason"].(string)
+		reasons = appendUnique(reasons, r)
+	}
+	sort.Strings(reasons)
+	return reasons
+}
+
+// IsPinned reports whether any unreleased pin holds a block.
+func IsPinned(hash string, store BlockStore) bool {
+	return len(activePins(hash, store)) > 0
+}
+
+// PinnedContent is the content held for one reason.
+type PinnedContent struct {
+	Reason  string   `json:"reason"`
+	Targets []string `json:"targets"`
+	Pins    []string `json:"pins"`
+}
+
+// PinnedByReason reports the blocks currently pinned, grouped by reason in
+// reason order.
+func PinnedByReason(store BlockStore) []PinnedContent {
+	byReason := map[string]*PinnedContent{}
+	for _, b := range store.Blocks() {
+		if b.Type != PinType || b.Refs["updates"] != nil {
+			continue
+		}
+		if b = currentPin(b, store); pinReleased(b) {
+			continue
+		}
+		target, _ := b.Refs["target"].(string)
+		reason, _ := b.State["reason"].(string)
+		pc := byReason[reason]
+		if pc == nil {
+			pc = &PinnedContent{Reason: reason}
+			byReason[reason] = pc
+		}
+		pc.Targets = appendUnique(pc.Targets, target)
+		pc.Pins = append(pc.Pins, b.Hash)
+	}
+	out := make([]PinnedContent, 0, len(byReason))
+	for _, pc := range byReason {
+		sort.Strings(pc.Targets)
+		sort.Strings(pc.Pins)
+		out = append(out, *pc)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Reason < out[j].Reason })
+	return out
+}
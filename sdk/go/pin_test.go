@@ -0,0 +1,67 @@
+package foodblock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPinHoldsContent(t *testing.T) {
+	store := NewMemoryStore()
+	review := Create("observe.review", map[string]interface{}{"rating": 1.0, "text": "evidence"}, map[string]interface{}{"author": "user_hash"})
+	lot := Create("substance.product", map[string]interface{}{"name": "Lot 42"}, nil)
+	hold := Pin(review.Hash, PinLegalHold)
+	recall := Pin(lot.Hash, PinRecall)
+	store.PutAll([]Block{review, lot, recall})
+	store.PutSigned(SignedBlock{FoodBlock: hold, AuthorHash: "counsel"})
+	store.PutSigned(SignedBlock{FoodBlock: Tombstone(review.Hash, "user_hash"), AuthorHash: "user_hash"})
+
+	if got := PinReasons(review.Hash, store); len(got) != 1 || got[0] != PinLegalHold {
+		t.Errorf("expected a legal hold, got %v", got)
+	}
+	pinned := PinnedByReason(store)
+	if len(pinned) != 2 || pinned[0].Reason != PinLegalHold || pinned[1].Reason != PinRecall || pinned[1].Targets[0] != lot.Hash {
+		t.Errorf("unexpected pin report %+v", pinned)
+	}
+
+	report, _ := Compact(store, CompactionPolicy{})
+	if len(report.Erased) != 0 || len(report.Held) != 1 || store.Resolve(review.Hash).State["text"] != "evidence" {
+		t.Fatalf("expected the held review to keep its content, got %+v", report)
+	}
+
+	// Pinned blocks stay hot, whatever their age.
+	cold, _ := NewFileColdStore(t.TempDir())
+	later := func() time.Time { return time.Now().Add(400 * 24 * time.Hour) }
+	archived, _ := ArchiveCold(store, cold, ArchivePolicy{MinAge: 365 * 24 * time.Hour, Now: later})
+	for _, h := range archived {
+		if h == review.Hash || h == lot.Hash {
+			t.Errorf("pinned block %s was archived", h)
+		}
+	}
+
+	// Sync does not erase a pinned local copy either.
+	if err := applyChange(store, Change{Block: Block{Hash: lot.Hash, Type: lot.Type, State: map[string]interface{}{"tombstoned": true}, Refs: lot.Refs}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if IsStub(*store.Resolve(lot.Hash)) {
+		t.Error("expected sync to keep the pinned lot")
+	}
+
+	// Only the actor who placed the hold can release it.
+	store.Put(Unpin(hold, "anyone"))
+	store.PutSigned(SignedBlock{FoodBlock: Unpin(hold, "mallory"), AuthorHash: "mallory"})
+	if !IsPinned(review.Hash, store) {
+		t.Fatal("a third party released the hold")
+	}
+	if report, _ := Compact(store, CompactionPolicy{}); len(report.Erased) != 0 {
+		t.Fatalf("erased %v under a legal hold", report.Erased)
+	}
+
+	store.PutSigned(SignedBlock{FoodBlock: Unpin(hold, "counsel"), AuthorHash: "counsel"})
+	if IsPinned(review.Hash, store) || len(PinnedByReason(store)) != 1 {
+		t.Error("expected the hold to be released")
+	}
+	report, _ = Compact(store, CompactionPolicy{})
+	if len(report.Erased) != 1 || report.Erased[0] != review.Hash {
+		t.Errorf("expected the review to be erased once released, got %+v", report)
+	}
+}
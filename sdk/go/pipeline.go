@@ -0,0 +1,312 @@
+package foodblock
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DefaultMaxBlockBytes is the largest canonical block ValidatePipeline
+// accepts unless told otherwise.
+const DefaultMaxBlockBytes = 64 * 1024
+
+// PipelineOptions configures ValidatePipeline. Every field is optional.
+type PipelineOptions struct {
+	// Store resolves refs and earlier versions; blocks already in it are
+	// reported, not rejected. Nothing is written to it.
+	Store BlockStore
+	// Keys verifies signatures. Without it signed blocks are not verified.
+	Keys              *KeyRegistry
+	RequireSignatures bool // reject unsigned blocks
+	MaxBytes          int  // canonical size limit, default DefaultMaxBlockBytes
+	// Vocabularies checks fields and workflow transitions; default Vocabularies.
+	Vocabularies map[string]VocabularyDef
+	// Roles checks ref roles against a registry, such as RefRoles. Nil
+	// skips the check.
+	Roles map[string]RoleDef
+}
+
+// PipelineIssue is one problem found with a block. Errors make a block
+// invalid; warnings do not.
+type PipelineIssue struct {
+	Check    string `json:"check"` // hash, size, schema, vocabulary, refs, roles, signature, duplicate or transition
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// PipelineBlock is the outcome for one block of the batch.
+type PipelineBlock struct {
+	Index  int             `json:"index"`
+	Hash   string          `json:"hash"`
+	Type   string          `json:"type"`
+	Valid  bool            `json:"valid"`
+	Issues []PipelineIssue `json:"issues"`
+}
+
+// PipelineReport is the outcome of validating a batch.
+type PipelineReport struct {
+	Blocks   []PipelineBlock `json:"blocks"`
+	Valid    int             `json:"valid"`
+	Invalid  int             `json:"invalid"`
+	Warnings int             `json:"warnings"`
+	OK       bool            `json:"ok"` // every block is valid
+}
+
+// ValidatePipeline runs every ingest check over a batch without persisting
+// anything, so a partner can pre-validate blocks before submitting them to a
+// federation peer. Per block it checks that:
+//
+//   - the hash matches the content and the canonical form fits MaxBytes;
+//   - the state satisfies its declared $schema, a core schema or one
+//     published in the store, which is not deprecated (a warning);
+//   - fields of vocabularies for the type have the vocabulary's types and
+//     valid values (warnings, as vocabularies are advisory);
+//   - every ref resolves in the batch or the store, to a block of a type
+//     the schema's RefTypes and the vocabularies allow;
+//   - with Roles, refs use roles on the types the registry allows them
+//     (aliases are warnings);
+//   - signatures verify against Keys, and blocks are signed if required;
+//   - no block appears twice in the batch (one already stored is a warning);
+//   - a status change from the version in refs.updates is a transition the
+//     type's workflow vocabulary allows, guards included.
+func ValidatePipeline(blocks []SignedBlock, opts PipelineOptions) PipelineReport {
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = DefaultMaxBlockBytes
+	}
+	if opts.Vocabularies == nil {
+		opts.Vocabularies = Vocabularies
+	}
+	batch := map[string]int{}
+	graph := make([]Block, 0, len(blocks))
+	for i, s := range blocks {
+		graph = append(graph, s.FoodBlock)
+		if _, ok := batch[s.FoodBlock.Hash]; !ok {
+			batch[s.FoodBlock.Hash] = i
+		}
+	}
+	if opts.Store != nil {
+		graph = append(graph, opts.Store.Blocks()...)
+	}
+	resolve := func(hash string) *Block {
+		if i, ok := batch[hash]; ok {
+			return &blocks[i].FoodBlock
+		}
+		if opts.Store != nil {
+			return opts.Store.Resolve(hash)
+		}
+		return nil
+	}
+
+	// Guards only take a batch block's signer once its signature verifies.
+	storeSigner := signerLookup(opts.Store)
+	signerOf := func(hash string) string {
+		if i, ok := batch[hash]; ok {
+			if s := blocks[i]; s.Signature != "" && opts.Keys != nil && opts.Keys.VerifySigned(s) {
+				return s.AuthorHash
+			}
+			return ""
+		}
+		if storeSigner != nil {
+			return storeSigner(hash)
+		}
+		return ""
+	}
+
+	r := PipelineReport{Blocks: make([]PipelineBlock, len(blocks))}
+	for i, s := range blocks {
+		b := s.FoodBlock
+		out := PipelineBlock{Index: i, Hash: b.Hash, Type: b.Type, Issues: []PipelineIssue{}}
+		issue := func(check, severity, format string, args ...interface{}) {
+			out.Issues = append(out.Issues, PipelineIssue{check, severity, fmt.Sprintf(format, args...)})
+		}
+
+		canonical := Canonical(b.Type, b.State, b.Refs)
+		if b.Type == "" {
+			issue("hash", "error", "block has no type")
+		} else if want := Hash(b.Type, b.State, b.Refs); b.Hash != want {
+			issue("hash", "error", "hash does not match content (expected %s)", want)
+		}
+		if len(canonical) > opts.MaxBytes {
+			issue("size", "error", "block is %d bytes, over the %d byte limit", len(canonical), opts.MaxBytes)
+		}
+		var schema *Schema
+		if ref, ok := b.State["$schema"].(string); ok {
+			if s, found := LookupSchema(ref, opts.Store); found {
+				schema = &s
+				if s.Deprecated != "" {
+					issue("schema", "warning", "%s is deprecated: %s", ref, s.Deprecated)
+				}
+			}
+		}
+		for _, msg := range Validate(b, schema) {
+			issue("schema", "error", "%s", msg)
+		}
+		for _, msg := range vocabularyIssues(b, opts.Vocabularies) {
+			issue("vocabulary", "warning", "%s", msg)
+		}
+
+		mismatched, dangling := refTargetIssues(b, schema, opts.Vocabularies, resolve)
+		for _, d := range dangling {
+			issue("refs", "error", "%s does not resolve", d)
+		}
+		for _, msg := range mismatched {
+			issue("refs", "error", "%s", msg)
+		}
+
+		if opts.Roles != nil {
+			for _, msg := range ValidateRefRoles(b, resolve, opts.Roles) {
+				if strings.Contains(msg, "is an alias of") {
+					issue("roles", "warning", "%s", msg)
+				} else {
+					issue("roles", "error", "%s", msg)
+				}
+			}
+		}
+
+		switch {
+		case s.Signature != "" && opts.Keys != nil:
+			if _, known := opts.Keys.PublicKey(s.AuthorHash); !known {
+				issue("signature", "error", "no key registered for author %s", s.AuthorHash)
+			} else if !opts.Keys.VerifySigned(s) {
+				issue("signature", "error", "signature does not verify")
+			}
+		case s.Signature == "" && opts.RequireSignatures:
+			issue("signature", "error", "block is not signed")
+		}
+
+		if first := batch[b.Hash]; first != i {
+			issue("duplicate", "error", "duplicate of block %d in the batch", first)
+		} else if opts.Store != nil && opts.Store.Resolve(b.Hash) != nil {
+			issue("duplicate", "warning", "already stored")
+		}
+
+		if msg := transitionIssue(b, resolve, graph, signerOf, opts.Vocabularies); msg != "" {
+			issue("transition", "error", "%s", msg)
+		}
+
+		out.Valid = true
+		for _, is := range out.Issues {
+			if is.Severity == "error" {
+				out.Valid = false
+			} else {
+				r.Warnings++
+			}
+		}
+		if out.Valid {
+			r.Valid++
+		} else {
+			r.Invalid++
+		}
+		r.Blocks[i] = out
+	}
+	r.OK = r.Invalid == 0
+	return r
+}
+
+// vocabularyIssues checks a block's state against the vocabularies for its
+// type: required fields, field types, valid values and constraints.
+func vocabularyIssues(b Block, vocabs map[string]VocabularyDef) []string {
+	domains := make([]string, 0, len(vocabs))
+	for d := range vocabs {
+		domains = append(domains, d)
+	}
+	sort.Strings(domains)
+	var out []string
+	for _, domain := range domains {
+		vocab := vocabs[domain]
+		if !containsStr(vocab.ForTypes, b.Type) {
+			continue
+		}
+		fields := make([]string, 0, len(vocab.Fields))
+		for f := range vocab.Fields {
+			fields = append(fields, f)
+		}
+		sort.Strings(fields)
+		for _, name := range fields {
+			def := vocab.Fields[name]
+			v, ok := b.State[name]
+			if !ok {
+				if def.Required {
+					out = append(out, fmt.Sprintf("%s: missing required field state.%s", domain, name))
+				}
+				continue
+			}
+			if got := goTypeToSchemaType(v); !vocabTypeAccepts(def.Type, got) {
+				out = append(out, fmt.Sprintf("%s: state.%s should be %s, got %s", domain, name, def.Type, got))
+				continue
+			}
+			if s, isStr := v.(string); isStr && len(def.ValidValues) > 0 {
+				valid := false
+				for _, vv := range def.ValidValues {
+					valid = valid || strings.EqualFold(vv, s)
+				}
+				if !valid {
+					out = append(out, fmt.Sprintf("%s: state.%s %q is not one of %s", domain, name, s, strings.Join(def.ValidValues, ", ")))
+				}
+			}
+			for _, msg := range checkConstraint("state."+name, def.Constraint, v) {
+				out = append(out, domain+": "+msg)
+			}
+		}
+	}
+	return out
+}
+
+// vocabTypeAccepts reports whether a value of a schema type suits a
+// vocabulary field type. Quantities may be bare numbers; objects such as
+// transit times are often written as text.
+func vocabTypeAccepts(fieldType, got string) bool {
+	switch fieldType {
+	case "", got:
+		return true
+	case "quantity":
+		return got == "number" || got == "object"
+	case "compound":
+		return got == "object" || got == "array"
+	case "object":
+		return got == "object" || got == "string" || got == "number"
+	}
+	return false
+}
+
+// transitionIssue checks a status change against the previous version's
+// status under the workflow vocabulary for the block's type.
+func transitionIssue(b Block, resolve func(string) *Block, graph []Block, signerOf func(string) string, vocabs map[string]VocabularyDef) string {
+	prevHash, _ := b.Refs["updates"].(string)
+	to, _ := b.State["status"].(string)
+	if prevHash == "" || to == "" {
+		return ""
+	}
+	prev := resolve(prevHash)
+	if prev == nil {
+		return ""
+	}
+	from, _ := prev.State["status"].(string)
+	if from == "" || from == to {
+		return ""
+	}
+	domains := make([]string, 0, len(vocabs))
+	for d := range vocabs {
+		domains = append(domains, d)
+	}
+	sort.Strings(domains)
+	for _, domain := range domains {
+		vocab := vocabs[domain]
+		if len(vocab.Transitions) == 0 || !containsStr(vocab.ForTypes, b.Type) {
+			continue
+		}
+		// Guards see the new state under the previous version's hash, which
+		// is what the rest of the graph references.
+		subject := Block{Hash: prev.Hash, Type: b.Type, State: map[string]interface{}{}, Refs: b.Refs}
+		for k, v := range b.State {
+			subject.State[k] = v
+		}
+		subject.State["status"] = from
+		check := CheckTransition(vocab, subject, to, graph, signerOf)
+		if check.Allowed {
+			return ""
+		}
+		return fmt.Sprintf("%s: %s", domain, check.Reason)
+	}
+	return ""
+}
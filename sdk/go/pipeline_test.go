@@ -0,0 +1,94 @@
+package foodblock
+
+import (
+	"strings"
+	"testing"
+)
+
+func pipelineIssues(b PipelineBlock, check string) []PipelineIssue {
+	var out []PipelineIssue
+	for _, is := range b.Issues {
+		if is.Check == check {
+			out = append(out, is)
+		}
+	}
+	return out
+}
+
+func TestValidatePipeline(t *testing.T) {
+	store := NewMemoryStore()
+	venue := Create("actor.venue", map[string]interface{}{"name": "Bakery"}, nil)
+	store.Put(venue)
+
+	pub, priv := GenerateKeypair()
+	keys := NewKeyRegistry()
+	keys.Register("author-1", pub)
+
+	order := Create("transfer.order", map[string]interface{}{"instance_id": "o1", "status": "order"}, map[string]interface{}{"buyer": venue.Hash})
+	confirmed := Update(order.Hash, "transfer.order", map[string]interface{}{"instance_id": "o1", "status": "confirmed", "payment_ref": "PAY1"}, map[string]interface{}{"buyer": venue.Hash})
+	skipped := Update(order.Hash, "transfer.order", map[string]interface{}{"instance_id": "o1", "status": "paid"}, map[string]interface{}{"buyer": venue.Hash})
+	dangling := Create("observe.reading", map[string]interface{}{"temperature": 4}, map[string]interface{}{"subject": "nowhere"})
+	tampered := Sign(Create("actor.producer", map[string]interface{}{"name": "Farm"}, nil), "author-1", priv)
+	tampered.FoodBlock.State = map[string]interface{}{"name": "Other farm"}
+	incident := Create("observe.incident", map[string]interface{}{"instance_id": "i1", "status": "open", "severity": "dire"}, nil)
+
+	batch := []SignedBlock{
+		Sign(order, "author-1", priv),
+		{FoodBlock: confirmed},
+		{FoodBlock: skipped},
+		{FoodBlock: dangling},
+		tampered,
+		{FoodBlock: venue},
+		{FoodBlock: order},
+		{FoodBlock: incident},
+	}
+	before := len(store.Blocks())
+	r := ValidatePipeline(batch, PipelineOptions{Store: store, Keys: keys, MaxBytes: 4096})
+	if len(store.Blocks()) != before {
+		t.Fatal("pipeline wrote to the store")
+	}
+	if r.OK || r.Valid != 4 || r.Invalid != 4 {
+		t.Fatalf("report: valid %d invalid %d", r.Valid, r.Invalid)
+	}
+	if !r.Blocks[0].Valid || !r.Blocks[1].Valid {
+		t.Errorf("signed order and allowed transition should pass: %+v %+v", r.Blocks[0], r.Blocks[1])
+	}
+	if is := pipelineIssues(r.Blocks[2], "transition"); len(is) != 1 || !strings.Contains(is[0].Message, "order->paid") {
+		t.Errorf("transition issues = %+v", r.Blocks[2].Issues)
+	}
+	if is := pipelineIssues(r.Blocks[3], "refs"); len(is) != 1 || !strings.Contains(is[0].Message, "refs.subject nowhere") {
+		t.Errorf("ref issues = %+v", r.Blocks[3].Issues)
+	}
+	if len(pipelineIssues(r.Blocks[4], "hash")) != 1 || len(pipelineIssues(r.Blocks[4], "signature")) != 1 {
+		t.Errorf("tampered issues = %+v", r.Blocks[4].Issues)
+	}
+	if is := pipelineIssues(r.Blocks[5], "duplicate"); !r.Blocks[5].Valid || len(is) != 1 || is[0].Severity != "warning" {
+		t.Errorf("stored block issues = %+v", r.Blocks[5].Issues)
+	}
+	if is := pipelineIssues(r.Blocks[6], "duplicate"); r.Blocks[6].Valid || len(is) != 1 || !strings.Contains(is[0].Message, "block 0") {
+		t.Errorf("duplicate issues = %+v", r.Blocks[6].Issues)
+	}
+	if is := pipelineIssues(r.Blocks[7], "vocabulary"); !r.Blocks[7].Valid || len(is) != 1 || !strings.Contains(is[0].Message, "severity") {
+		t.Errorf("vocabulary issues = %+v", r.Blocks[7].Issues)
+	}
+
+	big := Create("observe.reading", map[string]interface{}{"notes": strings.Repeat("x", 200)}, nil)
+	r = ValidatePipeline([]SignedBlock{{FoodBlock: big}}, PipelineOptions{MaxBytes: 100, RequireSignatures: true})
+	if len(pipelineIssues(r.Blocks[0], "size")) != 1 || len(pipelineIssues(r.Blocks[0], "signature")) != 1 {
+		t.Errorf("issues = %+v", r.Blocks[0].Issues)
+	}
+}
+
+func TestPipelineTransitionGuards(t *testing.T) {
+	open := Create("observe.incident", map[string]interface{}{"instance_id": "i1", "status": "open", "severity": "low"}, nil)
+	unassigned := Update(open.Hash, "observe.incident", map[string]interface{}{"instance_id": "i1", "status": "investigating", "severity": "low"}, nil)
+	assigned := Update(open.Hash, "observe.incident", map[string]interface{}{"instance_id": "i1", "status": "investigating", "severity": "low"},
+		map[string]interface{}{"assignee": open.Hash})
+	r := ValidatePipeline([]SignedBlock{{FoodBlock: open}, {FoodBlock: unassigned}, {FoodBlock: assigned}}, PipelineOptions{})
+	if r.Blocks[1].Valid || !strings.Contains(pipelineIssues(r.Blocks[1], "transition")[0].Message, "assignee") {
+		t.Errorf("unassigned = %+v", r.Blocks[1])
+	}
+	if !r.Blocks[2].Valid {
+		t.Errorf("assigned = %+v", r.Blocks[2])
+	}
+}
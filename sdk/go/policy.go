@@ -0,0 +1,104 @@
+package foodblock
+
+import (
+	"errors"
+	"sort"
+)
+
+// maxPolicyDepth bounds "extends" chains.
+const maxPolicyDepth = 16
+
+// ResolvePolicy loads an observe.trust_policy block and every policy it
+// extends (refs.extends, a hash or array of hashes) and merges them into a
+// policy map for ComputeTrust. Precedence, from lowest to highest:
+//
+//   - parents, in the order listed in refs.extends, each resolved recursively
+//   - the policy itself
+//
+// weights merge per key and min_score is taken from the nearest policy that
+// sets it. required_authorities accumulate across the chain, so adopting a
+// policy can add authority requirements but never drop them.
+func ResolvePolicy(policyHash string, resolve func(string) *Block) (map[string]interface{}, error) {
+	merged := map[string]interface{}{}
+	weights := map[string]interface{}{}
+	authorities := map[string]bool{}
+	var chain []string
+	if err := applyPolicy(policyHash, resolve, merged, weights, authorities, map[string]bool{}, &chain, 0); err != nil {
+		return nil, err
+	}
+	merged["weights"] = weights
+	if len(authorities) > 0 {
+		list := make([]string, 0, len(authorities))
+		for a := range authorities {
+			list = append(list, a)
+		}
+		sort.Strings(list)
+		merged["required_authorities"] = list
+	}
+	merged["policy_chain"] = chain
+	return merged, nil
+}
+
+func applyPolicy(hash string, resolve func(string) *Block, merged, weights map[string]interface{}, authorities, visiting map[string]bool, chain *[]string, depth int) error {
+	if depth > maxPolicyDepth {
+		return errors.New("FoodBlock: trust policy extends chain too deep")
+	}
+	if visiting[hash] {
+		return errors.New("FoodBlock: trust policy extends cycle at " + hash)
+	}
+	block := resolve(hash)
+	if block == nil {
+		return errors.New("FoodBlock: trust policy not found: " + hash)
+	}
+	if block.Type != "observe.trust_policy" {
+		return errors.New("FoodBlock: not a trust policy: " + hash)
+	}
+	visiting[hash] = true
+	defer delete(visiting, hash)
+
+	for _, parent := range flattenRefValues(map[string]interface{}{"extends": block.Refs["extends"]}) {
+		if err := applyPolicy(parent, resolve, merged, weights, authorities, visiting, chain, depth+1); err != nil {
+			return err
+		}
+	}
+
+	if w, ok := block.State["weights"].(map[string]interface{}); ok {
+		for k, v := range w {
+			if _, isNum := toFloat64(v); isNum {
+				weights[k] = v
+			}
+		}
+	}
+	if ms, ok := toFloat64(block.State["min_score"]); ok {
+		merged["min_score"] = ms
+	}
+	switch ra := block.State["required_authorities"].(type) {
+	case []interface{}:
+		for _, a := range ra {
+			if s, ok := a.(string); ok {
+				authorities[s] = true
+			}
+		}
+	case []string:
+		for _, a := range ra {
+			authorities[a] = true
+		}
+	}
+	for k, v := range block.State {
+		if k == "weights" || k == "min_score" || k == "required_authorities" || k == "name" || k == "instance_id" {
+			continue
+		}
+		merged[k] = v
+	}
+	*chain = append(*chain, hash)
+	return nil
+}
+
+// ComputeTrustWithPolicy resolves a published policy and computes trust under it.
+func ComputeTrustWithPolicy(actorHash string, blocks []TrustBlock, policyHash string, resolve func(string) *Block) (TrustResult, error) {
+	policy, err := ResolvePolicy(policyHash, resolve)
+	if err != nil {
+		return TrustResult{}, err
+	}
+	return ComputeTrust(actorHash, blocks, policy), nil
+}
@@ -0,0 +1,62 @@
+package foodblock
+
+import "testing"
+
+func TestResolvePolicyExtends(t *testing.T) {
+	store := NewMemoryStore()
+	base := CreateTrustPolicy("FSA baseline", map[string]interface{}{"authority_certs": 5.0, "peer_reviews": 0.5},
+		map[string]interface{}{"required_authorities": []interface{}{"fsa"}, "min_score": 10.0})
+	regional := CreateTrustPolicy("Regional", map[string]interface{}{"peer_reviews": 2.0},
+		map[string]interface{}{"required_authorities": []interface{}{"soil_assoc"}, "extends": base.Hash})
+	local := CreateTrustPolicy("Market", map[string]interface{}{"account_age": 0.0},
+		map[string]interface{}{"min_score": 4.0, "extends": regional.Hash})
+	store.PutAll([]Block{base, regional, local})
+
+	policy, err := ResolvePolicy(local.Hash, store.Resolve)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := policy["weights"].(map[string]interface{})
+	if w["authority_certs"] != 5.0 || w["peer_reviews"] != 2.0 || w["account_age"] != 0.0 {
+		t.Errorf("weights = %v", w)
+	}
+	if policy["min_score"] != 4.0 {
+		t.Errorf("min_score = %v, want nearest policy's 4", policy["min_score"])
+	}
+	ra := policy["required_authorities"].([]string)
+	if len(ra) != 2 || ra[0] != "fsa" || ra[1] != "soil_assoc" {
+		t.Errorf("required_authorities = %v", ra)
+	}
+	if chain := policy["policy_chain"].([]string); len(chain) != 3 || chain[2] != local.Hash {
+		t.Errorf("policy_chain = %v", chain)
+	}
+
+	actor := Create("actor.producer", map[string]interface{}{"name": "Farm"}, nil)
+	result, err := ComputeTrustWithPolicy(actor.Hash, []TrustBlock{{Block: actor}}, local.Hash, store.Resolve)
+	if err != nil || result.MeetsMinimum {
+		t.Errorf("result = %+v, err %v", result, err)
+	}
+
+	// Only certifications from the accumulated authorities count.
+	cert := func(authority string) TrustBlock {
+		return TrustBlock{Block: Create("observe.certification", map[string]interface{}{"name": "Organic " + authority},
+			map[string]interface{}{"subject": actor.Hash, "authority": authority})}
+	}
+	blocks := []TrustBlock{{Block: actor}, cert("fsa"), cert("soil_assoc"), cert("self_declared")}
+	if r := ComputeTrust(actor.Hash, blocks, policy); r.Inputs.AuthorityCerts != 2 {
+		t.Errorf("AuthorityCerts = %d, want 2 from required authorities", r.Inputs.AuthorityCerts)
+	}
+}
+
+func TestResolvePolicyErrors(t *testing.T) {
+	store := NewMemoryStore()
+	if _, err := ResolvePolicy("missing", store.Resolve); err == nil {
+		t.Error("expected missing policy error")
+	}
+	notPolicy := Create("actor.producer", map[string]interface{}{"name": "Farm"}, nil)
+	orphan := CreateTrustPolicy("Orphan", map[string]interface{}{}, map[string]interface{}{"extends": notPolicy.Hash})
+	store.PutAll([]Block{notPolicy, orphan})
+	if _, err := ResolvePolicy(orphan.Hash, store.Resolve); err == nil {
+		t.Error("expected error extending a non-policy block")
+	}
+}
@@ -0,0 +1,338 @@
+package foodblock
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// POSLineItem is one line of a point-of-sale transaction.
+type POSLineItem struct {
+	Name      string
+	SKU       string
+	Quantity  float64
+	UnitPrice float64
+	Total     float64
+}
+
+// POSTransaction is a normalized point-of-sale transaction.
+type POSTransaction struct {
+	ID        string
+	Source    string
+	Time      string
+	Location  string
+	PaymentID string
+	Currency  string
+	Total     float64
+	Items     []POSLineItem
+}
+
+// POSImportResult holds the blocks produced by ImportPOS.
+type POSImportResult struct {
+	Orders []Block // one transfer.order per transaction
+	Drafts []Block // draft substance.product blocks for unknown SKUs
+}
+
+// ParseSquareCSV parses a Square "Item Sales" CSV export, grouping rows by
+// Transaction ID. Money columns accept "$4.50", "-$1.00" and "(1.00)".
+func ParseSquareCSV(r io.Reader, currency string) ([]POSTransaction, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	col := make(map[string]int)
+	for i, h := range header {
+		col[strings.TrimSpace(h)] = i
+	}
+	if _, ok := col["Transaction ID"]; !ok {
+		return nil, errors.New("FoodBlock: Square CSV is missing the Transaction ID column")
+	}
+	get := func(row []string, name string) string {
+		if i, ok := col[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+	if currency == "" {
+		currency = "USD"
+	}
+
+	byID := make(map[string]*POSTransaction)
+	var order []string
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		id := get(row, "Transaction ID")
+		if id == "" {
+			continue
+		}
+		txn, ok := byID[id]
+		if !ok {
+			when := get(row, "Date")
+			if tm := get(row, "Time"); tm != "" {
+				when += "T" + tm
+			}
+			txn = &POSTransaction{
+				ID:        id,
+				Source:    "square",
+				Time:      when,
+				Location:  get(row, "Location"),
+				PaymentID: get(row, "Payment ID"),
+				Currency:  currency,
+			}
+			byID[id] = txn
+			order = append(order, id)
+		}
+		qty, _ := strconv.ParseFloat(get(row, "Qty"), 64)
+		if qty == 0 {
+			qty = 1
+		}
+		gross := parseMoney(get(row, "Gross Sales"))
+		net := parseMoney(get(row, "Net Sales"))
+		if get(row, "Net Sales") == "" {
+			net = gross
+		}
+		txn.Items = append(txn.Items, POSLineItem{
+			Name:      get(row, "Item"),
+			SKU:       get(row, "SKU"),
+			Quantity:  qty,
+			UnitPrice: roundCents(gross / qty),
+			Total:     net,
+		})
+		txn.Total = roundCents(txn.Total + net)
+	}
+
+	result := make([]POSTransaction, 0, len(order))
+	for _, id := range order {
+		result = append(result, *byID[id])
+	}
+	return result, nil
+}
+
+type squareMoney struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// currencyExponents lists ISO 4217 currencies whose minor unit is not a
+// hundredth. Amounts in any other currency have two decimals.
+var currencyExponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// value converts an amount in the currency's smallest unit to major units.
+func (m squareMoney) value() float64 {
+	exp, ok := currencyExponents[strings.ToUpper(m.Currency)]
+	if !ok {
+		exp = 2
+	}
+	return float64(m.Amount) / math.Pow10(exp)
+}
+
+type squareOrder struct {
+	ID         string `json:"id"`
+	LocationID string `json:"location_id"`
+	CreatedAt  string `json:"created_at"`
+	LineItems  []struct {
+		Name           string      `json:"name"`
+		VariationName  string      `json:"variation_name"`
+		Quantity       string      `json:"quantity"`
+		CatalogID      string      `json:"catalog_object_id"`
+		SKU            string      `json:"sku"`
+		BasePriceMoney squareMoney `json:"base_price_money"`
+		TotalMoney     squareMoney `json:"total_money"`
+	} `json:"line_items"`
+	TotalMoney squareMoney `json:"total_money"`
+	Tenders    []struct {
+		ID        string `json:"id"`
+		PaymentID string `json:"payment_id"`
+	} `json:"tenders"`
+}
+
+// ParseSquareOrders parses a Square Orders API response ({"orders": [...]}) or a
+// bare array of orders. Amounts are in the currency's smallest unit, a cent
+// for most currencies but a whole yen or won for JPY or KRW. Line items
+// carry SKU when the caller has expanded catalog variations; otherwise the
+// catalog object ID is used.
+func ParseSquareOrders(data []byte) ([]POSTransaction, error) {
+	var wrapped struct {
+		Orders []squareOrder `json:"orders"`
+	}
+	var orders []squareOrder
+	if err := json.Unmarshal(data, &wrapped); err == nil && wrapped.Orders != nil {
+		orders = wrapped.Orders
+	} else if err := json.Unmarshal(data, &orders); err != nil {
+		return nil, errors.New("FoodBlock: unrecognised Square orders payload")
+	}
+
+	result := make([]POSTransaction, 0, len(orders))
+	for _, o := range orders {
+		txn := POSTransaction{
+			ID:       o.ID,
+			Source:   "square",
+			Time:     o.CreatedAt,
+			Location: o.LocationID,
+			Currency: o.TotalMoney.Currency,
+			Total:    o.TotalMoney.value(),
+		}
+		if len(o.Tenders) > 0 {
+			txn.PaymentID = o.Tenders[0].PaymentID
+			if txn.PaymentID == "" {
+				txn.PaymentID = o.Tenders[0].ID
+			}
+		}
+		for _, li := range o.LineItems {
+			qty, _ := strconv.ParseFloat(li.Quantity, 64)
+			if qty == 0 {
+				qty = 1
+			}
+			sku := li.SKU
+			if sku == "" {
+				sku = li.CatalogID
+			}
+			name := li.Name
+			if li.VariationName != "" && li.VariationName != "Regular" {
+				name += " (" + li.VariationName + ")"
+			}
+			txn.Items = append(txn.Items, POSLineItem{
+				Name:      name,
+				SKU:       sku,
+				Quantity:  qty,
+				UnitPrice: li.BasePriceMoney.value(),
+				Total:     li.TotalMoney.value(),
+			})
+		}
+		result = append(result, txn)
+	}
+	return result, nil
+}
+
+// ImportPOS converts POS transactions into transfer.order blocks for a seller.
+// Line items are matched to existing substance.product blocks by state.sku (the
+// retail vocabulary's SKU field); unknown SKUs become draft products that the
+// seller can review. The POS transaction ID is used as instance_id, so
+// re-importing the same export yields the same order hashes.
+func ImportPOS(txns []POSTransaction, sellerHash string, products []Block) POSImportResult {
+	bySKU := make(map[string]string)
+	for _, p := range products {
+		if p.Type != "substance.product" {
+			continue
+		}
+		if sku, ok := p.State["sku"].(string); ok && sku != "" {
+			bySKU[strings.ToLower(sku)] = p.Hash
+		}
+	}
+
+	var result POSImportResult
+	for _, txn := range txns {
+		seen := make(map[string]bool)
+		var productHashes []string
+		items := make([]interface{}, 0, len(txn.Items))
+		for _, li := range txn.Items {
+			key := strings.ToLower(li.SKU)
+			if key == "" {
+				key = "name:" + strings.ToLower(li.Name)
+			}
+			hash, ok := bySKU[key]
+			if !ok {
+				draftState := map[string]interface{}{"name": li.Name, "price": li.UnitPrice, "draft": true}
+				if li.SKU != "" {
+					draftState["sku"] = li.SKU
+				}
+				refs := map[string]interface{}{}
+				if sellerHash != "" {
+					refs["seller"] = sellerHash
+				}
+				draft := Create("substance.product", draftState, refs)
+				result.Drafts = append(result.Drafts, draft)
+				hash = draft.Hash
+				bySKU[key] = hash
+			}
+			if !seen[hash] {
+				seen[hash] = true
+				productHashes = append(productHashes, hash)
+			}
+			item := map[string]interface{}{"name": li.Name, "quantity": li.Quantity, "price": li.UnitPrice, "total": li.Total}
+			if li.SKU != "" {
+				item["sku"] = li.SKU
+			}
+			items = append(items, item)
+		}
+
+		state := map[string]interface{}{
+			"instance_id": txn.Source + ":" + txn.ID,
+			"status":      "paid",
+			"source":      txn.Source,
+			"total":       txn.Total,
+			"currency":    txn.Currency,
+			"items":       items,
+		}
+		if txn.Time != "" {
+			state["date"] = txn.Time
+		}
+		if txn.PaymentID != "" {
+			state["payment_ref"] = txn.PaymentID
+		}
+		if txn.Location != "" {
+			state["location"] = txn.Location
+		}
+
+		sort.Strings(productHashes)
+		refs := map[string]interface{}{}
+		if sellerHash != "" {
+			refs["seller"] = sellerHash
+		}
+		if len(productHashes) == 1 {
+			refs["product"] = productHashes[0]
+		} else if len(productHashes) > 1 {
+			arr := make([]interface{}, len(productHashes))
+			for i, h := range productHashes {
+				arr[i] = h
+			}
+			refs["product"] = arr
+		}
+		result.Orders = append(result.Orders, Create("transfer.order", state, refs))
+	}
+	return result
+}
+
+func parseMoney(s string) float64 {
+	s = strings.TrimSpace(s)
+	neg := false
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		neg = true
+		s = s[1 : len(s)-1]
+	}
+	if strings.HasPrefix(s, "-") {
+		neg = true
+		s = s[1:]
+	}
+	s = strings.TrimLeft(s, "$£€¥")
+	s = strings.ReplaceAll(s, ",", "")
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	if neg {
+		v = -v
+	}
+	return v
+}
+
+func roundCents(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,75 @@
+package foodblock
+
+import (
+	"strings"
+	"testing"
+)
+
+const squareCSV = `Date,Time,Time Zone,Category,Item,Qty,Price Point Name,SKU,Modifiers Applied,Gross Sales,Discounts,Net Sales,Tax,Transaction ID,Payment ID,Location
+2026-03-01,09:15:02,GMT,Bread,Sourdough Loaf,2,Regular,SD-01,,$9.00,$0.00,$9.00,$0.00,TX1,PAY1,High St
+2026-03-01,09:15:02,GMT,Pastry,Croissant,1,Regular,CR-02,,$2.50,($0.50),$2.00,$0.00,TX1,PAY1,High St
+2026-03-01,10:02:44,GMT,Bread,Sourdough Loaf,1,Regular,SD-01,,$4.50,$0.00,$4.50,$0.00,TX2,PAY2,High St
+`
+
+func TestParseSquareCSV(t *testing.T) {
+	txns, err := ParseSquareCSV(strings.NewReader(squareCSV), "GBP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txns) != 2 {
+		t.Fatalf("expected 2 transactions, got %d", len(txns))
+	}
+	if len(txns[0].Items) != 2 || txns[0].Total != 11.0 {
+		t.Errorf("expected TX1 with 2 items totalling 11.00, got %d items, %v", len(txns[0].Items), txns[0].Total)
+	}
+	if txns[0].Items[0].UnitPrice != 4.5 {
+		t.Errorf("expected unit price 4.50, got %v", txns[0].Items[0].UnitPrice)
+	}
+}
+
+func TestParseSquareOrders(t *testing.T) {
+	payload := `{"orders":[{"id":"ORD1","location_id":"L1","created_at":"2026-03-01T09:15:02Z",
+		"line_items":[{"name":"Sourdough Loaf","quantity":"2","sku":"SD-01","base_price_money":{"amount":450,"currency":"USD"},"total_money":{"amount":900,"currency":"USD"}}],
+		"total_money":{"amount":900,"currency":"USD"},"tenders":[{"id":"T1","payment_id":"PAY9"}]}]}`
+	txns, err := ParseSquareOrders([]byte(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txns) != 1 || txns[0].Total != 9.0 || txns[0].PaymentID != "PAY9" || txns[0].Items[0].SKU != "SD-01" {
+		t.Errorf("unexpected parse result: %+v", txns)
+	}
+	yen := `[{"id":"ORD2","line_items":[{"name":"Melon Pan","quantity":"3","base_price_money":{"amount":250,"currency":"JPY"},"total_money":{"amount":750,"currency":"JPY"}}],
+		"total_money":{"amount":750,"currency":"JPY"}}]`
+	txns, err = ParseSquareOrders([]byte(yen))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if txns[0].Total != 750 || txns[0].Items[0].UnitPrice != 250 {
+		t.Errorf("zero-decimal currency scaled: %+v", txns[0])
+	}
+}
+
+func TestImportPOSMatchesSKUAndDraftsMissing(t *testing.T) {
+	bakery := Create("actor.venue", map[string]interface{}{"name": "Corner Bakery"}, nil)
+	sourdough := Create("substance.product", map[string]interface{}{"name": "Sourdough", "sku": "SD-01"}, map[string]interface{}{"seller": bakery.Hash})
+	txns, _ := ParseSquareCSV(strings.NewReader(squareCSV), "GBP")
+
+	result := ImportPOS(txns, bakery.Hash, []Block{bakery, sourdough})
+	if len(result.Orders) != 2 {
+		t.Fatalf("expected 2 orders, got %d", len(result.Orders))
+	}
+	if len(result.Drafts) != 1 || result.Drafts[0].State["sku"] != "CR-02" || result.Drafts[0].State["draft"] != true {
+		t.Fatalf("expected one draft product for CR-02, got %v", result.Drafts)
+	}
+	if result.Orders[1].Refs["product"] != sourdough.Hash {
+		t.Errorf("expected TX2 to reference existing sourdough, got %v", result.Orders[1].Refs["product"])
+	}
+	if result.Orders[0].State["payment_ref"] != "PAY1" {
+		t.Error("expected payment_ref from POS payment ID")
+	}
+
+	again := ImportPOS(txns, bakery.Hash, []Block{bakery, sourdough})
+	if again.Orders[0].Hash != result.Orders[0].Hash {
+		t.Error("expected re-import to be idempotent")
+	}
+}
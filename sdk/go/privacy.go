@@ -0,0 +1,265 @@
+package foodblock
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AggregateQuery selects a market-level statistic: a numeric state field of
+// the latest versions of blocks matching Type, grouped by GroupBy. GroupBy is
+// a state field, "type", or "region", which falls back to the seller's
+// region as AnonymizeExport generalizes it. An empty Field aggregates
+// blockAmount. Since and Until bound BlockDate when set.
+type AggregateQuery struct {
+	Type    string    `json:"type"`
+	Field   string    `json:"field,omitempty"`
+	GroupBy string    `json:"group_by,omitempty"`
+	Since   time.Time `json:"since,omitempty"`
+	Until   time.Time `json:"until,omitempty"`
+}
+
+// PrivacyOptions calibrate the noise PrivateAggregate adds. Sellers are the
+// verified signers of blocks, and each contributes to a single group: the
+// group of the first block it signed that the query selects. Within it, a
+// seller's contributions are capped at MaxPerSeller blocks, each value
+// clamped to [Lower, Upper], so one seller can move a count by at most
+// MaxPerSeller and a sum by MaxPerSeller × max(|Lower|, |Upper|). Laplace
+// noise scaled to those sensitivities is split evenly between the count and
+// the sum, and groups whose noisy count falls below MinGroupSize are
+// suppressed. The noise is Epsilon-differentially private per query; since
+// a group only appears once someone contributes to it, whether a near-empty
+// group is published leaks with the small probability that its noise lifts
+// it past MinGroupSize, so the guarantee is (Epsilon, δ) with δ shrinking
+// exponentially in MinGroupSize.
+type PrivacyOptions struct {
+	Epsilon      float64 // default 1
+	Lower, Upper float64 // clamp bounds, required
+	MaxPerSeller int     // default 1
+	MinGroupSize int     // noisy count, default 10
+	// Rand returns uniform values in [0, 1); the default reads crypto/rand.
+	// Only tests should replace it.
+	Rand func() float64
+}
+
+// AggregateGroup is one group's noisy statistics.
+type AggregateGroup struct {
+	Group string  `json:"group"`
+	Count float64 `json:"count"`
+	Sum   float64 `json:"sum"`
+	Mean  float64 `json:"mean"`
+}
+
+// PrivateAggregateResult is a query's published groups and the privacy
+// spent on them.
+type PrivateAggregateResult struct {
+	Query      AggregateQuery   `json:"query"`
+	Groups     []AggregateGroup `json:"groups"`
+	Suppressed int              `json:"suppressed"` // groups whose noisy count fell below MinGroupSize
+	Epsilon    float64          `json:"epsilon"`
+}
+
+// PrivateAggregate computes q over the store with differential privacy.
+// The store must record signers; blocks without one are left out, since
+// nothing would stop a single actor posing as many sellers. Every call
+// draws fresh noise, so publishers should compute once and serve the
+// result, as StatsHandler does; repeated answers average the noise away and
+// each spends another Epsilon.
+func PrivateAggregate(store BlockStore, q AggregateQuery, opts PrivacyOptions) (PrivateAggregateResult, error) {
+	if q.Type == "" {
+		return PrivateAggregateResult{}, errors.New("FoodBlock: aggregate query needs a type")
+	}
+	if opts.Upper <= opts.Lower {
+		return PrivateAggregateResult{}, errors.New("FoodBlock: private aggregates need clamp bounds with lower below upper")
+	}
+	if opts.Epsilon <= 0 {
+		opts.Epsilon = 1
+	}
+	if opts.MaxPerSeller <= 0 {
+		opts.MaxPerSeller = 1
+	}
+	if opts.MinGroupSize <= 0 {
+		opts.MinGroupSize = 10
+	}
+	if opts.Rand == nil {
+		opts.Rand = cryptoUniform
+	}
+	signerOf := signerLookup(store)
+	if signerOf == nil {
+		return PrivateAggregateResult{}, errors.New("FoodBlock: private aggregates need a store that records signers")
+	}
+
+	type group struct {
+		sellers map[string]int
+		count   float64
+		sum     float64
+	}
+	groups := map[string]*group{}
+	sellerGroup := map[string]string{}
+	for _, b := range store.Blocks() {
+		if !matchesType(b.Type, q.Type) || IsStub(b) || !headOf(b, store) {
+			continue
+		}
+		if !q.Since.IsZero() || !q.Until.IsZero() {
+			d, ok := BlockDate(b)
+			if !ok || (!q.Since.IsZero() && d.Before(q.Since)) || (!q.Until.IsZero() && d.After(q.Until)) {
+				continue
+			}
+		}
+		v, ok := aggregateValue(b, q.Field)
+		if !ok {
+			continue
+		}
+		seller := signerOf(b.Hash)
+		if seller == "" {
+			continue
+		}
+		key := aggregateGroup(b, q.GroupBy, seller, store)
+		if first, ok := sellerGroup[seller]; ok && first != key {
+			continue
+		}
+		sellerGroup[seller] = key
+		g := groups[key]
+		if g == nil {
+			g = &group{sellers: map[string]int{}}
+			groups[key] = g
+		}
+		if g.sellers[seller] >= opts.MaxPerSeller {
+			continue
+		}
+		g.sellers[seller]++
+		g.count++
+		g.sum += math.Max(opts.Lower, math.Min(opts.Upper, v))
+	}
+
+	result := PrivateAggregateResult{Query: q, Groups: []AggregateGroup{}, Epsilon: opts.Epsilon}
+	countScale := float64(opts.MaxPerSeller) / (opts.Epsilon / 2)
+	sumScale := float64(opts.MaxPerSeller) * math.Max(math.Abs(opts.Lower), math.Abs(opts.Upper)) / (opts.Epsilon / 2)
+	keys := make([]string, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		g := groups[k]
+		count := math.Max(0, g.count+laplace(countScale, opts.Rand))
+		sum := g.sum + laplace(sumScale, opts.Rand)
+		if count < float64(opts.MinGroupSize) {
+			result.Suppressed++
+			continue
+		}
+		mean := opts.Lower
+		if count >= 1 {
+			mean = math.Max(opts.Lower, math.Min(opts.Upper, sum/count))
+		}
+		result.Groups = append(result.Groups, AggregateGroup{Group: k, Count: math.Round(count), Sum: roundCents(sum), Mean: roundCents(mean)})
+	}
+	return result, nil
+}
+
+func aggregateValue(b Block, field string) (float64, bool) {
+	if field == "" {
+		return blockAmount(b)
+	}
+	return numericValue(b.State[field])
+}
+
+func aggregateGroup(b Block, by, seller string, store BlockStore) string {
+	switch by {
+	case "":
+		return "all"
+	case "type":
+		return b.Type
+	case "region":
+		if r := blockRegion(b); r != "" {
+			return r
+		}
+		if s := store.Resolve(seller); s != nil {
+			if r := blockRegion(*s); r != "" {
+				return r
+			}
+		}
+		return "unknown"
+	}
+	if s := firstString(b.State, by); s != "" {
+		return s
+	}
+	return "unknown"
+}
+
+// laplace draws from a zero-mean Laplace distribution with the given scale.
+func laplace(scale float64, uniform func() float64) float64 {
+	u := uniform() - 0.5
+	if u == -0.5 {
+		u = -0.5 + math.SmallestNonzeroFloat64
+	}
+	sign := 1.0
+	if u < 0 {
+		sign = -1
+	}
+	return -scale * sign * math.Log(1-2*math.Abs(u))
+}
+
+func cryptoUniform() float64 {
+	var buf [8]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		panic("FoodBlock: crypto/rand unavailable: " + err.Error())
+	}
+	return float64(binary.BigEndian.Uint64(buf[:])>>11) / (1 << 53)
+}
+
+// StatsHandler publishes private aggregates at GET /{name}, and the list of
+// names at GET /. Each result is computed once per Refresh (default a day)
+// and served from cache in between, so asking again does not yield fresh
+// noise.
+func StatsHandler(store BlockStore, queries map[string]AggregateQuery, opts PrivacyOptions, refresh time.Duration) http.Handler {
+	if refresh <= 0 {
+		refresh = 24 * time.Hour
+	}
+	type cached struct {
+		result PrivateAggregateResult
+		at     time.Time
+	}
+	var mu sync.Mutex
+	cache := map[string]cached{}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "statistics are read-only")
+			return
+		}
+		name := strings.Trim(r.URL.Path, "/")
+		if name == "" {
+			names := make([]string, 0, len(queries))
+			for n := range queries {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			writeJSON(w, http.StatusOK, map[string]interface{}{"statistics": names})
+			return
+		}
+		q, ok := queries[name]
+		if !ok {
+			writeError(w, http.StatusNotFound, "Unknown statistic")
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		c, ok := cache[name]
+		if !ok || time.Since(c.at) >= refresh {
+			result, err := PrivateAggregate(store, q, opts)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			c = cached{result, time.Now()}
+			cache[name] = c
+		}
+		writeJSON(w, http.StatusOK, c.result)
+	})
+}
@@ -0,0 +1,145 @@
+package foodblock
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func privacyStore() *MemoryStore {
+	store := NewMemoryStore()
+	add := func(region string, sellers int, price float64) {
+		for i := 0; i < sellers; i++ {
+			seller := Create("actor.producer", map[string]interface{}{"name": fmt.Sprintf("%s %d", region, i), "region": region}, nil)
+			store.Put(seller)
+			for j := 0; j < 2; j++ {
+				order := Create("transfer.order", map[string]interface{}{"price": price, "n": j}, map[string]interface{}{"seller": seller.Hash})
+				store.PutSigned(SignedBlock{FoodBlock: order, AuthorHash: seller.Hash})
+			}
+		}
+	}
+	add("Kent", 12, 4)
+	add("Devon", 3, 6)
+	// One outlier cannot move the published mean past the clamp.
+	seller := Create("actor.producer", map[string]interface{}{"name": "Outlier", "region": "Kent"}, nil)
+	store.Put(seller)
+	outlier := Create("transfer.order", map[string]interface{}{"price": 10000.0}, map[string]interface{}{"seller": seller.Hash})
+	store.PutSigned(SignedBlock{FoodBlock: outlier, AuthorHash: seller.Hash})
+	return store
+}
+
+func TestPrivateAggregate(t *testing.T) {
+	store := privacyStore()
+	q := AggregateQuery{Type: "transfer.order", Field: "price", GroupBy: "region"}
+	noiseless := func() float64 { return 0.5 }
+	res, err := PrivateAggregate(store, q, PrivacyOptions{Lower: 0, Upper: 20, MinGroupSize: 5, Rand: noiseless})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Suppressed != 1 || len(res.Groups) != 1 {
+		t.Fatalf("result = %+v", res)
+	}
+	if g := res.Groups[0]; g.Group != "Kent" || g.Count != 13 || g.Sum != 68 || math.Abs(g.Mean-68.0/13) > 0.01 {
+		t.Errorf("group = %+v", g)
+	}
+
+	// Noise scales with sensitivity over epsilon.
+	if n := laplace(2, func() float64 { return 0.75 }); math.Abs(n-2*math.Ln2) > 1e-9 {
+		t.Errorf("laplace = %v", n)
+	}
+	res, _ = PrivateAggregate(store, q, PrivacyOptions{Lower: 0, Upper: 20, MaxPerSeller: 2, MinGroupSize: 5, Epsilon: 0.5, Rand: func() float64 { return 0.75 }})
+	// Positive noise lifts Devon past MinGroupSize too.
+	if len(res.Groups) != 2 {
+		t.Fatalf("noisy groups = %+v", res.Groups)
+	}
+	if g := res.Groups[1]; g.Group != "Kent" || g.Count != math.Round(25+2/0.25*math.Ln2) {
+		t.Errorf("noisy count = %v", g.Count)
+	}
+
+	if _, err := PrivateAggregate(store, q, PrivacyOptions{}); err == nil {
+		t.Error("expected clamp bounds to be required")
+	}
+}
+
+func TestPrivateAggregateAttribution(t *testing.T) {
+	store := NewMemoryStore()
+	noiseless := func() float64 { return 0.5 }
+	opts := PrivacyOptions{Lower: 0, Upper: 20, MaxPerSeller: 5, MinGroupSize: 3, Rand: noiseless}
+	q := AggregateQuery{Type: "transfer.order", Field: "price", GroupBy: "market"}
+	// One actor signing orders at many markets counts in only the first.
+	for i := 0; i < 4; i++ {
+		order := Create("transfer.order", map[string]interface{}{"price": 5.0, "market": fmt.Sprintf("m%d", i)}, nil)
+		store.PutSigned(SignedBlock{FoodBlock: order, AuthorHash: "mallory"})
+	}
+	// Unsigned orders naming fresh sellers are not attributed to anyone.
+	for i := 0; i < 10; i++ {
+		store.Put(Create("transfer.order", map[string]interface{}{"price": 5.0, "market": "m1", "n": i}, map[string]interface{}{"seller": fmt.Sprintf("sybil-%d", i)}))
+	}
+	res, err := PrivateAggregate(store, q, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Groups) != 0 || res.Suppressed != 1 {
+		t.Errorf("result = %+v", res)
+	}
+
+	// Suppression follows the noisy count: noise that lifts a small group
+	// past MinGroupSize publishes it, noise that sinks a large one hides it.
+	for i := 0; i < 2; i++ {
+		order := Create("transfer.order", map[string]interface{}{"price": 5.0, "market": "m0", "i": i}, nil)
+		store.PutSigned(SignedBlock{FoodBlock: order, AuthorHash: fmt.Sprintf("seller-%d", i)})
+	}
+	res, _ = PrivateAggregate(store, q, opts)
+	if len(res.Groups) != 1 || res.Groups[0].Count != 3 {
+		t.Errorf("exact threshold = %+v", res)
+	}
+	opts.Rand = func() float64 { return 0.25 }
+	if res, _ = PrivateAggregate(store, q, opts); len(res.Groups) != 0 || res.Suppressed != 1 {
+		t.Errorf("negative noise = %+v", res)
+	}
+
+	if _, err := PrivateAggregate(noSigners{store}, q, opts); err == nil {
+		t.Error("expected a store without signers to be refused")
+	}
+}
+
+// noSigners hides a store's SignerOf.
+type noSigners struct{ BlockStore }
+
+func TestStatsHandler(t *testing.T) {
+	store := privacyStore()
+	queries := map[string]AggregateQuery{"order-prices": {Type: "transfer.order", Field: "price", GroupBy: "region"}}
+	// Small noise that differs on every draw: a cached result is the only
+	// way two requests can agree.
+	draws := 0
+	smallNoise := func() float64 { draws++; return 0.5 + float64(draws%40)/100 }
+	srv := httptest.NewServer(StatsHandler(store, queries, PrivacyOptions{Lower: 0, Upper: 20, MinGroupSize: 5, Rand: smallNoise}, 0))
+	defer srv.Close()
+	fetch := func() PrivateAggregateResult {
+		resp, err := http.Get(srv.URL + "/order-prices")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var res PrivateAggregateResult
+		json.NewDecoder(resp.Body).Decode(&res)
+		return res
+	}
+	first, second := fetch(), fetch()
+	if len(first.Groups) != 1 || first.Groups[0] != second.Groups[0] {
+		t.Errorf("repeated requests drew fresh noise: %+v, %+v", first, second)
+	}
+	if resp, _ := http.Get(srv.URL + "/unknown"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown: %d", resp.StatusCode)
+	}
+
+	var buf bytes.Buffer
+	ds, err := AnonymizeExport(store, &buf, AnonymizeOptions{Key: "k", Aggregates: []AggregateQuery{queries["order-prices"]}, Privacy: PrivacyOptions{Lower: 0, Upper: 20, MinGroupSize: 5, Rand: smallNoise}})
+	if err != nil || len(ds.Aggregates) != 1 || ds.Aggregates[0].Suppressed != 1 {
+		t.Errorf("export aggregates = %+v, %v", ds.Aggregates, err)
+	}
+}
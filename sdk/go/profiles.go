@@ -0,0 +1,134 @@
+package foodblock
+
+import (
+	"sort"
+	"strings"
+)
+
+// TrustProfile scores one concern (food safety, commercial reliability, ...)
+// from a subset of the trust inputs. Inputs without a weight are ignored.
+type TrustProfile struct {
+	Name    string             `json:"name"`
+	Weights map[string]float64 `json:"weights"`
+	// Certifications restricts which observe.certification blocks count: a
+	// certification counts when its name or scheme contains one of these
+	// terms (case-insensitive). Empty counts every certification.
+	Certifications []string `json:"certifications,omitempty"`
+	MinScore       float64  `json:"min_score,omitempty"`
+}
+
+// DefaultTrustProfiles are the built-in profiles.
+var DefaultTrustProfiles = map[string]TrustProfile{
+	"safety": {
+		Name:           "Food safety",
+		Weights:        map[string]float64{"authority_certs": 4.0, "chain_depth": 1.0, "peer_reviews": 0.5},
+		Certifications: []string{"hygiene", "haccp", "food safety", "brc", "sqf", "fssc", "salsa"},
+	},
+	"reliability": {
+		Name:    "Commercial reliability",
+		Weights: map[string]float64{"verified_orders": 2.0, "peer_reviews": 1.5, "account_age": 0.5, "chain_depth": 1.0, "disputes": -3.0, "quality": 2.0},
+	},
+	"sustainability": {
+		Name:           "Sustainability",
+		Weights:        map[string]float64{"authority_certs": 3.0, "chain_depth": 1.0},
+		Certifications: []string{"organic", "regenerative", "fairtrade", "rainforest", "msc", "b corp", "leaf"},
+	},
+}
+
+// policy converts a profile into a ComputeTrust policy. Every default input
+// is listed so unweighted inputs score zero rather than their default.
+func (p TrustProfile) policy() map[string]interface{} {
+	weights := make(map[string]interface{}, len(DefaultWeights))
+	for k := range DefaultWeights {
+		weights[k] = 0.0
+	}
+	for k, v := range p.Weights {
+		weights[k] = v
+	}
+	return map[string]interface{}{"weights": weights, "min_score": p.MinScore}
+}
+
+// certCounts reports whether a certification block is selected by the profile.
+func (p TrustProfile) certCounts(b Block) bool {
+	if len(p.Certifications) == 0 {
+		return true
+	}
+	name, _ := b.State["name"].(string)
+	scheme, _ := b.State["scheme"].(string)
+	text := strings.ToLower(name + " " + scheme)
+	for _, term := range p.Certifications {
+		if strings.Contains(text, strings.ToLower(term)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ComputeTrustProfiles scores an actor under each profile. A nil profiles
+// map uses DefaultTrustProfiles.
+func ComputeTrustProfiles(actorHash string, blocks []TrustBlock, profiles map[string]TrustProfile) map[string]TrustResult {
+	if profiles == nil {
+		profiles = DefaultTrustProfiles
+	}
+	results := make(map[string]TrustResult, len(profiles))
+	for key, profile := range profiles {
+		selected := blocks
+		if len(profile.Certifications) > 0 {
+			selected = make([]TrustBlock, 0, len(blocks))
+			for _, b := range blocks {
+				if b.Type == "observe.certification" && !profile.certCounts(b.Block) {
+					continue
+				}
+				selected = append(selected, b)
+			}
+		}
+		results[key] = ComputeTrust(actorHash, selected, profile.policy())
+	}
+	return results
+}
+
+// ProfilesFromPolicy reads declarative profiles from a trust policy's
+// "profiles" state (as returned by ResolvePolicy or a policy block's state)
+// and layers them over DefaultTrustProfiles. Each entry may set weights,
+// certifications, min_score and name.
+func ProfilesFromPolicy(policy map[string]interface{}) map[string]TrustProfile {
+	profiles := make(map[string]TrustProfile, len(DefaultTrustProfiles))
+	for k, v := range DefaultTrustProfiles {
+		profiles[k] = v
+	}
+	defs, _ := policy["profiles"].(map[string]interface{})
+	keys := make([]string, 0, len(defs))
+	for k := range defs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		def, ok := defs[key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		p := TrustProfile{Name: key, Weights: map[string]float64{}}
+		if name, ok := def["name"].(string); ok {
+			p.Name = name
+		}
+		if w, ok := def["weights"].(map[string]interface{}); ok {
+			for k, v := range w {
+				if f, ok := toFloat64(v); ok {
+					p.Weights[k] = f
+				}
+			}
+		}
+		if certs, ok := def["certifications"].([]interface{}); ok {
+			for _, c := range certs {
+				if s, ok := c.(string); ok {
+					p.Certifications = append(p.Certifications, s)
+				}
+			}
+		}
+		if ms, ok := toFloat64(def["min_score"]); ok {
+			p.MinScore = ms
+		}
+		profiles[key] = p
+	}
+	return profiles
+}
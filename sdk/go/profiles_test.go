@@ -0,0 +1,55 @@
+package foodblock
+
+import "testing"
+
+func TestComputeTrustProfiles(t *testing.T) {
+	farm := trustActor("Green Acres")
+	authority := trustActor("Council")
+	buyer := trustActor("Cafe")
+	hygiene := Create("observe.certification", map[string]interface{}{"instance_id": "c1", "name": "Food Hygiene Rating 5"},
+		map[string]interface{}{"subject": farm.Hash, "authority": authority.Hash})
+	organic := Create("observe.certification", map[string]interface{}{"instance_id": "c2", "name": "Organic"},
+		map[string]interface{}{"subject": farm.Hash, "authority": authority.Hash})
+	blocks := []TrustBlock{farm, authority, buyer, {Block: hygiene}, {Block: organic}, trustOrder(buyer.Hash, farm.Hash, true)}
+
+	results := ComputeTrustProfiles(farm.Hash, blocks, nil)
+	if len(results) != 3 {
+		t.Fatalf("profiles = %d", len(results))
+	}
+	if results["safety"].Inputs.AuthorityCerts != 1 || results["sustainability"].Inputs.AuthorityCerts != 1 {
+		t.Errorf("cert selection: safety %d, sustainability %d",
+			results["safety"].Inputs.AuthorityCerts, results["sustainability"].Inputs.AuthorityCerts)
+	}
+	if results["safety"].Score != 4.0 {
+		t.Errorf("safety score = %v, want 4 (one hygiene cert)", results["safety"].Score)
+	}
+	if results["reliability"].Score != 2.0 {
+		t.Errorf("reliability score = %v, want 2 (one verified order)", results["reliability"].Score)
+	}
+}
+
+func TestProfilesFromPolicy(t *testing.T) {
+	policy := CreateTrustPolicy("Market", map[string]interface{}{}, map[string]interface{}{
+		"profiles": map[string]interface{}{
+			"safety":  map[string]interface{}{"weights": map[string]interface{}{"authority_certs": 10}, "certifications": []interface{}{"haccp"}, "min_score": 10},
+			"halal":   map[string]interface{}{"name": "Halal", "weights": map[string]interface{}{"authority_certs": 5}, "certifications": []interface{}{"halal"}},
+			"invalid": "not a profile",
+		},
+	})
+	store := NewMemoryStore()
+	store.Put(policy)
+	resolved, err := ResolvePolicy(policy.Hash, store.Resolve)
+	if err != nil {
+		t.Fatal(err)
+	}
+	profiles := ProfilesFromPolicy(resolved)
+	if len(profiles) != 4 {
+		t.Errorf("profiles = %d, want defaults plus halal", len(profiles))
+	}
+	if profiles["safety"].Weights["authority_certs"] != 10 || profiles["safety"].MinScore != 10 {
+		t.Errorf("safety override = %+v", profiles["safety"])
+	}
+	if profiles["halal"].Name != "Halal" {
+		t.Errorf("halal = %+v", profiles["halal"])
+	}
+}
@@ -0,0 +1,130 @@
+package foodblock
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// ProvenanceKey is the state field annotating where field values came from:
+// a map of field name to the hash of the block the value was copied from.
+const ProvenanceKey = "_prov"
+
+// Provenance returns a block's provenance annotations.
+func Provenance(b Block) map[string]string {
+	out := map[string]string{}
+	switch p := b.State[ProvenanceKey].(type) {
+	case map[string]interface{}:
+		for field, src := range p {
+			if h, ok := src.(string); ok && h != "" {
+				out[field] = h
+			}
+		}
+	case map[string]string:
+		for field, h := range p {
+			if h != "" {
+				out[field] = h
+			}
+		}
+	}
+	return out
+}
+
+// Annotate records in state that fields were copied from a source block and
+// returns state.
+func Annotate(state map[string]interface{}, source string, fields ...string) map[string]interface{} {
+	prov := map[string]interface{}{}
+	for field, h := range Provenance(Block{State: state}) {
+		prov[field] = h
+	}
+	for _, f := range fields {
+		if _, ok := state[f]; ok {
+			prov[f] = source
+		}
+	}
+	if len(prov) > 0 {
+		state[ProvenanceKey] = prov
+	}
+	return state
+}
+
+// mergeProvenance keeps the previous annotations for fields changes leaves
+// alone and adds those given in changes.
+func mergeProvenance(previous, changes map[string]interface{}) map[string]interface{} {
+	out := map[string]interface{}{}
+	for field, h := range Provenance(Block{State: previous}) {
+		if _, changed := changes[field]; !changed {
+			out[field] = h
+		}
+	}
+	for field, h := range Provenance(Block{State: changes}) {
+		out[field] = h
+	}
+	return out
+}
+
+// ProvenanceStep is one block on a field value's trail.
+type ProvenanceStep struct {
+	Hash  string      `json:"hash"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+	// Via is how the value reached the previous step: "copied" (a _prov
+	// annotation), "updated" (unchanged from the version in refs.updates) or
+	// "merged" (from a fork in refs.merges). The last step is the origin and
+	// has Via "origin", or "missing" when its block cannot be resolved.
+	Via string `json:"via"`
+}
+
+// FieldProvenance traces where a block's field value came from, starting with
+// the block itself and ending at the block that set it. A value is followed
+// through _prov annotations, earlier versions that already held it and the
+// forks a merge block merged.
+func FieldProvenance(block Block, field string, store BlockStore) ([]ProvenanceStep, error) {
+	if _, ok := block.State[field]; !ok {
+		return nil, fmt.Errorf("FoodBlock: block has no field %s", field)
+	}
+	var trail []ProvenanceStep
+	seen := map[string]bool{}
+	current := &block
+	for current != nil && !seen[current.Hash] {
+		seen[current.Hash] = true
+		value := current.State[field]
+		step := ProvenanceStep{Hash: current.Hash, Type: current.Type, Value: value, Via: "origin"}
+		var next *Block
+		if src, ok := Provenance(*current)[field]; ok {
+			step.Via = "copied"
+			if next = store.Resolve(src); next == nil {
+				trail = append(trail, step, ProvenanceStep{Hash: src, Via: "missing"})
+				return trail, nil
+			}
+		} else if prev, ok := current.Refs["updates"].(string); ok && sameValue(store.Resolve(prev), field, value) {
+			step.Via, next = "updated", store.Resolve(prev)
+		} else {
+			parents := flattenRefValues(map[string]interface{}{"merges": current.Refs["merges"]})
+			sort.Strings(parents)
+			for _, h := range parents {
+				if p := store.Resolve(h); sameValue(p, field, value) {
+					step.Via, next = "merged", p
+					break
+				}
+			}
+		}
+		trail = append(trail, step)
+		current = next
+	}
+	return trail, nil
+}
+
+// sameValue reports whether b holds value in field.
+func sameValue(b *Block, field string, value interface{}) bool {
+	if b == nil {
+		return false
+	}
+	v, ok := b.State[field]
+	if !ok {
+		return false
+	}
+	x, _ := json.Marshal(v)
+	y, _ := json.Marshal(value)
+	return string(x) == string(y)
+}
@@ -0,0 +1,91 @@
+package foodblock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFieldProvenanceThroughTerms(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	offer, _ := Propose("mill", "bakery", "mill", Terms{Price: 2.5, Quantity: 40, Unit: "kg"}, ProposalOptions{})
+	accept, _ := Accept(offer, "bakery", now)
+	store.PutSigned(SignedBlock{FoodBlock: offer, AuthorHash: "mill"})
+	store.PutSigned(SignedBlock{FoodBlock: accept, AuthorHash: "bakery"})
+	terms, err := Referee(accept.Hash, store.Resolve, store.SignerOf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Provenance(terms)["price"] != offer.Hash {
+		t.Fatalf("_prov = %v", terms.State[ProvenanceKey])
+	}
+	store.Put(terms)
+
+	// Updating another field keeps the price annotation; changing the price
+	// drops it.
+	v2 := MergeUpdate(terms, map[string]interface{}{"quantity": 45}, nil)
+	if Provenance(v2)["price"] != offer.Hash || Provenance(v2)["quantity"] != "" {
+		t.Fatalf("v2 _prov = %v", v2.State[ProvenanceKey])
+	}
+	v3 := MergeUpdate(v2, map[string]interface{}{"note": "rush"}, nil)
+	store.PutAll([]Block{v2, v3})
+
+	trail, err := FieldProvenance(v3, "price", store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trail) != 2 || trail[0].Hash != v3.Hash || trail[0].Via != "copied" || trail[1].Hash != offer.Hash || trail[1].Via != "origin" {
+		t.Fatalf("trail = %+v", trail)
+	}
+
+	trail, _ = FieldProvenance(v3, "note", store)
+	if len(trail) != 1 || trail[0].Hash != v3.Hash || trail[0].Via != "origin" {
+		t.Errorf("note trail = %+v", trail)
+	}
+	trail, _ = FieldProvenance(v3, "quantity", store)
+	if len(trail) != 2 || trail[0].Via != "updated" || trail[1].Hash != v2.Hash {
+		t.Errorf("quantity trail = %+v", trail)
+	}
+
+	repriced := MergeUpdate(v3, map[string]interface{}{"price": 2.4}, nil)
+	if _, ok := Provenance(repriced)["price"]; ok {
+		t.Errorf("stale price annotation kept: %v", repriced.State[ProvenanceKey])
+	}
+	if _, err := FieldProvenance(v3, "missing", store); err == nil {
+		t.Error("expected an error for a missing field")
+	}
+}
+
+func TestFieldProvenanceThroughAutoMerge(t *testing.T) {
+	store := NewMemoryStore()
+	base := Create("substance.product", map[string]interface{}{"name": "Bread", "price": 4.0}, nil)
+	a := Update(base.Hash, base.Type, map[string]interface{}{"name": "Bread", "price": 4.5}, nil)
+	b := Update(base.Hash, base.Type, map[string]interface{}{"name": "Bread", "price": 5.0, "sku": "BR-1"}, nil)
+	store.PutAll([]Block{base, a, b})
+
+	merged, err := AutoMerge(a.Hash, b.Hash, store.Resolve, map[string]string{"price": "min"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	prov := Provenance(merged)
+	if prov["price"] != a.Hash || prov["sku"] != b.Hash || prov["name"] != "" {
+		t.Fatalf("_prov = %v", prov)
+	}
+	store.Put(merged)
+
+	trail, _ := FieldProvenance(merged, "name", store)
+	if len(trail) != 3 || trail[0].Via != "merged" || trail[2].Hash != base.Hash {
+		t.Errorf("name trail = %+v", trail)
+	}
+
+	// Annotations on the forks themselves do not conflict.
+	c := Update(base.Hash, base.Type, Annotate(map[string]interface{}{"name": "Bread", "price": 4.0}, "list-1", "price"), nil)
+	store.Put(c)
+	if _, err := AutoMerge(a.Hash, c.Hash, store.Resolve, map[string]string{"price": "max"}); err != nil {
+		t.Error(err)
+	}
+	trail, _ = FieldProvenance(c, "price", store)
+	if len(trail) != 2 || trail[1].Hash != "list-1" || trail[1].Via != "missing" {
+		t.Errorf("price trail = %+v", trail)
+	}
+}
@@ -1,20 +1,41 @@
 package foodblock
 
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+)
+
 // QueryParams holds query parameters for searching blocks.
 type QueryParams struct {
-	Type         string
-	Refs         map[string]string
-	StateFilters []StateFilter
-	Limit        int
-	Offset       int
-	HeadsOnly    bool
+	Type         string              `json:"type,omitempty"` // exact, or a pattern such as "substance.*" or "*.product"
+	Refs         map[string]string   `json:"refs,omitempty"`
+	RefsIn       map[string][]string `json:"refs_in,omitempty"`  // role -> any of these hashes
+	AnyRefs      []string            `json:"any_refs,omitempty"` // hashes referenced under any role
+	StateFilters []StateFilter       `json:"where,omitempty"`
+	OrderBy      []QueryOrder        `json:"order_by,omitempty"`
+	Limit        int                 `json:"limit,omitempty"`
+	Offset       int                 `json:"offset,omitempty"`
+	HeadsOnly    bool                `json:"latest,omitempty"`
+	// Expired blocks (see ExpiresAt) are left out unless IncludeExpired is
+	// set. Expiry is judged at AsOf, or now when it is zero.
+	IncludeExpired bool      `json:"include_expired,omitempty"`
+	AsOf           time.Time `json:"-"`
 }
 
 // StateFilter represents a filter condition on block state fields.
 type StateFilter struct {
-	Field string
-	Op    string // "eq", "lt", "gt"
-	Value interface{}
+	Field string      `json:"field"`
+	Op    string      `json:"op"` // "eq", "lt", "gt", "exists", "missing"
+	Value interface{} `json:"value,omitempty"`
+}
+
+// QueryOrder sorts results by a state field. Numbers sort numerically and
+// strings lexically; blocks without the field come last in either direction.
+type QueryOrder struct {
+	Field string `json:"field"`
+	Desc  bool   `json:"desc,omitempty"`
 }
 
 // QueryBuilder provides a fluent query interface for finding blocks.
@@ -34,7 +55,8 @@ func NewQuery(resolve func(QueryParams) ([]Block, error)) *QueryBuilder {
 	}
 }
 
-// Type filters by block type.
+// Type filters by block type. A "*" matches any part of the type, so
+// "substance.*" finds every substance and "*.product" every product.
 func (q *QueryBuilder) Type(t string) *QueryBuilder {
 	q.params.Type = t
 	return q
@@ -46,6 +68,21 @@ func (q *QueryBuilder) ByRef(role, hash string) *QueryBuilder {
 	return q
 }
 
+// ByAnyRef filters to blocks referencing hash under any role.
+func (q *QueryBuilder) ByAnyRef(hash string) *QueryBuilder {
+	q.params.AnyRefs = append(q.params.AnyRefs, hash)
+	return q
+}
+
+// ByRefIn filters to blocks whose role reference points at any of hashes.
+func (q *QueryBuilder) ByRefIn(role string, hashes ...string) *QueryBuilder {
+	if q.params.RefsIn == nil {
+		q.params.RefsIn = make(map[string][]string)
+	}
+	q.params.RefsIn[role] = append(q.params.RefsIn[role], hashes...)
+	return q
+}
+
 // WhereEq adds an equality filter on a state field.
 func (q *QueryBuilder) WhereEq(field string, value interface{}) *QueryBuilder {
 	q.params.StateFilters = append(q.params.StateFilters, StateFilter{Field: field, Op: "eq", Value: value})
@@ -64,12 +101,37 @@ func (q *QueryBuilder) WhereGt(field string, value interface{}) *QueryBuilder {
 	return q
 }
 
+// WhereExists keeps blocks whose state has the field.
+func (q *QueryBuilder) WhereExists(field string) *QueryBuilder {
+	q.params.StateFilters = append(q.params.StateFilters, StateFilter{Field: field, Op: "exists"})
+	return q
+}
+
+// WhereMissing keeps blocks whose state lacks the field.
+func (q *QueryBuilder) WhereMissing(field string) *QueryBuilder {
+	q.params.StateFilters = append(q.params.StateFilters, StateFilter{Field: field, Op: "missing"})
+	return q
+}
+
+// OrderBy sorts results by a state field, "asc" or "desc". Later calls
+// break ties left by earlier ones.
+func (q *QueryBuilder) OrderBy(field, direction string) *QueryBuilder {
+	q.params.OrderBy = append(q.params.OrderBy, QueryOrder{Field: field, Desc: direction == "desc"})
+	return q
+}
+
 // Latest restricts results to head blocks only (latest in update chains).
 func (q *QueryBuilder) Latest() *QueryBuilder {
 	q.params.HeadsOnly = true
 	return q
 }
 
+// IncludeExpired keeps blocks past their expiry in the results.
+func (q *QueryBuilder) IncludeExpired() *QueryBuilder {
+	q.params.IncludeExpired = true
+	return q
+}
+
 // Limit sets the maximum number of results.
 func (q *QueryBuilder) Limit(n int) *QueryBuilder {
 	q.params.Limit = n
@@ -82,7 +144,191 @@ func (q *QueryBuilder) Offset(n int) *QueryBuilder {
 	return q
 }
 
+// Params returns the parameters built so far, for saving with SaveQuery.
+func (q *QueryBuilder) Params() QueryParams {
+	return q.params
+}
+
 // Exec executes the query and returns matching blocks.
 func (q *QueryBuilder) Exec() ([]Block, error) {
 	return q.resolve(q.params)
 }
+
+// FilterBlocks evaluates query parameters against an in-memory set of blocks.
+// Results keep the input order unless params.OrderBy sorts them.
+func FilterBlocks(blocks []Block, params QueryParams) []Block {
+	superseded := map[string]bool{}
+	if params.HeadsOnly {
+		for _, b := range blocks {
+			for _, h := range flattenRefValues(map[string]interface{}{"updates": b.Refs["updates"]}) {
+				superseded[h] = true
+			}
+		}
+	}
+
+	now := params.AsOf
+	if now.IsZero() {
+		now = time.Now()
+	}
+	var matched []Block
+	for _, b := range blocks {
+		if params.Type != "" && !matchesType(b.Type, params.Type) {
+			continue
+		}
+		if !params.IncludeExpired && IsExpired(b, now) {
+			continue
+		}
+		if params.HeadsOnly && superseded[b.Hash] {
+			continue
+		}
+		if !matchesRefs(b, params) || !matchesFilters(b, params.StateFilters) {
+			continue
+		}
+		matched = append(matched, b)
+	}
+	if len(params.OrderBy) > 0 {
+		sort.SliceStable(matched, func(i, j int) bool { return orderedBefore(matched[i], matched[j], params.OrderBy) })
+	}
+
+	var out []Block
+	skipped := 0
+	for _, b := range matched {
+		if skipped < params.Offset {
+			skipped++
+			continue
+		}
+		out = append(out, b)
+		if params.Limit > 0 && len(out) >= params.Limit {
+			break
+		}
+	}
+	return out
+}
+
+func matchesRefs(b Block, params QueryParams) bool {
+	for role, hash := range params.Refs {
+		if !containsStr(flattenRefValues(map[string]interface{}{role: b.Refs[role]}), hash) {
+			return false
+		}
+	}
+	for role, hashes := range params.RefsIn {
+		found := false
+		for _, h := range flattenRefValues(map[string]interface{}{role: b.Refs[role]}) {
+			found = found || containsStr(hashes, h)
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(params.AnyRefs) > 0 {
+		all := flattenRefValues(b.Refs)
+		for _, hash := range params.AnyRefs {
+			if !containsStr(all, hash) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func matchesFilters(b Block, filters []StateFilter) bool {
+	for _, f := range filters {
+		v, ok := b.State[f.Field]
+		if f.Op == "missing" {
+			if ok {
+				return false
+			}
+			continue
+		}
+		if !ok {
+			return false
+		}
+		switch f.Op {
+		case "exists":
+		case "eq":
+			if !stateValuesEqual(v, f.Value) {
+				return false
+			}
+		case "lt", "gt":
+			a, aok := toFloat64(v)
+			c, cok := toFloat64(f.Value)
+			if !aok || !cok || (f.Op == "lt" && a >= c) || (f.Op == "gt" && a <= c) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// stateValuesEqual compares state values: numbers numerically, arrays and
+// objects by their canonical encoding, anything else with DeepEqual.
+func stateValuesEqual(a, b interface{}) bool {
+	if x, ok := toFloat64(a); ok {
+		if y, ok := toFloat64(b); ok {
+			return x == y
+		}
+	}
+	switch a.(type) {
+	case []interface{}, map[string]interface{}:
+		switch b.(type) {
+		case []interface{}, map[string]interface{}:
+			return stringify(a, false) == stringify(b, false)
+		}
+		return false
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// orderedBefore reports whether a sorts before b under orders.
+func orderedBefore(a, b Block, orders []QueryOrder) bool {
+	for _, o := range orders {
+		x, xok := a.State[o.Field]
+		y, yok := b.State[o.Field]
+		if !xok || !yok {
+			if xok != yok {
+				return xok
+			}
+			continue
+		}
+		c := compareValues(x, y)
+		if c == 0 {
+			continue
+		}
+		if o.Desc {
+			return c > 0
+		}
+		return c < 0
+	}
+	return false
+}
+
+// compareValues orders numbers numerically, before any other value, and
+// everything else by its string form.
+func compareValues(x, y interface{}) int {
+	a, aok := toFloat64(x)
+	b, bok := toFloat64(y)
+	switch {
+	case aok && bok:
+		if a < b {
+			return -1
+		}
+		if a > b {
+			return 1
+		}
+		return 0
+	case aok:
+		return -1
+	case bok:
+		return 1
+	}
+	sa, sb := fmt.Sprint(x), fmt.Sprint(y)
+	if sa < sb {
+		return -1
+	}
+	if sa > sb {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,288 @@
+package foodblock
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ReceiptType is a donation receipt issued for a transfer.donation.
+const ReceiptType = "observe.receipt"
+
+// ReceiptItem is one donated item with its fair-market value.
+type ReceiptItem struct {
+	Item      string  `json:"item"`
+	Name      string  `json:"name"`
+	Quantity  float64 `json:"quantity"`
+	Unit      string  `json:"unit,omitempty"`
+	UnitValue float64 `json:"unit_value"`
+	Value     float64 `json:"value"`
+	Basis     string  `json:"basis"` // declared, price_history, list_price or none
+}
+
+// DonationReceipt is the resolved content of a donation receipt.
+type DonationReceipt struct {
+	Number        string        `json:"number"`
+	Donation      string        `json:"donation"`
+	Date          string        `json:"date"`
+	Donor         string        `json:"donor"`
+	DonorName     string        `json:"donor_name"`
+	Recipient     string        `json:"recipient"`
+	RecipientName string        `json:"recipient_name"`
+	Registration  string        `json:"registration,omitempty"` // recipient charity number
+	Currency      string        `json:"currency,omitempty"`
+	Items         []ReceiptItem `json:"items"`
+	TotalValue    float64       `json:"total_value"`
+	Block         Block         `json:"block"`
+}
+
+// GenerateDonationReceipt resolves a transfer.donation and builds its receipt
+// and observe.receipt block. The donor is refs.source (or refs.donor), the
+// recipient refs.recipient (or refs.buyer), the items refs.item(s). Each
+// item's value is the donation's declared value if any, otherwise the latest
+// price for the item or its product on or before the donation date, otherwise
+// the item's own list price.
+func GenerateDonationReceipt(donationHash string, store BlockStore) (DonationReceipt, error) {
+	donation := store.Resolve(donationHash)
+	if donation == nil {
+		return DonationReceipt{}, fmt.Errorf("FoodBlock: donation not found: %s", donationHash)
+	}
+	if donation.Type != "transfer.donation" {
+		return DonationReceipt{}, fmt.Errorf("FoodBlock: %s is not a donation", donationHash)
+	}
+	r := DonationReceipt{Donation: donation.Hash, Number: "DR-" + donation.Hash[:12]}
+	r.Donor = firstRef(donation.Refs, "source", "donor", "seller")
+	r.Recipient = firstRef(donation.Refs, "recipient", "buyer", "charity")
+	if r.Donor == "" || r.Recipient == "" {
+		return DonationReceipt{}, errors.New("FoodBlock: donation needs a donor and a recipient")
+	}
+	r.DonorName = blockName(store, r.Donor)
+	r.RecipientName = blockName(store, r.Recipient)
+	if rec := store.Resolve(r.Recipient); rec != nil {
+		r.Registration = firstString(rec.State, "charity_number", "registration", "tax_id")
+	}
+	date, ok := BlockDate(*donation)
+	if !ok {
+		return DonationReceipt{}, errors.New("FoodBlock: donation has no date")
+	}
+	r.Date = date.Format("2006-01-02")
+	r.Currency, _ = donation.State["currency"].(string)
+
+	items := flattenRefValues(map[string]interface{}{"item": donation.Refs["item"], "items": donation.Refs["items"]})
+	prices := priceHistory(store)
+	declared, hasDeclared := numericValue(donation.State["fair_market_value"])
+	if !hasDeclared {
+		declared, hasDeclared = numericValue(donation.State["value"])
+	}
+	for _, h := range items {
+		item := ReceiptItem{Item: h, Name: blockName(store, h), Basis: "none"}
+		b := store.Resolve(h)
+		if len(items) == 1 {
+			if q, unit, ok := blockMass(*donation); ok {
+				item.Quantity, item.Unit = q, unit
+			}
+		}
+		if item.Quantity == 0 && b != nil {
+			item.Quantity, item.Unit, _ = blockMass(*b)
+		}
+		switch {
+		case hasDeclared && len(items) == 1:
+			item.Value, item.Basis = declared, "declared"
+		default:
+			unit, currency, ok := prices.at(h, b, date)
+			if ok {
+				item.UnitValue, item.Basis = unit, "price_history"
+				if r.Currency == "" {
+					r.Currency = currency
+				}
+			} else if b != nil {
+				if p, ok := numericValue(b.State["price"]); ok {
+					item.UnitValue, item.Basis = p, "list_price"
+				}
+			}
+			item.Value = roundCents(item.UnitValue * math.Max(item.Quantity, 1))
+		}
+		r.TotalValue += item.Value
+		r.Items = append(r.Items, item)
+	}
+	if hasDeclared && len(items) != 1 {
+		r.TotalValue = declared
+	}
+	r.TotalValue = roundCents(r.TotalValue)
+
+	lines := make([]interface{}, len(r.Items))
+	for i, it := range r.Items {
+		lines[i] = map[string]interface{}{
+			"item": it.Item, "name": it.Name, "quantity": it.Quantity, "unit": it.Unit,
+			"value": it.Value, "basis": it.Basis,
+		}
+	}
+	state := map[string]interface{}{
+		"instance_id":    r.Number,
+		"receipt_number": r.Number,
+		"date":           r.Date,
+		"total_value":    r.TotalValue,
+		"items":          lines,
+	}
+	if r.Currency != "" {
+		state["currency"] = r.Currency
+	}
+	r.Block = Create(ReceiptType, state, map[string]interface{}{
+		"donation": donation.Hash, "donor": r.Donor, "recipient": r.Recipient,
+	})
+	return r, nil
+}
+
+// Text renders the receipt as a printable document.
+func (r DonationReceipt) Text() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "DONATION RECEIPT %s\n", r.Number)
+	fmt.Fprintf(&sb, "Date:      %s\n", r.Date)
+	fmt.Fprintf(&sb, "Donor:     %s\n", r.DonorName)
+	fmt.Fprintf(&sb, "Recipient: %s", r.RecipientName)
+	if r.Registration != "" {
+		fmt.Fprintf(&sb, " (registered charity %s)", r.Registration)
+	}
+	sb.WriteString("\n\n")
+	for _, it := range r.Items {
+		fmt.Fprintf(&sb, "  %-28s %8.2f %-4s %10.2f  [%s]\n", it.Name, it.Quantity, it.Unit, it.Value, it.Basis)
+	}
+	fmt.Fprintf(&sb, "\nTotal fair-market value: %.2f %s\n", r.TotalValue, r.Currency)
+	fmt.Fprintf(&sb, "No goods or services were provided in exchange for this donation.\n")
+	fmt.Fprintf(&sb, "Verify: fb:%s\n", r.Block.Hash)
+	return sb.String()
+}
+
+// DonorYear totals a donor's receipts for one calendar year.
+type DonorYear struct {
+	Donor       string             `json:"donor"`
+	Year        int                `json:"year"`
+	Receipts    []string           `json:"receipts"`
+	TotalValue  float64            `json:"total_value"`
+	ByRecipient map[string]float64 `json:"by_recipient"`
+}
+
+// DonorYearlyReceipts aggregates the observe.receipt blocks in blocks for a
+// donor by calendar year, oldest year first.
+func DonorYearlyReceipts(donor string, blocks []Block) []DonorYear {
+	years := map[int]*DonorYear{}
+	for _, b := range blocks {
+		if b.Type != ReceiptType || b.Refs["donor"] != donor {
+			continue
+		}
+		date, ok := BlockDate(b)
+		if !ok {
+			continue
+		}
+		y, ok := years[date.Year()]
+		if !ok {
+			y = &DonorYear{Donor: donor, Year: date.Year(), ByRecipient: map[string]float64{}}
+			years[date.Year()] = y
+		}
+		value, _ := toFloat64(b.State["total_value"])
+		recipient, _ := b.Refs["recipient"].(string)
+		y.Receipts = append(y.Receipts, b.Hash)
+		y.TotalValue = roundCents(y.TotalValue + value)
+		y.ByRecipient[recipient] = roundCents(y.ByRecipient[recipient] + value)
+	}
+	out := make([]DonorYear, 0, len(years))
+	for _, y := range years {
+		out = append(out, *y)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Year < out[j].Year })
+	return out
+}
+
+type pricePoint struct {
+	date     time.Time
+	unit     float64
+	currency string
+}
+
+// priceIndex holds dated unit prices keyed by the item or product they price.
+type priceIndex map[string][]pricePoint
+
+// priceHistory collects unit prices from orders and observe.price blocks.
+func priceHistory(store BlockStore) priceIndex {
+	p := priceIndex{}
+	for _, b := range store.Blocks() {
+		if !strings.HasPrefix(b.Type, "transfer.order") && b.Type != "observe.price" {
+			continue
+		}
+		date, ok := BlockDate(b)
+		if !ok {
+			continue
+		}
+		unit, ok := numericValue(b.State["price"])
+		if !ok {
+			total, hasTotal := numericValue(b.State["total"])
+			qty, hasQty := numericValue(b.State["quantity"])
+			if !hasTotal || !hasQty || qty == 0 {
+				continue
+			}
+			unit = total / qty
+		}
+		currency, _ := b.State["currency"].(string)
+		for _, key := range []string{"product", "item", "subject"} {
+			if h, ok := b.Refs[key].(string); ok {
+				p[h] = append(p[h], pricePoint{date, unit, currency})
+			}
+		}
+	}
+	return p
+}
+
+// at returns the latest price on or before date for the item or its product.
+func (p priceIndex) at(hash string, item *Block, date time.Time) (float64, string, bool) {
+	keys := []string{hash}
+	if item != nil {
+		if product, ok := item.Refs["product"].(string); ok {
+			keys = append(keys, product)
+		}
+	}
+	var best *pricePoint
+	for _, k := range keys {
+		for i, pt := range p[k] {
+			if pt.date.After(date) {
+				continue
+			}
+			if best == nil || pt.date.After(best.date) {
+				best = &p[k][i]
+			}
+		}
+		if best != nil {
+			return best.unit, best.currency, true
+		}
+	}
+	return 0, "", false
+}
+
+func firstRef(refs map[string]interface{}, keys ...string) string {
+	for _, k := range keys {
+		if s, ok := refs[k].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+func firstString(state map[string]interface{}, keys ...string) string {
+	for _, k := range keys {
+		if s, ok := state[k].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+func blockName(store BlockStore, hash string) string {
+	if b := store.Resolve(hash); b != nil {
+		if name, ok := b.State["name"].(string); ok && name != "" {
+			return name
+		}
+	}
+	return hash
+}
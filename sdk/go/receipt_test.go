@@ -0,0 +1,71 @@
+package foodblock
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateDonationReceipt(t *testing.T) {
+	store := NewMemoryStore()
+	bakery := Create("actor.venue", map[string]interface{}{"name": "Corner Bakery"}, nil)
+	charity := Create("actor.foodbank", map[string]interface{}{"name": "City Food Bank", "charity_number": "1234567"}, nil)
+	bread := Create("substance.product", map[string]interface{}{"name": "Sourdough", "price": 4.0}, nil)
+	surplus := Create("substance.surplus", map[string]interface{}{"name": "Sourdough loaves", "quantity": 10.0, "unit": "each"},
+		map[string]interface{}{"product": bread.Hash, "seller": bakery.Hash})
+	orders := []Block{
+		Create("transfer.order", map[string]interface{}{"instance_id": "o1", "price": 3.5, "quantity": 2.0, "date": "2026-02-01", "currency": "GBP"},
+			map[string]interface{}{"product": bread.Hash}),
+		Create("transfer.order", map[string]interface{}{"instance_id": "o2", "total": 7.6, "quantity": 2.0, "date": "2026-03-01", "currency": "GBP"},
+			map[string]interface{}{"product": bread.Hash}),
+		Create("transfer.order", map[string]interface{}{"instance_id": "o3", "price": 9.0, "quantity": 1.0, "date": "2026-06-01", "currency": "GBP"},
+			map[string]interface{}{"product": bread.Hash}),
+	}
+	donation := Create("transfer.donation", map[string]interface{}{"instance_id": "d1", "date": "2026-03-15"},
+		map[string]interface{}{"source": bakery.Hash, "recipient": charity.Hash, "item": surplus.Hash})
+	store.PutAll(append([]Block{bakery, charity, bread, surplus, donation}, orders...))
+
+	r, err := GenerateDonationReceipt(donation.Hash, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Items) != 1 || r.Items[0].Basis != "price_history" || r.Items[0].UnitValue != 3.8 {
+		t.Fatalf("expected the March price of 3.80, got %+v", r.Items)
+	}
+	if r.TotalValue != 38 || r.Currency != "GBP" || r.Registration != "1234567" {
+		t.Errorf("unexpected receipt %+v", r)
+	}
+	if r.Block.Type != ReceiptType || r.Block.Refs["donation"] != donation.Hash {
+		t.Errorf("unexpected receipt block %+v", r.Block)
+	}
+	again, _ := GenerateDonationReceipt(donation.Hash, store)
+	if again.Block.Hash != r.Block.Hash {
+		t.Error("receipt block should be deterministic")
+	}
+	text := r.Text()
+	for _, want := range []string{"Corner Bakery", "City Food Bank", "1234567", "38.00 GBP"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("receipt text missing %q:\n%s", want, text)
+		}
+	}
+
+	declared := Create("transfer.donation", map[string]interface{}{"instance_id": "d2", "date": "2026-11-02", "fair_market_value": 120.0, "currency": "GBP"},
+		map[string]interface{}{"source": bakery.Hash, "recipient": charity.Hash, "item": surplus.Hash})
+	store.Put(declared)
+	r2, err := GenerateDonationReceipt(declared.Hash, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r2.TotalValue != 120 || r2.Items[0].Basis != "declared" {
+		t.Errorf("expected the declared value, got %+v", r2)
+	}
+	if _, err := GenerateDonationReceipt(bread.Hash, store); err == nil {
+		t.Error("expected an error for a non-donation")
+	}
+
+	lastYear := Create(ReceiptType, map[string]interface{}{"instance_id": "old", "date": "2025-12-01", "total_value": 10.0},
+		map[string]interface{}{"donor": bakery.Hash, "recipient": charity.Hash})
+	years := DonorYearlyReceipts(bakery.Hash, []Block{r.Block, r2.Block, lastYear})
+	if len(years) != 2 || years[0].Year != 2025 || years[1].TotalValue != 158 || years[1].ByRecipient[charity.Hash] != 158 {
+		t.Errorf("unexpected yearly totals %+v", years)
+	}
+}
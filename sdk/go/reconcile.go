@@ -0,0 +1,402 @@
+package foodblock
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// FilterScope selects which blocks a sync filter covers.
+type FilterScope struct {
+	Types []string `json:"types,omitempty"` // exact types or "prefix.*" patterns; empty means all
+	// ExpectedDiff sizes the IBLT. Differences much larger than this cannot be
+	// decoded and fall back to the Bloom filter. Defaults to 64.
+	ExpectedDiff int `json:"expected_diff,omitempty"`
+	// FalsePositive is the Bloom filter false-positive rate. Defaults to 0.01.
+	FalsePositive float64 `json:"false_positive,omitempty"`
+}
+
+func (sc FilterScope) includes(typ string) bool {
+	if len(sc.Types) == 0 {
+		return true
+	}
+	for _, p := range sc.Types {
+		if matchesType(typ, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Limits on filters received from peers, checked before anything is
+// allocated from them.
+const (
+	maxBloomHashes = 32
+	maxBloomBytes  = 16 << 20
+	maxIBLTCells   = 1 << 20
+)
+
+// BloomFilter is a fixed-size Bloom filter over block hashes.
+type BloomFilter struct {
+	Bits   []byte `json:"bits"`
+	Hashes int    `json:"k"`
+}
+
+// NewBloomFilter sizes a filter for n entries at the given false-positive rate.
+func NewBloomFilter(n int, fpRate float64) *BloomFilter {
+	if n < 1 {
+		n = 1
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		fpRate = 0.01
+	}
+	m := int(math.Ceil(-float64(n) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	k := int(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &BloomFilter{Bits: make([]byte, (m+7)/8), Hashes: k}
+}
+
+// check rejects a filter whose parameters could not have come from
+// NewBloomFilter, such as one received from a misbehaving peer.
+func (f *BloomFilter) check() error {
+	if f.Hashes < 1 || f.Hashes > maxBloomHashes {
+		return fmt.Errorf("FoodBlock: Bloom filter hash count %d outside 1..%d", f.Hashes, maxBloomHashes)
+	}
+	if len(f.Bits) == 0 || len(f.Bits) > maxBloomBytes {
+		return fmt.Errorf("FoodBlock: Bloom filter size %d bytes outside 1..%d", len(f.Bits), maxBloomBytes)
+	}
+	return nil
+}
+
+func (f *BloomFilter) positions(hash string) []uint64 {
+	key := filterKey(hash)
+	h1 := binary.BigEndian.Uint64(key[0:8])
+	h2 := binary.BigEndian.Uint64(key[8:16]) | 1
+	m := uint64(len(f.Bits) * 8)
+	out := make([]uint64, f.Hashes)
+	for i := range out {
+		out[i] = (h1 + uint64(i)*h2) % m
+	}
+	return out
+}
+
+// Add inserts a hash.
+func (f *BloomFilter) Add(hash string) {
+	for _, p := range f.positions(hash) {
+		f.Bits[p/8] |= 1 << (p % 8)
+	}
+}
+
+// Has reports whether a hash is probably in the filter. False positives are
+// possible; false negatives are not.
+func (f *BloomFilter) Has(hash string) bool {
+	if len(f.Bits) == 0 {
+		return false
+	}
+	for _, p := range f.positions(hash) {
+		if f.Bits[p/8]&(1<<(p%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// ibltCell is one cell of an invertible Bloom lookup table.
+type ibltCell struct {
+	Count   int32
+	KeySum  [32]byte
+	HashSum uint64
+}
+
+// IBLT is an invertible Bloom lookup table over block hashes. Subtracting two
+// tables and peeling the result yields the symmetric difference of the sets.
+type IBLT struct {
+	cells []ibltCell
+}
+
+const ibltHashes = 3
+
+// NewIBLT creates a table able to decode roughly expectedDiff differences.
+func NewIBLT(expectedDiff int) *IBLT {
+	if expectedDiff < 1 {
+		expectedDiff = 1
+	}
+	per := (expectedDiff*3/2 + ibltHashes) / ibltHashes
+	return &IBLT{cells: make([]ibltCell, per*ibltHashes)}
+}
+
+func (t *IBLT) indexes(key [32]byte) [ibltHashes]int {
+	per := uint32(len(t.cells) / ibltHashes)
+	var idx [ibltHashes]int
+	for i := range idx {
+		idx[i] = i*int(per) + int(binary.BigEndian.Uint32(key[16+4*i:])%per)
+	}
+	return idx
+}
+
+func checksum(key [32]byte) uint64 {
+	sum := sha256.Sum256(key[:])
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+func (t *IBLT) update(key [32]byte, delta int32) {
+	sum := checksum(key)
+	for _, i := range t.indexes(key) {
+		c := &t.cells[i]
+		c.Count += delta
+		for j := range c.KeySum {
+			c.KeySum[j] ^= key[j]
+		}
+		c.HashSum ^= sum
+	}
+}
+
+// Add inserts a hash.
+func (t *IBLT) Add(hash string) {
+	t.update(filterKey(hash), 1)
+}
+
+// Subtract returns t minus other. Both tables must have the same size.
+func (t *IBLT) Subtract(other *IBLT) (*IBLT, error) {
+	if len(t.cells) != len(other.cells) {
+		return nil, errors.New("FoodBlock: IBLT sizes differ")
+	}
+	out := &IBLT{cells: make([]ibltCell, len(t.cells))}
+	for i := range t.cells {
+		a, b := t.cells[i], other.cells[i]
+		c := ibltCell{Count: a.Count - b.Count, HashSum: a.HashSum ^ b.HashSum}
+		for j := range c.KeySum {
+			c.KeySum[j] = a.KeySum[j] ^ b.KeySum[j]
+		}
+		out.cells[i] = c
+	}
+	return out, nil
+}
+
+// Decode peels a (subtracted) table into hashes present only on the positive
+// side and only on the negative side. It fails when the difference is too
+// large for the table.
+func (t *IBLT) Decode() (positive, negative []string, err error) {
+	cells := append([]ibltCell(nil), t.cells...)
+	work := &IBLT{cells: cells}
+	for progress := true; progress; {
+		progress = false
+		for i := range cells {
+			c := cells[i]
+			if (c.Count != 1 && c.Count != -1) || checksum(c.KeySum) != c.HashSum {
+				continue
+			}
+			h := hex.EncodeToString(c.KeySum[:])
+			if c.Count == 1 {
+				positive = append(positive, h)
+			} else {
+				negative = append(negative, h)
+			}
+			work.update(c.KeySum, -c.Count)
+			progress = true
+		}
+	}
+	for _, c := range cells {
+		if c.Count != 0 || c.HashSum != 0 {
+			return nil, nil, errors.New("FoodBlock: IBLT difference too large to decode")
+		}
+	}
+	sort.Strings(positive)
+	sort.Strings(negative)
+	return positive, negative, nil
+}
+
+// MarshalJSON encodes the table compactly for transfer.
+func (t *IBLT) MarshalJSON() ([]byte, error) {
+	buf := make([]byte, 0, len(t.cells)*44)
+	for _, c := range t.cells {
+		buf = binary.BigEndian.AppendUint32(buf, uint32(c.Count))
+		buf = append(buf, c.KeySum[:]...)
+		buf = binary.BigEndian.AppendUint64(buf, c.HashSum)
+	}
+	return json.Marshal(base64.StdEncoding.EncodeToString(buf))
+}
+
+// UnmarshalJSON decodes a table produced by MarshalJSON.
+func (t *IBLT) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	buf, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(buf) == 0 || len(buf)%44 != 0 || len(buf)/44%ibltHashes != 0 {
+		return errors.New("FoodBlock: invalid IBLT encoding")
+	}
+	if len(buf)/44 > maxIBLTCells {
+		return fmt.Errorf("FoodBlock: IBLT has more than %d cells", maxIBLTCells)
+	}
+	t.cells = make([]ibltCell, len(buf)/44)
+	for i := range t.cells {
+		c := buf[i*44:]
+		t.cells[i].Count = int32(binary.BigEndian.Uint32(c))
+		copy(t.cells[i].KeySum[:], c[4:36])
+		t.cells[i].HashSum = binary.BigEndian.Uint64(c[36:44])
+	}
+	return nil
+}
+
+// SyncFilter summarizes a peer's block set for reconciliation.
+type SyncFilter struct {
+	Scope FilterScope  `json:"scope"`
+	Count int          `json:"count"`
+	Bloom *BloomFilter `json:"bloom"`
+	Table *IBLT        `json:"iblt"`
+
+	hashes []string // retained on the local side for the Bloom fallback
+}
+
+// BuildFilter summarizes the blocks of a store within a scope.
+func BuildFilter(store BlockStore, scope FilterScope) *SyncFilter {
+	if scope.ExpectedDiff <= 0 {
+		scope.ExpectedDiff = 64
+	}
+	if scope.FalsePositive <= 0 {
+		scope.FalsePositive = 0.01
+	}
+	var hashes []string
+	for _, b := range store.Blocks() {
+		if scope.includes(b.Type) {
+			hashes = append(hashes, b.Hash)
+		}
+	}
+	f := &SyncFilter{
+		Scope:  scope,
+		Count:  len(hashes),
+		Bloom:  NewBloomFilter(len(hashes), scope.FalsePositive),
+		Table:  NewIBLT(scope.ExpectedDiff),
+		hashes: hashes,
+	}
+	for _, h := range hashes {
+		f.Bloom.Add(h)
+		f.Table.Add(h)
+	}
+	return f
+}
+
+// ReconcileResult lists the hashes each side lacks.
+type ReconcileResult struct {
+	Missing []string // on the remote, not local
+	Extra   []string // local, not on the remote
+	// Exact is false when the IBLT could not be decoded. Extra then comes from
+	// the remote Bloom filter (probable) and Missing is unknown.
+	Exact bool
+}
+
+// Reconcile compares a local filter with a remote one. The remote filter must
+// have been built with the same scope sizing; a malformed one is an error.
+func Reconcile(local, remote *SyncFilter) (ReconcileResult, error) {
+	if local == nil || remote == nil || local.Table == nil || remote.Table == nil {
+		return ReconcileResult{}, errors.New("FoodBlock: both filters are required")
+	}
+	if remote.Bloom != nil {
+		if err := remote.Bloom.check(); err != nil {
+			return ReconcileResult{}, err
+		}
+	}
+	diff, err := local.Table.Subtract(remote.Table)
+	if err == nil {
+		extra, missing, derr := diff.Decode()
+		if derr == nil {
+			return ReconcileResult{Missing: missing, Extra: extra, Exact: true}, nil
+		}
+	}
+	if local.hashes == nil || remote.Bloom == nil {
+		return ReconcileResult{}, errors.New("FoodBlock: difference too large and no Bloom fallback available")
+	}
+	var extra []string
+	for _, h := range local.hashes {
+		if !remote.Bloom.Has(h) {
+			extra = append(extra, h)
+		}
+	}
+	return ReconcileResult{Extra: extra}, nil
+}
+
+// SyncRemote is a peer that can summarize its blocks and serve them by hash.
+type SyncRemote interface {
+	Filter(scope FilterScope) (*SyncFilter, error)
+	Fetch(hashes []string) ([]Block, error)
+}
+
+// maxSyncAttempts bounds how many filter exchanges SyncFrom makes.
+const maxSyncAttempts = 3
+
+// SyncFrom pulls blocks the remote has and the local store lacks, exchanging
+// filters instead of full hash lists. It returns the number of blocks stored.
+//
+// When the difference is too large for the IBLT, the Bloom filters estimate
+// its size and the exchange is repeated with a table sized to decode it, so
+// a large peer costs one more round trip rather than a full listing.
+func SyncFrom(local BlockStore, remote SyncRemote, scope FilterScope) (int, error) {
+	var result ReconcileResult
+	for attempt := 1; ; attempt++ {
+		localFilter := BuildFilter(local, scope)
+		remoteFilter, err := remote.Filter(localFilter.Scope)
+		if err != nil {
+			return 0, err
+		}
+		result, err = Reconcile(localFilter, remoteFilter)
+		if err != nil {
+			return 0, err
+		}
+		if result.Exact {
+			break
+		}
+		// Local blocks the remote lacks, plus the remote's blocks beyond
+		// those the two share. Bloom false positives undercount, hence the
+		// headroom.
+		extra := len(result.Extra)
+		missing := remoteFilter.Count - (localFilter.Count - extra)
+		if missing < 0 {
+			missing = 0
+		}
+		next := 2 * (extra + missing)
+		if next < 2*localFilter.Scope.ExpectedDiff {
+			next = 2 * localFilter.Scope.ExpectedDiff
+		}
+		if attempt == maxSyncAttempts || len(NewIBLT(next).cells) > maxIBLTCells {
+			return 0, errors.New("FoodBlock: difference too large for filter sync; fall back to a full listing")
+		}
+		scope = localFilter.Scope
+		scope.ExpectedDiff = next
+	}
+	if len(result.Missing) == 0 {
+		return 0, nil
+	}
+	blocks, err := remote.Fetch(result.Missing)
+	if err != nil {
+		return 0, err
+	}
+	stored := 0
+	for _, b := range blocks {
+		if err := local.Put(b); err != nil {
+			return stored, err
+		}
+		stored++
+	}
+	return stored, nil
+}
+
+// filterKey converts a hex block hash to a 32-byte key. Non-hex input is
+// hashed so arbitrary identifiers still work.
+func filterKey(hash string) [32]byte {
+	var key [32]byte
+	if b, err := hex.DecodeString(hash); err == nil && len(b) == 32 {
+		copy(key[:], b)
+		return key
+	}
+	return sha256.Sum256([]byte(hash))
+}
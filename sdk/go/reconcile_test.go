@@ -0,0 +1,154 @@
+package foodblock
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+type memRemote struct{ store *MemoryStore }
+
+func (r memRemote) Filter(scope FilterScope) (*SyncFilter, error) {
+	// Round-trip through JSON as a network peer would.
+	data, err := json.Marshal(BuildFilter(r.store, scope))
+	if err != nil {
+		return nil, err
+	}
+	var f SyncFilter
+	return &f, json.Unmarshal(data, &f)
+}
+
+func (r memRemote) Fetch(hashes []string) ([]Block, error) {
+	var out []Block
+	for _, h := range hashes {
+		if b := r.store.Resolve(h); b != nil {
+			out = append(out, *b)
+		}
+	}
+	return out, nil
+}
+
+func populate(store *MemoryStore, prefix string, n int) {
+	for i := 0; i < n; i++ {
+		store.Put(Create("substance.product", map[string]interface{}{"name": fmt.Sprintf("%s %d", prefix, i)}, nil))
+	}
+}
+
+func TestBloomFilter(t *testing.T) {
+	f := NewBloomFilter(1000, 0.01)
+	for i := 0; i < 1000; i++ {
+		f.Add(Sha256Hex(fmt.Sprint(i)))
+	}
+	for i := 0; i < 1000; i++ {
+		if !f.Has(Sha256Hex(fmt.Sprint(i))) {
+			t.Fatal("false negative")
+		}
+	}
+	fp := 0
+	for i := 1000; i < 11000; i++ {
+		if f.Has(Sha256Hex(fmt.Sprint(i))) {
+			fp++
+		}
+	}
+	if fp > 300 {
+		t.Errorf("false positive rate too high: %d/10000", fp)
+	}
+}
+
+func TestReconcileExact(t *testing.T) {
+	local, remote := NewMemoryStore(), NewMemoryStore()
+	populate(local, "shared", 500)
+	populate(remote, "shared", 500)
+	populate(local, "local", 7)
+	populate(remote, "remote", 12)
+
+	rf, _ := memRemote{remote}.Filter(FilterScope{})
+	result, err := Reconcile(BuildFilter(local, FilterScope{}), rf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Exact || len(result.Missing) != 12 || len(result.Extra) != 7 {
+		t.Fatalf("result = exact %v, missing %d, extra %d", result.Exact, len(result.Missing), len(result.Extra))
+	}
+	for _, h := range result.Missing {
+		if remote.Resolve(h) == nil || local.Resolve(h) != nil {
+			t.Fatalf("wrong missing hash %s", h)
+		}
+	}
+
+	n, err := SyncFrom(local, memRemote{remote}, FilterScope{})
+	if err != nil || n != 12 {
+		t.Fatalf("SyncFrom stored %d, err %v", n, err)
+	}
+	again, _ := SyncFrom(local, memRemote{remote}, FilterScope{})
+	if again != 0 {
+		t.Errorf("second sync stored %d", again)
+	}
+}
+
+func TestReconcileFallsBackToBloom(t *testing.T) {
+	local, remote := NewMemoryStore(), NewMemoryStore()
+	populate(local, "local", 300)
+	populate(remote, "remote", 300)
+	scope := FilterScope{ExpectedDiff: 8}
+	result, err := Reconcile(BuildFilter(local, scope), BuildFilter(remote, scope))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Exact || len(result.Extra) < 280 {
+		t.Errorf("fallback = exact %v, extra %d", result.Exact, len(result.Extra))
+	}
+	// SyncFrom resizes the table from the Bloom estimate and retries.
+	if n, err := SyncFrom(local, memRemote{remote}, scope); err != nil || n != 300 {
+		t.Errorf("SyncFrom stored %d, err %v", n, err)
+	}
+}
+
+func TestSyncFromLargeDifference(t *testing.T) {
+	local, remote := NewMemoryStore(), NewMemoryStore()
+	populate(local, "shared", 1000)
+	populate(remote, "shared", 1000)
+	populate(local, "local", 40)
+	populate(remote, "remote", 250)
+
+	n, err := SyncFrom(local, memRemote{remote}, FilterScope{})
+	if err != nil || n != 250 {
+		t.Fatalf("SyncFrom stored %d, err %v", n, err)
+	}
+	if local.Len() != 1290 {
+		t.Errorf("local has %d blocks, want 1290", local.Len())
+	}
+}
+
+func TestFilterScope(t *testing.T) {
+	store := NewMemoryStore()
+	populate(store, "p", 5)
+	store.Put(Create("actor.venue", map[string]interface{}{"name": "Cafe"}, nil))
+	if f := BuildFilter(store, FilterScope{Types: []string{"actor.*"}}); f.Count != 1 {
+		t.Errorf("scoped count = %d, want 1", f.Count)
+	}
+}
+
+func TestReconcileRejectsMalformedRemote(t *testing.T) {
+	local := NewMemoryStore()
+	populate(local, "local", 5)
+	localFilter := BuildFilter(local, FilterScope{})
+
+	var remote SyncFilter
+	if err := json.Unmarshal([]byte(`{"bloom":{"bits":"AA==","k":-1},"iblt":"`+base64.StdEncoding.EncodeToString(make([]byte, 44*3))+`"}`), &remote); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Reconcile(localFilter, &remote); err == nil {
+		t.Error("accepted a Bloom filter with a negative hash count")
+	}
+	remote.Bloom = &BloomFilter{Hashes: 3}
+	if _, err := Reconcile(localFilter, &remote); err == nil {
+		t.Error("accepted an empty Bloom filter")
+	}
+	for _, enc := range []string{"", base64.StdEncoding.EncodeToString(make([]byte, 44*2))} {
+		if err := json.Unmarshal([]byte(`"`+enc+`"`), new(IBLT)); err == nil {
+			t.Errorf("accepted IBLT encoding %q", enc)
+		}
+	}
+}
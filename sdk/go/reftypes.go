@@ -0,0 +1,96 @@
+package foodblock
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// StoreValidation is the result of ValidateWithStore. Dangling refs are
+// kept apart from errors: in a partial replica they may only be missing
+// for now.
+type StoreValidation struct {
+	Errors   []string `json:"errors"`
+	Dangling []string `json:"dangling"` // "refs.<role> <hash>" for refs that resolve to nothing
+}
+
+// Valid reports whether there are no errors. Dangling refs do not count.
+func (v StoreValidation) Valid() bool {
+	return len(v.Errors) == 0
+}
+
+// ValidateWithStore is Validate with the block's refs resolved in store:
+// each ref must point at a block of a type the schema's RefTypes and the
+// type's vocabularies allow, such as an actor.* for refs.seller. A nil
+// schema uses the block's $schema, looked up among CoreSchemas and the
+// schema blocks in store.
+func ValidateWithStore(block Block, schema *Schema, store BlockStore) StoreValidation {
+	if schema == nil {
+		if ref, ok := block.State["$schema"].(string); ok {
+			if s, found := LookupSchema(ref, store); found {
+				schema = &s
+			}
+		}
+	}
+	v := StoreValidation{Errors: Validate(block, schema)}
+	mismatched, dangling := refTargetIssues(block, schema, Vocabularies, store.Resolve)
+	v.Errors = append(v.Errors, mismatched...)
+	v.Dangling = dangling
+	return v
+}
+
+// refTargetTypes gathers the allowed target types for each ref role of a
+// block from its schema and the vocabularies for its type.
+func refTargetTypes(typ string, schema *Schema, vocabs map[string]VocabularyDef) map[string][][]string {
+	out := map[string][][]string{}
+	add := func(refs map[string][]string) {
+		for role, types := range refs {
+			out[role] = append(out[role], types)
+		}
+	}
+	if schema != nil {
+		add(schema.RefTypes)
+	}
+	for _, name := range sortedVocabNames(vocabs) {
+		if containsStr(vocabs[name].ForTypes, typ) {
+			add(vocabs[name].Refs)
+		}
+	}
+	return out
+}
+
+// refTargetIssues resolves every ref of b, returning refs whose target has
+// the wrong type and, separately, refs that do not resolve.
+func refTargetIssues(b Block, schema *Schema, vocabs map[string]VocabularyDef, resolve func(string) *Block) (mismatched, dangling []string) {
+	allowed := refTargetTypes(b.Type, schema, vocabs)
+	roles := make([]string, 0, len(b.Refs))
+	for role := range b.Refs {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	for _, role := range roles {
+		for _, h := range flattenRefValues(map[string]interface{}{role: b.Refs[role]}) {
+			target := resolve(h)
+			if target == nil {
+				dangling = append(dangling, fmt.Sprintf("refs.%s %s", role, h))
+				continue
+			}
+			for _, types := range allowed[role] {
+				if !matchesAnyType(target.Type, types) {
+					mismatched = append(mismatched, fmt.Sprintf("refs.%s should resolve to %s, got %s", role, strings.Join(types, " or "), target.Type))
+					break
+				}
+			}
+		}
+	}
+	return mismatched, dangling
+}
+
+func sortedVocabNames(vocabs map[string]VocabularyDef) []string {
+	names := make([]string, 0, len(vocabs))
+	for name := range vocabs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
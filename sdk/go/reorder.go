@@ -0,0 +1,260 @@
+package foodblock
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultLeadTimeDays is the supplier lead time assumed when no transit_time
+// is recorded.
+const DefaultLeadTimeDays = 2
+
+// ReorderReviewDays is how many days of demand an order covers beyond its
+// lead time.
+const ReorderReviewDays = 7
+
+// ReorderLine is the stock position of one item at a venue.
+type ReorderLine struct {
+	Item         string          `json:"item"`
+	Name         string          `json:"name"`
+	Unit         string          `json:"unit,omitempty"`
+	Count        string          `json:"count"` // the stock_level reading the ledger starts from
+	OnHand       float64         `json:"on_hand"`
+	Supplier     string          `json:"supplier,omitempty"`
+	LeadTimeDays float64         `json:"lead_time_days"`
+	ReorderPoint float64         `json:"reorder_point"` // upper forecast demand over the lead time
+	SafetyStock  float64         `json:"safety_stock"`  // the part of the reorder point above expected demand
+	StockOut     string          `json:"stock_out,omitempty"`
+	Reorder      bool            `json:"reorder"`
+	Quantity     float64         `json:"quantity"` // suggested order quantity
+	Forecast     *DemandForecast `json:"forecast,omitempty"`
+	Error        string          `json:"error,omitempty"` // why the item could not be forecast
+}
+
+// ReorderReport is the reorder analysis for a venue, lines in name order.
+type ReorderReport struct {
+	Venue string        `json:"venue"`
+	Lines []ReorderLine `json:"lines"`
+	Below int           `json:"below"` // lines at or below their reorder point
+}
+
+// ReorderAnalysis computes reorder points for every item a venue counts
+// stock of: observe.reading blocks with reading_type stock_level, refs.subject
+// the venue and refs.item (or product) the item, as in the agent-reorder
+// template.
+//
+// The inventory ledger starts from the latest count, adds deliveries to the
+// venue (transfer.delivery refs.buyer, with the item on the delivery or its
+// order) and subtracts sales by it (transfer.order refs.seller) dated after
+// the count. The supplier is the seller of the venue's latest order for the
+// item, and the lead time its transit_time, from the supplier block or its
+// latest delivery, else DefaultLeadTimeDays. Demand is the venue's sales of
+// the item, forecast as in Forecast over the lead time plus
+// ReorderReviewDays. An item is due for reorder when stock on hand is at or
+// below the upper forecast demand over the lead time, and the suggested
+// quantity is the forecast's SuggestedQuantity.
+func ReorderAnalysis(venueHash string, store BlockStore) (ReorderReport, error) {
+	if store.Resolve(venueHash) == nil {
+		return ReorderReport{}, fmt.Errorf("FoodBlock: venue not found: %s", venueHash)
+	}
+	heads := FilterBlocks(store.Blocks(), QueryParams{HeadsOnly: true})
+	counts := map[string]Block{}
+	countAt := map[string]time.Time{}
+	for _, b := range heads {
+		if b.Type != "observe.reading" || b.State["reading_type"] != "stock_level" || firstRef(b.Refs, "subject") != venueHash {
+			continue
+		}
+		item := firstRef(b.Refs, "item", "product")
+		at, ok := readingTime(b)
+		if item == "" || !ok {
+			continue
+		}
+		if prev, seen := countAt[item]; !seen || at.After(prev) {
+			counts[item], countAt[item] = b, at
+		}
+	}
+
+	r := ReorderReport{Venue: venueHash, Lines: []ReorderLine{}}
+	for item, count := range counts {
+		line := ReorderLine{Item: item, Name: blockName(store, item), Count: count.Hash}
+		line.OnHand, line.Unit = stockQuantity(count)
+		line.OnHand = round3(line.OnHand + stockMovements(venueHash, item, countAt[item], heads, store))
+		line.Supplier = itemSupplier(venueHash, item, heads)
+		line.LeadTimeDays = leadTimeDays(line.Supplier, heads, store)
+
+		lead := int(math.Ceil(line.LeadTimeDays))
+		f, err := forecastOrders(item, store, lead+ReorderReviewDays, func(b Block) bool { return firstRef(b.Refs, "seller") == venueHash })
+		if err != nil {
+			line.Error = err.Error()
+			r.Lines = append(r.Lines, line)
+			continue
+		}
+		line.Forecast = &f
+		var expected, cumulative float64
+		for i, p := range f.Points {
+			if i < lead {
+				line.ReorderPoint += p.High
+				expected += p.Quantity
+			}
+			cumulative += p.Quantity
+			if line.StockOut == "" && cumulative > line.OnHand {
+				line.StockOut = p.Date
+			}
+		}
+		line.ReorderPoint = round3(line.ReorderPoint)
+		line.SafetyStock = round3(line.ReorderPoint - expected)
+		line.Reorder = line.OnHand <= line.ReorderPoint
+		line.Quantity = f.SuggestedQuantity(line.OnHand)
+		if line.Unit == "" {
+			line.Unit = f.Unit
+		}
+		if line.Reorder {
+			r.Below++
+		}
+		r.Lines = append(r.Lines, line)
+	}
+	sort.Slice(r.Lines, func(i, j int) bool {
+		if r.Lines[i].Name != r.Lines[j].Name {
+			return r.Lines[i].Name < r.Lines[j].Name
+		}
+		return r.Lines[i].Item < r.Lines[j].Item
+	})
+	return r, nil
+}
+
+// stockMovements nets the deliveries to and sales by a venue of an item dated
+// after a stock count.
+func stockMovements(venue, item string, since time.Time, heads []Block, store BlockStore) float64 {
+	var net float64
+	for _, b := range heads {
+		if draft, _ := b.State["draft"].(bool); draft {
+			continue
+		}
+		date, ok := BlockDate(b)
+		if !ok || !date.After(since) {
+			continue
+		}
+		switch {
+		case b.Type == "transfer.delivery" && firstRef(b.Refs, "buyer") == venue:
+			qty, _ := stockQuantity(b)
+			delivered := firstRef(b.Refs, "item", "product")
+			if order := store.Resolve(firstRef(b.Refs, "order")); order != nil {
+				if delivered == "" {
+					delivered = firstRef(order.Refs, "item", "product")
+				}
+				if qty == 0 {
+					qty, _ = stockQuantity(*order)
+				}
+			}
+			if delivered == item {
+				net += qty
+			}
+		case b.Type == "transfer.order" && firstRef(b.Refs, "seller") == venue && firstRef(b.Refs, "item", "product") == item:
+			qty, _ := stockQuantity(b)
+			net -= qty
+		}
+	}
+	return net
+}
+
+// itemSupplier is the seller of a venue's latest order for an item.
+func itemSupplier(venue, item string, heads []Block) string {
+	supplier := ""
+	var latest time.Time
+	for _, b := range heads {
+		if b.Type != "transfer.order" || firstRef(b.Refs, "buyer") != venue || firstRef(b.Refs, "item", "product") != item {
+			continue
+		}
+		if draft, _ := b.State["draft"].(bool); draft {
+			continue
+		}
+		date, _ := BlockDate(b)
+		if seller := firstRef(b.Refs, "seller"); seller != "" && (supplier == "" || date.After(latest)) {
+			supplier, latest = seller, date
+		}
+	}
+	return supplier
+}
+
+// leadTimeDays reads a supplier's transit_time from its own block, else from
+// its latest delivery that records one.
+func leadTimeDays(supplier string, heads []Block, store BlockStore) float64 {
+	if supplier == "" {
+		return DefaultLeadTimeDays
+	}
+	if b := store.Resolve(supplier); b != nil {
+		if days, ok := transitDays(b.State["transit_time"]); ok {
+			return days
+		}
+	}
+	days, found := float64(DefaultLeadTimeDays), false
+	var latest time.Time
+	for _, b := range heads {
+		if b.Type != "transfer.delivery" || firstRef(b.Refs, "seller") != supplier {
+			continue
+		}
+		d, ok := transitDays(b.State["transit_time"])
+		date, _ := BlockDate(b)
+		if ok && (!found || date.After(latest)) {
+			days, latest, found = d, date, true
+		}
+	}
+	return days
+}
+
+// transitDays reads a transit_time as days: a number of days, a
+// {value, unit} object or a string such as "36 hours".
+func transitDays(v interface{}) (float64, bool) {
+	value, ok := numericValue(v)
+	unit := ""
+	switch x := v.(type) {
+	case map[string]interface{}:
+		unit, _ = x["unit"].(string)
+	case string:
+		_, err := fmt.Sscanf(x, "%g %s", &value, &unit)
+		ok = err == nil || (value > 0 && unit == "")
+	}
+	if !ok || value < 0 {
+		return 0, false
+	}
+	switch strings.TrimSuffix(strings.ToLower(unit), "s") {
+	case "h", "hour", "hr":
+		value /= 24
+	case "week", "wk":
+		value *= 7
+	}
+	return round3(value), true
+}
+
+// ReorderDraft is a draft order an agent raised for a reorder line.
+type ReorderDraft struct {
+	Line        ReorderLine `json:"line"`
+	Forecast    Block       `json:"forecast"`
+	Draft       Block       `json:"draft"`
+	SignedDraft SignedBlock `json:"signed_draft"`
+	Action      SignedBlock `json:"action"`
+}
+
+// DraftReorders raises a draft transfer.order through DraftReorder for every
+// line of a report due for reorder, from the venue to the line's supplier.
+func (a *Agent) DraftReorders(r ReorderReport) ([]ReorderDraft, error) {
+	var out []ReorderDraft
+	for _, line := range r.Lines {
+		if !line.Reorder || line.Quantity <= 0 || line.Forecast == nil {
+			continue
+		}
+		refs := map[string]interface{}{"buyer": r.Venue}
+		if line.Supplier != "" {
+			refs["seller"] = line.Supplier
+		}
+		forecast, draft, signed, action, err := a.DraftReorder(*line.Forecast, line.OnHand, refs)
+		if err != nil {
+			return out, err
+		}
+		out = append(out, ReorderDraft{Line: line, Forecast: forecast, Draft: draft, SignedDraft: signed, Action: action})
+	}
+	return out, nil
+}
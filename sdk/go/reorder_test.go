@@ -0,0 +1,102 @@
+package foodblock
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestReorderAnalysis(t *testing.T) {
+	store := NewMemoryStore()
+	put := func(b Block) Block {
+		t.Helper()
+		if err := store.Put(b); err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	venue := put(Create("actor.venue", map[string]interface{}{"name": "Bakery"}, nil))
+	mill := put(Create("actor.distributor", map[string]interface{}{"name": "Mill", "transit_time": map[string]interface{}{"value": 3, "unit": "days"}}, nil))
+	flour := put(Create("substance.product", map[string]interface{}{"name": "Flour"}, nil))
+	salt := put(Create("substance.product", map[string]interface{}{"name": "Salt"}, nil))
+
+	start := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 14; i++ {
+		put(Create("transfer.order", map[string]interface{}{
+			"instance_id": fmt.Sprintf("sale-%d", i), "quantity": 10, "unit": "kg",
+			"date": start.AddDate(0, 0, i).Format("2006-01-02"),
+		}, map[string]interface{}{"seller": venue.Hash, "item": flour.Hash}))
+	}
+	purchase := put(Create("transfer.order", map[string]interface{}{"instance_id": "po-1", "quantity": 20, "unit": "kg", "date": "2026-03-05"},
+		map[string]interface{}{"buyer": venue.Hash, "seller": mill.Hash, "item": flour.Hash}))
+	put(Create("transfer.delivery", map[string]interface{}{"instance_id": "del-1", "date": "2026-03-13"},
+		map[string]interface{}{"buyer": venue.Hash, "seller": mill.Hash, "order": purchase.Hash}))
+	put(Create("observe.reading", map[string]interface{}{"reading_type": "stock_level", "quantity": 80, "unit": "kg", "at": "2026-03-01T18:00:00Z"},
+		map[string]interface{}{"subject": venue.Hash, "item": flour.Hash}))
+	put(Create("observe.reading", map[string]interface{}{"reading_type": "stock_level", "quantity": 50, "unit": "kg", "at": "2026-03-11T18:00:00Z"},
+		map[string]interface{}{"subject": venue.Hash, "item": flour.Hash}))
+	put(Create("observe.reading", map[string]interface{}{"reading_type": "stock_level", "quantity": 100, "at": "2026-03-11T18:00:00Z"},
+		map[string]interface{}{"subject": venue.Hash, "item": salt.Hash}))
+
+	r, err := ReorderAnalysis(venue.Hash, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Lines) != 2 || r.Below != 1 {
+		t.Fatalf("report = %+v", r)
+	}
+	line := r.Lines[0]
+	if line.Name != "Flour" || line.Supplier != mill.Hash || line.LeadTimeDays != 3 {
+		t.Fatalf("line = %+v", line)
+	}
+	// 50 counted, 40 sold since, 20 delivered; purchases are not demand.
+	if line.OnHand != 30 || line.ReorderPoint != 30 || !line.Reorder {
+		t.Errorf("on hand %v, reorder point %v, reorder %v", line.OnHand, line.ReorderPoint, line.Reorder)
+	}
+	if line.Quantity != 70 || line.StockOut != "2026-03-19" || line.Unit != "kg" {
+		t.Errorf("quantity %v, stock out %s, unit %s", line.Quantity, line.StockOut, line.Unit)
+	}
+	if salt := r.Lines[1]; salt.Reorder || salt.Error == "" || salt.OnHand != 100 || salt.LeadTimeDays != DefaultLeadTimeDays {
+		t.Errorf("salt = %+v", salt)
+	}
+
+	agent, _ := CreateAgent("Reorder bot", venue.Hash, nil)
+	drafts, err := agent.DraftReorders(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drafts) != 1 {
+		t.Fatalf("drafts = %d", len(drafts))
+	}
+	d := drafts[0].Draft
+	if d.State["quantity"] != 70.0 || d.Refs["buyer"] != venue.Hash || d.Refs["seller"] != mill.Hash || d.Refs["item"] != flour.Hash {
+		t.Errorf("draft = %+v", d)
+	}
+	if drafts[0].Action.FoodBlock.Refs["draft"] != d.Hash {
+		t.Errorf("action refs = %v", drafts[0].Action.FoodBlock.Refs)
+	}
+
+	if _, err := ReorderAnalysis("missing", store); err == nil {
+		t.Error("expected an error for an unknown venue")
+	}
+}
+
+func TestTransitDays(t *testing.T) {
+	for _, c := range []struct {
+		in   interface{}
+		want float64
+	}{
+		{2, 2},
+		{"36 hours", 1.5},
+		{"1 week", 7},
+		{"4", 4},
+		{map[string]interface{}{"value": 12, "unit": "h"}, 0.5},
+	} {
+		if got, ok := transitDays(c.in); !ok || got != c.want {
+			t.Errorf("transitDays(%v) = %v, %v; want %v", c.in, got, ok, c.want)
+		}
+	}
+	if _, ok := transitDays("soon"); ok {
+		t.Error("expected no lead time from text")
+	}
+}
@@ -0,0 +1,260 @@
+package foodblock
+
+import (
+	"container/heap"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// WriteOptions bind a signed block to one write.
+type WriteOptions struct {
+	Audience string        // the peer the write is for, as its ReplayGuard names itself
+	TTL      time.Duration // how long the write may be submitted, default 5 minutes
+	Now      func() time.Time
+}
+
+// SignWrite signs a block for submission to a peer. The block is signed as
+// Sign does, so the stored wrapper verifies in every SDK; a second signature,
+// WriteSignature, covers the block hash with a fresh nonce, an expiry and the
+// audience, so a captured request cannot be replayed later or to another
+// peer. Resending the same wrapper is a retry and is accepted once.
+func SignWrite(block Block, authorHash string, privateKey []byte, opts WriteOptions) SignedBlock {
+	if opts.TTL <= 0 {
+		opts.TTL = 5 * time.Minute
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	signed := Sign(block, authorHash, privateKey)
+	signed.Nonce = hex.EncodeToString(nonce)
+	signed.Expires = opts.Now().Add(opts.TTL).UTC().Format(time.RFC3339)
+	signed.Audience = opts.Audience
+	sig := ed25519.Sign(ed25519.PrivateKey(privateKey), writeBinding(signed))
+	signed.WriteSignature = hex.EncodeToString(sig)
+	return signed
+}
+
+// VerifyWrite verifies both a write's block signature and its binding.
+func VerifyWrite(signed SignedBlock, publicKey []byte) bool {
+	if !Verify(signed, publicKey) {
+		return false
+	}
+	sig, err := hex.DecodeString(signed.WriteSignature)
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(ed25519.PublicKey(publicKey), writeBinding(signed), sig)
+}
+
+// writeBinding is what WriteSignature covers. The prefix keeps it from being
+// mistaken for a block signature, which always covers a JSON object.
+func writeBinding(s SignedBlock) []byte {
+	return []byte("foodblock-write\n" + s.FoodBlock.Hash + "\n" + s.AuthorHash + "\n" + s.Nonce + "\n" + s.Expires + "\n" + s.Audience)
+}
+
+// ReplayGuard remembers the nonces of accepted writes until they expire.
+// A nonce seen again with the same block is a retry; with any other block
+// it is a replay.
+type ReplayGuard struct {
+	Audience string        // this peer; writes for another audience are refused
+	MaxTTL   time.Duration // longest validity accepted, default 10 minutes
+	Now      func() time.Time
+
+	mu     sync.Mutex
+	seen   map[string]seenWrite
+	expiry expiryQueue
+}
+
+type seenWrite struct {
+	hash    string
+	expires time.Time
+}
+
+// expiryQueue is a min-heap of seen nonce keys by expiry.
+type expiryQueue []expiringKey
+
+type expiringKey struct {
+	key     string
+	expires time.Time
+}
+
+func (q expiryQueue) Len() int            { return len(q) }
+func (q expiryQueue) Less(i, j int) bool  { return q[i].expires.Before(q[j].expires) }
+func (q expiryQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *expiryQueue) Push(x interface{}) { *q = append(*q, x.(expiringKey)) }
+func (q *expiryQueue) Pop() interface{} {
+	old := *q
+	k := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return k
+}
+
+// NewReplayGuard returns a guard for the named peer.
+func NewReplayGuard(audience string) *ReplayGuard {
+	return &ReplayGuard{Audience: audience}
+}
+
+// Check admits a signed write. It returns true for a retry of a write
+// already admitted, and an error for a write without a binding, expired,
+// valid for longer than MaxTTL, for another audience, or reusing a nonce.
+// Check does not verify the signature.
+func (g *ReplayGuard) Check(s SignedBlock) (retry bool, err error) {
+	now := time.Now()
+	if g.Now != nil {
+		now = g.Now()
+	}
+	maxTTL := g.MaxTTL
+	if maxTTL <= 0 {
+		maxTTL = 10 * time.Minute
+	}
+	if s.Nonce == "" || s.Expires == "" {
+		return false, errors.New("FoodBlock: write has no nonce and expiry")
+	}
+	expires, err := time.Parse(time.RFC3339, s.Expires)
+	if err != nil {
+		return false, fmt.Errorf("FoodBlock: invalid write expiry %q", s.Expires)
+	}
+	if !expires.After(now) {
+		return false, errors.New("FoodBlock: write has expired")
+	}
+	if expires.Sub(now) > maxTTL {
+		return false, fmt.Errorf("FoodBlock: write is valid for longer than %s", maxTTL)
+	}
+	if g.Audience != "" && s.Audience != g.Audience {
+		return false, fmt.Errorf("FoodBlock: write is for %q, not %q", s.Audience, g.Audience)
+	}
+
+	key := s.AuthorHash + "\n" + s.Nonce
+	if hash, seen := g.admit(key, s.FoodBlock.Hash, expires, now); seen {
+		if hash != s.FoodBlock.Hash {
+			return false, errors.New("FoodBlock: nonce was already used for another write")
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// CheckRequest admits the nonce of a signed HTTP request, remembered until
+// expires. Any nonce seen before from the same author is a replay; requests
+// have no retries.
+func (g *ReplayGuard) CheckRequest(author, nonce string, expires time.Time) error {
+	now := time.Now()
+	if g.Now != nil {
+		now = g.Now()
+	}
+	if nonce == "" {
+		return errors.New("FoodBlock: request has no nonce")
+	}
+	if _, seen := g.admit("request\n"+author+"\n"+nonce, "", expires, now); seen {
+		return errors.New("FoodBlock: request nonce was already used")
+	}
+	return nil
+}
+
+// admit records key until expires, first dropping expired keys. When key is
+// already recorded it returns the hash it was recorded with instead.
+func (g *ReplayGuard) admit(key, hash string, expires, now time.Time) (string, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.seen == nil {
+		g.seen = map[string]seenWrite{}
+	}
+	for len(g.expiry) > 0 && !g.expiry[0].expires.After(now) {
+		e := heap.Pop(&g.expiry).(expiringKey)
+		// The nonce may have been forgotten and admitted again since.
+		if w, ok := g.seen[e.key]; ok && w.expires.Equal(e.expires) {
+			delete(g.seen, e.key)
+		}
+	}
+	if w, ok := g.seen[key]; ok {
+		return w.hash, true
+	}
+	g.seen[key] = seenWrite{hash, expires}
+	heap.Push(&g.expiry, expiringKey{key, expires})
+	return "", false
+}
+
+func (g *ReplayGuard) forget(s SignedBlock) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.seen, s.AuthorHash+"\n"+s.Nonce)
+}
+
+// WriteResult is the outcome of AcceptWrite.
+type WriteResult struct {
+	Hash      string `json:"hash"`
+	Duplicate bool   `json:"duplicate"` // a retry, or a block already stored; nothing was written
+}
+
+// AcceptWrite verifies a signed write and stores it once. Both signatures
+// must verify against keys, which is required, and guard must admit the
+// write. Retries and blocks already stored succeed without storing again.
+func AcceptWrite(store BlockStore, keys *KeyRegistry, guard *ReplayGuard, s SignedBlock) (WriteResult, error) {
+	if keys == nil || !keys.VerifyWrite(s) {
+		return WriteResult{Hash: s.FoodBlock.Hash}, errors.New("FoodBlock: signature does not verify")
+	}
+	return acceptWrite(store, guard, s)
+}
+
+// acceptWrite admits and stores a write whose signatures have been verified.
+func acceptWrite(store BlockStore, guard *ReplayGuard, s SignedBlock) (WriteResult, error) {
+	result := WriteResult{Hash: s.FoodBlock.Hash}
+	retry, err := guard.Check(s)
+	if err != nil {
+		return result, err
+	}
+	if retry || store.Resolve(s.FoodBlock.Hash) != nil {
+		result.Duplicate = true
+		return result, nil
+	}
+	put := func() error { return store.Put(s.FoodBlock) }
+	if signed, ok := store.(interface{ PutSigned(SignedBlock) error }); ok {
+		put = func() error { return signed.PutSigned(s) }
+	}
+	if err := put(); err != nil {
+		// The write had no effect, so a corrected retry may reuse the nonce.
+		guard.forget(s)
+		return result, err
+	}
+	return result, nil
+}
+
+// WriteHandler accepts signed blocks POSTed as JSON through AcceptWrite.
+// A new block gets 201, a retry or known block 200, and a rejected write
+// 401 for a bad signature or 409 for a replay or an invalid block. Without
+// keys every write is refused.
+func WriteHandler(store BlockStore, keys *KeyRegistry, guard *ReplayGuard) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "writes must be POSTed")
+			return
+		}
+		var s SignedBlock
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, DefaultMaxBlockBytes*2)).Decode(&s); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid signed block: "+err.Error())
+			return
+		}
+		if keys == nil || !keys.VerifyWrite(s) {
+			writeError(w, http.StatusUnauthorized, "signature does not verify")
+			return
+		}
+		result, err := acceptWrite(store, guard, s)
+		switch {
+		case err != nil:
+			writeError(w, http.StatusConflict, err.Error())
+		case result.Duplicate:
+			writeJSON(w, http.StatusOK, result)
+		default:
+			writeJSON(w, http.StatusCreated, result)
+		}
+	})
+}
@@ -0,0 +1,119 @@
+package foodblock
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignWriteAndReplayGuard(t *testing.T) {
+	pub, priv := GenerateKeypair()
+	actor := Create("actor.producer", map[string]interface{}{"name": "Mill"}, nil)
+	block := Create("substance.product", map[string]interface{}{"name": "Rye"}, map[string]interface{}{"seller": actor.Hash})
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	s := SignWrite(block, actor.Hash, priv, WriteOptions{Audience: "peer-a", Now: clock})
+	if !VerifyWrite(s, pub) || s.Nonce == "" || s.Expires != "2026-10-01T12:05:00Z" {
+		t.Fatalf("signed write = %+v", s)
+	}
+	// The block signature is the plain one, so stored writes verify anywhere.
+	if s.Signature != Sign(block, actor.Hash, priv).Signature {
+		t.Error("write changed the block signature")
+	}
+	// The binding is signed: changing it breaks the write signature.
+	moved := s
+	moved.Audience = "peer-b"
+	if VerifyWrite(moved, pub) || !Verify(moved, pub) {
+		t.Error("audience is not covered by the write signature")
+	}
+
+	guard := &ReplayGuard{Audience: "peer-a", Now: clock}
+	if retry, err := guard.Check(s); retry || err != nil {
+		t.Fatalf("first write: %v, %v", retry, err)
+	}
+	if retry, err := guard.Check(s); !retry || err != nil {
+		t.Errorf("retry: %v, %v", retry, err)
+	}
+	other := s
+	other.FoodBlock = actor
+	if _, err := guard.Check(other); err == nil || !strings.Contains(err.Error(), "already used") {
+		t.Errorf("nonce reuse: %v", err)
+	}
+	if _, err := (&ReplayGuard{Audience: "peer-b", Now: clock}).Check(s); err == nil {
+		t.Error("expected a write for peer-a to be refused by peer-b")
+	}
+	later := &ReplayGuard{Now: func() time.Time { return now.Add(6 * time.Minute) }}
+	if _, err := later.Check(s); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("expired: %v", err)
+	}
+	if _, err := guard.Check(Sign(block, actor.Hash, priv)); err == nil {
+		t.Error("expected an unbound write to be refused")
+	}
+
+	// Seen nonces are forgotten once they expire.
+	now = now.Add(6 * time.Minute)
+	fresh := SignWrite(block, actor.Hash, priv, WriteOptions{Audience: "peer-a", Now: clock})
+	guard.Check(fresh)
+	if len(guard.seen) != 1 {
+		t.Errorf("seen = %d entries", len(guard.seen))
+	}
+}
+
+func TestWriteHandler(t *testing.T) {
+	pub, priv := GenerateKeypair()
+	actor := Create("actor.producer", map[string]interface{}{"name": "Mill"}, nil)
+	keys := NewKeyRegistry()
+	keys.Register(actor.Hash, pub)
+	store := NewMemoryStore()
+	srv := httptest.NewServer(WriteHandler(store, keys, NewReplayGuard("peer-a")))
+	defer srv.Close()
+
+	post := func(s SignedBlock) (int, WriteResult) {
+		body, _ := json.Marshal(s)
+		resp, err := http.Post(srv.URL, "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var r WriteResult
+		json.NewDecoder(resp.Body).Decode(&r)
+		return resp.StatusCode, r
+	}
+
+	s := SignWrite(actor, actor.Hash, priv, WriteOptions{Audience: "peer-a"})
+	if code, r := post(s); code != http.StatusCreated || r.Duplicate || store.Len() != 1 {
+		t.Errorf("first post: %d %+v", code, r)
+	}
+	if code, r := post(s); code != http.StatusOK || !r.Duplicate || store.Len() != 1 {
+		t.Errorf("retry: %d %+v", code, r)
+	}
+	forged := s
+	forged.Nonce = "0000"
+	if code, _ := post(forged); code != http.StatusUnauthorized {
+		t.Errorf("forged nonce: %d", code)
+	}
+	if code, _ := post(SignWrite(actor, actor.Hash, priv, WriteOptions{Audience: "peer-b"})); code != http.StatusConflict {
+		t.Errorf("other audience: %d", code)
+	}
+
+	// Without keys, writes are refused rather than accepted unverified.
+	open := httptest.NewServer(WriteHandler(NewMemoryStore(), nil, NewReplayGuard("peer-a")))
+	defer open.Close()
+	body, _ := json.Marshal(SignWrite(actor, actor.Hash, priv, WriteOptions{Audience: "peer-a"}))
+	resp, err := http.Post(open.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("handler without keys: %d", resp.StatusCode)
+	}
+	if _, err := AcceptWrite(NewMemoryStore(), nil, NewReplayGuard("peer-a"), s); err == nil {
+		t.Error("AcceptWrite without keys accepted a write")
+	}
+}
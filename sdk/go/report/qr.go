@@ -0,0 +1,432 @@
+package report
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// QR code encoder for the short URIs reports embed: byte mode, error
+// correction level M, versions 1-10 (up to 213 bytes).
+
+type qrVersion struct {
+	ecPerBlock int
+	groups     [][2]int // {block count, data codewords per block}
+	align      []int
+}
+
+var qrVersions = []qrVersion{
+	{10, [][2]int{{1, 16}}, nil},
+	{16, [][2]int{{1, 28}}, []int{6, 18}},
+	{26, [][2]int{{1, 44}}, []int{6, 22}},
+	{18, [][2]int{{2, 32}}, []int{6, 26}},
+	{24, [][2]int{{2, 43}}, []int{6, 30}},
+	{16, [][2]int{{4, 27}}, []int{6, 34}},
+	{18, [][2]int{{4, 31}}, []int{6, 22, 38}},
+	{22, [][2]int{{2, 38}, {2, 39}}, []int{6, 24, 42}},
+	{22, [][2]int{{3, 36}, {2, 37}}, []int{6, 26, 46}},
+	{26, [][2]int{{4, 43}, {1, 44}}, []int{6, 28, 50}},
+}
+
+func (v qrVersion) dataCodewords() int {
+	n := 0
+	for _, g := range v.groups {
+		n += g[0] * g[1]
+	}
+	return n
+}
+
+var gfExp, gfLog [256]byte
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfLog[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[(int(gfLog[a])+int(gfLog[b]))%255]
+}
+
+// rsRemainder returns the Reed-Solomon error correction codewords for data.
+func rsRemainder(data []byte, degree int) []byte {
+	gen := []byte{1}
+	for i := 0; i < degree; i++ {
+		next := make([]byte, len(gen)+1)
+		for j, c := range gen {
+			next[j] ^= c
+			next[j+1] ^= gfMul(c, gfExp[i])
+		}
+		gen = next
+	}
+	msg := make([]byte, len(data)+degree)
+	copy(msg, data)
+	for i := range data {
+		coef := msg[i]
+		if coef == 0 {
+			continue
+		}
+		for j := 1; j < len(gen); j++ {
+			msg[i+j] ^= gfMul(gen[j], coef)
+		}
+	}
+	return msg[len(data):]
+}
+
+type bitBuffer []bool
+
+func (b *bitBuffer) append(val, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, (val>>i)&1 == 1)
+	}
+}
+
+// QR encodes data as a QR code and returns its modules (true = dark), row major.
+func QR(data string) ([][]bool, error) {
+	payload := []byte(data)
+	vi := -1
+	for i, v := range qrVersions {
+		countBits := 8
+		if i+1 >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+8*len(payload) <= 8*v.dataCodewords() {
+			vi = i
+			break
+		}
+	}
+	if vi < 0 {
+		return nil, errors.New("report: data too long for QR code")
+	}
+	version := vi + 1
+	v := qrVersions[vi]
+	capacity := v.dataCodewords()
+
+	var bits bitBuffer
+	bits.append(0x4, 4)
+	if version >= 10 {
+		bits.append(len(payload), 16)
+	} else {
+		bits.append(len(payload), 8)
+	}
+	for _, c := range payload {
+		bits.append(int(c), 8)
+	}
+	term := capacity*8 - len(bits)
+	if term > 4 {
+		term = 4
+	}
+	bits.append(0, term)
+	bits.append(0, (8-len(bits)%8)%8)
+	codewords := make([]byte, 0, capacity)
+	for i := 0; i < len(bits); i += 8 {
+		var c byte
+		for j := 0; j < 8; j++ {
+			if bits[i+j] {
+				c |= 1 << (7 - j)
+			}
+		}
+		codewords = append(codewords, c)
+	}
+	for pad := byte(0xec); len(codewords) < capacity; pad ^= 0xec ^ 0x11 {
+		codewords = append(codewords, pad)
+	}
+
+	// Split into blocks, add error correction and interleave.
+	var dataBlocks, ecBlocks [][]byte
+	offset := 0
+	for _, g := range v.groups {
+		for i := 0; i < g[0]; i++ {
+			block := codewords[offset : offset+g[1]]
+			offset += g[1]
+			dataBlocks = append(dataBlocks, block)
+			ecBlocks = append(ecBlocks, rsRemainder(block, v.ecPerBlock))
+		}
+	}
+	var final []byte
+	for i := 0; ; i++ {
+		added := false
+		for _, b := range dataBlocks {
+			if i < len(b) {
+				final = append(final, b[i])
+				added = true
+			}
+		}
+		if !added {
+			break
+		}
+	}
+	for i := 0; i < v.ecPerBlock; i++ {
+		for _, b := range ecBlocks {
+			final = append(final, b[i])
+		}
+	}
+
+	m := newQRMatrix(version)
+	m.drawFunctionPatterns(v.align)
+	m.drawCodewords(final)
+
+	best, bestPenalty := -1, 0
+	for mask := 0; mask < 8; mask++ {
+		m.applyMask(mask)
+		m.drawFormatBits(mask)
+		if p := m.penalty(); best < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		m.applyMask(mask)
+	}
+	m.applyMask(best)
+	m.drawFormatBits(best)
+	return m.modules, nil
+}
+
+type qrMatrix struct {
+	size       int
+	version    int
+	modules    [][]bool
+	isFunction [][]bool
+}
+
+func newQRMatrix(version int) *qrMatrix {
+	size := version*4 + 17
+	m := &qrMatrix{size: size, version: version}
+	m.modules = make([][]bool, size)
+	m.isFunction = make([][]bool, size)
+	for i := range m.modules {
+		m.modules[i] = make([]bool, size)
+		m.isFunction[i] = make([]bool, size)
+	}
+	return m
+}
+
+func (m *qrMatrix) setFunction(x, y int, dark bool) {
+	m.modules[y][x] = dark
+	m.isFunction[y][x] = true
+}
+
+func (m *qrMatrix) drawFunctionPatterns(align []int) {
+	for i := 0; i < m.size; i++ {
+		m.setFunction(6, i, i%2 == 0)
+		m.setFunction(i, 6, i%2 == 0)
+	}
+	for _, c := range [][2]int{{3, 3}, {m.size - 4, 3}, {3, m.size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := c[0]+dx, c[1]+dy
+				if x < 0 || x >= m.size || y < 0 || y >= m.size {
+					continue
+				}
+				d := maxInt(absInt(dx), absInt(dy))
+				m.setFunction(x, y, d != 2 && d != 4)
+			}
+		}
+	}
+	last := len(align) - 1
+	for i, ay := range align {
+		for j, ax := range align {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					m.setFunction(ax+dx, ay+dy, maxInt(absInt(dx), absInt(dy)) != 1)
+				}
+			}
+		}
+	}
+	m.drawFormatBits(0) // reserve format areas
+	if m.version >= 7 {
+		rem := m.version
+		for i := 0; i < 12; i++ {
+			rem = (rem << 1) ^ ((rem >> 11) * 0x1f25)
+		}
+		bits := m.version<<12 | rem
+		for i := 0; i < 18; i++ {
+			dark := (bits>>i)&1 == 1
+			a, b := m.size-11+i%3, i/3
+			m.setFunction(a, b, dark)
+			m.setFunction(b, a, dark)
+		}
+	}
+}
+
+// drawFormatBits writes the format information for level M and the given mask.
+func (m *qrMatrix) drawFormatBits(mask int) {
+	data := 0<<3 | mask // level M encodes as 00
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return (bits>>i)&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		m.setFunction(8, i, bit(i))
+	}
+	m.setFunction(8, 7, bit(6))
+	m.setFunction(8, 8, bit(7))
+	m.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		m.setFunction(14-i, 8, bit(i))
+	}
+	for i := 0; i < 8; i++ {
+		m.setFunction(m.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		m.setFunction(8, m.size-15+i, bit(i))
+	}
+	m.setFunction(8, m.size-8, true)
+}
+
+func (m *qrMatrix) drawCodewords(data []byte) {
+	i := 0
+	for right := m.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < m.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = m.size - 1 - vert
+				}
+				if !m.isFunction[y][x] && i < len(data)*8 {
+					m.modules[y][x] = (data[i>>3]>>(7-uint(i&7)))&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+func (m *qrMatrix) applyMask(mask int) {
+	for y := 0; y < m.size; y++ {
+		for x := 0; x < m.size; x++ {
+			if m.isFunction[y][x] {
+				continue
+			}
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert {
+				m.modules[y][x] = !m.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores a masked matrix with the four ISO 18004 penalty rules.
+func (m *qrMatrix) penalty() int {
+	p := 0
+	at := func(x, y int, vertical bool) bool {
+		if vertical {
+			return m.modules[x][y]
+		}
+		return m.modules[y][x]
+	}
+	for _, vertical := range []bool{false, true} {
+		for y := 0; y < m.size; y++ {
+			run := 1
+			for x := 1; x <= m.size; x++ {
+				if x < m.size && at(x, y, vertical) == at(x-1, y, vertical) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					p += run - 2
+				}
+				run = 1
+			}
+			for x := 0; x+7 <= m.size; x++ {
+				if at(x, y, vertical) && !at(x+1, y, vertical) && at(x+2, y, vertical) && at(x+3, y, vertical) &&
+					at(x+4, y, vertical) && !at(x+5, y, vertical) && at(x+6, y, vertical) {
+					before := x >= 4 && !at(x-1, y, vertical) && !at(x-2, y, vertical) && !at(x-3, y, vertical) && !at(x-4, y, vertical)
+					after := x+11 <= m.size && !at(x+7, y, vertical) && !at(x+8, y, vertical) && !at(x+9, y, vertical) && !at(x+10, y, vertical)
+					if before || after {
+						p += 40
+					}
+				}
+			}
+		}
+	}
+	dark := 0
+	for y := 0; y < m.size; y++ {
+		for x := 0; x < m.size; x++ {
+			if m.modules[y][x] {
+				dark++
+			}
+			if x+1 < m.size && y+1 < m.size {
+				c := m.modules[y][x]
+				if c == m.modules[y][x+1] && c == m.modules[y+1][x] && c == m.modules[y+1][x+1] {
+					p += 3
+				}
+			}
+		}
+	}
+	total := m.size * m.size
+	p += absInt(dark*20-total*10) / total * 10
+	return p
+}
+
+// QRSVG renders data as an inline SVG QR code with a four-module quiet zone.
+func QRSVG(data string, moduleSize int) (string, error) {
+	modules, err := QR(data)
+	if err != nil {
+		return "", err
+	}
+	if moduleSize <= 0 {
+		moduleSize = 4
+	}
+	n := len(modules) + 8
+	var path strings.Builder
+	for y, row := range modules {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&path, "M%d %dh1v1h-1z", x+4, y+4)
+			}
+		}
+	}
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+
+		`<rect width="%d" height="%d" fill="#fff"/><path d="%s" fill="#000"/></svg>`,
+		n*moduleSize, n*moduleSize, n, n, n, n, path.String()), nil
+}
+
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,441 @@
+// Package report renders FoodBlock audit results, recall reports, compliance
+// checklists and provenance narratives as HTML documents. Every referenced
+// block carries a QR code pointing at its verification URI, and the document
+// embeds the Merkle root of the blocks it covers so a reader can check the
+// report against a snapshot.
+package report
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	foodblock "github.com/FoodXDevelopment/foodblock/sdk/go"
+)
+
+// Kind identifies the type of report.
+type Kind string
+
+const (
+	KindAudit      Kind = "audit"
+	KindRecall     Kind = "recall"
+	KindChecklist  Kind = "checklist"
+	KindProvenance Kind = "provenance"
+)
+
+// Entry is one line of a report section, optionally tied to a block.
+type Entry struct {
+	Label  string
+	Detail string
+	Status string // "pass", "fail", "warn" or empty
+	Hash   string
+}
+
+// Section groups entries under a heading, with optional prose.
+type Section struct {
+	Heading string
+	Text    string
+	Entries []Entry
+}
+
+// Report is a renderable document and the blocks it covers.
+type Report struct {
+	Kind        Kind
+	Title       string
+	Subject     string
+	GeneratedAt time.Time
+	Sections    []Section
+	Blocks      []foodblock.Block
+}
+
+// CheckItem is one requirement of a compliance checklist.
+type CheckItem struct {
+	Label    string
+	Passed   bool
+	Evidence string // hash of the block that satisfies the requirement
+	Note     string
+}
+
+// Audit builds a report of schema validation and attestations for a block.
+func Audit(title string, block foodblock.Block, schema *foodblock.Schema, all []foodblock.Block) Report {
+	r := Report{Kind: KindAudit, Title: title, Subject: block.Hash, Blocks: []foodblock.Block{block}}
+
+	validation := Section{Heading: "Schema validation"}
+	if schema == nil {
+		validation.Text = "No schema registered for " + block.Type + "."
+	} else if errs := foodblock.Validate(block, schema); len(errs) == 0 {
+		validation.Entries = append(validation.Entries, Entry{Label: block.Type, Detail: "Valid", Status: "pass", Hash: block.Hash})
+	} else {
+		for _, e := range errs {
+			validation.Entries = append(validation.Entries, Entry{Label: block.Type, Detail: e, Status: "fail", Hash: block.Hash})
+		}
+	}
+	r.Sections = append(r.Sections, validation)
+
+	trace := foodblock.TraceAttestations(block.Hash, all)
+	attest := Section{Heading: "Attestations"}
+	for _, a := range trace.Attestations {
+		attest.Entries = append(attest.Entries, Entry{Label: "Attested", Detail: stateString(a, "confidence", "method"), Status: "pass", Hash: a.Hash})
+		r.Blocks = append(r.Blocks, a)
+	}
+	for _, d := range trace.Disputes {
+		attest.Entries = append(attest.Entries, Entry{Label: "Disputed", Detail: stateString(d, "reason"), Status: "fail", Hash: d.Hash})
+		r.Blocks = append(r.Blocks, d)
+	}
+	for i, a := range trace.Revoked {
+		rev := trace.Revocations[i]
+		attest.Entries = append(attest.Entries, Entry{Label: "Revoked", Detail: stateString(rev, "reason"), Status: "warn", Hash: a.Hash})
+		r.Blocks = append(r.Blocks, a, rev)
+	}
+	attest.Text = "Net attestation score: " + strconv.Itoa(trace.Score) + "."
+	r.Sections = append(r.Sections, attest)
+	return r
+}
+
+// Recall builds a report of every block affected by a recall of source.
+func Recall(source foodblock.Block, result foodblock.RecallResult) Report {
+	r := Report{
+		Kind:    KindRecall,
+		Title:   "Recall report: " + label(source),
+		Subject: source.Hash,
+		Blocks:  append([]foodblock.Block{source}, result.Affected...),
+	}
+	affected := Section{
+		Heading: "Affected blocks",
+		Text:    strconv.Itoa(len(result.Affected)) + " blocks affected, traced to depth " + strconv.Itoa(result.Depth) + ".",
+	}
+	for _, b := range result.Affected {
+		affected.Entries = append(affected.Entries, Entry{Label: label(b), Detail: b.Type, Status: "warn", Hash: b.Hash})
+	}
+	r.Sections = append(r.Sections, Section{
+		Heading: "Source",
+		Entries: []Entry{{Label: label(source), Detail: source.Type, Hash: source.Hash}},
+	}, affected)
+	if scope := recallScope(result.Options); scope != "" {
+		s := Section{Heading: "Scope", Text: scope}
+		for _, h := range result.Stopped {
+			for _, b := range result.Affected {
+				if b.Hash == h {
+					s.Entries = append(s.Entries, Entry{Label: label(b), Detail: "not traced further", Hash: h})
+				}
+			}
+		}
+		r.Sections = append(r.Sections, s)
+	}
+	return r
+}
+
+// recallScope describes the filters a recall was traced under.
+func recallScope(o foodblock.RecallOptions) string {
+	var parts []string
+	for _, f := range []struct {
+		label  string
+		values []string
+	}{
+		{"Only types", o.Types},
+		{"Only roles", o.Roles},
+		{"Excluding types", o.ExcludeTypes},
+		{"Excluding roles", o.ExcludeRoles},
+		{"Stopping at types", o.StopTypes},
+	} {
+		if len(f.values) > 0 {
+			parts = append(parts, f.label+": "+strings.Join(f.values, ", ")+".")
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Checklist builds a compliance checklist report. Blocks should contain the
+// evidence blocks so they are covered by the report's Merkle root.
+func Checklist(title, subject string, items []CheckItem, blocks []foodblock.Block) Report {
+	r := Report{Kind: KindChecklist, Title: title, Subject: subject, Blocks: blocks}
+	s := Section{Heading: "Requirements"}
+	passed := 0
+	for _, item := range items {
+		status := "fail"
+		if item.Passed {
+			status = "pass"
+			passed++
+		}
+		s.Entries = append(s.Entries, Entry{Label: item.Label, Detail: item.Note, Status: status, Hash: item.Evidence})
+	}
+	s.Text = strconv.Itoa(passed) + " of " + strconv.Itoa(len(items)) + " requirements met."
+	r.Sections = append(r.Sections, s)
+	return r
+}
+
+// Provenance builds a narrative report of a block and everything it references.
+func Provenance(hash string, resolve func(string) *foodblock.Block, maxDepth int) (Report, error) {
+	block := resolve(hash)
+	if block == nil {
+		return Report{}, errors.New("report: block not found: " + hash)
+	}
+	r := Report{Kind: KindProvenance, Title: "Provenance: " + label(*block), Subject: hash}
+	r.Sections = append(r.Sections, Section{Heading: "Narrative", Text: foodblock.Explain(hash, resolve, maxDepth)})
+	if m, ok := foodblock.ManufacturingOf(*block, resolve); ok {
+		made := Section{Heading: "Attribution", Text: m.Describe(resolve)}
+		for _, role := range []struct{ label, hash string }{
+			{"Brand owner", m.BrandOwner}, {"Manufacturer", m.Manufacturer}, {"Facility", m.Facility},
+		} {
+			if role.hash == "" {
+				continue
+			}
+			detail := role.hash
+			if b := resolve(role.hash); b != nil {
+				detail = label(*b)
+			}
+			made.Entries = append(made.Entries, Entry{Label: role.label, Detail: detail, Hash: role.hash})
+		}
+		r.Sections = append(r.Sections, made)
+	}
+
+	chain := Section{Heading: "Referenced blocks"}
+	visited := map[string]bool{}
+	var walk func(b *foodblock.Block, depth int)
+	walk = func(b *foodblock.Block, depth int) {
+		if b == nil || visited[b.Hash] || (maxDepth > 0 && depth > maxDepth) {
+			return
+		}
+		visited[b.Hash] = true
+		r.Blocks = append(r.Blocks, *b)
+		chain.Entries = append(chain.Entries, Entry{Label: label(*b), Detail: b.Type, Hash: b.Hash})
+		roles := make([]string, 0, len(b.Refs))
+		for role := range b.Refs {
+			roles = append(roles, role)
+		}
+		sort.Strings(roles)
+		for _, role := range roles {
+			for _, ref := range refHashes(b.Refs[role]) {
+				walk(resolve(ref), depth+1)
+			}
+		}
+	}
+	walk(block, 0)
+	r.Sections = append(r.Sections, chain)
+	return r, nil
+}
+
+// WithNotes adds a section listing the notes about the report's subject, so
+// discussion travels with the provenance. Encrypted notes are listed without
+// their text.
+func WithNotes(r Report, store foodblock.BlockStore) Report {
+	notes := Section{Heading: "Notes"}
+	var walk func(ns []foodblock.Note, depth int)
+	walk = func(ns []foodblock.Note, depth int) {
+		for _, n := range ns {
+			who := "Note"
+			if depth > 0 {
+				who = "Reply"
+			}
+			if a := store.Resolve(n.Author); a != nil {
+				who += " from " + label(*a)
+			}
+			detail := n.Text
+			if n.Encrypted {
+				detail = "(encrypted)"
+			}
+			notes.Entries = append(notes.Entries, Entry{Label: who, Detail: detail, Hash: n.Block.Hash})
+			r.Blocks = append(r.Blocks, n.Block)
+			walk(n.Replies, depth+1)
+		}
+	}
+	walk(foodblock.NotesFor(r.Subject, store), 0)
+	if len(notes.Entries) > 0 {
+		r.Sections = append(r.Sections, notes)
+	}
+	return r
+}
+
+// SnapshotRoot returns the Merkle root of the report's blocks, matching the
+// merkle_root of an observe.snapshot over the same blocks.
+func (r Report) SnapshotRoot() string {
+	root, _ := foodblock.CreateSnapshot(r.Blocks, "", nil).State["merkle_root"].(string)
+	return root
+}
+
+// PDFRenderer converts rendered HTML into PDF. Implementations typically wrap
+// a headless browser or an HTML-to-PDF library.
+type PDFRenderer interface {
+	RenderPDF(html []byte, w io.Writer) error
+}
+
+// Options controls rendering.
+type Options struct {
+	// VerifyURL maps a block hash to the URI encoded in its QR code.
+	// Defaults to the fb: URI.
+	VerifyURL func(hash string) string
+	// NoQR disables QR code generation.
+	NoQR bool
+	// Now overrides the generation time when the report has none.
+	Now func() time.Time
+}
+
+type viewEntry struct {
+	Entry
+	URL template.URL
+	QR  template.HTML
+}
+
+type viewSection struct {
+	Heading string
+	Text    string
+	Entries []viewEntry
+}
+
+type view struct {
+	Kind        Kind
+	Title       string
+	Subject     string
+	GeneratedAt string
+	BlockCount  int
+	Root        string
+	RootQR      template.HTML
+	Sections    []viewSection
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body{font-family:sans-serif;margin:2em;color:#222}
+table{border-collapse:collapse;width:100%}
+td,th{border-bottom:1px solid #ddd;padding:.4em;text-align:left;vertical-align:top}
+.pass{color:#16683a}.fail{color:#b3261e}.warn{color:#8a5a00}
+code{font-size:.8em;word-break:break-all}
+</style>
+</head>
+<body class="report report-{{.Kind}}">
+<h1>{{.Title}}</h1>
+<p>Generated {{.GeneratedAt}}{{if .Subject}} for <code>{{.Subject}}</code>{{end}}</p>
+{{range .Sections}}<section>
+<h2>{{.Heading}}</h2>
+{{if .Text}}<p>{{.Text}}</p>
+{{end}}{{if .Entries}}<table>
+<tr><th>Item</th><th>Detail</th><th>Status</th><th>Block</th></tr>
+{{range .Entries}}<tr><td>{{.Label}}</td><td>{{.Detail}}</td><td class="{{.Status}}">{{.Status}}</td><td>{{if .Hash}}{{.QR}}<br><code><a href="{{.URL}}">{{.Hash}}</a></code>{{end}}</td></tr>
+{{end}}</table>
+{{end}}</section>
+{{end}}<footer>
+<h2>Verification</h2>
+<p>This report covers {{.BlockCount}} blocks with Merkle root <code>{{.Root}}</code>. Recompute the root over the referenced blocks, or compare it with a signed observe.snapshot, to verify the report has not been altered.</p>
+{{.RootQR}}
+</footer>
+</body>
+</html>
+`))
+
+// RenderHTML writes the report as a standalone HTML document.
+func RenderHTML(w io.Writer, r Report, opts Options) error {
+	verifyURL := opts.VerifyURL
+	if verifyURL == nil {
+		verifyURL = foodblock.ToURIFromHash
+	}
+	generated := r.GeneratedAt
+	if generated.IsZero() {
+		if opts.Now != nil {
+			generated = opts.Now()
+		} else {
+			generated = time.Now()
+		}
+	}
+	v := view{
+		Kind:        r.Kind,
+		Title:       r.Title,
+		Subject:     r.Subject,
+		GeneratedAt: generated.UTC().Format(time.RFC3339),
+		BlockCount:  len(r.Blocks),
+		Root:        r.SnapshotRoot(),
+	}
+	qr := func(data string) (template.HTML, error) {
+		if opts.NoQR {
+			return "", nil
+		}
+		svg, err := QRSVG(data, 2)
+		if err != nil {
+			return "", err
+		}
+		return template.HTML(svg), nil
+	}
+	var err error
+	if v.RootQR, err = qr("merkle:" + v.Root); err != nil {
+		return err
+	}
+	for _, s := range r.Sections {
+		vs := viewSection{Heading: s.Heading, Text: s.Text}
+		for _, e := range s.Entries {
+			ve := viewEntry{Entry: e}
+			if e.Hash != "" {
+				url := verifyURL(e.Hash)
+				ve.URL = template.URL(url)
+				if ve.QR, err = qr(url); err != nil {
+					return err
+				}
+			}
+			vs.Entries = append(vs.Entries, ve)
+		}
+		v.Sections = append(v.Sections, vs)
+	}
+	return htmlTemplate.Execute(w, v)
+}
+
+// RenderPDF renders the report to HTML and converts it with the given renderer.
+func RenderPDF(w io.Writer, r Report, opts Options, pdf PDFRenderer) error {
+	if pdf == nil {
+		return errors.New("report: no PDF renderer configured")
+	}
+	var buf bytes.Buffer
+	if err := RenderHTML(&buf, r, opts); err != nil {
+		return err
+	}
+	return pdf.RenderPDF(buf.Bytes(), w)
+}
+
+func label(b foodblock.Block) string {
+	for _, k := range []string{"name", "title", "label"} {
+		if s, ok := b.State[k].(string); ok && s != "" {
+			return s
+		}
+	}
+	if len(b.Hash) > 12 {
+		return b.Type + " " + b.Hash[:12]
+	}
+	return b.Type
+}
+
+func stateString(b foodblock.Block, keys ...string) string {
+	out := ""
+	for _, k := range keys {
+		if s, ok := b.State[k].(string); ok && s != "" {
+			if out != "" {
+				out += ", "
+			}
+			out += k + ": " + s
+		}
+	}
+	return out
+}
+
+func refHashes(v interface{}) []string {
+	switch r := v.(type) {
+	case string:
+		return []string{r}
+	case []string:
+		return r
+	case []interface{}:
+		var out []string
+		for _, item := range r {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package report
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	foodblock "github.com/FoodXDevelopment/foodblock/sdk/go"
+)
+
+func TestRSRemainderKnownVector(t *testing.T) {
+	// "HELLO WORLD" at 1-M, from the ISO 18004 worked example.
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	got := rsRemainder(data, 10)
+	if !bytes.Equal(got, want) {
+		t.Fatalf("ec codewords = %v, want %v", got, want)
+	}
+}
+
+func TestQRStructure(t *testing.T) {
+	uri := foodblock.ToURIFromHash(strings.Repeat("ab", 32))
+	m, err := QR(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 67 bytes needs version 5 at level M: 37x37 modules.
+	if len(m) != 37 {
+		t.Fatalf("size = %d, want 37", len(m))
+	}
+	for _, c := range [][2]int{{0, 0}, {30, 0}, {0, 30}} {
+		for i := 0; i < 7; i++ {
+			if !m[c[1]][c[0]+i] || !m[c[1]+6][c[0]+i] || !m[c[1]+i][c[0]] || !m[c[1]+i][c[0]+6] {
+				t.Fatalf("finder pattern at %v incomplete", c)
+			}
+		}
+	}
+	if !m[len(m)-8][8] {
+		t.Error("dark module missing")
+	}
+
+	// Format bits read back must be a valid level M codeword.
+	format := 0
+	for i := 0; i < 8; i++ {
+		if m[8][len(m)-1-i] {
+			format |= 1 << i
+		}
+	}
+	for i := 8; i < 15; i++ {
+		if m[len(m)-15+i][8] {
+			format |= 1 << i
+		}
+	}
+	mask := (format ^ 0x5412) >> 10
+	if mask>>3 != 0 {
+		t.Fatalf("format level bits = %b, want M (00)", mask>>3)
+	}
+	want := newQRMatrix(5)
+	want.drawFormatBits(mask & 7)
+	if got := want.modules[8][len(m)-1]; got != m[8][len(m)-1] {
+		t.Error("format information inconsistent")
+	}
+}
+
+func TestQRTooLong(t *testing.T) {
+	if _, err := QR(strings.Repeat("x", 300)); err == nil {
+		t.Fatal("expected error for oversized payload")
+	}
+}
+
+func testChain() (foodblock.Block, foodblock.Block, map[string]*foodblock.Block) {
+	farm := foodblock.Create("actor.producer", map[string]interface{}{"name": "Green Acres"}, nil)
+	wheat := foodblock.Create("substance.ingredient", map[string]interface{}{"name": "Wheat"}, map[string]interface{}{"source": farm.Hash})
+	bread := foodblock.Create("substance.product", map[string]interface{}{"name": "Sourdough"}, map[string]interface{}{"inputs": []interface{}{wheat.Hash}, "seller": farm.Hash})
+	index := map[string]*foodblock.Block{farm.Hash: &farm, wheat.Hash: &wheat, bread.Hash: &bread}
+	return wheat, bread, index
+}
+
+func TestProvenanceHTML(t *testing.T) {
+	_, bread, index := testChain()
+	r, err := Provenance(bread.Hash, func(h string) *foodblock.Block { return index[h] }, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Blocks) != 3 {
+		t.Fatalf("blocks = %d, want 3", len(r.Blocks))
+	}
+	var buf bytes.Buffer
+	if err := RenderHTML(&buf, r, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{"Sourdough", r.SnapshotRoot(), "<svg", "fb:" + bread.Hash} {
+		if !strings.Contains(out, want) {
+			t.Errorf("HTML missing %q", want)
+		}
+	}
+	snap := foodblock.CreateSnapshot(r.Blocks, "", nil)
+	if snap.State["merkle_root"] != r.SnapshotRoot() {
+		t.Error("snapshot root does not match observe.snapshot")
+	}
+}
+
+func TestProvenanceAttribution(t *testing.T) {
+	brand := foodblock.Create("actor.brand", map[string]interface{}{"name": "Hillside Granola"}, nil)
+	packer := foodblock.Create("actor.processor", map[string]interface{}{"name": "PackRight Ltd"}, nil)
+	plant := foodblock.Create("place.facility", map[string]interface{}{"name": "Leeds Plant 2"}, nil)
+	granola := foodblock.Create("substance.product", map[string]interface{}{"name": "Honey Granola"}, nil)
+	rel, _ := foodblock.CreateManufacturing(granola.Hash, brand.Hash, packer.Hash, plant.Hash, nil)
+	attributed := foodblock.AttachManufacturing(granola, rel)
+	index := map[string]*foodblock.Block{}
+	for _, b := range []foodblock.Block{brand, packer, plant, granola, rel, attributed} {
+		b := b
+		index[b.Hash] = &b
+	}
+	r, err := Provenance(attributed.Hash, func(h string) *foodblock.Block { return index[h] }, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var made *Section
+	for i := range r.Sections {
+		if r.Sections[i].Heading == "Attribution" {
+			made = &r.Sections[i]
+		}
+	}
+	if made == nil || made.Text != "Made by PackRight Ltd for Hillside Granola at Leeds Plant 2." || len(made.Entries) != 3 {
+		t.Fatalf("expected an attribution section, got %+v", r.Sections)
+	}
+}
+
+func TestChecklistAndRecall(t *testing.T) {
+	wheat, bread, _ := testChain()
+	c := Checklist("HACCP", bread.Hash, []CheckItem{
+		{Label: "Allergen label", Passed: true, Evidence: bread.Hash},
+		{Label: "Temperature log", Passed: false, Note: "missing"},
+	}, []foodblock.Block{bread})
+	if c.Sections[0].Text != "1 of 2 requirements met." {
+		t.Errorf("summary = %q", c.Sections[0].Text)
+	}
+
+	r := Recall(wheat, foodblock.RecallResult{Affected: []foodblock.Block{bread}, Depth: 1})
+	var buf bytes.Buffer
+	if err := RenderHTML(&buf, r, Options{NoQR: true, VerifyURL: func(h string) string { return "https://example.test/b/" + h }}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "<svg") {
+		t.Error("NoQR still rendered QR codes")
+	}
+	if !strings.Contains(buf.String(), "https://example.test/b/"+bread.Hash) {
+		t.Error("custom verify URL not used")
+	}
+	scoped := Recall(wheat, foodblock.RecallResult{Affected: []foodblock.Block{bread}, Depth: 1, Stopped: []string{bread.Hash},
+		Options: foodblock.RecallOptions{ExcludeTypes: []string{"observe.*"}, StopTypes: []string{"substance.product"}}})
+	if s := scoped.Sections[len(scoped.Sections)-1]; s.Heading != "Scope" || s.Text != "Excluding types: observe.*. Stopping at types: substance.product." || len(s.Entries) != 1 {
+		t.Errorf("scope = %+v", s)
+	}
+}
+
+type fakePDF struct{ html []byte }
+
+func (f *fakePDF) RenderPDF(html []byte, w io.Writer) error {
+	f.html = html
+	_, err := w.Write([]byte("%PDF-1.4"))
+	return err
+}
+
+func TestRenderPDF(t *testing.T) {
+	wheat, _, _ := testChain()
+	r := Audit("Audit", wheat, nil, nil)
+	var buf bytes.Buffer
+	if err := RenderPDF(&buf, r, Options{}, nil); err == nil {
+		t.Fatal("expected error without renderer")
+	}
+	pdf := &fakePDF{}
+	if err := RenderPDF(&buf, r, Options{}, pdf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte("%PDF")) || !bytes.Contains(pdf.html, []byte("<!DOCTYPE html>")) {
+		t.Error("PDF renderer not given HTML")
+	}
+}
+
+func TestProvenanceWithNotes(t *testing.T) {
+	wheat, bread, _ := testChain()
+	store := foodblock.NewMemoryStore()
+	store.PutAll([]foodblock.Block{wheat, bread})
+	note, _ := foodblock.CreateNote(bread.Hash, "", "Reformulated in spring", foodblock.NoteOptions{})
+	store.Put(note)
+
+	r, err := Provenance(bread.Hash, store.Resolve, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = WithNotes(r, store)
+	last := r.Sections[len(r.Sections)-1]
+	if last.Heading != "Notes" || len(last.Entries) != 1 || last.Entries[0].Detail != "Reformulated in spring" {
+		t.Fatalf("sections = %+v", r.Sections)
+	}
+	if r.Blocks[len(r.Blocks)-1].Hash != note.Hash {
+		t.Error("expected the note among the report's blocks")
+	}
+}
@@ -0,0 +1,145 @@
+package foodblock
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RoleDef defines a canonical ref role. Source and Target are type patterns
+// such as "actor.*"; empty means any type. Inverse names the relation read
+// from the target's side: a product's seller is an actor that sells it.
+type RoleDef struct {
+	Name        string
+	Inverse     string
+	Aliases     []string
+	Source      []string // types that may carry the ref
+	Target      []string // types the ref may point at
+	Description string
+}
+
+// RefRoles is the default role registry. Roles not in it stay free-form.
+var RefRoles = map[string]RoleDef{
+	"seller":         {Name: "seller", Inverse: "sells", Aliases: []string{"vendor", "sold_by", "merchant"}, Target: []string{"actor.*"}, Description: "Actor selling the item"},
+	"buyer":          {Name: "buyer", Inverse: "buys", Aliases: []string{"purchaser", "bought_by", "customer"}, Target: []string{"actor.*"}, Description: "Actor buying the item"},
+	"producer":       {Name: "producer", Inverse: "produces", Aliases: []string{"grower", "farmer", "produced_by", "maker"}, Target: []string{"actor.*"}, Description: "Actor that produced it"},
+	"operator":       {Name: "operator", Inverse: "operates", Aliases: []string{"operated_by"}, Target: []string{"actor.*"}, Description: "Actor running the place or process"},
+	"author":         {Name: "author", Inverse: "authored", Aliases: []string{"written_by", "reviewer"}, Target: []string{"actor.*"}, Description: "Actor who wrote the block"},
+	"attestor":       {Name: "attestor", Inverse: "attests", Target: []string{"actor.*"}, Description: "Actor vouching for a claim"},
+	"inputs":         {Name: "inputs", Inverse: "input_to", Aliases: []string{"ingredients"}, Source: []string{"substance.*", "transform.*"}, Target: []string{"substance.*"}, Description: "Substances consumed"},
+	"source":         {Name: "source", Inverse: "source_of", Description: "Where it came from"},
+	"origin":         {Name: "origin", Inverse: "origin_of", Target: []string{"place.*", "actor.*"}, Description: "Place or actor of origin"},
+	"place":          {Name: "place", Inverse: "hosts", Aliases: []string{"location", "site", "venue"}, Target: []string{"place.*"}, Description: "Where it happened"},
+	"item":           {Name: "item", Inverse: "item_of", Source: []string{"transfer.*"}, Target: []string{"substance.*"}, Description: "Substance transferred"},
+	"subject":        {Name: "subject", Inverse: "subject_of", Description: "Block an observation is about"},
+	"certifications": {Name: "certifications", Inverse: "certifies", Aliases: []string{"certification", "certified_by"}, Target: []string{"observe.*"}, Description: "Certificates held"},
+	"confirms":       {Name: "confirms", Inverse: "confirmed_by", Source: []string{"observe.*"}, Description: "Block an attestation confirms"},
+	"updates":        {Name: "updates", Inverse: "updated_by", Description: "Previous version"},
+}
+
+// CanonicalRole maps a role or one of its aliases to its canonical name.
+// A nil registry uses RefRoles.
+func CanonicalRole(role string, roles map[string]RoleDef) (string, bool) {
+	if roles == nil {
+		roles = RefRoles
+	}
+	if _, ok := roles[role]; ok {
+		return role, true
+	}
+	for _, name := range sortedRoleNames(roles) {
+		if containsStr(roles[name].Aliases, role) {
+			return name, true
+		}
+	}
+	return role, false
+}
+
+// InverseRole names the relation read the other way: "seller" gives
+// "sells" and "sells" gives "seller". Unknown roles give "".
+func InverseRole(role string, roles map[string]RoleDef) string {
+	if roles == nil {
+		roles = RefRoles
+	}
+	if name, ok := CanonicalRole(role, roles); ok {
+		return roles[name].Inverse
+	}
+	for _, name := range sortedRoleNames(roles) {
+		if roles[name].Inverse == role {
+			return name
+		}
+	}
+	return ""
+}
+
+// ValidateRefRoles checks a block's refs against a role registry. Aliases
+// are reported so they can be renamed, as are refs on a type the role does
+// not belong to and refs pointing at the wrong type of block. Roles the
+// registry does not know, and refs resolve cannot find, are not checked.
+func ValidateRefRoles(b Block, resolve func(string) *Block, roles map[string]RoleDef) []string {
+	if roles == nil {
+		roles = RefRoles
+	}
+	names := make([]string, 0, len(b.Refs))
+	for role := range b.Refs {
+		names = append(names, role)
+	}
+	sort.Strings(names)
+
+	var errs []string
+	for _, role := range names {
+		name, known := CanonicalRole(role, roles)
+		if !known {
+			continue
+		}
+		def := roles[name]
+		if name != role {
+			errs = append(errs, fmt.Sprintf("refs.%s is an alias of %s", role, name))
+		}
+		if !matchesAnyType(b.Type, def.Source) {
+			errs = append(errs, fmt.Sprintf("refs.%s is not expected on %s, only on %s", role, b.Type, strings.Join(def.Source, ", ")))
+		}
+		if resolve == nil || len(def.Target) == 0 {
+			continue
+		}
+		for _, h := range flattenRefValues(map[string]interface{}{role: b.Refs[role]}) {
+			if target := resolve(h); target != nil && !matchesAnyType(target.Type, def.Target) {
+				errs = append(errs, fmt.Sprintf("refs.%s should point at %s, got %s", role, strings.Join(def.Target, ", "), target.Type))
+			}
+		}
+	}
+	return errs
+}
+
+// refByRole finds a ref by its canonical role, falling back to aliases.
+func refByRole(refs map[string]interface{}, role string) (interface{}, bool) {
+	if v, ok := refs[role]; ok {
+		return v, true
+	}
+	for _, alias := range RefRoles[role].Aliases {
+		if v, ok := refs[alias]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+func matchesAnyType(typ string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		if matchesType(typ, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedRoleNames(roles map[string]RoleDef) []string {
+	names := make([]string, 0, len(roles))
+	for name := range roles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,66 @@
+package foodblock
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRefRoles(t *testing.T) {
+	if name, ok := CanonicalRole("sold_by", nil); !ok || name != "seller" {
+		t.Errorf("CanonicalRole(sold_by) = %s, %v", name, ok)
+	}
+	if InverseRole("seller", nil) != "sells" || InverseRole("sells", nil) != "seller" || InverseRole("vendor", nil) != "sells" {
+		t.Error("seller and sells should be inverses")
+	}
+	if InverseRole("favourite", nil) != "" {
+		t.Error("unknown roles have no inverse")
+	}
+
+	mill := Create("actor.producer", map[string]interface{}{"name": "Mill"}, nil)
+	flour := Create("substance.ingredient", map[string]interface{}{"name": "Flour"}, map[string]interface{}{"vendor": mill.Hash})
+	odd := Create("observe.review", map[string]interface{}{"rating": 4.0}, map[string]interface{}{"seller": flour.Hash, "item": flour.Hash, "mood": mill.Hash})
+	resolve := makeResolver(map[string]*Block{mill.Hash: &mill, flour.Hash: &flour})
+
+	if errs := ValidateRefRoles(flour, resolve, nil); len(errs) != 1 || errs[0] != "refs.vendor is an alias of seller" {
+		t.Errorf("flour: %v", errs)
+	}
+	errs := strings.Join(ValidateRefRoles(odd, resolve, nil), "\n")
+	for _, want := range []string{"refs.item is not expected on observe.review", "refs.seller should point at actor.*, got substance.ingredient"} {
+		if !strings.Contains(errs, want) {
+			t.Errorf("missing %q in:\n%s", want, errs)
+		}
+	}
+	if strings.Contains(errs, "mood") {
+		t.Errorf("unknown roles should stay free-form:\n%s", errs)
+	}
+
+	// Role checks are opt-in in the pipeline.
+	batch := []SignedBlock{{FoodBlock: mill}, {FoodBlock: flour}, {FoodBlock: odd}}
+	if r := ValidatePipeline(batch, PipelineOptions{}); !r.OK {
+		t.Errorf("without Roles: %+v", r.Blocks)
+	}
+	r := ValidatePipeline(batch, PipelineOptions{Roles: RefRoles})
+	if last := r.Blocks[1].Issues[len(r.Blocks[1].Issues)-1]; !r.Blocks[1].Valid || last.Check != "roles" || last.Severity != "warning" || r.Blocks[2].Valid {
+		t.Errorf("with Roles: %+v", r.Blocks)
+	}
+}
+
+func TestForwardAndExplainUseRoles(t *testing.T) {
+	mill := Create("actor.producer", map[string]interface{}{"name": "Mill"}, nil)
+	rye := Create("substance.product", map[string]interface{}{"name": "Rye"}, map[string]interface{}{"seller": mill.Hash})
+	spelt := Create("substance.product", map[string]interface{}{"name": "Spelt"}, map[string]interface{}{"sold_by": mill.Hash})
+	review := Create("observe.review", map[string]interface{}{"title": "Great mill"}, map[string]interface{}{"subject": mill.Hash})
+	note := Create("observe.note", map[string]interface{}{"title": "Visit"}, map[string]interface{}{"visited": mill.Hash})
+
+	result := Forward(mill.Hash, buildForwardIndex([]Block{mill, rye, spelt, review, note}))
+	got := result.Describe(mill)
+	want := "Mill is subject of Great mill. Mill is visited of Visit. Mill sells Rye and Spelt."
+	if got != want {
+		t.Errorf("Describe = %q, want %q", got, want)
+	}
+
+	narrative := Explain(spelt.Hash, makeResolver(map[string]*Block{mill.Hash: &mill, spelt.Hash: &spelt}), 0)
+	if !strings.Contains(narrative, "By Mill.") {
+		t.Errorf("aliased seller not explained: %q", narrative)
+	}
+}
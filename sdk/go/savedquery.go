@@ -0,0 +1,166 @@
+package foodblock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SavedQueryType is a shareable query: state.query holds QueryParams as JSON,
+// in which a string value "$name" is a parameter filled in when the query is
+// run, so one block can serve every member of a co-op.
+const SavedQueryType = "observe.query"
+
+// SaveQuery creates a saved query block. minTrust, when positive, keeps only
+// results whose trust score as an actor reaches it. The parameters the query
+// takes are listed in state.parameters.
+func SaveQuery(name string, params QueryParams, minTrust float64) (Block, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return Block{}, err
+	}
+	var query map[string]interface{}
+	json.Unmarshal(data, &query)
+	state := map[string]interface{}{"name": name, "query": query}
+	if names := queryParameters(query, nil); len(names) > 0 {
+		sort.Strings(names)
+		list := make([]interface{}, len(names))
+		for i, n := range names {
+			list[i] = n
+		}
+		state["parameters"] = list
+	}
+	if minTrust > 0 {
+		state["min_trust"] = minTrust
+	}
+	return Create(SavedQueryType, state, nil), nil
+}
+
+// RunSavedQuery runs a saved query block against a store, substituting args
+// for its parameters. Every parameter must be given. Stores with a Query
+// method evaluate it themselves; others are filtered in memory.
+func RunSavedQuery(query Block, args map[string]interface{}, store BlockStore) ([]Block, error) {
+	if query.Type != SavedQueryType {
+		return nil, fmt.Errorf("FoodBlock: %s is not a saved query", query.Type)
+	}
+	raw, ok := query.State["query"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("FoodBlock: saved query has no state.query")
+	}
+	var missing []string
+	bound := bindQueryArgs(raw, args, &missing)
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("FoodBlock: saved query needs %s", strings.Join(missing, ", "))
+	}
+	data, err := json.Marshal(bound)
+	if err != nil {
+		return nil, err
+	}
+	var params QueryParams
+	if err := json.Unmarshal(data, &params); err != nil {
+		return nil, fmt.Errorf("FoodBlock: saved query %s: %w", query.Hash, err)
+	}
+
+	minTrust, _ := toFloat64(query.State["min_trust"])
+	if minTrust <= 0 {
+		return runQuery(params, store)
+	}
+	// Trust filtering has to see every match before the page is cut.
+	limit, offset := params.Limit, params.Offset
+	params.Limit, params.Offset = 0, 0
+	matches, err := runQuery(params, store)
+	if err != nil {
+		return nil, err
+	}
+	all := store.Blocks()
+	authors, _ := store.(interface{ AuthorOf(string) string })
+	tb := make([]TrustBlock, len(all))
+	for i, b := range all {
+		tb[i] = TrustBlock{Block: b}
+		if authors != nil {
+			tb[i].AuthorHash = authors.AuthorOf(b.Hash)
+		}
+	}
+	var out []Block
+	for _, b := range matches {
+		if ComputeTrust(b.Hash, tb, nil).Score < minTrust {
+			continue
+		}
+		if offset > 0 {
+			offset--
+			continue
+		}
+		out = append(out, b)
+		if limit > 0 && len(out) >= limit {
+			break
+		}
+	}
+	return out, nil
+}
+
+func runQuery(params QueryParams, store BlockStore) ([]Block, error) {
+	if q, ok := store.(interface {
+		Query(QueryParams) ([]Block, error)
+	}); ok {
+		return q.Query(params)
+	}
+	return FilterBlocks(store.Blocks(), params), nil
+}
+
+// parameterName returns the name of a "$name" placeholder.
+func parameterName(v interface{}) (string, bool) {
+	s, ok := v.(string)
+	if !ok || len(s) < 2 || s[0] != '$' {
+		return "", false
+	}
+	return s[1:], true
+}
+
+func queryParameters(v interface{}, names []string) []string {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for _, x := range t {
+			names = queryParameters(x, names)
+		}
+	case []interface{}:
+		for _, x := range t {
+			names = queryParameters(x, names)
+		}
+	default:
+		if name, ok := parameterName(v); ok {
+			names = appendUnique(names, name)
+		}
+	}
+	return names
+}
+
+// bindQueryArgs copies v with placeholders replaced by args, recording the
+// names args lacks.
+func bindQueryArgs(v interface{}, args map[string]interface{}, missing *[]string) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, x := range t {
+			out[k] = bindQueryArgs(x, args, missing)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, x := range t {
+			out[i] = bindQueryArgs(x, args, missing)
+		}
+		return out
+	}
+	name, ok := parameterName(v)
+	if !ok {
+		return v
+	}
+	arg, given := args[name]
+	if !given {
+		*missing = appendUnique(*missing, name)
+	}
+	return arg
+}
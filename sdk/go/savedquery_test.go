@@ -0,0 +1,48 @@
+package foodblock
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSavedQuery(t *testing.T) {
+	store := NewMemoryStore()
+	trusted := Create("actor.producer", map[string]interface{}{"name": "Green Acres", "region": "north", "organic": true}, nil)
+	unknown := Create("actor.producer", map[string]interface{}{"name": "New Farm", "region": "north", "organic": true}, nil)
+	south := Create("actor.producer", map[string]interface{}{"name": "Sun Farm", "region": "south", "organic": true}, nil)
+	store.PutAll([]Block{trusted, unknown, south})
+	for i := 0; i < 3; i++ {
+		review := Create("observe.review", map[string]interface{}{"rating": 5.0}, map[string]interface{}{"subject": trusted.Hash, "author": "member-" + string(rune('a'+i))})
+		store.Put(review)
+	}
+
+	params := NewQuery(nil).Type("actor.producer").WhereEq("organic", true).WhereEq("region", "$region").OrderBy("name", "asc").Params()
+	saved, err := SaveQuery("organic producers near me", params, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := saved.State["parameters"].([]interface{}); len(p) != 1 || p[0] != "region" {
+		t.Errorf("parameters = %v", saved.State["parameters"])
+	}
+	got, err := RunSavedQuery(saved, map[string]interface{}{"region": "north"}, store)
+	if err != nil || len(got) != 2 || got[0].Hash != trusted.Hash {
+		t.Fatalf("north = %v, %v", got, err)
+	}
+	if _, err := RunSavedQuery(saved, nil, store); err == nil || !strings.Contains(err.Error(), "region") {
+		t.Errorf("err = %v", err)
+	}
+
+	tb := []TrustBlock{}
+	for _, b := range store.Blocks() {
+		tb = append(tb, TrustBlock{Block: b, AuthorHash: store.AuthorOf(b.Hash)})
+	}
+	threshold := ComputeTrust(trusted.Hash, tb, nil).Score
+	if threshold <= ComputeTrust(unknown.Hash, tb, nil).Score {
+		t.Fatal("reviews did not raise trust")
+	}
+	strict, _ := SaveQuery("trusted organic producers", params, threshold)
+	got, err = RunSavedQuery(strict, map[string]interface{}{"region": "north"}, store)
+	if err != nil || len(got) != 1 || got[0].Hash != trusted.Hash {
+		t.Errorf("trusted = %v, %v", got, err)
+	}
+}
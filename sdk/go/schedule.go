@@ -0,0 +1,405 @@
+package foodblock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReportType is a generated report. state.content holds the rendered text,
+// state.content_hash its SHA-256 and state.data the figures behind it; each
+// run of a job updates the job's previous report, so its history is one
+// update chain.
+const ReportType = "observe.report"
+
+// ReportContext is what a report job runs against: the blocks of the period
+// (Since, Now] and the job's previous report, if any.
+type ReportContext struct {
+	Store    BlockStore
+	Now      time.Time
+	Since    time.Time // the previous run, or the schedule's period before Now
+	Previous *Block
+}
+
+// ReportOutput is a job's rendered result.
+type ReportOutput struct {
+	Title string
+	Text  string
+	Data  map[string]interface{}
+	Refs  map[string]interface{}
+}
+
+// ReportRecipient is where a report is sent.
+type ReportRecipient struct {
+	Channel string // "email" or "sms"
+	To      string
+	Locale  string
+}
+
+// ReportJob is a report generated on a schedule.
+type ReportJob struct {
+	Name       string
+	Schedule   string // see ParseSchedule
+	Subject    string // the venue or actor reported on, set as refs.subject
+	Run        func(rc ReportContext) (ReportOutput, error)
+	Recipients []ReportRecipient
+}
+
+type scheduledJob struct {
+	job      ReportJob
+	schedule Schedule
+	next     time.Time
+	last     time.Time
+	previous string
+}
+
+// ReportScheduler runs report jobs when due, stores their observe.report
+// blocks and sends them to the jobs' recipients. Call RunDue periodically,
+// or Start to do so on a ticker.
+type ReportScheduler struct {
+	Store  BlockStore
+	Sender NotificationSender // nil stores reports without sending them
+	Now    func() time.Time
+
+	mu   sync.Mutex
+	jobs []*scheduledJob
+}
+
+// Register adds a job. A job that has run before resumes after its last
+// report in the store, so a run missed while stopped happens on the next
+// RunDue.
+func (s *ReportScheduler) Register(job ReportJob) error {
+	if job.Name == "" || job.Run == nil {
+		return errors.New("FoodBlock: a report job needs a name and a run function")
+	}
+	schedule, err := ParseSchedule(job.Schedule)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.job.Name == job.Name {
+			return fmt.Errorf("FoodBlock: report job %s is already registered", job.Name)
+		}
+	}
+	sj := &scheduledJob{job: job, schedule: schedule}
+	from := s.now()
+	if prev := latestReport(s.Store, job.Name); prev != nil {
+		sj.previous = prev.Hash
+		if end, ok := stateTime(prev.State, "period_end"); ok {
+			sj.last, from = end, end
+		}
+	}
+	sj.next = schedule.Next(from)
+	s.jobs = append(s.jobs, sj)
+	return nil
+}
+
+// RunDue runs every job whose time has come and returns the reports made.
+// A failing job does not stop the others; their errors are joined.
+func (s *ReportScheduler) RunDue(ctx context.Context) ([]Block, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	var reports []Block
+	var errs []string
+	for _, j := range s.jobs {
+		if j.next.IsZero() || now.Before(j.next) {
+			continue
+		}
+		report, err := s.runLocked(ctx, j, now)
+		if report.Hash != "" {
+			reports = append(reports, report)
+		}
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+		j.next = j.schedule.Next(now)
+	}
+	if len(errs) > 0 {
+		return reports, fmt.Errorf("FoodBlock: report jobs failed: %s", strings.Join(errs, "; "))
+	}
+	return reports, nil
+}
+
+// RunNow runs a registered job immediately, outside its schedule.
+func (s *ReportScheduler) RunNow(ctx context.Context, name string) (Block, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.job.Name == name {
+			return s.runLocked(ctx, j, s.now())
+		}
+	}
+	return Block{}, fmt.Errorf("FoodBlock: no report job %s", name)
+}
+
+// Start calls RunDue every tick until ctx is done. Errors go to onError,
+// which may be nil.
+func (s *ReportScheduler) Start(ctx context.Context, tick time.Duration, onError func(error)) {
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.RunDue(ctx); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+func (s *ReportScheduler) runLocked(ctx context.Context, j *scheduledJob, now time.Time) (Block, error) {
+	rc := ReportContext{Store: s.Store, Now: now, Since: j.last}
+	if rc.Since.IsZero() {
+		// A first run covers one period: the gap between two run times.
+		if next := j.schedule.Next(now); !next.IsZero() {
+			rc.Since = now.Add(-j.schedule.Next(next).Sub(next))
+		}
+	}
+	if j.previous != "" {
+		rc.Previous = s.Store.Resolve(j.previous)
+	}
+	out, err := j.job.Run(rc)
+	if err != nil {
+		return Block{}, fmt.Errorf("%s: %w", j.job.Name, err)
+	}
+	report := reportBlock(j, out, rc)
+	if err := s.Store.Put(report); err != nil {
+		return Block{}, fmt.Errorf("%s: %w", j.job.Name, err)
+	}
+	j.previous, j.last = report.Hash, now
+	if s.Sender == nil {
+		return report, nil
+	}
+	var errs []string
+	for _, r := range j.job.Recipients {
+		delivery, err := Deliver(ctx, s.Sender, reportNotification(report, out.Title, out.Text, r))
+		s.Store.Put(delivery)
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return report, fmt.Errorf("%s: sending: %s", j.job.Name, strings.Join(errs, "; "))
+	}
+	return report, nil
+}
+
+func (s *ReportScheduler) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+func reportBlock(j *scheduledJob, out ReportOutput, rc ReportContext) Block {
+	title := out.Title
+	if title == "" {
+		title = j.job.Name
+	}
+	state := map[string]interface{}{
+		"name":         j.job.Name,
+		"title":        title,
+		"period_start": rc.Since.UTC().Format(time.RFC3339),
+		"period_end":   rc.Now.UTC().Format(time.RFC3339),
+		"content_type": "text/plain",
+		"content":      out.Text,
+		"content_hash": Sha256Hex(out.Text),
+	}
+	if len(out.Data) > 0 {
+		// Round-trip so the block holds plain JSON values.
+		data, _ := json.Marshal(out.Data)
+		var plain map[string]interface{}
+		json.Unmarshal(data, &plain)
+		state["data"] = plain
+	}
+	refs := map[string]interface{}{}
+	for k, v := range out.Refs {
+		refs[k] = v
+	}
+	if j.job.Subject != "" {
+		refs["subject"] = j.job.Subject
+	}
+	if j.previous != "" {
+		return Update(j.previous, ReportType, state, refs)
+	}
+	return Create(ReportType, state, refs)
+}
+
+func reportNotification(report Block, title, text string, r ReportRecipient) Notification {
+	n := Notification{Channel: r.Channel, To: r.To, Locale: r.Locale, AlertHash: report.Hash}
+	if r.Channel == "sms" {
+		n.Body = title + ". Ref fb:" + report.Hash[:12]
+		return n
+	}
+	n.Subject = title
+	n.Body = strings.TrimSpace(text) + "\n\nReference: fb:" + report.Hash
+	return n
+}
+
+// latestReport finds the head of a job's report chain.
+func latestReport(store BlockStore, name string) *Block {
+	for _, b := range FilterBlocks(store.Blocks(), QueryParams{Type: ReportType, HeadsOnly: true, IncludeExpired: true}) {
+		if b.State["name"] == name {
+			b := b
+			return &b
+		}
+	}
+	return nil
+}
+
+// inPeriod reports whether a block arrived in (Since, Now]: when the store
+// records it, else by the block's own date.
+func (rc ReportContext) inPeriod(b Block) bool {
+	t, ok := time.Time{}, false
+	if stubs, isStub := rc.Store.(StubStore); isStub {
+		t, ok = stubs.StoredAt(b.Hash)
+	}
+	if !ok {
+		t, ok = BlockDate(b)
+	}
+	return ok && t.After(rc.Since) && !t.After(rc.Now)
+}
+
+// SummaryReportJob reports the blocks added in the period by type, with
+// the store's totals.
+func SummaryReportJob(name, schedule string) ReportJob {
+	return ReportJob{Name: name, Schedule: schedule, Run: func(rc ReportContext) (ReportOutput, error) {
+		added := map[string]int{}
+		total := 0
+		for _, b := range rc.Store.Blocks() {
+			if b.Type != ReportType && rc.inPeriod(b) {
+				added[b.Type]++
+				total++
+			}
+		}
+		types := make([]string, 0, len(added))
+		for t := range added {
+			types = append(types, t)
+		}
+		sort.Strings(types)
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "%d blocks added.\n", total)
+		for _, t := range types {
+			fmt.Fprintf(&sb, "- %s: %d\n", t, added[t])
+		}
+		stats := GraphStats(rc.Store, GraphStatsOptions{Now: func() time.Time { return rc.Now }})
+		sb.WriteString("\n" + stats.String())
+		return ReportOutput{
+			Title: "Summary",
+			Text:  sb.String(),
+			Data:  map[string]interface{}{"added": added, "total_added": total, "blocks": stats.Blocks},
+		}, nil
+	}}
+}
+
+// ExpiringCertsReportJob reports the certifications that have expired or
+// expire within the window, soonest first.
+func ExpiringCertsReportJob(name, schedule string, within time.Duration) ReportJob {
+	return ReportJob{Name: name, Schedule: schedule, Run: func(rc ReportContext) (ReportOutput, error) {
+		type cert struct {
+			hash, name string
+			until      time.Time
+		}
+		var certs []cert
+		for _, b := range FilterBlocks(rc.Store.Blocks(), QueryParams{Type: "observe.certification", HeadsOnly: true, IncludeExpired: true}) {
+			until, ok := stateTime(b.State, "valid_until")
+			if ok && until.Before(rc.Now.Add(within)) {
+				certs = append(certs, cert{b.Hash, blockLabel(b), until})
+			}
+		}
+		sort.Slice(certs, func(i, j int) bool { return certs[i].until.Before(certs[j].until) })
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "%d certifications expired or expiring within %s.\n", len(certs), within)
+		hashes := make([]string, len(certs))
+		for i, c := range certs {
+			verb := "expires"
+			if c.until.Before(rc.Now) {
+				verb = "expired"
+			}
+			fmt.Fprintf(&sb, "- %s %s %s (fb:%s)\n", c.name, verb, c.until.Format("2006-01-02"), c.hash)
+			hashes[i] = c.hash
+		}
+		return ReportOutput{Title: "Expiring certifications", Text: sb.String(), Data: map[string]interface{}{"certifications": hashes}}, nil
+	}}
+}
+
+// TrustChangesReportJob reports actors whose trust score moved by at least
+// minChange since the previous report.
+func TrustChangesReportJob(name, schedule string, policy map[string]interface{}, minChange float64) ReportJob {
+	return ReportJob{Name: name, Schedule: schedule, Run: func(rc ReportContext) (ReportOutput, error) {
+		all := rc.Store.Blocks()
+		authors, _ := rc.Store.(interface{ AuthorOf(string) string })
+		tb := make([]TrustBlock, len(all))
+		for i, b := range all {
+			tb[i] = TrustBlock{Block: b}
+			if authors != nil {
+				tb[i].AuthorHash = authors.AuthorOf(b.Hash)
+			}
+		}
+		var before map[string]interface{}
+		if rc.Previous != nil {
+			data, _ := rc.Previous.State["data"].(map[string]interface{})
+			before, _ = data["scores"].(map[string]interface{})
+		}
+		scores := map[string]interface{}{}
+		var lines []string
+		for _, actor := range FilterBlocks(all, QueryParams{Type: "actor.*", HeadsOnly: true, IncludeExpired: true}) {
+			score := ComputeTrust(actor.Hash, tb, policy).Score
+			scores[actor.Hash] = score
+			prev, seen := toFloat64(before[actor.Hash])
+			switch {
+			case !seen && before != nil:
+				lines = append(lines, fmt.Sprintf("- %s: new, %.2f", blockLabel(actor), score))
+			case seen && math.Abs(score-prev) >= minChange && score != prev:
+				lines = append(lines, fmt.Sprintf("- %s: %.2f -> %.2f", blockLabel(actor), prev, score))
+			}
+		}
+		sort.Strings(lines)
+		text := fmt.Sprintf("%d trust changes.\n", len(lines))
+		if rc.Previous == nil {
+			text = fmt.Sprintf("Baseline of %d trust scores.\n", len(scores))
+		}
+		return ReportOutput{Title: "Trust changes", Text: text + strings.Join(lines, "\n"), Data: map[string]interface{}{"scores": scores}}, nil
+	}}
+}
+
+// SalesReportJob totals the orders of the period per currency; seller, when
+// set, keeps only that seller's orders.
+func SalesReportJob(name, schedule, seller string) ReportJob {
+	return ReportJob{Name: name, Schedule: schedule, Subject: seller, Run: func(rc ReportContext) (ReportOutput, error) {
+		totals := map[string]float64{}
+		orders := 0
+		for _, b := range FilterBlocks(rc.Store.Blocks(), QueryParams{Type: "transfer.order", HeadsOnly: true, IncludeExpired: true}) {
+			if (seller != "" && b.Refs["seller"] != seller) || !rc.inPeriod(b) {
+				continue
+			}
+			orders++
+			if amount, ok := blockAmount(b); ok {
+				currency, _ := b.State["currency"].(string)
+				totals[currency] += amount
+			}
+		}
+		currencies := make([]string, 0, len(totals))
+		for c := range totals {
+			currencies = append(currencies, c)
+		}
+		sort.Strings(currencies)
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "%d orders.\n", orders)
+		for _, c := range currencies {
+			fmt.Fprintf(&sb, "- %s %.2f\n", strings.TrimSpace(c+" total"), totals[c])
+		}
+		return ReportOutput{Title: "Sales", Text: sb.String(), Data: map[string]interface{}{"orders": orders, "totals": totals}}, nil
+	}}
+}
@@ -0,0 +1,85 @@
+package foodblock
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReportScheduler(t *testing.T) {
+	store := NewMemoryStore()
+	cafe := Create("actor.venue", map[string]interface{}{"name": "Corner Cafe"}, nil)
+	store.Put(cafe)
+	for _, total := range []float64{12, 30.5} {
+		store.Put(Create("transfer.order", map[string]interface{}{"total": total, "currency": "GBP"}, map[string]interface{}{"seller": cafe.Hash}))
+	}
+	store.Put(Create("observe.certification", map[string]interface{}{"name": "Food Hygiene 5", "valid_until": time.Now().AddDate(0, 0, 10).Format("2006-01-02")}, nil))
+
+	now := time.Now()
+	sender := &recordingSender{}
+	s := &ReportScheduler{Store: store, Sender: sender, Now: func() time.Time { return now }}
+	weekly := SalesReportJob("cafe-sales", "@weekly", cafe.Hash)
+	weekly.Recipients = []ReportRecipient{{Channel: "email", To: "owner@example.com"}}
+	if err := s.Register(weekly); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Register(ExpiringCertsReportJob("certs", "@daily", 30*24*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Register(weekly); err == nil {
+		t.Error("expected duplicate job names to be refused")
+	}
+
+	if reports, _ := s.RunDue(context.Background()); len(reports) != 0 {
+		t.Fatalf("nothing should be due yet: %v", reports)
+	}
+	sunday, _ := ParseSchedule("@weekly")
+	now = sunday.Next(now)
+	reports, err := s.RunDue(context.Background())
+	if err != nil || len(reports) != 2 {
+		t.Fatalf("reports = %v, %v", reports, err)
+	}
+	sales := reports[0]
+	if sales.Type != ReportType || sales.Refs["subject"] != cafe.Hash || !strings.Contains(sales.State["content"].(string), "GBP total 42.50") {
+		t.Errorf("sales report = %v", sales.State)
+	}
+	if sales.State["content_hash"] != Sha256Hex(sales.State["content"].(string)) {
+		t.Error("content hash does not match the content")
+	}
+	if !strings.Contains(reports[1].State["content"].(string), "Food Hygiene 5 expires") {
+		t.Errorf("cert report = %v", reports[1].State["content"])
+	}
+	if len(sender.sent) != 1 || sender.sent[0].Subject != "Sales" || !strings.Contains(sender.sent[0].Body, "fb:"+sales.Hash) {
+		t.Errorf("sent = %+v", sender.sent)
+	}
+
+	// A restarted scheduler resumes the chain; the next report updates the last.
+	restarted := &ReportScheduler{Store: store, Now: func() time.Time { return now.AddDate(0, 0, 7) }}
+	restarted.Register(SalesReportJob("cafe-sales", "@weekly", cafe.Hash))
+	next, err := restarted.RunDue(context.Background())
+	if err != nil || len(next) != 1 || next[0].Refs["updates"] != sales.Hash || !strings.Contains(next[0].State["content"].(string), "0 orders") {
+		t.Fatalf("resumed = %v, %v", next, err)
+	}
+}
+
+func TestTrustChangesReportJob(t *testing.T) {
+	store := NewMemoryStore()
+	farm := Create("actor.producer", map[string]interface{}{"name": "Green Farm"}, nil)
+	store.Put(farm)
+	s := &ReportScheduler{Store: store}
+	s.Register(TrustChangesReportJob("trust", "@daily", nil, 0.01))
+	first, err := s.RunNow(context.Background(), "trust")
+	if err != nil || !strings.HasPrefix(first.State["content"].(string), "Baseline of 1") {
+		t.Fatalf("first = %v, %v", first.State, err)
+	}
+	store.Put(Create("observe.certification", map[string]interface{}{"name": "Organic"}, map[string]interface{}{"subject": farm.Hash, "authority": farm.Hash}))
+	store.Put(Create("actor.producer", map[string]interface{}{"name": "Hill Farm"}, nil))
+	second, _ := s.RunNow(context.Background(), "trust")
+	if content := second.State["content"].(string); !strings.Contains(content, "Hill Farm: new") {
+		t.Errorf("second = %s", content)
+	}
+	if _, err := s.RunNow(context.Background(), "missing"); err == nil {
+		t.Error("expected an error for an unknown job")
+	}
+}
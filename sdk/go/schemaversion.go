@@ -0,0 +1,312 @@
+package foodblock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SchemaMigration declares how a block of an earlier schema version becomes
+// a block of the version declaring it. Steps apply in the order of the
+// fields below.
+type SchemaMigration struct {
+	From      string                 `json:"from"`                // version migrated from
+	Rename    map[string]string      `json:"rename,omitempty"`    // old field -> new field
+	Transform map[string]string      `json:"transform,omitempty"` // field -> SchemaTransforms name
+	Defaults  map[string]interface{} `json:"defaults,omitempty"`  // set when absent
+	Remove    []string               `json:"remove,omitempty"`
+}
+
+// SchemaTransforms are the field conversions migrations may name. Register
+// more by adding to the map.
+var SchemaTransforms = map[string]func(interface{}) (interface{}, error){
+	"string": func(v interface{}) (interface{}, error) {
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+		return fmt.Sprint(v), nil
+	},
+	"number": func(v interface{}) (interface{}, error) {
+		if n, ok := numericValue(v); ok {
+			return n, nil
+		}
+		if s, ok := v.(string); ok {
+			if n, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil {
+				return n, nil
+			}
+		}
+		return nil, fmt.Errorf("FoodBlock: %v is not a number", v)
+	},
+	"array": func(v interface{}) (interface{}, error) {
+		if arr, ok := v.([]interface{}); ok {
+			return arr, nil
+		}
+		return []interface{}{v}, nil
+	},
+}
+
+// SchemaRef names a schema version as used in state.$schema.
+func SchemaRef(targetType, version string) string {
+	return "foodblock:" + targetType + "@" + version
+}
+
+// ParseSchemaRef splits a $schema reference into type and version.
+func ParseSchemaRef(ref string) (targetType, version string, ok bool) {
+	rest := strings.TrimPrefix(ref, "foodblock:")
+	i := strings.LastIndex(rest, "@")
+	if rest == ref || i <= 0 || i == len(rest)-1 {
+		return "", "", false
+	}
+	return rest[:i], rest[i+1:], true
+}
+
+// CreateSchema creates an observe.schema block for s. A new version of an
+// existing schema passes the previous version's hash to join its chain.
+func CreateSchema(s Schema, previousHash string) (Block, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return Block{}, err
+	}
+	var state map[string]interface{}
+	json.Unmarshal(data, &state)
+	if previousHash != "" {
+		return Update(previousHash, "observe.schema", state, nil), nil
+	}
+	return Create("observe.schema", state, nil), nil
+}
+
+// SchemaFromBlock reads a schema from an observe.schema block.
+func SchemaFromBlock(b Block) (Schema, error) {
+	if b.Type != "observe.schema" {
+		return Schema{}, fmt.Errorf("FoodBlock: %s is not a schema block", b.Type)
+	}
+	data, err := json.Marshal(b.State)
+	if err != nil {
+		return Schema{}, err
+	}
+	var s Schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return Schema{}, fmt.Errorf("FoodBlock: schema %s: %w", b.Hash, err)
+	}
+	if s.TargetType == "" || s.Version == "" {
+		return Schema{}, fmt.Errorf("FoodBlock: schema %s needs target_type and version", b.Hash)
+	}
+	return s, nil
+}
+
+// SchemaVersions lists the known versions of the schemas for a type, oldest
+// first: CoreSchemas and the observe.schema blocks in store, which may be
+// nil. A store block replaces a core schema of the same version.
+func SchemaVersions(targetType string, store BlockStore) []Schema {
+	byVersion := map[string]Schema{}
+	for _, s := range CoreSchemas {
+		if s.TargetType == targetType {
+			byVersion[s.Version] = s
+		}
+	}
+	if store != nil {
+		for _, b := range store.Blocks() {
+			if b.Type != "observe.schema" || IsStub(b) || b.State["target_type"] != targetType {
+				continue
+			}
+			if s, err := SchemaFromBlock(b); err == nil {
+				byVersion[s.Version] = s
+			}
+		}
+	}
+	out := make([]Schema, 0, len(byVersion))
+	for _, s := range byVersion {
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return compareVersions(out[i].Version, out[j].Version) < 0 })
+	return out
+}
+
+// LookupSchema finds the schema a $schema reference names among CoreSchemas
+// and the schema blocks in store, which may be nil.
+func LookupSchema(ref string, store BlockStore) (Schema, bool) {
+	typ, version, ok := ParseSchemaRef(ref)
+	if !ok {
+		return Schema{}, false
+	}
+	for _, s := range SchemaVersions(typ, store) {
+		if s.Version == version {
+			return s, true
+		}
+	}
+	return Schema{}, false
+}
+
+// ResolveSchema picks the schema a block of targetType should use: the
+// newest non-deprecated version compatible with want (same major version,
+// no older than want), or the newest non-deprecated version when want is
+// empty. A deprecated version is returned only when nothing else fits. It
+// returns the $schema reference along with the schema.
+func ResolveSchema(targetType, want string, store BlockStore) (string, Schema, error) {
+	var best *Schema
+	for _, s := range SchemaVersions(targetType, store) {
+		s := s
+		if want != "" && (versionPart(s.Version, 0) != versionPart(want, 0) || compareVersions(s.Version, want) < 0) {
+			continue
+		}
+		if best == nil || best.Deprecated != "" || s.Deprecated == "" {
+			best = &s
+		}
+	}
+	if best == nil {
+		if want != "" {
+			return "", Schema{}, fmt.Errorf("FoodBlock: no schema for %s compatible with %s", targetType, want)
+		}
+		return "", Schema{}, fmt.Errorf("FoodBlock: no schema for %s", targetType)
+	}
+	return SchemaRef(targetType, best.Version), *best, nil
+}
+
+// MigrateToSchema moves a block to the target schema reference, applying
+// the declared migrations from the block's $schema version through each
+// intermediate version, and returns the update block. The migrated state is
+// validated against the target schema. store supplies schema blocks beyond
+// CoreSchemas and may be nil.
+func MigrateToSchema(block Block, targetSchema string, store BlockStore) (Block, error) {
+	targetType, targetVersion, ok := ParseSchemaRef(targetSchema)
+	if !ok {
+		return Block{}, fmt.Errorf("FoodBlock: invalid schema reference %s", targetSchema)
+	}
+	if targetType != block.Type {
+		return Block{}, fmt.Errorf("FoodBlock: schema %s is for %s, block is %s", targetSchema, targetType, block.Type)
+	}
+	current, _ := block.State["$schema"].(string)
+	_, from, ok := ParseSchemaRef(current)
+	if !ok {
+		return Block{}, errors.New("FoodBlock: block declares no $schema to migrate from")
+	}
+	if from == targetVersion {
+		return Block{}, fmt.Errorf("FoodBlock: block already uses %s", targetSchema)
+	}
+
+	versions := SchemaVersions(block.Type, store)
+	path, err := migrationPath(versions, from, targetVersion)
+	if err != nil {
+		return Block{}, err
+	}
+	state := make(map[string]interface{}, len(block.State))
+	for k, v := range block.State {
+		state[k] = v
+	}
+	for _, m := range path {
+		if err := applyMigration(state, m); err != nil {
+			return Block{}, err
+		}
+	}
+	state["$schema"] = targetSchema
+
+	target, _ := LookupSchema(targetSchema, store)
+	refs := make(map[string]interface{}, len(block.Refs))
+	for k, v := range block.Refs {
+		if k != "updates" {
+			refs[k] = v
+		}
+	}
+	migrated := Update(block.Hash, block.Type, state, refs)
+	if errs := Validate(migrated, &target); len(errs) > 0 {
+		return Block{}, fmt.Errorf("FoodBlock: migrated block does not satisfy %s: %s", targetSchema, strings.Join(errs, "; "))
+	}
+	return migrated, nil
+}
+
+// migrationPath finds the shortest chain of declared migrations from one
+// version to another.
+func migrationPath(versions []Schema, from, to string) ([]SchemaMigration, error) {
+	type step struct {
+		version string
+		path    []SchemaMigration
+	}
+	seen := map[string]bool{from: true}
+	queue := []step{{version: from}}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		if cur.version == to {
+			return cur.path, nil
+		}
+		for _, s := range versions {
+			for _, m := range s.Migrations {
+				if m.From == cur.version && !seen[s.Version] {
+					seen[s.Version] = true
+					path := append(append([]SchemaMigration(nil), cur.path...), m)
+					queue = append(queue, step{s.Version, path})
+				}
+			}
+		}
+	}
+	return nil, fmt.Errorf("FoodBlock: no migration from version %s to %s", from, to)
+}
+
+func applyMigration(state map[string]interface{}, m SchemaMigration) error {
+	for _, old := range sortedKeys(m.Rename) {
+		if v, ok := state[old]; ok {
+			delete(state, old)
+			state[m.Rename[old]] = v
+		}
+	}
+	for _, field := range sortedKeys(m.Transform) {
+		v, ok := state[field]
+		if !ok {
+			continue
+		}
+		fn, known := SchemaTransforms[m.Transform[field]]
+		if !known {
+			return fmt.Errorf("FoodBlock: unknown schema transform %s", m.Transform[field])
+		}
+		out, err := fn(v)
+		if err != nil {
+			return fmt.Errorf("FoodBlock: transforming state.%s: %w", field, err)
+		}
+		state[field] = out
+	}
+	for field, v := range m.Defaults {
+		if _, ok := state[field]; !ok {
+			state[field] = v
+		}
+	}
+	for _, field := range m.Remove {
+		delete(state, field)
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// compareVersions orders dotted versions numerically: 1.10 follows 1.9.
+func compareVersions(a, b string) int {
+	pa, pb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		x, y := versionPart(a, i), versionPart(b, i)
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func versionPart(v string, i int) int {
+	parts := strings.Split(v, ".")
+	if i >= len(parts) {
+		return 0
+	}
+	n, _ := strconv.Atoi(parts[i])
+	return n
+}
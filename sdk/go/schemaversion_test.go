@@ -0,0 +1,105 @@
+package foodblock
+
+import (
+	"strings"
+	"testing"
+)
+
+// productSchemas publishes substance.product 1.1, renaming price to
+// unit_price, and 2.0, turning allergens into a list, deprecating 1.1.
+func productSchemas(t *testing.T, store *MemoryStore) {
+	t.Helper()
+	v11 := CoreSchemas["foodblock:substance.product@1.0"]
+	v11.Version = "1.1"
+	v11.Fields = map[string]SchemaField{
+		"name":       {Type: "string", Required: true},
+		"unit_price": {Type: "number", Constraint: Constraint{Min: Bound(0)}},
+		"unit":       {Type: "string"},
+	}
+	v11.Migrations = []SchemaMigration{{From: "1.0", Rename: map[string]string{"price": "unit_price"}, Defaults: map[string]interface{}{"unit": "each"}}}
+	b11, err := CreateSchema(v11, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v2 := v11
+	v2.Version = "2.0"
+	v2.Fields = map[string]SchemaField{
+		"name":       {Type: "string", Required: true},
+		"unit_price": {Type: "number"},
+		"allergens":  {Type: "array"},
+	}
+	v2.Migrations = []SchemaMigration{{From: "1.1", Transform: map[string]string{"allergens": "array", "unit_price": "number"}, Remove: []string{"unit"}}}
+	b2, _ := CreateSchema(v2, b11.Hash)
+
+	v11.Deprecated = "use 2.0"
+	b11d, _ := CreateSchema(v11, b11.Hash)
+	store.PutAll([]Block{b11, b2, b11d})
+}
+
+func TestResolveSchema(t *testing.T) {
+	store := NewMemoryStore()
+	if ref, _, err := ResolveSchema("substance.product", "", nil); err != nil || ref != "foodblock:substance.product@1.0" {
+		t.Fatalf("core only: %s, %v", ref, err)
+	}
+	productSchemas(t, store)
+
+	// 1.1 is deprecated, so a 1.x request falls back to 1.0; any version
+	// resolves to 2.0.
+	if ref, _, _ := ResolveSchema("substance.product", "1.0", store); ref != "foodblock:substance.product@1.0" {
+		t.Errorf("1.x resolved to %s", ref)
+	}
+	if ref, _, _ := ResolveSchema("substance.product", "1.1", store); ref != "foodblock:substance.product@1.1" {
+		t.Errorf("1.1 resolved to %s", ref)
+	}
+	if ref, s, _ := ResolveSchema("substance.product", "", store); ref != "foodblock:substance.product@2.0" || len(s.Migrations) != 1 {
+		t.Errorf("latest resolved to %s", ref)
+	}
+	if _, _, err := ResolveSchema("substance.product", "3.0", store); err == nil {
+		t.Error("expected no schema compatible with 3.0")
+	}
+	if compareVersions("1.10", "1.9") <= 0 {
+		t.Error("versions compare as strings")
+	}
+}
+
+func TestMigrateToSchema(t *testing.T) {
+	store := NewMemoryStore()
+	productSchemas(t, store)
+	seller := Create("actor.producer", map[string]interface{}{"name": "Bakery"}, nil)
+	v1 := Create("substance.product", map[string]interface{}{
+		"$schema": "foodblock:substance.product@1.0", "name": "Rye", "price": 4.5, "allergens": "gluten",
+	}, map[string]interface{}{"seller": seller.Hash})
+
+	v2, err := MigrateToSchema(v1, "foodblock:substance.product@2.0", store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v2.Refs["updates"] != v1.Hash || v2.Refs["seller"] != seller.Hash {
+		t.Errorf("refs = %v", v2.Refs)
+	}
+	allergens, _ := v2.State["allergens"].([]interface{})
+	if v2.State["unit_price"] != 4.5 || v2.State["price"] != nil || v2.State["unit"] != nil || len(allergens) != 1 || v2.State["$schema"] != "foodblock:substance.product@2.0" {
+		t.Errorf("state = %v", v2.State)
+	}
+
+	// The deprecated intermediate version draws a pipeline warning.
+	mid, _ := MigrateToSchema(v1, "foodblock:substance.product@1.1", store)
+	store.PutAll([]Block{seller, v1})
+	report := ValidatePipeline([]SignedBlock{{FoodBlock: mid}}, PipelineOptions{Store: store})
+	if !report.OK || report.Warnings == 0 || !strings.Contains(report.Blocks[0].Issues[0].Message, "deprecated") {
+		t.Errorf("report = %+v", report.Blocks[0])
+	}
+
+	// Migrations whose result breaks the target's constraints are refused.
+	negative := Create("substance.product", map[string]interface{}{"$schema": "foodblock:substance.product@1.0", "name": "Rye", "price": -1.0}, nil)
+	if _, err := MigrateToSchema(negative, "foodblock:substance.product@1.1", store); err == nil || !strings.Contains(err.Error(), "at least 0") {
+		t.Errorf("err = %v", err)
+	}
+	if _, err := MigrateToSchema(v1, "foodblock:substance.product@1.0", store); err == nil {
+		t.Error("expected an error migrating to the current version")
+	}
+	if _, err := MigrateToSchema(v2, "foodblock:substance.product@1.0", store); err == nil {
+		t.Error("expected no downgrade path")
+	}
+}
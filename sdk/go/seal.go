@@ -0,0 +1,187 @@
+package foodblock
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Chain-of-custody block types. Seals are applied and inspected with
+// observe.seal (state.event "applied" or "inspected"), broken with
+// observe.seal_break, and custody passes between parties with
+// transfer.handover. All reference the shipment via refs.shipment.
+const (
+	SealType      = "observe.seal"
+	SealBreakType = "observe.seal_break"
+	HandoverType  = "transfer.handover"
+)
+
+// ApplySeal records a seal being applied to a shipment.
+func ApplySeal(shipment, sealID, by string, at time.Time) Block {
+	return sealEvent(SealType, shipment, sealID, by, at, map[string]interface{}{"event": "applied"})
+}
+
+// InspectSeal records a seal check; intact is false when it shows tampering.
+func InspectSeal(shipment, sealID, by string, at time.Time, intact bool) Block {
+	return sealEvent(SealType, shipment, sealID, by, at, map[string]interface{}{"event": "inspected", "intact": intact})
+}
+
+// BreakSeal records a seal being opened or cut.
+func BreakSeal(shipment, sealID, by string, at time.Time, reason string) Block {
+	extra := map[string]interface{}{}
+	if reason != "" {
+		extra["reason"] = reason
+	}
+	return sealEvent(SealBreakType, shipment, sealID, by, at, extra)
+}
+
+// Handover records custody of a shipment passing from one party to another.
+func Handover(shipment, from, to string, at time.Time) Block {
+	return Create(HandoverType, map[string]interface{}{
+		"instance_id": fmt.Sprintf("handover-%s-%d", shipment, at.Unix()),
+		"at":          at.UTC().Format(time.RFC3339),
+	}, map[string]interface{}{"shipment": shipment, "from": from, "to": to})
+}
+
+func sealEvent(typ, shipment, sealID, by string, at time.Time, extra map[string]interface{}) Block {
+	state := map[string]interface{}{
+		"seal_id": sealID,
+		"at":      at.UTC().Format(time.RFC3339),
+	}
+	for k, v := range extra {
+		state[k] = v
+	}
+	return Create(typ, state, map[string]interface{}{"shipment": shipment, "actor": by})
+}
+
+// CustodyGap is a break in the chain of custody.
+type CustodyGap struct {
+	Kind   string `json:"kind"` // unsealed_handover, uninspected_handover, seal_mismatch, seal_tampered, inspection_without_seal, no_seal
+	At     string `json:"at,omitempty"`
+	Block  string `json:"block,omitempty"`
+	Detail string `json:"detail"`
+}
+
+// CustodyReport is the result of VerifyCustody.
+type CustodyReport struct {
+	Delivery  string       `json:"delivery"`
+	Seals     []string     `json:"seals"`
+	Handovers int          `json:"handovers"`
+	Events    []Block      `json:"events"`
+	Gaps      []CustodyGap `json:"gaps"`
+	Intact    bool         `json:"intact"`
+}
+
+// VerifyCustody checks the chain of custody of a delivery. It gathers the
+// seal and handover events referencing any version of the delivery, orders
+// them by time, and requires that every handover happens under an applied,
+// unbroken seal and is followed by the receiver inspecting that seal.
+func VerifyCustody(deliveryHash string, store BlockStore) (CustodyReport, error) {
+	if store.Resolve(deliveryHash) == nil {
+		return CustodyReport{}, fmt.Errorf("FoodBlock: delivery not found: %s", deliveryHash)
+	}
+	report := CustodyReport{Delivery: deliveryHash, Seals: []string{}, Gaps: []CustodyGap{}}
+
+	versions := map[string]bool{}
+	for _, b := range Chain(deliveryHash, store.Resolve, 0) {
+		versions[b.Hash] = true
+	}
+	type event struct {
+		block Block
+		at    time.Time
+		rank  int
+	}
+	var events []event
+	seen := map[string]bool{}
+	for v := range versions {
+		for _, b := range store.ResolveForward(v) {
+			if seen[b.Hash] || b.Refs["shipment"] != v {
+				continue
+			}
+			rank := -1
+			switch {
+			case b.Type == SealType && b.State["event"] == "applied":
+				rank = 0
+			case b.Type == HandoverType:
+				rank = 1
+			case b.Type == SealType:
+				rank = 2
+			case b.Type == SealBreakType:
+				rank = 3
+			}
+			if rank < 0 {
+				continue
+			}
+			seen[b.Hash] = true
+			stamp, _ := b.State["at"].(string)
+			at, _ := time.Parse(time.RFC3339, stamp)
+			events = append(events, event{b, at, rank})
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].at.Equal(events[j].at) {
+			return events[i].at.Before(events[j].at)
+		}
+		if events[i].rank != events[j].rank {
+			return events[i].rank < events[j].rank
+		}
+		return events[i].block.Hash < events[j].block.Hash
+	})
+
+	gap := func(kind string, b Block, format string, args ...interface{}) {
+		at, _ := b.State["at"].(string)
+		report.Gaps = append(report.Gaps, CustodyGap{Kind: kind, At: at, Block: b.Hash, Detail: fmt.Sprintf(format, args...)})
+	}
+	sealed := ""
+	var awaiting *Block // handover whose receiver has not inspected the seal yet
+	for _, e := range events {
+		b := e.block
+		report.Events = append(report.Events, b)
+		id, _ := b.State["seal_id"].(string)
+		actor, _ := b.Refs["actor"].(string)
+		switch e.rank {
+		case 0:
+			sealed = id
+			if !containsStr(report.Seals, id) {
+				report.Seals = append(report.Seals, id)
+			}
+		case 1:
+			report.Handovers++
+			if awaiting != nil {
+				gap("uninspected_handover", *awaiting, "%v never inspected the seal after taking custody", awaiting.Refs["to"])
+			}
+			if sealed == "" {
+				gap("unsealed_handover", b, "custody passed from %v to %v without an intact seal", b.Refs["from"], b.Refs["to"])
+			}
+			handover := b
+			awaiting = &handover
+		case 2:
+			intact, _ := b.State["intact"].(bool)
+			switch {
+			case sealed == "":
+				gap("inspection_without_seal", b, "seal %s inspected but no seal is applied", id)
+			case id != sealed:
+				gap("seal_mismatch", b, "inspected seal %s but %s was applied", id, sealed)
+			case !intact:
+				gap("seal_tampered", b, "seal %s found not intact", id)
+				sealed = ""
+			}
+			if awaiting != nil && awaiting.Refs["to"] == actor {
+				awaiting = nil
+			}
+		case 3:
+			if id != sealed {
+				gap("seal_mismatch", b, "broke seal %s but %s was applied", id, sealed)
+			}
+			sealed = ""
+		}
+	}
+	if awaiting != nil {
+		gap("uninspected_handover", *awaiting, "%v never inspected the seal after taking custody", awaiting.Refs["to"])
+	}
+	if len(report.Seals) == 0 {
+		report.Gaps = append(report.Gaps, CustodyGap{Kind: "no_seal", Detail: "no seal was applied to the shipment"})
+	}
+	report.Intact = len(report.Gaps) == 0
+	return report, nil
+}
@@ -0,0 +1,64 @@
+package foodblock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVerifyCustody(t *testing.T) {
+	t0 := time.Date(2026, 6, 1, 6, 0, 0, 0, time.UTC)
+	at := func(h int) time.Time { return t0.Add(time.Duration(h) * time.Hour) }
+	shipment := Create("transfer.delivery", map[string]interface{}{"instance_id": "ship-1", "status": "in_transit"},
+		map[string]interface{}{"seller": "farm", "buyer": "shop"})
+	delivered := Update(shipment.Hash, "transfer.delivery", map[string]interface{}{"instance_id": "ship-1", "status": "delivered"},
+		map[string]interface{}{"seller": "farm", "buyer": "shop"})
+
+	good := []Block{
+		shipment, delivered,
+		ApplySeal(shipment.Hash, "S-100", "farm", at(0)),
+		Handover(shipment.Hash, "farm", "haulier", at(1)),
+		InspectSeal(shipment.Hash, "S-100", "haulier", at(1), true),
+		Handover(shipment.Hash, "haulier", "shop", at(5)),
+		InspectSeal(shipment.Hash, "S-100", "shop", at(5), true),
+		BreakSeal(shipment.Hash, "S-100", "shop", at(6), "unloading"),
+	}
+	store := NewMemoryStore()
+	store.PutAll(good)
+	report, err := VerifyCustody(delivered.Hash, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Intact || report.Handovers != 2 || len(report.Events) != 6 || len(report.Seals) != 1 {
+		t.Fatalf("expected intact custody, got %+v", report)
+	}
+
+	// The haulier breaks the seal mid-route and hands over without resealing;
+	// the shop then inspects a seal that is no longer applied.
+	bad := NewMemoryStore()
+	bad.PutAll([]Block{
+		shipment, delivered,
+		ApplySeal(shipment.Hash, "S-200", "farm", at(0)),
+		Handover(shipment.Hash, "farm", "haulier", at(1)),
+		BreakSeal(shipment.Hash, "S-200", "haulier", at(3), "spot check"),
+		Handover(shipment.Hash, "haulier", "shop", at(5)),
+		InspectSeal(shipment.Hash, "S-200", "shop", at(5), true),
+	})
+	report, _ = VerifyCustody(delivered.Hash, bad)
+	kinds := map[string]int{}
+	for _, g := range report.Gaps {
+		kinds[g.Kind]++
+	}
+	if report.Intact || kinds["uninspected_handover"] != 1 || kinds["unsealed_handover"] != 1 || kinds["inspection_without_seal"] != 1 {
+		t.Errorf("unexpected gaps %+v", report.Gaps)
+	}
+
+	empty := NewMemoryStore()
+	empty.Put(shipment)
+	report, _ = VerifyCustody(shipment.Hash, empty)
+	if report.Intact || report.Gaps[0].Kind != "no_seal" {
+		t.Errorf("expected a no_seal gap, got %+v", report.Gaps)
+	}
+	if _, err := VerifyCustody("missing", empty); err == nil {
+		t.Error("expected not found error")
+	}
+}
@@ -1,6 +1,21 @@
 package foodblock
 
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SDKVersion is the release of the FoodBlock SDKs, in step with the JS and
+// Python packages. Seed blocks and seed manifests record it.
+const SDKVersion = "0.5.0"
+
 // SeedVocabularies generates all vocabulary blocks from built-in definitions.
+// Transition guards are seeded in state.guards; initial and terminal states
+// and SLAs stay in the Go definitions.
+// Each seed records its content revision and the protocol and SDK versions
+// that produced it (see seedBlock). Seeds shared with the JS SDK hash the
+// same; test/seed-hashes.json holds the JS hashes.
 func SeedVocabularies() []Block {
 	var blocks []Block
 	for _, def := range Vocabularies {
@@ -44,6 +59,9 @@ func SeedVocabularies() []Block {
 			if field.Compound {
 				entry["compound"] = true
 			}
+			for k, v := range field.Constraint.toMap() {
+				entry[k] = v
+			}
 			fieldsMap[name] = entry
 		}
 
@@ -69,8 +87,11 @@ func SeedVocabularies() []Block {
 			}
 			state["transitions"] = transMap
 		}
+		if len(def.Guards) > 0 {
+			state["guards"] = guardsState(def.Guards)
+		}
 
-		blocks = append(blocks, Create("observe.vocabulary", state, nil))
+		blocks = append(blocks, seedBlock("observe.vocabulary", state))
 	}
 	return blocks
 }
@@ -99,6 +120,9 @@ func SeedTemplates() []Block {
 				}
 				step["required"] = req
 			}
+			if s.Optional {
+				step["optional"] = true
+			}
 			if len(s.DefaultState) > 0 {
 				step["default_state"] = s.DefaultState
 			}
@@ -111,11 +135,35 @@ func SeedTemplates() []Block {
 			"steps":       stepsSlice,
 		}
 
-		blocks = append(blocks, Create("observe.template", state, nil))
+		blocks = append(blocks, seedBlock("observe.template", state))
 	}
 	return blocks
 }
 
+// seedBlock creates a seed from a definition's state, adding its content
+// revision (the hash of the definition alone, so it survives SDK releases
+// that leave the definition unchanged) and the protocol and SDK versions.
+func seedBlock(typ string, state map[string]interface{}) Block {
+	state["revision"] = Create(typ, state, nil).Hash
+	state["protocol_version"] = ProtocolVersion
+	state["sdk_version"] = SDKVersion
+	return Create(typ, state, nil)
+}
+
+// seedRevision recomputes a seed's content revision from its state, leaving
+// out the fields seedBlock adds.
+func seedRevision(b Block) string {
+	content := make(map[string]interface{}, len(b.State))
+	for k, v := range b.State {
+		switch k {
+		case "revision", "protocol_version", "sdk_version":
+		default:
+			content[k] = v
+		}
+	}
+	return Create(b.Type, content, nil).Hash
+}
+
 // SeedAll generates all seed blocks (vocabularies + templates).
 func SeedAll() []Block {
 	vocabs := SeedVocabularies()
@@ -125,3 +173,142 @@ func SeedAll() []Block {
 	all = append(all, templates...)
 	return all
 }
+
+// seedKey names a seed by what it defines: "vocabulary:<domain>" or
+// "template:<name>".
+func seedKey(b Block) string {
+	switch b.Type {
+	case "observe.vocabulary":
+		return "vocabulary:" + firstString(b.State, "domain")
+	case "observe.template":
+		return "template:" + firstString(b.State, "name")
+	}
+	return ""
+}
+
+// SeedManifest creates the manifest of a seed set: the hash of every
+// seed by key, their Merkle root as the digest, and refs to the seed blocks.
+// The manifest of SeedAll identifies this SDK's seeds.
+func SeedManifest(seeds []Block) Block {
+	revisions := map[string]interface{}{}
+	hashes := make([]string, 0, len(seeds))
+	for _, b := range seeds {
+		if key := seedKey(b); key != "" {
+			revisions[key] = b.Hash
+			hashes = append(hashes, b.Hash)
+		}
+	}
+	sort.Strings(hashes)
+	refs := make([]interface{}, len(hashes))
+	for i, h := range hashes {
+		refs[i] = h
+	}
+	digest := Merkleize(revisions).Root
+	return Create("observe.seed_manifest", map[string]interface{}{
+		"instance_id":      "seed-manifest-" + digest[:16],
+		"protocol_version": ProtocolVersion,
+		"sdk_version":      SDKVersion,
+		"count":            len(revisions),
+		"digest":           digest,
+		"revisions":        revisions,
+	}, map[string]interface{}{"seeds": refs})
+}
+
+// SeedDrift is a deployed seed whose content differs from this SDK's.
+type SeedDrift struct {
+	Key      string `json:"key"`
+	Hash     string `json:"hash"`     // latest deployed version
+	Expected string `json:"expected"` // this SDK's seed hash
+}
+
+// SeedReport compares a store's seeds with this SDK's.
+type SeedReport struct {
+	SDKVersion string      `json:"sdk_version"`
+	Digest     string      `json:"digest"` // of this SDK's seeds
+	Matched    int         `json:"matched"`
+	Missing    []string    `json:"missing"`
+	Drifted    []SeedDrift `json:"drifted"`
+	Extra      []string    `json:"extra"`    // deployed keys this SDK does not define
+	Manifest   string      `json:"manifest"` // hash of the matching deployed manifest
+	OK         bool        `json:"ok"`
+}
+
+// VerifySeeds checks the vocabulary and template blocks deployed in a store
+// against this SDK's seeds. A definition counts as deployed when any of its
+// versions has the expected content revision, whichever SDK release seeded
+// it; otherwise the head of its update chain is reported as drifted. The store's seed manifest, if any, is matched by
+// digest. OK means every seed is deployed unchanged.
+func VerifySeeds(store BlockStore) SeedReport {
+	seeds := SeedAll()
+	expected := SeedManifest(seeds)
+	revisions, _ := expected.State["revisions"].(map[string]interface{})
+	content := make(map[string]string, len(seeds))
+	for _, b := range seeds {
+		content[seedKey(b)], _ = b.State["revision"].(string)
+	}
+	r := SeedReport{
+		SDKVersion: SDKVersion,
+		Digest:     fmt.Sprint(expected.State["digest"]),
+		Missing:    []string{},
+		Drifted:    []SeedDrift{},
+		Extra:      []string{},
+	}
+
+	deployed := map[string][]Block{}
+	var manifests []Block
+	for _, b := range store.Blocks() {
+		if b.Type == "observe.seed_manifest" {
+			manifests = append(manifests, b)
+		} else if key := seedKey(b); key != "" && !IsStub(b) {
+			deployed[key] = append(deployed[key], b)
+		}
+	}
+	keys := make([]string, 0, len(revisions))
+	for k := range revisions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		versions := deployed[key]
+		if len(versions) == 0 {
+			r.Missing = append(r.Missing, key)
+			continue
+		}
+		want := revisions[key].(string)
+		matched := false
+		for _, b := range versions {
+			if b.Hash == want || seedRevision(b) == content[key] {
+				matched = true
+				break
+			}
+		}
+		if matched {
+			r.Matched++
+			continue
+		}
+		r.Drifted = append(r.Drifted, SeedDrift{Key: key, Hash: chainHead(store, versions[0].Hash), Expected: want})
+	}
+	for key := range deployed {
+		if _, ok := revisions[key]; !ok && !strings.HasSuffix(key, ":") {
+			r.Extra = append(r.Extra, key)
+		}
+	}
+	sort.Strings(r.Extra)
+
+	for _, m := range manifests {
+		if m.State["digest"] == expected.State["digest"] {
+			r.Manifest = m.Hash
+		}
+	}
+	r.OK = len(r.Missing) == 0 && len(r.Drifted) == 0
+	return r
+}
+
+// chainHead returns the latest version of the chain containing hash, from the
+// store's head index when it keeps one.
+func chainHead(store BlockStore, hash string) string {
+	if idx, ok := store.(HeadIndex); ok {
+		return idx.HeadOf(hash)
+	}
+	return Head(hash, store.ResolveForward, 0)
+}
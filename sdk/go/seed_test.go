@@ -72,6 +72,16 @@ func TestSeedVocabulariesWorkflowTransitions(t *testing.T) {
 			if transitions["draft"] == nil {
 				t.Error("workflow transitions should include draft")
 			}
+			// Go-only definitions stay out of the hashed state.
+			for _, k := range []string{"initial", "terminals", "slas"} {
+				if _, ok := v.State[k]; ok {
+					t.Errorf("workflow seed should not carry %s", k)
+				}
+			}
+			guards, err := GuardsFromBlock(v)
+			if err != nil || len(guards["shipped->delivered"]) != 1 || guards["shipped->delivered"][0].FromRef != "carrier" {
+				t.Errorf("workflow seed guards = %v, %v", guards, err)
+			}
 			return
 		}
 	}
@@ -196,3 +206,84 @@ func TestSeedAllUniqueHashes(t *testing.T) {
 		seen[b.Hash] = true
 	}
 }
+
+func TestSeedManifest(t *testing.T) {
+	for _, b := range SeedAll() {
+		if b.State["sdk_version"] != SDKVersion || b.State["protocol_version"] != ProtocolVersion {
+			t.Fatalf("%s is not stamped with the SDK and protocol versions: %v", seedKey(b), b.State)
+		}
+		if rev := b.State["revision"]; rev == "" || rev != seedRevision(b) {
+			t.Fatalf("%s revision %v does not match its content", seedKey(b), rev)
+		}
+	}
+	a, b := SeedManifest(SeedAll()), SeedManifest(SeedAll())
+	if a.Hash != b.Hash || a.State["count"] != len(Vocabularies)+len(Templates) || a.State["sdk_version"] != SDKVersion {
+		t.Errorf("manifest not deterministic or incomplete: %v", a.State)
+	}
+}
+
+func TestVerifySeeds(t *testing.T) {
+	store := NewMemoryStore()
+	seeds := SeedAll()
+	store.PutAll(seeds)
+	store.Put(SeedManifest(seeds))
+	r := VerifySeeds(store)
+	if !r.OK || r.Matched != len(seeds) || r.Manifest == "" {
+		t.Fatalf("report = %+v", r)
+	}
+
+	var bakery Block
+	for _, s := range seeds {
+		if seedKey(s) == "vocabulary:bakery" {
+			bakery = s
+		}
+	}
+	drifted := NewMemoryStore()
+	for _, s := range seeds {
+		if seedKey(s) != "template:"+Templates["supply-chain"].Name && s.Hash != bakery.Hash {
+			drifted.Put(s)
+		}
+	}
+	edited := Create(bakery.Type, map[string]interface{}{"domain": "bakery", "fields": map[string]interface{}{}}, nil)
+	drifted.Put(edited)
+	drifted.Put(Create("observe.vocabulary", map[string]interface{}{"domain": "brewery", "fields": map[string]interface{}{}}, nil))
+	r = VerifySeeds(drifted)
+	if r.OK || len(r.Missing) != 1 || len(r.Drifted) != 1 || r.Drifted[0].Hash != edited.Hash || r.Drifted[0].Expected != bakery.Hash {
+		t.Errorf("drift report = %+v", r)
+	}
+	if len(r.Extra) != 1 || r.Extra[0] != "vocabulary:brewery" {
+		t.Errorf("extra = %v", r.Extra)
+	}
+
+	// A seed from another SDK release with the same content still matches,
+	// but a stale revision claim does not.
+	released := NewMemoryStore()
+	for _, s := range seeds {
+		if seedKey(s) == "vocabulary:bakery" || seedKey(s) == "vocabulary:lot" {
+			continue
+		}
+		released.Put(s)
+	}
+	older := map[string]interface{}{}
+	for k, v := range bakery.State {
+		older[k] = v
+	}
+	older["sdk_version"] = "0.4.0"
+	released.Put(Create(bakery.Type, older, nil))
+	claimed := map[string]interface{}{"domain": "lot", "fields": map[string]interface{}{}, "revision": bakery.State["revision"]}
+	released.Put(Create("observe.vocabulary", claimed, nil))
+	r = VerifySeeds(released)
+	if len(r.Drifted) != 1 || r.Drifted[0].Key != "vocabulary:lot" || r.Matched != len(seeds)-1 {
+		t.Errorf("release report = %+v", r)
+	}
+
+	// The chain head is reported even when it arrived before its parent.
+	reordered := NewMemoryStore()
+	newer := MergeUpdate(edited, map[string]interface{}{"for_types": []interface{}{"substance.product"}}, nil)
+	reordered.PutAll([]Block{newer, edited})
+	for _, d := range VerifySeeds(reordered).Drifted {
+		if d.Key == "vocabulary:bakery" && d.Hash != newer.Hash {
+			t.Errorf("drifted bakery = %s, want the chain head %s", d.Hash, newer.Hash)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+// Package sim generates synthetic FoodBlock supply chains for tests, demos and
+// dashboards. Output is deterministic for a given Config and is emitted in
+// dependency order, so every ref points at a block earlier in the slice.
+package sim
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	foodblock "github.com/FoodXDevelopment/foodblock/sdk/go"
+)
+
+// Config controls the size and shape of a generated graph.
+type Config struct {
+	Seed       int64
+	Farms      int
+	Processors int
+	Venues     int
+	Start      time.Time
+	Days       int
+	// OrdersPerWeek is the mean number of orders each venue places per week.
+	// Demand follows a seasonal curve peaking in late summer.
+	OrdersPerWeek float64
+	// RecallRate is the probability that a crop is recalled during the period.
+	RecallRate float64
+	// DisputeRate is the probability that an order is disputed.
+	DisputeRate float64
+	// ReviewRate is the probability that an order is followed by a review.
+	ReviewRate float64
+}
+
+// DefaultConfig returns a small, demo-sized configuration.
+func DefaultConfig() Config {
+	return Config{
+		Seed:          1,
+		Farms:         5,
+		Processors:    3,
+		Venues:        10,
+		Start:         time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		Days:          90,
+		OrdersPerWeek: 3,
+		RecallRate:    0.05,
+		DisputeRate:   0.02,
+		ReviewRate:    0.2,
+	}
+}
+
+// Graph is a generated supply chain.
+type Graph struct {
+	Blocks     []foodblock.Block // in dependency order
+	Farms      []string
+	Processors []string
+	Venues     []string
+	Products   []string
+	Orders     []string
+	Recalls    []string
+	Disputes   []string
+}
+
+var (
+	farmAdjectives = []string{"Green", "Old", "Willow", "Meadow", "Highland", "Sunny", "River", "Oak", "Stone", "Golden"}
+	farmNouns      = []string{"Acres", "Valley", "Hill", "Brook", "Fields", "Orchard", "Hollow", "Ridge", "Grange", "Moor"}
+	venueKinds     = []string{"Bakery", "Cafe", "Bistro", "Deli", "Kitchen", "Canteen", "Pantry", "Tavern"}
+	venueNames     = []string{"Corner", "Harbour", "Market", "Station", "Garden", "Mill", "Village", "Bridge", "Castle", "Park"}
+	processorNames = []string{"Mill", "Creamery", "Bakehouse", "Preserves", "Smokehouse", "Dairy", "Press"}
+	crops          = []struct {
+		Name    string
+		Peak    int // month of peak harvest
+		Product string
+		Price   float64
+		Unit    string
+	}{
+		{"Wheat", 8, "Stoneground Flour", 2.40, "kg"},
+		{"Rye", 8, "Rye Sourdough", 4.20, "each"},
+		{"Apples", 10, "Apple Juice", 3.10, "l"},
+		{"Strawberries", 6, "Strawberry Jam", 4.80, "each"},
+		{"Milk", 5, "Farmhouse Cheddar", 18.50, "kg"},
+		{"Tomatoes", 8, "Passata", 2.90, "each"},
+		{"Barley", 8, "Pearl Barley", 1.90, "kg"},
+		{"Oats", 9, "Porridge Oats", 2.20, "kg"},
+		{"Potatoes", 9, "Hand-cut Crisps", 1.60, "each"},
+		{"Plums", 9, "Plum Chutney", 3.90, "each"},
+	}
+	disputeReasons = []string{"short delivery", "temperature excursion on arrival", "damaged packaging", "wrong product"}
+	recallReasons  = []string{"Listeria detected", "undeclared allergen", "pesticide residue above limit", "foreign body"}
+)
+
+type generator struct {
+	rng *rand.Rand
+	g   *Graph
+}
+
+// Generate builds a synthetic supply chain graph.
+func Generate(cfg Config) *Graph {
+	if cfg.Start.IsZero() {
+		cfg.Start = DefaultConfig().Start
+	}
+	if cfg.Days <= 0 {
+		cfg.Days = 1
+	}
+	gen := &generator{rng: rand.New(rand.NewSource(cfg.Seed)), g: &Graph{}}
+	return gen.run(cfg)
+}
+
+func (gen *generator) run(cfg Config) *Graph {
+	g := gen.g
+	certifier := gen.emit("actor.certifier", map[string]interface{}{"name": "Soil & Food Standards Board"}, nil)
+
+	type cropRef struct {
+		hash string
+		idx  int
+	}
+	var farmCrops []cropRef
+	for i := 0; i < cfg.Farms; i++ {
+		name := fmt.Sprintf("%s %s Farm", gen.pick(farmAdjectives), gen.pick(farmNouns))
+		farm := gen.emit("actor.producer", map[string]interface{}{"name": name, "region": fmt.Sprintf("region-%d", i%4+1)}, nil)
+		g.Farms = append(g.Farms, farm)
+		if gen.rng.Float64() < 0.6 {
+			gen.emitEvent("observe.certification", map[string]interface{}{
+				"name":        "Organic",
+				"valid_until": cfg.Start.AddDate(1, 0, 0).Format("2006-01-02"),
+			}, map[string]interface{}{"authority": certifier, "subject": farm})
+		}
+		for _, c := range gen.rng.Perm(len(crops))[:1+gen.rng.Intn(3)] {
+			hash := gen.emit("substance.ingredient", map[string]interface{}{
+				"name":        crops[c].Name,
+				"peak_season": crops[c].Peak,
+			}, map[string]interface{}{"source": farm})
+			farmCrops = append(farmCrops, cropRef{hash, c})
+		}
+	}
+
+	type product struct {
+		hash, seller string
+		price        float64
+		unit         string
+		inputs       []string
+	}
+	var products []product
+	for i := 0; i < cfg.Processors; i++ {
+		name := fmt.Sprintf("%s %s", gen.pick(farmAdjectives), gen.pick(processorNames))
+		proc := gen.emit("actor.processor", map[string]interface{}{"name": name}, nil)
+		g.Processors = append(g.Processors, proc)
+		if len(farmCrops) == 0 {
+			continue
+		}
+		for j := 0; j < 2; j++ {
+			crop := farmCrops[gen.rng.Intn(len(farmCrops))]
+			spec := crops[crop.idx]
+			process := gen.emitEvent("transform.process", map[string]interface{}{"name": "Processing " + spec.Name},
+				map[string]interface{}{"input": []interface{}{crop.hash}, "processor": proc})
+			price := roundCents(spec.Price * (0.85 + 0.3*gen.rng.Float64()))
+			hash := gen.emit("substance.product", map[string]interface{}{
+				"name":  spec.Product,
+				"price": price,
+				"unit":  spec.Unit,
+			}, map[string]interface{}{"origin": process, "seller": proc})
+			products = append(products, product{hash, proc, price, spec.Unit, []string{crop.hash}})
+			g.Products = append(g.Products, hash)
+		}
+	}
+
+	for i := 0; i < cfg.Venues; i++ {
+		name := fmt.Sprintf("The %s %s", gen.pick(venueNames), gen.pick(venueKinds))
+		g.Venues = append(g.Venues, gen.emit("actor.venue", map[string]interface{}{"name": name}, nil))
+	}
+
+	recalled := map[string]bool{}
+	for day := 0; day < cfg.Days && len(products) > 0; day++ {
+		date := cfg.Start.AddDate(0, 0, day)
+		rate := cfg.OrdersPerWeek / 7 * seasonal(date)
+		for _, venue := range g.Venues {
+			for n := gen.poisson(rate); n > 0; n-- {
+				p := products[gen.rng.Intn(len(products))]
+				qty := 1 + gen.rng.Intn(20)
+				order := gen.emitEvent("transfer.order", map[string]interface{}{
+					"status":   "confirmed",
+					"date":     date.Format("2006-01-02"),
+					"quantity": qty,
+					"unit":     p.unit,
+					"total":    roundCents(p.price * float64(qty)),
+					"currency": "GBP",
+				}, map[string]interface{}{"buyer": venue, "seller": p.seller, "product": p.hash})
+				g.Orders = append(g.Orders, order)
+				if gen.rng.Float64() < cfg.ReviewRate {
+					gen.emitEvent("observe.review", map[string]interface{}{"rating": 3 + gen.rng.Intn(3), "date": date.Format("2006-01-02")},
+						map[string]interface{}{"subject": p.hash, "author": venue})
+				}
+				if gen.rng.Float64() < cfg.DisputeRate {
+					g.Disputes = append(g.Disputes, gen.emitEvent("observe.dispute", map[string]interface{}{"reason": gen.pick(disputeReasons)},
+						map[string]interface{}{"challenges": order, "disputor": venue}))
+				}
+			}
+		}
+		// Spread recalls across the period rather than front-loading them.
+		for _, c := range farmCrops {
+			if !recalled[c.hash] && gen.rng.Float64() < cfg.RecallRate/float64(cfg.Days) {
+				recalled[c.hash] = true
+				g.Recalls = append(g.Recalls, gen.emitEvent("observe.recall", map[string]interface{}{
+					"reason": gen.pick(recallReasons),
+					"date":   date.Format("2006-01-02"),
+				}, map[string]interface{}{"source": c.hash, "authority": certifier}))
+			}
+		}
+	}
+	return g
+}
+
+// emit creates a block and appends it to the graph.
+func (gen *generator) emit(typ string, state, refs map[string]interface{}) string {
+	b := foodblock.Create(typ, state, refs)
+	gen.g.Blocks = append(gen.g.Blocks, b)
+	return b.Hash
+}
+
+// emitEvent creates an event block with a seeded instance_id so output stays deterministic.
+func (gen *generator) emitEvent(typ string, state, refs map[string]interface{}) string {
+	state["instance_id"] = gen.uuid()
+	return gen.emit(typ, state, refs)
+}
+
+func (gen *generator) uuid() string {
+	var b [16]byte
+	gen.rng.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+func (gen *generator) pick(list []string) string {
+	return list[gen.rng.Intn(len(list))]
+}
+
+// poisson draws from a Poisson distribution with mean lambda (Knuth).
+func (gen *generator) poisson(lambda float64) int {
+	if lambda <= 0 {
+		return 0
+	}
+	l, k, p := math.Exp(-lambda), 0, 1.0
+	for {
+		p *= gen.rng.Float64()
+		if p <= l {
+			return k
+		}
+		k++
+	}
+}
+
+// seasonal returns a demand multiplier between 0.5 and 1.5 peaking in August.
+func seasonal(t time.Time) float64 {
+	return 1 + 0.5*math.Cos(2*math.Pi*float64(t.YearDay()-227)/365)
+}
+
+func roundCents(v float64) float64 {
+	return math.Round(v*100) / 100
+}
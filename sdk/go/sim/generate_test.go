@@ -0,0 +1,71 @@
+package sim
+
+import (
+	"testing"
+
+	foodblock "github.com/FoodXDevelopment/foodblock/sdk/go"
+)
+
+func TestGenerateDeterministic(t *testing.T) {
+	cfg := DefaultConfig()
+	a, b := Generate(cfg), Generate(cfg)
+	if len(a.Blocks) != len(b.Blocks) {
+		t.Fatalf("lengths differ: %d vs %d", len(a.Blocks), len(b.Blocks))
+	}
+	for i := range a.Blocks {
+		if a.Blocks[i].Hash != b.Blocks[i].Hash {
+			t.Fatalf("block %d differs between runs", i)
+		}
+	}
+	cfg.Seed = 2
+	if c := Generate(cfg); len(c.Blocks) > 0 && c.Blocks[len(c.Blocks)-1].Hash == a.Blocks[len(a.Blocks)-1].Hash {
+		t.Error("different seeds produced identical output")
+	}
+}
+
+func TestGenerateDependencyOrder(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Days = 365
+	cfg.RecallRate, cfg.DisputeRate = 0.5, 0.1
+	g := Generate(cfg)
+
+	seen := map[string]bool{}
+	for i, b := range g.Blocks {
+		for role, ref := range b.Refs {
+			hashes := []string{}
+			switch v := ref.(type) {
+			case string:
+				hashes = append(hashes, v)
+			case []interface{}:
+				for _, h := range v {
+					hashes = append(hashes, h.(string))
+				}
+			}
+			for _, h := range hashes {
+				if !seen[h] {
+					t.Fatalf("block %d (%s) refs.%s points forward", i, b.Type, role)
+				}
+			}
+		}
+		seen[b.Hash] = true
+	}
+
+	if len(g.Farms) != cfg.Farms || len(g.Processors) != cfg.Processors || len(g.Venues) != cfg.Venues {
+		t.Errorf("actor counts = %d/%d/%d", len(g.Farms), len(g.Processors), len(g.Venues))
+	}
+	if len(g.Orders) == 0 || len(g.Recalls) == 0 || len(g.Disputes) == 0 {
+		t.Errorf("orders=%d recalls=%d disputes=%d", len(g.Orders), len(g.Recalls), len(g.Disputes))
+	}
+
+	store := foodblock.NewMemoryStore()
+	if err := store.PutAll(g.Blocks); err != nil {
+		t.Fatalf("bulk load: %v", err)
+	}
+}
+
+func TestSeasonalDemand(t *testing.T) {
+	cfg := DefaultConfig()
+	if seasonal(cfg.Start.AddDate(0, 7, 14)) <= seasonal(cfg.Start.AddDate(0, 1, 0)) {
+		t.Error("August demand should exceed February demand")
+	}
+}
@@ -0,0 +1,145 @@
+package foodblock
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// StateSLA limits how long a block may stay in one workflow state before it
+// transitions out. SLAs are keyed in VocabularyDef.SLAs by state.
+type StateSLA struct {
+	MaxHours float64 `json:"max_hours"`
+	// EscalateAfter lists hours past the deadline at which a breach rises
+	// one more level. Without it, every further MaxHours overdue does.
+	EscalateAfter []float64 `json:"escalate_after,omitempty"`
+}
+
+// SLAEscalationLevels names breach levels in ascending order; level 1 is
+// the first. Levels beyond the list use its last name.
+var SLAEscalationLevels = []string{"overdue", "escalated", "critical"}
+
+// level returns the escalation level of a breach overdue by the given time.
+func (s StateSLA) level(overdue time.Duration) int {
+	hours := overdue.Hours()
+	if len(s.EscalateAfter) == 0 {
+		return 1 + int(math.Floor(hours/s.MaxHours))
+	}
+	level := 1
+	for _, h := range s.EscalateAfter {
+		if hours >= h {
+			level++
+		}
+	}
+	return level
+}
+
+// SLABreach is a block that has stayed in a workflow state past its SLA.
+type SLABreach struct {
+	Block      Block         `json:"block"` // head of the chain
+	Status     string        `json:"status"`
+	Since      time.Time     `json:"since"` // when the chain entered Status
+	MaxHours   float64       `json:"max_hours"`
+	Overdue    time.Duration `json:"overdue"`
+	Level      int           `json:"level"`
+	Escalation string        `json:"escalation"` // the level's SLAEscalationLevels name
+}
+
+// CheckSLAs scans the heads of blocks of the vocabulary's types for chains
+// that have been in their current state.status longer than its SLA allows,
+// most escalated first, then most overdue. The time a chain entered its
+// state is the timestamp (updated_at, at, timestamp, date or created_at) the
+// earliest consecutive version with that status set itself, not one carried
+// over from the version before; chains without one are skipped.
+func CheckSLAs(vocab VocabularyDef, blocks []Block, now time.Time) []SLABreach {
+	var out []SLABreach
+	if len(vocab.SLAs) == 0 {
+		return out
+	}
+	for _, b := range FilterBlocks(blocks, QueryParams{HeadsOnly: true, IncludeExpired: true, AsOf: now}) {
+		if !containsStr(vocab.ForTypes, b.Type) {
+			continue
+		}
+		status, _ := b.State["status"].(string)
+		sla, ok := vocab.SLAs[status]
+		if !ok || sla.MaxHours <= 0 {
+			continue
+		}
+		since, ok := enteredStatus(b, blocks, status)
+		if !ok {
+			continue
+		}
+		due := since.Add(time.Duration(sla.MaxHours * float64(time.Hour)))
+		if !now.After(due) {
+			continue
+		}
+		breach := SLABreach{Block: b, Status: status, Since: since, MaxHours: sla.MaxHours, Overdue: now.Sub(due)}
+		breach.Level = sla.level(breach.Overdue)
+		breach.Escalation = SLAEscalationLevels[len(SLAEscalationLevels)-1]
+		if breach.Level <= len(SLAEscalationLevels) {
+			breach.Escalation = SLAEscalationLevels[breach.Level-1]
+		}
+		out = append(out, breach)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Level != out[j].Level {
+			return out[i].Level > out[j].Level
+		}
+		if out[i].Overdue != out[j].Overdue {
+			return out[i].Overdue > out[j].Overdue
+		}
+		return out[i].Block.Hash < out[j].Block.Hash
+	})
+	return out
+}
+
+// enteredStatus finds the version that moved head's chain into status, the
+// earliest of the consecutive versions with it, and returns the first of its
+// timestamps that it did not inherit from the version before: MergeUpdate
+// copies every field forward, so an inherited date says nothing about the
+// transition. A chain's first version keeps all its timestamps.
+func enteredStatus(head Block, graph []Block, status string) (time.Time, bool) {
+	_, versions := chainHashes(head, graph)
+	entered := -1
+	for i, v := range versions {
+		if s, _ := v.State["status"].(string); s != status {
+			break
+		}
+		entered = i
+	}
+	if entered < 0 {
+		return time.Time{}, false
+	}
+	v := versions[entered]
+	for _, f := range []string{"updated_at", "at", "timestamp", "date", "created_at"} {
+		if entered+1 < len(versions) {
+			own, _ := v.State[f].(string)
+			if inherited, _ := versions[entered+1].State[f].(string); own == inherited {
+				continue
+			}
+		}
+		if t, ok := stateTime(v.State, f); ok {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// SLAAlert records a breach as an observe.alert on the block, for
+// RenderNotification. The instance_id is the same for every check at one
+// escalation level, so repeated checks can alert only when a breach escalates.
+func SLAAlert(b SLABreach) Block {
+	inState := b.Overdue.Hours() + b.MaxHours
+	return Create("observe.alert", map[string]interface{}{
+		"instance_id":   fmt.Sprintf("sla-%s-%d", b.Block.Hash, b.Level),
+		"kind":          "sla",
+		"status":        b.Status,
+		"since":         b.Since.UTC().Format(time.RFC3339),
+		"max_hours":     b.MaxHours,
+		"overdue_hours": math.Round(b.Overdue.Hours()*10) / 10,
+		"level":         b.Level,
+		"escalation":    b.Escalation,
+		"message":       fmt.Sprintf("%s has been %s for %.0f hours, over its %g-hour limit (%s)", blockLabel(b.Block), b.Status, inState, b.MaxHours, b.Escalation),
+	}, map[string]interface{}{"subject": b.Block.Hash})
+}
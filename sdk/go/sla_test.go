@@ -0,0 +1,77 @@
+package foodblock
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckSLAs(t *testing.T) {
+	now := time.Date(2026, 4, 20, 9, 0, 0, 0, time.UTC)
+	ago := func(h int) string { return now.Add(-time.Duration(h) * time.Hour).Format(time.RFC3339) }
+	order := func(id, status, at string, prev *Block) Block {
+		state := map[string]interface{}{"instance_id": id, "status": status, "updated_at": at}
+		if prev == nil {
+			return Create("transfer.order", state, nil)
+		}
+		return Update(prev.Hash, "transfer.order", state, nil)
+	}
+
+	// Processing for two weeks: entered 336h ago, updated since without moving on.
+	stuck := order("o-1", "confirmed", ago(400), nil)
+	stuck2 := order("o-1", "processing", ago(336), &stuck)
+	stuck3 := order("o-1", "processing", ago(10), &stuck2)
+	// Processing for 80h: 8h over the 72h limit.
+	late := order("o-2", "processing", ago(80), nil)
+	// Processing for a day: within its SLA.
+	fresh := order("o-3", "processing", ago(24), nil)
+	// Delivered has no SLA.
+	done := order("o-4", "delivered", ago(900), nil)
+	untimed := Create("transfer.order", map[string]interface{}{"instance_id": "o-5", "status": "processing"}, nil)
+
+	// Moved to processing 10h ago; its order date was carried over from
+	// the first version and does not start the clock.
+	dated := Create("transfer.order", map[string]interface{}{"instance_id": "o-6", "status": "confirmed", "date": "2026-04-01"}, nil)
+	dated2 := MergeUpdate(dated, map[string]interface{}{"status": "processing", "updated_at": ago(10)}, nil)
+	// Moved to processing with no timestamp of its own: skipped.
+	inherited := Create("transfer.order", map[string]interface{}{"instance_id": "o-7", "status": "confirmed", "date": "2026-04-01"}, nil)
+	inherited2 := MergeUpdate(inherited, map[string]interface{}{"status": "processing"}, nil)
+
+	blocks := []Block{stuck, stuck2, stuck3, late, fresh, done, untimed, dated, dated2, inherited, inherited2}
+	breaches := CheckSLAs(Vocabularies["workflow"], blocks, now)
+	if len(breaches) != 2 {
+		t.Fatalf("expected 2 breaches, got %+v", breaches)
+	}
+	top := breaches[0]
+	if top.Block.Hash != stuck3.Hash || top.Since.Format(time.RFC3339) != ago(336) || top.Overdue != 264*time.Hour {
+		t.Errorf("stuck order: %+v", top)
+	}
+	if top.Level != 3 || top.Escalation != "critical" {
+		t.Errorf("264h overdue should be level 3 critical, got %d %s", top.Level, top.Escalation)
+	}
+	if breaches[1].Block.Hash != late.Hash || breaches[1].Level != 1 || breaches[1].Escalation != "overdue" {
+		t.Errorf("late order: %+v", breaches[1])
+	}
+
+	// Without EscalateAfter, each further MaxHours overdue raises a level.
+	vocab := VocabularyDef{ForTypes: []string{"transfer.order"}, SLAs: map[string]StateSLA{"processing": {MaxHours: 24}}}
+	if got := CheckSLAs(vocab, []Block{late}, now); len(got) != 1 || got[0].Level != 3 {
+		t.Errorf("80h against 24h: %+v", got)
+	}
+
+	alert := SLAAlert(top)
+	if alert.Type != "observe.alert" || alert.Refs["subject"] != stuck3.Hash || alert.State["escalation"] != "critical" {
+		t.Errorf("alert = %+v", alert)
+	}
+	again := SLAAlert(CheckSLAs(Vocabularies["workflow"], blocks, now.Add(time.Hour))[0])
+	if again.State["instance_id"] != alert.State["instance_id"] {
+		t.Error("same escalation level should keep its instance_id")
+	}
+	n, err := RenderNotification(alert, "sms", "en", "+441234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(n.Body, "processing for 336 hours") {
+		t.Errorf("notification = %q", n.Body)
+	}
+}
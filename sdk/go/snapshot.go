@@ -61,7 +61,7 @@ func CreateSnapshot(blocks []Block, summary string, dateRange []string) Block {
 // VerifySnapshot verifies that a set of blocks matches a snapshot's Merkle root.
 func VerifySnapshot(snapshot Block, blocks []Block) (bool, []string) {
 	expectedRoot, _ := snapshot.State["merkle_root"].(string)
-	expectedCount, _ := snapshot.State["block_count"].(float64)
+	expectedCount, _ := toFloat64(snapshot.State["block_count"])
 
 	if expectedRoot == "" {
 		return false, nil
@@ -92,3 +92,45 @@ func Summarize(blocks []Block) SnapshotSummary {
 	}
 	return SnapshotSummary{Total: len(blocks), ByType: byType}
 }
+
+// SnapshotProof returns the sibling hashes linking target to the Merkle root
+// computeMerkleRoot gives for hashes, bottom up. Pairs are hashed in sorted
+// order, so the proof needs no left/right flags.
+func SnapshotProof(hashes []string, target string) ([]string, bool) {
+	layer := make([]string, len(hashes))
+	copy(layer, hashes)
+	sort.Strings(layer)
+	pos := sort.SearchStrings(layer, target)
+	if pos == len(layer) || layer[pos] != target {
+		return nil, false
+	}
+	proof := []string{}
+	for len(layer) > 1 {
+		var next []string
+		for i := 0; i < len(layer); i += 2 {
+			if i+1 < len(layer) {
+				pair := []string{layer[i], layer[i+1]}
+				sort.Strings(pair)
+				next = append(next, Sha256Hex(pair[0]+pair[1]))
+			} else {
+				next = append(next, layer[i])
+			}
+		}
+		if sibling := pos ^ 1; sibling < len(layer) {
+			proof = append(proof, layer[sibling])
+		}
+		layer, pos = next, pos/2
+	}
+	return proof, true
+}
+
+// VerifySnapshotProof checks a SnapshotProof for target against a root.
+func VerifySnapshotProof(target string, proof []string, root string) bool {
+	cur := target
+	for _, sibling := range proof {
+		pair := []string{cur, sibling}
+		sort.Strings(pair)
+		cur = Sha256Hex(pair[0] + pair[1])
+	}
+	return cur == root
+}
@@ -126,3 +126,27 @@ func TestSummarize(t *testing.T) {
 		t.Errorf("expected 0 observe.review, got %d", summary.ByType["observe.review"])
 	}
 }
+
+func TestSnapshotProof(t *testing.T) {
+	var hashes []string
+	for i := 0; i < 7; i++ {
+		hashes = append(hashes, Create("substance.product", map[string]interface{}{"n": i}, nil).Hash)
+	}
+	root := computeMerkleRoot(hashes)
+	for _, h := range hashes {
+		proof, ok := SnapshotProof(hashes, h)
+		if !ok || !VerifySnapshotProof(h, proof, root) {
+			t.Errorf("proof for %s does not verify", h)
+		}
+	}
+	if proof, ok := SnapshotProof(hashes[:1], hashes[0]); !ok || len(proof) != 0 || !VerifySnapshotProof(hashes[0], proof, computeMerkleRoot(hashes[:1])) {
+		t.Error("a single block is its own root")
+	}
+	if _, ok := SnapshotProof(hashes, Sha256Hex("other")); ok {
+		t.Error("expected no proof for a block outside the snapshot")
+	}
+	proof, _ := SnapshotProof(hashes, hashes[0])
+	if VerifySnapshotProof(hashes[1], proof, root) {
+		t.Error("a proof should not verify another block")
+	}
+}
@@ -0,0 +1,258 @@
+package foodblock
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ValueRange is an inclusive range; a nil bound is open.
+type ValueRange struct {
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+}
+
+// Contains reports whether v lies within the range.
+func (r ValueRange) Contains(v float64) bool {
+	return (r.Min == nil || v >= *r.Min) && (r.Max == nil || v <= *r.Max)
+}
+
+func (r ValueRange) String() string {
+	lo, hi := "-inf", "+inf"
+	if r.Min != nil {
+		lo = fmt.Sprintf("%g", *r.Min)
+	}
+	if r.Max != nil {
+		hi = fmt.Sprintf("%g", *r.Max)
+	}
+	return lo + ".." + hi
+}
+
+// StorageSpec is the storage requirement of a product or lot, read from the
+// storage vocabulary's storage_temperature and storage_humidity fields.
+// Temperatures are held in celsius, humidity in percent.
+type StorageSpec struct {
+	Temperature *ValueRange `json:"temperature,omitempty"`
+	Humidity    *ValueRange `json:"humidity,omitempty"`
+	Source      string      `json:"source"` // block the spec was read from
+}
+
+// StorageSpecFor reads the storage spec of a block. A lot without its own
+// spec inherits its refs.product's; fields set on the lot take precedence.
+func StorageSpecFor(block Block, store BlockStore) (StorageSpec, bool) {
+	spec := StorageSpec{Source: block.Hash}
+	spec.Temperature = temperatureRange(block.State["storage_temperature"])
+	spec.Humidity = humidityRange(block.State["storage_humidity"])
+	if product, ok := block.Refs["product"].(string); ok && (spec.Temperature == nil || spec.Humidity == nil) {
+		if p := store.Resolve(product); p != nil {
+			inherited, ok := StorageSpecFor(*p, store)
+			if ok && spec.Temperature == nil && spec.Humidity == nil {
+				spec.Source = inherited.Source
+			}
+			if spec.Temperature == nil {
+				spec.Temperature = inherited.Temperature
+			}
+			if spec.Humidity == nil {
+				spec.Humidity = inherited.Humidity
+			}
+		}
+	}
+	return spec, spec.Temperature != nil || spec.Humidity != nil
+}
+
+func temperatureRange(v interface{}) *ValueRange {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	unit, _ := m["unit"].(string)
+	r := ValueRange{}
+	if lo, ok := toFloat64(m["min"]); ok {
+		c := toCelsius(lo, unit)
+		r.Min = &c
+	}
+	if hi, ok := toFloat64(m["max"]); ok {
+		c := toCelsius(hi, unit)
+		r.Max = &c
+	}
+	if r.Min == nil && r.Max == nil {
+		return nil
+	}
+	return &r
+}
+
+func humidityRange(v interface{}) *ValueRange {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	r := ValueRange{}
+	if lo, ok := toFloat64(m["min"]); ok {
+		r.Min = &lo
+	}
+	if hi, ok := toFloat64(m["max"]); ok {
+		r.Max = &hi
+	}
+	if r.Min == nil && r.Max == nil {
+		return nil
+	}
+	return &r
+}
+
+func toCelsius(v float64, unit string) float64 {
+	switch strings.ToLower(unit) {
+	case "fahrenheit", "f":
+		return round3((v - 32) * 5 / 9)
+	case "kelvin", "k":
+		return round3(v - 273.15)
+	}
+	return v
+}
+
+// StorageViolation is a reading outside the storage spec.
+type StorageViolation struct {
+	Reading string     `json:"reading"`
+	Holder  string     `json:"holder"` // location, shipment or lot the reading was taken on
+	At      string     `json:"at,omitempty"`
+	Field   string     `json:"field"` // temperature or humidity
+	Value   float64    `json:"value"`
+	Range   ValueRange `json:"range"`
+}
+
+// StorageCompliance is the result of CheckStorageCompliance.
+type StorageCompliance struct {
+	Subject    string             `json:"subject"`
+	Spec       StorageSpec        `json:"spec"`
+	Holders    []string           `json:"holders"`
+	Readings   int                `json:"readings"`  // readings with a temperature or humidity the spec constrains
+	Compliant  int                `json:"compliant"` // readings with every constrained value in range
+	Percent    float64            `json:"percent"`   // 0 when there are no readings
+	Violations []StorageViolation `json:"violations"`
+}
+
+// storageHolderRefs are the refs by which a lot names where it is kept.
+var storageHolderRefs = []string{"location", "storage", "venue", "place"}
+
+// CheckStorageCompliance evaluates every observe.reading taken on the lot or
+// product itself, on the locations it names (refs.location, storage, venue or
+// place) and on the shipments and places that reference it, against its
+// storage spec.
+func CheckStorageCompliance(productOrLotHash string, store BlockStore) (StorageCompliance, error) {
+	lot := store.Resolve(productOrLotHash)
+	if lot == nil {
+		return StorageCompliance{}, fmt.Errorf("FoodBlock: block not found: %s", productOrLotHash)
+	}
+	spec, ok := StorageSpecFor(*lot, store)
+	if !ok {
+		return StorageCompliance{}, fmt.Errorf("FoodBlock: no storage spec for %s", productOrLotHash)
+	}
+	report := StorageCompliance{Subject: lot.Hash, Spec: spec, Holders: []string{}, Violations: []StorageViolation{}}
+
+	addHolder := func(h string) {
+		if h != "" && !containsStr(report.Holders, h) {
+			report.Holders = append(report.Holders, h)
+		}
+	}
+	for _, v := range Chain(lot.Hash, store.Resolve, 0) {
+		addHolder(v.Hash)
+		for _, key := range storageHolderRefs {
+			if h, ok := v.Refs[key].(string); ok {
+				addHolder(h)
+			}
+		}
+		for _, b := range store.ResolveForward(v.Hash) {
+			if strings.HasPrefix(b.Type, "transfer.") || strings.HasPrefix(b.Type, "place.") {
+				addHolder(b.Hash)
+			}
+		}
+	}
+
+	type reading struct {
+		block  Block
+		holder string
+		at     time.Time
+	}
+	var readings []reading
+	seen := map[string]bool{}
+	for _, h := range report.Holders {
+		for _, b := range store.ResolveForward(h) {
+			if b.Type != "observe.reading" || seen[b.Hash] || b.Refs["subject"] != h {
+				continue
+			}
+			seen[b.Hash] = true
+			at, _ := readingTime(b)
+			readings = append(readings, reading{b, h, at})
+		}
+	}
+	sort.Slice(readings, func(i, j int) bool {
+		if !readings[i].at.Equal(readings[j].at) {
+			return readings[i].at.Before(readings[j].at)
+		}
+		return readings[i].block.Hash < readings[j].block.Hash
+	})
+
+	for _, r := range readings {
+		checked, pass := 0, true
+		check := func(field string, value float64, rng *ValueRange) {
+			checked++
+			if rng.Contains(value) {
+				return
+			}
+			pass = false
+			at := ""
+			if !r.at.IsZero() {
+				at = r.at.UTC().Format(time.RFC3339)
+			}
+			report.Violations = append(report.Violations, StorageViolation{
+				Reading: r.block.Hash, Holder: r.holder, At: at, Field: field, Value: value, Range: *rng,
+			})
+		}
+		if spec.Temperature != nil {
+			if t, ok := readingTemperature(r.block); ok {
+				check("temperature", t, spec.Temperature)
+			}
+		}
+		if spec.Humidity != nil {
+			if h, ok := numericValue(r.block.State["humidity"]); ok {
+				check("humidity", h, spec.Humidity)
+			}
+		}
+		if checked == 0 {
+			continue
+		}
+		report.Readings++
+		if pass {
+			report.Compliant++
+		}
+	}
+	if report.Readings > 0 {
+		report.Percent = math.Round(float64(report.Compliant)/float64(report.Readings)*10000) / 100
+	}
+	return report, nil
+}
+
+// readingTemperature reads state.temperature, a number or a {value, unit}
+// quantity, in celsius. A bare number takes its unit from state.unit.
+func readingTemperature(b Block) (float64, bool) {
+	switch t := b.State["temperature"].(type) {
+	case map[string]interface{}:
+		v, ok := toFloat64(t["value"])
+		unit, _ := t["unit"].(string)
+		return toCelsius(v, unit), ok
+	default:
+		v, ok := toFloat64(t)
+		unit, _ := b.State["unit"].(string)
+		return toCelsius(v, unit), ok
+	}
+}
+
+func readingTime(b Block) (time.Time, bool) {
+	if s, ok := b.State["at"].(string); ok {
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			return t, true
+		}
+	}
+	return BlockDate(b)
+}
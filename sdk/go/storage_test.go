@@ -0,0 +1,61 @@
+package foodblock
+
+import "testing"
+
+func TestCheckStorageCompliance(t *testing.T) {
+	cheese := Create("substance.product", map[string]interface{}{
+		"name":                "Brie",
+		"storage_temperature": map[string]interface{}{"min": 35.6, "max": 42.8, "unit": "fahrenheit"},
+		"storage_humidity":    map[string]interface{}{"min": 80.0, "max": 95.0, "unit": "percent"},
+	}, nil)
+	cellar := Create("place.warehouse", map[string]interface{}{"name": "Cellar"}, nil)
+	lot := Create("substance.product", map[string]interface{}{"name": "Brie lot 7", "lot_id": "L7"},
+		map[string]interface{}{"product": cheese.Hash, "location": cellar.Hash})
+	truck := Create("transfer.delivery", map[string]interface{}{"instance_id": "d-7"},
+		map[string]interface{}{"item": lot.Hash, "seller": "dairy", "buyer": "shop"})
+	reading := func(subject, at string, state map[string]interface{}) Block {
+		state["instance_id"] = subject[:8] + at
+		state["at"] = at
+		return Create("observe.reading", state, map[string]interface{}{"subject": subject})
+	}
+
+	store := NewMemoryStore()
+	store.PutAll([]Block{
+		cheese, cellar, lot, truck,
+		reading(cellar.Hash, "2026-03-01T08:00:00Z", map[string]interface{}{"temperature": 4.0, "humidity": 90.0}),
+		reading(cellar.Hash, "2026-03-01T20:00:00Z", map[string]interface{}{"temperature": 4.5, "humidity": 70.0}),
+		reading(truck.Hash, "2026-03-02T09:00:00Z", map[string]interface{}{"temperature": map[string]interface{}{"value": 48.2, "unit": "fahrenheit"}}),
+		reading(truck.Hash, "2026-03-02T11:00:00Z", map[string]interface{}{"temperature": 275.65, "unit": "kelvin"}),
+		reading(truck.Hash, "2026-03-02T12:00:00Z", map[string]interface{}{"reading_type": "door_open"}),
+	})
+
+	report, err := CheckStorageCompliance(lot.Hash, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Spec.Source != cheese.Hash || *report.Spec.Temperature.Max != 6 {
+		t.Errorf("expected spec inherited from the product in celsius, got %+v", report.Spec)
+	}
+	if report.Readings != 4 || report.Compliant != 2 || report.Percent != 50 {
+		t.Errorf("expected 2 of 4 readings compliant, got %d of %d (%.2f%%)", report.Compliant, report.Readings, report.Percent)
+	}
+	if len(report.Violations) != 2 || report.Violations[0].Field != "humidity" || report.Violations[1].Holder != truck.Hash {
+		t.Errorf("unexpected violations %+v", report.Violations)
+	}
+
+	if _, err := CheckStorageCompliance(cellar.Hash, store); err == nil {
+		t.Error("expected an error for a block without a storage spec")
+	}
+}
+
+func TestMapFieldsStorageSpec(t *testing.T) {
+	result := MapFields("store at 0 to 4 c, humidity 85 to 95 percent", Vocabularies["storage"])
+	temp, _ := result.Matched["storage_temperature"].(map[string]interface{})
+	if temp["min"] != 0.0 || temp["max"] != 4.0 || temp["unit"] != "celsius" {
+		t.Errorf("expected 0-4 celsius, got %v", result.Matched)
+	}
+	hum, _ := result.Matched["storage_humidity"].(map[string]interface{})
+	if hum["min"] != 85.0 || hum["max"] != 95.0 {
+		t.Errorf("expected 85-95 percent, got %v", result.Matched)
+	}
+}
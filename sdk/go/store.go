@@ -0,0 +1,551 @@
+package foodblock
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BlockStore persists blocks and answers the lookups that the SDK's resolver
+// callbacks need. Resolve and ResolveForward can be passed directly wherever a
+// resolve or resolveForward function is expected.
+type BlockStore interface {
+	Put(block Block) error
+	Resolve(hash string) *Block
+	ResolveForward(hash string) []Block
+	Blocks() []Block
+	Delete(hash string) bool
+	Len() int
+}
+
+// AliasStore is implemented by stores that carry an alias registry.
+type AliasStore interface {
+	Aliases() *Registry
+}
+
+// HeadIndex is implemented by stores that track the latest version of every
+// update chain.
+type HeadIndex interface {
+	HeadOf(hash string) string
+	AllHeads(typ string) []Block
+}
+
+// StubStore is implemented by stores that can replace a block's content with a
+// stub while keeping its hash, type and refs (erasure, pruning, archival).
+type StubStore interface {
+	BlockStore
+	Stub(hash string, state map[string]interface{}) bool
+	PutStub(stub Block) error
+	StoredAt(hash string) (time.Time, bool)
+}
+
+// Stub markers set as the entire state of a stubbed block.
+var stubMarkers = []string{"tombstoned", "pruned", "archived"}
+
+// IsStub reports whether a block's content has been replaced by a stub.
+func IsStub(block Block) bool {
+	if len(block.State) != 1 {
+		return false
+	}
+	for _, m := range stubMarkers {
+		if v, ok := block.State[m].(bool); ok && v {
+			return true
+		}
+	}
+	return false
+}
+
+// MemoryStore is an in-memory BlockStore with type and forward-reference
+// indexes. It is safe for concurrent use.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	blocks  map[string]Block
+	order   []string
+	byType  map[string][]string
+	forward map[string][]string
+	stored  map[string]time.Time
+	aliases *Registry
+
+	// Head index: next maps a block to the first stored block that updates
+	// it, jump caches compressed paths along next, heads holds the blocks no
+	// block updates, by type.
+	next  map[string]string
+	jump  map[string]string
+	heads map[string]map[string]bool
+	pos   map[string]int
+	seq   int
+
+	// Change log: every Put and Stub appends an entry; latest maps a hash to
+	// the sequence number of its most recent change.
+	changes []change
+	latest  map[string]int
+
+	authors map[string]string
+	// Signed wrappers without their block, so the change feed can serve
+	// blocks with proof of who signed them.
+	signed map[string]SignedBlock
+
+	// Logical clock: every stored block gets a Clock, from its signed wrapper
+	// when it arrives with one, otherwise from the store's own HLC.
+	clock  *HLC
+	clocks map[string]Clock
+
+	// Reference integrity: the mode Put enforces, trusted peers consulted in
+	// strict mode, and refs to blocks not yet stored (see integrity.go).
+	integrity IntegrityMode
+	peers     []RefResolver
+	pending   map[string]*PendingRef
+}
+
+// NewMemoryStore creates an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		blocks:  make(map[string]Block),
+		byType:  make(map[string][]string),
+		forward: make(map[string][]string),
+		stored:  make(map[string]time.Time),
+		aliases: NewRegistry(),
+		next:    make(map[string]string),
+		jump:    make(map[string]string),
+		heads:   make(map[string]map[string]bool),
+		pos:     make(map[string]int),
+		authors: make(map[string]string),
+		latest:  make(map[string]int),
+		signed:  make(map[string]SignedBlock),
+		clock:   NewHLC(""),
+		clocks:  make(map[string]Clock),
+		pending: make(map[string]*PendingRef),
+	}
+}
+
+// Put stores a block. The hash must match the block's content; storing a block
+// that is already present is a no-op. Certifications under a registered scheme
+// must pass ValidateCertification, and refs are checked under the store's
+// integrity mode (see SetIntegrity).
+func (s *MemoryStore) Put(block Block) error {
+	if err := checkPut(block); err != nil {
+		return err
+	}
+	if err := s.checkRefs(block); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.insert(block)
+	s.trackRefs(block)
+	return nil
+}
+
+// PutSigned stores a signed block and records its author. Only the content
+// hash is checked; verify signed first with KeyRegistry.VerifySigned.
+//
+// A wrapper carrying a Clock keeps it as the block's clock and the store's
+// HLC observes it, so blocks created here afterwards order after it. Storing
+// a block that is already present is a no-op: it keeps the signer and clock
+// it was first stored with, so re-signing someone else's block cannot take
+// it over.
+func (s *MemoryStore) PutSigned(signed SignedBlock) error {
+	block := signed.FoodBlock
+	if err := checkPut(block); err != nil {
+		return err
+	}
+	if err := s.checkRefs(block); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.blocks[block.Hash]; exists {
+		return nil
+	}
+	if signed.Clock != nil {
+		s.clocks[block.Hash] = *signed.Clock
+		s.clock.Observe(*signed.Clock)
+	}
+	s.insert(block)
+	s.trackRefs(block)
+	if signed.AuthorHash != "" {
+		s.authors[block.Hash] = signed.AuthorHash
+	}
+	if signed.Signature != "" {
+		w := signed
+		w.FoodBlock, w.Clock = Block{}, nil
+		s.signed[block.Hash] = w
+	}
+	return nil
+}
+
+// checkPut validates a block before it is stored.
+func checkPut(block Block) error {
+	if block.Hash == "" || block.Type == "" {
+		return errors.New("FoodBlock: block must have hash and type")
+	}
+	if Hash(block.Type, block.State, block.Refs) != block.Hash {
+		return errors.New("FoodBlock: hash does not match block content")
+	}
+	return checkCertification(block)
+}
+
+// ClockOf returns the logical clock recorded for a block.
+func (s *MemoryStore) ClockOf(hash string) (Clock, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, ok := s.clocks[hash]
+	return c, ok
+}
+
+// Stamp sets a signed wrapper's Clock to the one the store recorded for its
+// block, ready to send to a peer. Wrappers for unknown blocks are returned
+// unchanged.
+func (s *MemoryStore) Stamp(signed SignedBlock) SignedBlock {
+	if c, ok := s.ClockOf(signed.FoodBlock.Hash); ok {
+		signed.Clock = &c
+	}
+	return signed
+}
+
+// UseClock replaces the store's HLC, e.g. with one named for this node and
+// shared with other stores it runs. Clocks already recorded are kept.
+func (s *MemoryStore) UseClock(h *HLC) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h.Observe(s.clock.Last())
+	s.clock = h
+}
+
+// AuthorOf returns the recorded author of a block: the signer for blocks
+// stored with PutSigned, otherwise refs.author.
+func (s *MemoryStore) AuthorOf(hash string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.authorOf(hash)
+}
+
+// SignerOf returns the signer recorded by PutSigned, or "" for a block
+// stored unsigned. Unlike AuthorOf it never trusts refs.author.
+func (s *MemoryStore) SignerOf(hash string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.authors[hash]
+}
+
+// SignedOf returns the signed wrapper a block was stored with by PutSigned.
+// Stubbed blocks have none: their content no longer matches the signature.
+func (s *MemoryStore) SignedOf(hash string) (SignedBlock, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.signedOf(hash)
+}
+
+func (s *MemoryStore) signedOf(hash string) (SignedBlock, bool) {
+	w, ok := s.signed[hash]
+	if !ok {
+		return SignedBlock{}, false
+	}
+	w.FoodBlock = s.blocks[hash]
+	return w, true
+}
+
+func (s *MemoryStore) authorOf(hash string) string {
+	if a, ok := s.authors[hash]; ok {
+		return a
+	}
+	author, _ := s.blocks[hash].Refs["author"].(string)
+	return author
+}
+
+// PutAll stores blocks in order, stopping at the first error.
+func (s *MemoryStore) PutAll(blocks []Block) error {
+	for _, b := range blocks {
+		if err := s.Put(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) insert(block Block) {
+	if _, exists := s.blocks[block.Hash]; exists {
+		return
+	}
+	s.blocks[block.Hash] = block
+	s.stored[block.Hash] = time.Now()
+	delete(s.pending, block.Hash)
+	if _, ok := s.clocks[block.Hash]; !ok {
+		s.clocks[block.Hash] = s.clock.Now()
+	}
+	s.seq++
+	s.pos[block.Hash] = s.seq
+	s.recordChange(block.Hash)
+	s.order = append(s.order, block.Hash)
+	s.index(block)
+}
+
+// index adds a block to the type, forward and head indexes. Callers hold the lock.
+func (s *MemoryStore) index(block Block) {
+	s.byType[block.Type] = append(s.byType[block.Type], block.Hash)
+	for _, ref := range uniqueRefValues(block.Refs) {
+		s.forward[ref] = append(s.forward[ref], block.Hash)
+	}
+	if _, superseded := s.next[block.Hash]; !superseded {
+		if s.heads[block.Type] == nil {
+			s.heads[block.Type] = make(map[string]bool)
+		}
+		s.heads[block.Type][block.Hash] = true
+	}
+	// Like Head, only the first block to update a version continues its chain.
+	if prev, ok := block.Refs["updates"].(string); ok && prev != block.Hash {
+		if _, taken := s.next[prev]; !taken {
+			s.next[prev] = block.Hash
+			if pb, ok := s.blocks[prev]; ok {
+				delete(s.heads[pb.Type], prev)
+			}
+		}
+	}
+}
+
+// Resolve returns the block with the given hash, or nil.
+func (s *MemoryStore) Resolve(hash string) *Block {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	b, ok := s.blocks[hash]
+	if !ok {
+		return nil
+	}
+	return &b
+}
+
+// ResolveForward returns all blocks that reference the given hash.
+func (s *MemoryStore) ResolveForward(hash string) []Block {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make([]Block, 0, len(s.forward[hash]))
+	for _, h := range s.forward[hash] {
+		result = append(result, s.blocks[h])
+	}
+	return result
+}
+
+// ByType returns all blocks of a type in insertion order.
+func (s *MemoryStore) ByType(typ string) []Block {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make([]Block, 0, len(s.byType[typ]))
+	for _, h := range s.byType[typ] {
+		result = append(result, s.blocks[h])
+	}
+	return result
+}
+
+// Blocks returns all blocks in insertion order.
+func (s *MemoryStore) Blocks() []Block {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make([]Block, len(s.order))
+	for i, h := range s.order {
+		result[i] = s.blocks[h]
+	}
+	return result
+}
+
+// Delete removes a block and its index entries. Returns whether it was present.
+func (s *MemoryStore) Delete(hash string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.blocks[hash]; !ok {
+		return false
+	}
+	delete(s.blocks, hash)
+	delete(s.stored, hash)
+	delete(s.pos, hash)
+	delete(s.authors, hash)
+	delete(s.signed, hash)
+	delete(s.latest, hash)
+	delete(s.clocks, hash)
+	s.order = removeStr(s.order, hash)
+	s.rebuildIndexes()
+	return true
+}
+
+// Stub replaces a block's state with a stub state, keeping hash, type and refs.
+// Returns whether the block was present.
+func (s *MemoryStore) Stub(hash string, state map[string]interface{}) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.blocks[hash]
+	if !ok {
+		return false
+	}
+	b.State = state
+	s.blocks[hash] = b
+	delete(s.signed, hash)
+	s.seq++
+	s.recordChange(hash)
+	return true
+}
+
+// PutStub stores a stub block without verifying its hash against its content.
+func (s *MemoryStore) PutStub(stub Block) error {
+	if stub.Hash == "" || stub.Type == "" || !IsStub(stub) {
+		return errors.New("FoodBlock: not a stub block")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.insert(stub)
+	return nil
+}
+
+// StoredAt returns when a block was first stored.
+func (s *MemoryStore) StoredAt(hash string) (time.Time, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t, ok := s.stored[hash]
+	return t, ok
+}
+
+// RebuildIndexes recomputes the type and forward indexes from stored blocks.
+func (s *MemoryStore) RebuildIndexes() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rebuildIndexes()
+}
+
+// Len returns the number of stored blocks.
+func (s *MemoryStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.blocks)
+}
+
+// Aliases returns the store's alias registry.
+func (s *MemoryStore) Aliases() *Registry {
+	return s.aliases
+}
+
+// TypeIndex returns a copy of the type index (type -> hashes in insertion order).
+func (s *MemoryStore) TypeIndex() map[string][]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make(map[string][]string, len(s.byType))
+	for t, hashes := range s.byType {
+		result[t] = append([]string(nil), hashes...)
+	}
+	return result
+}
+
+// rebuildIndexes recomputes every index from the stored blocks. Callers hold the lock.
+func (s *MemoryStore) rebuildIndexes() {
+	s.byType = make(map[string][]string)
+	s.forward = make(map[string][]string)
+	s.next = make(map[string]string)
+	s.jump = make(map[string]string)
+	s.heads = make(map[string]map[string]bool)
+	for _, h := range s.order {
+		s.index(s.blocks[h])
+	}
+}
+
+// HeadOf returns the latest version in the update chain containing hash,
+// matching Head without walking the graph. Unknown hashes are returned as is.
+func (s *MemoryStore) HeadOf(hash string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var path []string
+	current := hash
+	for i := 0; i <= len(s.next); i++ {
+		nx, ok := s.jump[current]
+		if !ok {
+			nx, ok = s.next[current]
+		}
+		if !ok {
+			break
+		}
+		path = append(path, current)
+		current = nx
+	}
+	// Chains only grow at their head, so compressed paths stay valid.
+	for _, h := range path {
+		s.jump[h] = current
+	}
+	return current
+}
+
+// AllHeads returns the latest version of every update chain of a type, in
+// insertion order. An empty type returns heads of every type.
+func (s *MemoryStore) AllHeads(typ string) []Block {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var hashes []string
+	for t, set := range s.heads {
+		if typ != "" && t != typ {
+			continue
+		}
+		for h := range set {
+			hashes = append(hashes, h)
+		}
+	}
+	sort.Slice(hashes, func(i, j int) bool { return s.pos[hashes[i]] < s.pos[hashes[j]] })
+	result := make([]Block, len(hashes))
+	for i, h := range hashes {
+		result[i] = s.blocks[h]
+	}
+	return result
+}
+
+// Query evaluates query parameters against the store, using the type and
+// head indexes to narrow candidates. It can be passed to NewQuery.
+func (s *MemoryStore) Query(params QueryParams) ([]Block, error) {
+	exactType := params.Type != "" && !strings.Contains(params.Type, "*")
+	var candidates []Block
+	switch {
+	case params.HeadsOnly && exactType:
+		candidates = s.AllHeads(params.Type)
+	case params.HeadsOnly:
+		candidates = s.AllHeads("")
+	case exactType:
+		candidates = s.ByType(params.Type)
+	default:
+		candidates = s.Blocks()
+	}
+	params.HeadsOnly = false
+	if !params.IncludeExpired {
+		now := params.AsOf
+		if now.IsZero() {
+			now = time.Now()
+		}
+		live := candidates[:0:0]
+		for _, b := range candidates {
+			stored, _ := s.StoredAt(b.Hash)
+			if t, ok := ExpiresAt(b, stored); !ok || now.Before(t) {
+				live = append(live, b)
+			}
+		}
+		candidates = live
+	}
+	return FilterBlocks(candidates, params), nil
+}
+
+// uniqueRefValues returns the distinct hashes a refs map points at, sorted.
+func uniqueRefValues(refs map[string]interface{}) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, h := range flattenRefValues(refs) {
+		if !seen[h] {
+			seen[h] = true
+			result = append(result, h)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func removeStr(slice []string, s string) []string {
+	out := slice[:0]
+	for _, v := range slice {
+		if v != s {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,157 @@
+package foodblock
+
+import "testing"
+
+func TestMemoryStorePutAndResolve(t *testing.T) {
+	store := NewMemoryStore()
+	farm := Create("actor.producer", map[string]interface{}{"name": "Green Acres"}, nil)
+	wheat := Create("substance.ingredient", map[string]interface{}{"name": "Wheat"}, map[string]interface{}{"source": farm.Hash})
+	if err := store.PutAll([]Block{farm, wheat, farm}); err != nil {
+		t.Fatal(err)
+	}
+	if store.Len() != 2 {
+		t.Errorf("expected duplicate put to be ignored, got %d blocks", store.Len())
+	}
+	if got := store.Resolve(wheat.Hash); got == nil || got.Hash != wheat.Hash {
+		t.Error("expected to resolve wheat")
+	}
+	fwd := store.ResolveForward(farm.Hash)
+	if len(fwd) != 1 || fwd[0].Hash != wheat.Hash {
+		t.Errorf("expected wheat to reference farm, got %v", fwd)
+	}
+	if got := Explain(wheat.Hash, store.Resolve, 0); got == "" {
+		t.Error("expected store.Resolve to work as a resolver callback")
+	}
+}
+
+func TestMemoryStoreRejectsMismatchedHash(t *testing.T) {
+	store := NewMemoryStore()
+	b := Create("substance.product", map[string]interface{}{"name": "Bread"}, nil)
+	b.State = map[string]interface{}{"name": "Cake"}
+	if err := store.Put(b); err == nil {
+		t.Error("expected tampered block to be rejected")
+	}
+}
+
+func TestMemoryStorePutSignedKeepsFirstSigner(t *testing.T) {
+	store := NewMemoryStore()
+	wheat := Create("substance.product", map[string]interface{}{"name": "Wheat"}, nil)
+	first := Clock{Wall: 1000, Node: "y"}
+	store.PutSigned(SignedBlock{FoodBlock: wheat, AuthorHash: "actor-y", Clock: &first})
+	if err := store.PutSigned(SignedBlock{FoodBlock: wheat, AuthorHash: "actor-x", Clock: &Clock{Wall: 2000, Node: "x"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := store.SignerOf(wheat.Hash); got != "actor-y" {
+		t.Errorf("re-signing took over the block: signer = %q", got)
+	}
+	if got, _ := store.ClockOf(wheat.Hash); got != first {
+		t.Errorf("clock = %+v, want %+v", got, first)
+	}
+}
+
+func TestMemoryStoreDelete(t *testing.T) {
+	store := NewMemoryStore()
+	farm := Create("actor.producer", map[string]interface{}{"name": "Green Acres"}, nil)
+	wheat := Create("substance.ingredient", map[string]interface{}{"name": "Wheat"}, map[string]interface{}{"source": farm.Hash})
+	store.PutAll([]Block{farm, wheat})
+	if !store.Delete(wheat.Hash) {
+		t.Fatal("expected delete to succeed")
+	}
+	if len(store.ResolveForward(farm.Hash)) != 0 || len(store.ByType("substance.ingredient")) != 0 {
+		t.Error("expected indexes to drop deleted block")
+	}
+}
+
+func TestMemoryStoreHeadIndex(t *testing.T) {
+	store := NewMemoryStore()
+	v1 := Create("substance.product", map[string]interface{}{"name": "Bread", "price": 4.0}, nil)
+	v2 := MergeUpdate(v1, map[string]interface{}{"price": 4.5}, nil)
+	v3 := MergeUpdate(v2, map[string]interface{}{"price": 5.0}, nil)
+	other := Create("substance.product", map[string]interface{}{"name": "Cake"}, nil)
+
+	// Out of order: v3 arrives before v2.
+	store.PutAll([]Block{v1, other, v3, v2})
+
+	for _, h := range []string{v1.Hash, v2.Hash, v3.Hash} {
+		if got := store.HeadOf(h); got != v3.Hash {
+			t.Errorf("HeadOf(%s) = %s, want v3", h[:8], got[:8])
+		}
+		if got := Head(h, store.ResolveForward, 0); got != store.HeadOf(h) {
+			t.Error("HeadOf disagrees with Head")
+		}
+	}
+	heads := store.AllHeads("substance.product")
+	if len(heads) != 2 || heads[0].Hash != other.Hash || heads[1].Hash != v3.Hash {
+		t.Errorf("AllHeads = %v", heads)
+	}
+
+	v4 := MergeUpdate(v3, map[string]interface{}{"price": 5.5}, nil)
+	store.Put(v4)
+	if store.HeadOf(v1.Hash) != v4.Hash {
+		t.Error("compressed path not extended to new head")
+	}
+
+	got, err := NewQuery(store.Query).Type("substance.product").Latest().Exec()
+	if err != nil || len(got) != 2 {
+		t.Fatalf("Latest() = %d blocks, err %v", len(got), err)
+	}
+	got, _ = NewQuery(store.Query).Type("substance.*").WhereGt("price", 5.2).Exec()
+	if len(got) != 1 || got[0].Hash != v4.Hash {
+		t.Errorf("filtered query = %v", got)
+	}
+
+	store.Delete(v4.Hash)
+	if store.HeadOf(v1.Hash) != v3.Hash {
+		t.Error("head index not rebuilt after delete")
+	}
+}
+
+func TestQueryRefsExistenceAndOrdering(t *testing.T) {
+	store := NewMemoryStore()
+	mill := Create("actor.producer", map[string]interface{}{"name": "Mill"}, nil)
+	farm := Create("actor.producer", map[string]interface{}{"name": "Farm"}, nil)
+	flour := Create("substance.product", map[string]interface{}{"name": "Flour", "price": 2.0}, map[string]interface{}{"seller": mill.Hash})
+	wheat := Create("substance.ingredient", map[string]interface{}{"name": "Wheat", "organic": true}, map[string]interface{}{"seller": farm.Hash})
+	bread := Create("substance.product", map[string]interface{}{"name": "Bread", "price": 4.5}, map[string]interface{}{"inputs": []interface{}{flour.Hash, wheat.Hash}})
+	order := Create("transfer.order", map[string]interface{}{"instance_id": "o1"}, map[string]interface{}{"buyer": mill.Hash, "item": bread.Hash})
+	store.PutAll([]Block{mill, farm, flour, wheat, bread, order})
+
+	q := func() *QueryBuilder { return NewQuery(store.Query) }
+	if got, _ := q().Type("*.product").Exec(); len(got) != 2 {
+		t.Errorf("*.product = %d blocks", len(got))
+	}
+	if got, _ := q().ByAnyRef(mill.Hash).Exec(); len(got) != 2 || got[0].Hash != flour.Hash || got[1].Hash != order.Hash {
+		t.Errorf("ByAnyRef = %v", got)
+	}
+	if got, _ := q().ByRefIn("seller", farm.Hash, "missing").Exec(); len(got) != 1 || got[0].Hash != wheat.Hash {
+		t.Errorf("ByRefIn = %v", got)
+	}
+	if got, _ := q().ByRefIn("inputs", wheat.Hash).Type("substance.*").Exec(); len(got) != 1 || got[0].Hash != bread.Hash {
+		t.Errorf("ByRefIn on a list = %v", got)
+	}
+	if got, _ := q().Type("substance.*").WhereMissing("price").WhereExists("organic").Exec(); len(got) != 1 || got[0].Hash != wheat.Hash {
+		t.Errorf("existence filters = %v", got)
+	}
+
+	tagged := Create("substance.product", map[string]interface{}{"name": "Rye", "tags": []interface{}{"organic", 1}, "origin": map[string]interface{}{"country": "FR"}}, nil)
+	store.Put(tagged)
+	if got, _ := q().WhereEq("tags", []interface{}{"organic", 1.0}).Exec(); len(got) != 1 || got[0].Hash != tagged.Hash {
+		t.Errorf("array filter = %v", got)
+	}
+	if got, _ := q().WhereEq("origin", map[string]interface{}{"country": "FR"}).Exec(); len(got) != 1 || got[0].Hash != tagged.Hash {
+		t.Errorf("object filter = %v", got)
+	}
+	if got, _ := q().WhereEq("name", []interface{}{"Rye"}).Exec(); len(got) != 0 {
+		t.Errorf("array filter against a string matched %v", got)
+	}
+	store.Delete(tagged.Hash)
+
+	got, _ := q().Type("substance.*").OrderBy("price", "desc").Exec()
+	if len(got) != 3 || got[0].Hash != bread.Hash || got[1].Hash != flour.Hash || got[2].Hash != wheat.Hash {
+		t.Errorf("OrderBy price desc = %v", got)
+	}
+	got, _ = q().Type("actor.*").OrderBy("name", "asc").Limit(1).Exec()
+	if len(got) != 1 || got[0].Hash != farm.Hash {
+		t.Errorf("OrderBy name then Limit = %v", got)
+	}
+}
@@ -0,0 +1,177 @@
+package foodblock
+
+import (
+	"sort"
+	"strings"
+)
+
+// Suggestion is one completion for partially typed text. Replace is the
+// number of trailing bytes of the input the completion replaces.
+type Suggestion struct {
+	Text    string  `json:"text"`
+	Kind    string  `json:"kind"` // field, alias or value
+	Field   string  `json:"field"`
+	Replace int     `json:"replace"`
+	Score   float64 `json:"score"`
+}
+
+// Usage counts how often a vocabulary's fields and values occur in stored
+// blocks, for ranking suggestions.
+type Usage struct {
+	Fields map[string]int            `json:"fields"`
+	Values map[string]map[string]int `json:"values"` // field -> lowercased value -> count
+}
+
+// LearnUsage counts field and value use per vocabulary domain across the
+// latest version of every block in a store. A block counts towards each
+// vocabulary whose ForTypes include its type. String values and the keys of
+// compound fields are counted as values; true booleans count the field only.
+func LearnUsage(store BlockStore) map[string]Usage {
+	out := map[string]Usage{}
+	for _, b := range FilterBlocks(store.Blocks(), QueryParams{HeadsOnly: true}) {
+		for domain, vocab := range Vocabularies {
+			if !containsStr(vocab.ForTypes, b.Type) {
+				continue
+			}
+			u, ok := out[domain]
+			if !ok {
+				u = Usage{Fields: map[string]int{}, Values: map[string]map[string]int{}}
+				out[domain] = u
+			}
+			for field := range vocab.Fields {
+				v, ok := b.State[field]
+				if !ok || v == false {
+					continue
+				}
+				u.Fields[field]++
+				var values []string
+				switch x := v.(type) {
+				case string:
+					values = []string{x}
+				case map[string]interface{}:
+					for k := range x {
+						values = append(values, k)
+					}
+				}
+				for _, value := range values {
+					if u.Values[field] == nil {
+						u.Values[field] = map[string]int{}
+					}
+					u.Values[field][strings.ToLower(value)]++
+				}
+			}
+		}
+	}
+	return out
+}
+
+// Suggest completes the last words of partial text against a vocabulary:
+// field names and aliases that start with what was typed ("org" → organic),
+// and, after an alias, the field's valid values or compound options
+// ("roast level d" → dark). Results are ranked by how much of the completion
+// has been typed.
+func Suggest(partial string, vocab VocabularyDef) []Suggestion {
+	return SuggestWith(partial, vocab, Usage{})
+}
+
+// SuggestWith is Suggest ranked with usage learned from a store: the more
+// often a field or value is used in the vocabulary's domain, the higher it
+// ranks among completions typed to the same extent.
+func SuggestWith(partial string, vocab VocabularyDef, usage Usage) []Suggestion {
+	lower := strings.ToLower(partial)
+	var starts []int // word starts, last word first
+	for i := len(lower) - 1; i >= 0; i-- {
+		if i == 0 || lower[i-1] == ' ' {
+			starts = append(starts, i)
+		}
+	}
+	if strings.HasSuffix(lower, " ") {
+		starts = append([]int{len(lower)}, starts...)
+	}
+	word := ""
+	if len(starts) > 0 {
+		word = lower[starts[0]:]
+	}
+
+	best := map[string]Suggestion{}
+	add := func(s Suggestion, typed, count, most int) {
+		s.Score = float64(typed) / float64(len(s.Text))
+		if most > 0 {
+			s.Score += float64(count) / float64(most)
+		}
+		s.Score = round3(s.Score)
+		if prev, ok := best[s.Text]; !ok || s.Score > prev.Score {
+			best[s.Text] = s
+		}
+	}
+	maxField := 0
+	for _, n := range usage.Fields {
+		if n > maxField {
+			maxField = n
+		}
+	}
+
+	for name, def := range vocab.Fields {
+		count := usage.Fields[name]
+		candidates := map[string]string{strings.ReplaceAll(name, "_", " "): "field"}
+		for _, a := range def.Aliases {
+			if _, ok := candidates[strings.ToLower(a)]; !ok {
+				candidates[strings.ToLower(a)] = "alias"
+			}
+		}
+		for text, kind := range candidates {
+			// Match the alias against the trailing words it could span.
+			for _, start := range starts {
+				typed := lower[start:]
+				if typed == "" || len(typed) >= len(text) {
+					continue
+				}
+				if strings.HasPrefix(text, typed) {
+					add(Suggestion{Text: text, Kind: kind, Field: name, Replace: len(typed)}, len(typed), count, maxField)
+				}
+			}
+
+			// Complete the value after a typed alias.
+			values := def.ValidValues
+			if def.Compound || def.Type == "compound" {
+				values = def.Aliases
+			}
+			learned := usage.Values[name]
+			if len(values) == 0 && len(learned) == 0 {
+				continue
+			}
+			before := strings.TrimRight(strings.TrimSuffix(lower, word), " ")
+			if before != text && !strings.HasSuffix(before, " "+text) {
+				continue
+			}
+			maxValue := 0
+			options := map[string]bool{}
+			for _, v := range values {
+				options[strings.ToLower(v)] = true
+			}
+			for v, n := range learned {
+				options[v] = true
+				if n > maxValue {
+					maxValue = n
+				}
+			}
+			for v := range options {
+				if v != word && strings.HasPrefix(v, word) {
+					add(Suggestion{Text: v, Kind: "value", Field: name, Replace: len(word)}, len(word), learned[v], maxValue)
+				}
+			}
+		}
+	}
+
+	out := make([]Suggestion, 0, len(best))
+	for _, s := range best {
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].Text < out[j].Text
+	})
+	return out
+}
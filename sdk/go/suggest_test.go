@@ -0,0 +1,56 @@
+package foodblock
+
+import "testing"
+
+func suggestionTexts(s []Suggestion) []string {
+	out := make([]string, len(s))
+	for i, x := range s {
+		out[i] = x.Text
+	}
+	return out
+}
+
+func TestSuggest(t *testing.T) {
+	got := Suggest("fresh org", Vocabularies["bakery"])
+	if len(got) == 0 || got[0].Text != "organic" || got[0].Field != "organic" || got[0].Replace != 3 {
+		t.Errorf("expected organic, got %+v", got)
+	}
+
+	got = Suggest("grade ", Vocabularies["processor"])
+	texts := suggestionTexts(got)
+	if len(texts) != 2 || texts[0] != "grade a" || texts[1] != "grade b" || got[0].Replace != 6 {
+		t.Errorf("expected grade a and grade b, got %+v", got)
+	}
+
+	got = Suggest("medium roast level d", Vocabularies["coffee"])
+	if len(got) != 1 || got[0].Text != "dark" || got[0].Kind != "value" || got[0].Field != "roast_level" {
+		t.Errorf("expected dark, got %+v", got)
+	}
+
+	if got := Suggest("moisture c", Vocabularies["apiary"]); len(got) == 0 || got[0].Text != "moisture content" {
+		t.Errorf("expected a multi-word completion, got %+v", got)
+	}
+	if got := Suggest("", Vocabularies["bakery"]); len(got) != 0 {
+		t.Errorf("expected no suggestions for empty input, got %+v", got)
+	}
+}
+
+func TestSuggestWithUsage(t *testing.T) {
+	store := NewMemoryStore()
+	for i, sev := range []string{"high", "high", "critical"} {
+		store.Put(Create("observe.incident", map[string]interface{}{"instance_id": string(rune('a' + i)), "severity": sev}, nil))
+	}
+	usage := LearnUsage(store)["incident"]
+	if usage.Fields["severity"] != 3 || usage.Values["severity"]["high"] != 2 {
+		t.Fatalf("unexpected usage %+v", usage)
+	}
+
+	plain := suggestionTexts(Suggest("severity ", Vocabularies["incident"]))
+	if plain[0] != "critical" {
+		t.Errorf("expected alphabetical order without usage, got %v", plain)
+	}
+	ranked := suggestionTexts(SuggestWith("severity ", Vocabularies["incident"], usage))
+	if ranked[0] != "high" || ranked[1] != "critical" {
+		t.Errorf("expected usage ranking, got %v", ranked)
+	}
+}
@@ -6,6 +6,7 @@ type TemplateStep struct {
 	Alias        string            `json:"alias,omitempty"`
 	Refs         map[string]string `json:"refs,omitempty"`
 	Required     []string          `json:"required,omitempty"`
+	Optional     bool              `json:"optional,omitempty"` // the step may be skipped
 	DefaultState map[string]interface{} `json:"default_state,omitempty"`
 }
 
@@ -78,7 +79,7 @@ var Templates = map[string]TemplateDef{
 		Description: "Restaurant needs ingredient → discovery → supplier offer → accept → order → delivery",
 		Steps: []TemplateStep{
 			{Type: "actor.venue", Alias: "restaurant", DefaultState: map[string]interface{}{"name": "Restaurant"}},
-			{Type: "substance.ingredient", Alias: "needed", Refs: map[string]string{}, DefaultState: map[string]interface{}{"name": "Ingredient Needed"}},
+			{Type: "substance.ingredient", Alias: "needed", DefaultState: map[string]interface{}{"name": "Ingredient Needed"}},
 			{Type: "actor.producer", Alias: "supplier", DefaultState: map[string]interface{}{"name": "Supplier"}},
 			{Type: "transfer.offer", Alias: "offer", Refs: map[string]string{"seller": "@supplier", "item": "@needed", "buyer": "@restaurant"}, DefaultState: map[string]interface{}{"status": "offered"}},
 			{Type: "transfer.order", Alias: "order", Refs: map[string]string{"buyer": "@restaurant", "seller": "@supplier", "item": "@needed"}, DefaultState: map[string]interface{}{"status": "confirmed"}},
@@ -103,8 +104,8 @@ var Templates = map[string]TemplateDef{
 			{Type: "actor.producer", Alias: "producer", DefaultState: map[string]interface{}{"name": "Market Producer"}},
 			{Type: "place.market", Alias: "market", DefaultState: map[string]interface{}{"name": "Farmers Market"}},
 			{Type: "substance.product", Alias: "stock", Refs: map[string]string{"seller": "@producer"}, DefaultState: map[string]interface{}{"name": "Market Stock"}},
-			{Type: "transfer.order", Alias: "sales", Refs: map[string]string{"seller": "@producer", "item": "@stock"}, DefaultState: map[string]interface{}{"status": "completed"}},
-			{Type: "substance.surplus", Alias: "leftover", Refs: map[string]string{"seller": "@producer", "source": "@stock"}, DefaultState: map[string]interface{}{"name": "End of Day Surplus", "status": "available"}},
+			{Type: "transfer.order", Alias: "sales", Refs: map[string]string{"seller": "@producer", "item": "@stock"}, Optional: true, DefaultState: map[string]interface{}{"status": "completed"}},
+			{Type: "substance.surplus", Alias: "leftover", Refs: map[string]string{"seller": "@producer", "source": "@stock"}, Optional: true, DefaultState: map[string]interface{}{"name": "End of Day Surplus", "status": "available"}},
 		},
 	},
 	"cold-chain": {
@@ -141,6 +142,9 @@ func CreateTemplate(name, description string, steps []TemplateStep, authorHash s
 			}
 			step["required"] = req
 		}
+		if s.Optional {
+			step["optional"] = true
+		}
 		if len(s.DefaultState) > 0 {
 			step["default_state"] = s.DefaultState
 		}
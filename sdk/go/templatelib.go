@@ -0,0 +1,312 @@
+package foodblock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// TemplateType is the block type of shareable templates.
+const TemplateType = "observe.template"
+
+var semverPattern = regexp.MustCompile(`^\d+\.\d+\.\d+$`)
+
+// CreateVersionedTemplate creates a template as CreateTemplate does, with a
+// semantic version (MAJOR.MINOR.PATCH) in state.version.
+func CreateVersionedTemplate(name, description string, steps []TemplateStep, authorHash, version string) (Block, error) {
+	if !semverPattern.MatchString(version) {
+		return Block{}, fmt.Errorf("FoodBlock: template version must be MAJOR.MINOR.PATCH, got %q", version)
+	}
+	t := CreateTemplate(name, description, steps, authorHash)
+	t.State["version"] = version
+	return Create(TemplateType, t.State, t.Refs), nil
+}
+
+// UpdateTemplate creates the next version of a template, chained to prev
+// with refs.updates. The name and author carry over and the version must
+// be greater than prev's.
+func UpdateTemplate(prev Block, description string, steps []TemplateStep, version string) (Block, error) {
+	if prev.Type != TemplateType {
+		return Block{}, errors.New("FoodBlock: not an " + TemplateType + " block")
+	}
+	name, _ := prev.State["name"].(string)
+	next, err := CreateVersionedTemplate(name, description, steps, firstRef(prev.Refs, "author"), version)
+	if err != nil {
+		return Block{}, err
+	}
+	if old, _ := prev.State["version"].(string); old != "" && compareVersions(version, old) <= 0 {
+		return Block{}, fmt.Errorf("FoodBlock: template version %s does not follow %s", version, old)
+	}
+	return Update(prev.Hash, TemplateType, next.State, next.Refs), nil
+}
+
+// TemplateFromBlock reads an observe.template block back into a TemplateDef.
+func TemplateFromBlock(b Block) (TemplateDef, error) {
+	if b.Type != TemplateType {
+		return TemplateDef{}, errors.New("FoodBlock: not an " + TemplateType + " block")
+	}
+	def := TemplateDef{}
+	def.Name, _ = b.State["name"].(string)
+	def.Description, _ = b.State["description"].(string)
+	steps, _ := b.State["steps"].([]interface{})
+	for i, raw := range steps {
+		m, ok := raw.(map[string]interface{})
+		if !ok {
+			return TemplateDef{}, fmt.Errorf("FoodBlock: template step %d is malformed", i)
+		}
+		step := TemplateStep{}
+		step.Type, _ = m["type"].(string)
+		if step.Type == "" {
+			return TemplateDef{}, fmt.Errorf("FoodBlock: template step %d has no type", i)
+		}
+		step.Alias, _ = m["alias"].(string)
+		if refs, ok := m["refs"].(map[string]interface{}); ok {
+			step.Refs = map[string]string{}
+			for k, v := range refs {
+				if s, ok := v.(string); ok {
+					step.Refs[k] = s
+				}
+			}
+		}
+		if req, ok := m["required"].([]interface{}); ok {
+			for _, r := range req {
+				if s, ok := r.(string); ok {
+					step.Required = append(step.Required, s)
+				}
+			}
+		}
+		step.Optional, _ = m["optional"].(bool)
+		step.DefaultState, _ = m["default_state"].(map[string]interface{})
+		def.Steps = append(def.Steps, step)
+	}
+	return def, nil
+}
+
+// TemplateVersion is one version of a template in a catalog.
+type TemplateVersion struct {
+	Version string `json:"version"`
+	Hash    string `json:"hash"`
+}
+
+// TemplateCatalogEntry describes a template chain: its latest version and
+// every version before it, oldest first.
+type TemplateCatalogEntry struct {
+	Name     string            `json:"name"`
+	Author   string            `json:"author"`
+	Root     string            `json:"root"` // first version
+	Latest   string            `json:"latest"`
+	Version  string            `json:"version"`
+	Versions []TemplateVersion `json:"versions"`
+}
+
+// TemplateCatalog is the body of GET /templates.
+type TemplateCatalog struct {
+	Templates []TemplateCatalogEntry `json:"templates"`
+	Blocks    []SignedBlock          `json:"blocks"` // every version, signed by its author
+}
+
+// TemplateLibrary holds signed templates and their versions. It is safe for
+// concurrent use.
+type TemplateLibrary struct {
+	mu     sync.RWMutex
+	signed map[string]SignedBlock
+	order  []string
+	next   map[string]string // version -> the version updating it
+}
+
+// NewTemplateLibrary creates an empty library.
+func NewTemplateLibrary() *TemplateLibrary {
+	return &TemplateLibrary{signed: map[string]SignedBlock{}, next: map[string]string{}}
+}
+
+// Add stores a signed template version. With keys, the signature must
+// verify and the signer must be the template's refs.author. An update must
+// follow a version already in the library, by the same author, with a
+// greater version.
+func (l *TemplateLibrary) Add(s SignedBlock, keys *KeyRegistry) error {
+	b := s.FoodBlock
+	if b.Type != TemplateType || Hash(b.Type, b.State, b.Refs) != b.Hash {
+		return errors.New("FoodBlock: not a valid " + TemplateType + " block")
+	}
+	version, _ := b.State["version"].(string)
+	if !semverPattern.MatchString(version) {
+		return fmt.Errorf("FoodBlock: template %s has no semantic version", b.Hash)
+	}
+	if author := firstRef(b.Refs, "author"); author == "" || author != s.AuthorHash {
+		return fmt.Errorf("FoodBlock: template %s is not signed by its author", b.Hash)
+	}
+	if keys != nil && !keys.VerifySigned(s) {
+		return fmt.Errorf("FoodBlock: template %s signature verification failed", b.Hash)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.signed[b.Hash]; ok {
+		return nil
+	}
+	if prevHash := firstRef(b.Refs, "updates"); prevHash != "" {
+		prev, ok := l.signed[prevHash]
+		if !ok {
+			return fmt.Errorf("FoodBlock: template %s updates %s, which is not in the library", b.Hash, prevHash)
+		}
+		if prev.AuthorHash != s.AuthorHash {
+			return fmt.Errorf("FoodBlock: template %s updates another author's template", b.Hash)
+		}
+		if old, _ := prev.FoodBlock.State["version"].(string); compareVersions(version, old) <= 0 {
+			return fmt.Errorf("FoodBlock: template version %s does not follow %s", version, old)
+		}
+		if other, ok := l.next[prevHash]; ok {
+			return fmt.Errorf("FoodBlock: template %s is already updated by %s", prevHash, other)
+		}
+		l.next[prevHash] = b.Hash
+	}
+	l.signed[b.Hash] = s
+	l.order = append(l.order, b.Hash)
+	return nil
+}
+
+func (l *TemplateLibrary) has(hash string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	_, ok := l.signed[hash]
+	return ok
+}
+
+// Resolve finds a template version by hash, or by "name@version" or a bare
+// name for the latest version. Any author may publish under any name, so a
+// name can be scoped to an author as "<author hash>/name"; an unscoped name
+// that more than one author uses is ambiguous and an error.
+func (l *TemplateLibrary) Resolve(ref string) (Block, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if s, ok := l.signed[ref]; ok {
+		return s.FoodBlock, nil
+	}
+	author, named, scoped := strings.Cut(ref, "/")
+	if !scoped {
+		author, named = "", ref
+	}
+	name, version, pinned := strings.Cut(named, "@")
+	var match *TemplateCatalogEntry
+	for _, e := range l.catalogLocked() {
+		if e.Name != name || (scoped && e.Author != author) {
+			continue
+		}
+		if match != nil && match.Author != e.Author {
+			return Block{}, fmt.Errorf("FoodBlock: template %s is published by more than one author; scope it as <author>/%s", name, named)
+		}
+		e := e
+		match = &e
+	}
+	if match == nil {
+		return Block{}, fmt.Errorf("FoodBlock: template not found: %s", ref)
+	}
+	if !pinned {
+		return l.signed[match.Latest].FoodBlock, nil
+	}
+	for _, v := range match.Versions {
+		if v.Version == version {
+			return l.signed[v.Hash].FoodBlock, nil
+		}
+	}
+	return Block{}, fmt.Errorf("FoodBlock: template not found: %s", ref)
+}
+
+// Catalog lists the library's template chains by name.
+func (l *TemplateLibrary) Catalog() TemplateCatalog {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	c := TemplateCatalog{Templates: l.catalogLocked(), Blocks: []SignedBlock{}}
+	for _, h := range l.order {
+		c.Blocks = append(c.Blocks, l.signed[h])
+	}
+	return c
+}
+
+func (l *TemplateLibrary) catalogLocked() []TemplateCatalogEntry {
+	entries := []TemplateCatalogEntry{}
+	for _, h := range l.order {
+		root := l.signed[h].FoodBlock
+		if firstRef(root.Refs, "updates") != "" {
+			continue
+		}
+		e := TemplateCatalogEntry{Author: l.signed[h].AuthorHash, Root: h}
+		e.Name, _ = root.State["name"].(string)
+		for cur, ok := h, true; ok; cur, ok = l.next[cur] {
+			v, _ := l.signed[cur].FoodBlock.State["version"].(string)
+			e.Versions = append(e.Versions, TemplateVersion{Version: v, Hash: cur})
+			e.Latest, e.Version = cur, v
+		}
+		entries = append(entries, e)
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries
+}
+
+// Handler serves the catalog to federation peers at GET /templates.
+func (l *TemplateLibrary) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "the template catalog is read-only")
+			return
+		}
+		writeJSON(w, http.StatusOK, l.Catalog())
+	})
+}
+
+// TemplatePull reports the outcome of PullTemplates.
+type TemplatePull struct {
+	Added    int            `json:"added"`
+	Rejected []BatchFailure `json:"rejected,omitempty"`
+}
+
+// PullTemplates fetches a peer's template catalog into lib. Every version
+// must be signed by its author under keys; those that are not, or that
+// break their chain, are rejected and reported.
+func (c *FederationClient) PullTemplates(ctx context.Context, keys *KeyRegistry, lib *TemplateLibrary) (TemplatePull, error) {
+	if keys == nil {
+		return TemplatePull{}, errors.New("FoodBlock: pulling templates needs a key registry")
+	}
+	var catalog TemplateCatalog
+	if err := c.getJSON(ctx, c.BaseURL+"/templates", &catalog); err != nil {
+		return TemplatePull{}, err
+	}
+	var pull TemplatePull
+	for _, s := range catalog.Blocks { // roots come before their updates
+		known := lib.has(s.FoodBlock.Hash)
+		if err := lib.Add(s, keys); err != nil {
+			pull.Rejected = append(pull.Rejected, BatchFailure{Target: s.FoodBlock.Hash, Error: strings.TrimPrefix(err.Error(), "FoodBlock: ")})
+			continue
+		}
+		if !known {
+			pull.Added++
+		}
+	}
+	return pull, nil
+}
+
+// FromTemplateVersion instantiates the template version ref names (see
+// Resolve) and pins it: every block created carries refs.template with the
+// version's hash, so the flow can be reproduced after the template moves on.
+func FromTemplateVersion(lib *TemplateLibrary, ref string, values map[string]StepOverrides) ([]Block, error) {
+	tb, err := lib.Resolve(ref)
+	if err != nil {
+		return nil, err
+	}
+	def, err := TemplateFromBlock(tb)
+	if err != nil {
+		return nil, err
+	}
+	for i := range def.Steps {
+		refs := map[string]string{"template": tb.Hash}
+		for k, v := range def.Steps[i].Refs {
+			refs[k] = v
+		}
+		def.Steps[i].Refs = refs
+	}
+	return FromTemplate(def, values), nil
+}
@@ -0,0 +1,108 @@
+package foodblock
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTemplateLibrary(t *testing.T) {
+	pub, priv := GenerateKeypair()
+	_, strangerPriv := GenerateKeypair()
+	author := Create("actor.producer", map[string]interface{}{"name": "Green Acres"}, nil)
+	keys := NewKeyRegistry()
+	keys.Register(author.Hash, pub)
+
+	steps := []TemplateStep{
+		{Type: "actor.producer", Alias: "farm", DefaultState: map[string]interface{}{"name": "Farm"}},
+		{Type: "substance.product", Alias: "crop", Refs: map[string]string{"seller": "@farm"}},
+	}
+	v1, err := CreateVersionedTemplate("harvest", "Farm harvest", steps, author.Hash, "1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CreateVersionedTemplate("harvest", "", steps, author.Hash, "v1"); err == nil {
+		t.Error("accepted a version that is not MAJOR.MINOR.PATCH")
+	}
+	v2, err := UpdateTemplate(v1, "Farm harvest with lot", append(steps, TemplateStep{Type: "observe.reading", Refs: map[string]string{"subject": "@crop"}}), "1.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v2.Refs["updates"] != v1.Hash || v2.Refs["author"] != author.Hash {
+		t.Errorf("update refs = %v", v2.Refs)
+	}
+	if _, err := UpdateTemplate(v2, "", steps, "1.0.9"); err == nil {
+		t.Error("accepted a version older than the one it updates")
+	}
+
+	remote := NewTemplateLibrary()
+	for _, b := range []Block{v1, v2} {
+		if err := remote.Add(Sign(b, author.Hash, priv), keys); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := remote.Add(Sign(v1, "someone-else", strangerPriv), keys); err == nil {
+		t.Error("accepted a template signed by someone other than its author")
+	}
+	cat := remote.Catalog()
+	if len(cat.Templates) != 1 || cat.Templates[0].Version != "1.1.0" || cat.Templates[0].Latest != v2.Hash || len(cat.Templates[0].Versions) != 2 {
+		t.Fatalf("catalog = %+v", cat.Templates)
+	}
+
+	srv := httptest.NewServer(remote.Handler())
+	defer srv.Close()
+	local := NewTemplateLibrary()
+	pull, err := NewFederationClient(srv.URL).PullTemplates(context.Background(), keys, local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pull.Added != 2 || len(pull.Rejected) != 0 {
+		t.Fatalf("pull = %+v", pull)
+	}
+	if untrusted, _ := NewFederationClient(srv.URL).PullTemplates(context.Background(), NewKeyRegistry(), NewTemplateLibrary()); untrusted.Added != 0 || len(untrusted.Rejected) != 2 {
+		t.Errorf("unknown author's templates not rejected: %+v", untrusted)
+	}
+
+	if b, err := local.Resolve("harvest"); err != nil || b.Hash != v2.Hash {
+		t.Error("bare name does not resolve to the latest version")
+	}
+	blocks, err := FromTemplateVersion(local, "harvest@1.0.0", map[string]StepOverrides{"crop": {State: map[string]interface{}{"name": "Carrots"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) != 2 {
+		t.Fatalf("pinned 1.0.0 made %d blocks, want 2", len(blocks))
+	}
+	for _, b := range blocks {
+		if b.Refs["template"] != v1.Hash {
+			t.Errorf("%s not pinned to 1.0.0: %v", b.Type, b.Refs)
+		}
+	}
+	if blocks[1].Refs["seller"] != blocks[0].Hash || blocks[1].State["name"] != "Carrots" {
+		t.Errorf("template refs or overrides lost: %+v", blocks[1])
+	}
+	if _, err := FromTemplateVersion(local, "harvest@2.0.0", nil); err == nil {
+		t.Error("instantiated a version that does not exist")
+	}
+
+	// Another author publishing the same name makes the bare name ambiguous.
+	squatterPub, squatterPriv := GenerateKeypair()
+	squatter := Create("actor.producer", map[string]interface{}{"name": "Squatter"}, nil)
+	keys.Register(squatter.Hash, squatterPub)
+	fake, _ := CreateVersionedTemplate("harvest", "Not the real one", steps, squatter.Hash, "9.0.0")
+	if err := local.Add(Sign(fake, squatter.Hash, squatterPriv), keys); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := local.Resolve("harvest"); err == nil {
+		t.Error("resolved a name two authors publish")
+	}
+	if _, err := FromTemplateVersion(local, "harvest@1.0.0", nil); err == nil {
+		t.Error("instantiated an ambiguous name")
+	}
+	if b, err := local.Resolve(author.Hash + "/harvest@1.0.0"); err != nil || b.Hash != v1.Hash {
+		t.Errorf("author-scoped name: %v", err)
+	}
+	if b, err := local.Resolve(squatter.Hash + "/harvest"); err != nil || b.Hash != fake.Hash {
+		t.Errorf("author-scoped latest: %v", err)
+	}
+}
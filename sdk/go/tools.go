@@ -0,0 +1,365 @@
+package foodblock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ToolSchema is a function-calling tool definition with a JSON Schema for its
+// parameters, in the shape accepted by common LLM tool-use APIs.
+type ToolSchema struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Parameters  map[string]interface{} `json:"parameters"`
+}
+
+// Tool names exported by ExportToolSchemas.
+const (
+	ToolCreateBlock     = "create_block"
+	ToolQueryBlocks     = "query_blocks"
+	ToolFromTemplate    = "from_template"
+	ToolAttest          = "attest"
+	ToolTransitionOrder = "transition_order"
+)
+
+// AttestationConfidences are the confidence levels accepted by the attest tool.
+var AttestationConfidences = []string{"verified", "probable", "unverified"}
+
+// ExportToolSchemas returns tool definitions for the core SDK operations.
+// Block types, template names and workflow statuses are constrained to the
+// built-in vocabularies, templates and schemas.
+func ExportToolSchemas() []ToolSchema {
+	types := knownBlockTypes()
+	templates := make([]string, 0, len(Templates))
+	for name := range Templates {
+		templates = append(templates, name)
+	}
+	sort.Strings(templates)
+	statuses := workflowStates(Vocabularies["workflow"])
+
+	hash := map[string]interface{}{"type": "string", "pattern": "^[0-9a-f]{64}$"}
+	stringMap := map[string]interface{}{"type": "object", "additionalProperties": map[string]interface{}{"type": "string"}}
+
+	return []ToolSchema{
+		{
+			Name:        ToolCreateBlock,
+			Description: "Create a FoodBlock with a type, state and refs. Returns the block with its content hash.",
+			Parameters: objectSchema(map[string]interface{}{
+				"type":  map[string]interface{}{"type": "string", "enum": types, "description": "Block type"},
+				"state": map[string]interface{}{"type": "object", "description": "Block state fields"},
+				"refs": map[string]interface{}{"type": "object", "description": "Named references to other block hashes",
+					"additionalProperties": map[string]interface{}{"oneOf": []interface{}{hash, map[string]interface{}{"type": "array", "items": hash}}}},
+			}, "type"),
+		},
+		{
+			Name:        ToolQueryBlocks,
+			Description: "Find blocks by type, refs and state equality filters.",
+			Parameters: objectSchema(map[string]interface{}{
+				"type":            map[string]interface{}{"type": "string", "description": "Block type, or a prefix pattern such as substance.*"},
+				"refs":            stringMap,
+				"where":           map[string]interface{}{"type": "object", "description": "State fields that must equal the given values"},
+				"latest":          map[string]interface{}{"type": "boolean", "description": "Only return the latest version of each block"},
+				"limit":           map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 500},
+				"include_expired": map[string]interface{}{"type": "boolean", "description": "Also return blocks past their expires_at or ttl"},
+			}),
+		},
+		{
+			Name:        ToolFromTemplate,
+			Description: "Instantiate a built-in template, creating every block in the pattern.",
+			Parameters: objectSchema(map[string]interface{}{
+				"template": map[string]interface{}{"type": "string", "enum": templates},
+				"values": map[string]interface{}{"type": "object", "description": "State overrides keyed by step alias",
+					"additionalProperties": map[string]interface{}{"type": "object"}},
+			}, "template"),
+		},
+		{
+			Name:        ToolAttest,
+			Description: "Attest that a block's claim is true.",
+			Parameters: objectSchema(map[string]interface{}{
+				"target":     hash,
+				"attestor":   hash,
+				"confidence": map[string]interface{}{"type": "string", "enum": AttestationConfidences},
+				"method":     map[string]interface{}{"type": "string"},
+			}, "target", "attestor"),
+		},
+		{
+			Name:        ToolTransitionOrder,
+			Description: "Move an order to a new workflow status, enforcing allowed transitions and guards.",
+			Parameters: objectSchema(map[string]interface{}{
+				"order":  hash,
+				"status": map[string]interface{}{"type": "string", "enum": statuses},
+				"reason": map[string]interface{}{"type": "string"},
+			}, "order", "status"),
+		},
+	}
+}
+
+func objectSchema(props map[string]interface{}, required ...string) map[string]interface{} {
+	s := map[string]interface{}{"type": "object", "properties": props, "additionalProperties": false}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+// knownBlockTypes collects every type named by a vocabulary, template or core schema.
+func knownBlockTypes() []string {
+	seen := map[string]bool{}
+	for _, v := range Vocabularies {
+		for _, t := range v.ForTypes {
+			seen[t] = true
+		}
+	}
+	for _, t := range Templates {
+		for _, s := range t.Steps {
+			seen[s.Type] = true
+		}
+	}
+	for _, s := range CoreSchemas {
+		seen[s.TargetType] = true
+	}
+	out := make([]string, 0, len(seen))
+	for t := range seen {
+		out = append(out, t)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ValidateToolCall checks arguments against a tool's parameter schema and
+// returns any violations.
+func ValidateToolCall(tool ToolSchema, args map[string]interface{}) []string {
+	return validateSchemaValue("", tool.Parameters, args)
+}
+
+func validateSchemaValue(path string, schema map[string]interface{}, v interface{}) []string {
+	var errs []string
+	name := path
+	if name == "" {
+		name = "arguments"
+	}
+	if options, ok := schema["oneOf"].([]interface{}); ok {
+		for _, o := range options {
+			if len(validateSchemaValue(path, o.(map[string]interface{}), v)) == 0 {
+				return nil
+			}
+		}
+		return []string{name + ": does not match any allowed form"}
+	}
+	switch schema["type"] {
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return []string{name + ": expected object"}
+		}
+		if req, ok := schema["required"].([]string); ok {
+			for _, r := range req {
+				if _, present := obj[r]; !present {
+					errs = append(errs, join(path, r)+": required")
+				}
+			}
+		}
+		props, _ := schema["properties"].(map[string]interface{})
+		extra, _ := schema["additionalProperties"].(map[string]interface{})
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if p, ok := props[k].(map[string]interface{}); ok {
+				errs = append(errs, validateSchemaValue(join(path, k), p, obj[k])...)
+			} else if extra != nil {
+				errs = append(errs, validateSchemaValue(join(path, k), extra, obj[k])...)
+			} else if schema["additionalProperties"] == false {
+				errs = append(errs, join(path, k)+": unknown field")
+			}
+		}
+	case "array":
+		arr, ok := v.([]interface{})
+		if !ok {
+			return []string{name + ": expected array"}
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range arr {
+				errs = append(errs, validateSchemaValue(fmt.Sprintf("%s[%d]", name, i), items, item)...)
+			}
+		}
+	case "string":
+		s, ok := v.(string)
+		if !ok {
+			return []string{name + ": expected string"}
+		}
+		if enum, ok := schema["enum"].([]string); ok && !containsStr(enum, s) {
+			errs = append(errs, fmt.Sprintf("%s: %q is not one of the allowed values", name, s))
+		}
+		if pattern, ok := schema["pattern"].(string); ok && pattern == "^[0-9a-f]{64}$" && !isHexHash(s) {
+			errs = append(errs, name+": expected a 64-character hex hash")
+		}
+	case "integer":
+		f, ok := toFloat64(v)
+		if !ok || f != float64(int64(f)) {
+			return []string{name + ": expected integer"}
+		}
+		if min, ok := schema["minimum"].(int); ok && f < float64(min) {
+			errs = append(errs, fmt.Sprintf("%s: must be at least %d", name, min))
+		}
+		if max, ok := schema["maximum"].(int); ok && f > float64(max) {
+			errs = append(errs, fmt.Sprintf("%s: must be at most %d", name, max))
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return []string{name + ": expected boolean"}
+		}
+	}
+	return errs
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func isHexHash(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// ToolDispatcher maps validated tool calls onto SDK operations.
+type ToolDispatcher struct {
+	Store BlockStore
+	// Persist stores created blocks in Store. Without it the dispatcher only
+	// returns the blocks it would create.
+	Persist bool
+}
+
+// Dispatch validates a tool call and executes it, returning the blocks
+// created or found.
+func (d *ToolDispatcher) Dispatch(name string, rawArgs json.RawMessage) ([]Block, error) {
+	var tool *ToolSchema
+	for _, t := range ExportToolSchemas() {
+		if t.Name == name {
+			t := t
+			tool = &t
+			break
+		}
+	}
+	if tool == nil {
+		return nil, errors.New("FoodBlock: unknown tool: " + name)
+	}
+	args := map[string]interface{}{}
+	if len(rawArgs) > 0 {
+		if err := json.Unmarshal(rawArgs, &args); err != nil {
+			return nil, fmt.Errorf("FoodBlock: invalid tool arguments: %w", err)
+		}
+	}
+	if errs := ValidateToolCall(*tool, args); len(errs) > 0 {
+		return nil, fmt.Errorf("FoodBlock: invalid tool arguments: %v", errs)
+	}
+
+	var out []Block
+	switch name {
+	case ToolCreateBlock:
+		state, _ := args["state"].(map[string]interface{})
+		refs, _ := args["refs"].(map[string]interface{})
+		out = []Block{Create(args["type"].(string), state, refs)}
+	case ToolQueryBlocks:
+		return d.query(args)
+	case ToolFromTemplate:
+		values := map[string]StepOverrides{}
+		if raw, ok := args["values"].(map[string]interface{}); ok {
+			for alias, v := range raw {
+				values[alias] = StepOverrides{State: v.(map[string]interface{})}
+			}
+		}
+		out = FromTemplate(Templates[args["template"].(string)], values)
+	case ToolAttest:
+		confidence, _ := args["confidence"].(string)
+		method, _ := args["method"].(string)
+		b, err := Attest(args["target"].(string), args["attestor"].(string), confidence, method)
+		if err != nil {
+			return nil, err
+		}
+		out = []Block{b}
+	case ToolTransitionOrder:
+		b, err := d.transition(args)
+		if err != nil {
+			return nil, err
+		}
+		out = []Block{b}
+	}
+
+	if d.Persist && d.Store != nil {
+		for _, b := range out {
+			if err := d.Store.Put(b); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return out, nil
+}
+
+func (d *ToolDispatcher) query(args map[string]interface{}) ([]Block, error) {
+	if d.Store == nil {
+		return nil, errors.New("FoodBlock: query requires a store")
+	}
+	params := QueryParams{Refs: map[string]string{}, Limit: 50}
+	params.Type, _ = args["type"].(string)
+	if refs, ok := args["refs"].(map[string]interface{}); ok {
+		for role, h := range refs {
+			params.Refs[role] = h.(string)
+		}
+	}
+	if where, ok := args["where"].(map[string]interface{}); ok {
+		fields := make([]string, 0, len(where))
+		for f := range where {
+			fields = append(fields, f)
+		}
+		sort.Strings(fields)
+		for _, f := range fields {
+			params.StateFilters = append(params.StateFilters, StateFilter{Field: f, Op: "eq", Value: where[f]})
+		}
+	}
+	params.HeadsOnly, _ = args["latest"].(bool)
+	params.IncludeExpired, _ = args["include_expired"].(bool)
+	if limit, ok := toFloat64(args["limit"]); ok {
+		params.Limit = int(limit)
+	}
+	if q, ok := d.Store.(interface {
+		Query(QueryParams) ([]Block, error)
+	}); ok {
+		return q.Query(params)
+	}
+	return FilterBlocks(d.Store.Blocks(), params), nil
+}
+
+func (d *ToolDispatcher) transition(args map[string]interface{}) (Block, error) {
+	if d.Store == nil {
+		return Block{}, errors.New("FoodBlock: transition requires a store")
+	}
+	order := d.Store.Resolve(args["order"].(string))
+	if order == nil {
+		return Block{}, errors.New("FoodBlock: order not found: " + args["order"].(string))
+	}
+	to := args["status"].(string)
+	check := CheckTransition(Vocabularies["workflow"], *order, to, d.Store.Blocks(), signerLookup(d.Store))
+	if !check.Allowed {
+		return Block{}, errors.New("FoodBlock: " + check.Reason)
+	}
+	changes := map[string]interface{}{"status": to, "previous_status": check.From}
+	if reason, ok := args["reason"].(string); ok && reason != "" {
+		changes["reason"] = reason
+	}
+	return MergeUpdate(*order, changes, nil), nil
+}
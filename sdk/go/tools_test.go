@@ -0,0 +1,96 @@
+package foodblock
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func findTool(t *testing.T, name string) ToolSchema {
+	for _, tool := range ExportToolSchemas() {
+		if tool.Name == name {
+			return tool
+		}
+	}
+	t.Fatalf("tool %s not exported", name)
+	return ToolSchema{}
+}
+
+func TestExportToolSchemasJSON(t *testing.T) {
+	data, err := json.Marshal(ExportToolSchemas())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded []map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil || len(decoded) != 5 {
+		t.Fatalf("decoded %d tools, err %v", len(decoded), err)
+	}
+	status := findTool(t, ToolTransitionOrder).Parameters["properties"].(map[string]interface{})["status"].(map[string]interface{})
+	if !containsStr(status["enum"].([]string), "confirmed") {
+		t.Error("status enum missing workflow states")
+	}
+}
+
+func TestValidateToolCall(t *testing.T) {
+	tool := findTool(t, ToolCreateBlock)
+	if errs := ValidateToolCall(tool, map[string]interface{}{"type": "substance.product", "state": map[string]interface{}{"name": "Bread"}}); len(errs) != 0 {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+	errs := ValidateToolCall(tool, map[string]interface{}{"type": "substance.spaceship", "colour": "red"})
+	if len(errs) != 2 {
+		t.Errorf("errors = %v, want enum and unknown-field violations", errs)
+	}
+	if errs := ValidateToolCall(tool, map[string]interface{}{"type": "substance.product", "refs": map[string]interface{}{"seller": "nope"}}); len(errs) != 1 {
+		t.Errorf("bad ref hash errors = %v", errs)
+	}
+}
+
+func TestDispatchTransitionAndQuery(t *testing.T) {
+	store := NewMemoryStore()
+	d := &ToolDispatcher{Store: store, Persist: true}
+
+	created, err := d.Dispatch(ToolCreateBlock, json.RawMessage(`{"type":"transfer.order","state":{"status":"order","total":12}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := created[0]
+
+	if _, err := d.Dispatch(ToolTransitionOrder, json.RawMessage(`{"order":"`+order.Hash+`","status":"confirmed"}`)); err == nil {
+		t.Fatal("expected payment_ref guard to block confirmation")
+	}
+	if _, err := d.Dispatch(ToolTransitionOrder, json.RawMessage(`{"order":"`+order.Hash+`","status":"paid"}`)); err == nil {
+		t.Fatal("expected undefined transition to fail")
+	}
+	cancelled, err := d.Dispatch(ToolTransitionOrder, json.RawMessage(`{"order":"`+order.Hash+`","status":"cancelled","reason":"customer request"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cancelled[0].State["status"] != "cancelled" || cancelled[0].Refs["updates"] != order.Hash {
+		t.Errorf("transition block = %+v", cancelled[0])
+	}
+
+	found, err := d.Dispatch(ToolQueryBlocks, json.RawMessage(`{"type":"transfer.*","latest":true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].Hash != cancelled[0].Hash {
+		t.Errorf("latest query = %d blocks", len(found))
+	}
+	found, _ = d.Dispatch(ToolQueryBlocks, json.RawMessage(`{"where":{"total":12}}`))
+	if len(found) != 2 {
+		t.Errorf("where query = %d blocks, want both versions", len(found))
+	}
+}
+
+func TestDispatchRejectsInvalidCalls(t *testing.T) {
+	d := &ToolDispatcher{}
+	if _, err := d.Dispatch("delete_everything", nil); err == nil {
+		t.Error("expected unknown tool error")
+	}
+	if _, err := d.Dispatch(ToolAttest, json.RawMessage(`{"target":"abc"}`)); err == nil {
+		t.Error("expected validation error")
+	}
+	blocks, err := d.Dispatch(ToolFromTemplate, json.RawMessage(`{"template":"review","values":{"venue":{"name":"Cafe"},"product":{"name":"Tea"},"review":{"rating":5}}}`))
+	if err != nil || len(blocks) != 3 {
+		t.Errorf("template dispatch = %d blocks, err %v", len(blocks), err)
+	}
+}
@@ -1,6 +1,7 @@
 package foodblock
 
 import (
+	"fmt"
 	"math"
 	"strings"
 	"time"
@@ -13,29 +14,79 @@ var DefaultWeights = map[string]float64{
 	"chain_depth":     2.0,
 	"verified_orders": 1.5,
 	"account_age":     0.5,
+	"disputes":        -2.0,
+}
+
+// DisputeSeverities maps a dispute's state.severity label to its weight.
+// Disputes without a severity count as 1; numeric severities are clamped to
+// between 0 and the heaviest label's weight.
+var DisputeSeverities = map[string]float64{
+	"low":      0.5,
+	"medium":   1.0,
+	"high":     2.0,
+	"critical": 4.0,
 }
 
 // PeerReviewResult holds the peer review sub-score.
 type PeerReviewResult struct {
-	Count         int     `json:"count"`
-	AvgScore      float64 `json:"avg_score"`
-	WeightedScore float64 `json:"weighted_score"`
+	Count         int            `json:"count"`
+	AvgScore      float64        `json:"avg_score"`
+	WeightedScore float64        `json:"weighted_score"`
+	Reviews       []ReviewWeight `json:"reviews,omitempty"`
+}
+
+// ReviewWeight explains how much one review contributed.
+type ReviewWeight struct {
+	Review       string  `json:"review"`
+	Reviewer     string  `json:"reviewer"`
+	Rating       float64 `json:"rating"`
+	Independence float64 `json:"independence"`         // 1 - connection density with the subject
+	Reputation   float64 `json:"reputation,omitempty"` // reviewer trust mapped to 0..1, when enabled
+	Weight       float64 `json:"weight"`
 }
 
-// TrustInputs holds the five raw trust inputs.
+// Reviewer reputation defaults. A reviewer's trust score s maps to a
+// reputation of s/(s+ReputationHalfScore); with damping d the review weight is
+// scaled by (1-d) + d*reputation, so an unknown reviewer still counts for
+// 1-d of an established one.
+const (
+	DefaultReputationDamping = 0.5
+	ReputationHalfScore      = 10.0
+)
+
+// OrderValueScale is the order total (in the reporting currency) at which
+// value weighting starts to matter: with policy "order_value_weighting" an
+// order counts 1 + ln(1 + total/OrderValueScale) instead of 1.
+const OrderValueScale = 100.0
+
+// DisputeResult holds the open disputes against an actor.
+type DisputeResult struct {
+	Count    int      `json:"count"`
+	Severity float64  `json:"severity"` // summed severity of open disputes
+	Disputes []string `json:"disputes,omitempty"`
+}
+
+// TrustInputs holds the raw trust inputs.
 type TrustInputs struct {
 	AuthorityCerts int              `json:"authority_certs"`
+	RevokedCerts   []string         `json:"revoked_certs,omitempty"` // certifications withdrawn by their authority
 	PeerReviews    PeerReviewResult `json:"peer_reviews"`
 	ChainDepth     int              `json:"chain_depth"`
 	VerifiedOrders int              `json:"verified_orders"`
+	VerifiedVolume float64          `json:"verified_volume"`       // summed order totals in the reporting currency
+	OrderValue     float64          `json:"order_value,omitempty"` // value-weighted order count, when enabled
 	AccountAge     float64          `json:"account_age"`
+	Disputes       DisputeResult    `json:"disputes"`
+	Quality        int              `json:"quality"` // accepted minus rejected inspections (observe.grading); weighted by the reliability profile, not by default
 }
 
-// TrustResult is the output of ComputeTrust.
+// TrustResult is the output of ComputeTrust. Contributions holds each
+// input's weighted share of Score, keyed like DefaultWeights.
 type TrustResult struct {
-	Score        float64     `json:"score"`
-	Inputs       TrustInputs `json:"inputs"`
-	MeetsMinimum bool        `json:"meets_minimum"`
+	Score         float64            `json:"score"`
+	Inputs        TrustInputs        `json:"inputs"`
+	Contributions map[string]float64 `json:"contributions"`
+	MeetsMinimum  bool               `json:"meets_minimum"`
 }
 
 // TrustBlock extends Block with optional metadata used by trust computation.
@@ -45,8 +96,9 @@ type TrustBlock struct {
 	CreatedAt  string `json:"created_at,omitempty"`
 }
 
-// ComputeTrust computes a trust score for an actor from five inputs
-// derived from the FoodBlock graph. Supports custom trust policies.
+// ComputeTrust computes a trust score for an actor from inputs derived from
+// the FoodBlock graph: five positive signals and open disputes, which weigh
+// negatively by default. Supports custom trust policies.
 func ComputeTrust(actorHash string, blocks []TrustBlock, policy map[string]interface{}) TrustResult {
 	if actorHash == "" {
 		panic("FoodBlock: actorHash is required")
@@ -56,26 +108,50 @@ func ComputeTrust(actorHash string, blocks []TrustBlock, policy map[string]inter
 	now := time.Now()
 
 	var requiredAuthorities []string
-	if ra, ok := policy["required_authorities"]; ok {
-		if arr, ok := ra.([]string); ok {
-			requiredAuthorities = arr
+	switch ra := policy["required_authorities"].(type) {
+	case []string:
+		requiredAuthorities = ra
+	case []interface{}:
+		for _, a := range ra {
+			if s, ok := a.(string); ok {
+				requiredAuthorities = append(requiredAuthorities, s)
+			}
 		}
 	}
 
+	requireDomainProof, _ := policy["require_domain_proof"].(bool)
+	orders := computeVerifiedOrders(actorHash, blocks, policy)
+	certs, revoked := countAuthorityCerts(actorHash, blocks, requiredAuthorities, requireDomainProof)
 	inputs := TrustInputs{
-		AuthorityCerts: countAuthorityCerts(actorHash, blocks, requiredAuthorities),
-		PeerReviews:    computePeerReviews(actorHash, blocks),
+		AuthorityCerts: certs,
+		RevokedCerts:   revoked,
+		PeerReviews:    computePeerReviews(actorHash, blocks, policy),
 		ChainDepth:     computeChainDepth(actorHash, blocks),
-		VerifiedOrders: countVerifiedOrders(actorHash, blocks),
+		VerifiedOrders: orders.count,
+		VerifiedVolume: orders.volume,
 		AccountAge:     computeAccountAge(actorHash, blocks, now),
+		Disputes:       computeDisputes(actorHash, blocks),
+		Quality:        computeQuality(actorHash, blocks),
+	}
+	orderScore := float64(inputs.VerifiedOrders)
+	if orders.weighted {
+		inputs.OrderValue = orders.value
+		orderScore = orders.value
 	}
 
-	score :=
-		float64(inputs.AuthorityCerts)*weights["authority_certs"] +
-			inputs.PeerReviews.WeightedScore*weights["peer_reviews"] +
-			float64(inputs.ChainDepth)*weights["chain_depth"] +
-			float64(inputs.VerifiedOrders)*weights["verified_orders"] +
-			inputs.AccountAge*weights["account_age"]
+	contributions := map[string]float64{
+		"authority_certs": float64(inputs.AuthorityCerts) * weights["authority_certs"],
+		"peer_reviews":    inputs.PeerReviews.WeightedScore * weights["peer_reviews"],
+		"chain_depth":     float64(inputs.ChainDepth) * weights["chain_depth"],
+		"verified_orders": orderScore * weights["verified_orders"],
+		"account_age":     inputs.AccountAge * weights["account_age"],
+		"disputes":        inputs.Disputes.Severity * weights["disputes"],
+		"quality":         float64(inputs.Quality) * weights["quality"],
+	}
+	score := 0.0
+	for _, k := range trustInputOrder {
+		score += contributions[k]
+	}
 
 	minScore := 0.0
 	if ms, ok := policy["min_score"]; ok {
@@ -88,12 +164,43 @@ func ComputeTrust(actorHash string, blocks []TrustBlock, policy map[string]inter
 	}
 
 	return TrustResult{
-		Score:        score,
-		Inputs:       inputs,
-		MeetsMinimum: score >= minScore,
+		Score:         score,
+		Inputs:        inputs,
+		Contributions: contributions,
+		MeetsMinimum:  score >= minScore,
 	}
 }
 
+// trustInputOrder fixes the summation and explanation order of inputs.
+var trustInputOrder = []string{"authority_certs", "peer_reviews", "chain_depth", "verified_orders", "account_age", "disputes", "quality"}
+
+// ExplainTrust renders a trust result as one line per input with its weighted
+// contribution, e.g. for showing a user why an actor scored as it did.
+func ExplainTrust(r TrustResult) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Trust score %.2f (meets minimum: %t)\n", r.Score, r.MeetsMinimum)
+	in := r.Inputs
+	details := map[string]string{
+		"authority_certs": fmt.Sprintf("%d certification(s)", in.AuthorityCerts),
+		"peer_reviews":    fmt.Sprintf("%d review(s), avg %.1f", in.PeerReviews.Count, in.PeerReviews.AvgScore),
+		"chain_depth":     fmt.Sprintf("%d distinct counterpart(s)", in.ChainDepth),
+		"verified_orders": fmt.Sprintf("%d order(s), volume %.2f", in.VerifiedOrders, in.VerifiedVolume),
+		"account_age":     fmt.Sprintf("%.0f day(s)", in.AccountAge),
+		"disputes":        fmt.Sprintf("%d open dispute(s), severity %.1f", in.Disputes.Count, in.Disputes.Severity),
+		"quality":         fmt.Sprintf("net %+d accepted inspection(s)", in.Quality),
+	}
+	if n := len(in.RevokedCerts); n > 0 {
+		details["authority_certs"] += fmt.Sprintf(", %d revoked", n)
+	}
+	for _, k := range trustInputOrder {
+		fmt.Fprintf(&sb, "  %-16s %+8.2f  %s\n", k, r.Contributions[k], details[k])
+	}
+	for _, h := range in.RevokedCerts {
+		fmt.Fprintf(&sb, "  revoked certification %s\n", h)
+	}
+	return sb.String()
+}
+
 // ConnectionDensity measures connection density between two actors (Section 6.3 sybil resistance).
 // Returns 0..1 where 0 = no shared refs, 1 = fully connected.
 func ConnectionDensity(actorA, actorB string, blocks []TrustBlock) float64 {
@@ -166,6 +273,14 @@ func CreateTrustPolicy(name string, weights map[string]interface{}, opts map[str
 		if ms, ok := opts["min_score"]; ok {
 			state["min_score"] = ms
 		}
+		if profiles, ok := opts["profiles"]; ok {
+			state["profiles"] = profiles
+		}
+		for _, k := range []string{"order_value_weighting", "reporting_currency", "exchange_rates", "require_domain_proof"} {
+			if v, ok := opts[k]; ok {
+				state[k] = v
+			}
+		}
 	}
 
 	refs := map[string]interface{}{}
@@ -175,6 +290,12 @@ func CreateTrustPolicy(name string, weights map[string]interface{}, opts map[str
 				refs["author"] = s
 			}
 		}
+		switch ext := opts["extends"].(type) {
+		case string:
+			refs["extends"] = ext
+		case []interface{}:
+			refs["extends"] = ext
+		}
 	}
 
 	return Create("observe.trust_policy", state, refs)
@@ -208,7 +329,23 @@ func mergeWeights(policy map[string]interface{}) map[string]float64 {
 	return result
 }
 
-func countAuthorityCerts(actorHash string, blocks []TrustBlock, requiredAuthorities []string) int {
+// countAuthorityCerts counts unexpired certifications of the actor, leaving
+// out and returning those revoked by their authority. When
+// requiredAuthorities is not empty, only certifications issued by one of
+// them count. With requireDomainProof, only certifications whose authority has an
+// observe.domain_proof count, and one covering the scheme's domains when the
+// certification names a registered scheme.
+func countAuthorityCerts(actorHash string, blocks []TrustBlock, requiredAuthorities []string, requireDomainProof bool) (int, []string) {
+	withdrawn := revokedHashes(blocks)
+	var revoked []string
+	var proofs []Block
+	if requireDomainProof {
+		for _, b := range blocks {
+			if b.Type == DomainProofType {
+				proofs = append(proofs, b.Block)
+			}
+		}
+	}
 	count := 0
 	for _, b := range blocks {
 		if b.Type != "observe.certification" {
@@ -221,6 +358,9 @@ func countAuthorityCerts(actorHash string, blocks []TrustBlock, requiredAuthorit
 		if subject != actorHash {
 			continue
 		}
+		if authority, _ := b.Refs["authority"].(string); len(requiredAuthorities) > 0 && !containsStr(requiredAuthorities, authority) {
+			continue
+		}
 		if vu, ok := b.State["valid_until"].(string); ok {
 			t, err := time.Parse(time.RFC3339, vu)
 			if err != nil {
@@ -230,12 +370,26 @@ func countAuthorityCerts(actorHash string, blocks []TrustBlock, requiredAuthorit
 				continue
 			}
 		}
+		if requireDomainProof {
+			authority, _ := b.Refs["authority"].(string)
+			if len(ProvenDomains(authority, proofs)) == 0 || ValidateCertificationAuthority(b.Block, proofs) != nil {
+				continue
+			}
+		}
+		if _, ok := withdrawn[b.Hash]; ok {
+			revoked = append(revoked, b.Hash)
+			continue
+		}
 		count++
 	}
-	return count
+	return count, revoked
 }
 
-func computePeerReviews(actorHash string, blocks []TrustBlock) PeerReviewResult {
+// computePeerReviews scores reviews of an actor, discounting reviewers closely
+// connected to it. With policy "reviewer_reputation" set, each review is also
+// scaled by the reviewer's own trust, computed without reputation weighting so
+// scores cannot feed back into each other ("reputation_damping" sets d).
+func computePeerReviews(actorHash string, blocks []TrustBlock, policy map[string]interface{}) PeerReviewResult {
 	var reviews []TrustBlock
 	for _, b := range blocks {
 		if b.Type != "observe.review" {
@@ -258,8 +412,25 @@ func computePeerReviews(actorHash string, blocks []TrustBlock) PeerReviewResult
 		return PeerReviewResult{}
 	}
 
+	useReputation, _ := policy["reviewer_reputation"].(bool)
+	damping := DefaultReputationDamping
+	if d, ok := toFloat64(policy["reputation_damping"]); ok {
+		damping = math.Max(0, math.Min(1, d))
+	}
+	var reviewerPolicy map[string]interface{}
+	if useReputation {
+		reviewerPolicy = make(map[string]interface{}, len(policy))
+		for k, v := range policy {
+			reviewerPolicy[k] = v
+		}
+		reviewerPolicy["reviewer_reputation"] = false
+	}
+	reputations := map[string]float64{}
+
 	totalWeighted := 0.0
 	totalWeight := 0.0
+	effectiveCount := 0.0 // reviews counted at their reputation factor
+	details := make([]ReviewWeight, 0, len(reviews))
 
 	for _, review := range reviews {
 		reviewerHash := ""
@@ -270,26 +441,45 @@ func computePeerReviews(actorHash string, blocks []TrustBlock) PeerReviewResult
 		}
 		density := ConnectionDensity(reviewerHash, actorHash, blocks)
 		weight := 1 - density
-		rating := toFloat64(review.State["rating"])
+		rating, _ := toFloat64(review.State["rating"])
+		detail := ReviewWeight{Review: review.Hash, Reviewer: reviewerHash, Rating: rating, Independence: weight}
+		if useReputation && reviewerHash != "" {
+			rep, ok := reputations[reviewerHash]
+			if !ok {
+				score := math.Max(0, ComputeTrust(reviewerHash, blocks, reviewerPolicy).Score)
+				rep = score / (score + ReputationHalfScore)
+				reputations[reviewerHash] = rep
+			}
+			detail.Reputation = rep
+			factor := (1 - damping) + damping*rep
+			weight *= factor
+			effectiveCount += factor
+		} else {
+			effectiveCount++
+		}
+		detail.Weight = weight
+		details = append(details, detail)
 		totalWeighted += (rating / 5.0) * weight
 		totalWeight += weight
 	}
 
 	sum := 0.0
 	for _, r := range reviews {
-		sum += toFloat64(r.State["rating"])
+		rating, _ := toFloat64(r.State["rating"])
+		sum += rating
 	}
 	avgScore := sum / float64(len(reviews))
 
 	weightedScore := 0.0
 	if totalWeight > 0 {
-		weightedScore = totalWeighted / totalWeight * float64(len(reviews))
+		weightedScore = totalWeighted / totalWeight * effectiveCount
 	}
 
 	return PeerReviewResult{
 		Count:         len(reviews),
 		AvgScore:      avgScore,
 		WeightedScore: weightedScore,
+		Reviews:       details,
 	}
 }
 
@@ -321,10 +511,38 @@ func computeChainDepth(actorHash string, blocks []TrustBlock) int {
 	return len(authors)
 }
 
-func countVerifiedOrders(actorHash string, blocks []TrustBlock) int {
-	count := 0
+type verifiedOrders struct {
+	count    int
+	volume   float64
+	value    float64
+	weighted bool
+}
+
+// computeVerifiedOrders counts orders with an adapter or payment reference
+// and totals their value in the policy's "reporting_currency" (default USD).
+// Orders in another currency are converted with "exchange_rates" (an
+// ExchangeRates, or a map of units per reporting-currency unit); orders that
+// cannot be converted still count but add no volume and weigh 1. Only the
+// latest version of each order counts.
+func computeVerifiedOrders(actorHash string, blocks []TrustBlock, policy map[string]interface{}) verifiedOrders {
+	result := verifiedOrders{}
+	result.weighted, _ = policy["order_value_weighting"].(bool)
+	currency, _ := policy["reporting_currency"].(string)
+	if currency == "" {
+		currency = "USD"
+	}
+	rates := policyRates(policy, currency)
+
+	superseded := map[string]bool{}
 	for _, b := range blocks {
-		if !strings.HasPrefix(b.Type, "transfer.order") {
+		if strings.HasPrefix(b.Type, "transfer.order") {
+			if prev, ok := b.Refs["updates"].(string); ok {
+				superseded[prev] = true
+			}
+		}
+	}
+	for _, b := range blocks {
+		if !strings.HasPrefix(b.Type, "transfer.order") || superseded[b.Hash] {
 			continue
 		}
 		if b.Refs == nil {
@@ -337,11 +555,130 @@ func countVerifiedOrders(actorHash string, blocks []TrustBlock) int {
 		}
 		_, hasAdapterRef := b.State["adapter_ref"]
 		_, hasPaymentRef := b.State["payment_ref"]
-		if hasAdapterRef || hasPaymentRef {
-			count++
+		if !hasAdapterRef && !hasPaymentRef {
+			continue
 		}
+		result.count++
+		weight := 1.0
+		if amount, ok := orderTotal(b.Block, currency, rates); ok && amount > 0 {
+			result.volume += amount
+			weight += math.Log1p(amount / OrderValueScale)
+		}
+		result.value += weight
 	}
-	return count
+	return result
+}
+
+// orderTotal reads an order's total converted into the reporting currency.
+func orderTotal(b Block, currency string, rates ExchangeRates) (float64, bool) {
+	amount, ok := blockAmount(b)
+	if !ok {
+		return 0, false
+	}
+	from, _ := b.State["currency"].(string)
+	if from == "" || strings.EqualFold(from, currency) {
+		return amount, true
+	}
+	if rates == nil {
+		return 0, false
+	}
+	rate, err := rates.Rate(from, currency, "")
+	if err != nil {
+		return 0, false
+	}
+	return amount * rate, true
+}
+
+func policyRates(policy map[string]interface{}, currency string) ExchangeRates {
+	switch r := policy["exchange_rates"].(type) {
+	case ExchangeRates:
+		return r
+	case map[string]float64:
+		return StaticRates{Base: currency, Rates: r}
+	case map[string]interface{}:
+		rates := make(map[string]float64, len(r))
+		for k, v := range r {
+			if f, ok := toFloat64(v); ok {
+				rates[strings.ToUpper(k)] = f
+			}
+		}
+		return StaticRates{Base: currency, Rates: rates}
+	}
+	return nil
+}
+
+// computeDisputes collects observe.dispute blocks that challenge the actor, or
+// challenge a block referencing the actor (such as an order it sold), raised by
+// someone else. Disputes whose latest version has status "resolved" or
+// "rejected" are excluded.
+func computeDisputes(actorHash string, blocks []TrustBlock) DisputeResult {
+	byHash := make(map[string]*TrustBlock, len(blocks))
+	superseded := map[string]bool{}
+	for i := range blocks {
+		byHash[blocks[i].Hash] = &blocks[i]
+		if blocks[i].Type == "observe.dispute" {
+			if prev, ok := blocks[i].Refs["updates"].(string); ok {
+				superseded[prev] = true
+			}
+		}
+	}
+
+	result := DisputeResult{}
+	for _, b := range blocks {
+		if b.Type != "observe.dispute" || b.Refs == nil || superseded[b.Hash] {
+			continue
+		}
+		if status, _ := b.State["status"].(string); status == "resolved" || status == "rejected" {
+			continue
+		}
+		disputor, _ := b.Refs["disputor"].(string)
+		if disputor == actorHash {
+			continue
+		}
+		target, _ := b.Refs["challenges"].(string)
+		if target != actorHash {
+			challenged, ok := byHash[target]
+			if !ok || challenged.Refs == nil || !containsStr(flattenRefValues(challenged.Refs), actorHash) {
+				continue
+			}
+		}
+		severity := 1.0
+		switch v := b.State["severity"].(type) {
+		case string:
+			if w, ok := DisputeSeverities[strings.ToLower(v)]; ok {
+				severity = w
+			}
+		default:
+			if f, ok := toFloat64(v); ok {
+				severity = math.Max(0, math.Min(f, maxDisputeSeverity()))
+			}
+		}
+		result.Count++
+		result.Severity += severity
+		result.Disputes = append(result.Disputes, b.Hash)
+	}
+	return result
+}
+
+// maxDisputeSeverity is the heaviest weight in DisputeSeverities.
+func maxDisputeSeverity() float64 {
+	max := 0.0
+	for _, w := range DisputeSeverities {
+		max = math.Max(max, w)
+	}
+	return max
+}
+
+// computeQuality nets accepted against rejected inspections of the actor.
+func computeQuality(actorHash string, blocks []TrustBlock) int {
+	var plain []Block
+	for _, b := range blocks {
+		if b.Type == GradingType || strings.HasPrefix(b.Type, "transfer.order") {
+			plain = append(plain, b.Block)
+		}
+	}
+	stats := SupplierGradingStats(actorHash, plain)
+	return stats.Accepted - stats.Rejected
 }
 
 func computeAccountAge(actorHash string, blocks []TrustBlock, now time.Time) float64 {
@@ -386,15 +723,3 @@ func containsStr(slice []string, s string) bool {
 	}
 	return false
 }
-
-func toFloat64(v interface{}) float64 {
-	switch n := v.(type) {
-	case float64:
-		return n
-	case int:
-		return float64(n)
-	case int64:
-		return float64(n)
-	}
-	return 0
-}
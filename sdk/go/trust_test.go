@@ -1,6 +1,8 @@
 package foodblock
 
 import (
+	"math"
+	"strings"
 	"testing"
 	"time"
 )
@@ -107,6 +109,135 @@ func TestComputeTrustVerifiedOrders(t *testing.T) {
 	}
 }
 
+func TestComputeTrustVerifiedOrdersCountsHeads(t *testing.T) {
+	buyer := trustActor("Restaurant")
+	seller := trustActor("Supplier")
+	order := Create("transfer.order", map[string]interface{}{
+		"instance_id": "o1", "total": 100.0, "payment_ref": "pi_o1",
+	}, map[string]interface{}{"buyer": buyer.Hash, "seller": seller.Hash})
+	grading := Create(GradingType, map[string]interface{}{"decision": "accepted", "grade": "A"}, map[string]interface{}{"order": order.Hash})
+	decided, err := DecideOrder(order, grading)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks := []TrustBlock{buyer, seller, {Block: order}, {Block: decided}}
+
+	result := ComputeTrust(seller.Hash, blocks, map[string]interface{}{})
+	if result.Inputs.VerifiedOrders != 1 || result.Inputs.VerifiedVolume != 100 {
+		t.Errorf("expected one $100 order, got %d orders worth %v", result.Inputs.VerifiedOrders, result.Inputs.VerifiedVolume)
+	}
+}
+
+func TestComputeTrustOrderValueWeighting(t *testing.T) {
+	buyer := trustActor("Restaurant")
+	small := trustActor("Stall")
+	large := trustActor("Wholesaler")
+	order := func(seller, id string, total float64, currency string) TrustBlock {
+		b := Create("transfer.order", map[string]interface{}{
+			"instance_id": id, "total": total, "currency": currency, "payment_ref": "pi_" + id,
+		}, map[string]interface{}{"buyer": buyer.Hash, "seller": seller})
+		return TrustBlock{Block: b}
+	}
+	blocks := []TrustBlock{buyer, small, large,
+		order(small.Hash, "o1", 5, "USD"),
+		order(large.Hash, "o2", 40000, "GBP"),
+	}
+	policy := map[string]interface{}{
+		"order_value_weighting": true,
+		"exchange_rates":        map[string]interface{}{"GBP": 0.8},
+	}
+
+	rates := map[string]interface{}{"exchange_rates": policy["exchange_rates"]}
+	flat := ComputeTrust(large.Hash, blocks, rates)
+	if flat.Inputs.VerifiedVolume != 50000 {
+		t.Errorf("expected 50000 USD volume, got %v", flat.Inputs.VerifiedVolume)
+	}
+	if flat.Inputs.OrderValue != 0 {
+		t.Error("order value should only be reported when weighting is enabled")
+	}
+	if ComputeTrust(small.Hash, blocks, rates).Score != flat.Score {
+		t.Error("without weighting both sellers should score the same")
+	}
+
+	smallRes := ComputeTrust(small.Hash, blocks, policy)
+	largeRes := ComputeTrust(large.Hash, blocks, policy)
+	if smallRes.Inputs.VerifiedOrders != 1 || largeRes.Inputs.VerifiedOrders != 1 {
+		t.Fatal("both sellers should have one verified order")
+	}
+	if want := 1 + math.Log1p(500); math.Abs(largeRes.Inputs.OrderValue-want) > 1e-9 {
+		t.Errorf("expected order value %v, got %v", want, largeRes.Inputs.OrderValue)
+	}
+	if largeRes.Score <= smallRes.Score {
+		t.Errorf("large order should outweigh small one: %v <= %v", largeRes.Score, smallRes.Score)
+	}
+
+	// Without a rate the GBP order still counts, but carries no value.
+	noRates := ComputeTrust(large.Hash, blocks, map[string]interface{}{"order_value_weighting": true})
+	if noRates.Inputs.VerifiedVolume != 0 || noRates.Inputs.OrderValue != 1 {
+		t.Errorf("unconvertible order: volume %v value %v", noRates.Inputs.VerifiedVolume, noRates.Inputs.OrderValue)
+	}
+}
+
+func TestComputeTrustDisputeSeverityBounds(t *testing.T) {
+	buyer := trustActor("Restaurant")
+	seller := trustActor("Supplier")
+	severity := func(v float64) float64 {
+		d := Create("observe.dispute", map[string]interface{}{"reason": "spoiled", "severity": v},
+			map[string]interface{}{"challenges": seller.Hash, "disputor": buyer.Hash})
+		return ComputeTrust(seller.Hash, []TrustBlock{buyer, seller, {Block: d}}, nil).Inputs.Disputes.Severity
+	}
+	if got := severity(-500); got != 0 {
+		t.Errorf("negative severity counted as %v", got)
+	}
+	if got := severity(1e6); got != DisputeSeverities["critical"] {
+		t.Errorf("oversized severity counted as %v", got)
+	}
+	if got := severity(1.5); got != 1.5 {
+		t.Errorf("in-range severity counted as %v", got)
+	}
+}
+
+func TestComputeTrustDisputes(t *testing.T) {
+	buyer := trustActor("Restaurant")
+	seller := trustActor("Supplier")
+	ord := trustOrder(buyer.Hash, seller.Hash, true)
+	open1, _ := Dispute(ord.Hash, buyer.Hash, "short delivery")
+	open2 := Create("observe.dispute", map[string]interface{}{"reason": "spoiled", "severity": "high"},
+		map[string]interface{}{"challenges": seller.Hash, "disputor": buyer.Hash})
+	rejected := Create("observe.dispute", map[string]interface{}{"reason": "late", "status": "rejected"},
+		map[string]interface{}{"challenges": ord.Hash, "disputor": buyer.Hash})
+	resolved := Update(open1.Hash, "observe.dispute", map[string]interface{}{"reason": "short delivery", "status": "resolved"},
+		map[string]interface{}{"challenges": ord.Hash, "disputor": buyer.Hash})
+
+	base := []TrustBlock{buyer, seller, ord}
+	clean := ComputeTrust(seller.Hash, base, nil)
+	blocks := append(base, TrustBlock{Block: open1}, TrustBlock{Block: open2}, TrustBlock{Block: rejected})
+
+	result := ComputeTrust(seller.Hash, blocks, nil)
+	if result.Inputs.Disputes.Count != 2 || result.Inputs.Disputes.Severity != 3 {
+		t.Fatalf("expected 2 open disputes with severity 3, got %+v", result.Inputs.Disputes)
+	}
+	if want := clean.Score + 3*DefaultWeights["disputes"]; math.Abs(result.Score-want) > 1e-9 {
+		t.Errorf("expected score %v, got %v", want, result.Score)
+	}
+	if ComputeTrust(buyer.Hash, blocks, nil).Inputs.Disputes.Count != 0 {
+		t.Error("disputes should not count against the disputor")
+	}
+
+	blocks = append(blocks, TrustBlock{Block: resolved})
+	if got := ComputeTrust(seller.Hash, blocks, nil).Inputs.Disputes.Count; got != 1 {
+		t.Errorf("expected resolved dispute to be excluded, got %d open", got)
+	}
+
+	custom := ComputeTrust(seller.Hash, blocks, map[string]interface{}{"weights": map[string]interface{}{"disputes": 0}})
+	if custom.Contributions["disputes"] != 0 {
+		t.Errorf("policy should be able to disable disputes, got %v", custom.Contributions["disputes"])
+	}
+	if !strings.Contains(ExplainTrust(result), "2 open dispute(s)") {
+		t.Errorf("explanation should mention disputes:\n%s", ExplainTrust(result))
+	}
+}
+
 func TestComputeTrustUnverifiedOrders(t *testing.T) {
 	buyer := trustActor("Restaurant")
 	seller := trustActor("Supplier")
@@ -264,3 +395,45 @@ func TestCreateTrustPolicyMinimal(t *testing.T) {
 		t.Error("minimal policy should not have required_authorities")
 	}
 }
+
+func TestComputeTrustReviewerReputation(t *testing.T) {
+	farm := trustActor("Green Acres")
+	authority := trustActor("Council")
+	veteran := trustActor("Established Cafe")
+	newcomer := trustActor("Brand New Account")
+	blocks := []TrustBlock{farm, authority, veteran, newcomer,
+		trustCertification(veteran.Hash, authority.Hash, "2099-01-01"),
+		trustReview(farm.Hash, veteran.Hash, 5),
+		trustReview(farm.Hash, newcomer.Hash, 1),
+	}
+
+	plain := ComputeTrust(farm.Hash, blocks, nil).Inputs.PeerReviews
+	weighted := ComputeTrust(farm.Hash, blocks, map[string]interface{}{"reviewer_reputation": true}).Inputs.PeerReviews
+	if len(weighted.Reviews) != 2 {
+		t.Fatalf("expected per-review weights, got %v", weighted.Reviews)
+	}
+	weights := map[string]ReviewWeight{}
+	for _, r := range weighted.Reviews {
+		weights[r.Reviewer] = r
+	}
+	if weights[veteran.Hash].Weight <= weights[newcomer.Hash].Weight {
+		t.Errorf("veteran weight %v should exceed newcomer weight %v", weights[veteran.Hash].Weight, weights[newcomer.Hash].Weight)
+	}
+	if w := weights[newcomer.Hash].Weight; w < 0.5 || w >= 1 {
+		t.Errorf("newcomer weight = %v, want damped into [0.5, 1)", w)
+	}
+	// The veteran's 5-star review now outweighs the newcomer's 1-star.
+	plainMean := plain.WeightedScore / 2
+	var effective float64
+	for _, r := range weighted.Reviews {
+		effective += r.Weight
+	}
+	if weighted.WeightedScore/effective <= plainMean {
+		t.Errorf("reputation should raise the weighted mean: plain %v, weighted %v/%v", plainMean, weighted.WeightedScore, effective)
+	}
+
+	damped := ComputeTrust(farm.Hash, blocks, map[string]interface{}{"reviewer_reputation": true, "reputation_damping": 0.0}).Inputs.PeerReviews
+	if damped.WeightedScore != plain.WeightedScore {
+		t.Errorf("zero damping should match plain scoring: %v vs %v", damped.WeightedScore, plain.WeightedScore)
+	}
+}
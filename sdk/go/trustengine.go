@@ -0,0 +1,198 @@
+package foodblock
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// TrustEngine caches ComputeTrust results per actor over a growing block set.
+// Adding blocks invalidates only the actors whose score they can change, so
+// repeated lookups do not rescan the graph. It is safe for concurrent use.
+type TrustEngine struct {
+	mu       sync.RWMutex
+	policy   map[string]interface{}
+	blocks   []TrustBlock
+	byHash   map[string]int
+	reviews  map[string]map[string]bool // reviewer -> subjects it reviewed
+	cache    map[string]TrustResult
+	versions map[string]uint64 // bumped on invalidation, guards stale writes
+}
+
+// NewTrustEngine creates an engine scoring with the given policy.
+func NewTrustEngine(policy map[string]interface{}) *TrustEngine {
+	return &TrustEngine{
+		policy:   policy,
+		byHash:   make(map[string]int),
+		reviews:  make(map[string]map[string]bool),
+		cache:    make(map[string]TrustResult),
+		versions: make(map[string]uint64),
+	}
+}
+
+// SetPolicy replaces the policy and drops every cached score.
+func (e *TrustEngine) SetPolicy(policy map[string]interface{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.policy = policy
+	for actor := range e.cache {
+		e.versions[actor]++
+	}
+	e.cache = make(map[string]TrustResult)
+}
+
+// Add ingests blocks and returns the actors whose cached scores were
+// invalidated, sorted. Blocks already known are ignored.
+func (e *TrustEngine) Add(blocks ...TrustBlock) []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	affected := map[string]bool{}
+	for _, b := range blocks {
+		if _, ok := e.byHash[b.Hash]; ok {
+			continue
+		}
+		e.byHash[b.Hash] = len(e.blocks)
+		e.blocks = append(e.blocks, b)
+		if b.Type == "observe.review" {
+			if subject, ok := b.Refs["subject"].(string); ok {
+				reviewer, _ := b.Refs["author"].(string)
+				if reviewer == "" {
+					reviewer = b.AuthorHash
+				}
+				if reviewer != "" {
+					if e.reviews[reviewer] == nil {
+						e.reviews[reviewer] = map[string]bool{}
+					}
+					e.reviews[reviewer][subject] = true
+				}
+			}
+		}
+		for _, actor := range e.affectedBy(b) {
+			affected[actor] = true
+		}
+	}
+	// A review's weight depends on the reviewer's connections (and, with
+	// reviewer_reputation, its score), so subjects of affected reviewers
+	// change too.
+	for reviewer := range affected {
+		for subject := range e.reviews[reviewer] {
+			affected[subject] = true
+		}
+	}
+	return e.invalidateLocked(affected)
+}
+
+// affectedBy lists the actors a block can influence: the block itself (account
+// age), its author, everything it references, and for disputes, revocations
+// and gradings the parties of the challenged, revoked or inspected block.
+func (e *TrustEngine) affectedBy(b TrustBlock) []string {
+	actors := []string{b.Hash}
+	if b.AuthorHash != "" {
+		actors = append(actors, b.AuthorHash)
+	}
+	if b.Refs == nil {
+		return actors
+	}
+	actors = append(actors, flattenRefValues(b.Refs)...)
+	if b.Type == "observe.dispute" || b.Type == RevocationType || b.Type == GradingType {
+		target := firstRef(b.Refs, "challenges", "revokes", "order")
+		if i, ok := e.byHash[target]; ok && e.blocks[i].Refs != nil {
+			actors = append(actors, flattenRefValues(e.blocks[i].Refs)...)
+		}
+	}
+	return actors
+}
+
+// Invalidate drops cached scores for the given actors.
+func (e *TrustEngine) Invalidate(actors ...string) {
+	set := make(map[string]bool, len(actors))
+	for _, a := range actors {
+		set[a] = true
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.invalidateLocked(set)
+}
+
+func (e *TrustEngine) invalidateLocked(set map[string]bool) []string {
+	out := make([]string, 0, len(set))
+	for actor := range set {
+		e.versions[actor]++
+		if _, ok := e.cache[actor]; ok {
+			delete(e.cache, actor)
+			out = append(out, actor)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Cached returns the cached score for an actor without computing it.
+func (e *TrustEngine) Cached(actorHash string) (TrustResult, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	r, ok := e.cache[actorHash]
+	return r, ok
+}
+
+// Score returns the actor's trust, computing and caching it on a miss.
+func (e *TrustEngine) Score(actorHash string) TrustResult {
+	if r, ok := e.Cached(actorHash); ok {
+		return r
+	}
+	return e.compute(actorHash)
+}
+
+func (e *TrustEngine) compute(actorHash string) TrustResult {
+	e.mu.RLock()
+	blocks := e.blocks[:len(e.blocks):len(e.blocks)]
+	policy := e.policy
+	version := e.versions[actorHash]
+	e.mu.RUnlock()
+
+	r := ComputeTrust(actorHash, blocks, policy)
+
+	e.mu.Lock()
+	// Skip the write if blocks arrived for this actor while computing.
+	if e.versions[actorHash] == version {
+		e.cache[actorHash] = r
+	}
+	e.mu.Unlock()
+	return r
+}
+
+// Actors returns the hashes of all actor.* blocks known to the engine, sorted.
+func (e *TrustEngine) Actors() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	var out []string
+	for _, b := range e.blocks {
+		if strings.HasPrefix(b.Type, "actor.") {
+			out = append(out, b.Hash)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Recompute scores the given actors (all known actors when nil), skipping
+// those already cached, and calls progress after each with the number done
+// and the total. It stops early with the context's error when cancelled.
+func (e *TrustEngine) Recompute(ctx context.Context, actors []string, progress func(done, total int)) error {
+	if actors == nil {
+		actors = e.Actors()
+	}
+	for i, actor := range actors {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, ok := e.Cached(actor); !ok {
+			e.compute(actor)
+		}
+		if progress != nil {
+			progress(i+1, len(actors))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package foodblock
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTrustEngineCachesAndInvalidates(t *testing.T) {
+	farm := trustActor("Farm")
+	bakery := trustActor("Bakery")
+	shop := trustActor("Shop")
+	engine := NewTrustEngine(nil)
+	engine.Add(farm, bakery, shop)
+
+	var calls []int
+	if err := engine.Recompute(context.Background(), nil, func(done, total int) {
+		if total != 3 {
+			t.Errorf("expected 3 actors, got %d", total)
+		}
+		calls = append(calls, done)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 3 || calls[2] != 3 {
+		t.Fatalf("unexpected progress calls %v", calls)
+	}
+	before := engine.Score(bakery.Hash)
+
+	// A certification of the bakery only invalidates the bakery.
+	cert := TrustBlock{Block: Create("observe.certification", map[string]interface{}{"name": "Hygiene"},
+		map[string]interface{}{"subject": bakery.Hash})}
+	invalidated := engine.Add(cert)
+	if len(invalidated) != 1 || invalidated[0] != bakery.Hash {
+		t.Fatalf("expected only the bakery to be invalidated, got %v", invalidated)
+	}
+	if _, ok := engine.Cached(farm.Hash); !ok {
+		t.Error("farm score should still be cached")
+	}
+	after := engine.Score(bakery.Hash)
+	if after.Inputs.AuthorityCerts != 1 || after.Score <= before.Score {
+		t.Errorf("expected the new certification to raise the score: %v -> %v", before.Score, after.Score)
+	}
+
+	// Adding the same block again changes nothing.
+	if got := engine.Add(cert); len(got) != 0 {
+		t.Errorf("duplicate block invalidated %v", got)
+	}
+}
+
+func TestTrustEngineGradingInvalidatesSeller(t *testing.T) {
+	buyer := trustActor("Restaurant")
+	seller := trustActor("Supplier")
+	order := TrustBlock{Block: Create("transfer.order", map[string]interface{}{"instance_id": "o1"},
+		map[string]interface{}{"buyer": buyer.Hash, "seller": seller.Hash})}
+	engine := NewTrustEngine(nil)
+	engine.Add(buyer, seller, order)
+	engine.Score(seller.Hash)
+
+	// The grading names only the order, not the seller.
+	grading := TrustBlock{Block: Create(GradingType, map[string]interface{}{"decision": "rejected", "grade": "C"},
+		map[string]interface{}{"order": order.Hash})}
+	engine.Add(grading)
+	cached := engine.Score(seller.Hash)
+	fresh := ComputeTrust(seller.Hash, []TrustBlock{buyer, seller, order, grading}, nil)
+	if cached.Inputs.Quality != -1 || cached.Inputs.Quality != fresh.Inputs.Quality {
+		t.Errorf("cached quality %d, fresh %d", cached.Inputs.Quality, fresh.Inputs.Quality)
+	}
+}
+
+func TestTrustEngineReviewerInvalidation(t *testing.T) {
+	farm := trustActor("Farm")
+	bakery := trustActor("Bakery")
+	review := TrustBlock{Block: Create("observe.review", map[string]interface{}{"rating": 5.0},
+		map[string]interface{}{"subject": bakery.Hash, "author": farm.Hash})}
+	engine := NewTrustEngine(map[string]interface{}{"reviewer_reputation": true})
+	engine.Add(farm, bakery, review)
+	if err := engine.Recompute(context.Background(), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Certifying the reviewer changes its reputation, so the subject of its
+	// review is recomputed too.
+	cert := TrustBlock{Block: Create("observe.certification", map[string]interface{}{"name": "Organic"},
+		map[string]interface{}{"subject": farm.Hash})}
+	got := engine.Add(cert)
+	if len(got) != 2 {
+		t.Fatalf("expected reviewer and subject to be invalidated, got %v", got)
+	}
+	if _, ok := engine.Cached(bakery.Hash); ok {
+		t.Error("bakery should have been invalidated")
+	}
+}
+
+func TestTrustEngineRecomputeCancelled(t *testing.T) {
+	engine := NewTrustEngine(nil)
+	engine.Add(trustActor("A"), trustActor("B"))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := engine.Recompute(ctx, nil, nil); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
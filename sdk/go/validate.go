@@ -2,20 +2,31 @@ package foodblock
 
 import "fmt"
 
-// SchemaField defines a field in a schema.
+// SchemaField defines a field in a schema, with optional constraints on its
+// value.
 type SchemaField struct {
-	Type     string
-	Required bool
+	Type     string `json:"type"`
+	Required bool   `json:"required,omitempty"`
+	Constraint
 }
 
-// Schema defines validation rules for a block type.
+// Schema defines validation rules for a block type. Its JSON form is the
+// state of an observe.schema block.
 type Schema struct {
-	TargetType        string
-	Version           string
-	Fields            map[string]SchemaField
-	ExpectedRefs      []string
-	OptionalRefs      []string
-	RequiresInstanceID bool
+	TargetType         string                 `json:"target_type"`
+	Version            string                 `json:"version"`
+	Fields             map[string]SchemaField `json:"fields"`
+	ExpectedRefs       []string               `json:"expected_refs,omitempty"`
+	OptionalRefs       []string               `json:"optional_refs,omitempty"`
+	// RefTypes restricts the types of block a ref may point at, by role,
+	// as type patterns such as "actor.*". ValidateWithStore checks them.
+	RefTypes           map[string][]string    `json:"ref_types,omitempty"`
+	RequiresInstanceID bool                   `json:"requires_instance_id,omitempty"`
+	// Deprecated explains why the version should no longer be used; empty
+	// when it is current.
+	Deprecated string `json:"deprecated,omitempty"`
+	// Migrations declare how blocks of earlier versions become this one.
+	Migrations []SchemaMigration `json:"migrations,omitempty"`
 }
 
 // CoreSchemas are the bundled core schemas.
@@ -30,6 +41,7 @@ var CoreSchemas = map[string]Schema{
 		},
 		ExpectedRefs:      []string{"seller"},
 		OptionalRefs:      []string{"origin", "inputs", "certifications"},
+		RefTypes:          map[string][]string{"seller": {"actor.*"}, "inputs": {"substance.*"}, "certifications": {"observe.certification"}},
 		RequiresInstanceID: false,
 	},
 	"foodblock:transfer.order@1.0": {
@@ -43,6 +55,7 @@ var CoreSchemas = map[string]Schema{
 		},
 		ExpectedRefs:      []string{"buyer", "seller"},
 		OptionalRefs:      []string{"product", "agent"},
+		RefTypes:          map[string][]string{"buyer": {"actor.*"}, "seller": {"actor.*"}, "product": {"substance.*"}, "agent": {"actor.*"}},
 		RequiresInstanceID: true,
 	},
 	"foodblock:observe.review@1.0": {
@@ -54,6 +67,7 @@ var CoreSchemas = map[string]Schema{
 			"text":        {Type: "string"},
 		},
 		ExpectedRefs:      []string{"subject", "author"},
+		RefTypes:          map[string][]string{"author": {"actor.*"}},
 		RequiresInstanceID: true,
 	},
 	"foodblock:actor.producer@1.0": {
@@ -74,6 +88,7 @@ var CoreSchemas = map[string]Schema{
 			"standard":    {Type: "string"},
 		},
 		ExpectedRefs:      []string{"subject", "authority"},
+		RefTypes:          map[string][]string{"authority": {"actor.*"}},
 		RequiresInstanceID: true,
 	},
 }
@@ -116,10 +131,13 @@ func Validate(block Block, schema *Schema) []string {
 				errs = append(errs, fmt.Sprintf("Missing required field: state.%s", field))
 			}
 		}
-		if val, ok := block.State[field]; ok && def.Type != "" {
-			actualType := goTypeToSchemaType(val)
-			if actualType != def.Type {
+		if val, ok := block.State[field]; ok {
+			if actualType := goTypeToSchemaType(val); def.Type != "" && actualType != def.Type {
 				errs = append(errs, fmt.Sprintf("Field state.%s should be %s, got %s", field, def.Type, actualType))
+			} else {
+				for _, msg := range checkConstraint("state."+field, def.Constraint, val) {
+					errs = append(errs, "Field "+msg)
+				}
 			}
 		}
 	}
@@ -154,3 +154,33 @@ func TestCoreSchemas(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateWithStoreRefTypes(t *testing.T) {
+	store := NewMemoryStore()
+	mill := Create("actor.producer", map[string]interface{}{"name": "Mill"}, nil)
+	flour := Create("substance.ingredient", map[string]interface{}{"name": "Flour"}, nil)
+	store.PutAll([]Block{mill, flour})
+
+	good := Create("substance.product", map[string]interface{}{"$schema": "foodblock:substance.product@1.0", "name": "Rye"},
+		map[string]interface{}{"seller": mill.Hash, "inputs": []interface{}{flour.Hash}})
+	if v := ValidateWithStore(good, nil, store); !v.Valid() || len(v.Dangling) != 0 {
+		t.Errorf("good: %+v", v)
+	}
+
+	bad := Create("substance.product", map[string]interface{}{"$schema": "foodblock:substance.product@1.0", "name": "Rye"},
+		map[string]interface{}{"seller": flour.Hash, "inputs": []interface{}{flour.Hash, "missing"}})
+	v := ValidateWithStore(bad, nil, store)
+	if v.Valid() || len(v.Errors) != 1 || v.Errors[0] != "refs.seller should resolve to actor.*, got substance.ingredient" {
+		t.Errorf("errors = %v", v.Errors)
+	}
+	if len(v.Dangling) != 1 || v.Dangling[0] != "refs.inputs missing" {
+		t.Errorf("dangling = %v", v.Dangling)
+	}
+
+	// Vocabularies can restrict refs too; the pipeline reports both.
+	vocabs := map[string]VocabularyDef{"mill": {Domain: "mill", ForTypes: []string{"substance.product"}, Refs: map[string][]string{"inputs": {"substance.grain"}}}}
+	r := ValidatePipeline([]SignedBlock{{FoodBlock: good}}, PipelineOptions{Store: store, Vocabularies: vocabs})
+	if r.OK || !strings.Contains(r.Blocks[0].Issues[0].Message, "refs.inputs should resolve to substance.grain") {
+		t.Errorf("report = %+v", r.Blocks[0])
+	}
+}
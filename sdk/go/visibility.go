@@ -0,0 +1,136 @@
+package foodblock
+
+import "sort"
+
+// VisibilityKey is the state field annotating which audiences may see each
+// of a block's other fields: a map from field name to an audience or a list
+// of audiences. Annotations on the block override the Visibility of the
+// field in the vocabularies for its type; fields without either are public.
+const VisibilityKey = "_visibility"
+
+const (
+	// AudiencePublic may see only public fields.
+	AudiencePublic = "public"
+	// AudienceAll sees every field, as the block's author does.
+	AudienceAll = "*"
+)
+
+// ProjectedBlock is a block as one audience sees it. When fields are
+// withheld the block no longer matches its hash; each disclosed field then
+// carries a Merkle proof (as SelectiveDisclose makes) against MerkleRoot,
+// the root of the full state.
+type ProjectedBlock struct {
+	Hash       string                  `json:"hash"`
+	Type       string                  `json:"type"`
+	State      map[string]interface{}  `json:"state"`
+	Refs       map[string]interface{}  `json:"refs"`
+	Audience   string                  `json:"audience"`
+	Withheld   int                     `json:"withheld,omitempty"` // number of fields left out
+	MerkleRoot string                  `json:"merkle_root,omitempty"`
+	Proofs     map[string][]ProofEntry `json:"proofs,omitempty"`
+}
+
+// FieldVisibility returns the audiences of each restricted field of b, from
+// its VisibilityKey annotations and the vocabularies for its type. A nil
+// vocabulary map uses Vocabularies.
+func FieldVisibility(b Block, vocabs map[string]VocabularyDef) map[string][]string {
+	if vocabs == nil {
+		vocabs = Vocabularies
+	}
+	out := map[string][]string{}
+	for _, name := range sortedVocabNames(vocabs) {
+		v := vocabs[name]
+		if !containsStr(v.ForTypes, b.Type) {
+			continue
+		}
+		for field, def := range v.Fields {
+			if len(def.Visibility) > 0 {
+				out[field] = def.Visibility
+			}
+		}
+	}
+	annotations, _ := b.State[VisibilityKey].(map[string]interface{})
+	for field, v := range annotations {
+		switch a := v.(type) {
+		case string:
+			out[field] = []string{a}
+		case []interface{}:
+			var audiences []string
+			for _, e := range a {
+				if s, ok := e.(string); ok {
+					audiences = append(audiences, s)
+				}
+			}
+			out[field] = audiences
+		}
+	}
+	for field, audiences := range out {
+		if containsStr(audiences, AudiencePublic) {
+			delete(out, field)
+		}
+	}
+	return out
+}
+
+// ProjectForAudience strips the fields of b that audience may not see. The
+// VisibilityKey annotations, which name the restricted fields, are only
+// shown to an audience that sees every field.
+func ProjectForAudience(b Block, audience string) ProjectedBlock {
+	if audience == "" {
+		audience = AudiencePublic
+	}
+	p := ProjectedBlock{Hash: b.Hash, Type: b.Type, Refs: b.Refs, Audience: audience}
+	restricted := FieldVisibility(b, nil)
+	var shown []string
+	for field := range b.State {
+		audiences, ok := restricted[field]
+		if field == VisibilityKey || audience == AudienceAll || !ok || containsStr(audiences, audience) {
+			shown = append(shown, field)
+		} else {
+			p.Withheld++
+		}
+	}
+	if p.Withheld == 0 || audience == AudienceAll {
+		p.State = b.State
+		return p
+	}
+	sort.Strings(shown)
+	p.State = map[string]interface{}{}
+	p.Proofs = map[string][]ProofEntry{}
+	for _, field := range shown {
+		if field == VisibilityKey {
+			p.Withheld++
+			continue
+		}
+		d := SelectiveDisclose(b.State, []string{field})
+		p.State[field] = b.State[field]
+		p.Proofs[field] = d.Proof
+		p.MerkleRoot = d.Root
+	}
+	if p.MerkleRoot == "" {
+		p.MerkleRoot = Merkleize(b.State).Root
+	}
+	return p
+}
+
+// VerifyProjection checks a projection: a complete one against its hash,
+// a partial one field by field against its Merkle root. Tying that root to
+// the block is left to whoever vouches for it, such as the author signing
+// it or a verifier holding the full block.
+func VerifyProjection(p ProjectedBlock) bool {
+	if p.Withheld == 0 {
+		return Hash(p.Type, p.State, p.Refs) == p.Hash
+	}
+	for field, v := range p.State {
+		if !VerifyProof(map[string]interface{}{field: v}, p.Proofs[field], p.MerkleRoot) {
+			return false
+		}
+	}
+	return true
+}
+
+// Block returns the projection as a block for rendering. It carries the
+// original hash, which a partial state no longer matches.
+func (p ProjectedBlock) Block() Block {
+	return Block{Hash: p.Hash, Type: p.Type, State: p.State, Refs: p.Refs}
+}
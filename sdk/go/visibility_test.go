@@ -0,0 +1,98 @@
+package foodblock
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestProjectForAudience(t *testing.T) {
+	bread := Create("substance.product", map[string]interface{}{
+		"name":            "Sourdough",
+		"story":           "Baked overnight",
+		"wholesale_price": 2.1,
+		"margin":          0.4,
+		VisibilityKey:     map[string]interface{}{"wholesale_price": "trade", "margin": []interface{}{"internal"}, "story": "public"},
+	}, nil)
+
+	public := ProjectForAudience(bread, "")
+	if public.Audience != AudiencePublic || public.Withheld != 3 || len(public.State) != 2 || public.State["story"] != "Baked overnight" {
+		t.Fatalf("public = %+v", public)
+	}
+	if public.MerkleRoot != Merkleize(bread.State).Root || !VerifyProjection(public) {
+		t.Error("public projection does not verify against the full state")
+	}
+	public.State["name"] = "Rye"
+	if VerifyProjection(public) {
+		t.Error("expected a tampered projection to fail")
+	}
+
+	trade := ProjectForAudience(bread, "trade")
+	if trade.State["wholesale_price"] != 2.1 || trade.State["margin"] != nil || trade.State[VisibilityKey] != nil || !VerifyProjection(trade) {
+		t.Errorf("trade = %+v", trade)
+	}
+	all := ProjectForAudience(bread, AudienceAll)
+	if all.Withheld != 0 || all.Proofs != nil || !VerifyProjection(all) {
+		t.Errorf("all = %+v", all)
+	}
+
+	vocabs := map[string]VocabularyDef{"bakery": {ForTypes: []string{"substance.product"}, Fields: map[string]FieldDef{"recipe": {Type: "string", Visibility: []string{"internal"}}}}}
+	recipe := Create("substance.product", map[string]interface{}{"name": "Rye", "recipe": "secret"}, nil)
+	if vis := FieldVisibility(recipe, vocabs); len(vis) != 1 || vis["recipe"][0] != "internal" {
+		t.Errorf("visibility = %v", vis)
+	}
+}
+
+func TestGatewayAudience(t *testing.T) {
+	store := NewMemoryStore()
+	pub, priv := GenerateKeypair()
+	farm := Create("actor.producer", map[string]interface{}{"name": "Green Acres"}, nil)
+	bread := Create("substance.product", map[string]interface{}{"name": "Sourdough", "wholesale_price": 2.1, VisibilityKey: map[string]interface{}{"wholesale_price": "trade"}}, map[string]interface{}{"seller": farm.Hash})
+	store.Put(farm)
+	store.PutSigned(Sign(bread, farm.Hash, priv))
+	keys := NewKeyRegistry()
+	keys.Register(farm.Hash, pub)
+	srv := httptest.NewServer(GatewayHandler(store, GatewayOptions{Keys: keys}))
+	defer srv.Close()
+
+	fetch := func(token string) ProjectedBlock {
+		resp, err := http.Get(srv.URL + "/blocks/" + bread.Hash + "?token=" + token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var p ProjectedBlock
+		json.NewDecoder(resp.Body).Decode(&p)
+		return p
+	}
+	publicToken, _ := CreateShareToken(bread.Hash, farm.Hash, priv, time.Hour)
+	tradeToken, _ := CreateAudienceShareToken(bread.Hash, farm.Hash, "trade", priv, time.Hour)
+	if p := fetch(publicToken); p.State["wholesale_price"] != nil || p.State["name"] != "Sourdough" || !VerifyProjection(p) {
+		t.Errorf("public = %+v", p)
+	}
+	if p := fetch(tradeToken); p.Audience != "trade" || p.State["wholesale_price"] != 2.1 {
+		t.Errorf("trade = %+v", p)
+	}
+
+	// A token's audience does not reach blocks by other authors.
+	_, millPriv := GenerateKeypair()
+	mill := Create("actor.producer", map[string]interface{}{"name": "Mill"}, nil)
+	flour := Create("substance.ingredient", map[string]interface{}{"name": "Flour", "contract_price": 0.4, VisibilityKey: map[string]interface{}{"contract_price": "trade"}}, map[string]interface{}{"seller": mill.Hash})
+	loaf := Create("substance.product", map[string]interface{}{"name": "Rye"}, map[string]interface{}{"seller": farm.Hash, "inputs": []interface{}{flour.Hash}})
+	store.Put(mill)
+	store.PutSigned(Sign(flour, mill.Hash, millPriv))
+	store.PutSigned(Sign(loaf, farm.Hash, priv))
+	allToken, _ := CreateAudienceShareToken(loaf.Hash, farm.Hash, AudienceAll, priv, time.Hour)
+	resp, err := http.Get(srv.URL + "/blocks/" + flour.Hash + "?token=" + allToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var p ProjectedBlock
+	json.NewDecoder(resp.Body).Decode(&p)
+	if p.State["contract_price"] != nil {
+		t.Errorf("another author's restricted field leaked: %+v", p)
+	}
+}
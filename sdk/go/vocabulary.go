@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -18,15 +19,25 @@ type FieldDef struct {
 	ValidValues    []string `json:"valid_values,omitempty"`
 	Description    string   `json:"description,omitempty"`
 	Compound       bool     `json:"compound,omitempty"`
+	// Visibility lists the audiences that may see the field; empty is public.
+	Visibility     []string `json:"visibility,omitempty"`
+	Constraint
 }
 
 // VocabularyDef is a vocabulary definition containing domain, applicable types,
 // field definitions, and optional workflow transitions.
 type VocabularyDef struct {
-	Domain      string              `json:"domain"`
-	ForTypes    []string            `json:"for_types"`
-	Fields      map[string]FieldDef `json:"fields"`
-	Transitions map[string][]string `json:"transitions,omitempty"`
+	Domain      string                       `json:"domain"`
+	ForTypes    []string                     `json:"for_types"`
+	Fields      map[string]FieldDef          `json:"fields"`
+	Transitions map[string][]string          `json:"transitions,omitempty"`
+	Guards      map[string][]TransitionGuard `json:"guards,omitempty"`
+	Initial     string                       `json:"initial,omitempty"`
+	Terminals   []string                     `json:"terminals,omitempty"`
+	// SLAs limits how long a block may stay in a state, by state.
+	SLAs        map[string]StateSLA          `json:"slas,omitempty"`
+	// Refs restricts the types of block a ref may point at, by role.
+	Refs        map[string][]string          `json:"refs,omitempty"`
 }
 
 // MapFieldsResult is the result of mapping natural language text against a vocabulary.
@@ -43,7 +54,7 @@ var Vocabularies = map[string]VocabularyDef{
 		Fields: map[string]FieldDef{
 			"price":    {Type: "number", Aliases: []string{"price", "cost", "sells for", "costs"}, Description: "Price of the baked good"},
 			"weight":   {Type: "number", Aliases: []string{"weight", "weighs", "grams", "kg"}, Description: "Weight of the product"},
-			"allergens": {Type: "compound", Aliases: []string{"gluten", "nuts", "dairy", "eggs", "soy", "wheat"}, Description: "Allergens present in the product", Compound: true},
+			"allergens": {Type: "compound", Aliases: []string{"gluten", "nuts", "dairy", "eggs", "soy", "wheat"}, Description: "Allergens present in the product"},
 			"name":     {Type: "string", Required: true, Aliases: []string{"name", "called", "named"}, Description: "Product name"},
 			"organic":  {Type: "boolean", Aliases: []string{"organic", "bio"}, Description: "Whether the product is organic"},
 		},
@@ -87,13 +98,21 @@ var Vocabularies = map[string]VocabularyDef{
 		ForTypes: []string{"substance.product", "substance.ingredient", "transform.process"},
 		Fields: map[string]FieldDef{
 			"lot_id":          {Type: "string", Required: true, Aliases: []string{"lot", "lot number", "lot id", "batch"}, Description: "Lot or batch identifier"},
-			"batch_id":        {Type: "string", Aliases: []string{"batch", "batch number", "batch id"}, Description: "Batch identifier"},
+			"batch_id":        {Type: "string", Aliases: []string{"batch", "batch number", "batch id"}, Description: "Batch identifier (alias for lot_id in some systems)"},
 			"production_date": {Type: "string", Aliases: []string{"produced", "manufactured", "made on", "production date"}, Description: "Date of production (ISO 8601)"},
 			"expiry_date":     {Type: "string", Aliases: []string{"expires", "expiry", "best before", "use by", "sell by"}, Description: "Expiry or best-before date (ISO 8601)"},
 			"lot_size":        {Type: "number", Aliases: []string{"lot size", "batch size", "quantity produced"}, Description: "Number of units in the lot"},
 			"facility":        {Type: "string", Aliases: []string{"facility", "plant", "factory", "site"}, Description: "Production facility identifier"},
 		},
 	},
+	"storage": {
+		Domain:  "storage",
+		ForTypes: []string{"substance.product", "substance.ingredient"},
+		Fields: map[string]FieldDef{
+			"storage_temperature": {Type: "quantity", Aliases: []string{"store at", "stored at", "storage temperature", "keep at"}, ValidUnits: []string{"celsius", "fahrenheit", "kelvin"}, Description: "Permitted storage temperature range ({min, max, unit})"},
+			"storage_humidity":    {Type: "quantity", Aliases: []string{"humidity", "relative humidity", "rh"}, ValidUnits: []string{"percent"}, Description: "Permitted relative humidity range ({min, max, unit: percent})"},
+		},
+	},
 	"units": {
 		Domain:  "units",
 		ForTypes: []string{"substance.product", "substance.ingredient", "transfer.order", "observe.reading"},
@@ -109,7 +128,7 @@ var Vocabularies = map[string]VocabularyDef{
 		Domain:  "workflow",
 		ForTypes: []string{"transfer.order", "transfer.shipment", "transfer.booking"},
 		Fields: map[string]FieldDef{
-			"status":          {Type: "string", Required: true, Aliases: []string{"status", "state", "stage"}, Description: "Current workflow status"},
+			"status":          {Type: "string", Required: true, Aliases: []string{"status", "state", "stage"}, ValidValues: []string{"draft", "quote", "order", "confirmed", "processing", "shipped", "delivered", "paid", "cancelled", "returned"}, Description: "Current workflow status"},
 			"previous_status": {Type: "string", Aliases: []string{"was", "previously", "changed from"}, Description: "Previous status before transition"},
 			"reason":          {Type: "string", Aliases: []string{"reason", "because", "note"}, Description: "Reason for status change"},
 		},
@@ -125,6 +144,57 @@ var Vocabularies = map[string]VocabularyDef{
 			"cancelled":  {},
 			"returned":   {"order"},
 		},
+		Initial:   "draft",
+		Terminals: []string{"paid", "cancelled"},
+		SLAs: map[string]StateSLA{
+			"confirmed":  {MaxHours: 48},
+			"processing": {MaxHours: 72, EscalateAfter: []float64{24, 168}},
+			"shipped":    {MaxHours: 168},
+		},
+		Guards: map[string][]TransitionGuard{
+			"order->confirmed": {
+				{Name: "payment_ref", RequireState: "payment_ref", Description: "Order must carry a payment reference"},
+			},
+			"shipped->delivered": {
+				{Name: "carrier_ack", RequireBlock: "observe.acknowledgement", FromRef: "carrier", Description: "Carrier must acknowledge delivery"},
+			},
+		},
+	},
+	"incident": {
+		Domain:   "incident",
+		ForTypes: []string{"observe.incident"},
+		Fields: map[string]FieldDef{
+			"status":            {Type: "string", Required: true, Aliases: []string{"status", "stage"}, Description: "Current incident status"},
+			"severity":          {Type: "string", Required: true, Aliases: []string{"severity", "priority"}, ValidValues: []string{"low", "medium", "high", "critical"}, Description: "Incident severity"},
+			"category":          {Type: "string", Aliases: []string{"excursion", "audit", "complaint", "contamination", "dispute"}, Description: "Kind of incident"},
+			"root_cause":        {Type: "string", Aliases: []string{"root cause", "caused by", "cause"}, Description: "Finding of the investigation"},
+			"corrective_action": {Type: "string", Aliases: []string{"corrective action", "fix", "remedy"}, Description: "Action taken to prevent recurrence"},
+			"resolution":        {Type: "string", Aliases: []string{"resolution", "resolved", "no action"}, Description: "Why the incident was closed"},
+			"due_at":            {Type: "string", Description: "Resolution deadline (ISO 8601)"},
+		},
+		Transitions: map[string][]string{
+			"open":              {"investigating"},
+			"investigating":     {"corrective_action", "closed"},
+			"corrective_action": {"closed", "investigating"},
+			"closed":            {},
+		},
+		Initial:   "open",
+		Terminals: []string{"closed"},
+		Guards: map[string][]TransitionGuard{
+			"open->investigating": {
+				{Name: "assignee", RequireRef: "assignee", Description: "Incident must be assigned before investigation"},
+			},
+			"investigating->corrective_action": {
+				{Name: "root_cause", RequireState: "root_cause", Description: "Investigation must record a root cause"},
+			},
+			"investigating->closed": {
+				{Name: "resolution", RequireState: "resolution", Description: "Closing without action needs a resolution"},
+			},
+			"corrective_action->closed": {
+				{Name: "corrective_action", RequireState: "corrective_action", Description: "Corrective action must be recorded"},
+				{Name: "evidence", RequireRef: "evidence", Description: "Closure must link evidence"},
+			},
+		},
 	},
 	"distributor": {
 		Domain:   "distributor",
@@ -159,6 +229,7 @@ var Vocabularies = map[string]VocabularyDef{
 			"seasonal":     {Type: "boolean", Aliases: []string{"seasonal", "summer only", "winter market"}, Description: "Whether the market is seasonal"},
 			"pitch_fee":    {Type: "number", Aliases: []string{"pitch fee", "stall fee", "rent"}, Description: "Fee for a market pitch or stall"},
 			"market_name":  {Type: "string", Aliases: []string{"market", "farmers market", "street market", "food market"}, Description: "Name or type of the market"},
+			"stall_count":  {Type: "number", Aliases: []string{"stalls", "pitches", "stands"}, Description: "Number of pitches the market lets"},
 		},
 	},
 	"catering": {
@@ -167,7 +238,7 @@ var Vocabularies = map[string]VocabularyDef{
 		Fields: map[string]FieldDef{
 			"event_type":      {Type: "string", Aliases: []string{"wedding", "corporate", "party", "banquet", "conference", "reception", "private event"}, Description: "Type of event being catered"},
 			"covers":          {Type: "number", Aliases: []string{"covers", "guests", "people", "servings", "portions", "pax"}, Description: "Number of covers or guests"},
-			"dietary_options":  {Type: "compound", Aliases: []string{"vegan", "vegetarian", "gluten-free", "halal", "kosher", "nut-free", "dairy-free"}, Description: "Available dietary options", Compound: true},
+			"dietary_options":  {Type: "compound", Aliases: []string{"vegan", "vegetarian", "gluten-free", "halal", "kosher", "nut-free", "dairy-free"}, Description: "Available dietary options"},
 			"service_style":   {Type: "string", Aliases: []string{"buffet", "plated", "canape", "family style", "food truck"}, Description: "Style of catering service"},
 			"per_head_price":  {Type: "number", Aliases: []string{"per head", "per person", "per cover", "pp"}, Description: "Price per person"},
 		},
@@ -210,6 +281,79 @@ var Vocabularies = map[string]VocabularyDef{
 			"kosher":           {Type: "boolean", Aliases: []string{"kosher", "kosher certified", "glatt"}, Description: "Whether the meat is kosher"},
 		},
 	},
+	"fermentation": {
+		Domain:   "fermentation",
+		ForTypes: []string{"transform.fermentation", "substance.beverage", "actor.brewery"},
+		Fields: map[string]FieldDef{
+			"status":            {Type: "string", Aliases: []string{"status", "stage"}, Description: "Batch stage: brew, ferment, condition, package or dumped"},
+			"style":             {Type: "string", Aliases: []string{"style", "ipa", "stout", "porter", "lager", "pilsner", "saison", "sour", "kombucha", "cider", "mead"}, Description: "Beer or beverage style"},
+			"abv":               {Type: "number", Aliases: []string{"abv", "alcohol", "strength"}, Description: "Alcohol by volume, percent"},
+			"original_gravity":  {Type: "number", Aliases: []string{"og", "original gravity", "starting gravity"}, Description: "Specific gravity of the wort before fermentation"},
+			"final_gravity":     {Type: "number", Aliases: []string{"fg", "final gravity", "terminal gravity"}, Description: "Specific gravity when fermentation is complete"},
+			"ibu":               {Type: "number", Aliases: []string{"ibu", "ibus", "bitterness"}, Description: "International bitterness units"},
+			"yeast_strain":      {Type: "string", Aliases: []string{"yeast", "strain", "culture", "pitched"}, Description: "Yeast strain or culture pitched"},
+			"fermentation_days": {Type: "number", Aliases: []string{"fermented for", "days", "fermentation days"}, Description: "Days spent fermenting"},
+			"vessel":            {Type: "string", Aliases: []string{"fermenter", "fv", "vessel", "tank", "barrel"}, Description: "Fermentation or conditioning vessel"},
+		},
+		Transitions: map[string][]string{
+			"brew":      {"ferment", "dumped"},
+			"ferment":   {"condition", "dumped"},
+			"condition": {"package", "dumped"},
+			"package":   {},
+			"dumped":    {},
+		},
+		Initial:   "brew",
+		Terminals: []string{"package", "dumped"},
+		Guards: map[string][]TransitionGuard{
+			"brew->ferment": {
+				{Name: "original_gravity", RequireState: "original_gravity", Description: "Wort gravity must be measured before pitching"},
+				{Name: "yeast_strain", RequireState: "yeast_strain", Description: "Yeast strain must be recorded"},
+			},
+			"ferment->condition": {
+				{Name: "final_gravity", RequireState: "final_gravity", Description: "Fermentation must reach a final gravity"},
+			},
+			"condition->package": {
+				{Name: "abv", RequireState: "abv", Description: "ABV must be declared before packaging"},
+			},
+		},
+	},
+	"apiary": {
+		Domain:   "apiary",
+		ForTypes: []string{"substance.honey", "actor.apiary", "place.hive"},
+		Fields: map[string]FieldDef{
+			"floral_source":    {Type: "string", Aliases: []string{"heather", "clover", "manuka", "acacia", "wildflower", "lavender", "borage", "rapeseed", "lime", "floral source"}, Description: "Predominant nectar source"},
+			"hive_id":          {Type: "string", Aliases: []string{"hive", "colony", "hive id"}, Description: "Hive the honey was taken from"},
+			"harvest_date":     {Type: "string", Aliases: []string{"harvested", "extracted", "harvest date"}, Description: "Date the honey was extracted"},
+			"moisture_content": {Type: "number", Aliases: []string{"moisture", "water content", "moisture content"}, Description: "Moisture content, percent"},
+			"region":           {Type: "string", Aliases: []string{"region", "county", "valley"}, Description: "Region the apiary is in"},
+		},
+	},
+	"coffee": {
+		Domain:   "coffee",
+		ForTypes: []string{"substance.coffee", "substance.cacao", "transform.roast"},
+		Fields: map[string]FieldDef{
+			"variety":        {Type: "string", Aliases: []string{"variety", "varietal", "geisha", "gesha", "bourbon", "typica", "caturra", "catuai", "sl28", "pacamara", "heirloom", "criollo", "trinitario", "forastero", "nacional"}, Description: "Coffee or cacao variety"},
+			"process_method": {Type: "string", Aliases: []string{"washed", "natural", "honey", "anaerobic", "wet hulled", "semi washed", "process", "processed"}, Description: "Post-harvest processing method"},
+			"altitude":       {Type: "number", Aliases: []string{"altitude", "masl", "elevation", "grown at"}, Description: "Growing altitude in metres above sea level"},
+			"cupping_score":  {Type: "number", Aliases: []string{"cupping score", "cupped at", "sca score", "points"}, Description: "SCA cupping score out of 100"},
+			"moisture":       {Type: "number", Aliases: []string{"moisture", "moisture content"}, Description: "Green bean moisture content, percent"},
+			"screen_size":    {Type: "number", Aliases: []string{"screen", "screen size"}, Description: "Bean size in 64ths of an inch"},
+			"origin":         {Type: "string", Aliases: []string{"origin", "grown in", "country"}, Description: "Country of origin"},
+			"single_origin":  {Type: "boolean", Aliases: []string{"single origin", "single estate", "single farm"}, Description: "Whether the product claims a single origin"},
+			"roast_level":    {Type: "string", Aliases: []string{"roast level", "roasted"}, ValidValues: []string{"light", "medium", "dark"}, Description: "Roast degree"},
+		},
+	},
+	"wine": {
+		Domain:   "wine",
+		ForTypes: []string{"substance.wine", "substance.grapes", "actor.vineyard"},
+		Fields: map[string]FieldDef{
+			"grape_variety":  {Type: "string", Aliases: []string{"grape", "variety", "chardonnay", "pinot", "merlot", "cabernet", "syrah", "shiraz", "riesling", "sauvignon", "tempranillo", "sangiovese", "malbec", "grenache"}, Description: "Grape variety"},
+			"vintage":        {Type: "number", Aliases: []string{"vintage", "harvest year"}, Description: "Year the grapes were harvested"},
+			"appellation":    {Type: "string", Aliases: []string{"appellation", "aoc", "doc", "docg", "ava", "pdo", "region"}, Description: "Protected appellation or region"},
+			"alcohol":        {Type: "number", Aliases: []string{"alcohol", "abv", "vol"}, Description: "Alcohol by volume, percent"},
+			"residual_sugar": {Type: "number", Aliases: []string{"residual sugar", "rs", "sugar"}, Description: "Residual sugar, g/l"},
+		},
+	},
 }
 
 // CreateVocabulary creates an observe.vocabulary FoodBlock.
@@ -254,6 +398,9 @@ func CreateVocabulary(domain string, forTypes []string, fields map[string]FieldD
 		if def.Compound {
 			entry["compound"] = true
 		}
+		for k, v := range def.Constraint.toMap() {
+			entry[k] = v
+		}
 		fieldsMap[name] = entry
 	}
 
@@ -284,8 +431,20 @@ func MapFields(text string, vocab VocabularyDef) MapFieldsResult {
 	lower := strings.ToLower(text)
 	tokens := splitTokens(lower)
 	used := make(map[int]bool)
+	// Quantity phrases are blanked out of remaining once a field takes them,
+	// so "costs 5 pounds" cannot also become a weight.
+	remaining := lower
 
-	for fieldName, fieldDef := range vocab.Fields {
+	// Fields are visited in name order so that the same text always maps
+	// the same way.
+	fieldNames := make([]string, 0, len(vocab.Fields))
+	for name := range vocab.Fields {
+		fieldNames = append(fieldNames, name)
+	}
+	sort.Strings(fieldNames)
+
+	for _, fieldName := range fieldNames {
+		fieldDef := vocab.Fields[fieldName]
 		aliases := fieldDef.Aliases
 		if len(aliases) == 0 {
 			aliases = []string{fieldName}
@@ -368,6 +527,22 @@ func MapFields(text string, vocab VocabularyDef) MapFieldsResult {
 					}
 				}
 
+			case "quantity":
+				if _, done := matched[fieldName]; done {
+					if strings.Contains(lower, aliasLower) {
+						markUsed(tokens, used, aliasLower)
+					}
+					continue
+				}
+				if q, phrase, ok := parseQuantity(lower, remaining, aliasLower, fieldDef.ValidUnits); ok {
+					matched[fieldName] = q
+					remaining = strings.Replace(remaining, phrase, strings.Repeat(" ", len(phrase)), 1)
+					if strings.Contains(lower, aliasLower) {
+						markUsed(tokens, used, aliasLower)
+					}
+					markUsed(tokens, used, phrase)
+				}
+
 			default: // string
 				aliasIdx := indexOf(tokens, aliasLower)
 				if aliasIdx >= 0 {
@@ -424,7 +599,10 @@ func Quantity(value float64, unit string, measureType string) (map[string]interf
 	return map[string]interface{}{"value": value, "unit": unit}, nil
 }
 
-// Transition validates a workflow state transition.
+// Transition reports whether the workflow vocabulary defines the edge from
+// one status to another, as the JS and Python transition do. It cannot see
+// guards, which need the block and its graph: use TransitionIn to move a
+// block.
 func Transition(from, to string) bool {
 	wf, ok := Vocabularies["workflow"]
 	if !ok || wf.Transitions == nil {
@@ -442,6 +620,17 @@ func Transition(from, to string) bool {
 	return false
 }
 
+// TransitionIn validates moving a block to a new status under the workflow
+// vocabulary, evaluating the transition's guards against the blocks in
+// store and the signers it records. The check names any guard that failed.
+func TransitionIn(block Block, to string, store BlockStore) TransitionCheck {
+	var signerOf func(string) string
+	if s, ok := store.(interface{ SignerOf(string) string }); ok {
+		signerOf = s.SignerOf
+	}
+	return CheckTransition(Vocabularies["workflow"], block, to, store.Blocks(), signerOf)
+}
+
 // NextStatuses returns valid next statuses for a given workflow status.
 func NextStatuses(status string) []string {
 	wf, ok := Vocabularies["workflow"]
@@ -495,6 +684,184 @@ func Localize(block Block, locale string, fallback string) Block {
 	return Create(block.Type, localizedState, block.Refs)
 }
 
+var (
+	rangeRe    = regexp.MustCompile(`(?:between|from)\s+(-?\d+(?:\.\d+)?)\s*(?:[a-z°][a-z_°]*\s+)?(?:and|to)\s+(-?\d+(?:\.\d+)?)\s*([a-z°][a-z_°]*)(?:\s+(celsius|fahrenheit|kelvin|c|f|k)\b)?`)
+	dashRange  = regexp.MustCompile(`(-?\d+(?:\.\d+)?)\s*(?:-|–|to)\s*(-?\d+(?:\.\d+)?)\s*([a-z°][a-z_°]*)(?:\s+(celsius|fahrenheit|kelvin|c|f|k)\b)?`)
+	amountRe   = regexp.MustCompile(`(-?\d+(?:\.\d+)?)\s*([a-z°][a-z_°]*)(?:\s+(celsius|fahrenheit|kelvin|c|f|k)\b)?`)
+	currencyRe = regexp.MustCompile(`([$£€¥])\s*(\d+(?:\.\d+)?)`)
+)
+
+// unitSynonyms maps spoken unit forms to candidate canonical units. The first
+// candidate present in a field's ValidUnits wins, so "pounds" resolves to lb
+// for weight and GBP for currency.
+var unitSynonyms = map[string][]string{
+	"gram": {"g"}, "grams": {"g"}, "gr": {"g"},
+	"kilo": {"kg"}, "kilos": {"kg"}, "kgs": {"kg"}, "kilogram": {"kg"}, "kilograms": {"kg"},
+	"milligram": {"mg"}, "milligrams": {"mg"},
+	"ounce": {"oz"}, "ounces": {"oz"},
+	"lbs": {"lb"}, "pound": {"lb", "GBP"}, "pounds": {"lb", "GBP"},
+	"tons": {"ton"}, "tonne": {"ton"}, "tonnes": {"ton"},
+	"milliliter": {"ml"}, "milliliters": {"ml"}, "millilitre": {"ml"}, "millilitres": {"ml"},
+	"liter": {"l"}, "liters": {"l"}, "litre": {"l"}, "litres": {"l"}, "ltr": {"l"},
+	"gallon": {"gal"}, "gallons": {"gal"}, "cups": {"cup"},
+	"tablespoon": {"tbsp"}, "tablespoons": {"tbsp"}, "teaspoon": {"tsp"}, "teaspoons": {"tsp"},
+	"floz": {"fl_oz"},
+	"c": {"celsius"}, "°c": {"celsius"}, "°": {"celsius"}, "degree": {"celsius"}, "degrees": {"celsius"}, "deg": {"celsius"}, "centigrade": {"celsius"},
+	"f": {"fahrenheit"}, "°f": {"fahrenheit"}, "k": {"kelvin"},
+	"millimeter": {"mm"}, "millimeters": {"mm"}, "millimetre": {"mm"}, "millimetres": {"mm"},
+	"centimeter": {"cm"}, "centimeters": {"cm"}, "centimetre": {"cm"}, "centimetres": {"cm"},
+	"meter": {"m"}, "meters": {"m"}, "metre": {"m"}, "metres": {"m"},
+	"kilometer": {"km"}, "kilometers": {"km"}, "kilometre": {"km"}, "kilometres": {"km"},
+	"inch": {"in"}, "inches": {"in"}, "foot": {"ft"}, "feet": {"ft"},
+	"$": {"USD"}, "dollar": {"USD"}, "dollars": {"USD"},
+	"€": {"EUR"}, "euro": {"EUR"}, "euros": {"EUR"},
+	"£": {"GBP"}, "¥": {"JPY"}, "yen": {"JPY"},
+	"pct": {"percent"}, "rh": {"percent"},
+}
+
+// NormalizeUnit resolves a spoken or abbreviated unit against a list of valid
+// units. Returns the valid unit's canonical spelling and whether it matched.
+func NormalizeUnit(raw string, validUnits []string) (string, bool) {
+	raw = strings.ToLower(strings.TrimSpace(raw))
+	if raw == "" {
+		return "", false
+	}
+	candidates := append([]string{raw}, unitSynonyms[raw]...)
+	for _, c := range candidates {
+		for _, u := range validUnits {
+			if strings.EqualFold(u, c) {
+				return u, true
+			}
+		}
+	}
+	return "", false
+}
+
+// maxQuantityGap is how many words may separate a field alias from its
+// quantity, as in "weighs about 3 kg" or "5 kg in weight".
+const maxQuantityGap = 2
+
+// parseQuantity finds a {value, unit} or {min, max, unit} quantity next to an
+// occurrence of alias in lowercased text: touching it, or at most
+// maxQuantityGap words away. The unit must be valid for the field; a bare
+// alias that is itself a unit ("degrees") counts as both. Ranges win over
+// single amounts, and the phrase nearest the alias wins within each.
+// Phrases are searched in remaining, text with phrases other fields took
+// blanked out; distances are measured in text. Returns the matched phrase so
+// callers can mark tokens.
+func parseQuantity(text, remaining, alias string, validUnits []string) (map[string]interface{}, string, bool) {
+	var spans [][2]int
+	for i := 0; alias != ""; {
+		j := strings.Index(text[i:], alias)
+		if j < 0 {
+			break
+		}
+		spans = append(spans, [2]int{i + j, i + j + len(alias)})
+		i += j + len(alias)
+	}
+	if len(spans) == 0 {
+		return nil, "", false
+	}
+
+	// distance counts the words between a phrase and the nearest alias. The
+	// rest of a word the alias starts ("cost" in "costs") is not counted.
+	distance := func(start, end int) (int, bool) {
+		best, found := 0, false
+		for _, a := range spans {
+			var between string
+			switch {
+			case end <= a[0]:
+				between = text[end:a[0]]
+			case start >= a[1]:
+				between = strings.TrimLeft(text[a[1]:start], "abcdefghijklmnopqrstuvwxyz")
+			}
+			if n := len(strings.Fields(between)); n <= maxQuantityGap && (!found || n < best) {
+				best, found = n, true
+			}
+		}
+		return best, found
+	}
+
+	unitOf := func(word, qualifier string) (string, bool) {
+		if qualifier != "" {
+			return NormalizeUnit(qualifier, validUnits)
+		}
+		return NormalizeUnit(word, validUnits)
+	}
+	group := func(m []int, i int) string {
+		if m[2*i] < 0 {
+			return ""
+		}
+		return remaining[m[2*i]:m[2*i+1]]
+	}
+
+	var best map[string]interface{}
+	var phrase string
+	bestGap := -1
+	consider := func(m []int, q map[string]interface{}) {
+		if gap, ok := distance(m[0], m[1]); ok && (bestGap < 0 || gap < bestGap) {
+			best, phrase, bestGap = q, remaining[m[0]:m[1]], gap
+		}
+	}
+
+	for _, re := range []*regexp.Regexp{rangeRe, dashRange} {
+		for _, m := range re.FindAllStringSubmatchIndex(remaining, -1) {
+			unit, ok := unitOf(group(m, 3), group(m, 4))
+			if !ok {
+				continue
+			}
+			lo, err1 := strconv.ParseFloat(group(m, 1), 64)
+			hi, err2 := strconv.ParseFloat(group(m, 2), 64)
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			consider(m, map[string]interface{}{"min": lo, "max": hi, "unit": unit})
+		}
+	}
+	if best != nil {
+		return best, phrase, true
+	}
+
+	for _, m := range currencyRe.FindAllStringSubmatchIndex(remaining, -1) {
+		unit, ok := NormalizeUnit(group(m, 1), validUnits)
+		if !ok {
+			continue
+		}
+		if v, err := strconv.ParseFloat(group(m, 2), 64); err == nil {
+			consider(m, map[string]interface{}{"value": v, "unit": unit})
+		}
+	}
+	if best != nil {
+		return best, phrase, true
+	}
+
+	for _, m := range amountRe.FindAllStringSubmatchIndex(remaining, -1) {
+		unit, ok := unitOf(group(m, 2), group(m, 3))
+		if !ok {
+			continue
+		}
+		if v, err := strconv.ParseFloat(group(m, 1), 64); err == nil {
+			consider(m, map[string]interface{}{"value": v, "unit": unit})
+		}
+	}
+	return best, phrase, best != nil
+}
+
+// markUsed flags every token that appears in phrase as consumed.
+func markUsed(tokens []string, used map[int]bool, phrase string) {
+	for _, p := range splitTokens(phrase) {
+		for i, tok := range tokens {
+			if tok == p && !used[i] {
+				used[i] = true
+				break
+			}
+		}
+	}
+}
+
 func splitTokens(s string) []string {
 	re := regexp.MustCompile(`[\s,;]+`)
 	parts := re.Split(s, -1)
@@ -0,0 +1,76 @@
+package foodblock
+
+import "testing"
+
+func TestMapFieldsQuantityValueAndUnit(t *testing.T) {
+	result := MapFields("weighs 2.5 kg", Vocabularies["units"])
+	q, ok := result.Matched["weight"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected weight quantity, got %v", result.Matched["weight"])
+	}
+	if q["value"] != 2.5 || q["unit"] != "kg" {
+		t.Errorf("expected 2.5 kg, got %v", q)
+	}
+	if len(result.Unmatched) != 0 {
+		t.Errorf("expected all tokens used, got unmatched %v", result.Unmatched)
+	}
+}
+
+func TestMapFieldsQuantityNormalizesUnit(t *testing.T) {
+	result := MapFields("volume 3 litres", Vocabularies["units"])
+	q, ok := result.Matched["volume"].(map[string]interface{})
+	if !ok || q["unit"] != "l" || q["value"] != 3.0 {
+		t.Errorf("expected 3 l, got %v", result.Matched["volume"])
+	}
+}
+
+func TestMapFieldsQuantityRange(t *testing.T) {
+	result := MapFields("keep between 2 and 4 degrees", Vocabularies["units"])
+	q, ok := result.Matched["temperature"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected temperature range, got %v", result.Matched)
+	}
+	if q["min"] != 2.0 || q["max"] != 4.0 || q["unit"] != "celsius" {
+		t.Errorf("expected 2-4 celsius, got %v", q)
+	}
+}
+
+func TestMapFieldsQuantityRejectsInvalidUnit(t *testing.T) {
+	result := MapFields("weight 5 parsecs", Vocabularies["units"])
+	if _, ok := result.Matched["weight"]; ok {
+		t.Errorf("expected no weight for invalid unit, got %v", result.Matched["weight"])
+	}
+}
+
+func TestNormalizeUnitContextual(t *testing.T) {
+	if u, _ := NormalizeUnit("pounds", Vocabularies["units"].Fields["weight"].ValidUnits); u != "lb" {
+		t.Errorf("expected lb for weight, got %q", u)
+	}
+	if u, _ := NormalizeUnit("pounds", Vocabularies["units"].Fields["currency"].ValidUnits); u != "GBP" {
+		t.Errorf("expected GBP for currency, got %q", u)
+	}
+}
+
+func TestMapFieldsQuantityFillsOneField(t *testing.T) {
+	for text, want := range map[string]string{"costs 5 pounds": "currency", "weighs 3 pounds": "weight"} {
+		result := MapFields(text, Vocabularies["units"])
+		if len(result.Matched) != 1 || result.Matched[want] == nil {
+			t.Errorf("%q matched %v, want only %s", text, result.Matched, want)
+		}
+	}
+}
+
+func TestMapFieldsQuantityTakesAmountNextToAlias(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		result := MapFields("weighs 3 pounds and costs 5 pounds", Vocabularies["units"])
+		weight, _ := result.Matched["weight"].(map[string]interface{})
+		currency, _ := result.Matched["currency"].(map[string]interface{})
+		if weight["value"] != 3.0 || weight["unit"] != "lb" || currency["value"] != 5.0 || currency["unit"] != "GBP" {
+			t.Fatalf("run %d: weight %v, currency %v", i, weight, currency)
+		}
+	}
+	// An amount far from the alias is not taken.
+	if result := MapFields("weight was not recorded when we delivered 3 kg of flour", Vocabularies["units"]); result.Matched["weight"] != nil {
+		t.Errorf("took a distant amount: %v", result.Matched["weight"])
+	}
+}
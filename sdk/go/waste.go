@@ -0,0 +1,255 @@
+package foodblock
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WasteType records food leaving the supply chain as waste or loss.
+const WasteType = "transform.waste"
+
+// Disposal routes, roughly in order of the food waste hierarchy.
+const (
+	RouteDonation           = "donation"
+	RouteAnimalFeed         = "animal_feed"
+	RouteAnaerobicDigestion = "anaerobic_digestion"
+	RouteCompost            = "compost"
+	RouteIncineration       = "incineration"
+	RouteSewer              = "sewer"
+	RouteLandfill           = "landfill"
+)
+
+// WasteRouteEmissions are indicative emission factors in kg CO2e per kg of
+// food for each disposal route, used for WasteReport.EmissionsKgCO2e.
+// Negative values credit avoided emissions. Override to match a reporting
+// methodology.
+var WasteRouteEmissions = map[string]float64{
+	RouteDonation:           0,
+	RouteAnimalFeed:         0.02,
+	RouteAnaerobicDigestion: -0.03,
+	RouteCompost:            0.05,
+	RouteIncineration:       0.02,
+	RouteSewer:              0.1,
+	RouteLandfill:           0.58,
+}
+
+// Waste describes one waste event.
+type Waste struct {
+	Source   string  // wasted item (substance, surplus, lot), optional
+	Venue    string  // venue or actor where the waste arose
+	Quantity float64 // in Unit
+	Unit     string  // mass unit, default kg
+	Reason   string  // e.g. expired, spoiled, overproduction, damaged, plate_waste
+	Route    string  // disposal route, one of the Route constants
+	Date     time.Time
+}
+
+// CreateWaste creates a transform.waste block.
+func CreateWaste(w Waste) (Block, error) {
+	if w.Venue == "" {
+		return Block{}, errors.New("FoodBlock: waste needs a venue")
+	}
+	if w.Quantity <= 0 {
+		return Block{}, errors.New("FoodBlock: waste quantity must be positive")
+	}
+	if w.Unit == "" {
+		w.Unit = "kg"
+	}
+	if _, ok := toKilograms(1, w.Unit); !ok {
+		return Block{}, fmt.Errorf("FoodBlock: unsupported waste unit %q", w.Unit)
+	}
+	if w.Route == "" {
+		return Block{}, errors.New("FoodBlock: waste needs a disposal route")
+	}
+	if w.Reason == "" {
+		w.Reason = "unspecified"
+	}
+	if w.Date.IsZero() {
+		w.Date = time.Now()
+	}
+	refs := map[string]interface{}{"venue": w.Venue}
+	if w.Source != "" {
+		refs["source"] = w.Source
+	}
+	return Create(WasteType, map[string]interface{}{
+		"quantity":       w.Quantity,
+		"unit":           w.Unit,
+		"reason":         w.Reason,
+		"disposal_route": w.Route,
+		"date":           w.Date.Format("2006-01-02"),
+	}, refs), nil
+}
+
+// WasteFromInventory records an inventory item (a substance or
+// substance.surplus block) as wasted in full. The reason is "expired" when the
+// item's expiry_date, use_by or best_before is before now, "surplus" for
+// surplus blocks, and "spoiled" otherwise.
+func WasteFromInventory(item Block, venue, route string, now time.Time) (Block, error) {
+	qty, unit, ok := blockMass(item)
+	if !ok {
+		return Block{}, fmt.Errorf("FoodBlock: %s has no quantity to waste", item.Hash)
+	}
+	reason := "spoiled"
+	if item.Type == "substance.surplus" {
+		reason = "surplus"
+	}
+	for _, field := range []string{"expiry_date", "use_by", "best_before"} {
+		if s, ok := item.State[field].(string); ok {
+			if t, err := time.Parse("2006-01-02", s); err == nil && t.Before(now) {
+				reason = "expired"
+				break
+			}
+		}
+	}
+	return CreateWaste(Waste{Source: item.Hash, Venue: venue, Quantity: qty, Unit: unit, Reason: reason, Route: route, Date: now})
+}
+
+// WasteReport aggregates waste for a venue over a period.
+type WasteReport struct {
+	Venue           string             `json:"venue,omitempty"`
+	From            string             `json:"from,omitempty"`
+	To              string             `json:"to,omitempty"`
+	TotalKg         float64            `json:"total_kg"`
+	ByReason        map[string]float64 `json:"by_reason"`
+	ByRoute         map[string]float64 `json:"by_route"`
+	DonatedKg       float64            `json:"donated_kg"`
+	LandfilledKg    float64            `json:"landfilled_kg"`
+	DiversionRate   float64            `json:"diversion_rate"` // share of total kept out of landfill
+	EmissionsKgCO2e float64            `json:"emissions_kg_co2e"`
+	Events          int                `json:"events"`
+}
+
+// ReportWaste aggregates transform.waste blocks, and transfer.donation blocks
+// whose refs.source is the venue, into a report. An empty venue includes all
+// venues; zero times leave the period open. Donations count as the donation
+// route with reason "surplus".
+func ReportWaste(blocks []Block, venue string, from, to time.Time) WasteReport {
+	r := WasteReport{Venue: venue, ByReason: map[string]float64{}, ByRoute: map[string]float64{}}
+	if !from.IsZero() {
+		r.From = from.Format("2006-01-02")
+	}
+	if !to.IsZero() {
+		r.To = to.Format("2006-01-02")
+	}
+	for _, b := range blocks {
+		var place, reason, route string
+		switch b.Type {
+		case WasteType:
+			place, _ = b.Refs["venue"].(string)
+			reason, _ = b.State["reason"].(string)
+			route, _ = b.State["disposal_route"].(string)
+		case "transfer.donation":
+			place, _ = b.Refs["source"].(string)
+			reason, route = "surplus", RouteDonation
+		default:
+			continue
+		}
+		if venue != "" && place != venue {
+			continue
+		}
+		if date, ok := BlockDate(b); ok {
+			if !from.IsZero() && date.Before(from) || !to.IsZero() && date.After(to) {
+				continue
+			}
+		} else if !from.IsZero() || !to.IsZero() {
+			continue
+		}
+		qty, unit, ok := blockMass(b)
+		if !ok {
+			continue
+		}
+		kg, ok := toKilograms(qty, unit)
+		if !ok {
+			continue
+		}
+		r.Events++
+		r.TotalKg += kg
+		r.ByReason[reason] += kg
+		r.ByRoute[route] += kg
+		r.EmissionsKgCO2e += kg * WasteRouteEmissions[route]
+		switch route {
+		case RouteDonation:
+			r.DonatedKg += kg
+		case RouteLandfill:
+			r.LandfilledKg += kg
+		}
+	}
+	if r.TotalKg > 0 {
+		r.DiversionRate = 1 - r.LandfilledKg/r.TotalKg
+	}
+	return r
+}
+
+// String renders the report as plain text, largest reasons first.
+func (r WasteReport) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Waste %s to %s: %.1f kg over %d event(s)\n", orOpen(r.From), orOpen(r.To), r.TotalKg, r.Events)
+	fmt.Fprintf(&sb, "Donated %.1f kg, landfilled %.1f kg, diversion %.0f%%, %.1f kg CO2e\n",
+		r.DonatedKg, r.LandfilledKg, r.DiversionRate*100, r.EmissionsKgCO2e)
+	reasons := make([]string, 0, len(r.ByReason))
+	for k := range r.ByReason {
+		reasons = append(reasons, k)
+	}
+	sort.Slice(reasons, func(i, j int) bool {
+		if r.ByReason[reasons[i]] != r.ByReason[reasons[j]] {
+			return r.ByReason[reasons[i]] > r.ByReason[reasons[j]]
+		}
+		return reasons[i] < reasons[j]
+	})
+	for _, k := range reasons {
+		fmt.Fprintf(&sb, "  %-16s %8.1f kg\n", k, r.ByReason[k])
+	}
+	return sb.String()
+}
+
+func orOpen(date string) string {
+	if date == "" {
+		return "…"
+	}
+	return date
+}
+
+// blockMass reads a quantity and unit from state.quantity (number or
+// {value, unit}) with state.unit, falling back to state.weight.
+func blockMass(b Block) (float64, string, bool) {
+	for _, field := range []string{"quantity", "weight"} {
+		v, ok := numericValue(b.State[field])
+		if !ok {
+			continue
+		}
+		unit := ""
+		if m, ok := b.State[field].(map[string]interface{}); ok {
+			unit, _ = m["unit"].(string)
+		}
+		if unit == "" {
+			unit, _ = b.State["unit"].(string)
+		}
+		if unit == "" {
+			unit = "kg"
+		}
+		return v, unit, true
+	}
+	return 0, "", false
+}
+
+// toKilograms converts a mass to kilograms.
+func toKilograms(v float64, unit string) (float64, bool) {
+	switch strings.ToLower(unit) {
+	case "kg":
+		return v, true
+	case "g":
+		return v / 1000, true
+	case "mg":
+		return v / 1e6, true
+	case "t", "ton", "tonne":
+		return v * 1000, true
+	case "lb":
+		return v * 0.45359237, true
+	case "oz":
+		return v * 0.028349523125, true
+	}
+	return 0, false
+}
@@ -0,0 +1,63 @@
+package foodblock
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWasteFromInventory(t *testing.T) {
+	now := time.Date(2026, 5, 10, 18, 0, 0, 0, time.UTC)
+	milk := Create("substance.product", map[string]interface{}{"name": "Milk", "quantity": 12.0, "unit": "kg", "use_by": "2026-05-09"}, nil)
+	waste, err := WasteFromInventory(milk, "cafe", RouteSewer, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if waste.Type != WasteType || waste.State["reason"] != "expired" || waste.Refs["source"] != milk.Hash {
+		t.Errorf("unexpected waste block %+v", waste)
+	}
+	bread := Create("substance.surplus", map[string]interface{}{"name": "Bread", "quantity": map[string]interface{}{"value": 800.0, "unit": "g"}}, nil)
+	waste, _ = WasteFromInventory(bread, "cafe", RouteCompost, now)
+	if waste.State["reason"] != "surplus" || waste.State["unit"] != "g" {
+		t.Errorf("unexpected surplus waste %+v", waste.State)
+	}
+	if _, err := CreateWaste(Waste{Venue: "cafe", Quantity: 1, Unit: "litres", Route: RouteSewer}); err == nil {
+		t.Error("expected unsupported unit error")
+	}
+}
+
+func TestReportWaste(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 5, d, 0, 0, 0, 0, time.UTC) }
+	mk := func(venue string, kg float64, reason, route string, d int) Block {
+		b, err := CreateWaste(Waste{Venue: venue, Quantity: kg, Reason: reason, Route: route, Date: day(d)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	blocks := []Block{
+		mk("cafe", 10, "expired", RouteLandfill, 2),
+		mk("cafe", 5, "plate_waste", RouteCompost, 3),
+		mk("cafe", 100, "expired", RouteLandfill, 20), // outside period
+		mk("deli", 7, "spoiled", RouteLandfill, 3),    // other venue
+		Create("transfer.donation", map[string]interface{}{"instance_id": "d1", "quantity": 5000.0, "unit": "g", "date": "2026-05-04"},
+			map[string]interface{}{"source": "cafe"}),
+	}
+	r := ReportWaste(blocks, "cafe", day(1), day(10))
+	if r.Events != 3 || r.TotalKg != 20 || r.DonatedKg != 5 || r.LandfilledKg != 10 {
+		t.Fatalf("unexpected report %+v", r)
+	}
+	if r.ByReason["expired"] != 10 || r.ByRoute[RouteCompost] != 5 || r.DiversionRate != 0.5 {
+		t.Errorf("unexpected breakdown %+v", r)
+	}
+	if want := 10*WasteRouteEmissions[RouteLandfill] + 5*WasteRouteEmissions[RouteCompost]; math.Abs(r.EmissionsKgCO2e-want) > 1e-9 {
+		t.Errorf("expected %v kg CO2e, got %v", want, r.EmissionsKgCO2e)
+	}
+	if !strings.Contains(r.String(), "expired") {
+		t.Errorf("report text missing reasons:\n%s", r)
+	}
+	if all := ReportWaste(blocks, "", time.Time{}, time.Time{}); all.TotalKg != 127 {
+		t.Errorf("expected 127 kg across venues, got %v", all.TotalKg)
+	}
+}
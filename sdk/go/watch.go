@@ -0,0 +1,400 @@
+package foodblock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WatchlistType lists what an actor follows. refs.owner is the actor;
+// state.hashes are followed blocks (a block matches when it refs one under
+// any role), state.types are type patterns and state.queries QueryParams a
+// block must satisfy on its own. A watchlist is retired by updating it with
+// active set to false.
+const WatchlistType = "observe.watchlist"
+
+// Watchlist is the content of a watchlist block.
+type Watchlist struct {
+	Name    string        `json:"name,omitempty"`
+	Hashes  []string      `json:"hashes,omitempty"`
+	Types   []string      `json:"types,omitempty"`
+	Queries []QueryParams `json:"queries,omitempty"`
+}
+
+// CreateWatchlist creates a watchlist block for owner.
+func CreateWatchlist(owner string, w Watchlist) (Block, error) {
+	if owner == "" {
+		return Block{}, errors.New("FoodBlock: a watchlist needs an owner")
+	}
+	if len(w.Hashes) == 0 && len(w.Types) == 0 && len(w.Queries) == 0 {
+		return Block{}, errors.New("FoodBlock: a watchlist needs hashes, types or queries")
+	}
+	data, err := json.Marshal(w)
+	if err != nil {
+		return Block{}, err
+	}
+	var state map[string]interface{}
+	json.Unmarshal(data, &state)
+	return Create(WatchlistType, state, map[string]interface{}{"owner": owner}), nil
+}
+
+// WatchlistFromBlock reads a watchlist block.
+func WatchlistFromBlock(b Block) (Watchlist, error) {
+	if b.Type != WatchlistType {
+		return Watchlist{}, fmt.Errorf("FoodBlock: %s is not a watchlist", b.Type)
+	}
+	data, err := json.Marshal(b.State)
+	if err != nil {
+		return Watchlist{}, err
+	}
+	var w Watchlist
+	if err := json.Unmarshal(data, &w); err != nil {
+		return Watchlist{}, fmt.Errorf("FoodBlock: watchlist %s: %w", b.Hash, err)
+	}
+	return w, nil
+}
+
+// WatchMatch is a block matching one of an owner's watchlists.
+type WatchMatch struct {
+	Owner     string `json:"owner"`
+	Watchlist string `json:"watchlist"`
+	Reason    string `json:"reason"` // "ref <hash>", "type <pattern>" or "query <index>"
+}
+
+type watchEntry struct {
+	owner, hash string
+	list        Watchlist
+}
+
+// WatchMatcher evaluates blocks against many watchlists. Followed hashes and
+// exact types are indexed, so a block costs one lookup per ref plus a scan of
+// the type patterns and queries only.
+type WatchMatcher struct {
+	mu       sync.RWMutex
+	entries  map[string]*watchEntry // watchlist hash -> entry
+	byRef    map[string][]*watchEntry
+	byType   map[string][]*watchEntry
+	patterns []*watchEntry // entries with wildcard types or queries
+}
+
+// NewWatchMatcher builds a matcher over the active watchlists in store.
+func NewWatchMatcher(store BlockStore) *WatchMatcher {
+	m := &WatchMatcher{}
+	m.Load(FilterBlocks(store.Blocks(), QueryParams{Type: WatchlistType, HeadsOnly: true}))
+	return m
+}
+
+// Load replaces the matcher's watchlists with the given watchlist blocks,
+// which should be chain heads. Inactive and malformed ones are ignored.
+func (m *WatchMatcher) Load(watchlists []Block) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries = map[string]*watchEntry{}
+	for _, b := range watchlists {
+		m.addLocked(b)
+	}
+	m.reindexLocked()
+}
+
+// Observe keeps the matcher current as blocks arrive: a new watchlist
+// version replaces the one it updates.
+func (m *WatchMatcher) Observe(b Block) {
+	if b.Type != WatchlistType {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if prev, ok := b.Refs["updates"].(string); ok {
+		delete(m.entries, prev)
+	}
+	m.addLocked(b)
+	m.reindexLocked()
+}
+
+func (m *WatchMatcher) addLocked(b Block) {
+	if active, ok := b.State["active"].(bool); ok && !active {
+		return
+	}
+	owner, _ := b.Refs["owner"].(string)
+	w, err := WatchlistFromBlock(b)
+	if err != nil || owner == "" {
+		return
+	}
+	m.entries[b.Hash] = &watchEntry{owner: owner, hash: b.Hash, list: w}
+}
+
+func (m *WatchMatcher) reindexLocked() {
+	m.byRef = map[string][]*watchEntry{}
+	m.byType = map[string][]*watchEntry{}
+	m.patterns = nil
+	hashes := make([]string, 0, len(m.entries))
+	for h := range m.entries {
+		hashes = append(hashes, h)
+	}
+	sort.Strings(hashes)
+	for _, h := range hashes {
+		e := m.entries[h]
+		for _, ref := range e.list.Hashes {
+			m.byRef[ref] = append(m.byRef[ref], e)
+		}
+		scan := len(e.list.Queries) > 0
+		for _, t := range e.list.Types {
+			if strings.Contains(t, "*") {
+				scan = true
+			} else {
+				m.byType[t] = append(m.byType[t], e)
+			}
+		}
+		if scan {
+			m.patterns = append(m.patterns, e)
+		}
+	}
+}
+
+// Match returns the watchlists a block matches, at most one match per
+// watchlist. Watchlist blocks themselves never match.
+func (m *WatchMatcher) Match(b Block) []WatchMatch {
+	if b.Type == WatchlistType {
+		return nil
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	seen := map[string]bool{}
+	var out []WatchMatch
+	add := func(e *watchEntry, reason string) {
+		if !seen[e.hash] {
+			seen[e.hash] = true
+			out = append(out, WatchMatch{Owner: e.owner, Watchlist: e.hash, Reason: reason})
+		}
+	}
+	for _, ref := range uniqueRefValues(b.Refs) {
+		for _, e := range m.byRef[ref] {
+			add(e, "ref "+ref)
+		}
+	}
+	for _, e := range m.byType[b.Type] {
+		add(e, "type "+b.Type)
+	}
+	for _, e := range m.patterns {
+		for _, t := range e.list.Types {
+			if strings.Contains(t, "*") && matchesType(b.Type, t) {
+				add(e, "type "+t)
+			}
+		}
+		for i, q := range e.list.Queries {
+			q.HeadsOnly, q.Limit, q.Offset = false, 0, 0
+			if len(FilterBlocks([]Block{b}, q)) == 1 {
+				add(e, fmt.Sprintf("query %d", i))
+			}
+		}
+	}
+	return out
+}
+
+// WatchContact is where and how often an owner is notified. A zero Digest
+// sends each match as it arrives; otherwise matches are batched and sent at
+// most once per Digest.
+type WatchContact struct {
+	Channel string // "email" or "sms"
+	To      string
+	Locale  string
+	Digest  time.Duration
+}
+
+// WatchTemplates holds the watch notification templates per locale. They
+// see .Count and .Items, each with .Name, .Type, .Hash and .Reason.
+var WatchTemplates = map[string]NotificationTemplate{
+	"en": {
+		Subject: "{{.Count}} update{{if gt .Count 1}}s{{end}} on your watchlist",
+		Body:    "{{range .Items}}- {{.Name}} ({{.Type}}, {{.Reason}}) fb:{{.Hash}}\n{{end}}",
+		SMS:     "{{.Count}} watchlist update{{if gt .Count 1}}s{{end}}{{range .Items}}; {{.Name}}{{end}}",
+	},
+	"fr": {
+		Subject: "{{.Count}} mise{{if gt .Count 1}}s{{end}} à jour de votre liste de suivi",
+		Body:    "{{range .Items}}- {{.Name}} ({{.Type}}, {{.Reason}}) fb:{{.Hash}}\n{{end}}",
+		SMS:     "{{.Count}} mise{{if gt .Count 1}}s{{end}} à jour{{range .Items}} ; {{.Name}}{{end}}",
+	},
+}
+
+type watchPending struct {
+	since  time.Time
+	blocks []Block
+	why    map[string]string // block hash -> reason
+}
+
+// WatchNotifier fans matched blocks out to watchlist owners. It is a
+// Publisher, so a Flow's publish stage can feed it. Owners are not notified
+// about blocks they authored themselves.
+type WatchNotifier struct {
+	Matcher *WatchMatcher
+	Contact func(owner string) (WatchContact, bool)
+	Sender  NotificationSender
+	Now     func() time.Time
+
+	mu      sync.Mutex
+	pending map[string]*watchPending // owner -> queued matches
+}
+
+// Publish notifies the owners watching a stored block.
+func (n *WatchNotifier) Publish(ctx context.Context, signed SignedBlock) error {
+	_, err := n.Notify(ctx, signed.FoodBlock, signed.AuthorHash)
+	return err
+}
+
+// Notify matches a block, sends immediate notifications and queues digest
+// ones. It returns the observe.delivery blocks of what was sent.
+func (n *WatchNotifier) Notify(ctx context.Context, b Block, author string) ([]Block, error) {
+	n.Matcher.Observe(b)
+	byOwner := map[string][]string{}
+	var owners []string
+	for _, m := range n.Matcher.Match(b) {
+		if m.Owner == author {
+			continue
+		}
+		if _, ok := byOwner[m.Owner]; !ok {
+			owners = append(owners, m.Owner)
+		}
+		byOwner[m.Owner] = append(byOwner[m.Owner], m.Reason)
+	}
+
+	var deliveries []Block
+	var errs []string
+	for _, owner := range owners {
+		contact, ok := n.Contact(owner)
+		if !ok {
+			continue
+		}
+		reason := strings.Join(byOwner[owner], ", ")
+		if contact.Digest > 0 {
+			n.queue(owner, b, reason)
+			continue
+		}
+		d, err := n.send(ctx, contact, []Block{b}, map[string]string{b.Hash: reason}, b.Hash)
+		if d.Hash != "" {
+			deliveries = append(deliveries, d)
+		}
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return deliveries, fmt.Errorf("FoodBlock: watch notifications failed: %s", strings.Join(errs, "; "))
+	}
+	return deliveries, nil
+}
+
+func (n *WatchNotifier) queue(owner string, b Block, reason string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.pending == nil {
+		n.pending = map[string]*watchPending{}
+	}
+	p := n.pending[owner]
+	if p == nil {
+		p = &watchPending{since: n.now(), why: map[string]string{}}
+		n.pending[owner] = p
+	}
+	if _, dup := p.why[b.Hash]; !dup {
+		p.blocks = append(p.blocks, b)
+	}
+	p.why[b.Hash] = reason
+}
+
+// Flush sends the digests that are due: those whose oldest queued match is
+// at least the owner's Digest old. force sends every pending digest.
+func (n *WatchNotifier) Flush(ctx context.Context, force bool) ([]Block, error) {
+	n.mu.Lock()
+	now := n.now()
+	due := map[string]*watchPending{}
+	var owners []string
+	for owner, p := range n.pending {
+		contact, ok := n.Contact(owner)
+		if !ok {
+			delete(n.pending, owner)
+			continue
+		}
+		if force || !now.Before(p.since.Add(contact.Digest)) {
+			due[owner] = p
+			owners = append(owners, owner)
+			delete(n.pending, owner)
+		}
+	}
+	n.mu.Unlock()
+	sort.Strings(owners)
+
+	var deliveries []Block
+	var errs []string
+	for _, owner := range owners {
+		contact, _ := n.Contact(owner)
+		p := due[owner]
+		d, err := n.send(ctx, contact, p.blocks, p.why, p.blocks[0].Hash)
+		if d.Hash != "" {
+			deliveries = append(deliveries, d)
+		}
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return deliveries, fmt.Errorf("FoodBlock: watch digests failed: %s", strings.Join(errs, "; "))
+	}
+	return deliveries, nil
+}
+
+func (n *WatchNotifier) send(ctx context.Context, contact WatchContact, blocks []Block, why map[string]string, subject string) (Block, error) {
+	note, err := RenderWatchNotification(blocks, why, contact.Channel, contact.Locale, contact.To)
+	if err != nil {
+		return Block{}, err
+	}
+	note.AlertHash = subject
+	return Deliver(ctx, n.Sender, note)
+}
+
+func (n *WatchNotifier) now() time.Time {
+	if n.Now != nil {
+		return n.Now()
+	}
+	return time.Now()
+}
+
+// RenderWatchNotification renders matched blocks as one notification, with
+// the reason each matched. The alert hash is the first block's.
+func RenderWatchNotification(blocks []Block, why map[string]string, channel, locale, to string) (Notification, error) {
+	if len(blocks) == 0 {
+		return Notification{}, errors.New("FoodBlock: nothing to notify")
+	}
+	if channel != "email" && channel != "sms" {
+		return Notification{}, fmt.Errorf("FoodBlock: unknown notification channel: %s", channel)
+	}
+	if _, ok := WatchTemplates[locale]; !ok {
+		locale = "en"
+	}
+	tmpl := WatchTemplates[locale]
+	items := make([]interface{}, len(blocks))
+	for i, b := range blocks {
+		items[i] = map[string]interface{}{"Name": blockLabel(Localize(b, locale, "en")), "Type": b.Type, "Hash": b.Hash, "Reason": why[b.Hash]}
+	}
+	data := map[string]interface{}{"Count": len(blocks), "Items": items}
+
+	n := Notification{Channel: channel, To: to, Locale: locale, AlertHash: blocks[0].Hash}
+	var err error
+	if channel == "email" {
+		if n.Subject, err = renderTemplate(tmpl.Subject, data); err != nil {
+			return Notification{}, err
+		}
+		n.Body, err = renderTemplate(tmpl.Body, data)
+		return n, err
+	}
+	if n.Body, err = renderTemplate(tmpl.SMS, data); err != nil {
+		return Notification{}, err
+	}
+	if r := []rune(n.Body); len(r) > smsMaxLen {
+		n.Body = string(r[:smsMaxLen-1]) + "…"
+	}
+	return n, nil
+}
@@ -0,0 +1,114 @@
+package foodblock
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+type recordingSender struct{ sent []Notification }
+
+func (r *recordingSender) Send(ctx context.Context, n Notification) (string, error) {
+	r.sent = append(r.sent, n)
+	return "", nil
+}
+
+func TestWatchMatcher(t *testing.T) {
+	store := NewMemoryStore()
+	buyer := Create("actor.venue", map[string]interface{}{"name": "Corner Cafe"}, nil)
+	mill := Create("actor.producer", map[string]interface{}{"name": "Stone Mill"}, nil)
+	follow, err := CreateWatchlist(buyer.Hash, Watchlist{
+		Name:    "Suppliers",
+		Hashes:  []string{mill.Hash},
+		Types:   []string{"observe.recall", "substance.*"},
+		Queries: []QueryParams{{Type: "transfer.offer", StateFilters: []StateFilter{{Field: "price", Op: "lt", Value: 2.0}}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.PutAll([]Block{buyer, mill, follow})
+	m := NewWatchMatcher(store)
+
+	flour := Create("substance.ingredient", map[string]interface{}{"name": "Rye Flour"}, map[string]interface{}{"seller": mill.Hash})
+	if got := m.Match(flour); len(got) != 1 || got[0].Reason != "ref "+mill.Hash || got[0].Owner != buyer.Hash {
+		t.Errorf("ref match = %+v", got)
+	}
+	if got := m.Match(Create("observe.recall", map[string]interface{}{"reason": "glass"}, nil)); len(got) != 1 || got[0].Reason != "type observe.recall" {
+		t.Errorf("type match = %+v", got)
+	}
+	cheap := Create("transfer.offer", map[string]interface{}{"price": 1.5}, nil)
+	dear := Create("transfer.offer", map[string]interface{}{"price": 3.0}, nil)
+	if got := m.Match(cheap); len(got) != 1 || got[0].Reason != "query 0" {
+		t.Errorf("query match = %+v", got)
+	}
+	if got := m.Match(dear); len(got) != 0 {
+		t.Errorf("unexpected match = %+v", got)
+	}
+
+	// Retiring the watchlist stops matches.
+	m.Observe(Update(follow.Hash, WatchlistType, map[string]interface{}{"active": false, "types": []interface{}{"substance.*"}}, follow.Refs))
+	if got := m.Match(flour); len(got) != 0 {
+		t.Errorf("retired watchlist matched: %+v", got)
+	}
+	if _, err := CreateWatchlist(buyer.Hash, Watchlist{Name: "empty"}); err == nil {
+		t.Error("expected an error for an empty watchlist")
+	}
+}
+
+func TestWatchNotifierDigest(t *testing.T) {
+	store := NewMemoryStore()
+	cafe := Create("actor.venue", map[string]interface{}{"name": "Corner Cafe"}, nil)
+	deli := Create("actor.venue", map[string]interface{}{"name": "Deli"}, nil)
+	mill := Create("actor.producer", map[string]interface{}{"name": "Stone Mill"}, nil)
+	w1, _ := CreateWatchlist(cafe.Hash, Watchlist{Hashes: []string{mill.Hash}})
+	w2, _ := CreateWatchlist(deli.Hash, Watchlist{Types: []string{"substance.*"}})
+	store.PutAll([]Block{cafe, deli, mill, w1, w2})
+
+	now := time.Date(2026, 6, 1, 9, 0, 0, 0, time.UTC)
+	sender := &recordingSender{}
+	n := &WatchNotifier{
+		Matcher: NewWatchMatcher(store),
+		Sender:  sender,
+		Now:     func() time.Time { return now },
+		Contact: func(owner string) (WatchContact, bool) {
+			switch owner {
+			case cafe.Hash:
+				return WatchContact{Channel: "email", To: "cafe@example.com", Digest: time.Hour}, true
+			case deli.Hash:
+				return WatchContact{Channel: "sms", To: "+447700900001"}, true
+			}
+			return WatchContact{}, false
+		},
+	}
+
+	rye := Create("substance.ingredient", map[string]interface{}{"name": "Rye Flour"}, map[string]interface{}{"seller": mill.Hash})
+	spelt := Create("substance.ingredient", map[string]interface{}{"name": "Spelt Flour"}, map[string]interface{}{"seller": mill.Hash})
+	if err := n.Publish(context.Background(), SignedBlock{FoodBlock: rye, AuthorHash: mill.Hash}); err != nil {
+		t.Fatal(err)
+	}
+	deliveries, _ := n.Notify(context.Background(), spelt, mill.Hash)
+	if len(sender.sent) != 2 || sender.sent[0].Channel != "sms" || len(deliveries) != 1 {
+		t.Fatalf("immediate sends = %+v", sender.sent)
+	}
+
+	// The cafe's digest waits for its hour, then carries both matches.
+	if sent, _ := n.Flush(context.Background(), false); len(sent) != 0 {
+		t.Errorf("digest sent early: %v", sent)
+	}
+	now = now.Add(time.Hour)
+	sent, err := n.Flush(context.Background(), false)
+	if err != nil || len(sent) != 1 || sent[0].Refs["subject"] != rye.Hash {
+		t.Fatalf("digest = %v, %v", sent, err)
+	}
+	digest := sender.sent[2]
+	if digest.Subject != "2 updates on your watchlist" || !strings.Contains(digest.Body, "Spelt Flour") || !strings.Contains(digest.Body, "ref "+mill.Hash) {
+		t.Errorf("digest = %+v", digest)
+	}
+
+	// Owners are not told about their own blocks.
+	n.Notify(context.Background(), Create("substance.product", map[string]interface{}{"name": "Deli Sandwich"}, nil), deli.Hash)
+	if len(sender.sent) != 3 {
+		t.Errorf("self-authored block notified: %+v", sender.sent[3:])
+	}
+}
@@ -0,0 +1,290 @@
+package foodblock
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SnapshotSet is a snapshot block with the hashes it covers, which the
+// snapshot itself only records as a Merkle root.
+type SnapshotSet struct {
+	Snapshot Block
+	Hashes   []string
+}
+
+// VerificationBundle is what a "verify this product" badge needs, small
+// enough to render client side and complete enough to check offline with
+// VerifyBundle: the block itself, an inclusion proof in a snapshot, the
+// certifications in force, the seller's trust and any recalls.
+type VerificationBundle struct {
+	Hash           string          `json:"hash"`
+	Block          Block           `json:"block"`
+	Projection     *ProjectedBlock `json:"projection,omitempty"` // instead of Block when fields are withheld
+	Snapshot       *Block          `json:"snapshot,omitempty"`
+	Proof          []string        `json:"proof,omitempty"` // SnapshotProof from Hash to the snapshot's merkle_root
+	Certifications []Block         `json:"certifications"`
+	Trust          *BundleTrust    `json:"trust,omitempty"`
+	Recall         BundleRecall    `json:"recall"`
+	GeneratedAt    string          `json:"generated_at"`
+}
+
+// BundleTrust summarizes the trust of the actor selling or producing the
+// product.
+type BundleTrust struct {
+	Actor        string  `json:"actor"`
+	Name         string  `json:"name"`
+	Score        float64 `json:"score"`
+	MeetsMinimum bool    `json:"meets_minimum"`
+}
+
+// BundleRecall reports recalls naming the product or anything in its
+// provenance.
+type BundleRecall struct {
+	Recalled bool     `json:"recalled"`
+	Recalls  []string `json:"recalls,omitempty"`
+	Reasons  []string `json:"reasons,omitempty"`
+}
+
+// VerificationOptions configure BuildVerificationBundle.
+type VerificationOptions struct {
+	Snapshots   func() []SnapshotSet // the newest snapshot covering the product is used
+	TrustPolicy map[string]interface{}
+	Trust       *TrustEngine // scores the seller instead of ComputeTrust over the store
+	MaxDepth    int          // provenance depth searched for recalls, default 10
+	Now         func() time.Time
+
+	// Gateway decides which blocks VerificationHandler serves and which of
+	// their fields are shown, as for GatewayHandler.
+	Gateway GatewayOptions
+}
+
+// BuildVerificationBundle assembles the verification bundle for a block.
+func BuildVerificationBundle(hash string, store BlockStore, opts VerificationOptions) (VerificationBundle, error) {
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	now := opts.Now()
+	b := store.Resolve(hash)
+	if b == nil {
+		return VerificationBundle{}, fmt.Errorf("FoodBlock: block not found: %s", hash)
+	}
+	bundle := VerificationBundle{Hash: hash, Block: *b, Certifications: []Block{}, GeneratedAt: now.UTC().Format(time.RFC3339)}
+
+	if opts.Snapshots != nil {
+		sets := opts.Snapshots()
+		for i := len(sets) - 1; i >= 0; i-- {
+			if proof, ok := SnapshotProof(sets[i].Hashes, hash); ok {
+				snap := sets[i].Snapshot
+				bundle.Snapshot, bundle.Proof = &snap, proof
+				break
+			}
+		}
+	}
+
+	actor := firstRef(b.Refs, "seller", "producer", "source")
+	subjects := []string{hash}
+	if actor != "" {
+		subjects = append(subjects, actor)
+	}
+	for _, s := range subjects {
+		for _, c := range store.ResolveForward(s) {
+			if c.Type == "observe.certification" && c.Refs["subject"] == s && headOf(c, store) && certInForce(c, now) && !IsRevoked(c.Hash, store) {
+				bundle.Certifications = append(bundle.Certifications, c)
+			}
+		}
+	}
+	sort.Slice(bundle.Certifications, func(i, j int) bool { return bundle.Certifications[i].Hash < bundle.Certifications[j].Hash })
+
+	if a := store.Resolve(actor); a != nil {
+		var result TrustResult
+		if opts.Trust != nil {
+			result = opts.Trust.Score(actor)
+		} else {
+			result = ComputeTrust(actor, trustBlocks(store, store.Blocks()), opts.TrustPolicy)
+		}
+		bundle.Trust = &BundleTrust{Actor: actor, Name: blockLabel(*a), Score: result.Score, MeetsMinimum: result.MeetsMinimum}
+	}
+
+	for _, h := range ExplainStructured(hash, store.Resolve, opts.MaxDepth).Hashes() {
+		for _, r := range store.ResolveForward(h) {
+			if r.Type != "observe.recall" || !headOf(r, store) || containsStr(bundle.Recall.Recalls, r.Hash) {
+				continue
+			}
+			if status, _ := r.State["status"].(string); status == "closed" || status == "lifted" {
+				continue
+			}
+			if r.Refs["authority"] == h {
+				continue // issued by, not about, this block
+			}
+			bundle.Recall.Recalled = true
+			bundle.Recall.Recalls = append(bundle.Recall.Recalls, r.Hash)
+			if reason, ok := r.State["reason"].(string); ok {
+				bundle.Recall.Reasons = appendUnique(bundle.Recall.Reasons, reason)
+			}
+		}
+	}
+	return bundle, nil
+}
+
+// trustBlocks pairs blocks with their authors as the store records them.
+func trustBlocks(store BlockStore, blocks []Block) []TrustBlock {
+	authors, _ := store.(interface{ AuthorOf(string) string })
+	tb := make([]TrustBlock, len(blocks))
+	for i, b := range blocks {
+		tb[i] = TrustBlock{Block: b}
+		if authors != nil {
+			tb[i].AuthorHash = authors.AuthorOf(b.Hash)
+		}
+	}
+	return tb
+}
+
+// project shows the block as audience sees it and leaves out certifications
+// with fields the public may not see, which could not be checked by hash.
+func (b *VerificationBundle) project(audience string) {
+	if p := ProjectForAudience(b.Block, audience); p.Withheld > 0 {
+		b.Block, b.Projection = Block{}, &p
+	}
+	certs := []Block{}
+	for _, c := range b.Certifications {
+		if ProjectForAudience(c, AudiencePublic).Withheld == 0 {
+			certs = append(certs, c)
+		}
+	}
+	b.Certifications = certs
+}
+
+func certInForce(c Block, now time.Time) bool {
+	if until, ok := stateTime(c.State, "valid_until"); ok && until.Before(now) {
+		return false
+	}
+	if from, ok := stateTime(c.State, "valid_from"); ok && from.After(now) {
+		return false
+	}
+	return true
+}
+
+// BundleCheck is the outcome of VerifyBundle.
+type BundleCheck struct {
+	Valid  bool     `json:"valid"`
+	Issues []string `json:"issues,omitempty"`
+}
+
+// VerifyBundle checks a bundle without a store: every block matches its
+// hash, the proof links the block to the snapshot's root, the
+// certifications concern the block or its seller and are in force at now.
+// trustedRoots, when given, are the snapshot roots the verifier accepts
+// (from an anchor or its own snapshots); without them any snapshot is taken
+// at its word. Trust and recall status are reported as served.
+func VerifyBundle(bundle VerificationBundle, trustedRoots []string, now time.Time) BundleCheck {
+	var issues []string
+	block := bundle.Block
+	if p := bundle.Projection; p != nil {
+		if p.Hash != bundle.Hash || !VerifyProjection(*p) {
+			issues = append(issues, "projection does not match its proofs")
+		}
+		block = p.Block()
+	} else if block.Hash != bundle.Hash || Hash(block.Type, block.State, block.Refs) != bundle.Hash {
+		issues = append(issues, "block does not match its hash")
+	}
+	if s := bundle.Snapshot; s == nil {
+		if len(trustedRoots) > 0 {
+			issues = append(issues, "no snapshot proof")
+		}
+	} else {
+		root, _ := s.State["merkle_root"].(string)
+		switch {
+		case s.Type != "observe.snapshot" || Hash(s.Type, s.State, s.Refs) != s.Hash:
+			issues = append(issues, "snapshot does not match its hash")
+		case !VerifySnapshotProof(bundle.Hash, bundle.Proof, root):
+			issues = append(issues, "block is not in the snapshot")
+		case len(trustedRoots) > 0 && !containsStr(trustedRoots, root):
+			issues = append(issues, "snapshot root "+root+" is not trusted")
+		}
+	}
+	seller := firstRef(block.Refs, "seller", "producer", "source")
+	for _, c := range bundle.Certifications {
+		subject, _ := c.Refs["subject"].(string)
+		switch {
+		case c.Type != "observe.certification" || Hash(c.Type, c.State, c.Refs) != c.Hash:
+			issues = append(issues, "certification "+c.Hash+" does not match its hash")
+		case subject == "" || (subject != bundle.Hash && subject != seller):
+			issues = append(issues, "certification "+c.Hash+" is about another block")
+		case !certInForce(c, now):
+			issues = append(issues, "certification "+blockLabel(c)+" is not in force")
+		}
+	}
+	return BundleCheck{Valid: len(issues) == 0, Issues: issues}
+}
+
+// VerificationHandler serves bundles at GET /{hash} for embedding. Like
+// GatewayHandler, it serves only blocks in the provenance of an allowed
+// root or of a share token's root, projected for the audience, and rate
+// limits clients. Any origin may fetch them, and responses may be cached for
+// five minutes. Without opts.Trust, seller trust comes from an engine kept
+// up to date from the store's change feed.
+func VerificationHandler(store BlockStore, opts VerificationOptions) http.Handler {
+	g := newGateway(store, opts.Gateway)
+	if opts.Trust == nil {
+		opts.Trust = NewTrustEngine(opts.TrustPolicy)
+	}
+	trust := &trustSync{engine: opts.Trust, store: store}
+	return g.limit(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		hash := strings.Trim(r.URL.Path, "/")
+		access, status, msg := g.grant(r, hash)
+		if status != 0 {
+			writeError(w, status, msg)
+			return
+		}
+		trust.catchUp()
+		bundle, err := BuildVerificationBundle(hash, store, opts)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "Block not found")
+			return
+		}
+		bundle.project(g.audienceOf(access, hash))
+		if access.issuer != "" {
+			w.Header().Set("Cache-Control", "private, max-age=300")
+		} else {
+			w.Header().Set("Cache-Control", "public, max-age=300")
+		}
+		writeJSON(w, http.StatusOK, bundle)
+	})
+}
+
+// trustSync feeds a trust engine the blocks added to a store since it last
+// looked, through the change feed when the store has one.
+type trustSync struct {
+	engine *TrustEngine
+	store  BlockStore
+
+	mu    sync.Mutex
+	since int
+}
+
+func (t *trustSync) catchUp() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	feed, ok := t.store.(ChangeFeed)
+	if !ok {
+		t.engine.Add(trustBlocks(t.store, t.store.Blocks())...)
+		return
+	}
+	for {
+		page := feed.Changes(t.since, 0)
+		blocks := make([]Block, len(page.Changes))
+		for i, c := range page.Changes {
+			blocks[i] = c.Block
+		}
+		t.engine.Add(trustBlocks(t.store, blocks)...)
+		t.since = page.LastSeq
+		if !page.HasMore {
+			return
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package foodblock
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestVerificationBundle(t *testing.T) {
+	store := NewMemoryStore()
+	authority := Create("actor.authority", map[string]interface{}{"name": "Soil Association"}, nil)
+	farm := Create("actor.producer", map[string]interface{}{"name": "Green Acres"}, nil)
+	wheat := Create("substance.ingredient", map[string]interface{}{"name": "Wheat"}, map[string]interface{}{"source": farm.Hash})
+	bread := Create("substance.product", map[string]interface{}{"name": "Sourdough", "wholesale_price": 2.1, VisibilityKey: map[string]interface{}{"wholesale_price": "trade"}}, map[string]interface{}{"inputs": []interface{}{wheat.Hash}, "seller": farm.Hash})
+	private := Create("transfer.order", map[string]interface{}{"total": 900.0}, map[string]interface{}{"seller": farm.Hash})
+	organic := Create("observe.certification", map[string]interface{}{"name": "Organic", "valid_until": "2099-01-01"}, map[string]interface{}{"subject": farm.Hash, "authority": authority.Hash})
+	lapsed := Create("observe.certification", map[string]interface{}{"name": "Old", "valid_until": "2001-01-01"}, map[string]interface{}{"subject": bread.Hash, "authority": authority.Hash})
+	store.PutAll([]Block{authority, farm, wheat, bread, organic, lapsed, private})
+	snapshot := CreateSnapshot(store.Blocks(), "", nil)
+	var hashes []string
+	for _, b := range store.Blocks() {
+		hashes = append(hashes, b.Hash)
+	}
+	opts := VerificationOptions{
+		Snapshots: func() []SnapshotSet { return []SnapshotSet{{Snapshot: snapshot, Hashes: hashes}} },
+		Gateway:   GatewayOptions{Allow: []string{bread.Hash}},
+	}
+
+	srv := httptest.NewServer(VerificationHandler(store, opts))
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/" + bread.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bundle VerificationBundle
+	json.NewDecoder(resp.Body).Decode(&bundle)
+	resp.Body.Close()
+	if resp.Header.Get("Access-Control-Allow-Origin") != "*" || bundle.Snapshot == nil || len(bundle.Certifications) != 1 || bundle.Trust == nil || bundle.Trust.Name != "Green Acres" || bundle.Recall.Recalled {
+		t.Fatalf("bundle = %+v", bundle)
+	}
+	if bundle.Projection == nil || bundle.Projection.State["wholesale_price"] != nil || bundle.Projection.State["name"] != "Sourdough" {
+		t.Errorf("trade-only field served publicly: %+v", bundle.Projection)
+	}
+	if resp, _ := http.Get(srv.URL + "/" + private.Hash); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("block outside the allowed provenance: %d", resp.StatusCode)
+	}
+	root := snapshot.State["merkle_root"].(string)
+	now := time.Now()
+	if check := VerifyBundle(bundle, []string{root}, now); !check.Valid {
+		t.Errorf("issues = %v", check.Issues)
+	}
+	if check := VerifyBundle(bundle, []string{Sha256Hex("other")}, now); check.Valid {
+		t.Error("expected an untrusted root to fail")
+	}
+	if check := VerifyBundle(bundle, nil, time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)); check.Valid {
+		t.Error("expected the certification to lapse")
+	}
+	tampered := bundle
+	projection := *bundle.Projection
+	projection.State = map[string]interface{}{"name": "Premium Sourdough"}
+	tampered.Projection = &projection
+	if check := VerifyBundle(tampered, nil, now); check.Valid {
+		t.Error("expected a tampered block to fail")
+	}
+	full, _ := BuildVerificationBundle(bread.Hash, store, opts)
+	full.Block.State = map[string]interface{}{"name": "Premium Sourdough"}
+	if check := VerifyBundle(full, nil, now); check.Valid {
+		t.Error("expected a tampered full block to fail")
+	}
+
+	// A recall of an upstream ingredient reaches the product.
+	store.Put(Create("observe.recall", map[string]interface{}{"reason": "ergot"}, map[string]interface{}{"source": wheat.Hash, "authority": authority.Hash}))
+	recalled, _ := BuildVerificationBundle(bread.Hash, store, opts)
+	if !recalled.Recall.Recalled || recalled.Recall.Reasons[0] != "ergot" {
+		t.Errorf("recall = %+v", recalled.Recall)
+	}
+	if resp, _ := http.Get(srv.URL + "/missing"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("missing: %d", resp.StatusCode)
+	}
+}
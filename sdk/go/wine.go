@@ -0,0 +1,226 @@
+package foodblock
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WineLabelRule is the minimum share of a wine, by grape weight, that must
+// come from the vintage, variety and appellation named on its label.
+type WineLabelRule struct {
+	Vintage     float64 `json:"vintage"`
+	Variety     float64 `json:"variety"`
+	Appellation float64 `json:"appellation"`
+}
+
+// WineLabelRules are keyed by jurisdiction. "eu" follows Regulation (EU)
+// 2019/33; "us" follows 27 CFR 4 for an AVA.
+var WineLabelRules = map[string]WineLabelRule{
+	"eu": {Vintage: 0.85, Variety: 0.85, Appellation: 1},
+	"us": {Vintage: 0.95, Variety: 0.75, Appellation: 0.85},
+}
+
+// WineClaimCheck is the share of a wine's grapes matching one label claim.
+type WineClaimCheck struct {
+	Claim    string  `json:"claim"` // vintage, grape_variety or appellation
+	Value    string  `json:"value"`
+	Share    float64 `json:"share"`
+	Required float64 `json:"required"`
+	Passed   bool    `json:"passed"`
+}
+
+// WineClaims is the verdict on a wine's label claims.
+type WineClaims struct {
+	Wine      string           `json:"wine"`
+	Rules     string           `json:"rules"`
+	Harvests  []string         `json:"harvests"`
+	GrapesKg  float64          `json:"grapes_kg"`
+	Checks    []WineClaimCheck `json:"checks"`
+	Valid     bool             `json:"valid"`
+	Unweighed int              `json:"unweighed"` // harvests without a quantity, counted as 1 kg
+}
+
+// ValidateWineClaims checks a wine's vintage, grape_variety and appellation
+// claims against the substance.grapes harvests upstream of it (through its
+// origin, process, source and input refs) under a jurisdiction's
+// WineLabelRules. Shares are by harvest weight; a harvest's year is its
+// vintage or harvest_date, its region its appellation or region.
+func ValidateWineClaims(wine Block, store BlockStore, jurisdiction string) (WineClaims, error) {
+	rule, ok := WineLabelRules[strings.ToLower(jurisdiction)]
+	if !ok {
+		return WineClaims{}, fmt.Errorf("FoodBlock: unknown wine rules: %s", jurisdiction)
+	}
+	c := WineClaims{Wine: wine.Hash, Rules: strings.ToLower(jurisdiction), Harvests: []string{}, Checks: []WineClaimCheck{}}
+	harvests := upstreamOfType(wine, store, "substance.grapes")
+	matched := map[string]float64{}
+	claims := []struct {
+		field    string
+		value    string
+		required float64
+		of       func(Block) string
+	}{
+		{"vintage", wineVintage(wine), rule.Vintage, wineVintage},
+		{"grape_variety", firstString(wine.State, "grape_variety", "variety"), rule.Variety, func(b Block) string { return firstString(b.State, "grape_variety", "variety") }},
+		{"appellation", firstString(wine.State, "appellation", "region"), rule.Appellation, func(b Block) string { return firstString(b.State, "appellation", "region") }},
+	}
+	for _, h := range harvests {
+		c.Harvests = append(c.Harvests, h.Hash)
+		kg := 1.0
+		if q, unit, ok := blockMass(h); ok {
+			kg, _ = toKilograms(q, unit)
+		} else {
+			c.Unweighed++
+		}
+		c.GrapesKg += kg
+		for _, claim := range claims {
+			if claim.value != "" && strings.EqualFold(claim.of(h), claim.value) {
+				matched[claim.field] += kg
+			}
+		}
+	}
+	c.Valid = true
+	for _, claim := range claims {
+		if claim.value == "" {
+			continue
+		}
+		check := WineClaimCheck{Claim: claim.field, Value: claim.value, Required: claim.required}
+		if c.GrapesKg > 0 {
+			check.Share = round3(matched[claim.field] / c.GrapesKg)
+		}
+		check.Passed = c.GrapesKg > 0 && check.Share >= claim.required
+		c.Valid = c.Valid && check.Passed
+		c.Checks = append(c.Checks, check)
+	}
+	c.GrapesKg = round3(c.GrapesKg)
+	return c, nil
+}
+
+// wineVintage reads a block's vintage, or the year of its harvest_date.
+func wineVintage(b Block) string {
+	switch v := b.State["vintage"].(type) {
+	case float64:
+		return strconv.Itoa(int(v))
+	case int:
+		return strconv.Itoa(v)
+	case string:
+		return v
+	}
+	if d := firstString(b.State, "harvest_date"); len(d) >= 4 {
+		return d[:4]
+	}
+	return ""
+}
+
+// upstreamOfType returns the blocks of a type found by walking upstreamRefs
+// from b, without descending past them.
+func upstreamOfType(b Block, store BlockStore, typ string) []Block {
+	var out []Block
+	visited := map[string]bool{b.Hash: true}
+	queue := []Block{b}
+	for depth := 0; len(queue) > 0 && depth < 20; depth++ {
+		var next []Block
+		for _, cur := range queue {
+			for _, key := range upstreamRefs {
+				for _, h := range flattenRefValues(map[string]interface{}{key: cur.Refs[key]}) {
+					if visited[h] {
+						continue
+					}
+					visited[h] = true
+					up := store.Resolve(h)
+					switch {
+					case up == nil:
+					case matchesType(up.Type, typ):
+						out = append(out, *up)
+					default:
+						next = append(next, *up)
+					}
+				}
+			}
+		}
+		queue = next
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Hash < out[j].Hash })
+	return out
+}
+
+// WineBottling describes a bottling run of one wine.
+type WineBottling struct {
+	Wine       string // substance.wine hash of the bulk wine
+	Bottler    string
+	LotCode    string // printed on the label
+	Bottles    int
+	BottleSize float64 // ml, default 750
+	Date       time.Time
+}
+
+// BottleWine records a bottling run as a transform.process (process_type
+// bottling) and the bottled substance.wine lot it produced. The lot carries
+// the bulk wine's label fields and its lot code, and refs.origin points at the
+// run so a recall of the bulk wine or its grapes reaches it.
+func BottleWine(b WineBottling, resolve func(string) *Block) (Block, Block, error) {
+	if b.Bottler == "" || b.LotCode == "" || b.Bottles <= 0 {
+		return Block{}, Block{}, errors.New("FoodBlock: bottling run needs a bottler, a lot code and bottles")
+	}
+	wine := resolve(b.Wine)
+	if wine == nil || wine.Type != "substance.wine" {
+		return Block{}, Block{}, fmt.Errorf("FoodBlock: wine not found: %s", b.Wine)
+	}
+	if b.BottleSize == 0 {
+		b.BottleSize = 750
+	}
+	if b.Date.IsZero() {
+		b.Date = time.Now()
+	}
+	litres := round3(float64(b.Bottles) * b.BottleSize / 1000)
+	run := Create("transform.process", map[string]interface{}{
+		"name":         "Bottling " + b.LotCode,
+		"process_type": "bottling",
+		"lot_code":     b.LotCode,
+		"volume":       map[string]interface{}{"value": litres, "unit": "l"},
+		"date":         b.Date.Format("2006-01-02"),
+	}, map[string]interface{}{"input": b.Wine, "operator": b.Bottler})
+
+	state := map[string]interface{}{
+		"lot_code":    b.LotCode,
+		"quantity":    b.Bottles,
+		"bottle_size": map[string]interface{}{"value": b.BottleSize, "unit": "ml"},
+		"bottled_at":  b.Date.Format("2006-01-02"),
+	}
+	for _, field := range []string{"name", "grape_variety", "vintage", "appellation", "alcohol", "residual_sugar"} {
+		if v, ok := wine.State[field]; ok {
+			state[field] = v
+		}
+	}
+	lot := Create("substance.wine", state, map[string]interface{}{"origin": run.Hash, "producer": b.Bottler})
+	return run, lot, nil
+}
+
+// WineLot is a bottled lot caught by a recall.
+type WineLot struct {
+	Lot       string `json:"lot"`
+	LotCode   string `json:"lot_code"`
+	Bottles   int    `json:"bottles"`
+	BottledAt string `json:"bottled_at"`
+}
+
+// RecallWineLots returns the bottled lots downstream of a source (a grape
+// harvest, bulk wine, additive or bottling run), with the lot codes to
+// publish in a recall notice, in lot code order.
+func RecallWineLots(sourceHash string, store BlockStore) []WineLot {
+	var out []WineLot
+	for _, b := range Recall(sourceHash, store.ResolveForward, 0, nil, nil).Affected {
+		code, _ := b.State["lot_code"].(string)
+		if b.Type != "substance.wine" || code == "" || !headOf(b, store) {
+			continue
+		}
+		n, _ := toFloat64(b.State["quantity"])
+		date, _ := b.State["bottled_at"].(string)
+		out = append(out, WineLot{Lot: b.Hash, LotCode: code, Bottles: int(n), BottledAt: date})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].LotCode < out[j].LotCode })
+	return out
+}
@@ -0,0 +1,72 @@
+package foodblock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWineClaimsAndBottlingLots(t *testing.T) {
+	vineyard := Create("actor.vineyard", map[string]interface{}{"name": "Clos des Pierres"}, nil)
+	grapes := func(variety, appellation, date string, kg float64) Block {
+		return Create("substance.grapes", map[string]interface{}{"grape_variety": variety, "appellation": appellation, "harvest_date": date, "quantity": kg, "unit": "kg"},
+			map[string]interface{}{"producer": vineyard.Hash})
+	}
+	pinot := grapes("Pinot Noir", "Bourgogne", "2024-09-18", 9000)
+	gamay := grapes("Gamay", "Bourgogne", "2024-09-20", 800)
+	reserveGrapes := grapes("Pinot Noir", "Beaujolais", "2023-09-25", 200)
+	press := Create("transform.process", map[string]interface{}{"name": "2023 press", "process_type": "pressing"}, map[string]interface{}{"input": reserveGrapes.Hash})
+	reserve := Create("substance.wine", map[string]interface{}{"name": "2023 reserve"}, map[string]interface{}{"origin": press.Hash})
+	blend := Create("transform.process", map[string]interface{}{"name": "Assemblage", "process_type": "blending"},
+		map[string]interface{}{"inputs": []interface{}{pinot.Hash, gamay.Hash, reserve.Hash}})
+	wine := Create("substance.wine", map[string]interface{}{"name": "Bourgogne Rouge", "grape_variety": "Pinot Noir", "vintage": 2024.0, "appellation": "Bourgogne", "alcohol": 13.0},
+		map[string]interface{}{"origin": blend.Hash})
+
+	store := NewMemoryStore()
+	store.PutAll([]Block{vineyard, pinot, gamay, reserveGrapes, press, reserve, blend, wine})
+
+	eu, err := ValidateWineClaims(wine, store, "eu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eu.GrapesKg != 10000 || len(eu.Harvests) != 3 || len(eu.Checks) != 3 {
+		t.Fatalf("unexpected claims %+v", eu)
+	}
+	byClaim := map[string]WineClaimCheck{}
+	for _, c := range eu.Checks {
+		byClaim[c.Claim] = c
+	}
+	if c := byClaim["vintage"]; c.Share != 0.98 || !c.Passed {
+		t.Errorf("expected 98%% 2024 grapes to pass, got %+v", c)
+	}
+	if c := byClaim["grape_variety"]; c.Share != 0.92 || !c.Passed {
+		t.Errorf("expected 92%% pinot to pass, got %+v", c)
+	}
+	if c := byClaim["appellation"]; c.Share != 0.98 || c.Passed || eu.Valid {
+		t.Errorf("expected Beaujolais grapes to break the EU appellation rule, got %+v", c)
+	}
+	if us, _ := ValidateWineClaims(wine, store, "us"); !us.Valid {
+		t.Errorf("expected the wine to meet US rules, got %+v", us.Checks)
+	}
+	if _, err := ValidateWineClaims(wine, store, "mars"); err == nil {
+		t.Error("expected unknown rules to fail")
+	}
+
+	bottler := Create("actor.producer", map[string]interface{}{"name": "Clos des Pierres Bottling"}, nil)
+	run1, lot1, err := BottleWine(WineBottling{Wine: wine.Hash, Bottler: bottler.Hash, LotCode: "L24-031", Bottles: 1200, Date: time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)}, store.Resolve)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run2, lot2, _ := BottleWine(WineBottling{Wine: wine.Hash, Bottler: bottler.Hash, LotCode: "L24-012", Bottles: 600, BottleSize: 1500}, store.Resolve)
+	store.PutAll([]Block{bottler, run1, lot1, run2, lot2})
+	if lot1.State["vintage"] != 2024.0 || lot1.State["appellation"] != "Bourgogne" {
+		t.Errorf("expected label fields on the bottled lot, got %v", lot1.State)
+	}
+
+	lots := RecallWineLots(gamay.Hash, store)
+	if len(lots) != 2 || lots[0].LotCode != "L24-012" || lots[1].Bottles != 1200 || lots[1].BottledAt != "2025-06-02" {
+		t.Errorf("expected both bottling runs recalled, got %+v", lots)
+	}
+	if lots := RecallWineLots(run1.Hash, store); len(lots) != 1 || lots[0].LotCode != "L24-031" {
+		t.Errorf("expected a run recall to reach only its lot, got %+v", lots)
+	}
+}
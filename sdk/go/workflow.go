@@ -0,0 +1,402 @@
+package foodblock
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// TransitionGuard is a predicate a workflow transition must satisfy.
+// Guards are keyed in VocabularyDef.Guards by "from->to", and seeded
+// vocabulary blocks carry them in state.guards (see GuardsFromBlock).
+type TransitionGuard struct {
+	Name         string `json:"name"`
+	RequireState string `json:"require_state,omitempty"` // state field that must be present on the subject
+	RequireRef   string `json:"require_ref,omitempty"`   // ref role that must be present on the subject
+	RequireBlock string `json:"require_block,omitempty"` // type of a graph block that must reference the subject
+	FromRef      string `json:"from_ref,omitempty"`      // RequireBlock must be signed by the actor in this subject ref
+	Description  string `json:"description,omitempty"`
+}
+
+// TransitionCheck is the outcome of a guarded transition check.
+type TransitionCheck struct {
+	Allowed bool
+	From    string
+	To      string
+	Failed  []string
+	Reason  string
+}
+
+// GuardKey returns the Guards map key for a transition.
+func GuardKey(from, to string) string {
+	return from + "->" + to
+}
+
+// CheckTransition validates moving a block from its current state.status to a new
+// status under a vocabulary's transitions, evaluating any guards against the graph.
+// signerOf returns the verified signer of a graph block, such as a store's
+// SignerOf; when nil, guards that need a block from a given actor fail.
+func CheckTransition(vocab VocabularyDef, block Block, to string, graph []Block, signerOf func(string) string) TransitionCheck {
+	from, _ := block.State["status"].(string)
+	check := TransitionCheck{From: from, To: to}
+
+	allowed := false
+	for _, s := range vocab.Transitions[from] {
+		if s == to {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		check.Reason = "transition " + GuardKey(from, to) + " is not defined"
+		return check
+	}
+
+	for _, g := range vocab.Guards[GuardKey(from, to)] {
+		if !g.Evaluate(block, graph, signerOf) {
+			check.Failed = append(check.Failed, g.Name)
+		}
+	}
+	if len(check.Failed) > 0 {
+		check.Reason = "guard failed: " + strings.Join(check.Failed, ", ")
+		return check
+	}
+
+	check.Allowed = true
+	return check
+}
+
+// Evaluate reports whether the guard holds for a block within a graph. With
+// FromRef set, the required block only counts when signerOf reports the
+// actor as its signer; refs naming the actor are not enough.
+func (g TransitionGuard) Evaluate(block Block, graph []Block, signerOf func(string) string) bool {
+	if g.RequireState != "" {
+		if v, ok := block.State[g.RequireState]; !ok || v == "" {
+			return false
+		}
+	}
+	if g.RequireRef != "" {
+		if _, ok := block.Refs[g.RequireRef]; !ok {
+			return false
+		}
+	}
+	if g.RequireBlock == "" {
+		return true
+	}
+
+	subjects, versions := chainHashes(block, graph)
+	actor := ""
+	for _, v := range versions {
+		if a, ok := v.Refs[g.FromRef].(string); ok {
+			actor = a
+			break
+		}
+	}
+	for _, b := range graph {
+		if !matchesType(b.Type, g.RequireBlock) {
+			continue
+		}
+		if g.FromRef != "" && (actor == "" || signerOf == nil || signerOf(b.Hash) != actor) {
+			continue
+		}
+		for _, h := range flattenRefValues(b.Refs) {
+			if subjects[h] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// chainHashes returns the block's hash plus every earlier version reachable
+// through refs.updates within the graph, and the resolved versions newest first.
+func chainHashes(block Block, graph []Block) (map[string]bool, []Block) {
+	byHash := make(map[string]*Block, len(graph))
+	for i := range graph {
+		byHash[graph[i].Hash] = &graph[i]
+	}
+	hashes := map[string]bool{block.Hash: true}
+	versions := []Block{block}
+	current, _ := block.Refs["updates"].(string)
+	for current != "" && !hashes[current] {
+		hashes[current] = true
+		prev, ok := byHash[current]
+		if !ok {
+			break
+		}
+		versions = append(versions, *prev)
+		current, _ = prev.Refs["updates"].(string)
+	}
+	return hashes, versions
+}
+
+// toMap renders a guard as it is stored in a vocabulary block.
+func (g TransitionGuard) toMap() map[string]interface{} {
+	m := map[string]interface{}{"name": g.Name}
+	for k, v := range map[string]string{
+		"require_state": g.RequireState,
+		"require_ref":   g.RequireRef,
+		"require_block": g.RequireBlock,
+		"from_ref":      g.FromRef,
+		"description":   g.Description,
+	} {
+		if v != "" {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+// guardsState renders a vocabulary's guards for state.guards.
+func guardsState(guards map[string][]TransitionGuard) map[string]interface{} {
+	out := make(map[string]interface{}, len(guards))
+	for key, gs := range guards {
+		list := make([]interface{}, len(gs))
+		for i, g := range gs {
+			list[i] = g.toMap()
+		}
+		out[key] = list
+	}
+	return out
+}
+
+// GuardsFromBlock reads the transition guards declared in a vocabulary
+// block's state.guards, keyed by "from->to".
+func GuardsFromBlock(b Block) (map[string][]TransitionGuard, error) {
+	raw, ok := b.State["guards"]
+	if !ok {
+		return nil, nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var guards map[string][]TransitionGuard
+	if err := json.Unmarshal(data, &guards); err != nil {
+		return nil, fmt.Errorf("FoodBlock: invalid guards in %s: %v", b.Hash, err)
+	}
+	for key, gs := range guards {
+		for _, g := range gs {
+			if g.Name == "" {
+				return nil, fmt.Errorf("FoodBlock: guard on %s has no name", key)
+			}
+		}
+	}
+	return guards, nil
+}
+
+// signerLookup returns a store's SignerOf, or nil if it records no signers.
+func signerLookup(store BlockStore) func(string) string {
+	if s, ok := store.(interface{ SignerOf(string) string }); ok {
+		return s.SignerOf
+	}
+	return nil
+}
+
+// matchesType matches a block type against a pattern, supporting "prefix.*"
+// and "*" in any other position, as in "*.product".
+func matchesType(typ, pattern string) bool {
+	if strings.HasSuffix(pattern, ".*") && !strings.Contains(pattern[:len(pattern)-2], "*") {
+		return strings.HasPrefix(typ, pattern[:len(pattern)-1])
+	}
+	if strings.Contains(pattern, "*") {
+		ok, _ := path.Match(pattern, typ)
+		return ok
+	}
+	return typ == pattern
+}
+
+// WorkflowAnalysis reports structural problems in a vocabulary's state machine.
+type WorkflowAnalysis struct {
+	States      []string
+	Unreachable []string   // states not reachable from the initial state
+	DeadEnds    []string   // states with no exit that are not declared terminals
+	Cycles      [][]string // transition loops, each closed on its first state
+	Valid       bool       // no unreachable states and no dead ends
+}
+
+// ExportWorkflow renders a vocabulary's transitions as "dot" (Graphviz) or "mermaid".
+func ExportWorkflow(domain, format string) (string, error) {
+	vocab, ok := Vocabularies[domain]
+	if !ok {
+		return "", fmt.Errorf("FoodBlock: unknown vocabulary: %s", domain)
+	}
+	if len(vocab.Transitions) == 0 {
+		return "", fmt.Errorf("FoodBlock: vocabulary %s has no transitions", domain)
+	}
+
+	states := workflowStates(vocab)
+	terminal := make(map[string]bool)
+	for _, t := range vocab.Terminals {
+		terminal[t] = true
+	}
+
+	var b strings.Builder
+	switch format {
+	case "dot":
+		fmt.Fprintf(&b, "digraph %q {\n", domain)
+		b.WriteString("  rankdir=LR;\n")
+		for _, st := range states {
+			shape := "ellipse"
+			if terminal[st] {
+				shape = "doublecircle"
+			} else if st == vocab.Initial {
+				shape = "box"
+			}
+			fmt.Fprintf(&b, "  %q [shape=%s];\n", st, shape)
+		}
+		for _, from := range states {
+			for _, to := range vocab.Transitions[from] {
+				if guards := vocab.Guards[GuardKey(from, to)]; len(guards) > 0 {
+					fmt.Fprintf(&b, "  %q -> %q [label=%q];\n", from, to, guardNames(guards))
+				} else {
+					fmt.Fprintf(&b, "  %q -> %q;\n", from, to)
+				}
+			}
+		}
+		b.WriteString("}\n")
+	case "mermaid":
+		b.WriteString("stateDiagram-v2\n")
+		if vocab.Initial != "" {
+			fmt.Fprintf(&b, "  [*] --> %s\n", vocab.Initial)
+		}
+		for _, from := range states {
+			for _, to := range vocab.Transitions[from] {
+				if guards := vocab.Guards[GuardKey(from, to)]; len(guards) > 0 {
+					fmt.Fprintf(&b, "  %s --> %s : %s\n", from, to, guardNames(guards))
+				} else {
+					fmt.Fprintf(&b, "  %s --> %s\n", from, to)
+				}
+			}
+		}
+		for _, st := range states {
+			if terminal[st] {
+				fmt.Fprintf(&b, "  %s --> [*]\n", st)
+			}
+		}
+	default:
+		return "", fmt.Errorf("FoodBlock: unknown workflow format: %s", format)
+	}
+	return b.String(), nil
+}
+
+// AnalyzeWorkflow detects unreachable states, undeclared dead ends and cycles.
+// Without a declared initial state, every state with no incoming transition is a start.
+func AnalyzeWorkflow(vocab VocabularyDef) WorkflowAnalysis {
+	states := workflowStates(vocab)
+	analysis := WorkflowAnalysis{States: states}
+
+	incoming := make(map[string]bool)
+	for _, tos := range vocab.Transitions {
+		for _, to := range tos {
+			incoming[to] = true
+		}
+	}
+	var starts []string
+	if vocab.Initial != "" {
+		starts = []string{vocab.Initial}
+	} else {
+		for _, st := range states {
+			if !incoming[st] {
+				starts = append(starts, st)
+			}
+		}
+	}
+
+	reached := make(map[string]bool)
+	queue := append([]string{}, starts...)
+	for len(queue) > 0 {
+		st := queue[0]
+		queue = queue[1:]
+		if reached[st] {
+			continue
+		}
+		reached[st] = true
+		queue = append(queue, vocab.Transitions[st]...)
+	}
+
+	terminal := make(map[string]bool)
+	for _, t := range vocab.Terminals {
+		terminal[t] = true
+	}
+	for _, st := range states {
+		if !reached[st] {
+			analysis.Unreachable = append(analysis.Unreachable, st)
+		}
+		if len(vocab.Transitions[st]) == 0 && !terminal[st] {
+			analysis.DeadEnds = append(analysis.DeadEnds, st)
+		}
+	}
+
+	// DFS back edges give one cycle per loop entry, in deterministic order.
+	const (
+		unvisited = iota
+		onStack
+		done
+	)
+	color := make(map[string]int)
+	var stack []string
+	var visit func(string)
+	visit = func(st string) {
+		color[st] = onStack
+		stack = append(stack, st)
+		next := append([]string{}, vocab.Transitions[st]...)
+		sort.Strings(next)
+		for _, to := range next {
+			switch color[to] {
+			case unvisited:
+				visit(to)
+			case onStack:
+				for i := len(stack) - 1; i >= 0; i-- {
+					if stack[i] == to {
+						cycle := append(append([]string{}, stack[i:]...), to)
+						analysis.Cycles = append(analysis.Cycles, cycle)
+						break
+					}
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		color[st] = done
+	}
+	for _, st := range append(starts, states...) {
+		if color[st] == unvisited {
+			visit(st)
+		}
+	}
+
+	analysis.Valid = len(analysis.Unreachable) == 0 && len(analysis.DeadEnds) == 0
+	return analysis
+}
+
+func workflowStates(vocab VocabularyDef) []string {
+	seen := make(map[string]bool)
+	if vocab.Initial != "" {
+		seen[vocab.Initial] = true
+	}
+	for from, tos := range vocab.Transitions {
+		seen[from] = true
+		for _, to := range tos {
+			seen[to] = true
+		}
+	}
+	for _, t := range vocab.Terminals {
+		seen[t] = true
+	}
+	states := make([]string, 0, len(seen))
+	for st := range seen {
+		states = append(states, st)
+	}
+	sort.Strings(states)
+	return states
+}
+
+func guardNames(guards []TransitionGuard) string {
+	names := make([]string, len(guards))
+	for i, g := range guards {
+		names[i] = g.Name
+	}
+	return strings.Join(names, ", ")
+}
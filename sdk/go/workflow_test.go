@@ -0,0 +1,134 @@
+package foodblock
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckTransitionUndefined(t *testing.T) {
+	order := Create("transfer.order", map[string]interface{}{"status": "paid"}, nil)
+	check := CheckTransition(Vocabularies["workflow"], order, "shipped", nil, nil)
+	if check.Allowed {
+		t.Error("expected paid->shipped to be rejected")
+	}
+}
+
+func TestCheckTransitionStateGuard(t *testing.T) {
+	wf := Vocabularies["workflow"]
+	order := Create("transfer.order", map[string]interface{}{"status": "order"}, nil)
+	check := CheckTransition(wf, order, "confirmed", nil, nil)
+	if check.Allowed {
+		t.Fatal("expected confirmation without payment_ref to fail")
+	}
+	if len(check.Failed) != 1 || check.Failed[0] != "payment_ref" {
+		t.Errorf("expected payment_ref guard to fail, got %v", check.Failed)
+	}
+
+	paid := MergeUpdate(order, map[string]interface{}{"payment_ref": "pi_123"}, nil)
+	if check := CheckTransition(wf, paid, "confirmed", nil, nil); !check.Allowed {
+		t.Errorf("expected confirmation with payment_ref to pass, got %s", check.Reason)
+	}
+}
+
+func TestCheckTransitionBlockGuard(t *testing.T) {
+	wf := Vocabularies["workflow"]
+	carrier := Create("actor.distributor", map[string]interface{}{"name": "Cold Express"}, nil)
+	other := Create("actor.distributor", map[string]interface{}{"name": "Someone Else"}, nil)
+	shipped := Create("transfer.order", map[string]interface{}{"status": "shipped"}, map[string]interface{}{"carrier": carrier.Hash})
+	store := NewMemoryStore()
+	store.PutAll([]Block{carrier, other, shipped})
+
+	wrongAck := Create("observe.acknowledgement", nil, map[string]interface{}{"subject": shipped.Hash})
+	store.PutSigned(SignedBlock{FoodBlock: wrongAck, AuthorHash: other.Hash})
+	if check := CheckTransition(wf, shipped, "delivered", store.Blocks(), store.SignerOf); check.Allowed {
+		t.Error("expected acknowledgement from another actor to be ignored")
+	}
+
+	// An unsigned block naming the carrier is not the carrier's acknowledgement.
+	claimed := Create("observe.acknowledgement", map[string]interface{}{"note": "claimed"}, map[string]interface{}{"subject": shipped.Hash, "author": carrier.Hash, "carrier": carrier.Hash})
+	store.Put(claimed)
+	if check := CheckTransition(wf, shipped, "delivered", store.Blocks(), store.SignerOf); check.Allowed {
+		t.Error("expected an unsigned acknowledgement naming the carrier to be ignored")
+	}
+	if !Transition("shipped", "delivered") {
+		t.Error("expected Transition to check the edge only, guards aside")
+	}
+	if check := TransitionIn(shipped, "delivered", store); check.Allowed || len(check.Failed) != 1 || check.Failed[0] != "carrier_ack" {
+		t.Errorf("TransitionIn = %+v, want carrier_ack failed", check)
+	}
+
+	// Acknowledgement of an earlier version still counts for the head.
+	head := MergeUpdate(shipped, map[string]interface{}{"tracking": "TRK1"}, shipped.Refs)
+	ack := Create("observe.acknowledgement", nil, map[string]interface{}{"subject": shipped.Hash})
+	store.Put(head)
+	store.PutSigned(SignedBlock{FoodBlock: ack, AuthorHash: carrier.Hash})
+	check := CheckTransition(wf, head, "delivered", store.Blocks(), store.SignerOf)
+	if !check.Allowed {
+		t.Errorf("expected carrier acknowledgement to satisfy guard, got %s", check.Reason)
+	}
+	if check := TransitionIn(head, "delivered", store); !check.Allowed {
+		t.Errorf("TransitionIn refused the acknowledged head: %s", check.Reason)
+	}
+	if check := CheckTransition(wf, head, "delivered", store.Blocks(), nil); check.Allowed {
+		t.Error("expected the guard to fail without signer information")
+	}
+}
+
+func TestExportWorkflowFormats(t *testing.T) {
+	dot, err := ExportWorkflow("workflow", "dot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(dot, `"order" -> "confirmed" [label="payment_ref"];`) {
+		t.Errorf("expected guarded edge in DOT output:\n%s", dot)
+	}
+	mermaid, err := ExportWorkflow("workflow", "mermaid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(mermaid, "stateDiagram-v2\n  [*] --> draft\n") || !strings.Contains(mermaid, "paid --> [*]") {
+		t.Errorf("unexpected mermaid output:\n%s", mermaid)
+	}
+	if _, err := ExportWorkflow("workflow", "svg"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+	if _, err := ExportWorkflow("bakery", "dot"); err == nil {
+		t.Error("expected error for vocabulary without transitions")
+	}
+}
+
+func TestAnalyzeWorkflowBuiltin(t *testing.T) {
+	a := AnalyzeWorkflow(Vocabularies["workflow"])
+	if !a.Valid {
+		t.Errorf("expected built-in workflow to be valid, got unreachable=%v dead=%v", a.Unreachable, a.DeadEnds)
+	}
+	if len(a.Cycles) == 0 {
+		t.Error("expected the returned->order loop to be reported as a cycle")
+	}
+}
+
+func TestAnalyzeWorkflowProblems(t *testing.T) {
+	vocab := VocabularyDef{
+		Initial:   "new",
+		Terminals: []string{"closed"},
+		Transitions: map[string][]string{
+			"new":    {"open"},
+			"open":   {"closed", "stuck"},
+			"orphan": {"closed"},
+			"closed": {},
+		},
+	}
+	a := AnalyzeWorkflow(vocab)
+	if a.Valid {
+		t.Error("expected workflow with problems to be invalid")
+	}
+	if len(a.Unreachable) != 1 || a.Unreachable[0] != "orphan" {
+		t.Errorf("expected orphan unreachable, got %v", a.Unreachable)
+	}
+	if len(a.DeadEnds) != 1 || a.DeadEnds[0] != "stuck" {
+		t.Errorf("expected stuck dead end, got %v", a.DeadEnds)
+	}
+	if len(a.Cycles) != 0 {
+		t.Errorf("expected no cycles, got %v", a.Cycles)
+	}
+}
@@ -8,6 +8,19 @@
 const { create } = require('./block')
 const { VOCABULARIES } = require('./vocabulary')
 const { TEMPLATES } = require('./template')
+const { version: SDK_VERSION } = require('../package.json')
+
+// Stamped on every seed, as the Go and Python SDKs do, so seeds hash alike.
+const SEED_PROTOCOL_VERSION = '0.4.0' // the version signed wrappers carry
+
+/**
+ * Create a seed block stamped with its content revision (the hash of the
+ * definition alone) and the protocol and SDK versions that produced it.
+ */
+function seedBlock(type, state) {
+  const revision = create(type, state).hash
+  return create(type, { ...state, revision, protocol_version: SEED_PROTOCOL_VERSION, sdk_version: SDK_VERSION })
+}
 
 /**
  * Generate all vocabulary blocks from built-in definitions.
@@ -15,11 +28,12 @@ const { TEMPLATES } = require('./template')
  */
 function seedVocabularies() {
   return Object.entries(VOCABULARIES).map(([domain, def]) => {
-    return create('observe.vocabulary', {
+    return seedBlock('observe.vocabulary', {
       domain: def.domain,
       for_types: def.for_types,
       fields: def.fields,
-      ...(def.transitions ? { transitions: def.transitions } : {})
+      ...(def.transitions ? { transitions: def.transitions } : {}),
+      ...(def.guards ? { guards: def.guards } : {})
     })
   })
 }
@@ -30,7 +44,7 @@ function seedVocabularies() {
  */
 function seedTemplates() {
   return Object.entries(TEMPLATES).map(([key, def]) => {
-    return create('observe.template', {
+    return seedBlock('observe.template', {
       name: def.name,
       description: def.description,
       steps: def.steps
@@ -130,64 +130,64 @@ const TEMPLATES = {
     name: 'Surplus Rescue',
     description: 'Food business posts surplus, sustainer collects, donation recorded',
     steps: [
-      { type: 'actor.venue', alias: 'donor', required: true, defaultState: { name: 'Food Business' } },
-      { type: 'substance.surplus', alias: 'surplus', refs: { seller: '@donor' }, required: true, defaultState: { name: 'Surplus Food', status: 'available' } },
-      { type: 'transfer.donation', alias: 'donation', refs: { source: '@donor', item: '@surplus' }, required: true, defaultState: { status: 'collected' } }
+      { type: 'actor.venue', alias: 'donor', default_state: { name: 'Food Business' } },
+      { type: 'substance.surplus', alias: 'surplus', refs: { seller: '@donor' }, default_state: { name: 'Surplus Food', status: 'available' } },
+      { type: 'transfer.donation', alias: 'donation', refs: { source: '@donor', item: '@surplus' }, default_state: { status: 'collected' } }
     ]
   },
   'agent-reorder': {
     name: 'Agent Reorder',
     description: 'Inventory check → low stock → draft order → approve → order placed',
     steps: [
-      { type: 'actor.venue', alias: 'business', required: true, defaultState: { name: 'Business' } },
-      { type: 'observe.reading', alias: 'inventory-check', refs: { subject: '@business' }, required: true, defaultState: { name: 'Inventory Check', reading_type: 'stock_level' } },
-      { type: 'actor.agent', alias: 'agent', refs: { operator: '@business' }, required: true, defaultState: { name: 'Reorder Agent', capabilities: ['ordering'] } },
-      { type: 'transfer.order', alias: 'draft-order', refs: { buyer: '@business', agent: '@agent' }, required: true, defaultState: { status: 'draft', draft: true } },
-      { type: 'transfer.order', alias: 'confirmed-order', refs: { buyer: '@business', updates: '@draft-order' }, required: true, defaultState: { status: 'confirmed' } }
+      { type: 'actor.venue', alias: 'business', default_state: { name: 'Business' } },
+      { type: 'observe.reading', alias: 'inventory-check', refs: { subject: '@business' }, default_state: { name: 'Inventory Check', reading_type: 'stock_level' } },
+      { type: 'actor.agent', alias: 'agent', refs: { operator: '@business' }, default_state: { name: 'Reorder Agent', capabilities: ['ordering'] } },
+      { type: 'transfer.order', alias: 'draft-order', refs: { buyer: '@business', agent: '@agent' }, default_state: { status: 'draft', draft: true } },
+      { type: 'transfer.order', alias: 'confirmed-order', refs: { buyer: '@business', updates: '@draft-order' }, default_state: { status: 'confirmed' } }
     ]
   },
   'restaurant-sourcing': {
     name: 'Restaurant Sourcing',
     description: 'Restaurant needs ingredient → discovery → supplier offer → accept → order → delivery',
     steps: [
-      { type: 'actor.venue', alias: 'restaurant', required: true, defaultState: { name: 'Restaurant' } },
-      { type: 'substance.ingredient', alias: 'needed', refs: {}, required: true, defaultState: { name: 'Ingredient Needed' } },
-      { type: 'actor.producer', alias: 'supplier', required: true, defaultState: { name: 'Supplier' } },
-      { type: 'transfer.offer', alias: 'offer', refs: { seller: '@supplier', item: '@needed', buyer: '@restaurant' }, required: true, defaultState: { status: 'offered' } },
-      { type: 'transfer.order', alias: 'order', refs: { buyer: '@restaurant', seller: '@supplier', item: '@needed' }, required: true, defaultState: { status: 'confirmed' } },
-      { type: 'transfer.delivery', alias: 'delivery', refs: { order: '@order', seller: '@supplier', buyer: '@restaurant' }, required: true, defaultState: { status: 'delivered' } }
+      { type: 'actor.venue', alias: 'restaurant', default_state: { name: 'Restaurant' } },
+      { type: 'substance.ingredient', alias: 'needed', default_state: { name: 'Ingredient Needed' } },
+      { type: 'actor.producer', alias: 'supplier', default_state: { name: 'Supplier' } },
+      { type: 'transfer.offer', alias: 'offer', refs: { seller: '@supplier', item: '@needed', buyer: '@restaurant' }, default_state: { status: 'offered' } },
+      { type: 'transfer.order', alias: 'order', refs: { buyer: '@restaurant', seller: '@supplier', item: '@needed' }, default_state: { status: 'confirmed' } },
+      { type: 'transfer.delivery', alias: 'delivery', refs: { order: '@order', seller: '@supplier', buyer: '@restaurant' }, default_state: { status: 'delivered' } }
     ]
   },
   'food-safety-audit': {
     name: 'Food Safety Audit',
     description: 'Inspector visits → readings taken → report → certification → attestation',
     steps: [
-      { type: 'actor.venue', alias: 'premises', required: true, defaultState: { name: 'Food Premises' } },
-      { type: 'actor.producer', alias: 'inspector', required: true, defaultState: { name: 'Food Safety Inspector' } },
-      { type: 'observe.reading', alias: 'readings', refs: { subject: '@premises', author: '@inspector' }, required: true, defaultState: { name: 'Safety Readings' } },
-      { type: 'observe.certification', alias: 'certificate', refs: { subject: '@premises', authority: '@inspector' }, required: true, defaultState: { name: 'Food Safety Certificate' } },
-      { type: 'observe.attestation', alias: 'attestation', refs: { confirms: '@certificate', attestor: '@inspector' }, required: true, defaultState: { confidence: 'verified' } }
+      { type: 'actor.venue', alias: 'premises', default_state: { name: 'Food Premises' } },
+      { type: 'actor.producer', alias: 'inspector', default_state: { name: 'Food Safety Inspector' } },
+      { type: 'observe.reading', alias: 'readings', refs: { subject: '@premises', author: '@inspector' }, default_state: { name: 'Safety Readings' } },
+      { type: 'observe.certification', alias: 'certificate', refs: { subject: '@premises', authority: '@inspector' }, default_state: { name: 'Food Safety Certificate' } },
+      { type: 'observe.attestation', alias: 'attestation', refs: { confirms: '@certificate', attestor: '@inspector' }, default_state: { confidence: 'verified' } }
     ]
   },
   'market-day': {
     name: 'Market Day',
     description: 'Producer brings stock → stall setup → sales → end-of-day surplus → donation',
     steps: [
-      { type: 'actor.producer', alias: 'producer', required: true, defaultState: { name: 'Market Producer' } },
-      { type: 'place.market', alias: 'market', required: true, defaultState: { name: 'Farmers Market' } },
-      { type: 'substance.product', alias: 'stock', refs: { seller: '@producer' }, required: true, defaultState: { name: 'Market Stock' } },
-      { type: 'transfer.order', alias: 'sales', refs: { seller: '@producer', item: '@stock' }, required: false, defaultState: { status: 'completed' } },
-      { type: 'substance.surplus', alias: 'leftover', refs: { seller: '@producer', source: '@stock' }, required: false, defaultState: { name: 'End of Day Surplus', status: 'available' } }
+      { type: 'actor.producer', alias: 'producer', default_state: { name: 'Market Producer' } },
+      { type: 'place.market', alias: 'market', default_state: { name: 'Farmers Market' } },
+      { type: 'substance.product', alias: 'stock', refs: { seller: '@producer' }, default_state: { name: 'Market Stock' } },
+      { type: 'transfer.order', alias: 'sales', refs: { seller: '@producer', item: '@stock' }, optional: true, default_state: { status: 'completed' } },
+      { type: 'substance.surplus', alias: 'leftover', refs: { seller: '@producer', source: '@stock' }, optional: true, default_state: { name: 'End of Day Surplus', status: 'available' } }
     ]
   },
   'cold-chain': {
     name: 'Cold Chain',
     description: 'Shipment departs → temperature readings → delivery → chain verified',
     steps: [
-      { type: 'actor.distributor', alias: 'carrier', required: true, defaultState: { name: 'Cold Chain Carrier' } },
-      { type: 'transfer.delivery', alias: 'shipment', refs: { carrier: '@carrier' }, required: true, defaultState: { status: 'in_transit' } },
-      { type: 'observe.reading', alias: 'temp-log', refs: { subject: '@shipment' }, required: true, defaultState: { name: 'Temperature Log', reading_type: 'temperature' } },
-      { type: 'observe.attestation', alias: 'chain-verified', refs: { confirms: '@shipment', attestor: '@carrier' }, required: true, defaultState: { confidence: 'verified', method: 'continuous_monitoring' } }
+      { type: 'actor.distributor', alias: 'carrier', default_state: { name: 'Cold Chain Carrier' } },
+      { type: 'transfer.delivery', alias: 'shipment', refs: { carrier: '@carrier' }, default_state: { status: 'in_transit' } },
+      { type: 'observe.reading', alias: 'temp-log', refs: { subject: '@shipment' }, default_state: { name: 'Temperature Log', reading_type: 'temperature' } },
+      { type: 'observe.attestation', alias: 'chain-verified', refs: { confirms: '@shipment', attestor: '@carrier' }, default_state: { confidence: 'verified', method: 'continuous_monitoring' } }
     ]
   }
 }
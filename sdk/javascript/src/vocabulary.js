@@ -401,6 +401,14 @@ const VOCABULARIES = {
       paid: [],
       cancelled: [],
       returned: ['order']
+    },
+    guards: {
+      'order->confirmed': [
+        { name: 'payment_ref', require_state: 'payment_ref', description: 'Order must carry a payment reference' }
+      ],
+      'shipped->delivered': [
+        { name: 'carrier_ack', require_block: 'observe.acknowledgement', from_ref: 'carrier', description: 'Carrier must acknowledge delivery' }
+      ]
     }
   },
 
@@ -506,6 +514,11 @@ const VOCABULARIES = {
         type: 'string',
         aliases: ['market', 'farmers market', 'street market', 'food market'],
         description: 'Name or type of the market'
+      },
+      stall_count: {
+        type: 'number',
+        aliases: ['stalls', 'pitches', 'stands'],
+        description: 'Number of pitches the market lets'
       }
     }
   },
@@ -83,7 +83,8 @@ def _canonical_number(n) -> str:
 
         # Use decimal module for precise control over formatting
         # repr() gives shortest representation, then we reformat per ECMAScript rules
-        d = decimal.Decimal(repr(n))
+        # normalize() drops the ".0" repr keeps on integer floats below 1e16
+        d = decimal.Decimal(repr(n)).normalize()
         sign, digits, exponent = d.as_tuple()
         num_digits = len(digits)
         digit_str = ''.join(str(d) for d in digits)
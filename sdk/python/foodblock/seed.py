@@ -4,6 +4,19 @@ from .block import create
 from .vocabulary import VOCABULARIES
 from .template import TEMPLATES
 
+# Stamped on every seed, as the Go and JS SDKs do, so seeds hash alike.
+SEED_PROTOCOL_VERSION = '0.4.0'  # the version signed wrappers carry
+SDK_VERSION = '0.5.0'  # keep in step with pyproject.toml
+
+
+def _seed_block(block_type, state):
+    """Create a seed block stamped with its content revision and versions."""
+    state = dict(state)
+    state['revision'] = create(block_type, state)['hash']
+    state['protocol_version'] = SEED_PROTOCOL_VERSION
+    state['sdk_version'] = SDK_VERSION
+    return create(block_type, state)
+
 
 def seed_vocabularies():
     """Generate all vocabulary blocks from built-in definitions."""
@@ -16,7 +29,9 @@ def seed_vocabularies():
         }
         if 'transitions' in defn:
             state['transitions'] = defn['transitions']
-        result.append(create('observe.vocabulary', state))
+        if 'guards' in defn:
+            state['guards'] = defn['guards']
+        result.append(_seed_block('observe.vocabulary', state))
     return result
 
 
@@ -24,7 +39,7 @@ def seed_templates():
     """Generate all template blocks from built-in definitions."""
     result = []
     for key, defn in TEMPLATES.items():
-        result.append(create('observe.template', {
+        result.append(_seed_block('observe.template', {
             'name': defn['name'],
             'description': defn['description'],
             'steps': defn['steps'],
@@ -111,64 +111,64 @@ TEMPLATES = {
         'name': 'Surplus Rescue',
         'description': 'Food business posts surplus, sustainer collects, donation recorded',
         'steps': [
-            {'type': 'actor.venue', 'alias': 'donor', 'required': True, 'default_state': {'name': 'Food Business'}},
-            {'type': 'substance.surplus', 'alias': 'surplus', 'refs': {'seller': '@donor'}, 'required': True, 'default_state': {'name': 'Surplus Food', 'status': 'available'}},
-            {'type': 'transfer.donation', 'alias': 'donation', 'refs': {'source': '@donor', 'item': '@surplus'}, 'required': True, 'default_state': {'status': 'collected'}}
+            {'type': 'actor.venue', 'alias': 'donor', 'default_state': {'name': 'Food Business'}},
+            {'type': 'substance.surplus', 'alias': 'surplus', 'refs': {'seller': '@donor'}, 'default_state': {'name': 'Surplus Food', 'status': 'available'}},
+            {'type': 'transfer.donation', 'alias': 'donation', 'refs': {'source': '@donor', 'item': '@surplus'}, 'default_state': {'status': 'collected'}}
         ]
     },
     'agent-reorder': {
         'name': 'Agent Reorder',
         'description': 'Inventory check \u2192 low stock \u2192 draft order \u2192 approve \u2192 order placed',
         'steps': [
-            {'type': 'actor.venue', 'alias': 'business', 'required': True, 'default_state': {'name': 'Business'}},
-            {'type': 'observe.reading', 'alias': 'inventory-check', 'refs': {'subject': '@business'}, 'required': True, 'default_state': {'name': 'Inventory Check', 'reading_type': 'stock_level'}},
-            {'type': 'actor.agent', 'alias': 'agent', 'refs': {'operator': '@business'}, 'required': True, 'default_state': {'name': 'Reorder Agent', 'capabilities': ['ordering']}},
-            {'type': 'transfer.order', 'alias': 'draft-order', 'refs': {'buyer': '@business', 'agent': '@agent'}, 'required': True, 'default_state': {'status': 'draft', 'draft': True}},
-            {'type': 'transfer.order', 'alias': 'confirmed-order', 'refs': {'buyer': '@business', 'updates': '@draft-order'}, 'required': True, 'default_state': {'status': 'confirmed'}}
+            {'type': 'actor.venue', 'alias': 'business', 'default_state': {'name': 'Business'}},
+            {'type': 'observe.reading', 'alias': 'inventory-check', 'refs': {'subject': '@business'}, 'default_state': {'name': 'Inventory Check', 'reading_type': 'stock_level'}},
+            {'type': 'actor.agent', 'alias': 'agent', 'refs': {'operator': '@business'}, 'default_state': {'name': 'Reorder Agent', 'capabilities': ['ordering']}},
+            {'type': 'transfer.order', 'alias': 'draft-order', 'refs': {'buyer': '@business', 'agent': '@agent'}, 'default_state': {'status': 'draft', 'draft': True}},
+            {'type': 'transfer.order', 'alias': 'confirmed-order', 'refs': {'buyer': '@business', 'updates': '@draft-order'}, 'default_state': {'status': 'confirmed'}}
         ]
     },
     'restaurant-sourcing': {
         'name': 'Restaurant Sourcing',
         'description': 'Restaurant needs ingredient \u2192 discovery \u2192 supplier offer \u2192 accept \u2192 order \u2192 delivery',
         'steps': [
-            {'type': 'actor.venue', 'alias': 'restaurant', 'required': True, 'default_state': {'name': 'Restaurant'}},
-            {'type': 'substance.ingredient', 'alias': 'needed', 'refs': {}, 'required': True, 'default_state': {'name': 'Ingredient Needed'}},
-            {'type': 'actor.producer', 'alias': 'supplier', 'required': True, 'default_state': {'name': 'Supplier'}},
-            {'type': 'transfer.offer', 'alias': 'offer', 'refs': {'seller': '@supplier', 'item': '@needed', 'buyer': '@restaurant'}, 'required': True, 'default_state': {'status': 'offered'}},
-            {'type': 'transfer.order', 'alias': 'order', 'refs': {'buyer': '@restaurant', 'seller': '@supplier', 'item': '@needed'}, 'required': True, 'default_state': {'status': 'confirmed'}},
-            {'type': 'transfer.delivery', 'alias': 'delivery', 'refs': {'order': '@order', 'seller': '@supplier', 'buyer': '@restaurant'}, 'required': True, 'default_state': {'status': 'delivered'}}
+            {'type': 'actor.venue', 'alias': 'restaurant', 'default_state': {'name': 'Restaurant'}},
+            {'type': 'substance.ingredient', 'alias': 'needed', 'default_state': {'name': 'Ingredient Needed'}},
+            {'type': 'actor.producer', 'alias': 'supplier', 'default_state': {'name': 'Supplier'}},
+            {'type': 'transfer.offer', 'alias': 'offer', 'refs': {'seller': '@supplier', 'item': '@needed', 'buyer': '@restaurant'}, 'default_state': {'status': 'offered'}},
+            {'type': 'transfer.order', 'alias': 'order', 'refs': {'buyer': '@restaurant', 'seller': '@supplier', 'item': '@needed'}, 'default_state': {'status': 'confirmed'}},
+            {'type': 'transfer.delivery', 'alias': 'delivery', 'refs': {'order': '@order', 'seller': '@supplier', 'buyer': '@restaurant'}, 'default_state': {'status': 'delivered'}}
         ]
     },
     'food-safety-audit': {
         'name': 'Food Safety Audit',
         'description': 'Inspector visits \u2192 readings taken \u2192 report \u2192 certification \u2192 attestation',
         'steps': [
-            {'type': 'actor.venue', 'alias': 'premises', 'required': True, 'default_state': {'name': 'Food Premises'}},
-            {'type': 'actor.producer', 'alias': 'inspector', 'required': True, 'default_state': {'name': 'Food Safety Inspector'}},
-            {'type': 'observe.reading', 'alias': 'readings', 'refs': {'subject': '@premises', 'author': '@inspector'}, 'required': True, 'default_state': {'name': 'Safety Readings'}},
-            {'type': 'observe.certification', 'alias': 'certificate', 'refs': {'subject': '@premises', 'authority': '@inspector'}, 'required': True, 'default_state': {'name': 'Food Safety Certificate'}},
-            {'type': 'observe.attestation', 'alias': 'attestation', 'refs': {'confirms': '@certificate', 'attestor': '@inspector'}, 'required': True, 'default_state': {'confidence': 'verified'}}
+            {'type': 'actor.venue', 'alias': 'premises', 'default_state': {'name': 'Food Premises'}},
+            {'type': 'actor.producer', 'alias': 'inspector', 'default_state': {'name': 'Food Safety Inspector'}},
+            {'type': 'observe.reading', 'alias': 'readings', 'refs': {'subject': '@premises', 'author': '@inspector'}, 'default_state': {'name': 'Safety Readings'}},
+            {'type': 'observe.certification', 'alias': 'certificate', 'refs': {'subject': '@premises', 'authority': '@inspector'}, 'default_state': {'name': 'Food Safety Certificate'}},
+            {'type': 'observe.attestation', 'alias': 'attestation', 'refs': {'confirms': '@certificate', 'attestor': '@inspector'}, 'default_state': {'confidence': 'verified'}}
         ]
     },
     'market-day': {
         'name': 'Market Day',
         'description': 'Producer brings stock \u2192 stall setup \u2192 sales \u2192 end-of-day surplus \u2192 donation',
         'steps': [
-            {'type': 'actor.producer', 'alias': 'producer', 'required': True, 'default_state': {'name': 'Market Producer'}},
-            {'type': 'place.market', 'alias': 'market', 'required': True, 'default_state': {'name': 'Farmers Market'}},
-            {'type': 'substance.product', 'alias': 'stock', 'refs': {'seller': '@producer'}, 'required': True, 'default_state': {'name': 'Market Stock'}},
-            {'type': 'transfer.order', 'alias': 'sales', 'refs': {'seller': '@producer', 'item': '@stock'}, 'required': False, 'default_state': {'status': 'completed'}},
-            {'type': 'substance.surplus', 'alias': 'leftover', 'refs': {'seller': '@producer', 'source': '@stock'}, 'required': False, 'default_state': {'name': 'End of Day Surplus', 'status': 'available'}}
+            {'type': 'actor.producer', 'alias': 'producer', 'default_state': {'name': 'Market Producer'}},
+            {'type': 'place.market', 'alias': 'market', 'default_state': {'name': 'Farmers Market'}},
+            {'type': 'substance.product', 'alias': 'stock', 'refs': {'seller': '@producer'}, 'default_state': {'name': 'Market Stock'}},
+            {'type': 'transfer.order', 'alias': 'sales', 'refs': {'seller': '@producer', 'item': '@stock'}, 'optional': True, 'default_state': {'status': 'completed'}},
+            {'type': 'substance.surplus', 'alias': 'leftover', 'refs': {'seller': '@producer', 'source': '@stock'}, 'optional': True, 'default_state': {'name': 'End of Day Surplus', 'status': 'available'}}
         ]
     },
     'cold-chain': {
         'name': 'Cold Chain',
         'description': 'Shipment departs \u2192 temperature readings \u2192 delivery \u2192 chain verified',
         'steps': [
-            {'type': 'actor.distributor', 'alias': 'carrier', 'required': True, 'default_state': {'name': 'Cold Chain Carrier'}},
-            {'type': 'transfer.delivery', 'alias': 'shipment', 'refs': {'carrier': '@carrier'}, 'required': True, 'default_state': {'status': 'in_transit'}},
-            {'type': 'observe.reading', 'alias': 'temp-log', 'refs': {'subject': '@shipment'}, 'required': True, 'default_state': {'name': 'Temperature Log', 'reading_type': 'temperature'}},
-            {'type': 'observe.attestation', 'alias': 'chain-verified', 'refs': {'confirms': '@shipment', 'attestor': '@carrier'}, 'required': True, 'default_state': {'confidence': 'verified', 'method': 'continuous_monitoring'}}
+            {'type': 'actor.distributor', 'alias': 'carrier', 'default_state': {'name': 'Cold Chain Carrier'}},
+            {'type': 'transfer.delivery', 'alias': 'shipment', 'refs': {'carrier': '@carrier'}, 'default_state': {'status': 'in_transit'}},
+            {'type': 'observe.reading', 'alias': 'temp-log', 'refs': {'subject': '@shipment'}, 'default_state': {'name': 'Temperature Log', 'reading_type': 'temperature'}},
+            {'type': 'observe.attestation', 'alias': 'chain-verified', 'refs': {'confirms': '@shipment', 'attestor': '@carrier'}, 'default_state': {'confidence': 'verified', 'method': 'continuous_monitoring'}}
         ]
     }
 }
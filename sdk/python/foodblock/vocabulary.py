@@ -196,121 +196,131 @@ def _compute_unmatched(text, consumed_spans):
 VOCABULARIES = {
     'bakery': {
         'domain': 'bakery',
-        'for_types': ['substance.product', 'transform.process', 'actor.producer'],
+        'for_types': ['substance.product', 'substance.ingredient', 'transform.process'],
         'fields': {
-            'name': {
-                'type': 'string',
-                'required': True,
-                'aliases': ['called', 'named', 'name'],
-                'description': 'Product or item name',
-            },
             'price': {
                 'type': 'number',
-                'required': False,
-                'aliases': ['costs', 'priced at', 'price', '$'],
-                'description': 'Price in local currency',
-            },
-            'organic': {
-                'type': 'boolean',
-                'required': False,
-                'aliases': ['organic', 'organically grown'],
-                'description': 'Whether the item is organic',
+                'aliases': ['price', 'cost', 'sells for', 'costs'],
+                'description': 'Price of the baked good',
             },
             'weight': {
                 'type': 'number',
-                'required': False,
-                'aliases': ['weighs', 'weight', 'grams', 'oz'],
+                'aliases': ['weight', 'weighs', 'grams', 'kg'],
                 'description': 'Weight of the product',
             },
+            'allergens': {
+                'type': 'compound',
+                'aliases': ['gluten', 'nuts', 'dairy', 'eggs', 'soy', 'wheat'],
+                'description': 'Allergens present in the product',
+            },
+            'name': {
+                'type': 'string',
+                'required': True,
+                'aliases': ['name', 'called', 'named'],
+                'description': 'Product name',
+            },
+            'organic': {
+                'type': 'boolean',
+                'aliases': ['organic', 'bio'],
+                'description': 'Whether the product is organic',
+            },
         },
     },
     'restaurant': {
         'domain': 'restaurant',
-        'for_types': ['substance.product', 'actor.venue', 'observe.review'],
+        'for_types': ['actor.venue', 'substance.product', 'observe.review'],
         'fields': {
-            'name': {
+            'cuisine': {
                 'type': 'string',
-                'required': True,
-                'aliases': ['called', 'named', 'name'],
-                'description': 'Dish or venue name',
-            },
-            'price': {
-                'type': 'number',
-                'required': False,
-                'aliases': ['costs', 'priced at', 'price', '$'],
-                'description': 'Menu price',
+                'aliases': ['cuisine', 'style', 'serves'],
+                'description': 'Type of cuisine served',
             },
             'rating': {
                 'type': 'number',
-                'required': False,
-                'aliases': ['rated', 'rating', 'stars', 'score'],
-                'description': 'Rating out of 5',
+                'aliases': ['rating', 'rated', 'stars', 'score'],
+                'description': 'Rating score',
             },
-            'cuisine': {
+            'price_range': {
                 'type': 'string',
-                'required': False,
-                'aliases': ['cuisine', 'style', 'type of food'],
-                'description': 'Cuisine type',
+                'aliases': ['price range', 'budget', 'expensive', 'cheap', 'moderate'],
+                'description': 'Price range category',
+            },
+            'halal': {
+                'type': 'boolean',
+                'aliases': ['halal'],
+                'description': 'Whether food is halal',
+            },
+            'kosher': {
+                'type': 'boolean',
+                'aliases': ['kosher'],
+                'description': 'Whether food is kosher',
+            },
+            'vegan': {
+                'type': 'boolean',
+                'aliases': ['vegan', 'plant-based'],
+                'description': 'Whether food is vegan',
             },
         },
     },
     'farm': {
         'domain': 'farm',
-        'for_types': ['substance.ingredient', 'actor.producer', 'observe.certification'],
+        'for_types': ['actor.producer', 'substance.ingredient', 'observe.certification'],
         'fields': {
-            'name': {
+            'crop': {
                 'type': 'string',
-                'required': True,
-                'aliases': ['called', 'named', 'name'],
-                'description': 'Farm or crop name',
+                'aliases': ['crop', 'grows', 'produces', 'cultivates'],
+                'description': 'Primary crop or product',
+            },
+            'acreage': {
+                'type': 'number',
+                'aliases': ['acreage', 'acres', 'hectares', 'area'],
+                'description': 'Farm size',
             },
             'organic': {
                 'type': 'boolean',
-                'required': False,
-                'aliases': ['organic', 'organically grown', 'certified organic'],
-                'description': 'Organic certification status',
+                'aliases': ['organic', 'bio', 'chemical-free'],
+                'description': 'Whether the farm is organic',
             },
-            'harvest_date': {
+            'region': {
                 'type': 'string',
-                'required': False,
-                'aliases': ['harvested', 'picked', 'harvest date'],
-                'description': 'Date of harvest',
+                'aliases': ['region', 'location', 'from', 'based in'],
+                'description': 'Geographic region',
             },
-            'quantity': {
-                'type': 'number',
-                'required': False,
-                'aliases': ['quantity', 'amount', 'bushels', 'kg', 'lbs'],
-                'description': 'Quantity produced',
+            'seasonal': {
+                'type': 'boolean',
+                'aliases': ['seasonal'],
+                'description': 'Whether production is seasonal',
             },
         },
     },
     'retail': {
         'domain': 'retail',
-        'for_types': ['substance.product', 'actor.venue', 'transfer.order'],
+        'for_types': ['actor.venue', 'substance.product', 'transfer.order'],
         'fields': {
-            'name': {
-                'type': 'string',
-                'required': True,
-                'aliases': ['called', 'named', 'name'],
-                'description': 'Product name',
-            },
             'price': {
                 'type': 'number',
-                'required': False,
-                'aliases': ['costs', 'priced at', 'price', '$', 'retail price'],
+                'aliases': ['price', 'cost', 'sells for', 'priced at'],
                 'description': 'Retail price',
             },
             'sku': {
                 'type': 'string',
-                'required': False,
                 'aliases': ['sku', 'product code', 'item number'],
                 'description': 'Stock keeping unit',
             },
-            'in_stock': {
+            'quantity': {
+                'type': 'number',
+                'aliases': ['quantity', 'qty', 'count', 'units'],
+                'description': 'Available quantity',
+            },
+            'category': {
+                'type': 'string',
+                'aliases': ['category', 'department', 'section', 'aisle'],
+                'description': 'Product category',
+            },
+            'on_sale': {
                 'type': 'boolean',
-                'required': False,
-                'aliases': ['in stock', 'available', 'in-stock'],
-                'description': 'Whether the product is in stock',
+                'aliases': ['on sale', 'discounted', 'clearance'],
+                'description': 'Whether the item is on sale',
             },
         },
     },
@@ -421,6 +431,14 @@ VOCABULARIES = {
             'cancelled': [],
             'returned': ['order'],
         },
+        'guards': {
+            'order->confirmed': [
+                {'name': 'payment_ref', 'require_state': 'payment_ref', 'description': 'Order must carry a payment reference'},
+            ],
+            'shipped->delivered': [
+                {'name': 'carrier_ack', 'require_block': 'observe.acknowledgement', 'from_ref': 'carrier', 'description': 'Carrier must acknowledge delivery'},
+            ],
+        },
     },
     'distributor': {
         'domain': 'distributor',
@@ -523,6 +541,11 @@ VOCABULARIES = {
                 'aliases': ['market', 'farmers market', 'street market', 'food market'],
                 'description': 'Name or type of the market',
             },
+            'stall_count': {
+                'type': 'number',
+                'aliases': ['stalls', 'pitches', 'stands'],
+                'description': 'Number of pitches the market lets',
+            },
         },
     },
     'catering': {
@@ -33,6 +33,11 @@ class TestCanonical:
         assert " " not in result
         assert "\n" not in result
 
+    def test_large_integer_floats(self):
+        # Matches JS String(n) past 2^53, where repr(n) still ends in ".0"
+        assert canonical("test", {"v": 2.0**53 + 2}, {}) == '{"refs":{},"state":{"v":9007199254740994},"type":"test"}'
+        assert canonical("test", {"v": 1e21}, {}) == '{"refs":{},"state":{"v":1e+21},"type":"test"}'
+
     def test_omits_nulls(self):
         result = canonical("test", {"a": 1, "b": None}, {})
         assert '"b"' not in result
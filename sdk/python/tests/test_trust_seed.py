@@ -1,11 +1,13 @@
 """Tests for trust computation and seed data modules."""
 
+import json
+import os
 import pytest
 from datetime import datetime, timezone, timedelta
 from foodblock import (
     create, compute_trust, connection_density, create_trust_policy,
     DEFAULT_WEIGHTS, seed_vocabularies, seed_templates, seed_all,
-    VOCABULARIES, TEMPLATES,
+    VOCABULARIES, TEMPLATES, map_fields, from_template,
 )
 
 
@@ -226,6 +228,73 @@ class TestSeedAll:
         assert len(hashes) == len(all_blocks)
 
 
+# --- Parity with the JavaScript seeds ---
+
+SEED_HASHES = os.path.join(os.path.dirname(__file__), '..', '..', '..', 'test', 'seed-hashes.json')
+
+
+def seed_key(block):
+    state = block['state']
+    if block['type'] == 'observe.vocabulary':
+        return 'vocabulary:' + state['domain']
+    return 'template:' + state['name']
+
+
+def vocab(domain):
+    return {'state': VOCABULARIES[domain]}
+
+
+class TestSeedParity:
+    def test_hashes_match_javascript(self):
+        with open(SEED_HASHES) as f:
+            expected = json.load(f)
+        for block in seed_all():
+            key = seed_key(block)
+            if key in expected:
+                assert block['hash'] == expected[key], key
+
+    def test_bakery_aliases(self):
+        result = map_fields('sells for 4.50, bio', vocab('bakery'))
+        assert result['matched']['price'] == 4.5
+        assert result['matched']['organic'] is True
+
+    def test_restaurant_dietary_fields(self):
+        result = map_fields('halal and kosher, rated 4', vocab('restaurant'))
+        assert result['matched']['halal'] is True
+        assert result['matched']['kosher'] is True
+        assert result['matched']['rating'] == 4
+        assert map_fields('plant-based', vocab('restaurant'))['matched']['vegan'] is True
+
+    def test_farm_acreage(self):
+        result = map_fields('acreage 40, seasonal', vocab('farm'))
+        assert result['matched']['acreage'] == 40
+        assert result['matched']['seasonal'] is True
+        assert 'crop' in VOCABULARIES['farm']['fields']
+        assert 'region' in VOCABULARIES['farm']['fields']
+
+    def test_retail_sale_and_category(self):
+        result = map_fields('dairy aisle, on sale, qty 12', vocab('retail'))
+        assert result['matched']['on_sale'] is True
+        assert result['matched']['quantity'] == 12
+        assert 'category' in result['matched']
+
+    def test_template_steps_use_default_state(self):
+        for template in TEMPLATES.values():
+            for step in template['steps']:
+                assert 'defaultState' not in step
+                assert 'state' not in step
+
+    def test_market_day_optional_steps(self):
+        steps = TEMPLATES['market-day']['steps']
+        assert [s['alias'] for s in steps if s.get('optional')] == ['sales', 'leftover']
+
+    def test_from_template_applies_default_state(self):
+        blocks = from_template({'state': TEMPLATES['market-day']})
+        assert blocks[0]['state']['name'] == 'Market Producer'
+        assert blocks[3]['state']['status'] == 'completed'
+        assert blocks[3]['refs']['item'] == blocks[2]['hash']
+
+
 # --- Instance_id auto-injection tests ---
 
 class TestInstanceIdAutoInject:
@@ -0,0 +1,12 @@
+const { seedAll } = require('../sdk/javascript/src/seed')
+
+// Hash of every JS seed block by key, for the other SDKs to compare against
+const hashes = {}
+for (const b of seedAll()) {
+  const key = b.type === 'observe.vocabulary' ? `vocabulary:${b.state.domain}` : `template:${b.state.name}`
+  hashes[key] = b.hash
+}
+
+const sorted = {}
+for (const k of Object.keys(hashes).sort()) sorted[k] = hashes[k]
+console.log(JSON.stringify(sorted, null, 2))